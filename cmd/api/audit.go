@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mikko-kohtala/go-api/internal/audit"
+)
+
+// runAudit implements `api audit verify <file>`, checking the hash chain of an
+// audit log written via AUDIT_LOG_FILE. It returns the process exit code.
+func runAudit(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 2 || args[0] != "verify" {
+		fmt.Fprintln(stderr, "usage: api audit verify <file>")
+		return 2
+	}
+	f, err := os.Open(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "audit verify: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	res, err := audit.Verify(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "audit verify: %v (%d records valid before the break)\n", err, res.Records)
+		return 1
+	}
+	fmt.Fprintf(stdout, "audit verify: ok, %d records from seq %d, last hash %s\n", res.Records, res.FirstSeq, res.LastHash)
+	return 0
+}
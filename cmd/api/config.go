@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+// runConfig implements `api config schema` (JSON Schema of the environment
+// variables) and `api config env` (an example .env with the defaults). It
+// returns the process exit code.
+func runConfig(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: api config schema|env")
+		return 2
+	}
+	switch args[0] {
+	case "schema":
+		schema, err := config.JSONSchema()
+		if err != nil {
+			fmt.Fprintf(stderr, "config schema: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s\n", schema)
+	case "env":
+		fmt.Fprint(stdout, config.ExampleEnv())
+	default:
+		fmt.Fprintln(stderr, "usage: api config schema|env")
+		return 2
+	}
+	return 0
+}
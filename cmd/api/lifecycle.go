@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/assets"
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/degradation"
+	"github.com/mikko-kohtala/go-api/internal/httpserver"
+	"github.com/mikko-kohtala/go-api/internal/invalidation"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/retention"
+	"github.com/mikko-kohtala/go-api/internal/shutdown"
+	"github.com/mikko-kohtala/go-api/internal/snapshot"
+	"github.com/mikko-kohtala/go-api/internal/streams"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+)
+
+// lifecycle shuts the server down in phases: readiness fails for
+// SHUTDOWN_DRAIN_DELAY so load balancers stop sending traffic, streaming
+// clients are asked to leave, the listeners drain, and then the hooks on
+// shutdown.Default stop the rest stage by stage.
+type lifecycle struct {
+	cfg       *config.Config
+	logger    *slog.Logger
+	listeners []httpserver.Listener
+	servers   []*http.Server
+	hooks     *shutdown.Hooks
+}
+
+// registerShutdownHooks registers the hooks of the subsystems main starts.
+// Subsystems the router starts, such as the database pool and the rate
+// limiters' Redis client, register their own.
+func registerShutdownHooks(cfg *config.Config, logger *slog.Logger) {
+	for _, c := range []struct {
+		name string
+		stop func()
+	}{
+		{"retention", retention.Default.Stop},
+		{"degradation", degradation.Default.Stop},
+		{"assets", assets.Default.Stop},
+		{"invalidation", invalidation.Default.Stop},
+	} {
+		shutdown.Default.Register(shutdown.Hook{Name: c.name, Stage: shutdown.StageComponents, Timeout: cfg.ShutdownStopTimeout, Stop: shutdown.Func(c.stop)})
+	}
+
+	// Queued background jobs finish within what is left of the deadline
+	shutdown.Default.Register(shutdown.Hook{Name: "jobs", Stage: shutdown.StageWorkers, Stop: jobs.Default.Shutdown})
+
+	// Keep the in-memory data for the next start, after the last writes
+	if cfg.SnapshotFile != "" {
+		shutdown.Default.Register(shutdown.Hook{Name: "snapshot", Stage: shutdown.StageStorage, Stop: func(context.Context) error {
+			if err := snapshot.Default.SaveFile(cfg.SnapshotFile); err != nil {
+				return err
+			}
+			logger.Info("snapshot saved", slog.String("file", cfg.SnapshotFile))
+			return nil
+		}})
+	}
+
+	// Export the spans of the last requests and jobs
+	shutdown.Default.Register(shutdown.Hook{Name: "tracing", Stage: shutdown.StageTelemetry, Timeout: cfg.ShutdownStopTimeout, Stop: tracing.Default.Shutdown})
+}
+
+// shutdown runs the phases after sig and logs the shutdown report. Another
+// signal during the drain delay cuts the delay short.
+func (l *lifecycle) shutdown(sig os.Signal, quit <-chan os.Signal) {
+	report := shutdown.Start(sig.String())
+
+	// Fail readiness first, while still serving, so load balancers take the
+	// instance out of rotation before it stops accepting connections
+	shutdown.SetDraining(true)
+	if l.cfg.ShutdownDrainDelay > 0 {
+		l.logger.Info("readiness off, waiting for load balancers", slog.Duration("drain_delay", l.cfg.ShutdownDrainDelay))
+		_ = report.Run(context.Background(), "readiness", 0, func(ctx context.Context) error {
+			select {
+			case <-time.After(l.cfg.ShutdownDrainDelay):
+			case <-quit:
+				l.logger.Info("second signal received, skipping the rest of the drain delay")
+			}
+			return nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Ask streaming clients to disconnect first; srv.Shutdown would otherwise wait on them until the deadline
+	if err := report.Run(ctx, "streams", 0, func(ctx context.Context) error {
+		_, err := streams.Default.Drain(ctx)
+		return err
+	}); err != nil {
+		l.logger.Warn("streams did not close before shutdown deadline", slog.Int("remaining", streams.Default.Active()))
+	}
+
+	// Drain all listeners concurrently so they share the same deadline
+	inFlight, drainStart := metrics.InFlight(), time.Now()
+	var wg sync.WaitGroup
+	for i, srv := range l.servers {
+		wg.Add(1)
+		go func(name string, srv *http.Server) {
+			defer wg.Done()
+			if err := report.Run(ctx, "listener:"+name, 0, func(ctx context.Context) error {
+				return httpserver.Shutdown(ctx, srv, l.logger.With(slog.String("listener", name)))
+			}); err != nil {
+				l.logger.Error("graceful shutdown failed", slog.String("listener", name), slog.String("error", err.Error()))
+				_ = srv.Close()
+			}
+		}(l.listeners[i].Name, srv)
+	}
+	wg.Wait()
+	report.SetRequests(inFlight, metrics.InFlight(), time.Since(drainStart))
+
+	pending := jobs.Default.Pending() + jobs.Default.Running()
+	l.hooks.Run(ctx, report, l.logger)
+	report.SetJobs(pending, jobs.Default.Pending()+jobs.Default.Running())
+
+	report.Log(l.logger)
+}
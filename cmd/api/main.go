@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
@@ -11,13 +10,29 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
 
 	_ "go.uber.org/automaxprocs" // Auto-tune GOMAXPROCS for containers
 
+	"github.com/mikko-kohtala/go-api/internal/assets"
+	"github.com/mikko-kohtala/go-api/internal/boot"
 	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/degradation"
 	"github.com/mikko-kohtala/go-api/internal/httpserver"
+	"github.com/mikko-kohtala/go-api/internal/i18n"
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/invalidation"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/preflight"
+	"github.com/mikko-kohtala/go-api/internal/redis"
+	"github.com/mikko-kohtala/go-api/internal/retention"
+	"github.com/mikko-kohtala/go-api/internal/sampling"
+	"github.com/mikko-kohtala/go-api/internal/secrets"
+	"github.com/mikko-kohtala/go-api/internal/shutdown"
+	"github.com/mikko-kohtala/go-api/internal/snapshot"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+	"github.com/mikko-kohtala/go-api/internal/ws"
 	"github.com/mikko-kohtala/go-api/pkg/logger"
+	"github.com/mikko-kohtala/go-api/pkg/timestamp"
 )
 
 //go:generate swag init -g cmd/api/main.go -o internal/docs --parseDependency --parseInternal
@@ -32,6 +47,17 @@ func init() {
 }
 
 func main() {
+	// Offline maintenance commands run without loading server configuration
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mock" {
+		os.Exit(runMock(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Load configuration from env with sane defaults
 	cfg, err := config.Load()
 	if err != nil {
@@ -40,6 +66,7 @@ func main() {
 
 	// Configure logger using the new package
 	appLogger := logger.NewForEnvironment(cfg.Env)
+	boot.Default.Start(cfg.Env)
 
 	// CORS strict enforcement in production if enabled
 	if (cfg.Env == "production" || cfg.Env == "prod") && cfg.CORSStrict {
@@ -50,39 +77,122 @@ func main() {
 		}
 	}
 
+	// Size the shared background job pool before handlers start submitting to it
+	jobs.Default = jobs.NewPool(cfg.JobWorkers, cfg.JobQueueSize)
+	jobs.Default.SetHighWater(max(1, int(float64(cfg.JobQueueSize)*cfg.JobQueueHighWater)))
+	retention.Default = retention.NewScheduler(retention.Options{Interval: cfg.RetentionInterval, DryRun: cfg.RetentionDryRun})
+	assets.Default = assets.New(cfg.AssetsDir)
+	i18n.Default = i18n.NewCatalog(cfg.DefaultLanguage)
+	timestamp.Default.Skew = cfg.ClockSkew
+	ids.Default = ids.New(ids.Strategy(cfg.IDStrategy), cfg.IDPrefixed)
+	sampling.Default = sampling.New(sampling.Options{Rate: cfg.BodySampleRate, PerRoute: cfg.BodySamplePerRoute, MaxBodyBytes: cfg.BodySampleMaxBytes})
+	var secretSource secrets.Source = secrets.Env{Prefix: cfg.SecretsEnvPrefix}
+	if cfg.SecretsSource == "dir" {
+		secretSource = secrets.Dir{Path: cfg.SecretsDir}
+	}
+	secrets.Default = secrets.NewResolver(secretSource, cfg.SecretsCacheTTL)
+	ws.Default = ws.NewHub(ws.Options{PingInterval: cfg.WSPingInterval, MaxMessageBytes: cfg.WSMaxMessageBytes, AllowedOrigins: cfg.WSAllowedOrigins})
+	degradation.Default = degradation.New(degradation.Options{
+		Interval:     cfg.DependencyCheckInterval,
+		Timeout:      cfg.PreflightTimeout,
+		Features:     cfg.DegradedFeatures,
+		CacheEntries: cfg.DegradedCacheEntries,
+	})
+	if cfg.OTELEndpoint != "" {
+		tracing.Default = tracing.New(tracing.NewExporter(tracing.ExporterOptions{
+			Endpoint:    cfg.OTELEndpoint,
+			Headers:     cfg.OTELHeaders,
+			ServiceName: cfg.OTELServiceName,
+			Logger:      appLogger,
+		}), cfg.OTELSampleRatio)
+	}
+	registerDependencies(cfg, appLogger)
+	if cfg.RedisURL != "" {
+		opts, _ := redis.ParseURL(cfg.RedisURL) // validated by config.Load
+		broker := invalidation.NewRedisBroker(opts, cfg.CacheInvalidationChannel)
+		invalidation.Default = invalidation.New(broker, invalidation.Options{})
+		shutdown.Default.Register(shutdown.Hook{Name: "invalidation:redis", Stage: shutdown.StageStorage, Timeout: cfg.ShutdownStopTimeout, Stop: func(context.Context) error { return broker.Close() }})
+	}
+	registerShutdownHooks(cfg, appLogger)
+
 	// Build the HTTP server (router, middleware, handlers)
-	mux := httpserver.NewRouter(cfg, appLogger)
-
-	srv := &http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.Port),
-		Handler:           mux,
-		ReadTimeout:       15 * time.Second,
-		ReadHeaderTimeout: 10 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		MaxHeaderBytes:    1 << 20, // 1 MiB
+	mux, err := httpserver.NewCheckedRouter(cfg, appLogger)
+	if err != nil {
+		log.Fatalf("refusing to start: %v", err)
 	}
 
-	// Start server in background
-	go func() {
-		appLogger.Info("Started server", slog.Int("port", cfg.Port))
-		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			appLogger.Error("Server failed", slog.String("error", err.Error()))
-			os.Exit(1)
+	// Bring back the development data of the last run
+	if cfg.SnapshotFile != "" {
+		restored, err := snapshot.Default.LoadFile(cfg.SnapshotFile)
+		if err != nil {
+			log.Fatalf("refusing to start: snapshot %s: %v", cfg.SnapshotFile, err)
+		}
+		if len(restored) > 0 {
+			appLogger.Info("snapshot restored", slog.String("file", cfg.SnapshotFile), slog.Any("stores", restored))
+		}
+	}
+
+	// Verify external dependencies before accepting traffic
+	if cfg.PreflightRequired {
+		results, err := preflight.Run(context.Background(), preflightChecks(cfg), cfg.PreflightTimeout)
+		for _, r := range results {
+			if r.Err == nil {
+				appLogger.Info("preflight check passed", slog.String("check", r.Name), slog.Duration("duration", r.Duration))
+			}
 		}
-	}()
+		boot.Default.SetChecks(results)
+		if err != nil {
+			boot.Default.Log(appLogger)
+			log.Fatalf("refusing to start: %v", err)
+		}
+	}
+	boot.Default.Log(appLogger)
+
+	// Degrade features while the dependencies they need are down
+	degradation.Default.Start(appLogger)
+
+	// Apply data retention policies registered by the router on a schedule
+	retention.Default.Start(appLogger)
+
+	// Share cache invalidations with the other replicas
+	invalidation.Default.Start(appLogger)
+
+	// Pick up edited templates from ASSETS_DIR without a restart, except in production
+	if cfg.Env != "production" && cfg.Env != "prod" {
+		assets.Default.Watch(cfg.AssetsReloadInterval, appLogger)
+	}
+
+	// Start every configured listener in the background; they share the router
+	listeners := httpserver.Listeners(cfg)
+	servers := make([]*http.Server, 0, len(listeners))
+	for _, l := range listeners {
+		srv := httpserver.NewServer(l, mux)
+		servers = append(servers, srv)
+		go func(l httpserver.Listener, srv *http.Server) {
+			appLogger.Info("Started server", slog.String("listener", l.Name), slog.String("addr", l.Addr), slog.Bool("tls", l.TLS()), slog.Bool("proxy_protocol", l.ProxyProtocol))
+			ln, err := l.Listen()
+			if err != nil {
+				appLogger.Error("Server failed", slog.String("listener", l.Name), slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+			if l.TLS() {
+				err = srv.ServeTLS(ln, l.CertFile, l.KeyFile)
+			} else {
+				err = srv.Serve(ln)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				appLogger.Error("Server failed", slog.String("listener", l.Name), slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+		}(l, srv)
+	}
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	sig := <-quit
 	appLogger.Info("shutdown signal received")
-
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		appLogger.Error("graceful shutdown failed", slog.String("error", err.Error()))
-		_ = srv.Close()
-	}
+	lc := &lifecycle{cfg: cfg, logger: appLogger, listeners: listeners, servers: servers, hooks: shutdown.Default}
+	lc.shutdown(sig, quit)
 	appLogger.Info("server stopped")
 }
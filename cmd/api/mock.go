@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/docs"
+	"github.com/mikko-kohtala/go-api/internal/mock"
+)
+
+// runMock implements `api mock`, serving example responses generated from
+// the Swagger document until interrupted. It returns the process exit code.
+func runMock(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", ":4010", "listen address")
+	specFile := fs.String("spec", "", "Swagger JSON file (the built-in API document when empty)")
+	latency := fs.Duration("latency", 0, "latency added to every response")
+	jitter := fs.Duration("jitter", 0, "up to this much random extra latency")
+	errorRate := fs.Float64("error-rate", 0, "share of requests (0-1) answered with a documented error")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *errorRate < 0 || *errorRate > 1 {
+		fmt.Fprintln(stderr, "mock: -error-rate must be between 0 and 1")
+		return 2
+	}
+
+	doc := []byte(docs.SwaggerInfo.ReadDoc())
+	if *specFile != "" {
+		b, err := os.ReadFile(*specFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "mock: %v\n", err)
+			return 1
+		}
+		doc = b
+	}
+	spec, err := mock.ParseSpec(doc)
+	if err != nil {
+		fmt.Fprintf(stderr, "mock: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "mock: serving %d paths on %s (latency %s + up to %s, error rate %.2f)\n", len(spec.Paths), *addr, *latency, *jitter, *errorRate)
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mock.Handler(spec, mock.Options{Latency: *latency, Jitter: *jitter, ErrorRate: *errorRate}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "mock: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"slices"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/degradation"
+	"github.com/mikko-kohtala/go-api/internal/preflight"
+	"github.com/mikko-kohtala/go-api/internal/redis"
+)
+
+// preflightChecks lists the configured external dependencies: the storage
+// locations, the Postgres server users are stored in, the Redis server
+// carrying cache invalidations, notification and alert transports, and the
+// canary upstream. The same checks feed degradation while serving.
+func preflightChecks(cfg *config.Config) []preflight.Check {
+	var checks []preflight.Check
+	if cfg.StorageDir != "" {
+		checks = append(checks, preflight.WritableDir("storage", cfg.StorageDir))
+	}
+	if cfg.AuditLogFile != "" {
+		checks = append(checks, preflight.WritableDir("audit log", filepath.Dir(cfg.AuditLogFile)))
+	}
+	if cfg.QuotaStateFile != "" {
+		checks = append(checks, preflight.WritableDir("quota state", filepath.Dir(cfg.QuotaStateFile)))
+	}
+	if cfg.FeatureFlagsProvider == "file" {
+		checks = append(checks, preflight.ReadableFile("feature flags", cfg.FeatureFlagsConfig))
+	}
+	if cfg.DatabaseURL != "" {
+		checks = append(checks, preflight.URL("database", cfg.DatabaseURL))
+	}
+	if cfg.RedisURL != "" {
+		opts, _ := redis.ParseURL(cfg.RedisURL)
+		checks = append(checks, preflight.TCP("redis", opts.Addr))
+	}
+	if cfg.NotifySMTPAddr != "" {
+		checks = append(checks, preflight.TCP("smtp", cfg.NotifySMTPAddr))
+	}
+	for _, u := range []struct{ name, url string }{
+		{"sms webhook", cfg.NotifySMSWebhookURL},
+		{"push webhook", cfg.NotifyPushWebhookURL},
+		{"slack webhook", cfg.NotifySlackWebhookURL},
+		{"alert webhook", cfg.AlertWebhookURL},
+		{"canary upstream", cfg.CanaryUpstreamURL},
+	} {
+		if u.url != "" {
+			checks = append(checks, preflight.URL(u.name, u.url))
+		}
+	}
+	return checks
+}
+
+// registerDependencies adds the checks of the dependencies degraded features
+// need to degradation.Default and logs those that are not configured.
+func registerDependencies(cfg *config.Config, logger *slog.Logger) {
+	for _, check := range preflightChecks(cfg) {
+		for _, deps := range cfg.DegradedFeatures {
+			if slices.Contains(deps, check.Name) {
+				degradation.Default.Register(check)
+				break
+			}
+		}
+	}
+	if missing := degradation.Default.Unregistered(); len(missing) > 0 {
+		logger.Warn("DEGRADED_FEATURES names dependencies that are not configured; they never degrade a feature", slog.Any("dependencies", missing))
+	}
+}
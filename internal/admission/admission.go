@@ -0,0 +1,179 @@
+// Package admission limits how many requests are served at once. Requests
+// over the limit wait in one queue per priority class and are admitted by
+// weighted fair queuing, so a burst of bulk traffic delays health checks and
+// interactive reads only in proportion to the class weights instead of
+// starving them.
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Class is a request priority class.
+type Class string
+
+// Priority classes, highest first.
+const (
+	Critical    Class = "critical"    // health checks and operator endpoints
+	Interactive Class = "interactive" // reads a user is waiting on
+	Normal      Class = "normal"      // other API calls
+	Bulk        Class = "bulk"        // exports, imports and uploads
+)
+
+// Classes lists the priority classes, highest first.
+var Classes = []Class{Critical, Interactive, Normal, Bulk}
+
+// DefaultWeights is the share of freed slots each class gets while several
+// classes are waiting.
+var DefaultWeights = map[Class]int{Critical: 8, Interactive: 4, Normal: 2, Bulk: 1}
+
+// Valid reports whether c is a known class.
+func (c Class) Valid() bool {
+	_, ok := DefaultWeights[c]
+	return ok
+}
+
+// ErrQueueFull is returned by Acquire when no more requests may wait.
+var ErrQueueFull = errors.New("admission queue full")
+
+type waiter struct {
+	ready chan struct{}
+}
+
+// Limiter admits up to a fixed number of concurrent holders.
+type Limiter struct {
+	mu       sync.Mutex
+	limit    int
+	inUse    int
+	maxQueue int
+	queued   int
+	weights  map[Class]int
+	queues   map[Class][]*waiter
+	current  map[Class]int // smooth weighted round-robin state
+}
+
+// NewLimiter creates a limiter admitting limit concurrent holders with up to
+// maxQueue waiting. Classes missing from weights get weight 1.
+func NewLimiter(limit, maxQueue int, weights map[Class]int) *Limiter {
+	return &Limiter{
+		limit:    limit,
+		maxQueue: maxQueue,
+		weights:  weights,
+		queues:   make(map[Class][]*waiter),
+		current:  make(map[Class]int),
+	}
+}
+
+// Acquire waits for a slot for a request of class c. It returns ErrQueueFull
+// when the queue is full, or ctx.Err() when ctx ends first. On success the
+// caller must call release exactly once.
+func (l *Limiter) Acquire(ctx context.Context, c Class) (release func(), err error) {
+	l.mu.Lock()
+	if l.inUse < l.limit && l.queued == 0 {
+		l.inUse++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if l.queued >= l.maxQueue {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	l.queues[c] = append(l.queues[c], w)
+	l.queued++
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.release, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		removed := l.remove(c, w)
+		l.mu.Unlock()
+		if !removed {
+			// The slot was handed over while ctx ended; pass it on
+			l.release()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// InUse returns the number of held slots and waiting requests.
+func (l *Limiter) InUse() (held, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse, l.queued
+}
+
+// release hands the slot to the next waiter, or frees it.
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.next()
+	if !ok {
+		l.inUse--
+		return
+	}
+	w := l.queues[c][0]
+	l.queues[c] = l.queues[c][1:]
+	l.queued--
+	close(w.ready)
+}
+
+// next picks the class to admit by smooth weighted round-robin over the
+// classes with waiters.
+func (l *Limiter) next() (Class, bool) {
+	var (
+		best  Class
+		found bool
+		total int
+	)
+	for _, c := range Classes {
+		if len(l.queues[c]) == 0 {
+			continue
+		}
+		w := l.weights[c]
+		if w <= 0 {
+			w = 1
+		}
+		l.current[c] += w
+		total += w
+		if !found || l.current[c] > l.current[best] {
+			best, found = c, true
+		}
+	}
+	if found {
+		l.current[best] -= total
+	}
+	return best, found
+}
+
+// remove drops w from the queue of c, reporting whether it was still queued.
+func (l *Limiter) remove(c Class, w *waiter) bool {
+	q := l.queues[c]
+	for i, qw := range q {
+		if qw == w {
+			l.queues[c] = append(q[:i:i], q[i+1:]...)
+			l.queued--
+			return true
+		}
+	}
+	return false
+}
+
+type classKey struct{}
+
+// WithClass returns ctx carrying the request's priority class.
+func WithClass(ctx context.Context, c Class) context.Context {
+	return context.WithValue(ctx, classKey{}, c)
+}
+
+// ClassFromContext returns the request's priority class, Normal by default.
+func ClassFromContext(ctx context.Context) Class {
+	if c, ok := ctx.Value(classKey{}).(Class); ok {
+		return c
+	}
+	return Normal
+}
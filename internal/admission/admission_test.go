@@ -0,0 +1,97 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitQueued blocks until l has n waiting requests.
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, queued := l.InUse(); queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued requests", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterAdmitsByWeight(t *testing.T) {
+	l := NewLimiter(1, 100, map[Class]int{Critical: 3, Bulk: 1})
+	release, err := l.Acquire(context.Background(), Normal)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	admitted := make(chan Class, 8)
+	enqueue := func(c Class) {
+		go func() {
+			rel, err := l.Acquire(context.Background(), c)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			admitted <- c
+			rel()
+		}()
+	}
+	// Bulk arrives first, but critical gets three of every four slots
+	for i := 0; i < 4; i++ {
+		enqueue(Bulk)
+		waitQueued(t, l, i+1)
+	}
+	for i := 0; i < 4; i++ {
+		enqueue(Critical)
+		waitQueued(t, l, 5+i)
+	}
+	release()
+
+	var order []Class
+	for i := 0; i < 8; i++ {
+		order = append(order, <-admitted)
+	}
+	critical := 0
+	for _, c := range order[:4] {
+		if c == Critical {
+			critical++
+		}
+	}
+	if critical != 3 {
+		t.Fatalf("expected 3 of the first 4 admissions to be critical, got order %v", order)
+	}
+	if held, queued := l.InUse(); held != 0 || queued != 0 {
+		t.Fatalf("expected an idle limiter, got held=%d queued=%d", held, queued)
+	}
+}
+
+func TestLimiterQueueFull(t *testing.T) {
+	l := NewLimiter(1, 0, DefaultWeights)
+	release, _ := l.Acquire(context.Background(), Normal)
+	defer release()
+	if _, err := l.Acquire(context.Background(), Critical); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestLimiterAcquireGivesUpWithContext(t *testing.T) {
+	l := NewLimiter(1, 10, DefaultWeights)
+	release, _ := l.Acquire(context.Background(), Normal)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, Bulk); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if _, queued := l.InUse(); queued != 0 {
+		t.Fatalf("expected the timed out request to leave the queue, got %d queued", queued)
+	}
+	release()
+	if held, _ := l.InUse(); held != 0 {
+		t.Fatalf("expected the slot to be freed, got %d held", held)
+	}
+}
@@ -0,0 +1,200 @@
+// Package alert posts operational alerts (panics, 5xx spikes) to a Slack or
+// generic JSON webhook. Alerts are deduplicated so an incident produces one
+// message per window rather than one per request.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/scrub"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+	"github.com/mikko-kohtala/go-api/pkg/safego"
+)
+
+// maxSampleRequests bounds how many request IDs are quoted in a spike alert.
+const maxSampleRequests = 5
+
+// Options configure an Alerter. Zero values fall back to the defaults noted.
+type Options struct {
+	// Sender delivers the rendered alert, e.g. a notify.WebhookProvider.
+	Sender notify.Provider
+	// Service names the deployment in alert titles.
+	Service string
+	// TraceURL links request IDs to a log or trace viewer; "{request_id}" is replaced.
+	TraceURL string
+	// ErrorRateThreshold is the fraction of 5xx responses within ErrorWindow
+	// that triggers a spike alert (default 0.05), once MinRequests were seen (default 20).
+	ErrorRateThreshold float64
+	ErrorWindow        time.Duration // default 1m
+	MinRequests        int
+	// DedupWindow suppresses repeats of the same alert (default 10m).
+	DedupWindow time.Duration
+	Logger      *slog.Logger
+}
+
+// Alerter detects alert conditions and sends rate-limited notifications.
+// A nil *Alerter is valid and does nothing.
+type Alerter struct {
+	opts Options
+	now  func() time.Time
+
+	mu          sync.Mutex
+	lastSent    map[string]time.Time
+	suppressed  map[string]int
+	windowStart time.Time
+	total       int
+	errors      int
+	samples     []string
+}
+
+// New creates an Alerter.
+func New(opts Options) *Alerter {
+	if opts.ErrorRateThreshold <= 0 {
+		opts.ErrorRateThreshold = 0.05
+	}
+	if opts.ErrorWindow <= 0 {
+		opts.ErrorWindow = time.Minute
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.DedupWindow <= 0 {
+		opts.DedupWindow = 10 * time.Minute
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Alerter{
+		opts:       opts,
+		now:        time.Now,
+		lastSent:   make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// Panic reports a recovered panic for request r.
+func (a *Alerter) Panic(r *http.Request, rvr any) {
+	if a == nil {
+		return
+	}
+	value := scrub.Value(rvr)
+	rid := pkglogger.RequestIDFromContext(r.Context())
+	text := fmt.Sprintf("panic: %s\n%s %s", value, r.Method, scrub.URL(r.URL))
+	if h := requestctx.Handler(r.Context()); h != "" {
+		text += "\nhandler: " + h
+	}
+	if len(value) > 200 {
+		value = value[:200]
+	}
+	a.send("panic:"+value, "Panic recovered", text, []string{rid})
+}
+
+// BackgroundPanic reports a panic recovered in the named background goroutine.
+func (a *Alerter) BackgroundPanic(ctx context.Context, name string, rvr any) {
+	if a == nil {
+		return
+	}
+	value := scrub.Value(rvr)
+	rid := pkglogger.RequestIDFromContext(ctx)
+	text := fmt.Sprintf("panic: %s\ngoroutine: %s", value, name)
+	if len(value) > 200 {
+		value = value[:200]
+	}
+	a.send("panic:"+name+":"+value, "Panic recovered", text, []string{rid})
+}
+
+// Middleware records response statuses and alerts when the share of 5xx
+// responses crosses the threshold within the window.
+func (a *Alerter) Middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := respwriter.Wrap(w)
+		next.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		a.observe(r, status)
+	})
+}
+
+func (a *Alerter) observe(r *http.Request, status int) {
+	now := a.now()
+	a.mu.Lock()
+	if now.Sub(a.windowStart) > a.opts.ErrorWindow {
+		a.windowStart, a.total, a.errors, a.samples = now, 0, 0, nil
+	}
+	a.total++
+	if status >= 500 {
+		a.errors++
+		if rid := pkglogger.RequestIDFromContext(r.Context()); rid != "" && len(a.samples) < maxSampleRequests {
+			a.samples = append(a.samples, rid)
+		}
+	}
+	total, errs := a.total, a.errors
+	rate := float64(errs) / float64(total)
+	fire := total >= a.opts.MinRequests && rate >= a.opts.ErrorRateThreshold
+	samples := append([]string(nil), a.samples...)
+	a.mu.Unlock()
+
+	if fire {
+		text := fmt.Sprintf("%d of %d requests (%.1f%%) failed with 5xx in the last %s",
+			errs, total, rate*100, a.opts.ErrorWindow)
+		a.send("error_spike", "5xx error spike", text, samples)
+	}
+}
+
+// send delivers an alert unless the same key was sent within the dedup window.
+func (a *Alerter) send(key, title, text string, requestIDs []string) {
+	now := a.now()
+	a.mu.Lock()
+	if last, ok := a.lastSent[key]; ok && now.Sub(last) < a.opts.DedupWindow {
+		a.suppressed[key]++
+		a.mu.Unlock()
+		return
+	}
+	a.lastSent[key] = now
+	suppressed := a.suppressed[key]
+	delete(a.suppressed, key)
+	a.mu.Unlock()
+
+	if a.opts.Service != "" {
+		title = "[" + a.opts.Service + "] " + title
+	}
+	var body strings.Builder
+	body.WriteString(text)
+	for _, rid := range requestIDs {
+		if rid == "" {
+			continue
+		}
+		body.WriteString("\nrequest_id: " + rid)
+		if a.opts.TraceURL != "" {
+			body.WriteString(" " + strings.ReplaceAll(a.opts.TraceURL, "{request_id}", rid))
+		}
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(&body, "\n(%d similar alerts suppressed)", suppressed)
+	}
+
+	// Deliver in the background so a slow webhook never delays the request
+	msg := notify.Message{Channel: notify.ChannelSlack, Subject: title, Body: body.String()}
+	safego.GoNamed(pkglogger.IntoContext(context.Background(), a.opts.Logger), "alert_delivery", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := a.opts.Sender.Send(ctx, msg); err != nil {
+			return fmt.Errorf("alert %s: %w", key, err)
+		}
+		return nil
+	})
+}
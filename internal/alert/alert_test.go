@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+type captureSender struct {
+	mu   sync.Mutex
+	sent []notify.Message
+	ch   chan struct{}
+}
+
+func newCaptureSender() *captureSender { return &captureSender{ch: make(chan struct{}, 10)} }
+
+func (c *captureSender) Send(ctx context.Context, msg notify.Message) error {
+	c.mu.Lock()
+	c.sent = append(c.sent, msg)
+	c.mu.Unlock()
+	c.ch <- struct{}{}
+	return nil
+}
+
+func (c *captureSender) wait(t *testing.T) notify.Message {
+	t.Helper()
+	select {
+	case <-c.ch:
+	case <-time.After(time.Second):
+		t.Fatalf("alert was not sent")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sent[len(c.sent)-1]
+}
+
+func (c *captureSender) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sent)
+}
+
+func requestWithID(id string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/users?token=secret", nil)
+	return r.WithContext(pkglogger.WithRequestID(r.Context(), id))
+}
+
+func TestAlerter_PanicIsDeduplicated(t *testing.T) {
+	sender := newCaptureSender()
+	a := New(Options{Sender: sender, Service: "api", TraceURL: "https://logs.example.com/?q={request_id}", DedupWindow: time.Minute})
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	a.Panic(requestWithID("req-1"), "nil map write")
+	msg := sender.wait(t)
+	if msg.Subject != "[api] Panic recovered" || !strings.Contains(msg.Body, "https://logs.example.com/?q=req-1") {
+		t.Fatalf("unexpected alert %+v", msg)
+	}
+	if strings.Contains(msg.Body, "secret") {
+		t.Fatalf("alert must not contain secrets: %s", msg.Body)
+	}
+
+	a.Panic(requestWithID("req-2"), "nil map write")
+	a.Panic(requestWithID("req-3"), "nil map write")
+	time.Sleep(20 * time.Millisecond)
+	if sender.count() != 1 {
+		t.Fatalf("expected repeats to be suppressed, got %d alerts", sender.count())
+	}
+
+	now = now.Add(2 * time.Minute)
+	a.Panic(requestWithID("req-4"), "nil map write")
+	if msg := sender.wait(t); !strings.Contains(msg.Body, "2 similar alerts suppressed") {
+		t.Fatalf("expected suppressed count in follow-up alert, got %s", msg.Body)
+	}
+}
+
+func TestAlerter_ErrorSpike(t *testing.T) {
+	sender := newCaptureSender()
+	a := New(Options{Sender: sender, ErrorRateThreshold: 0.5, MinRequests: 4})
+	status := http.StatusOK
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), requestWithID("ok"))
+	}
+	status = http.StatusBadGateway
+	h.ServeHTTP(httptest.NewRecorder(), requestWithID("bad-1"))
+	time.Sleep(20 * time.Millisecond)
+	if sender.count() != 0 {
+		t.Fatalf("expected no alert below the threshold")
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), requestWithID("bad-2"))
+	h.ServeHTTP(httptest.NewRecorder(), requestWithID("bad-3"))
+	msg := sender.wait(t)
+	if !strings.Contains(msg.Body, "3 of 6 requests") || !strings.Contains(msg.Body, "request_id: bad-1") {
+		t.Fatalf("unexpected spike alert %s", msg.Body)
+	}
+}
+
+func TestAlerter_NilIsNoop(t *testing.T) {
+	var a *Alerter
+	a.Panic(requestWithID("x"), "boom")
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), requestWithID("x"))
+}
@@ -0,0 +1,261 @@
+// Package apiversion keeps old clients working across request and response
+// shape changes. Clients name the dated API version they were written
+// against in the API-Version header; migrations newer than that version
+// rewrite the request body into the current shape before the handler runs,
+// and rewrite the response back into the old shape, so handlers only know
+// the current shapes.
+package apiversion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// Header carries the API version a client was written against.
+const Header = "API-Version"
+
+// dateLayout is the format of versions.
+const dateLayout = "2006-01-02"
+
+// Migration is one change of a route's shapes.
+type Migration struct {
+	Version     string // first version with the new shape (YYYY-MM-DD)
+	Method      string // HTTP method; any when empty
+	Route       string // path pattern such as /api/v1/tasks/{id}; {name} matches one segment
+	Description string
+
+	// Request rewrites an old-shape request body into the new shape.
+	Request func(body map[string]any) error
+	// Response rewrites a new-shape response body into the old shape.
+	Response func(body map[string]any) error
+}
+
+func (m Migration) matches(method, path string) bool {
+	if m.Method != "" && m.Method != method {
+		return false
+	}
+	want := strings.Split(strings.Trim(m.Route, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, seg := range want {
+		if !strings.HasPrefix(seg, "{") && seg != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry holds the current version and the migrations leading to it.
+type Registry struct {
+	current    string
+	migrations []Migration // by Version, oldest first
+}
+
+// NewRegistry validates the migrations against current and orders them.
+func NewRegistry(current string, migrations ...Migration) (*Registry, error) {
+	if !valid(current) {
+		return nil, fmt.Errorf("invalid current version %q", current)
+	}
+	for _, m := range migrations {
+		if !valid(m.Version) || m.Version > current {
+			return nil, fmt.Errorf("migration %q: version %q must be a date no later than %s", m.Description, m.Version, current)
+		}
+		if m.Route == "" || (m.Request == nil && m.Response == nil) {
+			return nil, fmt.Errorf("migration %q: route and a transform are required", m.Description)
+		}
+	}
+	sorted := slices.Clone(migrations)
+	slices.SortStableFunc(sorted, func(a, b Migration) int { return strings.Compare(a.Version, b.Version) })
+	return &Registry{current: current, migrations: sorted}, nil
+}
+
+// Current returns the version of the handlers' own shapes.
+func (r *Registry) Current() string { return r.current }
+
+// Migrations returns the migrations, oldest first.
+func (r *Registry) Migrations() []Migration { return slices.Clone(r.migrations) }
+
+// pending returns the migrations newer than version that apply to the
+// request, oldest first.
+func (r *Registry) pending(version, method, path string) []Migration {
+	var out []Migration
+	for _, m := range r.migrations {
+		if m.Version > version && m.matches(method, path) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func valid(v string) bool {
+	_, err := time.Parse(dateLayout, v)
+	return err == nil
+}
+
+type ctxKey struct{}
+
+// FromContext returns the API version of the request, or "" outside Middleware.
+func FromContext(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKey{}).(string)
+	return v
+}
+
+// Middleware resolves the request's API version (defaultVersion, or the
+// current one, when the header is absent), echoes it in the response header
+// and applies the pending migrations. Unknown or future versions get 400.
+func Middleware(reg *Registry, defaultVersion string) func(http.Handler) http.Handler {
+	if defaultVersion == "" {
+		defaultVersion = reg.current
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := strings.TrimSpace(r.Header.Get(Header))
+			if version == "" {
+				version = defaultVersion
+			}
+			if !valid(version) || version > reg.current {
+				response.Error(w, r, http.StatusBadRequest, "invalid_api_version",
+					fmt.Sprintf("%s must be a date (YYYY-MM-DD) no later than %s", Header, reg.current), nil)
+				return
+			}
+			w.Header().Set(Header, version)
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, version))
+
+			pending := reg.pending(version, r.Method, r.URL.Path)
+			if len(pending) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err := migrateRequest(r, pending); err != nil {
+				response.Error(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+				return
+			}
+			if !slices.ContainsFunc(pending, func(m Migration) bool { return m.Response != nil }) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bw := &bufferedWriter{ResponseWriter: w}
+			next.ServeHTTP(bw, r)
+			bw.flush(pending)
+		})
+	}
+}
+
+// migrateRequest applies the request transforms to a JSON object body. Other
+// bodies are left for the handler to reject.
+func migrateRequest(r *http.Request, pending []Migration) error {
+	if r.Body == nil || r.Body == http.NoBody || !isJSON(r.Header.Get("Content-Type")) {
+		return nil
+	}
+	if !slices.ContainsFunc(pending, func(m Migration) bool { return m.Request != nil }) {
+		return nil
+	}
+	raw, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		// Typically the body limit; let the handler report it
+		r.Body = io.NopCloser(errReader{err})
+		return nil
+	}
+	var body map[string]any
+	if json.Unmarshal(raw, &body) != nil || body == nil {
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		return nil
+	}
+	for _, m := range pending {
+		if m.Request == nil {
+			continue
+		}
+		if err := m.Request(body); err != nil {
+			return err
+		}
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(out))
+	r.ContentLength = int64(len(out))
+	r.Header.Set("Content-Length", strconv.Itoa(len(out)))
+	return nil
+}
+
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// bufferedWriter holds the response so its body can be migrated.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+// flush writes the response, with the response transforms applied newest
+// first when the body is a JSON object.
+func (w *bufferedWriter) flush(pending []Migration) {
+	if w.status == 0 {
+		return
+	}
+	out := w.buf.Bytes()
+	var body map[string]any
+	if isJSON(w.Header().Get("Content-Type")) && json.Unmarshal(out, &body) == nil && body != nil {
+		migrated := true
+		for i := len(pending) - 1; i >= 0; i-- {
+			if pending[i].Response == nil {
+				continue
+			}
+			if err := pending[i].Response(body); err != nil {
+				migrated = false
+				break
+			}
+		}
+		if b, err := json.Marshal(body); migrated && err == nil {
+			out = append(b, '\n')
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(out)
+}
+
+// Rename moves the field from to the field to, a common migration. Missing
+// fields are left alone.
+func Rename(body map[string]any, from, to string) error {
+	if v, ok := body[from]; ok {
+		delete(body, from)
+		body[to] = v
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package apiversion
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	reg, err := NewRegistry("2026-10-15",
+		Migration{
+			Version:     "2026-06-01",
+			Method:      http.MethodPost,
+			Route:       "/api/v1/tasks",
+			Description: "name renamed to title",
+			Request:     func(b map[string]any) error { return Rename(b, "name", "title") },
+			Response:    func(b map[string]any) error { return Rename(b, "title", "name") },
+		},
+		Migration{
+			Version:     "2026-09-01",
+			Route:       "/api/v1/tasks",
+			Description: "note renamed to description",
+			Request:     func(b map[string]any) error { return Rename(b, "note", "description") },
+			Response:    func(b map[string]any) error { return Rename(b, "description", "note") },
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return reg
+}
+
+// echo answers with the request body as the current shape.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(b)
+})
+
+func serve(h http.Handler, version, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if version != "" {
+		req.Header.Set(Header, version)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_MigratesOldClients(t *testing.T) {
+	var seen map[string]any
+	h := Middleware(testRegistry(t), "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&seen)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(seen)
+	}))
+
+	rec := serve(h, "2026-01-01", `{"name":"write docs","note":"soon"}`)
+	if seen["title"] != "write docs" || seen["description"] != "soon" {
+		t.Fatalf("expected the handler to see the current shape, got %v", seen)
+	}
+	var got map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &got)
+	if rec.Code != http.StatusCreated || got["name"] != "write docs" || got["note"] != "soon" || rec.Header().Get(Header) != "2026-01-01" {
+		t.Fatalf("expected the old shape back, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Between the two changes only the later one applies
+	serve(h, "2026-07-01", `{"title":"write docs","note":"soon"}`)
+	if seen["title"] != "write docs" || seen["description"] != "soon" {
+		t.Fatalf("expected only the newer migration, got %v", seen)
+	}
+}
+
+func TestMiddleware_CurrentClientsPassThrough(t *testing.T) {
+	h := Middleware(testRegistry(t), "")(echo)
+	rec := serve(h, "", `{"name":"kept"}`)
+	if rec.Body.String() != `{"name":"kept"}` || rec.Header().Get(Header) != "2026-10-15" {
+		t.Fatalf("expected the body untouched at the current version, got %s (%s)", rec.Body.String(), rec.Header().Get(Header))
+	}
+}
+
+func TestMiddleware_RejectsInvalidVersions(t *testing.T) {
+	h := Middleware(testRegistry(t), "")(echo)
+	for _, v := range []string{"2027-01-01", "v2", "2026-13-01"} {
+		if rec := serve(h, v, `{}`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_api_version") {
+			t.Fatalf("%s: expected 400 invalid_api_version, got %d %s", v, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestNewRegistry_RejectsFutureMigrations(t *testing.T) {
+	_, err := NewRegistry("2026-10-15", Migration{Version: "2026-12-01", Route: "/x", Request: func(map[string]any) error { return nil }})
+	if err == nil {
+		t.Fatal("expected an error for a migration newer than the current version")
+	}
+}
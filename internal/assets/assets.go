@@ -0,0 +1,180 @@
+// Package assets packages the files the API ships with into the binary:
+// notification templates (templates/notify), seed data (seed), Grafana
+// dashboards (dashboards), static files served under /static (static) and
+// message catalogs (locales).
+//
+// A directory on disk can override them file by file, so in development the
+// source tree can be edited and picked up without a rebuild, while production
+// builds run on the embedded defaults alone.
+package assets
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed templates seed dashboards static locales
+var embedded embed.FS
+
+// Embedded returns the files built into the binary.
+func Embedded() fs.FS {
+	return embedded
+}
+
+// FS serves each file from its override directory when the file exists there
+// and from the embedded defaults otherwise.
+type FS struct {
+	dir  string
+	disk fs.FS
+	base fs.FS
+
+	mu       sync.Mutex
+	onChange []func()
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Default holds the assets used by the server; main replaces it with one
+// overridden by ASSETS_DIR before building the router.
+var Default = New("")
+
+// New returns the embedded assets overridden by the files in dir; an empty dir
+// serves the embedded files only.
+func New(dir string) *FS {
+	f := &FS{dir: dir, base: embedded}
+	if dir != "" {
+		f.disk = os.DirFS(dir)
+	}
+	return f
+}
+
+// Dir returns the override directory, empty when there is none.
+func (f *FS) Dir() string {
+	return f.dir
+}
+
+// Open implements fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	if f.disk != nil {
+		file, err := f.disk.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return f.base.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS, listing the embedded and overriding files
+// of a directory together.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, baseErr := fs.ReadDir(f.base, name)
+	if f.disk == nil {
+		return entries, baseErr
+	}
+	disk, err := fs.ReadDir(f.disk, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return entries, baseErr
+		}
+		return nil, err
+	}
+	for _, e := range entries {
+		if !slices.ContainsFunc(disk, func(d fs.DirEntry) bool { return d.Name() == e.Name() }) {
+			disk = append(disk, e)
+		}
+	}
+	slices.SortFunc(disk, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return disk, nil
+}
+
+// OnChange registers fn to be called after Watch sees the override directory
+// change, e.g. to parse templates again.
+func (f *FS) OnChange(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onChange = append(f.onChange, fn)
+}
+
+// Watch checks the override directory every interval until Stop and calls the
+// OnChange functions when a file was added, removed or modified. It is a no-op
+// without an override directory, with a zero interval or when already
+// watching. Files are read on every Open anyway; Watch is for callers that
+// cache what they parsed.
+func (f *FS) Watch(interval time.Duration, logger *slog.Logger) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" || interval <= 0 || f.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel, f.done = cancel, make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := snapshot(f.dir)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := snapshot(f.dir)
+			if current == last {
+				continue
+			}
+			last = current
+			logger.Info("assets changed, reloading", slog.String("dir", f.dir))
+			f.mu.Lock()
+			fns := slices.Clone(f.onChange)
+			f.mu.Unlock()
+			for _, fn := range fns {
+				fn()
+			}
+		}
+	}(f.done)
+}
+
+// Stop ends Watch and waits for its loop to exit.
+func (f *FS) Stop() {
+	f.mu.Lock()
+	cancel, done := f.cancel, f.done
+	f.cancel, f.done = nil, nil
+	f.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// snapshot summarizes the names, sizes and modification times of the files
+// under dir; it changes when any of them does.
+func snapshot(dir string) string {
+	var b strings.Builder
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String()
+}
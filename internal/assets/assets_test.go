@@ -0,0 +1,58 @@
+package assets
+
+import (
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFS_OverridesEmbeddedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "static"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "robots.txt"), []byte("override"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "extra.txt"), []byte("extra"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := New(dir)
+
+	if data, err := fs.ReadFile(f, "static/robots.txt"); err != nil || string(data) != "override" {
+		t.Fatalf("expected the file on disk, got %q, %v", data, err)
+	}
+	if _, err := fs.ReadFile(f, "seed/tasks.json"); err != nil {
+		t.Fatalf("expected the embedded file where there is no override: %v", err)
+	}
+	entries, err := fs.ReadDir(f, "static")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "extra.txt" || entries[1].Name() != "robots.txt" {
+		t.Fatalf("expected embedded and overriding files listed together, got %v, %v", entries, err)
+	}
+	if err := fs.WalkDir(New(""), ".", func(string, fs.DirEntry, error) error { return nil }); err != nil {
+		t.Fatalf("embedded assets not walkable: %v", err)
+	}
+}
+
+func TestFS_WatchCallsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	f := New(dir)
+	changed := make(chan struct{}, 1)
+	f.OnChange(func() { changed <- struct{}{} })
+	f.Watch(5*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer f.Stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "new.tmpl"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatalf("OnChange not called after a file was added")
+	}
+}
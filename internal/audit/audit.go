@@ -0,0 +1,277 @@
+// Package audit records who did what for compliance. Records are appended to a
+// sink; the file sink chains each record to the previous one with a SHA-256
+// hash so that edits, deletions and reordering are detectable with Verify,
+// the log sink writes them as log records, and Multi writes to several.
+// Services record the before and after state of what they change with
+// RecordChange, which the request's record carries.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is one audit entry. Seq, PrevHash and Hash are assigned by the sink.
+type Record struct {
+	Seq       uint64         `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Actor     string         `json:"actor,omitempty"`
+	Tenant    string         `json:"tenant,omitempty"`
+	Action    string         `json:"action"`
+	Resource  string         `json:"resource,omitempty"`
+	Outcome   string         `json:"outcome,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+	PrevHash  string         `json:"prev_hash"`
+	Hash      string         `json:"hash,omitempty"`
+}
+
+// Sink persists audit records.
+type Sink interface {
+	Write(ctx context.Context, rec Record) error
+	Close() error
+}
+
+// genesisHash is the PrevHash of the first record in a chain.
+var genesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// ChainedFileSink appends hash-chained records to a JSON Lines file.
+type ChainedFileSink struct {
+	mu       sync.Mutex
+	path     string
+	f        *os.File
+	seq      uint64
+	lastHash string
+}
+
+// OpenChainedFile opens (or creates) path and continues the chain from its last record.
+func OpenChainedFile(path string) (*ChainedFileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	s := &ChainedFileSink{path: path, f: f, lastHash: genesisHash}
+	if err := s.resume(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// resume reads the existing records to find where the chain continues.
+func (s *ChainedFileSink) resume() error {
+	sc := bufio.NewScanner(s.f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	var last []byte
+	for sc.Scan() {
+		last = append(last[:0], sc.Bytes()...)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(last) == 0 {
+		return nil
+	}
+	var rec Record
+	if err := json.Unmarshal(last, &rec); err != nil {
+		return fmt.Errorf("last record is corrupt (run the verify command): %w", err)
+	}
+	s.seq, s.lastHash = rec.Seq, rec.Hash
+	return nil
+}
+
+// Write assigns the next sequence number and hash, then appends and syncs the record.
+func (s *ChainedFileSink) Write(ctx context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec.Seq = s.seq + 1
+	rec.PrevHash = s.lastHash
+	details, err := canonicalDetails(rec.Details)
+	if err != nil {
+		return err
+	}
+	rec.Details = details
+	if rec.Hash, err = hashRecord(rec); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.seq, s.lastHash = rec.Seq, rec.Hash
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *ChainedFileSink) Close() error {
+	return s.f.Close()
+}
+
+// Prune removes the leading records older than cutoff and returns how many were
+// removed, or with dryRun how many would be. Retained records keep their hashes,
+// so Verify anchors the chain at the first remaining record.
+func (s *ChainedFileSink) Prune(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in, err := os.Open(s.path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	tmpPath := s.path + ".tmp"
+	var out *os.File
+	if !dryRun {
+		if out, err = os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640); err != nil {
+			return 0, err
+		}
+		defer os.Remove(tmpPath) // no-op once renamed
+		defer out.Close()
+	}
+
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	removed, pruning := 0, true
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if pruning {
+			var rec Record
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				return 0, fmt.Errorf("audit log %s: %w", s.path, err)
+			}
+			if rec.Time.Before(cutoff) {
+				removed++
+				continue
+			}
+			// Only a contiguous head can go; later records must stay chained
+			pruning = false
+		}
+		if dryRun {
+			break
+		}
+		if _, err := out.Write(append(sc.Bytes(), '\n')); err != nil {
+			return 0, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	if dryRun || removed == 0 {
+		return removed, nil
+	}
+
+	if err := out.Sync(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	s.f.Close()
+	s.f = f
+	return removed, nil
+}
+
+// canonicalDetails round-trips details through JSON so the hash computed now
+// matches the one recomputed from the decoded file (struct values become maps
+// with sorted keys).
+func canonicalDetails(details map[string]any) (map[string]any, error) {
+	if details == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// hashRecord hashes the record's canonical JSON encoding (without Hash).
+func hashRecord(rec Record) (string, error) {
+	rec.Hash = ""
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ErrChainBroken reports the first record that fails verification.
+var ErrChainBroken = errors.New("audit chain broken")
+
+// VerifyResult summarises a verification run.
+type VerifyResult struct {
+	Records  int    // records checked
+	FirstSeq uint64 // sequence number of the first record; above 1 after Prune
+	LastHash string // hash of the last valid record
+}
+
+// Verify checks every record's hash and link to its predecessor. A log whose
+// leading records were pruned is anchored at the first remaining record. On
+// failure it returns the records verified so far and an error wrapping
+// ErrChainBroken naming the offending line.
+func Verify(r io.Reader) (VerifyResult, error) {
+	res := VerifyResult{LastHash: genesisHash}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			return res, fmt.Errorf("%w at line %d: empty line", ErrChainBroken, line)
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return res, fmt.Errorf("%w at line %d: %v", ErrChainBroken, line, err)
+		}
+		if line == 1 && rec.Seq > 1 {
+			res.FirstSeq, res.LastHash = rec.Seq, rec.PrevHash
+		} else if line == 1 {
+			res.FirstSeq = 1
+		}
+		if want := res.FirstSeq + uint64(res.Records); rec.Seq != want {
+			return res, fmt.Errorf("%w at line %d: expected seq %d, got %d", ErrChainBroken, line, want, rec.Seq)
+		}
+		if rec.PrevHash != res.LastHash {
+			return res, fmt.Errorf("%w at line %d: prev_hash does not match the preceding record", ErrChainBroken, line)
+		}
+		want, err := hashRecord(rec)
+		if err != nil {
+			return res, err
+		}
+		if rec.Hash != want {
+			return res, fmt.Errorf("%w at line %d: record content does not match its hash", ErrChainBroken, line)
+		}
+		res.Records++
+		res.LastHash = rec.Hash
+	}
+	return res, sc.Err()
+}
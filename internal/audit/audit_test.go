@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestChain(t *testing.T, path string, n int) {
+	t.Helper()
+	sink, err := OpenChainedFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer sink.Close()
+	for i := 0; i < n; i++ {
+		rec := Record{Time: time.Unix(1_700_000_000, 0).UTC(), Action: "user.update", Resource: "usr_001", Details: map[string]any{"i": i}}
+		if err := sink.Write(context.Background(), rec); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func verifyFile(t *testing.T, path string) (VerifyResult, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	return Verify(f)
+}
+
+func TestChainedFileSink_ResumesAndVerifies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeTestChain(t, path, 3)
+	writeTestChain(t, path, 2) // reopening continues the same chain
+
+	res, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatalf("expected valid chain, got %v", err)
+	}
+	if res.Records != 5 {
+		t.Fatalf("expected 5 records, got %d", res.Records)
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tamper := map[string]func(lines []string) []string{
+		"edited": func(l []string) []string {
+			l[1] = strings.Replace(l[1], "usr_001", "usr_002", 1)
+			return l
+		},
+		"deleted":   func(l []string) []string { return append(l[:1], l[2:]...) },
+		"reordered": func(l []string) []string { l[0], l[1] = l[1], l[0]; return l },
+	}
+	for name, fn := range tamper {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		writeTestChain(t, path, 3)
+		data, _ := os.ReadFile(path)
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		lines = fn(lines)
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			t.Fatalf("rewrite: %v", err)
+		}
+		if _, err := verifyFile(t, path); !errors.Is(err, ErrChainBroken) {
+			t.Fatalf("%s: expected ErrChainBroken, got %v", name, err)
+		}
+	}
+}
+
+func TestChainedFileSink_PruneKeepsChainVerifiable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := OpenChainedFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer sink.Close()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := sink.Write(context.Background(), Record{Time: base.Add(time.Duration(i) * 24 * time.Hour), Action: "user.update"}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	cutoff := base.Add(2 * 24 * time.Hour)
+
+	if n, err := sink.Prune(context.Background(), cutoff, true); err != nil || n != 2 {
+		t.Fatalf("dry run: expected 2, got %d (%v)", n, err)
+	}
+	if res, _ := verifyFile(t, path); res.Records != 5 {
+		t.Fatalf("dry run changed the log: %d records", res.Records)
+	}
+
+	if n, err := sink.Prune(context.Background(), cutoff, false); err != nil || n != 2 {
+		t.Fatalf("prune: expected 2, got %d (%v)", n, err)
+	}
+	if err := sink.Write(context.Background(), Record{Time: base.Add(10 * 24 * time.Hour), Action: "user.delete"}); err != nil {
+		t.Fatalf("write after prune: %v", err)
+	}
+	res, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatalf("expected valid chain after prune, got %v", err)
+	}
+	if res.Records != 4 || res.FirstSeq != 3 {
+		t.Fatalf("expected 4 records from seq 3, got %+v", res)
+	}
+}
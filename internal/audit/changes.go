@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// Change is what one operation changed about a resource: the fields whose
+// values differ between before and after. A created resource has no before
+// and a deleted one no after, so every field is listed.
+type Change struct {
+	Resource string                 `json:"resource"`
+	ID       string                 `json:"id"`
+	Fields   map[string]FieldChange `json:"fields"`
+}
+
+// FieldChange is the value of a field before and after a change; a missing
+// side is null.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type changesKey struct{}
+
+type changes struct {
+	mu   sync.Mutex
+	list []Change
+}
+
+// WithChanges returns a context collecting the changes services record with
+// RecordChange, for the audit record of the request.
+func WithChanges(ctx context.Context) context.Context {
+	return context.WithValue(ctx, changesKey{}, &changes{})
+}
+
+// Collecting reports whether ctx collects changes, so services can skip
+// loading the state before an operation when nobody audits it.
+func Collecting(ctx context.Context) bool {
+	_, ok := ctx.Value(changesKey{}).(*changes)
+	return ok
+}
+
+// RecordChange records the difference between before and after, the
+// resource's representation before and after an operation (nil for a side
+// that does not exist). Nothing is recorded outside a context from
+// WithChanges or when nothing changed.
+func RecordChange(ctx context.Context, resource, id string, before, after any) {
+	c, ok := ctx.Value(changesKey{}).(*changes)
+	if !ok {
+		return
+	}
+	fields := Diff(before, after)
+	if len(fields) == 0 {
+		return
+	}
+	c.mu.Lock()
+	c.list = append(c.list, Change{Resource: resource, ID: id, Fields: fields})
+	c.mu.Unlock()
+}
+
+// Changes returns the changes recorded in ctx, in the order they were made.
+func Changes(ctx context.Context) []Change {
+	c, ok := ctx.Value(changesKey{}).(*changes)
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Change(nil), c.list...)
+}
+
+// Diff compares the JSON representations of before and after field by field
+// and returns the fields that differ. Either may be nil.
+func Diff(before, after any) map[string]FieldChange {
+	b, a := fieldsOf(before), fieldsOf(after)
+	diff := map[string]FieldChange{}
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			diff[k] = FieldChange{Before: v, After: a[k]}
+		}
+	}
+	for k, w := range a {
+		if _, ok := b[k]; !ok {
+			diff[k] = FieldChange{After: w}
+		}
+	}
+	return diff
+}
+
+// fieldsOf returns the top-level fields of v's JSON object, or nil.
+func fieldsOf(v any) map[string]any {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRecordChange_DiffsRepresentations(t *testing.T) {
+	type user struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	before := &user{ID: "usr_001", Name: "John", Role: "user"}
+
+	RecordChange(context.Background(), "user", "usr_001", before, nil) // not collecting: ignored
+	ctx := WithChanges(context.Background())
+	RecordChange(ctx, "user", "usr_001", before, &user{ID: "usr_001", Name: "John", Role: "admin"})
+	RecordChange(ctx, "user", "usr_001", before, before) // nothing changed
+	RecordChange(ctx, "user", "usr_002", nil, &user{ID: "usr_002", Name: "Jane"})
+	RecordChange(ctx, "user", "usr_001", before, (*user)(nil))
+
+	changes := Changes(ctx)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if f := changes[0].Fields; len(f) != 1 || f["role"] != (FieldChange{Before: "user", After: "admin"}) {
+		t.Fatalf("update: %+v", f)
+	}
+	if f := changes[1].Fields; len(f) != 3 || f["name"] != (FieldChange{After: "Jane"}) {
+		t.Fatalf("create: %+v", f)
+	}
+	if f := changes[2].Fields; len(f) != 3 || f["id"] != (FieldChange{Before: "usr_001"}) {
+		t.Fatalf("delete: %+v", f)
+	}
+}
+
+type failingSink struct{ writes int }
+
+func (s *failingSink) Write(context.Context, Record) error {
+	s.writes++
+	return errors.New("disk full")
+}
+
+func (s *failingSink) Close() error { return nil }
+
+func TestMulti_WritesToEverySink(t *testing.T) {
+	var logs bytes.Buffer
+	failing := &failingSink{}
+	sink := Multi(failing, NewLogSink(slog.New(slog.NewJSONHandler(&logs, nil))))
+
+	err := sink.Write(context.Background(), Record{Action: "DELETE /api/v1/users/{userID}", Actor: "usr_001", Details: map[string]any{"status": 204}})
+	if err == nil || failing.writes != 1 {
+		t.Fatalf("expected the failing sink's error, got %v", err)
+	}
+	if !strings.Contains(logs.String(), `"msg":"audit"`) || !strings.Contains(logs.String(), `"actor":"usr_001"`) {
+		t.Fatalf("record not logged despite the failing sink: %s", logs.String())
+	}
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// LogSink writes each record as an "audit" log record, for deployments that
+// ship their logs somewhere durable already.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink returns a sink logging to logger.
+func NewLogSink(logger *slog.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// Write logs rec at info level.
+func (s *LogSink) Write(ctx context.Context, rec Record) error {
+	attrs := []slog.Attr{
+		slog.Time("audit_time", rec.Time),
+		slog.String("actor", rec.Actor),
+		slog.String("action", rec.Action),
+		slog.String("resource", rec.Resource),
+		slog.String("outcome", rec.Outcome),
+		slog.String("request_id", rec.RequestID),
+	}
+	if rec.Tenant != "" {
+		attrs = append(attrs, slog.String("tenant", rec.Tenant))
+	}
+	if len(rec.Details) > 0 {
+		attrs = append(attrs, slog.Any("details", rec.Details))
+	}
+	s.logger.LogAttrs(ctx, slog.LevelInfo, "audit", attrs...)
+	return nil
+}
+
+// Close does nothing; the logger outlives the sink.
+func (s *LogSink) Close() error { return nil }
+
+// Multi writes every record to all of sinks, in order. A failing sink does
+// not keep the record from the others; their errors are joined.
+func Multi(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+type multiSink []Sink
+
+func (m multiSink) Write(ctx context.Context, rec Record) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Write(ctx, rec))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiSink) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,284 @@
+// Package auth authenticates requests carrying a JWT bearer token. Tokens are
+// signed with HS256 under one of the configured shared secrets, or with
+// RS256 under a key from a JWKS document, which is cached and fetched again
+// when a token names a key it does not hold, so issuers can rotate keys
+// without a restart. A valid token's subject becomes the request's principal
+// (requestctx) and its claims are available with FromContext.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+	"github.com/mikko-kohtala/go-api/pkg/timestamp"
+)
+
+var (
+	ErrMalformed  = errors.New("auth: malformed token")
+	ErrAlgorithm  = errors.New("auth: unsupported signing algorithm")
+	ErrUnknownKey = errors.New("auth: unknown signing key")
+	ErrSignature  = errors.New("auth: invalid signature")
+	ErrIssuer     = errors.New("auth: unexpected issuer")
+	ErrAudience   = errors.New("auth: unexpected audience")
+	ErrNoSubject  = errors.New("auth: token has no subject")
+	ErrNoKeys     = errors.New("auth: no signing keys configured")
+)
+
+// Claims are the claims of a verified token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	Tenant    string   // the claim named by Options.TenantClaim
+	Scopes    []string // the space-separated scope claim; nil when absent
+	ExpiresAt time.Time
+	// Raw holds every claim as decoded, for those the fields leave out.
+	Raw map[string]any
+}
+
+type claimsKey struct{}
+
+// FromContext returns the claims of the request's verified token.
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+// Options configures a Verifier. At least one of HMACSecrets and JWKSURL is
+// required.
+type Options struct {
+	// HMACSecrets verify HS256 tokens; a token signed with any of them is
+	// accepted, so a new secret is added before the old one is removed.
+	HMACSecrets [][]byte
+	// JWKSURL serves the RSA keys RS256 tokens are verified with.
+	JWKSURL string
+	// JWKSCacheTTL is how long fetched keys are used before they are
+	// fetched again.
+	JWKSCacheTTL time.Duration
+	Client       *http.Client // fetches the JWKS; one with a 10s timeout when nil
+
+	Issuer      string   // required iss when set
+	Audiences   []string // the aud must contain one of them when set
+	TenantClaim string   // claim holding the principal's tenant, e.g. "tenant"
+
+	// Clock checks exp and nbf; timestamp.Default when nil.
+	Clock *timestamp.Checker
+}
+
+// Verifier verifies JWTs.
+type Verifier struct {
+	opts Options
+	jwks *keySet
+}
+
+// NewVerifier returns a verifier for opts.
+func NewVerifier(opts Options) (*Verifier, error) {
+	if len(opts.HMACSecrets) == 0 && opts.JWKSURL == "" {
+		return nil, ErrNoKeys
+	}
+	v := &Verifier{opts: opts}
+	if opts.JWKSURL != "" {
+		client := opts.Client
+		if client == nil {
+			client = &http.Client{Timeout: jwksFetchTimeout}
+		}
+		v.jwks = newKeySet(opts.JWKSURL, client, opts.JWKSCacheTTL)
+	}
+	return v, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks token's signature, expiry, not-before, issuer and audience
+// and returns its claims. Timestamp failures are timestamp's errors.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	// The algorithm decides which keys are tried, never the other way
+	// round, so an RSA public key can never verify an HS256 token
+	switch h.Alg {
+	case "HS256":
+		if err := v.verifyHMAC(signed, sig); err != nil {
+			return Claims{}, err
+		}
+	case "RS256":
+		if err := v.verifyRSA(ctx, h.Kid, signed, sig); err != nil {
+			return Claims{}, err
+		}
+	default:
+		return Claims{}, ErrAlgorithm
+	}
+
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return Claims{}, err
+	}
+	return v.claims(raw)
+}
+
+func (v *Verifier) verifyHMAC(signed, sig []byte) error {
+	if len(v.opts.HMACSecrets) == 0 {
+		return ErrAlgorithm
+	}
+	for _, secret := range v.opts.HMACSecrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if hmac.Equal(sig, mac.Sum(nil)) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+func (v *Verifier) verifyRSA(ctx context.Context, kid string, signed, sig []byte) error {
+	if v.jwks == nil {
+		return ErrAlgorithm
+	}
+	key, err := v.jwks.key(ctx, kid)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(signed)
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) != nil {
+		return ErrSignature
+	}
+	return nil
+}
+
+func (v *Verifier) claims(raw map[string]any) (Claims, error) {
+	c := Claims{Raw: raw}
+	c.Subject, _ = raw["sub"].(string)
+	c.Issuer, _ = raw["iss"].(string)
+	switch aud := raw["aud"].(type) {
+	case string:
+		c.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				c.Audience = append(c.Audience, s)
+			}
+		}
+	}
+	if v.opts.TenantClaim != "" {
+		c.Tenant, _ = raw[v.opts.TenantClaim].(string)
+	}
+	if scope, ok := raw["scope"].(string); ok {
+		c.Scopes = strings.Fields(scope)
+	}
+
+	clock := timestamp.Default
+	if v.opts.Clock != nil {
+		clock = *v.opts.Clock
+	}
+	if exp, ok := numericDate(raw["exp"]); ok {
+		c.ExpiresAt = exp
+		if err := clock.NotExpired(exp); err != nil {
+			return Claims{}, err
+		}
+	}
+	if nbf, ok := numericDate(raw["nbf"]); ok {
+		if err := clock.NotBefore(nbf); err != nil {
+			return Claims{}, err
+		}
+	}
+	if v.opts.Issuer != "" && c.Issuer != v.opts.Issuer {
+		return Claims{}, ErrIssuer
+	}
+	if len(v.opts.Audiences) > 0 && !slices.ContainsFunc(c.Audience, func(a string) bool {
+		return slices.Contains(v.opts.Audiences, a)
+	}) {
+		return Claims{}, ErrAudience
+	}
+	if c.Subject == "" {
+		return Claims{}, ErrNoSubject
+	}
+	return c, nil
+}
+
+// Authenticate returns middleware that verifies the bearer token of requests
+// that carry one and records its subject as the principal. Requests without
+// a token pass through unauthenticated, so RequireAuthenticated decides
+// whether they are served; a token that fails verification is answered with
+// 401.
+func (v *Verifier) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearer(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := v.Verify(r.Context(), token)
+		if err != nil {
+			logger.FromContext(r.Context()).Info("bearer token rejected", slog.String("error", err.Error()))
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			code, message := "invalid_token", "Bearer token is invalid"
+			if tc := timestamp.Code(err); tc != "" {
+				code, message = tc, "Bearer token is expired or not yet valid"
+			}
+			response.Error(w, r, http.StatusUnauthorized, code, message, nil)
+			return
+		}
+		ctx := requestctx.SetPrincipal(r.Context(), requestctx.Identity{UserID: claims.Subject, Tenant: claims.Tenant, Method: "jwt", Scopes: claims.Scopes})
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, claimsKey{}, claims)))
+	})
+}
+
+// bearer returns the token of an Authorization: Bearer header.
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func decodeSegment(seg string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return nil
+}
+
+// numericDate reads a JWT NumericDate claim.
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/pkg/timestamp"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func segment(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(secret []byte, claims map[string]any) string {
+	signed := segment(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signed := segment(map[string]string{"alg": "RS256", "kid": kid}) + "." + segment(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func jwk(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "use": "sig", "kid": kid,
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestVerify_HS256(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	clock := &timestamp.Checker{Skew: 30 * time.Second, Now: func() time.Time { return now }}
+	old := []byte("fedcba9876543210fedcba9876543210")
+	v, err := NewVerifier(Options{HMACSecrets: [][]byte{testSecret, old}, Issuer: "issuer", Audiences: []string{"api"}, TenantClaim: "tenant", Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]any{"sub": "usr_001", "iss": "issuer", "aud": []string{"other", "api"}, "tenant": "acme", "exp": now.Add(time.Minute).Unix()}
+
+	for _, secret := range [][]byte{testSecret, old} {
+		c, err := v.Verify(context.Background(), signHS256(secret, valid))
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if c.Subject != "usr_001" || c.Tenant != "acme" {
+			t.Fatalf("unexpected claims %+v", c)
+		}
+	}
+
+	with := func(k string, val any) map[string]any {
+		claims := map[string]any{}
+		for key, v := range valid {
+			claims[key] = v
+		}
+		claims[k] = val
+		return claims
+	}
+	cases := map[string]struct {
+		token string
+		want  error
+	}{
+		"expired":       {signHS256(testSecret, with("exp", now.Add(-time.Minute).Unix())), timestamp.ErrExpired},
+		"not yet valid": {signHS256(testSecret, with("nbf", now.Add(time.Minute).Unix())), timestamp.ErrNotYetValid},
+		"other issuer":  {signHS256(testSecret, with("iss", "someone")), ErrIssuer},
+		"other aud":     {signHS256(testSecret, with("aud", "billing")), ErrAudience},
+		"no subject":    {signHS256(testSecret, with("sub", "")), ErrNoSubject},
+		"other secret":  {signHS256([]byte("another-secret-another-secret-xx"), valid), ErrSignature},
+		"alg none":      {segment(map[string]string{"alg": "none"}) + "." + segment(valid) + ".", ErrAlgorithm},
+		"rs256 no jwks": {segment(map[string]string{"alg": "RS256"}) + "." + segment(valid) + ".c2ln", ErrAlgorithm},
+		"garbage":       {"not-a-token", ErrMalformed},
+	}
+	for name, tc := range cases {
+		if _, err := v.Verify(context.Background(), tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestVerify_RS256FollowsKeyRotation(t *testing.T) {
+	first, _ := rsa.GenerateKey(rand.Reader, 2048)
+	second, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var (
+		keys    atomic.Value
+		fetches atomic.Int32
+	)
+	keys.Store([]map[string]string{jwk("k1", &first.PublicKey)})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys.Load()})
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(Options{JWKSURL: srv.URL, Client: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.jwks.now = func() time.Time { return now }
+	claims := map[string]any{"sub": "usr_001"}
+
+	if _, err := v.Verify(context.Background(), signRS256(t, first, "k1", claims)); err != nil {
+		t.Fatalf("Verify with k1: %v", err)
+	}
+	if _, err := v.Verify(context.Background(), signRS256(t, first, "k1", claims)); err != nil || fetches.Load() != 1 {
+		t.Fatalf("expected the cached key to be used, err %v after %d fetches", err, fetches.Load())
+	}
+	if _, err := v.Verify(context.Background(), signHS256(testSecret, claims)); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("expected HS256 to be refused without secrets, got %v", err)
+	}
+
+	// The issuer rotates to k2; an unknown kid fetches the keys again, but
+	// not more often than minRefetchInterval
+	keys.Store([]map[string]string{jwk("k1", &first.PublicKey), jwk("k2", &second.PublicKey)})
+	if _, err := v.Verify(context.Background(), signRS256(t, second, "k2", claims)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey within the refetch interval, got %v", err)
+	}
+	now = now.Add(minRefetchInterval)
+	if _, err := v.Verify(context.Background(), signRS256(t, second, "k2", claims)); err != nil {
+		t.Fatalf("Verify with rotated key: %v", err)
+	}
+	if _, err := v.Verify(context.Background(), signRS256(t, first, "k2", claims)); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected ErrSignature for a token signed with another key, got %v", err)
+	}
+}
+
+func TestVerify_RS256DoesNotWaitForAHungIssuer(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			close(started)
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{jwk("k1", &key.PublicKey)}})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	v, err := NewVerifier(Options{JWKSURL: srv.URL, Client: srv.Client(), JWKSCacheTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]any{"sub": "usr_001"}
+	if _, err := v.Verify(context.Background(), signRS256(t, key, "k1", claims)); err != nil {
+		t.Fatalf("Verify with k1: %v", err)
+	}
+
+	// The cache goes stale and the issuer stops answering
+	later := time.Now().Add(time.Hour)
+	v.jwks.now = func() time.Time { return later }
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(context.Background(), signRS256(t, key, "k1", claims))
+		refreshed <- err
+	}()
+	<-started
+
+	if _, err := v.Verify(context.Background(), signRS256(t, key, "k1", claims)); err != nil {
+		t.Fatalf("expected the cached key while the issuer hangs, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := v.Verify(ctx, signRS256(t, key, "k9", claims)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected an unknown kid to wait for the fetch until its deadline, got %v", err)
+	}
+	release <- struct{}{}
+	if err := <-refreshed; err != nil || fetches.Load() != 2 {
+		t.Fatalf("expected one shared fetch to finish, got %v after %d fetches", err, fetches.Load())
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	v, _ := NewVerifier(Options{HMACSecrets: [][]byte{testSecret}, TenantClaim: "tenant"})
+	h := v.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := requestctx.Principal(r.Context())
+		c, _ := FromContext(r.Context())
+		_, _ = w.Write([]byte(p.UserID + "/" + p.Tenant + "/" + p.Method + "/" + c.Subject))
+	}))
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("Bearer " + signHS256(testSecret, map[string]any{"sub": "usr_001", "tenant": "acme"})); rec.Body.String() != "usr_001/acme/jwt/usr_001" {
+		t.Fatalf("expected the principal and claims in the context, got %d %q", rec.Code, rec.Body)
+	}
+	if rec := serve(""); rec.Code != http.StatusOK || rec.Body.String() != "///" {
+		t.Fatalf("expected requests without a token to pass unauthenticated, got %d %q", rec.Code, rec.Body)
+	}
+	rec := serve("Bearer " + signHS256(testSecret, map[string]any{"sub": "usr_001", "exp": time.Now().Add(-time.Hour).Unix()}))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "timestamp_expired") || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected 401 timestamp_expired, got %d %q", rec.Code, rec.Body)
+	}
+	if rec := serve("Bearer nonsense"); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "invalid_token") {
+		t.Fatalf("expected 401 invalid_token, got %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	keys := services.NewAPIKeyService()
+	issued, err := keys.Issue(context.Background(), services.NewAPIKey{Name: "billing", OwnerID: "svc_billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := NewVerifier(Options{HMACSecrets: [][]byte{testSecret}})
+	identify := Identify(keys, v)
+	who := func(header, value string) (string, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return identify(req)
+	}
+
+	if user, ok := who(APIKeyHeader, issued.Key); !ok || user != "svc_billing" {
+		t.Fatalf("API key identified as %q %v", user, ok)
+	}
+	if user, ok := who("Authorization", "Bearer "+signHS256(testSecret, map[string]any{"sub": "usr_001"})); !ok || user != "usr_001" {
+		t.Fatalf("bearer token identified as %q %v", user, ok)
+	}
+	for _, c := range [][2]string{{"", ""}, {APIKeyHeader, "gak_000000000000_forged"}, {"Authorization", "Bearer nonsense"}} {
+		if user, ok := who(c[0], c[1]); ok {
+			t.Fatalf("%s %q identified as %q", c[0], c[1], user)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(testSecret, map[string]any{"sub": "usr_001"}))
+	if user, ok := Identify(keys, nil)(req); ok {
+		t.Fatalf("bearer token identified as %q without a verifier", user)
+	}
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+const (
+	// defaultJWKSCacheTTL applies when Options.JWKSCacheTTL is 0.
+	defaultJWKSCacheTTL = time.Hour
+	// minRefetchInterval bounds how often tokens naming unknown keys make
+	// the JWKS be fetched, so forged kids cannot hammer the issuer.
+	minRefetchInterval = 30 * time.Second
+	// maxJWKSBytes bounds the JWKS document read.
+	maxJWKSBytes = 1 << 20
+	// jwksFetchTimeout bounds a fetch, whichever request started it.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// keySet caches the RSA keys of a JWKS document by key ID.
+type keySet struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time     // last successful fetch
+	triedAt   time.Time     // last attempt
+	fetching  chan struct{} // closed when the fetch in progress ends; nil without one
+}
+
+func newKeySet(url string, client *http.Client, ttl time.Duration) *keySet {
+	if ttl <= 0 {
+		ttl = defaultJWKSCacheTTL
+	}
+	return &keySet{url: url, client: client, ttl: ttl, now: time.Now}
+}
+
+// key returns the key with kid. Keys are fetched when the cache is older
+// than the TTL, or when kid is unknown and the last attempt is long enough
+// ago; a failed fetch keeps the cached keys. One fetch runs at a time, without
+// holding the lock: requests whose key is cached go on with it meanwhile, and
+// others wait for the fetch (or their own deadline). A token without a kid is
+// verified with the only key of a single-key set.
+func (s *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	now := s.now()
+	k, ok := s.lookup(kid)
+	if (now.Sub(s.fetchedAt) >= s.ttl || !ok) && now.Sub(s.triedAt) >= minRefetchInterval && s.fetching == nil {
+		s.triedAt = now
+		s.fetching = make(chan struct{})
+		s.mu.Unlock()
+		s.refresh(ctx, now)
+		s.mu.Lock()
+		k, ok = s.lookup(kid)
+	} else if !ok && s.fetching != nil {
+		done := s.fetching
+		s.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		s.mu.Lock()
+		k, ok = s.lookup(kid)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return k, nil
+}
+
+// refresh fetches the keys started at now, replacing the cached ones when it
+// succeeds, and ends the fetch in progress. The fetch is not canceled with
+// ctx, as other requests may be waiting for it.
+func (s *keySet) refresh(ctx context.Context, now time.Time) {
+	defer func() {
+		s.mu.Lock()
+		close(s.fetching)
+		s.fetching = nil
+		s.mu.Unlock()
+	}()
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	defer cancel()
+	keys, err := s.fetch(fetchCtx)
+	if err != nil {
+		logger.FromContext(ctx).Warn("jwks fetch failed, using cached keys",
+			slog.String("url", s.url),
+			slog.String("error", err.Error()))
+		return
+	}
+	s.mu.Lock()
+	s.keys, s.fetchedAt = keys, now
+	s.mu.Unlock()
+}
+
+func (s *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+// fetch downloads the JWKS document and returns its RSA signing keys.
+func (s *keySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(body)
+}
+
+// parseJWKS returns the RSA signature keys of a JWKS document; keys of other
+// types or uses are skipped.
+func parseJWKS(body []byte) (map[string]*rsa.PublicKey, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwks: key %q is malformed", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
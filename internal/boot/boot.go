@@ -0,0 +1,181 @@
+// Package boot records what an instance is running: its build, where its
+// configuration came from, the middleware and optional features it enabled,
+// the routes it mounted and how its dependency checks went. main logs the
+// report once at startup, and /admin/boot serves it, so the running setup of
+// any instance can be audited.
+package boot
+
+import (
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/preflight"
+)
+
+// Build identifies the binary.
+type Build struct {
+	Version   string `json:"version"`            // module version, "(devel)" for local builds
+	Revision  string `json:"revision,omitempty"` // VCS revision, when built from a checkout
+	Time      string `json:"time,omitempty"`     // VCS commit time
+	Modified  bool   `json:"modified,omitempty"` // built with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// ConfigSources tells which configuration keys were set in the environment;
+// all others have their defaults. Values are left out, see /admin/config.
+type ConfigSources struct {
+	Environment []string `json:"environment"`
+	Defaults    int      `json:"defaults"`
+}
+
+// Check is the outcome of one dependency check.
+type Check struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Report describes a running instance.
+type Report struct {
+	StartedAt     time.Time     `json:"started_at"`
+	Env           string        `json:"env"`
+	Hostname      string        `json:"hostname"`
+	Build         Build         `json:"build"`
+	Config        ConfigSources `json:"config"`
+	Middleware    []string      `json:"middleware"` // global chain, outermost first
+	Features      []string      `json:"features"`   // optional subsystems enabled by configuration
+	Routes        int           `json:"routes"`     // method and pattern pairs mounted
+	ChecksSkipped bool          `json:"checks_skipped,omitempty"`
+	Checks        []Check       `json:"checks"` // the preflight checks, empty when skipped
+}
+
+// Recorder collects the report while the instance starts.
+type Recorder struct {
+	mu     sync.Mutex
+	report Report
+}
+
+// Default is the report of this process. The router records its middleware,
+// features and routes when built, and main the rest.
+var Default = &Recorder{}
+
+// Start records the build, environment and configuration sources.
+func (r *Recorder) Start(env string) {
+	hostname, _ := os.Hostname()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.StartedAt = time.Now().UTC()
+	r.report.Env = env
+	r.report.Hostname = hostname
+	r.report.Build = readBuild()
+	r.report.Config = configSources(os.LookupEnv)
+	r.report.ChecksSkipped, r.report.Checks = true, []Check{}
+}
+
+// SetRouter records the middleware chain, enabled features and number of
+// routes of the router being served.
+func (r *Recorder) SetRouter(middleware, features []string, routes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Middleware = slices.Clone(middleware)
+	r.report.Features = slices.Clone(features)
+	r.report.Routes = routes
+}
+
+// SetChecks records the results of the preflight checks, which count as
+// skipped until it is called.
+func (r *Recorder) SetChecks(results []preflight.Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.ChecksSkipped = false
+	r.report.Checks = make([]Check, 0, len(results))
+	for _, res := range results {
+		c := Check{Name: res.Name, Passed: res.Err == nil, Duration: res.Duration}
+		if res.Err != nil {
+			c.Error = res.Err.Error()
+		}
+		r.report.Checks = append(r.report.Checks, c)
+	}
+}
+
+// Report returns a copy of the report.
+func (r *Recorder) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.report
+	rep.Config.Environment = slices.Clone(rep.Config.Environment)
+	rep.Middleware = slices.Clone(rep.Middleware)
+	rep.Features = slices.Clone(rep.Features)
+	rep.Checks = slices.Clone(rep.Checks)
+	return rep
+}
+
+// Log writes the report as one "boot report" record.
+func (r *Recorder) Log(logger *slog.Logger) {
+	rep := r.Report()
+	failed := 0
+	for _, c := range rep.Checks {
+		if !c.Passed {
+			failed++
+		}
+	}
+	logger.Info("boot report",
+		slog.String("env", rep.Env),
+		slog.String("hostname", rep.Hostname),
+		slog.Group("build",
+			slog.String("version", rep.Build.Version),
+			slog.String("revision", rep.Build.Revision),
+			slog.Bool("modified", rep.Build.Modified),
+			slog.String("go_version", rep.Build.GoVersion)),
+		slog.Group("config",
+			slog.Any("environment", rep.Config.Environment),
+			slog.Int("defaults", rep.Config.Defaults)),
+		slog.Any("middleware", rep.Middleware),
+		slog.Any("features", rep.Features),
+		slog.Int("routes", rep.Routes),
+		slog.Group("checks",
+			slog.Bool("skipped", rep.ChecksSkipped),
+			slog.Int("run", len(rep.Checks)),
+			slog.Int("failed", failed)))
+}
+
+func readBuild() Build {
+	b := Build{Version: "(devel)", GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	if info.Main.Version != "" {
+		b.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+func configSources(lookup func(string) (string, bool)) ConfigSources {
+	src := ConfigSources{Environment: []string{}}
+	for _, f := range config.Fields() {
+		if _, ok := lookup(f.Key); ok {
+			src.Environment = append(src.Environment, f.Key)
+		} else {
+			src.Defaults++
+		}
+	}
+	return src
+}
@@ -0,0 +1,46 @@
+package boot
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/preflight"
+)
+
+func TestConfigSources(t *testing.T) {
+	set := map[string]string{"PORT": "9090", "REDIS_URL": "redis://cache:6379", "NOT_A_SETTING": "x"}
+	src := configSources(func(key string) (string, bool) {
+		v, ok := set[key]
+		return v, ok
+	})
+	if !slices.Equal(src.Environment, []string{"PORT", "REDIS_URL"}) {
+		t.Fatalf("Environment = %v", src.Environment)
+	}
+	if src.Defaults != len(config.Fields())-2 {
+		t.Fatalf("Defaults = %d, want %d", src.Defaults, len(config.Fields())-2)
+	}
+}
+
+func TestRecorder_Checks(t *testing.T) {
+	var r Recorder
+	r.Start("production")
+	if rep := r.Report(); !rep.ChecksSkipped || rep.Checks == nil || rep.Build.GoVersion == "" || rep.StartedAt.IsZero() {
+		t.Fatalf("unexpected report after Start: %+v", rep)
+	}
+
+	r.SetChecks([]preflight.Result{
+		{Name: "database", Duration: time.Millisecond},
+		{Name: "storage", Err: errors.New("permission denied")},
+	})
+	rep := r.Report()
+	if rep.ChecksSkipped || len(rep.Checks) != 2 || !rep.Checks[0].Passed || rep.Checks[1].Passed || rep.Checks[1].Error != "permission denied" {
+		t.Fatalf("unexpected checks: %+v", rep)
+	}
+	rep.Checks[0].Name = "changed"
+	if r.Report().Checks[0].Name != "database" {
+		t.Fatal("Report shares its slices with the recorder")
+	}
+}
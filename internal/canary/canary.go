@@ -0,0 +1,97 @@
+// Package canary splits traffic between the stable implementation and a
+// canary: either alternative in-process handlers (see Switch) or a proxied
+// upstream. Callers can force a variant with a header or cookie; everyone
+// else is bucketed by a hash of their unit, so a caller stays on one side.
+package canary
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// Variants a request can be routed to.
+const (
+	Stable = "stable"
+	Canary = "canary"
+)
+
+// Options configures the split.
+type Options struct {
+	// Percent of units (0-100) routed to the canary.
+	Percent int
+	// Header and Cookie force a variant when set to "canary" (or 1, true,
+	// always) or "stable" (or 0, false, never). The header wins over the cookie.
+	Header string
+	Cookie string
+}
+
+// Decide returns the variant for r. unit identifies the caller for the
+// percentage split; an empty unit is never sampled into the canary.
+func (o Options) Decide(r *http.Request, unit string) string {
+	if o.Header != "" {
+		if v, ok := forced(r.Header.Get(o.Header)); ok {
+			return v
+		}
+	}
+	if o.Cookie != "" {
+		if c, err := r.Cookie(o.Cookie); err == nil {
+			if v, ok := forced(c.Value); ok {
+				return v
+			}
+		}
+	}
+	if o.Percent <= 0 || unit == "" {
+		return Stable
+	}
+	if o.Percent >= 100 || bucket(unit) < o.Percent {
+		return Canary
+	}
+	return Stable
+}
+
+// forced maps an override header or cookie value to a variant.
+func forced(v string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "canary", "1", "true", "always":
+		return Canary, true
+	case "stable", "0", "false", "never":
+		return Stable, true
+	}
+	return "", false
+}
+
+// bucket maps unit to 0-99.
+func bucket(unit string) int {
+	sum := sha256.Sum256([]byte("canary\x00" + unit))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+type contextKey struct{}
+
+// IntoContext returns ctx carrying the request's variant.
+func IntoContext(ctx context.Context, variant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, variant)
+}
+
+// FromContext returns the request's variant, Stable when none was decided.
+func FromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(contextKey{}).(string); ok {
+		return v
+	}
+	return Stable
+}
+
+// Switch serves canary requests with next and the rest with stable, for
+// routes whose new implementation lives in this process (e.g. a v2 handler).
+func Switch(stable, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if FromContext(r.Context()) == Canary {
+			next(w, r)
+			return
+		}
+		stable(w, r)
+	}
+}
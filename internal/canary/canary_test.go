@@ -0,0 +1,66 @@
+package canary
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecide_Percent(t *testing.T) {
+	o := Options{Percent: 20}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	n := 0
+	for i := 0; i < 10000; i++ {
+		unit := fmt.Sprintf("unit-%d", i)
+		v := o.Decide(r, unit)
+		if o.Decide(r, unit) != v {
+			t.Fatalf("variant for %s is not deterministic", unit)
+		}
+		if v == Canary {
+			n++
+		}
+	}
+	if n < 1800 || n > 2200 {
+		t.Fatalf("expected about 20%% canary, got %d of 10000", n)
+	}
+	if (Options{}).Decide(r, "unit") != Stable || (Options{Percent: 100}).Decide(r, "unit") != Canary {
+		t.Fatal("0% and 100% must not sample")
+	}
+}
+
+func TestDecide_Overrides(t *testing.T) {
+	o := Options{Percent: 100, Header: "X-Canary", Cookie: "canary"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "canary", Value: "never"})
+	if got := o.Decide(r, "unit"); got != Stable {
+		t.Fatalf("cookie override: got %s", got)
+	}
+	r.Header.Set("X-Canary", "always")
+	if got := o.Decide(r, "unit"); got != Canary {
+		t.Fatalf("header should win over cookie: got %s", got)
+	}
+
+	o.Percent = 0
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Canary", "bogus")
+	if got := o.Decide(r, "unit"); got != Stable {
+		t.Fatalf("unrecognised override should fall back to the split: got %s", got)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	h := Switch(
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("v1")) },
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("v2")) },
+	)
+	for variant, want := range map[string]string{Stable: "v1", Canary: "v2"} {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		h(rr, r.WithContext(IntoContext(r.Context(), variant)))
+		if rr.Body.String() != want {
+			t.Fatalf("%s: got %q, want %q", variant, rr.Body.String(), want)
+		}
+	}
+}
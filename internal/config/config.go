@@ -2,33 +2,349 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	env "github.com/caarlos0/env/v10"
+
+	"github.com/mikko-kohtala/go-api/internal/experiments"
+	"github.com/mikko-kohtala/go-api/internal/i18n"
+	"github.com/mikko-kohtala/go-api/internal/proxyproto"
+	"github.com/mikko-kohtala/go-api/internal/redis"
 )
 
 // Config holds application configuration loaded from environment variables.
 type Config struct {
-	Env            string        `env:"APP_ENV" envDefault:"development"`
-	Port           int           `env:"PORT" envDefault:"8080"`
-	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"15s"`
-	BodyLimitBytes int64         `env:"BODY_LIMIT_BYTES" envDefault:"10485760"` // 10 MiB
+	Env            string        `env:"APP_ENV" envDefault:"development" desc:"Deployment environment; production or prod enables production behaviour"`
+	Port           int           `env:"PORT" envDefault:"8080" desc:"Plain HTTP listener port"`
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"15s" desc:"Maximum time to serve a request"`
+	BodyLimitBytes int64         `env:"BODY_LIMIT_BYTES" envDefault:"10485760" desc:"Largest accepted request body, counted after decompression"` // 10 MiB
+
+	// Compressed request bodies decoding to more than this multiple of their
+	// wire size are cut off as decompression bombs
+	DecompressionMaxRatio int `env:"DECOMPRESSION_MAX_RATIO" envDefault:"100" desc:"Largest decoded to wire size ratio of a gzip request body past 64 KiB decoded (0 disables)"`
+
+	// Honour a caller's X-Request-Timeout budget (capped by REQUEST_TIMEOUT); the
+	// outbound client forwards what is left of it
+	RequestBudgetEnabled bool `env:"REQUEST_BUDGET_ENABLED" envDefault:"true" desc:"Derive the request deadline from the caller's X-Request-Timeout budget, capped by REQUEST_TIMEOUT"`
+
+	// Global concurrency limit (0 disables). Requests over the limit queue per
+	// priority class and are admitted by weighted fair queuing; the class comes
+	// from the route, or from PRIORITY_HEADER when sent by a trusted caller.
+	MaxConcurrentRequests int           `env:"MAX_CONCURRENT_REQUESTS" envDefault:"0" desc:"Requests served at once; others queue by priority class (0 disables)"`
+	AdmissionQueueSize    int           `env:"ADMISSION_QUEUE_SIZE" envDefault:"256" desc:"Requests that may wait for a slot before new ones get 503"`
+	AdmissionQueueTimeout time.Duration `env:"ADMISSION_QUEUE_TIMEOUT" envDefault:"2s" desc:"Longest wait for a slot before 503"`
+	PriorityHeader        string        `env:"PRIORITY_HEADER" envDefault:"X-Priority" desc:"Request header trusted callers set to a priority class: critical, interactive, normal or bulk"`
+	PriorityTrustedCIDRs  []string      `env:"PRIORITY_TRUSTED_CIDRS" envSeparator:"," desc:"Callers whose priority header is honoured (none when empty)"`
+	PriorityBulkRoutes    []string      `env:"PRIORITY_BULK_ROUTES" envSeparator:"," envDefault:"/api/v1/users/import,/api/v1/reports,/api/v1/files,/admin/metering" desc:"Path prefixes served at bulk priority"`
+
+	// Outbound connections: resolved addresses are cached for their DNS TTL
+	// (capped), and new connections are spread over a host's instances.
+	// OUTBOUND_ENDPOINTS pins hosts to static instances instead of DNS:
+	// "billing.internal=10.0.0.1:8443 10.0.0.2:8443;search.internal=10.0.1.5".
+	OutboundDNSCacheMaxTTL   time.Duration       `env:"OUTBOUND_DNS_CACHE_MAX_TTL" envDefault:"5m" desc:"Longest reuse of resolved outbound addresses, normally their DNS TTL (0 disables the cache)"`
+	OutboundDNSFallbackTTL   time.Duration       `env:"OUTBOUND_DNS_FALLBACK_TTL" envDefault:"30s" desc:"Reuse of addresses whose DNS TTL is unknown, e.g. /etc/hosts entries"`
+	OutboundEndpointsSpec    string              `env:"OUTBOUND_ENDPOINTS" desc:"Static upstream instances per host, e.g. billing.internal=10.0.0.1:8443 10.0.0.2:8443;search.internal=10.0.1.5"`
+	OutboundEndpoints        map[string][]string `env:"-"`
+	OutboundEndpointCooldown time.Duration       `env:"OUTBOUND_ENDPOINT_COOLDOWN" envDefault:"10s" desc:"An upstream instance is skipped this long after a failed connection"`
+
+	// Outbound retries of requests that failed to connect or got 502, 503 or
+	// 504, for idempotent methods and requests with an Idempotency-Key. POSTs
+	// to OUTBOUND_IDEMPOTENT_HOSTS get a key, which the Idempotency middleware
+	// of such services honours; inbound keys are kept for IDEMPOTENCY_TTL.
+	OutboundRetries         int           `env:"OUTBOUND_RETRIES" envDefault:"2" desc:"Attempts after a failed one for outbound requests that are safe to repeat (0 disables retries)"`
+	OutboundRetryBackoff    time.Duration `env:"OUTBOUND_RETRY_BACKOFF" envDefault:"100ms" desc:"Wait before the first outbound retry, doubled for each one after, with jitter"`
+	OutboundIdempotentHosts []string      `env:"OUTBOUND_IDEMPOTENT_HOSTS" envSeparator:"," desc:"Internal hosts (or *.domain patterns) whose POST and PATCH requests get an Idempotency-Key and are retried"`
+	IdempotencyTTL          time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h" desc:"How long responses to requests with an Idempotency-Key are replayed"`
+	IdempotencyMaxKeys      int           `env:"IDEMPOTENCY_MAX_KEYS" envDefault:"10000" desc:"Idempotency keys remembered at most; the oldest completed ones are dropped first"`
+
+	// Service-to-service credentials attached by the outbound client to
+	// requests for S2S_AUTH_HOSTS, cached and refreshed before they expire.
+	S2SAuth          string        `env:"S2S_AUTH" envDefault:"none" enum:"none,client_credentials,kubernetes,gcp,aws" desc:"Token source for calls to internal services: none, client_credentials, kubernetes, gcp or aws"`
+	S2SAuthHosts     []string      `env:"S2S_AUTH_HOSTS" envSeparator:"," desc:"Hosts (or *.domain patterns) that receive the service token"`
+	S2STokenURL      string        `env:"S2S_TOKEN_URL" desc:"OAuth2 token endpoint for client_credentials"`
+	S2SClientID      string        `env:"S2S_CLIENT_ID" desc:"OAuth2 client ID for client_credentials"`
+	S2SClientSecret  string        `env:"S2S_CLIENT_SECRET" desc:"OAuth2 client secret for client_credentials" secret:"true"`
+	S2SScopes        []string      `env:"S2S_SCOPES" envSeparator:"," desc:"OAuth2 scopes requested with client_credentials"`
+	S2SAudience      string        `env:"S2S_AUDIENCE" desc:"Token audience (client_credentials; gcp then issues an ID token)"`
+	S2STokenFile     string        `env:"S2S_TOKEN_FILE" envDefault:"/var/run/secrets/kubernetes.io/serviceaccount/token" desc:"Service account token file for kubernetes"`
+	S2SRefreshBefore time.Duration `env:"S2S_TOKEN_REFRESH_BEFORE" envDefault:"1m" desc:"Tokens are refreshed this long before they expire"`
+
+	// Inbound authentication with API keys (X-API-Key) or JWT bearer tokens.
+	// AUTH_GROUPS names the route groups (see routes.Groups) that require
+	// one; elsewhere only routes declared as authenticated do.
+	JWTHS256Secrets []string      `env:"JWT_HS256_SECRETS" envSeparator:"," secret:"true" desc:"Shared secrets HS256 tokens are verified with; list the new one first while rotating"`
+	JWTJWKSURL      string        `env:"JWT_JWKS_URL" desc:"JWKS document with the RSA keys RS256 tokens are verified with"`
+	JWTJWKSCacheTTL time.Duration `env:"JWT_JWKS_CACHE_TTL" envDefault:"1h" desc:"How long fetched JWKS keys are used before fetching them again"`
+	JWTIssuer       string        `env:"JWT_ISSUER" desc:"Required iss claim (any when empty)"`
+	JWTAudiences    []string      `env:"JWT_AUDIENCE" envSeparator:"," desc:"Accepted aud claims (any when empty)"`
+	JWTTenantClaim  string        `env:"JWT_TENANT_CLAIM" envDefault:"tenant" desc:"Claim holding the caller's tenant"`
+	AuthGroups      []string      `env:"AUTH_GROUPS" envSeparator:"," desc:"Route groups that require an API key or a valid bearer token, e.g. api,uploads"`
+
+	// API version assumed for requests without an API-Version header (the
+	// current version when empty); older versions get their bodies migrated
+	APIVersionDefault string `env:"API_VERSION_DEFAULT" desc:"API version (YYYY-MM-DD) assumed when a request has no API-Version header; the current version when empty"`
+
+	// JSON conventions of every response, applied on top of the json tags
+	JSONFieldNames string `env:"JSON_FIELD_NAMES" envDefault:"snake_case" enum:"snake_case,camelCase" desc:"Field naming of JSON responses: snake_case or camelCase"`
+	JSONTimeFormat string `env:"JSON_TIME_FORMAT" envDefault:"rfc3339" enum:"rfc3339,epoch_millis" desc:"Timestamps in JSON responses: rfc3339 strings or epoch_millis numbers"`
+	JSONTimeUTC    bool   `env:"JSON_TIME_UTC" envDefault:"false" desc:"Convert timestamps in JSON responses to UTC"`
 
 	// CORS
-	CORSAllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" envSeparator:"," envDefault:"*"`
-	CORSAllowedMethods []string `env:"CORS_ALLOWED_METHODS" envSeparator:"," envDefault:"GET,POST,PUT,PATCH,DELETE,OPTIONS"`
-	CORSAllowedHeaders []string `env:"CORS_ALLOWED_HEADERS" envSeparator:"," envDefault:"Accept,Authorization,Content-Type,X-Requested-With"`
+	CORSAllowedOrigins []string      `env:"CORS_ALLOWED_ORIGINS" envSeparator:"," envDefault:"*" desc:"Origins allowed by CORS; supports subdomain patterns such as https://*.example.com"`
+	CORSAllowedMethods []string      `env:"CORS_ALLOWED_METHODS" envSeparator:"," envDefault:"GET,POST,PUT,PATCH,DELETE,OPTIONS" desc:"Methods allowed by CORS"`
+	CORSAllowedHeaders []string      `env:"CORS_ALLOWED_HEADERS" envSeparator:"," envDefault:"Accept,Authorization,Content-Type,X-Requested-With,X-API-Key,API-Version,Tus-Resumable,Upload-Length,Upload-Offset,Upload-Metadata" desc:"Request headers allowed by CORS"`
+	CORSMaxAge         time.Duration `env:"CORS_MAX_AGE" envDefault:"5m" desc:"Preflight cache lifetime sent as Access-Control-Max-Age"`
+	// Per route group origin overrides: "/admin=https://ops.example.com https://internal.example.com;/api/v1/public=*".
+	// Methods and headers are inherited from the global policy.
+	CORSRoutePolicies string              `env:"CORS_ROUTE_POLICIES" desc:"Per route group origin overrides, e.g. /admin=https://ops.example.com;/api/v1/public=*"`
+	CORSRouteOrigins  map[string][]string `env:"-"`
 
 	// Rate limiting
-	RateLimitEnabled bool   `env:"RATE_LIMIT_ENABLED" envDefault:"true"`
-	RateLimitPeriod  string `env:"RATE_LIMIT_PERIOD" envDefault:"1m"` // parsed at runtime
-	RateLimit        int    `env:"RATE_LIMIT" envDefault:"100"`       // requests per period per IP
+	RateLimitEnabled bool   `env:"RATE_LIMIT_ENABLED" envDefault:"true" desc:"Enable rate limiting of /api routes"`
+	RateLimitPeriod  string `env:"RATE_LIMIT_PERIOD" envDefault:"1m" desc:"Rate limit period"` // parsed at runtime
+	RateLimit        int    `env:"RATE_LIMIT" envDefault:"100" desc:"Requests per period per user, or per IP for callers without valid credentials"`
+	BotRateLimit     int    `env:"BOT_RATE_LIMIT" envDefault:"0" desc:"Stricter per-IP limit for bots and unidentified clients (0 disables)"`
+	RateLimitBackend string `env:"RATE_LIMIT_BACKEND" envDefault:"memory" enum:"memory,redis" desc:"Where rate limit counters live: memory (per replica) or redis (shared by all replicas; requires REDIS_URL)"`
+	RateLimitMaxKeys int    `env:"RATE_LIMIT_MAX_KEYS" envDefault:"100000" desc:"Client keys each rate limiter counts in process before evicting others (0 means unbounded)"`
+	// Limits of their own for some routes, replacing RATE_LIMIT there
+	RateLimitRoutesSpec string           `env:"RATE_LIMIT_ROUTES" desc:"Per-route limits by optional method and path prefix, e.g. POST /api/v1/users=10;/api/v1/files=500/1h (period defaults to RATE_LIMIT_PERIOD)"`
+	RateLimitRoutes     []RouteRateLimit `env:"-"`
 
 	// CORS strict mode: fail startup in production if origins include "*"
-	CORSStrict bool `env:"CORS_STRICT" envDefault:"false"`
+	CORSStrict bool `env:"CORS_STRICT" envDefault:"false" desc:"Refuse to start in production when CORS allows all origins"`
+
+	// Boot-time check of the middleware order: off, warn (log hazards) or strict (refuse to start)
+	MiddlewareLint string `env:"MIDDLEWARE_LINT" envDefault:"warn" enum:"off,warn,strict" desc:"Boot-time middleware order check: off, warn or strict (refuse to start)"`
+
+	// Verify storage, transports and upstreams before binding the listeners; failures abort startup
+	PreflightRequired bool          `env:"PREFLIGHT_REQUIRED" envDefault:"false" desc:"Check external dependencies before binding the listeners; failures abort startup"`
+	PreflightTimeout  time.Duration `env:"PREFLIGHT_TIMEOUT" envDefault:"5s" desc:"Timeout of each preflight check"`
+
+	// What the instance runs is logged once at startup as the boot report
+	BootReportEndpoint bool `env:"BOOT_REPORT_ENDPOINT" envDefault:"true" desc:"Serve the startup boot report at /admin/boot"`
+
+	// Swagger UI and the OpenAPI document, served to anyone, to operators or
+	// not at all; the document names the host and scheme of DOCS_PUBLIC_URL
+	DocsExposure  string `env:"DOCS_EXPOSURE" envDefault:"public" enum:"public,admin,disabled" desc:"Who is served the API docs: public, admin (principals with the admin scope, on the admin listener only when ADMIN_PORT is set) or disabled"`
+	DocsPublicURL string `env:"DOCS_PUBLIC_URL" desc:"URL clients reach the API at, e.g. https://api.example.com; the OpenAPI document's host, scheme and base path (the docs page's own when empty)"`
+
+	// Graceful shutdown, logged as the shutdown report. Readiness fails for the
+	// drain delay first; then listeners, streams and jobs share the deadline
+	// and other components each get the stop timeout
+	ShutdownDrainDelay  time.Duration `env:"SHUTDOWN_DRAIN_DELAY" envDefault:"0s" desc:"How long /readyz answers 503 before the listeners stop accepting requests, so load balancers stop sending traffic first"`
+	ShutdownTimeout     time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"10s" desc:"Deadline of a graceful shutdown, after the drain delay"`
+	ShutdownStopTimeout time.Duration `env:"SHUTDOWN_STOP_TIMEOUT" envDefault:"2s" desc:"Time each background component gets to stop during shutdown"`
+
+	// Request traces exported to an OpenTelemetry collector (disabled without an
+	// endpoint). Only OTLP over HTTP with JSON encoding is implemented
+	OTELEndpoint    string            `env:"OTEL_EXPORTER_OTLP_ENDPOINT" desc:"OTLP/HTTP collector base URL, e.g. http://otel-collector:4318; spans are posted to /v1/traces (empty disables tracing)"`
+	OTELProtocol    string            `env:"OTEL_EXPORTER_OTLP_PROTOCOL" envDefault:"http/json" enum:"http/json" desc:"OTLP protocol; only http/json is supported"`
+	OTELHeadersSpec string            `env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true" desc:"Headers sent with every export, e.g. api-key=secret,x-tenant=acme"`
+	OTELHeaders     map[string]string `env:"-"`
+	OTELServiceName string            `env:"OTEL_SERVICE_NAME" envDefault:"go-api" desc:"service.name of the exported spans"`
+	OTELSampleRatio float64           `env:"OTEL_TRACES_SAMPLER_ARG" envDefault:"1" desc:"Share of new traces recorded (0 to 1); traces continued from a caller keep its decision"`
+
+	// Keep checking the preflight dependencies while serving; a feature named in
+	// DEGRADED_FEATURES degrades while any of its dependencies is down
+	DependencyCheckInterval time.Duration       `env:"DEPENDENCY_CHECK_INTERVAL" envDefault:"10s" desc:"How often dependencies are checked while serving (0 disables degradation)"`
+	DegradedFeaturesSpec    string              `env:"DEGRADED_FEATURES" desc:"Features and the dependencies they need, e.g. users=database;files=storage; writes get 503 and reads are served from cache while one is down"`
+	DegradedFeatures        map[string][]string `env:"-"`
+	DegradedCacheEntries    int                 `env:"DEGRADED_CACHE_ENTRIES" envDefault:"1000" desc:"Successful reads kept per feature to serve while it is degraded"`
+
+	// Response header policy. SERVER_HEADER replaces any Server header (removed
+	// when empty); RESPONSE_HEADERS adds organisation headers to every response.
+	// The Cache-Control defaults apply per route class when a handler sets none.
+	ServerHeader        string            `env:"SERVER_HEADER" desc:"Server response header value (removed when empty)"`
+	ResponseTimeHeader  bool              `env:"RESPONSE_TIME_HEADER" envDefault:"true" desc:"Add X-Response-Time (time to first byte) to responses"`
+	ResponseHeadersSpec string            `env:"RESPONSE_HEADERS" desc:"Headers added to every response, e.g. X-Org=acme;X-Env=prod"`
+	ResponseHeaders     map[string]string `env:"-"`
+	CacheControlAPI     string            `env:"CACHE_CONTROL_API" envDefault:"private, no-cache" desc:"Default Cache-Control of /api responses"`
+	CacheControlOps     string            `env:"CACHE_CONTROL_OPS" envDefault:"no-store" desc:"Default Cache-Control of health, metrics and admin responses"`
+	CacheControlDocs    string            `env:"CACHE_CONTROL_DOCS" envDefault:"public, max-age=300" desc:"Default Cache-Control of the API documentation"`
 
 	// Compression level (1-9)
-	CompressionLevel int `env:"COMPRESSION_LEVEL" envDefault:"5"`
+	CompressionLevel int `env:"COMPRESSION_LEVEL" envDefault:"5" desc:"Gzip compression level (1-9)"`
+
+	// Gzipped copies of responses under these prefixes are cached by content
+	// hash, so identical bodies are compressed once (0 bytes disables)
+	CompressionCacheBytes  int64    `env:"COMPRESSION_CACHE_BYTES" envDefault:"16777216" desc:"Memory for cached compressed responses (0 disables)"` // 16 MiB
+	CompressionCacheRoutes []string `env:"COMPRESSION_CACHE_ROUTES" envSeparator:"," envDefault:"/swagger/,/api-docs" desc:"Path prefixes of rarely-changing responses whose compressed form is cached"`
+
+	// Unbounded collections: GET /api responses over either limit are logged,
+	// and in strict mode their largest list is cut to the item limit
+	ResponseGuard         string `env:"RESPONSE_GUARD" envDefault:"warn" enum:"off,warn,strict" desc:"Large response check: off, warn (log and count) or strict (also truncate lists over the item limit, answering 206)"`
+	ResponseGuardMaxItems int    `env:"RESPONSE_GUARD_MAX_ITEMS" envDefault:"1000" desc:"Most items a list in a GET /api response may hold"`
+	ResponseGuardMaxBytes int64  `env:"RESPONSE_GUARD_MAX_BYTES" envDefault:"5242880" desc:"Largest GET /api response body before it is logged"` // 5 MiB
+
+	// Anonymized request/response samples kept per route for GET /admin/samples
+	BodySampleRate     float64 `env:"BODY_SAMPLE_RATE" envDefault:"0" desc:"Share of requests whose anonymized bodies are sampled, 0 to 1 (0 disables)"`
+	BodySamplePerRoute int     `env:"BODY_SAMPLE_PER_ROUTE" envDefault:"20" desc:"Samples kept per route; older ones are replaced"`
+	BodySampleMaxBytes int     `env:"BODY_SAMPLE_MAX_BYTES" envDefault:"4096" desc:"Longest body sampled; longer ones are described by size only"`
+
+	// Upstream credentials handlers resolve per tenant or route with secrets.Get
+	SecretsSource    string        `env:"SECRETS_SOURCE" envDefault:"env" enum:"env,dir" desc:"Where secrets are read: env (SECRETS_ENV_PREFIX variables) or dir (files under SECRETS_DIR)"`
+	SecretsEnvPrefix string        `env:"SECRETS_ENV_PREFIX" envDefault:"SECRET_" desc:"Prefix of the environment variables holding secrets"`
+	SecretsDir       string        `env:"SECRETS_DIR" envDefault:"/var/run/secrets/api" desc:"Directory of secret files, e.g. a mounted Kubernetes secret"`
+	SecretsCacheTTL  time.Duration `env:"SECRETS_CACHE_TTL" envDefault:"5m" desc:"How long a secret is cached before it is read again, picking up rotations"`
+
+	// Queries of /graphql
+	GraphQLMaxDepth      int `env:"GRAPHQL_MAX_DEPTH" envDefault:"10" desc:"Deepest selection a GraphQL query may make"`
+	GraphQLMaxSelections int `env:"GRAPHQL_MAX_SELECTIONS" envDefault:"1000" desc:"Most fields a GraphQL query may select, counting fragments each time they are spread"`
+
+	// Language of localized response fields when Accept-Language matches no message catalog
+	DefaultLanguage string `env:"DEFAULT_LANGUAGE" envDefault:"en" desc:"Language of localized response fields when Accept-Language names none of the message catalogs"`
+
+	// WebSocket connections of /api/v1/ws
+	WSPingInterval    time.Duration `env:"WS_PING_INTERVAL" envDefault:"30s" desc:"How often WebSocket connections are pinged; silent for two intervals, they are closed"`
+	WSMaxMessageBytes int64         `env:"WS_MAX_MESSAGE_BYTES" envDefault:"65536" desc:"Longest message a WebSocket client may send"`
+	WSAllowedOrigins  []string      `env:"WS_ALLOWED_ORIGINS" envSeparator:"," desc:"Browser origins allowed to open WebSockets besides the API's own (* for any)"`
+
+	// Additional listeners (0 disables). TLS is served with the given certificate pair;
+	// the admin listener exclusively serves operational endpoints such as /metrics.
+	TLSPort     int    `env:"TLS_PORT" envDefault:"0" desc:"HTTPS listener port (0 disables)"`
+	TLSCertFile string `env:"TLS_CERT_FILE" desc:"TLS certificate file"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE" desc:"TLS private key file"`
+	TLSRedirect bool   `env:"TLS_REDIRECT" envDefault:"true" desc:"Redirect plain HTTP to HTTPS when TLS_PORT is set"`
+	AdminPort   int    `env:"ADMIN_PORT" envDefault:"0" desc:"Admin listener port; when set, /metrics and /admin are only served there (0 disables)"`
+
+	// PROXY protocol (v1/v2) on the public listeners, e.g. behind HAProxy or an AWS NLB.
+	// Headers are only honoured from the trusted CIDRs (any peer when empty).
+	ProxyProtocol             bool     `env:"PROXY_PROTOCOL" envDefault:"false" desc:"Accept PROXY protocol v1/v2 headers on the public listeners"`
+	ProxyProtocolTrustedCIDRs []string `env:"PROXY_PROTOCOL_TRUSTED_CIDRS" envSeparator:"," desc:"Peers whose PROXY headers are honoured, e.g. the load balancers' subnet (required with PROXY_PROTOCOL=true)"`
+
+	// File storage. Files are kept in memory when STORAGE_DIR is empty.
+	StorageDir     string        `env:"STORAGE_DIR" desc:"Directory for uploaded files (kept in memory when empty)"`
+	UploadMaxBytes int64         `env:"UPLOAD_MAX_BYTES" envDefault:"1073741824" desc:"Largest accepted upload"` // 1 GiB
+	UploadExpiry   time.Duration `env:"UPLOAD_EXPIRY" envDefault:"24h" desc:"Incomplete resumable uploads are discarded after this"`
+
+	// Client-supplied timestamps (token expiry, signature and request times)
+	// are accepted this far off the server's clock
+	ClockSkew time.Duration `env:"CLOCK_SKEW" envDefault:"30s" desc:"Tolerated clock difference when checking timestamps clients send"`
+
+	// Format of the IDs of created resources; all are sortable by creation time
+	IDStrategy string `env:"ID_STRATEGY" envDefault:"uuidv7" enum:"uuidv7,ulid,ksuid" desc:"Format of generated resource IDs: uuidv7, ulid or ksuid"`
+	IDPrefixed bool   `env:"ID_PREFIXED" envDefault:"true" desc:"Start generated IDs with their resource type, e.g. usr_ or tsk_"`
+
+	// Signed download URLs. The secret must be shared by all instances; a random
+	// per-process secret is used when it is empty.
+	SignedURLSecret    string        `env:"SIGNED_URL_SECRET" desc:"HMAC key for signed download URLs, shared by all instances (at least 32 characters)" secret:"true"`
+	SignedURLMaxTTL    time.Duration `env:"SIGNED_URL_MAX_TTL" envDefault:"24h" desc:"Longest lifetime of a signed download URL"`
+	SignedURLClockSkew time.Duration `env:"SIGNED_URL_CLOCK_SKEW" envDefault:"30s" desc:"Tolerated clock difference between instances when checking signed URLs"`
+
+	// Background job pool (reports and other deferred work)
+	JobWorkers        int     `env:"JOB_WORKERS" envDefault:"4" desc:"Background job workers"`
+	JobQueueSize      int     `env:"JOB_QUEUE_SIZE" envDefault:"256" desc:"Background job queue capacity"`
+	JobQueueHighWater float64 `env:"JOB_QUEUE_HIGH_WATER" envDefault:"0.8" desc:"Fraction of JOB_QUEUE_SIZE above which new background work from clients is refused with 429"`
+
+	// Notification transports. Channels without a transport are logged outside
+	// production and skipped in production.
+	NotifySMTPAddr        string `env:"NOTIFY_SMTP_ADDR" desc:"SMTP server (host:port) for email notifications"`
+	NotifySMTPFrom        string `env:"NOTIFY_SMTP_FROM" desc:"Sender address of email notifications"`
+	NotifySMTPUsername    string `env:"NOTIFY_SMTP_USERNAME" desc:"SMTP username"`
+	NotifySMTPPassword    string `env:"NOTIFY_SMTP_PASSWORD" desc:"SMTP password" secret:"true"`
+	NotifySMSWebhookURL   string `env:"NOTIFY_SMS_WEBHOOK_URL" desc:"Webhook that delivers SMS notifications" secret:"true"`
+	NotifyPushWebhookURL  string `env:"NOTIFY_PUSH_WEBHOOK_URL" desc:"Webhook that delivers push notifications" secret:"true"`
+	NotifySlackWebhookURL string `env:"NOTIFY_SLACK_WEBHOOK_URL" desc:"Slack incoming webhook for Slack notifications" secret:"true"`
+
+	// Outgoing webhooks to subscriptions managed under /admin/webhooks. Failed
+	// deliveries are retried with exponential backoff.
+	WebhookMaxAttempts      int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"5" desc:"Delivery attempts per webhook subscription and event"`
+	WebhookRetryDelay       time.Duration `env:"WEBHOOK_RETRY_DELAY" envDefault:"2s" desc:"Delay before the first webhook retry, doubled for each further attempt"`
+	WebhookTimeout          time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s" desc:"Timeout of one webhook delivery attempt"`
+	WebhookKeyRotationGrace time.Duration `env:"WEBHOOK_KEY_ROTATION_GRACE" envDefault:"24h" desc:"How long a replaced webhook signing key keeps signing deliveries after a rotation"`
+
+	// Alerting on panics and 5xx spikes (disabled when ALERT_WEBHOOK_URL is empty).
+	// ALERT_TRACE_URL links request IDs to a log viewer, e.g. "https://logs.example.com/?q={request_id}".
+	AlertWebhookURL    string        `env:"ALERT_WEBHOOK_URL" desc:"Webhook for panic and error spike alerts (disabled when empty)" secret:"true"`
+	AlertWebhookFormat string        `env:"ALERT_WEBHOOK_FORMAT" envDefault:"slack" enum:"slack,json" desc:"Alert payload format: slack or json"`
+	AlertTraceURL      string        `env:"ALERT_TRACE_URL" desc:"Log viewer link for request IDs in alerts, e.g. https://logs.example.com/?q={request_id}"`
+	AlertErrorRate     float64       `env:"ALERT_ERROR_RATE" envDefault:"0.05" desc:"Share of 5xx responses that triggers an alert"`
+	AlertErrorWindow   time.Duration `env:"ALERT_ERROR_WINDOW" envDefault:"1m" desc:"Window the error rate is measured over"`
+	AlertMinRequests   int           `env:"ALERT_MIN_REQUESTS" envDefault:"20" desc:"Minimum requests in the window before the error rate is considered"`
+	AlertDedupWindow   time.Duration `env:"ALERT_DEDUP_WINDOW" envDefault:"10m" desc:"Repeated alerts are suppressed for this long"`
+
+	// Audit trail of state-changing API requests, written to each of the sinks
+	// (disabled when none). The file sink is tamper-evident; check its
+	// integrity with: api audit verify <file>
+	AuditLogFile string   `env:"AUDIT_LOG_FILE" desc:"Hash-chained audit log of state-changing API requests (disabled when empty)"`
+	AuditSinks   []string `env:"AUDIT_SINKS" envSeparator:"," desc:"Where audit records go: any of file (AUDIT_LOG_FILE), log and database (an audit_log table in DATABASE_URL); file when empty and AUDIT_LOG_FILE is set"`
+
+	// Data retention. Policies with a zero max age are disabled; in dry-run mode
+	// policies only log and count what they would remove.
+	RetentionInterval          time.Duration `env:"RETENTION_INTERVAL" envDefault:"1h" desc:"How often retention policies run (0 disables scheduled runs)"`
+	RetentionDryRun            bool          `env:"RETENTION_DRY_RUN" envDefault:"false" desc:"Only log and count what retention policies would remove"`
+	RetentionAuditLogMaxAge    time.Duration `env:"RETENTION_AUDIT_LOG_MAX_AGE" desc:"Audit records older than this are purged (0 keeps them)"` // e.g. 4320h (180 days)
+	RetentionFilesMaxAge       time.Duration `env:"RETENTION_FILES_MAX_AGE" desc:"Stored files and reports older than this are purged (0 keeps them)"`
+	RetentionDeadLettersMaxAge time.Duration `env:"RETENTION_DEAD_LETTERS_MAX_AGE" envDefault:"168h" desc:"Dead-lettered jobs older than this are purged (0 keeps them)"`
+
+	// Per API key usage statistics (GET /api/v1/usage, GET /admin/usage)
+	UsageWindow  time.Duration `env:"USAGE_WINDOW" envDefault:"24h" desc:"Rolling period per-API-key usage is reported over"`
+	UsageMaxKeys int           `env:"USAGE_MAX_KEYS" envDefault:"10000" desc:"API keys tracked individually; further keys are counted as other"`
+
+	// Default quotas per user (0 = unlimited); /admin/quotas overrides them per user.
+	// Counters are persisted to QUOTA_STATE_FILE so they survive restarts.
+	QuotaRequestsPerDay int64  `env:"QUOTA_REQUESTS_PER_DAY" desc:"Default daily request quota per user (0 = unlimited)"`
+	QuotaStorageBytes   int64  `env:"QUOTA_STORAGE_BYTES" desc:"Default storage quota per user (0 = unlimited)"`
+	QuotaUsers          int64  `env:"QUOTA_USERS" desc:"Default user quota per user (0 = unlimited)"`
+	QuotaStateFile      string `env:"QUOTA_STATE_FILE" desc:"File quota counters are persisted to across restarts"`
+
+	// Users are stored in Postgres when DATABASE_URL is set (the binary must be
+	// built with -tags pgx), otherwise in memory
+	DatabaseURL            string        `env:"DATABASE_URL" secret:"true" desc:"Postgres connection URL users are stored under; empty keeps them in memory"`
+	DatabaseConnectTimeout time.Duration `env:"DATABASE_CONNECT_TIMEOUT" envDefault:"5s" desc:"How long startup waits for the database to answer before keeping users in memory"`
+
+	// Tasks reference resource: reads are cached in-process for this long (0 disables the cache)
+	TaskCacheTTL time.Duration `env:"TASK_CACHE_TTL" envDefault:"30s" desc:"How long task reads are cached in-process (0 disables the cache)"`
+
+	// With Redis, writes on one replica invalidate the in-process caches of
+	// all others over a pub/sub channel instead of waiting for the TTL
+	RedisURL                 string `env:"REDIS_URL" secret:"true" desc:"Redis server as redis://[:password@]host[:port][/db]; enables cache invalidation across replicas"`
+	CacheInvalidationChannel string `env:"CACHE_INVALIDATION_CHANNEL" envDefault:"go-api:invalidate" desc:"Redis pub/sub channel cache invalidations are published on"`
+
+	// Packaged assets (notification templates, seed data, dashboards, static
+	// files) are embedded; files in ASSETS_DIR override them. Outside production
+	// the directory is polled and changed templates are reloaded.
+	AssetsDir            string        `env:"ASSETS_DIR" desc:"Directory whose files override the embedded assets, e.g. internal/assets in development"`
+	AssetsReloadInterval time.Duration `env:"ASSETS_RELOAD_INTERVAL" envDefault:"1s" desc:"How often ASSETS_DIR is checked for changes outside production (0 disables reloading)"`
+	SeedData             bool          `env:"SEED_DATA" envDefault:"false" desc:"Create the tasks in seed/tasks.json at startup"`
+
+	// The in-memory stores (users, tasks) are restored from SNAPSHOT_FILE at
+	// startup, replacing seed data, and written back to it on shutdown.
+	SnapshotFile string `env:"SNAPSHOT_FILE" desc:"JSON file the in-memory stores are restored from at startup and saved to on shutdown (disabled when empty)"`
+
+	// Hourly metering rollups served by /admin/metering are kept this long
+	MeteringRetention time.Duration `env:"METERING_RETENTION" envDefault:"48h" desc:"How long hourly metering rollups are kept"`
+
+	// Feature flags. FEATURE_FLAGS_PROVIDER selects a registered provider ("none",
+	// "file", or a vendor adapter); FEATURE_FLAGS_CONFIG is passed to it (the
+	// flags file for "file"). The country attribute is read from the header below.
+	FeatureFlagsProvider      string `env:"FEATURE_FLAGS_PROVIDER" envDefault:"none" desc:"Feature flag provider: none, file, or a registered vendor adapter"`
+	FeatureFlagsConfig        string `env:"FEATURE_FLAGS_CONFIG" desc:"Feature flag provider configuration (the flags file for file)"`
+	FeatureFlagsCountryHeader string `env:"FEATURE_FLAGS_COUNTRY_HEADER" envDefault:"CF-IPCountry" desc:"Request header the country flag attribute is read from"`
+
+	// A/B experiments: "checkout-button=control:50,green:50;search=a:90,b:10"
+	ExperimentsSpec string                   `env:"EXPERIMENTS" desc:"A/B experiments, e.g. checkout=control:50,green:50;search=a:90,b:10"`
+	Experiments     []experiments.Experiment `env:"-"`
+
+	// Canary routing of /api/v1: CANARY_PERCENT of callers (by API key, else IP)
+	// go to CANARY_UPSTREAM_URL when set, otherwise to in-process canary handlers.
+	// The header or cookie ("canary" or "stable") overrides the split.
+	CanaryPercent     int    `env:"CANARY_PERCENT" desc:"Share of callers (0-100) routed to the canary"`
+	CanaryUpstreamURL string `env:"CANARY_UPSTREAM_URL" desc:"Upstream canary requests are proxied to (in-process canary handlers when empty)"`
+	CanaryHeader      string `env:"CANARY_HEADER" envDefault:"X-Canary" desc:"Request header that forces a canary routing variant"`
+	CanaryCookie      string `env:"CANARY_COOKIE" envDefault:"canary" desc:"Cookie that forces a canary routing variant"`
+
+	// Image processing
+	ImageMaxConcurrency  int   `env:"IMAGE_MAX_CONCURRENCY" envDefault:"2" desc:"Concurrent image transforms; others wait briefly, then get 503"`
+	ImageCacheBytes      int64 `env:"IMAGE_CACHE_BYTES" envDefault:"67108864" desc:"Processed image variant cache size (0 disables)"` // 64 MiB
+	ImageMaxSourcePixels int   `env:"IMAGE_MAX_SOURCE_PIXELS" envDefault:"40000000" desc:"Larger source images are refused"`
+	ImageMaxDimension    int   `env:"IMAGE_MAX_DIMENSION" envDefault:"4096" desc:"Largest output width or height"`
 }
 
 // Load parses environment variables into Config and validates values.
@@ -46,11 +362,447 @@ func Load() (*Config, error) {
 	if cfg.BodyLimitBytes <= 0 || cfg.BodyLimitBytes > 1<<30 { // cap at 1 GiB
 		return nil, errors.New("BODY_LIMIT_BYTES must be between 1 and 1073741824 (1GiB)")
 	}
+	if cfg.DecompressionMaxRatio < 0 {
+		return nil, errors.New("DECOMPRESSION_MAX_RATIO must be >= 0")
+	}
+	if cfg.MaxConcurrentRequests < 0 {
+		return nil, errors.New("MAX_CONCURRENT_REQUESTS must be >= 0")
+	}
+	if cfg.AdmissionQueueSize < 0 || cfg.AdmissionQueueTimeout <= 0 {
+		return nil, errors.New("ADMISSION_QUEUE_SIZE must be >= 0 and ADMISSION_QUEUE_TIMEOUT > 0")
+	}
+	if _, err := proxyproto.ParseCIDRs(cfg.PriorityTrustedCIDRs); err != nil {
+		return nil, fmt.Errorf("PRIORITY_TRUSTED_CIDRS: %w", err)
+	}
+	if cfg.OutboundDNSCacheMaxTTL < 0 || cfg.OutboundDNSFallbackTTL < 0 || cfg.OutboundEndpointCooldown < 0 {
+		return nil, errors.New("OUTBOUND_DNS_CACHE_MAX_TTL, OUTBOUND_DNS_FALLBACK_TTL and OUTBOUND_ENDPOINT_COOLDOWN must be >= 0")
+	}
+	routeOrigins, err := ParseCORSRoutePolicies(cfg.CORSRoutePolicies)
+	if err != nil {
+		return nil, fmt.Errorf("CORS_ROUTE_POLICIES: %w", err)
+	}
+	cfg.CORSRouteOrigins = routeOrigins
+	if cfg.ResponseHeaders, err = ParseResponseHeaders(cfg.ResponseHeadersSpec); err != nil {
+		return nil, fmt.Errorf("RESPONSE_HEADERS: %w", err)
+	}
+	if cfg.APIVersionDefault != "" {
+		if _, err := time.Parse("2006-01-02", cfg.APIVersionDefault); err != nil {
+			return nil, errors.New("API_VERSION_DEFAULT must be a date (YYYY-MM-DD)")
+		}
+	}
+	switch cfg.S2SAuth {
+	case "none":
+	case "client_credentials":
+		if cfg.S2STokenURL == "" || cfg.S2SClientID == "" || cfg.S2SClientSecret == "" {
+			return nil, errors.New("S2S_TOKEN_URL, S2S_CLIENT_ID and S2S_CLIENT_SECRET are required when S2S_AUTH=client_credentials")
+		}
+	case "kubernetes", "gcp", "aws":
+	default:
+		return nil, errors.New("S2S_AUTH must be none, client_credentials, kubernetes, gcp or aws")
+	}
+	if cfg.S2SAuth != "none" && len(cfg.S2SAuthHosts) == 0 {
+		return nil, errors.New("S2S_AUTH_HOSTS is required when S2S_AUTH is set")
+	}
+	if cfg.S2SRefreshBefore < 0 {
+		return nil, errors.New("S2S_TOKEN_REFRESH_BEFORE must be >= 0")
+	}
+	if cfg.OutboundEndpoints, err = ParseOutboundEndpoints(cfg.OutboundEndpointsSpec); err != nil {
+		return nil, fmt.Errorf("OUTBOUND_ENDPOINTS: %w", err)
+	}
+	if cfg.OutboundRetries < 0 || cfg.OutboundRetryBackoff < 0 {
+		return nil, errors.New("OUTBOUND_RETRIES and OUTBOUND_RETRY_BACKOFF must be >= 0")
+	}
+	if cfg.IdempotencyTTL <= 0 || cfg.IdempotencyMaxKeys <= 0 {
+		return nil, errors.New("IDEMPOTENCY_TTL and IDEMPOTENCY_MAX_KEYS must be > 0")
+	}
+	if cfg.MiddlewareLint != "off" && cfg.MiddlewareLint != "warn" && cfg.MiddlewareLint != "strict" {
+		return nil, errors.New("MIDDLEWARE_LINT must be off, warn or strict")
+	}
+	if cfg.PreflightTimeout <= 0 {
+		return nil, errors.New("PREFLIGHT_TIMEOUT must be > 0")
+	}
+	if cfg.DocsExposure != "public" && cfg.DocsExposure != "admin" && cfg.DocsExposure != "disabled" {
+		return nil, errors.New("DOCS_EXPOSURE must be public, admin or disabled")
+	}
+	if cfg.DocsPublicURL != "" {
+		u, err := url.Parse(cfg.DocsPublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return nil, errors.New("DOCS_PUBLIC_URL must be an http or https URL without query or fragment")
+		}
+	}
+	if cfg.ShutdownTimeout <= 0 || cfg.ShutdownStopTimeout <= 0 {
+		return nil, errors.New("SHUTDOWN_TIMEOUT and SHUTDOWN_STOP_TIMEOUT must be > 0")
+	}
+	if cfg.ShutdownDrainDelay < 0 {
+		return nil, errors.New("SHUTDOWN_DRAIN_DELAY must be >= 0")
+	}
+	if cfg.OTELProtocol != "http/json" {
+		return nil, errors.New("OTEL_EXPORTER_OTLP_PROTOCOL must be http/json; grpc and http/protobuf are not supported")
+	}
+	if cfg.OTELHeaders, err = ParseOTLPHeaders(cfg.OTELHeadersSpec); err != nil {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+	}
+	if cfg.OTELSampleRatio < 0 || cfg.OTELSampleRatio > 1 {
+		return nil, errors.New("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+	}
+	if cfg.DependencyCheckInterval < 0 {
+		return nil, errors.New("DEPENDENCY_CHECK_INTERVAL must be >= 0")
+	}
+	if cfg.DegradedFeatures, err = ParseDegradedFeatures(cfg.DegradedFeaturesSpec); err != nil {
+		return nil, fmt.Errorf("DEGRADED_FEATURES: %w", err)
+	}
+	if cfg.DegradedCacheEntries <= 0 {
+		return nil, errors.New("DEGRADED_CACHE_ENTRIES must be > 0")
+	}
 	if cfg.RateLimitEnabled && cfg.RateLimit <= 0 {
 		return nil, errors.New("RATE_LIMIT must be > 0 when RATE_LIMIT_ENABLED=true")
 	}
+	if cfg.BotRateLimit < 0 {
+		return nil, errors.New("BOT_RATE_LIMIT must be >= 0")
+	}
+	if cfg.RateLimitRoutes, err = ParseRateLimitRoutes(cfg.RateLimitRoutesSpec); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+	}
+	if cfg.RateLimitBackend != "memory" && cfg.RateLimitBackend != "redis" {
+		return nil, errors.New("RATE_LIMIT_BACKEND must be memory or redis")
+	}
+	if cfg.RateLimitBackend == "redis" && cfg.RedisURL == "" {
+		return nil, errors.New("REDIS_URL must be set when RATE_LIMIT_BACKEND=redis")
+	}
+	if cfg.RateLimitMaxKeys < 0 {
+		return nil, errors.New("RATE_LIMIT_MAX_KEYS must be >= 0")
+	}
 	if cfg.CompressionLevel < 1 || cfg.CompressionLevel > 9 {
 		return nil, errors.New("COMPRESSION_LEVEL must be between 1 and 9")
 	}
+	if cfg.ResponseGuard != "off" && cfg.ResponseGuard != "warn" && cfg.ResponseGuard != "strict" {
+		return nil, errors.New("RESPONSE_GUARD must be off, warn or strict")
+	}
+	if cfg.ResponseGuardMaxItems <= 0 || cfg.ResponseGuardMaxBytes <= 0 {
+		return nil, errors.New("RESPONSE_GUARD_MAX_ITEMS and RESPONSE_GUARD_MAX_BYTES must be > 0")
+	}
+	if cfg.BodySampleRate < 0 || cfg.BodySampleRate > 1 {
+		return nil, errors.New("BODY_SAMPLE_RATE must be between 0 and 1")
+	}
+	if cfg.BodySamplePerRoute <= 0 || cfg.BodySampleMaxBytes <= 0 {
+		return nil, errors.New("BODY_SAMPLE_PER_ROUTE and BODY_SAMPLE_MAX_BYTES must be > 0")
+	}
+	if cfg.SecretsSource != "env" && cfg.SecretsSource != "dir" {
+		return nil, errors.New("SECRETS_SOURCE must be env or dir")
+	}
+	if cfg.SecretsCacheTTL <= 0 {
+		return nil, errors.New("SECRETS_CACHE_TTL must be > 0")
+	}
+	if cfg.GraphQLMaxDepth <= 0 || cfg.GraphQLMaxSelections <= 0 {
+		return nil, errors.New("GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_SELECTIONS must be > 0")
+	}
+	if !i18n.ValidTag(cfg.DefaultLanguage) {
+		return nil, errors.New("DEFAULT_LANGUAGE must be a language tag, e.g. en or pt-BR")
+	}
+	if cfg.WSPingInterval <= 0 || cfg.WSMaxMessageBytes <= 0 {
+		return nil, errors.New("WS_PING_INTERVAL and WS_MAX_MESSAGE_BYTES must be > 0")
+	}
+	if cfg.CompressionCacheBytes < 0 {
+		return nil, errors.New("COMPRESSION_CACHE_BYTES must be >= 0")
+	}
+	if cfg.TLSPort < 0 || cfg.TLSPort > 65535 {
+		return nil, errors.New("invalid TLS_PORT")
+	}
+	if cfg.TLSPort > 0 && (cfg.TLSCertFile == "" || cfg.TLSKeyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE are required when TLS_PORT is set")
+	}
+	if cfg.AdminPort < 0 || cfg.AdminPort > 65535 {
+		return nil, errors.New("invalid ADMIN_PORT")
+	}
+	if (cfg.TLSPort > 0 && cfg.TLSPort == cfg.Port) || (cfg.AdminPort > 0 && (cfg.AdminPort == cfg.Port || cfg.AdminPort == cfg.TLSPort)) {
+		return nil, errors.New("PORT, TLS_PORT and ADMIN_PORT must be distinct")
+	}
+	proxyTrusted, err := proxyproto.ParseCIDRs(cfg.ProxyProtocolTrustedCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("PROXY_PROTOCOL_TRUSTED_CIDRS: %w", err)
+	}
+	if cfg.ProxyProtocol && len(proxyTrusted) == 0 {
+		return nil, errors.New("PROXY_PROTOCOL_TRUSTED_CIDRS must be set when PROXY_PROTOCOL=true")
+	}
+	if cfg.UploadMaxBytes <= 0 {
+		return nil, errors.New("UPLOAD_MAX_BYTES must be > 0")
+	}
+	if cfg.UploadExpiry <= 0 {
+		return nil, errors.New("UPLOAD_EXPIRY must be > 0")
+	}
+	if cfg.SignedURLSecret != "" && len(cfg.SignedURLSecret) < 32 {
+		return nil, errors.New("SIGNED_URL_SECRET must be at least 32 characters")
+	}
+	if len(cfg.AuditSinks) == 0 && cfg.AuditLogFile != "" {
+		cfg.AuditSinks = []string{"file"}
+	}
+	for _, sink := range cfg.AuditSinks {
+		switch {
+		case sink != "file" && sink != "log" && sink != "database":
+			return nil, errors.New("AUDIT_SINKS must list file, log or database")
+		case sink == "file" && cfg.AuditLogFile == "":
+			return nil, errors.New("AUDIT_SINKS file requires AUDIT_LOG_FILE")
+		case sink == "database" && cfg.DatabaseURL == "":
+			return nil, errors.New("AUDIT_SINKS database requires DATABASE_URL")
+		}
+	}
+	if cfg.IDStrategy != "uuidv7" && cfg.IDStrategy != "ulid" && cfg.IDStrategy != "ksuid" {
+		return nil, errors.New("ID_STRATEGY must be uuidv7, ulid or ksuid")
+	}
+	if cfg.SignedURLMaxTTL <= 0 {
+		return nil, errors.New("SIGNED_URL_MAX_TTL must be > 0")
+	}
+	if cfg.SignedURLClockSkew < 0 || cfg.ClockSkew < 0 {
+		return nil, errors.New("SIGNED_URL_CLOCK_SKEW and CLOCK_SKEW must be >= 0")
+	}
+	if cfg.WebhookMaxAttempts <= 0 || cfg.WebhookRetryDelay <= 0 || cfg.WebhookTimeout <= 0 || cfg.WebhookKeyRotationGrace < 0 {
+		return nil, errors.New("WEBHOOK_MAX_ATTEMPTS, WEBHOOK_RETRY_DELAY and WEBHOOK_TIMEOUT must be > 0 and WEBHOOK_KEY_ROTATION_GRACE >= 0")
+	}
+	if cfg.JobWorkers <= 0 || cfg.JobQueueSize <= 0 {
+		return nil, errors.New("JOB_WORKERS and JOB_QUEUE_SIZE must be > 0")
+	}
+	if cfg.AssetsReloadInterval < 0 {
+		return nil, errors.New("ASSETS_RELOAD_INTERVAL must be >= 0")
+	}
+	if cfg.AssetsDir != "" {
+		if info, err := os.Stat(cfg.AssetsDir); err != nil || !info.IsDir() {
+			return nil, errors.New("ASSETS_DIR must be an existing directory")
+		}
+	}
+	if cfg.RedisURL != "" {
+		if _, err := redis.ParseURL(cfg.RedisURL); err != nil {
+			return nil, errors.New("REDIS_URL must be redis://[:password@]host[:port][/db]")
+		}
+		if cfg.CacheInvalidationChannel == "" {
+			return nil, errors.New("CACHE_INVALIDATION_CHANNEL must not be empty when REDIS_URL is set")
+		}
+	}
+	if cfg.JobQueueHighWater <= 0 || cfg.JobQueueHighWater > 1 {
+		return nil, errors.New("JOB_QUEUE_HIGH_WATER must be > 0 and <= 1")
+	}
+	if cfg.NotifySMTPAddr != "" && cfg.NotifySMTPFrom == "" {
+		return nil, errors.New("NOTIFY_SMTP_FROM is required when NOTIFY_SMTP_ADDR is set")
+	}
+	if (cfg.JWTIssuer != "" || len(cfg.JWTAudiences) > 0) && len(cfg.JWTHS256Secrets) == 0 && cfg.JWTJWKSURL == "" {
+		return nil, errors.New("JWT_ISSUER and JWT_AUDIENCE require JWT_HS256_SECRETS or JWT_JWKS_URL")
+	}
+	for _, secret := range cfg.JWTHS256Secrets {
+		if len(secret) < 32 {
+			return nil, errors.New("JWT_HS256_SECRETS must be at least 32 characters each")
+		}
+	}
+	if cfg.JSONFieldNames != "snake_case" && cfg.JSONFieldNames != "camelCase" {
+		return nil, errors.New("JSON_FIELD_NAMES must be snake_case or camelCase")
+	}
+	if cfg.JSONTimeFormat != "rfc3339" && cfg.JSONTimeFormat != "epoch_millis" {
+		return nil, errors.New("JSON_TIME_FORMAT must be rfc3339 or epoch_millis")
+	}
+	if cfg.AlertWebhookFormat != "slack" && cfg.AlertWebhookFormat != "json" {
+		return nil, errors.New("ALERT_WEBHOOK_FORMAT must be slack or json")
+	}
+	if cfg.AlertErrorRate <= 0 || cfg.AlertErrorRate > 1 {
+		return nil, errors.New("ALERT_ERROR_RATE must be between 0 and 1")
+	}
+	if cfg.RetentionInterval < 0 || cfg.RetentionAuditLogMaxAge < 0 || cfg.RetentionFilesMaxAge < 0 || cfg.RetentionDeadLettersMaxAge < 0 {
+		return nil, errors.New("RETENTION_INTERVAL and RETENTION_*_MAX_AGE must be >= 0")
+	}
+	if cfg.UsageWindow <= 0 || cfg.UsageMaxKeys <= 0 {
+		return nil, errors.New("USAGE_WINDOW and USAGE_MAX_KEYS must be > 0")
+	}
+	if cfg.QuotaRequestsPerDay < 0 || cfg.QuotaStorageBytes < 0 || cfg.QuotaUsers < 0 {
+		return nil, errors.New("QUOTA_* limits must be >= 0")
+	}
+	if cfg.MeteringRetention <= 0 {
+		return nil, errors.New("METERING_RETENTION must be > 0")
+	}
+	if cfg.Experiments, err = experiments.Parse(cfg.ExperimentsSpec); err != nil {
+		return nil, fmt.Errorf("EXPERIMENTS: %w", err)
+	}
+	if cfg.CanaryPercent < 0 || cfg.CanaryPercent > 100 {
+		return nil, errors.New("CANARY_PERCENT must be between 0 and 100")
+	}
+	if cfg.CanaryUpstreamURL != "" {
+		if u, err := url.Parse(cfg.CanaryUpstreamURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("CANARY_UPSTREAM_URL must be an absolute http(s) URL")
+		}
+	}
+	if cfg.ImageMaxConcurrency <= 0 {
+		return nil, errors.New("IMAGE_MAX_CONCURRENCY must be > 0")
+	}
+	if cfg.ImageCacheBytes < 0 {
+		return nil, errors.New("IMAGE_CACHE_BYTES must be >= 0")
+	}
+	if cfg.ImageMaxSourcePixels <= 0 || cfg.ImageMaxDimension <= 0 {
+		return nil, errors.New("IMAGE_MAX_SOURCE_PIXELS and IMAGE_MAX_DIMENSION must be > 0")
+	}
 	return &cfg, nil
 }
+
+// ParseCORSRoutePolicies parses "prefix=origin origin;prefix=origin" into a map
+// of route prefix to allowed origins.
+func ParseCORSRoutePolicies(s string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, origins, ok := strings.Cut(entry, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid entry %q: expected /prefix=origin", entry)
+		}
+		list := strings.Fields(origins)
+		if len(list) == 0 {
+			return nil, fmt.Errorf("invalid entry %q: no origins", entry)
+		}
+		out[prefix] = list
+	}
+	return out, nil
+}
+
+// ParseResponseHeaders parses "Name=value;Name=value" into a map of header
+// name to value.
+func ParseResponseHeaders(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("invalid entry %q: expected Name=value", entry)
+		}
+		out[name] = strings.TrimSpace(value)
+	}
+	return out, nil
+}
+
+// ParseOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS, "name=value,name=value"
+// with URL-encoded values as the OpenTelemetry specification defines it.
+func ParseOTLPHeaders(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("invalid entry %q: expected name=value", name)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %q: %w", name, err)
+		}
+		out[name] = decoded
+	}
+	return out, nil
+}
+
+// ParseDegradedFeatures parses "feature=dependency dependency;feature=dependency"
+// into a map of feature to the dependencies it needs. Dependencies are named
+// like the preflight checks, e.g. database, redis or storage.
+func ParseDegradedFeatures(s string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		feature, deps, ok := strings.Cut(entry, "=")
+		feature = strings.TrimSpace(feature)
+		if !ok || feature == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected feature=dependency", entry)
+		}
+		list := strings.Fields(deps)
+		if len(list) == 0 {
+			return nil, fmt.Errorf("invalid entry %q: no dependencies", entry)
+		}
+		out[feature] = list
+	}
+	return out, nil
+}
+
+// RouteRateLimit is the rate limit of the requests whose path starts with
+// Prefix and, unless Method is empty, whose method is Method.
+type RouteRateLimit struct {
+	Method string
+	Prefix string
+	Limit  int
+	Period time.Duration // 0 means RATE_LIMIT_PERIOD
+}
+
+// ParseRateLimitRoutes parses "[METHOD ]/prefix=limit[/period];..." into
+// route limits, e.g. "POST /api/v1/users=10;/api/v1/files=500/1h".
+func ParseRateLimitRoutes(s string) ([]RouteRateLimit, error) {
+	var out []RouteRateLimit
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected [METHOD ]/prefix=limit[/period]", entry)
+		}
+		var rl RouteRateLimit
+		route = strings.TrimSpace(route)
+		if method, prefix, ok := strings.Cut(route, " "); ok {
+			rl.Method, rl.Prefix = strings.ToUpper(method), strings.TrimSpace(prefix)
+		} else {
+			rl.Prefix = route
+		}
+		if !strings.HasPrefix(rl.Prefix, "/") {
+			return nil, fmt.Errorf("invalid entry %q: the path prefix must start with /", entry)
+		}
+		rl.Prefix = strings.TrimSuffix(rl.Prefix, "/")
+		n, period, hasPeriod := strings.Cut(strings.TrimSpace(limit), "/")
+		var err error
+		if rl.Limit, err = strconv.Atoi(n); err != nil || rl.Limit <= 0 {
+			return nil, fmt.Errorf("invalid entry %q: the limit must be a positive integer", entry)
+		}
+		if hasPeriod {
+			if rl.Period, err = time.ParseDuration(period); err != nil || rl.Period <= 0 {
+				return nil, fmt.Errorf("invalid entry %q: invalid period %q", entry, period)
+			}
+		}
+		out = append(out, rl)
+	}
+	return out, nil
+}
+
+// ParseOutboundEndpoints parses "host=addr addr;host=addr" into a map of host
+// to instance addresses, each an IP with an optional port.
+func ParseOutboundEndpoints(s string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, addrs, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected host=address", entry)
+		}
+		list := strings.Fields(addrs)
+		if len(list) == 0 {
+			return nil, fmt.Errorf("invalid entry %q: no addresses", entry)
+		}
+		for _, a := range list {
+			ip := a
+			if h, _, err := net.SplitHostPort(a); err == nil {
+				ip = h
+			}
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("invalid address %q: expected ip or ip:port", a)
+			}
+		}
+		out[host] = list
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitRoutes(t *testing.T) {
+	got, err := ParseRateLimitRoutes("post /api/v1/users=10; /api/v1/files/=500/1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RouteRateLimit{
+		{Method: "POST", Prefix: "/api/v1/users", Limit: 10},
+		{Prefix: "/api/v1/files", Limit: 500, Period: time.Hour},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for _, spec := range []string{"POST /users", "users=10", "/users=0", "/users=10/soon"} {
+		if _, err := ParseRateLimitRoutes(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	got, err := ParseOTLPHeaders("api-key=s3cr%2Ct, x-tenant = acme ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["api-key"] != "s3cr,t" || got["x-tenant"] != "acme" {
+		t.Fatalf("unexpected headers %v", got)
+	}
+	for _, spec := range []string{"api-key", "=v", "k=%zz"} {
+		if _, err := ParseOTLPHeaders(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field describes one environment variable read into Config.
+type Field struct {
+	Key         string   `json:"key"`     // environment variable name
+	Name        string   `json:"-"`       // Config field name
+	Type        string   `json:"type"`    // string, integer, number, boolean, duration or list
+	Default     string   `json:"default"` // envDefault, when HasDefault
+	HasDefault  bool     `json:"has_default"`
+	Separator   string   `json:"separator,omitempty"` // list separator
+	Enum        []string `json:"enum,omitempty"`      // allowed values, from the enum tag
+	Description string   `json:"description"`         // from the desc tag
+	Secret      bool     `json:"secret"`              // value is redacted by Describe
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Fields lists the environment variables of Config in declaration order.
+// Derived fields (env:"-") are skipped.
+func Fields() []Field {
+	t := reflect.TypeOf(Config{})
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key, _, _ := strings.Cut(sf.Tag.Get("env"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		f := Field{Key: key, Name: sf.Name, Type: kindOf(sf.Type)}
+		f.Default, f.HasDefault = sf.Tag.Lookup("envDefault")
+		if f.Type == "list" {
+			f.Separator = sf.Tag.Get("envSeparator")
+			if f.Separator == "" {
+				f.Separator = ","
+			}
+		}
+		if enum := sf.Tag.Get("enum"); enum != "" {
+			f.Enum = strings.Split(enum, ",")
+		}
+		f.Description = sf.Tag.Get("desc")
+		f.Secret = sf.Tag.Get("secret") == "true"
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func kindOf(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list"
+	}
+	return "string"
+}
+
+// durationPattern matches the values accepted by time.ParseDuration.
+const durationPattern = `^(0|[-+]?([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the environment
+// as an object keyed by variable name. Numbers and booleans are typed, so the
+// schema also validates structured values such as Helm chart settings.
+func JSONSchema() ([]byte, error) {
+	props := make(map[string]any)
+	for _, f := range Fields() {
+		p := map[string]any{"description": f.Description}
+		switch f.Type {
+		case "integer", "number", "boolean":
+			p["type"] = f.Type
+		case "duration":
+			p["type"] = "string"
+			p["pattern"] = durationPattern
+		case "list":
+			p["type"] = "string"
+			p["description"] = fmt.Sprintf("%s (%q-separated list)", f.Description, f.Separator)
+		default:
+			p["type"] = "string"
+		}
+		if f.Enum != nil {
+			p["enum"] = f.Enum
+		}
+		if f.Secret {
+			p["writeOnly"] = true
+		}
+		if f.HasDefault {
+			def, err := f.typedDefault()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Key, err)
+			}
+			p["default"] = def
+		}
+		props[f.Key] = p
+	}
+	return json.MarshalIndent(map[string]any{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "API server configuration",
+		"type":       "object",
+		"properties": props,
+	}, "", "  ")
+}
+
+// typedDefault returns the default as the JSON type used in the schema.
+func (f Field) typedDefault() (any, error) {
+	switch f.Type {
+	case "integer":
+		return strconv.ParseInt(f.Default, 10, 64)
+	case "number":
+		return strconv.ParseFloat(f.Default, 64)
+	case "boolean":
+		return strconv.ParseBool(f.Default)
+	}
+	return f.Default, nil
+}
+
+// ExampleEnv renders a .env file with every variable set to its default;
+// variables without a default are commented out.
+func ExampleEnv() string {
+	var b strings.Builder
+	for _, f := range Fields() {
+		if f.HasDefault {
+			fmt.Fprintf(&b, "%s=%s\n", f.Key, f.Default)
+		} else {
+			fmt.Fprintf(&b, "# %s=\n", f.Key)
+		}
+	}
+	return b.String()
+}
+
+// Redacted replaces the values of secret settings.
+const Redacted = "[redacted]"
+
+// Setting is a Field with its current value.
+type Setting struct {
+	Field
+	Value string `json:"value"`
+}
+
+// Describe returns every Field with its value in cfg, formatted as it would
+// be written in the environment. Secrets that are set read as Redacted.
+func Describe(cfg *Config) []Setting {
+	v := reflect.ValueOf(cfg).Elem()
+	fields := Fields()
+	out := make([]Setting, len(fields))
+	for i, f := range fields {
+		out[i] = Setting{Field: f, Value: formatValue(v.FieldByName(f.Name), f.Separator)}
+		if f.Secret && out[i].Value != "" {
+			out[i].Value = Redacted
+		}
+	}
+	return out
+}
+
+func formatValue(v reflect.Value, sep string) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, sep)
+	}
+	return fmt.Sprint(v.Interface())
+}
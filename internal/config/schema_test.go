@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	var schema struct {
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if p := schema.Properties["PORT"]; p["type"] != "integer" || p["default"] != float64(8080) {
+		t.Fatalf("unexpected PORT schema: %v", p)
+	}
+	if p := schema.Properties["REQUEST_TIMEOUT"]; p["pattern"] == nil || p["default"] != "15s" {
+		t.Fatalf("unexpected REQUEST_TIMEOUT schema: %v", p)
+	}
+	if _, ok := schema.Properties["CORS_ROUTE_POLICIES"]; !ok {
+		t.Fatal("missing CORS_ROUTE_POLICIES")
+	}
+	for key := range schema.Properties {
+		if key == "-" || key == "" {
+			t.Fatalf("derived field leaked into schema: %q", key)
+		}
+	}
+}
+
+func TestExampleEnv(t *testing.T) {
+	env := ExampleEnv()
+	if !strings.HasPrefix(env, "APP_ENV=development\n") || !strings.Contains(env, "\n# AUDIT_LOG_FILE=\n") {
+		t.Fatalf("unexpected example env:\n%s", env)
+	}
+}
+
+func TestDescribe_RedactsSecrets(t *testing.T) {
+	cfg := &Config{
+		SignedURLSecret:    "0123456789abcdef0123456789abcdef",
+		CORSAllowedOrigins: []string{"https://a.example", "https://b.example"},
+		RequestTimeout:     15e9,
+	}
+	values := map[string]Setting{}
+	for _, s := range Describe(cfg) {
+		values[s.Key] = s
+	}
+	if s := values["SIGNED_URL_SECRET"]; s.Value != Redacted || !s.Secret {
+		t.Fatalf("secret not redacted: %+v", s)
+	}
+	if s := values["ALERT_WEBHOOK_URL"]; s.Value != "" {
+		t.Fatalf("unset secret should be empty, got %q", s.Value)
+	}
+	if got := values["CORS_ALLOWED_ORIGINS"].Value; got != "https://a.example,https://b.example" {
+		t.Fatalf("unexpected list value %q", got)
+	}
+	if s := values["REQUEST_TIMEOUT"]; s.Value != "15s" || s.Description == "" {
+		t.Fatalf("unexpected REQUEST_TIMEOUT setting: %+v", s)
+	}
+}
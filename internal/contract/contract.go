@@ -0,0 +1,279 @@
+// Package contract records what API consumers rely on and verifies it against
+// the provider. A contract is a Pact-style list of interactions: a request a
+// consumer makes and the response it expects. Responses are matched by shape,
+// not by value: every field the consumer reads must be present with the same
+// JSON type, while new fields and different example values are allowed.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Contract is the set of interactions one consumer depends on.
+type Contract struct {
+	Consumer     string        `json:"consumer"`
+	Provider     string        `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one request and the response the consumer expects.
+type Interaction struct {
+	Description string `json:"description"`
+	// ProviderState names the data the provider must hold first, such as
+	// "a task exists"; see State.
+	ProviderState string   `json:"provider_state,omitempty"`
+	Request       Request  `json:"request"`
+	Response      Response `json:"response"`
+}
+
+// Request is the request a consumer sends. Path, query and body may contain
+// {{name}} placeholders filled from the provider state.
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Response is the response a consumer expects. Headers are compared by value
+// (ignoring media type parameters for Content-Type); the body by shape.
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// State prepares the provider for an interaction, typically by sending setup
+// requests to h, and returns values for the interaction's placeholders.
+type State func(h http.Handler) (map[string]string, error)
+
+// Mismatch is one way the provider broke an interaction.
+type Mismatch struct {
+	Consumer    string
+	Interaction string
+	Path        string // JSON path into the body, or the header name
+	Message     string
+}
+
+func (m Mismatch) Error() string {
+	if m.Path == "" {
+		return fmt.Sprintf("%s: %s: %s", m.Consumer, m.Interaction, m.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", m.Consumer, m.Interaction, m.Path, m.Message)
+}
+
+// Load reads a contract file.
+func Load(path string) (*Contract, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Contract
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse contract %s: %w", path, err)
+	}
+	if c.Consumer == "" {
+		return nil, fmt.Errorf("parse contract %s: missing consumer", path)
+	}
+	for i, in := range c.Interactions {
+		if in.Request.Method == "" || in.Request.Path == "" || in.Response.Status == 0 {
+			return nil, fmt.Errorf("parse contract %s: interaction %d needs a method, path and status", path, i)
+		}
+	}
+	return &c, nil
+}
+
+// LoadDir reads every *.json contract in dir, sorted by file name.
+func LoadDir(dir string) ([]*Contract, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	out := make([]*Contract, 0, len(paths))
+	for _, p := range paths {
+		c, err := Load(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// WriteFile stores c as indented JSON.
+func (c *Contract) WriteFile(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Verify replays every interaction of c against h and returns the mismatches.
+// states sets up the provider states the interactions name; an unknown state
+// is itself a mismatch.
+func Verify(h http.Handler, c *Contract, states map[string]State) []Mismatch {
+	var out []Mismatch
+	for _, in := range c.Interactions {
+		fail := func(path, format string, args ...any) {
+			out = append(out, Mismatch{Consumer: c.Consumer, Interaction: in.Description, Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+
+		vars := map[string]string{}
+		if in.ProviderState != "" {
+			setup, ok := states[in.ProviderState]
+			if !ok {
+				fail("", "unknown provider state %q", in.ProviderState)
+				continue
+			}
+			v, err := setup(h)
+			if err != nil {
+				fail("", "provider state %q: %v", in.ProviderState, err)
+				continue
+			}
+			vars = v
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, buildRequest(in.Request, vars))
+
+		if rec.Code != in.Response.Status {
+			fail("", "expected status %d, got %d: %s", in.Response.Status, rec.Code, truncate(rec.Body.String()))
+			continue
+		}
+		for name, want := range in.Response.Headers {
+			got := rec.Header().Get(name)
+			if strings.EqualFold(name, "Content-Type") {
+				got = mediaType(got)
+			}
+			if !strings.EqualFold(got, want) {
+				fail(name, "expected header %q, got %q", want, got)
+			}
+		}
+		if len(in.Response.Body) == 0 {
+			continue
+		}
+		var want, got any
+		if err := json.Unmarshal(in.Response.Body, &want); err != nil {
+			fail("$", "invalid expected body: %v", err)
+			continue
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			fail("$", "expected a JSON body, got %q", truncate(rec.Body.String()))
+			continue
+		}
+		for _, m := range Match(want, got) {
+			fail(m.Path, "%s", m.Message)
+		}
+	}
+	return out
+}
+
+func buildRequest(req Request, vars map[string]string) *http.Request {
+	pairs := make([]string, 0, 2*len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	fill := strings.NewReplacer(pairs...).Replace
+
+	target := fill(req.Path)
+	if req.Query != "" {
+		target += "?" + fill(req.Query)
+	}
+	r := httptest.NewRequest(req.Method, target, strings.NewReader(fill(string(req.Body))))
+	for k, v := range req.Headers {
+		r.Header.Set(k, fill(v))
+	}
+	if len(req.Body) > 0 && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// Match compares a response body with the expected one by shape: objects must
+// have every expected key, arrays must be non-empty when the example is and
+// each element must match the first example element, and scalars must have
+// the same JSON type. An expected null matches anything.
+func Match(want, got any) []Mismatch {
+	var out []Mismatch
+	match("$", want, got, &out)
+	return out
+}
+
+func match(path string, want, got any, out *[]Mismatch) {
+	if want == nil {
+		return
+	}
+	if kind(want) != kind(got) {
+		*out = append(*out, Mismatch{Path: path, Message: fmt.Sprintf("expected %s, got %s", kind(want), kind(got))})
+		return
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g := got.(map[string]any)
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v, ok := g[k]
+			if !ok {
+				*out = append(*out, Mismatch{Path: path + "." + k, Message: "missing field"})
+				continue
+			}
+			match(path+"."+k, w[k], v, out)
+		}
+	case []any:
+		g := got.([]any)
+		if len(w) == 0 {
+			return
+		}
+		if len(g) == 0 {
+			*out = append(*out, Mismatch{Path: path, Message: "expected a non-empty array"})
+			return
+		}
+		for i, v := range g {
+			match(fmt.Sprintf("%s[%d]", path, i), w[0], v, out)
+		}
+	}
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mt)
+}
+
+func truncate(s string) string {
+	if len(s) > 200 {
+		return s[:200] + "..."
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package contract
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch_ComparesShape(t *testing.T) {
+	var want, got any
+	_ = json.Unmarshal([]byte(`{"id":"tsk_1","done":false,"tags":["a"],"owner":null,"meta":{"n":1}}`), &want)
+	_ = json.Unmarshal([]byte(`{"id":"tsk_9","done":true,"tags":["x","y"],"owner":{"id":"u"},"meta":{"n":2},"extra":1}`), &got)
+	if m := Match(want, got); len(m) != 0 {
+		t.Fatalf("expected different values and extra fields to match, got %v", m)
+	}
+
+	_ = json.Unmarshal([]byte(`{"id":7,"done":false,"tags":[1],"owner":null}`), &got)
+	m := Match(want, got)
+	paths := make([]string, len(m))
+	for i := range m {
+		paths[i] = m[i].Path
+	}
+	if strings.Join(paths, ",") != "$.id,$.meta,$.tags[0]" {
+		t.Fatalf("unexpected mismatches %v", m)
+	}
+}
+
+func TestVerify_ReportsBrokenInteractions(t *testing.T) {
+	tasks := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		tasks["tsk_1"] = "open"
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := tasks[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": r.PathValue("id"), "state": status})
+	})
+
+	c := &Contract{Consumer: "web", Interactions: []Interaction{{
+		Description:   "get a task",
+		ProviderState: "a task exists",
+		Request:       Request{Method: http.MethodGet, Path: "/tasks/{{id}}"},
+		Response: Response{
+			Status:  http.StatusOK,
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    json.RawMessage(`{"id":"tsk_x","status":"open"}`),
+		},
+	}}}
+	states := map[string]State{"a task exists": func(h http.Handler) (map[string]string, error) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/tasks", nil))
+		return map[string]string{"id": "tsk_1"}, nil
+	}}
+
+	m := Verify(mux, c, states)
+	if len(m) != 1 || m[0].Path != "$.status" || m[0].Message != "missing field" {
+		t.Fatalf("expected the renamed field to break the consumer, got %v", m)
+	}
+	if m := Verify(mux, c, nil); len(m) != 1 || !strings.Contains(m[0].Message, "unknown provider state") {
+		t.Fatalf("expected unknown states to fail, got %v", m)
+	}
+}
+
+func TestRecorder_WritesVerifiableContract(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	rec := NewRecorder("web", "api", nil)
+	client := &http.Client{Transport: rec}
+	rec.Next("create a task", "")
+	resp, err := client.Post(srv.URL+"/tasks", "application/json", strings.NewReader(`{"title":"Ship it"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != `{"title":"Ship it"}` {
+		t.Fatalf("the recorder must not consume the response, got %q", body)
+	}
+	_ = resp.Body.Close()
+
+	path := filepath.Join(t.TempDir(), "web.json")
+	if err := rec.Contract().WriteFile(path); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(c.Interactions) != 1 || c.Interactions[0].Description != "create a task" {
+		t.Fatalf("unexpected contract %+v", c)
+	}
+	if m := Verify(srv.Config.Handler, c, nil); len(m) != 0 {
+		t.Fatalf("expected the recorded contract to verify, got %v", m)
+	}
+}
@@ -0,0 +1,99 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Recorder is an http.RoundTripper for consumer tests: it passes requests to
+// the next transport (a test server or mock of the provider) and records each
+// exchange as an interaction, so the consumer's expectations can be committed
+// as a contract and verified against the provider.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu          sync.Mutex
+	contract    Contract
+	description string
+	state       string
+}
+
+// NewRecorder records the interactions of consumer with provider. A nil next
+// uses http.DefaultTransport.
+func NewRecorder(consumer, provider string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, contract: Contract{Consumer: consumer, Provider: provider}}
+}
+
+// Next describes the next request and the provider state it relies on.
+// Requests sent without a description are named after their method and path.
+func (r *Recorder) Next(description, state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.description, r.state = description, state
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request:  Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery},
+		Response: Response{Status: resp.StatusCode},
+	}
+	if json.Valid(reqBody) {
+		in.Request.Body = reqBody
+		in.Request.Headers = map[string]string{"Content-Type": "application/json"}
+	}
+	if json.Valid(respBody) {
+		in.Response.Body = respBody
+	}
+	// Only the media type is kept; other headers vary between runs
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		in.Response.Headers = map[string]string{"Content-Type": mediaType(ct)}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in.Description, in.ProviderState = r.description, r.state
+	if in.Description == "" {
+		in.Description = req.Method + " " + req.URL.Path
+	}
+	r.description, r.state = "", ""
+	r.contract.Interactions = append(r.contract.Interactions, in)
+	return resp, nil
+}
+
+// Contract returns a copy of the interactions recorded so far.
+func (r *Recorder) Contract() *Contract {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.contract
+	c.Interactions = append([]Interaction(nil), r.contract.Interactions...)
+	return &c
+}
@@ -0,0 +1,91 @@
+// Package corsreport remembers the origins recently refused by the CORS
+// policies, so a misconfigured frontend can be diagnosed from the admin API
+// instead of from browser consoles or packet captures. Origins stay out of
+// metric labels; this bounded log is where they go.
+package corsreport
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxOriginLength truncates hostile or broken Origin headers.
+const maxOriginLength = 256
+
+// Rejection summarizes the refusals of one origin by one policy.
+type Rejection struct {
+	Origin    string    `json:"origin"`
+	Policy    string    `json:"policy"`
+	Path      string    `json:"path"`      // path of the latest rejected request
+	Preflight bool      `json:"preflight"` // whether the latest rejected request was a preflight
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Log keeps the most recently rejected origins, evicting the least recently
+// seen when full.
+type Log struct {
+	max int
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[[2]string]*Rejection // by policy and origin
+}
+
+// New returns a log of at most max origins.
+func New(max int) *Log {
+	return &Log{max: max, now: time.Now, entries: make(map[[2]string]*Rejection)}
+}
+
+// Default is the process-wide log the CORS middleware records to.
+var Default = New(100)
+
+// Record notes that policy refused origin for a request to path. It reports
+// whether the origin is new to the log, so callers can log it once rather
+// than on every request.
+func (l *Log) Record(policy, origin, path string, preflight bool) bool {
+	if len(origin) > maxOriginLength {
+		origin = origin[:maxOriginLength]
+	}
+	now := l.now()
+	key := [2]string{policy, origin}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		e.Count++
+		e.Path, e.Preflight, e.LastSeen = path, preflight, now
+		return false
+	}
+	if len(l.entries) >= l.max {
+		var oldest [2]string
+		var oldestSeen time.Time
+		for k, e := range l.entries {
+			if oldestSeen.IsZero() || e.LastSeen.Before(oldestSeen) {
+				oldest, oldestSeen = k, e.LastSeen
+			}
+		}
+		delete(l.entries, oldest)
+	}
+	l.entries[key] = &Rejection{Origin: origin, Policy: policy, Path: path, Preflight: preflight, Count: 1, FirstSeen: now, LastSeen: now}
+	return true
+}
+
+// Recent returns the logged rejections, most recently seen first.
+func (l *Log) Recent() []Rejection {
+	l.mu.Lock()
+	out := make([]Rejection, 0, len(l.entries))
+	for _, e := range l.entries {
+		out = append(out, *e)
+	}
+	l.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastSeen.Equal(out[j].LastSeen) {
+			return out[i].LastSeen.After(out[j].LastSeen)
+		}
+		return out[i].Origin < out[j].Origin
+	})
+	return out
+}
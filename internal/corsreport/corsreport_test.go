@@ -0,0 +1,29 @@
+package corsreport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLog_CountsAndEvictsLeastRecentlySeen(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	l := New(2)
+	l.now = func() time.Time { return now }
+
+	tick := func(policy, origin string) bool {
+		now = now.Add(time.Second)
+		return l.Record(policy, origin, "/api/v1/tasks", false)
+	}
+	if !tick("default", "https://a.example.com") || !tick("default", "https://b.example.com") {
+		t.Fatalf("expected new origins to be reported as new")
+	}
+	if tick("default", "https://a.example.com") {
+		t.Fatalf("expected a known origin not to be reported again")
+	}
+	tick("/admin", "https://c.example.com") // evicts b, the least recently seen
+
+	got := l.Recent()
+	if len(got) != 2 || got[0].Origin != "https://c.example.com" || got[1].Origin != "https://a.example.com" || got[1].Count != 2 {
+		t.Fatalf("unexpected rejections %+v", got)
+	}
+}
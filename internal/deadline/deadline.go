@@ -0,0 +1,63 @@
+// Package deadline carries a caller's time budget across service hops. An
+// upstream states how long it will wait in the X-Request-Timeout header; the
+// server derives its request deadline from it and forwards what is left on
+// outbound calls, so downstream work stops when nobody is waiting for it.
+package deadline
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header is the request header carrying the remaining budget.
+const Header = "X-Request-Timeout"
+
+// Parse reads a budget in milliseconds ("1500") or as a Go duration
+// ("1.5s"). Negative or malformed values are rejected; zero means the budget
+// is already spent.
+func Parse(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if ms < 0 || ms > int64(time.Duration(1<<63-1)/time.Millisecond) {
+			return 0, false
+		}
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// Format renders d as whole milliseconds, rounding up so a nearly spent
+// budget is not forwarded as zero.
+func Format(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64((d+time.Millisecond-1)/time.Millisecond), 10)
+}
+
+// Remaining returns the time left until ctx's deadline.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(dl), true
+}
+
+// Budget returns the request timeout to apply: the budget in header value v
+// capped at max, or max when v is absent or invalid.
+func Budget(v string, max time.Duration) time.Duration {
+	if d, ok := Parse(v); ok && d < max {
+		return d
+	}
+	return max
+}
@@ -0,0 +1,48 @@
+package deadline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"1500", 1500 * time.Millisecond, true},
+		{"2s", 2 * time.Second, true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := Parse(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("Parse(%q) = %v, %v; want %v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestBudgetIsCappedByServer(t *testing.T) {
+	if got := Budget("500", time.Second); got != 500*time.Millisecond {
+		t.Fatalf("expected caller budget, got %v", got)
+	}
+	if got := Budget("60000", time.Second); got != time.Second {
+		t.Fatalf("expected server cap, got %v", got)
+	}
+	if got := Budget("bogus", time.Second); got != time.Second {
+		t.Fatalf("expected server timeout for invalid budget, got %v", got)
+	}
+}
+
+func TestFormatRoundsUp(t *testing.T) {
+	if got := Format(1500*time.Microsecond + 1); got != "2" {
+		t.Fatalf("expected 2, got %s", got)
+	}
+	if got := Format(-time.Second); got != "0" {
+		t.Fatalf("expected 0, got %s", got)
+	}
+}
@@ -0,0 +1,278 @@
+// Package degradation degrades features while a dependency they need is
+// unavailable. A controller runs the registered dependency checks on an
+// interval; a feature is degraded while any of its configured dependencies
+// fails its check, and recovers once they all pass again. Routes declare the
+// feature they belong to: while it is degraded their writes get 503
+// dependency_unavailable and their reads are served from the last successful
+// responses, or get the same 503 when none was cached.
+package degradation
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/preflight"
+)
+
+// Options configure a Controller.
+type Options struct {
+	Interval     time.Duration       // time between check runs; 0 disables them, so nothing degrades
+	Timeout      time.Duration       // bound of each check, default 5s
+	Features     map[string][]string // feature to the dependencies it needs
+	CacheEntries int                 // successful reads kept per feature for outages, default 1000
+}
+
+// Dependency is the state of one registered dependency.
+type Dependency struct {
+	Name  string    `json:"name"`
+	Up    bool      `json:"up"`
+	Since time.Time `json:"since"`           // when it last changed, or was registered
+	Error string    `json:"error,omitempty"` // why the last check failed
+}
+
+// Feature is the state of one configured feature.
+type Feature struct {
+	Name         string   `json:"name"`
+	Degraded     bool     `json:"degraded"`
+	Dependencies []string `json:"dependencies"`
+	Unavailable  []string `json:"unavailable,omitempty"` // dependencies that are down
+}
+
+// Status is the state of every dependency and feature, ordered by name.
+type Status struct {
+	Dependencies []Dependency `json:"dependencies"`
+	Features     []Feature    `json:"features"`
+}
+
+// Controller tracks dependency health and the features it degrades.
+type Controller struct {
+	opts Options
+	now  func() time.Time
+
+	mu     sync.Mutex
+	checks map[string]preflight.Check
+	deps   map[string]*Dependency
+	caches map[string]*readCache // per feature
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Default is the process-wide controller. main replaces it with one
+// configured from the environment and registers the dependency checks before
+// building the router, whose feature routes it guards.
+var Default = New(Options{})
+
+// New returns a controller with no dependencies.
+func New(opts Options) *Controller {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.CacheEntries <= 0 {
+		opts.CacheEntries = 1000
+	}
+	c := &Controller{
+		opts:   opts,
+		now:    time.Now,
+		checks: make(map[string]preflight.Check),
+		deps:   make(map[string]*Dependency),
+		caches: make(map[string]*readCache),
+	}
+	for feature := range opts.Features {
+		metrics.SetFeatureDegraded(feature, false)
+	}
+	return c
+}
+
+// Register adds a dependency check, replacing one with the same name. The
+// dependency counts as up until a check fails.
+func (c *Controller) Register(check preflight.Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[check.Name] = check
+	if _, ok := c.deps[check.Name]; !ok {
+		c.deps[check.Name] = &Dependency{Name: check.Name, Up: true, Since: c.now().UTC()}
+		metrics.SetDependencyUp(check.Name, true)
+	}
+}
+
+// Unregistered returns the dependencies features are configured with that
+// have no check, ordered by name. They never degrade a feature.
+func (c *Controller) Unregistered() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for _, deps := range c.opts.Features {
+		for _, d := range deps {
+			if _, ok := c.checks[d]; !ok && !slices.Contains(out, d) {
+				out = append(out, d)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// CheckOnce runs every check once, logging dependencies and features that
+// change state and recording them in metrics.
+func (c *Controller) CheckOnce(ctx context.Context, logger *slog.Logger) {
+	c.mu.Lock()
+	checks := make([]preflight.Check, 0, len(c.checks))
+	for _, check := range c.checks {
+		checks = append(checks, check)
+	}
+	c.mu.Unlock()
+	results, _ := preflight.Run(ctx, checks, c.opts.Timeout)
+	if ctx.Err() != nil {
+		return // a cancelled run says nothing about the dependencies
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	before := c.degradedLocked()
+	now := c.now().UTC()
+	for _, res := range results {
+		dep, ok := c.deps[res.Name]
+		if !ok {
+			continue
+		}
+		up := res.Err == nil
+		dep.Error = ""
+		if !up {
+			dep.Error = res.Err.Error()
+		}
+		if dep.Up == up {
+			continue
+		}
+		dep.Up, dep.Since = up, now
+		metrics.SetDependencyUp(dep.Name, up)
+		if up {
+			logger.Info("dependency recovered", slog.String("dependency", dep.Name))
+		} else {
+			logger.Warn("dependency unavailable", slog.String("dependency", dep.Name), slog.String("error", dep.Error))
+		}
+	}
+	after := c.degradedLocked()
+	for feature := range c.opts.Features {
+		if before[feature] == after[feature] {
+			continue
+		}
+		metrics.SetFeatureDegraded(feature, after[feature])
+		if after[feature] {
+			logger.Warn("feature degraded", slog.String("feature", feature), slog.Any("unavailable", c.unavailableLocked(feature)))
+		} else {
+			logger.Info("feature recovered", slog.String("feature", feature))
+		}
+	}
+}
+
+// Start runs the checks right away and then every Interval in the background
+// until Stop. It is a no-op when the interval is zero or the controller is
+// already running.
+func (c *Controller) Start(logger *slog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.Interval <= 0 || c.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel, c.done = cancel, make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(c.opts.Interval)
+		defer ticker.Stop()
+		for {
+			c.CheckOnce(ctx, logger)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}(c.done)
+}
+
+// Stop cancels a check run in progress and waits for the background loop to
+// exit.
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel, c.done = nil, nil
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Unavailable returns the dependencies of feature that are down; none means
+// the feature is not degraded.
+func (c *Controller) Unavailable(feature string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unavailableLocked(feature)
+}
+
+// Status returns the state of every dependency and configured feature.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := Status{Dependencies: make([]Dependency, 0, len(c.deps)), Features: make([]Feature, 0, len(c.opts.Features))}
+	for _, d := range c.deps {
+		st.Dependencies = append(st.Dependencies, *d)
+	}
+	for name, deps := range c.opts.Features {
+		down := c.unavailableLocked(name)
+		st.Features = append(st.Features, Feature{Name: name, Degraded: len(down) > 0, Dependencies: slices.Clone(deps), Unavailable: down})
+	}
+	slices.SortFunc(st.Dependencies, func(a, b Dependency) int { return strings.Compare(a.Name, b.Name) })
+	slices.SortFunc(st.Features, func(a, b Feature) int { return strings.Compare(a.Name, b.Name) })
+	return st
+}
+
+func (c *Controller) unavailableLocked(feature string) []string {
+	var down []string
+	for _, name := range c.opts.Features[feature] {
+		if d, ok := c.deps[name]; ok && !d.Up {
+			down = append(down, name)
+		}
+	}
+	return down
+}
+
+func (c *Controller) degradedLocked() map[string]bool {
+	out := make(map[string]bool, len(c.opts.Features))
+	for feature := range c.opts.Features {
+		out[feature] = len(c.unavailableLocked(feature)) > 0
+	}
+	return out
+}
+
+// configured reports whether feature has dependencies, i.e. can degrade.
+func (c *Controller) configured(feature string) bool {
+	return len(c.opts.Features[feature]) > 0
+}
+
+// cache returns the read cache of feature, creating it on first use.
+func (c *Controller) cache(feature string) *readCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rc, ok := c.caches[feature]
+	if !ok {
+		rc = newReadCache(c.opts.CacheEntries)
+		c.caches[feature] = rc
+	}
+	return rc
+}
+
+// retryAfter is the Retry-After sent while a feature is degraded: the time
+// until the next check, in whole seconds.
+func (c *Controller) retryAfter() string {
+	return strconv.Itoa(max(1, int((c.opts.Interval+time.Second-1)/time.Second)))
+}
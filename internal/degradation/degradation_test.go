@@ -0,0 +1,126 @@
+package degradation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/preflight"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+func TestController_DegradesAndRecovers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var down atomic.Bool
+	c := New(Options{Features: map[string][]string{"users": {"database"}, "files": {"storage", "cdn"}}})
+	c.Register(preflight.Check{Name: "database", Run: func(context.Context) error {
+		if down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+	c.Register(preflight.Check{Name: "storage", Run: func(context.Context) error { return nil }})
+	if got := c.Unregistered(); len(got) != 1 || got[0] != "cdn" {
+		t.Fatalf("Unregistered = %v, want [cdn]", got)
+	}
+
+	writes := 0
+	h := c.Guard("users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writes++
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-ID", "req-1")
+		_, _ = io.WriteString(w, `{"id":"usr_001"}`)
+	}))
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "/users/usr_001"); rr.Code != http.StatusOK {
+		t.Fatalf("healthy read: %d", rr.Code)
+	}
+
+	down.Store(true)
+	c.CheckOnce(context.Background(), logger)
+	if got := c.Unavailable("users"); len(got) != 1 || got[0] != "database" {
+		t.Fatalf("Unavailable(users) = %v", got)
+	}
+	if got := c.Unavailable("files"); len(got) != 0 {
+		t.Fatalf("files degraded by an unrelated dependency: %v", got)
+	}
+
+	rr := serve(http.MethodGet, "/users/usr_001")
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"id":"usr_001"}` || rr.Header().Get("X-Degraded") != "database" || rr.Header().Get("Age") == "" {
+		t.Fatalf("expected the cached read, got %d %v %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	if rr.Header().Get("X-Request-ID") != "" {
+		t.Fatal("cached read repeated a header of the request that produced it")
+	}
+	if rr := serve(http.MethodGet, "/users/usr_002"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for an uncached read, got %d", rr.Code)
+	}
+	rr = serve(http.MethodPost, "/users")
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" || writes != 0 {
+		t.Fatalf("expected the write refused, got %d (%d writes)", rr.Code, writes)
+	}
+
+	st := c.Status()
+	if len(st.Features) != 2 || st.Features[1].Name != "users" || !st.Features[1].Degraded || st.Dependencies[0].Error == "" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	down.Store(false)
+	c.CheckOnce(context.Background(), logger)
+	if rr := serve(http.MethodPost, "/users"); rr.Code != http.StatusCreated || writes != 1 {
+		t.Fatalf("expected the write served after recovery, got %d", rr.Code)
+	}
+
+	// The write forgot the cached reads
+	down.Store(true)
+	c.CheckOnce(context.Background(), logger)
+	if rr := serve(http.MethodGet, "/users/usr_001"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after a write cleared the cache, got %d", rr.Code)
+	}
+}
+
+func TestGuard_UnconfiguredFeatureIsUntouched(t *testing.T) {
+	c := New(Options{})
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	if h := c.Guard("users", next); h == nil || c.configured("users") {
+		t.Fatal("expected next to be served as it is")
+	}
+}
+
+func TestGuard_DoesNotKeepNoStoreReads(t *testing.T) {
+	var down atomic.Bool
+	c := New(Options{Features: map[string][]string{"users": {"database"}}})
+	c.Register(preflight.Check{Name: "database", Run: func(context.Context) error {
+		if down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+	h := c.Guard("users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.NoStore(w)
+		_, _ = io.WriteString(w, `{"token":"secret"}`)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/usr_001/token", nil))
+	down.Store(true)
+	c.CheckOnce(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/usr_001/token", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a no-store read not to be served from the outage cache, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,212 @@
+package degradation
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// maxCachedBody bounds the body of a read kept for outages; larger ones are
+// not cached.
+const maxCachedBody = 1 << 20
+
+// cachedHeaders are the headers of a read that describe its body and are sent
+// again with it; the others belong to the request that produced it.
+var cachedHeaders = []string{"Content-Type", "Content-Language", "ETag", "Last-Modified"}
+
+// Guard serves next as part of feature. While the feature is healthy,
+// successful GET responses are remembered per caller and URL, unless marked
+// with response.NoStore, and successful writes forget them, since they may
+// have changed what the reads return.
+// While it is degraded, writes get 503 dependency_unavailable and reads get
+// the remembered response with Age and X-Degraded headers, or the same 503.
+// Features without configured dependencies are served as they are.
+func (c *Controller) Guard(feature string, next http.Handler) http.Handler {
+	if !c.configured(feature) {
+		return next
+	}
+	rc := c.cache(feature)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if down := c.Unavailable(feature); len(down) > 0 {
+			if read {
+				if e, ok := rc.get(cacheKey(r)); ok {
+					metrics.ObserveDegradedResponse(feature, "cached")
+					e.write(w, r, c.now(), down)
+					return
+				}
+			}
+			metrics.ObserveDegradedResponse(feature, "rejected")
+			w.Header().Set("Retry-After", c.retryAfter())
+			w.Header().Set("X-Degraded", strings.Join(down, ", "))
+			response.Error(w, r, http.StatusServiceUnavailable, "dependency_unavailable",
+				"Temporarily unavailable while a dependency is down: "+strings.Join(down, ", "), nil)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet:
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status() == http.StatusOK && !rec.overflow && response.Storable(rec.Header()) {
+				rc.put(cacheKey(r), &cachedRead{
+					header: representation(rec.Header()),
+					body:   bytes.Clone(rec.body.Bytes()),
+					stored: c.now(),
+				})
+			}
+		case read || r.Method == http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			rec := &recorder{ResponseWriter: w, discard: true}
+			next.ServeHTTP(rec, r)
+			if s := rec.status(); s >= 200 && s < 300 {
+				rc.clear()
+			}
+		}
+	})
+}
+
+// cacheKey keys a read by the caller and its URL, so one caller is never
+// served what another was allowed to see.
+func cacheKey(r *http.Request) string {
+	p, _ := requestctx.Principal(r.Context())
+	return p.Method + ":" + p.UserID + " " + r.URL.RequestURI()
+}
+
+func representation(h http.Header) http.Header {
+	out := make(http.Header, len(cachedHeaders))
+	for _, k := range cachedHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// cachedRead is a successful read remembered for outages.
+type cachedRead struct {
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+func (e *cachedRead) write(w http.ResponseWriter, r *http.Request, now time.Time, down []string) {
+	h := w.Header()
+	for k, v := range e.header {
+		h[k] = v
+	}
+	h.Set("Age", strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	h.Set("X-Degraded", strings.Join(down, ", "))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	response.Bytes(w, r, http.StatusOK, e.body)
+}
+
+// recorder passes a response through while keeping its status and, unless
+// discard is set, up to maxCachedBody of its body.
+type recorder struct {
+	http.ResponseWriter
+	code     int
+	discard  bool
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	if !rec.discard && !rec.overflow {
+		if rec.body.Len()+len(b) > maxCachedBody {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
+
+func (rec *recorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
+
+// readCache is an LRU of cached reads bounded by their number.
+type readCache struct {
+	limit int
+
+	mu    sync.Mutex
+	order *list.List // front is most recently stored
+	items map[string]*list.Element
+}
+
+type readEntry struct {
+	key  string
+	read *cachedRead
+}
+
+func newReadCache(limit int) *readCache {
+	return &readCache{limit: limit, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (rc *readCache) get(key string) (*cachedRead, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	el, ok := rc.items[key]
+	if !ok {
+		return nil, false
+	}
+	return el.Value.(*readEntry).read, true
+}
+
+func (rc *readCache) put(key string, read *cachedRead) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[key]; ok {
+		el.Value.(*readEntry).read = read
+		rc.order.MoveToFront(el)
+		return
+	}
+	rc.items[key] = rc.order.PushFront(&readEntry{key: key, read: read})
+	for rc.order.Len() > rc.limit {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.items, oldest.Value.(*readEntry).key)
+	}
+}
+
+func (rc *readCache) clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.order.Init()
+	clear(rc.items)
+}
@@ -169,7 +169,7 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/github_com_mikko-kohtala_go-api_internal_services.User"
+                                "$ref": "#/definitions/internal_handlers.UserResponse"
                             }
                         }
                     },
@@ -209,7 +209,7 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/github_com_mikko-kohtala_go-api_internal_services.User"
+                            "$ref": "#/definitions/internal_handlers.UserResponse"
                         }
                     },
                     "400": {
@@ -259,7 +259,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_mikko-kohtala_go-api_internal_services.User"
+                            "$ref": "#/definitions/internal_handlers.UserResponse"
                         }
                     },
                     "404": {
@@ -312,7 +312,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_mikko-kohtala_go-api_internal_services.User"
+                            "$ref": "#/definitions/internal_handlers.UserResponse"
                         }
                     },
                     "400": {
@@ -396,7 +396,7 @@ const docTemplate = `{
         },
         "/readyz": {
             "get": {
-                "description": "Indicates whether the service is ready to accept traffic.",
+                "description": "Indicates whether the service is ready to accept traffic; 503 while it drains before shutting down.",
                 "tags": [
                     "health"
                 ],
@@ -410,6 +410,15 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
@@ -494,26 +503,6 @@ const docTemplate = `{
                 }
             }
         },
-        "github_com_mikko-kohtala_go-api_internal_services.User": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "email": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "role": {
-                    "type": "string"
-                }
-            }
-        },
         "internal_handlers.CreateUserRequest": {
             "type": "object",
             "required": [
@@ -604,6 +593,31 @@ const docTemplate = `{
                 }
             }
         },
+        "internal_handlers.UserResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "role_display": {
+                    "description": "RoleDisplay is the role's name in the request's language.",
+                    "type": "string",
+                    "example": "Administrator"
+                }
+            }
+        },
         "time.Duration": {
             "type": "integer",
             "format": "int64",
@@ -0,0 +1,15 @@
+// Package examples holds the golden responses the API documentation shows as
+// examples. Each file is named after an operation's route name and status,
+// e.g. users.get.200.json, and holds the body the handler returned for it.
+// TestGoldenExamples in internal/httpserver replays the requests and fails
+// when a handler's output drifts from its file; rewrite them with
+//
+//	go test ./internal/httpserver -run TestGoldenExamples -update
+package examples
+
+import "embed"
+
+// FS holds the golden response files.
+//
+//go:embed *.json
+var FS embed.FS
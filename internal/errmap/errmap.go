@@ -0,0 +1,76 @@
+// Package errmap maps service errors to HTTP statuses and stable error codes,
+// so handlers can answer with response.FromError instead of errors.Is ladders.
+package errmap
+
+import (
+	"errors"
+	"sync"
+)
+
+// Mapping is how an error is presented to API clients.
+type Mapping struct {
+	Status  int
+	Code    string
+	Message string // shown to clients; the error text when empty
+}
+
+type entry struct {
+	target error
+	Mapping
+}
+
+var (
+	mu      sync.RWMutex
+	entries []entry
+)
+
+// Register maps errors matching target (per errors.Is) to status and code.
+// message is shown to clients; when empty the error's own text is used.
+// Registering the same target again replaces its mapping.
+func Register(target error, status int, code, message string) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := entry{target: target, Mapping: Mapping{Status: status, Code: code, Message: message}}
+	for i, e := range entries {
+		if e.target == target {
+			entries[i] = m
+			return
+		}
+	}
+	entries = append(entries, m)
+}
+
+// Lookup returns the mapping of the first registered target err matches.
+func Lookup(err error) (Mapping, bool) {
+	if err == nil {
+		return Mapping{}, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, e := range entries {
+		if errors.Is(err, e.target) {
+			m := e.Mapping
+			if m.Message == "" {
+				m.Message = err.Error()
+			}
+			return m, true
+		}
+	}
+	return Mapping{}, false
+}
+
+// FieldError is implemented by errors that carry field-level messages,
+// returned to clients alongside the mapped code.
+type FieldError interface {
+	error
+	Fields() map[string]string
+}
+
+// Fields returns the field messages of the first FieldError in err's chain.
+func Fields(err error) map[string]string {
+	var fe FieldError
+	if errors.As(err, &fe) {
+		return fe.Fields()
+	}
+	return nil
+}
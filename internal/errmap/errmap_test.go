@@ -0,0 +1,44 @@
+package errmap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type conflictError struct{ field string }
+
+func (e conflictError) Error() string { return e.field + " is taken" }
+func (e conflictError) Fields() map[string]string {
+	return map[string]string{e.field: "already exists"}
+}
+
+func TestLookup(t *testing.T) {
+	errGone := errors.New("gone")
+	errTaken := errors.New("taken")
+	Register(errGone, http.StatusNotFound, "not_found", "Thing not found")
+	Register(errTaken, http.StatusConflict, "conflict", "")
+
+	m, ok := Lookup(fmt.Errorf("lookup: %w", errGone))
+	if !ok || m.Status != http.StatusNotFound || m.Code != "not_found" || m.Message != "Thing not found" {
+		t.Fatalf("unexpected mapping: %+v %v", m, ok)
+	}
+
+	wrapped := fmt.Errorf("save: %w", errors.Join(errTaken, conflictError{"email"}))
+	m, ok = Lookup(wrapped)
+	if !ok || m.Status != http.StatusConflict || m.Message != wrapped.Error() {
+		t.Fatalf("expected error text as message, got %+v", m)
+	}
+	if f := Fields(wrapped); f["email"] != "already exists" {
+		t.Fatalf("unexpected fields: %v", f)
+	}
+
+	Register(errGone, http.StatusGone, "gone", "")
+	if m, _ := Lookup(errGone); m.Status != http.StatusGone {
+		t.Fatalf("re-registering should replace the mapping, got %+v", m)
+	}
+	if _, ok := Lookup(errors.New("other")); ok {
+		t.Fatal("unregistered errors must not map")
+	}
+}
@@ -0,0 +1,154 @@
+// Package errors provides APIError, an error carrying the HTTP status, code
+// and message clients see plus the stack where it was created, and helpers
+// to classify failures as retryable or terminal for outbound calls and
+// background workers. Import it under an alias (apierrors) next to the
+// standard library package.
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strconv"
+)
+
+// maxFrames bounds the captured stack depth.
+const maxFrames = 32
+
+// APIError is an error with its client-facing representation.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  map[string]string
+	Err     error // cause, if any
+	stack   []uintptr
+}
+
+// New returns an APIError with the caller's stack.
+func New(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message, stack: callers(0)}
+}
+
+// NewDepth is New for helpers that create errors on behalf of their caller:
+// the stack leaves out depth frames above NewDepth's caller, so NewDepth(1,
+// ...) starts at whoever called the helper.
+func NewDepth(depth, status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message, stack: callers(depth)}
+}
+
+// Wrap returns an APIError caused by err, with the caller's stack.
+func Wrap(err error, status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message, Err: err, stack: callers(0)}
+}
+
+// WrapDepth is Wrap for helpers, skipping depth frames like NewDepth.
+func WrapDepth(depth int, err error, status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message, Err: err, stack: callers(depth)}
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// StackTrace returns the frames captured by New or Wrap as "function file:line".
+func (e *APIError) StackTrace() []string {
+	frames := runtime.CallersFrames(e.stack)
+	out := make([]string, 0, len(e.stack))
+	for {
+		f, more := frames.Next()
+		out = append(out, f.Function+" "+f.File+":"+strconv.Itoa(f.Line))
+		if !more {
+			return out
+		}
+	}
+}
+
+func callers(depth int) []uintptr {
+	pcs := make([]uintptr, maxFrames)
+	n := runtime.Callers(3+depth, pcs) // skip Callers, callers and New/Wrap
+	return pcs[:n]
+}
+
+// Stack returns the stack of the first APIError in err's chain, or nil.
+func Stack(err error) []string {
+	var e *APIError
+	if stderrors.As(err, &e) && len(e.stack) > 0 {
+		return e.StackTrace()
+	}
+	return nil
+}
+
+// classified marks an error as retryable or terminal.
+type classified struct {
+	err       error
+	retryable bool
+}
+
+func (c *classified) Error() string { return c.err.Error() }
+func (c *classified) Unwrap() error { return c.err }
+
+// Retryable marks err as a transient failure worth retrying.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classified{err: err, retryable: true}
+}
+
+// Terminal marks err as a failure that will not succeed on retry.
+func Terminal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classified{err: err}
+}
+
+// Retryablef is Retryable(fmt.Errorf(format, args...)).
+func Retryablef(format string, args ...any) error {
+	return Retryable(fmt.Errorf(format, args...))
+}
+
+// Terminalf is Terminal(fmt.Errorf(format, args...)).
+func Terminalf(format string, args ...any) error {
+	return Terminal(fmt.Errorf(format, args...))
+}
+
+// IsRetryable reports whether err is worth retrying: it was marked Retryable,
+// or it is a deadline or network error and was not marked Terminal. The
+// outermost mark wins.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var c *classified
+	if stderrors.As(err, &c) {
+		return c.retryable
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return stderrors.As(err, &ne)
+}
+
+// IsTerminal reports whether err was marked Terminal or is a cancellation,
+// so retrying is pointless. Unclassified errors are neither retryable nor
+// terminal; callers choose their own default.
+func IsTerminal(err error) bool {
+	if err == nil {
+		return false
+	}
+	var c *classified
+	if stderrors.As(err, &c) {
+		return !c.retryable
+	}
+	return stderrors.Is(err, context.Canceled)
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func findWidget() error {
+	return New(http.StatusNotFound, "not_found", "Widget not found")
+}
+
+func TestNew_CapturesCallerStack(t *testing.T) {
+	err := fmt.Errorf("handler: %w", findWidget())
+	stack := Stack(err)
+	if len(stack) == 0 || !strings.Contains(stack[0], "findWidget") {
+		t.Fatalf("expected stack to start at findWidget, got %v", stack)
+	}
+	var apiErr *APIError
+	if !stderrors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Fatalf("expected APIError in chain, got %v", err)
+	}
+	if Stack(stderrors.New("plain")) != nil {
+		t.Fatal("plain errors have no stack")
+	}
+}
+
+func TestWrap_KeepsCause(t *testing.T) {
+	cause := stderrors.New("disk full")
+	err := Wrap(cause, http.StatusInternalServerError, "internal_error", "Failed to save")
+	if !stderrors.Is(err, cause) || err.Error() != "Failed to save: disk full" {
+		t.Fatalf("unexpected wrap: %v", err)
+	}
+}
+
+func TestClassification(t *testing.T) {
+	cases := []struct {
+		err                 error
+		retryable, terminal bool
+	}{
+		{Retryablef("upstream %d", 503), true, false},
+		{Terminalf("upstream %d", 404), false, true},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), true, false},
+		{context.Canceled, false, true},
+		{stderrors.New("unknown"), false, false},
+		{Terminal(context.DeadlineExceeded), false, true}, // explicit mark wins
+		{nil, false, false},
+	}
+	for _, tc := range cases {
+		if IsRetryable(tc.err) != tc.retryable || IsTerminal(tc.err) != tc.terminal {
+			t.Errorf("%v: retryable=%v terminal=%v, want %v %v", tc.err, IsRetryable(tc.err), IsTerminal(tc.err), tc.retryable, tc.terminal)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package events is a minimal in-process publish/subscribe bus. Delivery is
+// synchronous, so subscribers should be quick and hand slow work elsewhere.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Handler receives the payloads published on a topic.
+type Handler func(ctx context.Context, payload any)
+
+// Bus routes published payloads to the handlers subscribed to their topic.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[string]map[int]Handler
+}
+
+// NewBus creates a bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[string]map[int]Handler)}
+}
+
+// Subscribe registers h for topic and returns a function that removes it.
+func (b *Bus) Subscribe(topic string, h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[int]Handler)
+	}
+	b.subs[topic][id] = h
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[topic], id)
+	}
+}
+
+// Publish delivers payload to every subscriber of topic. A panicking
+// subscriber is logged and does not affect the others or the publisher.
+func (b *Bus) Publish(ctx context.Context, topic string, payload any) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.subs[topic]))
+	for _, h := range b.subs[topic] {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		deliver(ctx, topic, h, payload)
+	}
+}
+
+func deliver(ctx context.Context, topic string, h Handler, payload any) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			pkglogger.FromContext(ctx).Error("event subscriber panicked", slog.String("topic", topic), slog.Any("panic", rec))
+		}
+	}()
+	h(ctx, payload)
+}
@@ -0,0 +1,22 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBus_PublishAndUnsubscribe(t *testing.T) {
+	b := NewBus()
+	var got []any
+	unsubscribe := b.Subscribe("t", func(_ context.Context, p any) { got = append(got, p) })
+	b.Subscribe("t", func(context.Context, any) { panic("boom") })
+	b.Subscribe("other", func(context.Context, any) { t.Fatal("wrong topic delivered") })
+
+	b.Publish(context.Background(), "t", 1)
+	unsubscribe()
+	b.Publish(context.Background(), "t", 2)
+
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("unexpected deliveries: %v", got)
+	}
+}
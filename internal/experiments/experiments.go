@@ -0,0 +1,142 @@
+// Package experiments assigns units (API keys or browser cookies) to A/B
+// experiment variants deterministically, so the same unit always sees the
+// same variant without any stored state.
+package experiments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExposureTopic is the event bus topic Exposure events are published on.
+const ExposureTopic = "experiments.exposure"
+
+// Variant is one arm of an experiment; Weight is its relative share of units.
+type Variant struct {
+	Name   string
+	Weight int
+}
+
+// Experiment is a named set of weighted variants.
+type Experiment struct {
+	Name     string
+	Variants []Variant
+	total    int
+}
+
+// Parse reads "name=variant:weight,variant:weight;name=..." into experiments.
+func Parse(spec string) ([]Experiment, error) {
+	var out []Experiment
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, arms, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, ",: ") {
+			return nil, fmt.Errorf("invalid entry %q: expected name=variant:weight,...", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate experiment %q", name)
+		}
+		seen[name] = true
+		e := Experiment{Name: name}
+		for _, arm := range strings.Split(arms, ",") {
+			vname, w, ok := strings.Cut(strings.TrimSpace(arm), ":")
+			weight, err := strconv.Atoi(w)
+			if !ok || vname == "" || err != nil || weight < 0 {
+				return nil, fmt.Errorf("experiment %q: invalid variant %q", name, arm)
+			}
+			e.Variants = append(e.Variants, Variant{Name: vname, Weight: weight})
+			e.total += weight
+		}
+		if len(e.Variants) < 2 || e.total == 0 {
+			return nil, fmt.Errorf("experiment %q needs at least two variants and a positive total weight", name)
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Assign returns the variant for unit. The bucket is a hash of the experiment
+// name and unit, so units are shuffled independently per experiment.
+func (e Experiment) Assign(unit string) string {
+	sum := sha256.Sum256([]byte(e.Name + "\x00" + unit))
+	point := int(binary.BigEndian.Uint64(sum[:8]) % uint64(e.total))
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v.Name
+		}
+		point -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1].Name
+}
+
+// Assignment is the variant a request was bucketed into.
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+// Assignments are a request's assignments, ordered by experiment.
+type Assignments []Assignment
+
+// Variant returns the assigned variant of experiment, or "" when the request
+// is not part of it.
+func (a Assignments) Variant(experiment string) string {
+	for _, x := range a {
+		if x.Experiment == experiment {
+			return x.Variant
+		}
+	}
+	return ""
+}
+
+// String formats the assignments as "experiment=variant;experiment=variant".
+func (a Assignments) String() string {
+	parts := make([]string, len(a))
+	for i, x := range a {
+		parts[i] = x.Experiment + "=" + x.Variant
+	}
+	return strings.Join(parts, ";")
+}
+
+// AssignAll buckets unit into every experiment.
+func AssignAll(exps []Experiment, unit string) Assignments {
+	out := make(Assignments, len(exps))
+	for i, e := range exps {
+		out[i] = Assignment{Experiment: e.Name, Variant: e.Assign(unit)}
+	}
+	return out
+}
+
+// Exposure records that a unit was served a variant, for analysis.
+type Exposure struct {
+	Experiment string    `json:"experiment"`
+	Variant    string    `json:"variant"`
+	Unit       string    `json:"unit"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+type ctxKey struct{}
+
+// IntoContext attaches a request's assignments.
+func IntoContext(ctx context.Context, a Assignments) context.Context {
+	return context.WithValue(ctx, ctxKey{}, a)
+}
+
+// FromContext returns the assignments attached to ctx, if any.
+func FromContext(ctx context.Context) Assignments {
+	a, _ := ctx.Value(ctxKey{}).(Assignments)
+	return a
+}
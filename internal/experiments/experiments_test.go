@@ -0,0 +1,43 @@
+package experiments
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	exps, err := Parse("search=a:90,b:10; checkout=control:1,green:1")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(exps) != 2 || exps[0].Name != "checkout" || exps[1].Variants[1] != (Variant{Name: "b", Weight: 10}) {
+		t.Fatalf("unexpected experiments: %+v", exps)
+	}
+	for _, bad := range []string{"x", "x=a:1", "x=a:1,b", "x=a:0,b:0", "x=a:1,b:1;x=a:1,b:1", "x=a:-1,b:2"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestAssign_DeterministicAndWeighted(t *testing.T) {
+	exps, _ := Parse("search=a:90,b:10")
+	e := exps[0]
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		unit := fmt.Sprintf("unit-%d", i)
+		v := e.Assign(unit)
+		if e.Assign(unit) != v {
+			t.Fatalf("assignment for %s is not deterministic", unit)
+		}
+		counts[v]++
+	}
+	if counts["b"] < 800 || counts["b"] > 1200 {
+		t.Fatalf("expected about 10%% in b, got %v", counts)
+	}
+
+	a := AssignAll(exps, "unit-1")
+	if a.Variant("search") == "" || a.Variant("other") != "" || a.String() != "search="+a.Variant("search") {
+		t.Fatalf("unexpected assignments %v", a)
+	}
+}
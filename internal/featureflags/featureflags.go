@@ -0,0 +1,202 @@
+// Package featureflags evaluates feature flags through pluggable providers.
+// The Provider interface follows the OpenFeature FeatureProvider contract
+// (typed evaluations returning a value plus variant, reason and error code),
+// so vendor backends such as LaunchDarkly, Flagsmith or Unleash can be
+// plugged in with a thin adapter registered under a name selected by config.
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Reason explains why a value was returned (OpenFeature resolution reasons).
+type Reason string
+
+const (
+	ReasonStatic         Reason = "STATIC"
+	ReasonDefault        Reason = "DEFAULT"
+	ReasonTargetingMatch Reason = "TARGETING_MATCH"
+	ReasonDisabled       Reason = "DISABLED"
+	ReasonError          Reason = "ERROR"
+)
+
+// ErrorCode classifies a failed evaluation (OpenFeature error codes).
+type ErrorCode string
+
+const (
+	ErrorFlagNotFound     ErrorCode = "FLAG_NOT_FOUND"
+	ErrorTypeMismatch     ErrorCode = "TYPE_MISMATCH"
+	ErrorParse            ErrorCode = "PARSE_ERROR"
+	ErrorGeneral          ErrorCode = "GENERAL"
+	ErrorProviderNotReady ErrorCode = "PROVIDER_NOT_READY"
+)
+
+// EvaluationContext describes who a flag is evaluated for. TargetingKey is the
+// stable identity used for percentage rollouts; Attributes hold everything
+// else rules may match on (tenant, country, ...).
+type EvaluationContext struct {
+	TargetingKey string         `json:"targeting_key,omitempty"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+}
+
+// Detail is the evaluation metadata returned alongside a value.
+type Detail struct {
+	Variant      string    `json:"variant,omitempty"`
+	Reason       Reason    `json:"reason"`
+	ErrorCode    ErrorCode `json:"error_code,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+}
+
+// Resolution is a typed evaluation result. On error Value is the caller's default.
+type Resolution[T any] struct {
+	Value T `json:"value"`
+	Detail
+}
+
+// Metadata identifies a provider.
+type Metadata struct {
+	Name string `json:"name"`
+}
+
+// Provider resolves flags against a backend.
+type Provider interface {
+	Metadata() Metadata
+	BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, ec EvaluationContext) Resolution[bool]
+	StringEvaluation(ctx context.Context, flag string, defaultValue string, ec EvaluationContext) Resolution[string]
+	IntEvaluation(ctx context.Context, flag string, defaultValue int64, ec EvaluationContext) Resolution[int64]
+	FloatEvaluation(ctx context.Context, flag string, defaultValue float64, ec EvaluationContext) Resolution[float64]
+	ObjectEvaluation(ctx context.Context, flag string, defaultValue any, ec EvaluationContext) Resolution[any]
+}
+
+// Factory creates a provider from its configuration string (a file path, an
+// SDK key, ...).
+type Factory func(config string) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"none": func(string) (Provider, error) { return NoopProvider{}, nil },
+		"file": func(path string) (Provider, error) { return NewFileProvider(path) },
+	}
+)
+
+// Register makes a provider available to New under name, replacing any
+// provider already registered with it.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = f
+}
+
+// Providers returns the registered provider names.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the provider registered under name.
+func New(name, config string) (Provider, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown feature flag provider %q (registered: %v)", name, Providers())
+	}
+	return f(config)
+}
+
+// Client evaluates flags for the evaluation context carried by ctx.
+type Client struct {
+	provider Provider
+}
+
+// NewClient creates a client for p.
+func NewClient(p Provider) *Client {
+	if p == nil {
+		p = NoopProvider{}
+	}
+	return &Client{provider: p}
+}
+
+// Provider returns the client's provider.
+func (c *Client) Provider() Provider {
+	return c.provider
+}
+
+// Boolean returns the flag's value, or defaultValue if it cannot be evaluated.
+func (c *Client) Boolean(ctx context.Context, flag string, defaultValue bool) bool {
+	return c.BooleanDetails(ctx, flag, defaultValue).Value
+}
+
+// BooleanDetails evaluates a boolean flag with resolution details.
+func (c *Client) BooleanDetails(ctx context.Context, flag string, defaultValue bool) Resolution[bool] {
+	return c.provider.BooleanEvaluation(ctx, flag, defaultValue, FromContext(ctx))
+}
+
+// String returns the flag's value, or defaultValue if it cannot be evaluated.
+func (c *Client) String(ctx context.Context, flag string, defaultValue string) string {
+	return c.provider.StringEvaluation(ctx, flag, defaultValue, FromContext(ctx)).Value
+}
+
+// Int returns the flag's value, or defaultValue if it cannot be evaluated.
+func (c *Client) Int(ctx context.Context, flag string, defaultValue int64) int64 {
+	return c.provider.IntEvaluation(ctx, flag, defaultValue, FromContext(ctx)).Value
+}
+
+// Float returns the flag's value, or defaultValue if it cannot be evaluated.
+func (c *Client) Float(ctx context.Context, flag string, defaultValue float64) float64 {
+	return c.provider.FloatEvaluation(ctx, flag, defaultValue, FromContext(ctx)).Value
+}
+
+// Object evaluates a flag of any type with resolution details, e.g. for
+// exposing flags to clients.
+func (c *Client) Object(ctx context.Context, flag string, defaultValue any) Resolution[any] {
+	return c.provider.ObjectEvaluation(ctx, flag, defaultValue, FromContext(ctx))
+}
+
+type ctxKey struct{}
+
+// IntoContext attaches the evaluation context for the current request.
+func IntoContext(ctx context.Context, ec EvaluationContext) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ec)
+}
+
+// FromContext returns the evaluation context attached to ctx, if any.
+func FromContext(ctx context.Context) EvaluationContext {
+	ec, _ := ctx.Value(ctxKey{}).(EvaluationContext)
+	return ec
+}
+
+// NoopProvider returns the default value for every flag.
+type NoopProvider struct{}
+
+func (NoopProvider) Metadata() Metadata { return Metadata{Name: "none"} }
+
+func (NoopProvider) BooleanEvaluation(_ context.Context, _ string, v bool, _ EvaluationContext) Resolution[bool] {
+	return Resolution[bool]{Value: v, Detail: Detail{Reason: ReasonDefault}}
+}
+
+func (NoopProvider) StringEvaluation(_ context.Context, _ string, v string, _ EvaluationContext) Resolution[string] {
+	return Resolution[string]{Value: v, Detail: Detail{Reason: ReasonDefault}}
+}
+
+func (NoopProvider) IntEvaluation(_ context.Context, _ string, v int64, _ EvaluationContext) Resolution[int64] {
+	return Resolution[int64]{Value: v, Detail: Detail{Reason: ReasonDefault}}
+}
+
+func (NoopProvider) FloatEvaluation(_ context.Context, _ string, v float64, _ EvaluationContext) Resolution[float64] {
+	return Resolution[float64]{Value: v, Detail: Detail{Reason: ReasonDefault}}
+}
+
+func (NoopProvider) ObjectEvaluation(_ context.Context, _ string, v any, _ EvaluationContext) Resolution[any] {
+	return Resolution[any]{Value: v, Detail: Detail{Reason: ReasonDefault}}
+}
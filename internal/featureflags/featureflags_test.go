@@ -0,0 +1,78 @@
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+const testFlags = `{"flags": {
+  "new-checkout": {
+    "variants": {"on": true, "off": false},
+    "defaultVariant": "off",
+    "rules": [{"match": {"country": ["FI", "SE"], "tenant": ["key_acme"]}, "variant": "on"}],
+    "rollout": {"variant": "on", "percentage": 50}
+  },
+  "page-size": {"variants": {"small": 10, "large": 50}, "defaultVariant": "small"},
+  "banner": {"state": "DISABLED", "variants": {"x": "hello"}, "defaultVariant": "x"}
+}}`
+
+func TestFileProvider_Evaluation(t *testing.T) {
+	p, err := ParseFlags([]byte(testFlags))
+	if err != nil {
+		t.Fatalf("ParseFlags: %v", err)
+	}
+	c := NewClient(p)
+	ctx := IntoContext(context.Background(), EvaluationContext{Attributes: map[string]any{"country": "FI", "tenant": "key_acme"}})
+
+	if res := c.BooleanDetails(ctx, "new-checkout", false); !res.Value || res.Reason != ReasonTargetingMatch || res.Variant != "on" {
+		t.Fatalf("expected targeting match, got %+v", res)
+	}
+	if res := c.BooleanDetails(context.Background(), "new-checkout", true); res.Value || res.Reason != ReasonDefault {
+		t.Fatalf("expected default variant without context, got %+v", res)
+	}
+	if got := c.Int(ctx, "page-size", 1); got != 10 {
+		t.Fatalf("expected 10, got %d", got)
+	}
+	if res := c.provider.StringEvaluation(ctx, "banner", "fallback", EvaluationContext{}); res.Value != "fallback" || res.Reason != ReasonDisabled {
+		t.Fatalf("expected disabled flag to return default, got %+v", res)
+	}
+	if res := c.BooleanDetails(ctx, "page-size", true); res.ErrorCode != ErrorTypeMismatch || !res.Value {
+		t.Fatalf("expected type mismatch with default value, got %+v", res)
+	}
+	if res := c.BooleanDetails(ctx, "missing", true); res.ErrorCode != ErrorFlagNotFound || !res.Value {
+		t.Fatalf("expected flag not found, got %+v", res)
+	}
+}
+
+func TestFileProvider_RolloutIsStablePerTargetingKey(t *testing.T) {
+	p, _ := ParseFlags([]byte(testFlags))
+	on := 0
+	for i := 0; i < 1000; i++ {
+		ec := EvaluationContext{TargetingKey: fmt.Sprintf("key_%d", i)}
+		first := p.BooleanEvaluation(context.Background(), "new-checkout", false, ec)
+		again := p.BooleanEvaluation(context.Background(), "new-checkout", false, ec)
+		if first.Value != again.Value {
+			t.Fatalf("rollout not stable for %s", ec.TargetingKey)
+		}
+		if first.Value {
+			on++
+		}
+	}
+	if on < 400 || on > 600 {
+		t.Fatalf("expected about half of keys in a 50%% rollout, got %d/1000", on)
+	}
+}
+
+func TestNew_UnknownProviderAndRegistration(t *testing.T) {
+	if _, err := New("launchdarkly", ""); err == nil {
+		t.Fatal("expected error for unregistered provider")
+	}
+	Register("test", func(string) (Provider, error) { return NoopProvider{}, nil })
+	if p, err := New("test", ""); err != nil || p.Metadata().Name != "none" {
+		t.Fatalf("expected registered provider, got %v %v", p, err)
+	}
+	if _, err := ParseFlags([]byte(`{"flags": {"f": {"variants": {"a": 1}, "defaultVariant": "b"}}}`)); err == nil {
+		t.Fatal("expected unknown default variant to be rejected")
+	}
+}
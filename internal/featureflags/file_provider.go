@@ -0,0 +1,181 @@
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+)
+
+// ReasonSplit is returned when a percentage rollout selected the variant.
+const ReasonSplit Reason = "SPLIT"
+
+// FileProvider serves flags from a JSON document modelled on flagd flag
+// definitions, with simple attribute rules instead of JsonLogic:
+//
+//	{"flags": {"new-checkout": {
+//	  "state": "ENABLED",
+//	  "variants": {"on": true, "off": false},
+//	  "defaultVariant": "off",
+//	  "rules": [{"match": {"country": ["FI", "SE"]}, "variant": "on"}],
+//	  "rollout": {"variant": "on", "percentage": 10}
+//	}}}
+//
+// Rules are checked in order; a rule matches when every listed attribute has
+// one of the given values ("targeting_key" matches the targeting key). The
+// rollout then assigns a stable share of targeting keys to its variant.
+type FileProvider struct {
+	flags map[string]flagDefinition
+}
+
+type flagDefinition struct {
+	State          string         `json:"state"`
+	Variants       map[string]any `json:"variants"`
+	DefaultVariant string         `json:"defaultVariant"`
+	Rules          []flagRule     `json:"rules"`
+	Rollout        *flagRollout   `json:"rollout"`
+}
+
+type flagRule struct {
+	Match   map[string][]string `json:"match"`
+	Variant string              `json:"variant"`
+}
+
+type flagRollout struct {
+	Variant    string  `json:"variant"`
+	Percentage float64 `json:"percentage"`
+}
+
+// NewFileProvider loads flag definitions from path.
+func NewFileProvider(path string) (*FileProvider, error) {
+	if path == "" {
+		return nil, errors.New("file provider requires a flags file path")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFlags(data)
+}
+
+// ParseFlags builds a provider from a flags document.
+func ParseFlags(data []byte) (*FileProvider, error) {
+	var doc struct {
+		Flags map[string]flagDefinition `json:"flags"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse flags: %w", err)
+	}
+	for name, def := range doc.Flags {
+		for _, v := range append([]string{def.DefaultVariant}, variantRefs(def)...) {
+			if _, ok := def.Variants[v]; !ok {
+				return nil, fmt.Errorf("flag %q references unknown variant %q", name, v)
+			}
+		}
+	}
+	return &FileProvider{flags: doc.Flags}, nil
+}
+
+func variantRefs(def flagDefinition) []string {
+	var refs []string
+	for _, r := range def.Rules {
+		refs = append(refs, r.Variant)
+	}
+	if def.Rollout != nil {
+		refs = append(refs, def.Rollout.Variant)
+	}
+	return refs
+}
+
+func (p *FileProvider) Metadata() Metadata { return Metadata{Name: "file"} }
+
+func (p *FileProvider) BooleanEvaluation(_ context.Context, flag string, v bool, ec EvaluationContext) Resolution[bool] {
+	return evaluate(p, flag, v, ec, func(x any) (bool, bool) { b, ok := x.(bool); return b, ok })
+}
+
+func (p *FileProvider) StringEvaluation(_ context.Context, flag string, v string, ec EvaluationContext) Resolution[string] {
+	return evaluate(p, flag, v, ec, func(x any) (string, bool) { s, ok := x.(string); return s, ok })
+}
+
+func (p *FileProvider) IntEvaluation(_ context.Context, flag string, v int64, ec EvaluationContext) Resolution[int64] {
+	return evaluate(p, flag, v, ec, func(x any) (int64, bool) {
+		f, ok := x.(float64)
+		if !ok || f != math.Trunc(f) {
+			return 0, false
+		}
+		return int64(f), true
+	})
+}
+
+func (p *FileProvider) FloatEvaluation(_ context.Context, flag string, v float64, ec EvaluationContext) Resolution[float64] {
+	return evaluate(p, flag, v, ec, func(x any) (float64, bool) { f, ok := x.(float64); return f, ok })
+}
+
+func (p *FileProvider) ObjectEvaluation(_ context.Context, flag string, v any, ec EvaluationContext) Resolution[any] {
+	return evaluate(p, flag, v, ec, func(x any) (any, bool) { return x, true })
+}
+
+// evaluate resolves flag and converts the chosen variant's value with as.
+func evaluate[T any](p *FileProvider, flag string, defaultValue T, ec EvaluationContext, as func(any) (T, bool)) Resolution[T] {
+	def, ok := p.flags[flag]
+	if !ok {
+		return Resolution[T]{Value: defaultValue, Detail: Detail{Reason: ReasonError, ErrorCode: ErrorFlagNotFound, ErrorMessage: "flag not found"}}
+	}
+	if def.State == "DISABLED" {
+		return Resolution[T]{Value: defaultValue, Detail: Detail{Reason: ReasonDisabled}}
+	}
+	variant, reason := def.choose(flag, ec)
+	value, ok := as(def.Variants[variant])
+	if !ok {
+		return Resolution[T]{Value: defaultValue, Detail: Detail{Reason: ReasonError, ErrorCode: ErrorTypeMismatch,
+			ErrorMessage: fmt.Sprintf("variant %q has type %T", variant, def.Variants[variant])}}
+	}
+	return Resolution[T]{Value: value, Detail: Detail{Variant: variant, Reason: reason}}
+}
+
+func (def flagDefinition) choose(flag string, ec EvaluationContext) (string, Reason) {
+	for _, r := range def.Rules {
+		if r.matches(ec) {
+			return r.Variant, ReasonTargetingMatch
+		}
+	}
+	if ro := def.Rollout; ro != nil && ec.TargetingKey != "" && bucket(flag, ec.TargetingKey) < ro.Percentage {
+		return ro.Variant, ReasonSplit
+	}
+	if len(def.Rules) == 0 && def.Rollout == nil {
+		return def.DefaultVariant, ReasonStatic
+	}
+	return def.DefaultVariant, ReasonDefault
+}
+
+func (r flagRule) matches(ec EvaluationContext) bool {
+	for attr, allowed := range r.Match {
+		var actual string
+		if attr == "targeting_key" {
+			actual = ec.TargetingKey
+		} else if v, ok := ec.Attributes[attr]; ok {
+			actual = fmt.Sprint(v)
+		}
+		found := false
+		for _, a := range allowed {
+			if a == actual {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// bucket maps a targeting key to a stable point in [0, 100) per flag.
+func bucket(flag, key string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(flag + "/" + key))
+	return float64(h.Sum32()%10000) / 100
+}
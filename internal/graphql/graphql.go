@@ -0,0 +1,132 @@
+// Package graphql serves GraphQL queries over the service layer with gqlgen.
+// schema.graphqls is the schema; generated.go, generated from it by
+// go generate, parses, validates and executes queries, and the resolvers in
+// *.resolvers.go call the services behind the REST endpoints. Users are
+// fetched through a per-request Loader, so the user lookups of one query are
+// batched into one service call. Introspection is off; SDL describes the
+// schema instead.
+package graphql
+
+//go:generate go run github.com/99designs/gqlgen@v0.17.70 generate
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// SDL is the schema in the GraphQL schema definition language, for clients
+// and code generators.
+//
+//go:embed schema.graphqls
+var SDL string
+
+// maxUsers bounds the users one users field returns.
+const maxUsers = 1000
+
+// Stats is the source of the Stats type, whose fields are all resolved.
+type Stats struct{}
+
+// Options bound the queries an executor runs.
+type Options struct {
+	// MaxDepth is the deepest selection a query may make; 0 means 10.
+	MaxDepth int
+	// MaxSelections bounds the fields a query may select, counting those of
+	// fragments each time they are spread; 0 means 1000.
+	MaxSelections int
+	// PresentError turns an error returned by a resolver into the error
+	// shown to clients. By default its text is shown.
+	PresentError graphql.ErrorPresenterFunc
+}
+
+// NewExecutor returns the executor of queries against the schema, resolved
+// by r. Run queries with a context from WithLoaders.
+func NewExecutor(r *Resolver, opts Options) *executor.Executor {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 10
+	}
+	if opts.MaxSelections <= 0 {
+		opts.MaxSelections = 1000
+	}
+	exec := executor.New(NewExecutableSchema(Config{Resolvers: r}))
+	exec.Use(limits{maxDepth: opts.MaxDepth, maxSelections: opts.MaxSelections})
+	if opts.PresentError != nil {
+		exec.SetErrorPresenter(opts.PresentError)
+	}
+	// The error presenter logs and hides panics like any unexpected error
+	exec.SetRecoverFunc(func(_ context.Context, v any) error {
+		return fmt.Errorf("panic: %v", v)
+	})
+	return exec
+}
+
+// MarshalDateTime writes a DateTime: RFC 3339 in UTC.
+func MarshalDateTime(t time.Time) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(t.UTC().Format(time.RFC3339Nano)))
+	})
+}
+
+// UnmarshalDateTime reads a DateTime argument.
+func UnmarshalDateTime(v any) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, errors.New("DateTime must be an RFC 3339 string")
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// limits refuses queries nested deeper than maxDepth or selecting more than
+// maxSelections fields before they run.
+type limits struct {
+	maxDepth      int
+	maxSelections int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = limits{}
+
+func (limits) ExtensionName() string { return "Limits" }
+
+func (limits) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (l limits) MutateOperationContext(_ context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	selections := 0
+	var walk func(set ast.SelectionSet, depth int) *gqlerror.Error
+	walk = func(set ast.SelectionSet, depth int) *gqlerror.Error {
+		for _, sel := range set {
+			var err *gqlerror.Error
+			switch sel := sel.(type) {
+			case *ast.Field:
+				if depth > l.maxDepth {
+					return gqlerror.ErrorPosf(sel.Position, "Query is nested deeper than %d levels.", l.maxDepth)
+				}
+				if selections++; selections > l.maxSelections {
+					return gqlerror.ErrorPosf(sel.Position, "Query selects more than %d fields.", l.maxSelections)
+				}
+				err = walk(sel.SelectionSet, depth+1)
+			case *ast.InlineFragment:
+				err = walk(sel.SelectionSet, depth)
+			case *ast.FragmentSpread:
+				// Validation refuses fragment cycles
+				err = walk(sel.Definition.SelectionSet, depth)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(oc.Operation.SelectionSet, 1)
+}
@@ -0,0 +1,140 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+// countingUsers records the batches of GetUsersByIDs.
+type countingUsers struct {
+	services.UserService
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (u *countingUsers) GetUsersByIDs(ctx context.Context, ids []string) ([]services.User, error) {
+	u.mu.Lock()
+	u.batches = append(u.batches, slices.Sorted(slices.Values(ids)))
+	u.mu.Unlock()
+	return u.UserService.GetUsersByIDs(ctx, ids)
+}
+
+func execute(t *testing.T, r *Resolver, opts Options, query string, variables map[string]any) (string, bool) {
+	t.Helper()
+	exec := NewExecutor(r, opts)
+	ctx := r.WithLoaders(graphql.StartOperationTrace(context.Background()))
+	oc, errs := exec.CreateOperationContext(ctx, &graphql.RawParams{Query: query, Variables: variables})
+	if errs != nil {
+		b, _ := json.Marshal(errs)
+		return string(b), false
+	}
+	handle, ctx := exec.DispatchOperation(ctx, oc)
+	b, err := json.Marshal(handle(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), true
+}
+
+func TestExecute_BatchesUserLookups(t *testing.T) {
+	users := &countingUsers{UserService: services.NewUserService()}
+	// Wide enough that the concurrently resolved fields all make the batch
+	r := &Resolver{UserService: users, StatsService: services.NewStatsService(), loaderWait: 50 * time.Millisecond}
+
+	got, ok := execute(t, r, Options{}, `query($id: ID!) {
+		a: user(id: $id) { name }
+		b: user(id: "usr_404") { name }
+		c: user(id: "usr_002") { id }
+		users(ids: ["usr_002", "usr_404", "usr_001"]) { id role }
+	}`, map[string]any{"id": "usr_001"})
+	want := `{"data":{"a":{"name":"John Doe"},"b":null,"c":{"id":"usr_002"},"users":[{"id":"usr_002","role":"user"},{"id":"usr_001","role":"admin"}]}}`
+	if !ok || got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if len(users.batches) != 1 || strings.Join(users.batches[0], ",") != "usr_001,usr_002,usr_404" {
+		t.Fatalf("expected one batch of every user, got %v", users.batches)
+	}
+}
+
+func TestExecute_Limits(t *testing.T) {
+	r := &Resolver{UserService: services.NewUserService(), StatsService: services.NewStatsService()}
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{`{ stats { system { cpus } } }`, "Query is nested deeper than 2 levels."},
+		{`{ users { ...f ...f } } fragment f on User { id name }`, "Query selects more than 4 fields."},
+	} {
+		got, ok := execute(t, r, Options{MaxDepth: 2, MaxSelections: 4}, tc.query, nil)
+		if ok || !strings.Contains(got, tc.want) {
+			t.Errorf("%s: expected the request to fail with %q, got %s", tc.query, tc.want, got)
+		}
+	}
+	if got, ok := execute(t, r, Options{MaxDepth: 2, MaxSelections: 4}, `{ users(limit: 1) { id name } }`, nil); !ok || strings.Contains(got, "errors") {
+		t.Fatalf("expected a query within the limits to run, got %s", got)
+	}
+	if got, _ := execute(t, r, Options{}, `{ __schema { queryType { name } } }`, nil); !strings.Contains(got, "introspection disabled") {
+		t.Fatalf("expected introspection to be refused, got %s", got)
+	}
+}
+
+func TestExecute_PresentsResolverErrors(t *testing.T) {
+	r := &Resolver{UserService: services.NewUserService(), StatsService: services.NewStatsService()}
+	opts := Options{PresentError: func(ctx context.Context, err error) *gqlerror.Error {
+		e := graphql.DefaultErrorPresenter(ctx, err)
+		e.Message = "presented: " + e.Message
+		return e
+	}}
+	got, _ := execute(t, r, opts, `{ users(limit: 0) { id } }`, nil)
+	if !strings.Contains(got, `"message":"presented: limit must be between 1 and 1000","path":["users"]`) {
+		t.Fatalf("expected the presented error on its path, got %s", got)
+	}
+}
+
+func TestLoader_BatchesConcurrentLoadsAndCaches(t *testing.T) {
+	var batches [][]string
+	loader := NewLoader(50*time.Millisecond, func(_ context.Context, keys []string) (map[string]int, error) {
+		batches = append(batches, slices.Sorted(slices.Values(keys)))
+		if slices.Contains(keys, "fail") {
+			return nil, errors.New("backend down")
+		}
+		return map[string]int{"a": 1, "b": 2}, nil
+	})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c", "a"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loader.Load(ctx, key)
+		}()
+	}
+	wg.Wait()
+	if len(batches) != 1 || strings.Join(batches[0], ",") != "a,b,c" {
+		t.Fatalf("expected one batch of a,b,c, got %v", batches)
+	}
+
+	if v, found, err := loader.Load(ctx, "b"); v != 2 || !found || err != nil {
+		t.Fatalf("expected the cached b, got %v %v %v", v, found, err)
+	}
+	if values, err := loader.LoadAll(ctx, []string{"c", "a"}); err != nil || !slices.Equal(values, []int{1}) {
+		t.Fatalf("expected the found values in order, got %v %v", values, err)
+	}
+	if len(batches) != 1 {
+		t.Fatalf("expected cached keys not to be fetched again, got %v", batches)
+	}
+	if _, _, err := loader.Load(ctx, "fail"); err == nil || err.Error() != "backend down" {
+		t.Fatalf("expected the fetch error, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+// Loader batches the lookups of one request. gqlgen resolves sibling fields
+// and list items concurrently, so Load waits up to the loader's wait for
+// other lookups and the keys asked for in that window are fetched in one
+// call. Results are cached for the Loader's lifetime, so create one per
+// request.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+	wait  time.Duration
+
+	mu      sync.Mutex
+	batch   []K
+	results map[K]*loaded[V]
+}
+
+type loaded[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+// NewLoader returns a Loader fetching with fetch, which returns the values
+// of the keys it found; keys it leaves out are not found.
+func NewLoader[K comparable, V any](wait time.Duration, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, wait: wait, results: map[K]*loaded[V]{}}
+}
+
+// Load returns the value of key and whether it was found.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	r := l.enqueue(ctx, key)
+	l.mu.Unlock()
+	return r.wait(ctx)
+}
+
+// LoadAll returns the values of the keys that were found, in the order of
+// keys, fetching the missing ones in one batch.
+func (l *Loader[K, V]) LoadAll(ctx context.Context, keys []K) ([]V, error) {
+	l.mu.Lock()
+	pending := make([]*loaded[V], len(keys))
+	for i, k := range keys {
+		pending[i] = l.enqueue(ctx, k)
+	}
+	l.mu.Unlock()
+	values := make([]V, 0, len(keys))
+	for _, r := range pending {
+		v, found, err := r.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// enqueue returns the result of key, adding key to the next batch unless it
+// was asked for before. It is called with mu held.
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K) *loaded[V] {
+	if r, ok := l.results[key]; ok {
+		return r
+	}
+	r := &loaded[V]{done: make(chan struct{})}
+	l.results[key] = r
+	if len(l.batch) == 0 {
+		time.AfterFunc(l.wait, func() { l.dispatch(context.WithoutCancel(ctx)) })
+	}
+	l.batch = append(l.batch, key)
+	return r
+}
+
+// dispatch fetches the keys of the current batch.
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	keys := l.batch
+	l.batch = nil
+	l.mu.Unlock()
+	values, err := l.fetch(ctx, keys)
+	for _, k := range keys {
+		l.mu.Lock()
+		r := l.results[k]
+		l.mu.Unlock()
+		r.value, r.found = values[k]
+		r.err = err
+		close(r.done)
+	}
+}
+
+func (r *loaded[V]) wait(ctx context.Context) (V, bool, error) {
+	select {
+	case <-r.done:
+		return r.value, r.found, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+// defaultLoaderWait is how long the lookups of a request gather before they
+// are fetched.
+const defaultLoaderWait = time.Millisecond
+
+type loadersKey struct{}
+
+// loaders are the Loaders of one request.
+type loaders struct {
+	users *Loader[string, *services.User]
+}
+
+// WithLoaders returns ctx carrying the Loaders of one request, which the
+// resolvers of r fetch through.
+func (r *Resolver) WithLoaders(ctx context.Context) context.Context {
+	wait := r.loaderWait
+	if wait <= 0 {
+		wait = defaultLoaderWait
+	}
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		users: NewLoader(wait, func(ctx context.Context, ids []string) (map[string]*services.User, error) {
+			users, err := r.UserService.GetUsersByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[string]*services.User, len(users))
+			for i := range users {
+				byID[users[i].ID] = &users[i]
+			}
+			return byID, nil
+		}),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,16 @@
+package graphql
+
+import (
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+// Resolver resolves the fields of the schema with the services behind the
+// REST endpoints. The resolvers of each type are in schema.resolvers.go.
+type Resolver struct {
+	UserService  services.UserService
+	StatsService services.StatsService
+
+	loaderWait time.Duration // 0 means defaultLoaderWait
+}
@@ -0,0 +1,85 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikko-kohtala/go-api/internal/i18n"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*services.User, error) {
+	user, _, err := loadersFrom(ctx).users.Load(ctx, id)
+	return user, err
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, ids []string, limit *int) ([]*services.User, error) {
+	n := 100 // limit: null asks for the default
+	if limit != nil {
+		n = *limit
+	}
+	if n < 1 || n > maxUsers {
+		return nil, &gqlerror.Error{Message: fmt.Sprintf("limit must be between 1 and %d", maxUsers), Extensions: map[string]any{"code": "invalid_request"}}
+	}
+	if ids == nil {
+		all, err := r.UserService.GetAllUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		users := make([]*services.User, min(n, len(all)))
+		for i := range users {
+			users[i] = &all[i]
+		}
+		return users, nil
+	}
+	return loadersFrom(ctx).users.LoadAll(ctx, ids[:min(n, len(ids))])
+}
+
+// Stats is the resolver for the stats field.
+func (r *queryResolver) Stats(ctx context.Context) (*Stats, error) {
+	return &Stats{}, nil
+}
+
+// System is the resolver for the system field.
+func (r *statsResolver) System(ctx context.Context, obj *Stats) (*services.SystemStats, error) {
+	return r.StatsService.GetSystemStats(ctx)
+}
+
+// UptimeSeconds is the resolver for the uptimeSeconds field.
+func (r *systemStatsResolver) UptimeSeconds(ctx context.Context, obj *services.SystemStats) (float64, error) {
+	return obj.Uptime.Seconds(), nil
+}
+
+// MemoryUsageMb is the resolver for the memoryUsageMB field.
+func (r *systemStatsResolver) MemoryUsageMb(ctx context.Context, obj *services.SystemStats) (int, error) {
+	return int(obj.MemoryUsage), nil
+}
+
+// RoleDisplay is the resolver for the roleDisplay field.
+func (r *userResolver) RoleDisplay(ctx context.Context, obj *services.User) (string, error) {
+	return i18n.T(ctx, "role."+obj.Role), nil
+}
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Stats returns StatsResolver implementation.
+func (r *Resolver) Stats() StatsResolver { return &statsResolver{r} }
+
+// SystemStats returns SystemStatsResolver implementation.
+func (r *Resolver) SystemStats() SystemStatsResolver { return &systemStatsResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type queryResolver struct{ *Resolver }
+type statsResolver struct{ *Resolver }
+type systemStatsResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+// APIKeyScope is the scope an API key needs to manage keys.
+const APIKeyScope = "apikeys"
+
+type APIKeyHandler struct {
+	keys   services.APIKeyService
+	logger *slog.Logger
+}
+
+func NewAPIKeyHandler(keys services.APIKeyService, logger *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"dive,required"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// IssueAPIKeyRequest is CreateAPIKeyRequest for an owner named by an operator.
+type IssueAPIKeyRequest struct {
+	CreateAPIKeyRequest
+	OwnerID string `json:"owner_id" validate:"required"`
+}
+
+// APIKeyList lists API keys, oldest first.
+type APIKeyList struct {
+	Keys []services.APIKey `json:"keys"`
+}
+
+// ListAPIKeys godoc
+// @Summary      List the caller's API keys
+// @Tags         apikeys
+// @Produce      json
+// @Success      200 {object} APIKeyList
+// @Failure      401 {object} map[string]interface{}
+// @Router       /api/v1/apikeys [get]
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	p, _ := requestctx.Principal(r.Context())
+	keys, err := h.keys.List(r.Context(), p.UserID)
+	if err != nil {
+		return err
+	}
+	response.JSON(w, r, http.StatusOK, APIKeyList{Keys: keys})
+	return nil
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Issues a key acting as the caller, limited to scopes. The key is only returned in this response;
+// @Description  send it as X-API-Key. A caller authenticated with a scoped key can only grant its own scopes.
+// @Tags         apikeys
+// @Accept       json
+// @Produce      json
+// @Param        key body CreateAPIKeyRequest true "Key"
+// @Success      201 {object} services.IssuedKey
+// @Failure      400 {object} map[string]interface{}
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /api/v1/apikeys [post]
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	var req CreateAPIKeyRequest
+	if err := bindAPIKeyRequest(r, &req); err != nil {
+		return err
+	}
+	p, _ := requestctx.Principal(r.Context())
+	for _, scope := range req.Scopes {
+		if !p.HasScope(scope) {
+			return httpabort.Errorf(http.StatusForbidden, "insufficient_scope", "The credentials cannot grant the %s scope", scope)
+		}
+	}
+	return h.issue(w, r, p.UserID, req, "/api/v1/apikeys/")
+}
+
+// GetAPIKey godoc
+// @Summary      Get one of the caller's API keys
+// @Tags         apikeys
+// @Produce      json
+// @Param        keyID path string true "API key ID"
+// @Success      200 {object} services.APIKey
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/apikeys/{keyID} [get]
+func (h *APIKeyHandler) GetAPIKey(w http.ResponseWriter, r *http.Request) error {
+	key, err := h.ownKey(r)
+	if err != nil {
+		return err
+	}
+	response.JSON(w, r, http.StatusOK, key)
+	return nil
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke one of the caller's API keys
+// @Description  Requests with a revoked key get 401. Revoking a revoked key changes nothing.
+// @Tags         apikeys
+// @Produce      json
+// @Param        keyID path string true "API key ID"
+// @Success      200 {object} services.APIKey
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/apikeys/{keyID} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	if _, err := h.ownKey(r); err != nil {
+		return err
+	}
+	return h.revoke(w, r)
+}
+
+// ListAllAPIKeys godoc
+// @Summary      List every API key
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} APIKeyList
+// @Router       /admin/apikeys [get]
+func (h *APIKeyHandler) ListAllAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	keys, err := h.keys.List(r.Context(), "")
+	if err != nil {
+		return err
+	}
+	response.JSON(w, r, http.StatusOK, APIKeyList{Keys: keys})
+	return nil
+}
+
+// IssueAPIKey godoc
+// @Summary      Issue an API key for a principal
+// @Description  Operator view: issues a key acting as owner_id with any scopes, e.g. a machine client's first key.
+// @Description  Requires the admin scope; the route is not mounted where the admin group does not check it.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        key body IssueAPIKeyRequest true "Key"
+// @Success      201 {object} services.IssuedKey
+// @Failure      400 {object} map[string]interface{}
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /admin/apikeys [post]
+func (h *APIKeyHandler) IssueAPIKey(w http.ResponseWriter, r *http.Request) error {
+	var req IssueAPIKeyRequest
+	if err := bindAPIKeyRequest(r, &req); err != nil {
+		return err
+	}
+	return h.issue(w, r, req.OwnerID, req.CreateAPIKeyRequest, "/admin/apikeys/")
+}
+
+// AdminRevokeAPIKey godoc
+// @Summary      Revoke any API key
+// @Tags         admin
+// @Produce      json
+// @Param        keyID path string true "API key ID"
+// @Success      200 {object} services.APIKey
+// @Failure      404 {object} map[string]interface{}
+// @Router       /admin/apikeys/{keyID} [delete]
+func (h *APIKeyHandler) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	return h.revoke(w, r)
+}
+
+func (h *APIKeyHandler) issue(w http.ResponseWriter, r *http.Request, owner string, req CreateAPIKeyRequest, location string) error {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "expires_at must be in the future")
+	}
+	issued, err := h.keys.Issue(r.Context(), services.NewAPIKey{
+		Name:      req.Name,
+		OwnerID:   owner,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	h.logger.Info("api key issued",
+		slog.String("api_key_id", issued.ID),
+		slog.String("owner_id", issued.OwnerID),
+		slog.Any("scopes", issued.Scopes))
+	w.Header().Set("Location", location+issued.ID)
+	response.JSON(w, r, http.StatusCreated, issued)
+	return nil
+}
+
+func (h *APIKeyHandler) revoke(w http.ResponseWriter, r *http.Request) error {
+	key, err := h.keys.Revoke(r.Context(), chi.URLParam(r, "keyID"))
+	if err != nil {
+		return err
+	}
+	h.logger.Info("api key revoked", slog.String("api_key_id", key.ID), slog.String("owner_id", key.OwnerID))
+	response.JSON(w, r, http.StatusOK, key)
+	return nil
+}
+
+// ownKey returns the key in the path when it belongs to the caller; keys of
+// others are not found.
+func (h *APIKeyHandler) ownKey(r *http.Request) (*services.APIKey, error) {
+	key, err := h.keys.Get(r.Context(), chi.URLParam(r, "keyID"))
+	if err != nil {
+		return nil, err
+	}
+	if p, _ := requestctx.Principal(r.Context()); key.OwnerID != p.UserID {
+		return nil, services.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func bindAPIKeyRequest(r *http.Request, dst any) error {
+	errs, err := validate.BindAndValidate(r, dst)
+	if err != nil {
+		return bindError(err)
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// AssetHandler serves the packaged assets: Grafana dashboards to operators
+// and the static files.
+type AssetHandler struct {
+	assets fs.FS
+	logger *slog.Logger
+}
+
+func NewAssetHandler(assets fs.FS, logger *slog.Logger) *AssetHandler {
+	return &AssetHandler{
+		assets: assets,
+		logger: logger,
+	}
+}
+
+// DashboardList names the Grafana dashboards that can be downloaded.
+type DashboardList struct {
+	Dashboards []string `json:"dashboards"`
+}
+
+// ListDashboards godoc
+// @Summary      List Grafana dashboards
+// @Description  Admin view: names of the packaged Grafana dashboards for the API's metrics.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} DashboardList
+// @Router       /admin/dashboards [get]
+func (h *AssetHandler) ListDashboards(w http.ResponseWriter, r *http.Request) error {
+	files, err := fs.Glob(h.assets, "dashboards/*.json")
+	if err != nil {
+		return err
+	}
+	list := DashboardList{Dashboards: make([]string, 0, len(files))}
+	for _, f := range files {
+		list.Dashboards = append(list.Dashboards, strings.TrimSuffix(path.Base(f), ".json"))
+	}
+	response.JSON(w, r, http.StatusOK, list)
+	return nil
+}
+
+// GetDashboard godoc
+// @Summary      Download a Grafana dashboard
+// @Description  Returns the dashboard JSON, ready to import into Grafana.
+// @Tags         admin
+// @Produce      json
+// @Param        name path string true "Dashboard name"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} map[string]interface{}
+// @Router       /admin/dashboards/{name} [get]
+func (h *AssetHandler) GetDashboard(w http.ResponseWriter, r *http.Request) error {
+	data, err := fs.ReadFile(h.assets, "dashboards/"+chi.URLParam(r, "name")+".json")
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		return httpabort.New(http.StatusNotFound, "not_found", "Dashboard not found")
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response.Bytes(w, r, http.StatusOK, data)
+	return nil
+}
+
+// Static serves the files in the static assets directory.
+func (h *AssetHandler) Static() http.Handler {
+	static, err := fs.Sub(h.assets, "static")
+	if err != nil {
+		panic(err) // only for an invalid path
+	}
+	return http.FileServerFS(static)
+}
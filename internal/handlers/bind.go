@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+// bind decodes and validates the JSON body of r into dst. It returns the
+// violations as validate.Errors, and a body that could not be decoded as a
+// 413 when the body limit was hit and a 400 otherwise.
+func bind(r *http.Request, dst any) error {
+	errs, err := validate.BindAndValidate(r, dst)
+	if err != nil {
+		return bindError(err)
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// bindError is the error for a body validate.BindAndValidate could not decode.
+func bindError(err error) error {
+	if _, ok := validate.TooLarge(err); ok {
+		return err
+	}
+	return httpabort.New(http.StatusBadRequest, "invalid_request", "Invalid JSON")
+}
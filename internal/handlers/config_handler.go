@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/boot"
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+type ConfigHandler struct {
+	settings []config.Setting
+	logger   *slog.Logger
+}
+
+func NewConfigHandler(settings []config.Setting, logger *slog.Logger) *ConfigHandler {
+	return &ConfigHandler{
+		settings: settings,
+		logger:   logger,
+	}
+}
+
+// ConfigReport lists the server's configuration keys.
+type ConfigReport struct {
+	Settings []config.Setting `json:"settings"`
+}
+
+// GetConfig godoc
+// @Summary      Describe the configuration
+// @Description  Admin view: every configuration key with its type, default, description and
+// @Description  current value as loaded at startup. Secret values are shown as "[redacted]".
+// @Description  Requires the admin scope.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} ConfigReport
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /admin/config [get]
+func (h *ConfigHandler) GetConfig(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, ConfigReport{Settings: h.settings})
+	return nil
+}
+
+// GetBootReport godoc
+// @Summary      Describe this instance
+// @Description  Admin view: what this instance runs, as logged at startup in the "boot report" record: build,
+// @Description  configuration keys set in the environment, middleware chain, enabled features, number of
+// @Description  mounted routes and the results of the preflight checks.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} boot.Report
+// @Router       /admin/boot [get]
+func (h *ConfigHandler) GetBootReport(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, boot.Default.Report())
+	return nil
+}
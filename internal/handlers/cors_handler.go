@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/corsreport"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+type CORSHandler struct {
+	rejections *corsreport.Log
+	logger     *slog.Logger
+}
+
+func NewCORSHandler(rejections *corsreport.Log, logger *slog.Logger) *CORSHandler {
+	return &CORSHandler{
+		rejections: rejections,
+		logger:     logger,
+	}
+}
+
+// CORSRejectionReport lists the origins recently refused by the CORS policies.
+type CORSRejectionReport struct {
+	Rejections []corsreport.Rejection `json:"rejections"`
+}
+
+// GetRejections godoc
+// @Summary      List recently rejected CORS origins
+// @Description  Admin view: origins refused by a CORS policy, most recently seen first, with the
+// @Description  policy, request count and the latest path. Requests from the API's own host are not listed.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} CORSRejectionReport
+// @Router       /admin/cors/rejections [get]
+func (h *CORSHandler) GetRejections(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, CORSRejectionReport{Rejections: h.rejections.Recent()})
+	return nil
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+type DeadLetterHandler struct {
+	pool   *jobs.Pool
+	logger *slog.Logger
+}
+
+func NewDeadLetterHandler(pool *jobs.Pool, logger *slog.Logger) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// DeadLetterList lists failed background jobs, most recent first.
+type DeadLetterList struct {
+	DeadLetters []jobs.DeadLetter `json:"dead_letters"`
+	Total       int               `json:"total"`
+}
+
+type ReplayDeadLettersRequest struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=1000,dive,required"`
+}
+
+// ReplayResult reports which dead letters were queued again.
+type ReplayResult struct {
+	Replayed []string          `json:"replayed"`
+	Failed   map[string]string `json:"failed,omitempty"` // id to reason
+}
+
+// PurgeResult reports how many dead letters were removed.
+type PurgeResult struct {
+	Purged int `json:"purged"`
+}
+
+// ListDeadLetters godoc
+// @Summary      List dead-lettered jobs
+// @Description  Admin view: background jobs and webhook deliveries that failed for good, with the failure reason.
+// @Tags         admin
+// @Produce      json
+// @Param        job query string false "Only this job, e.g. webhook"
+// @Success      200 {object} DeadLetterList
+// @Router       /admin/dead-letters [get]
+func (h *DeadLetterHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	dead := h.pool.DeadLetters()
+	response.JSON(w, r, http.StatusOK, DeadLetterList{DeadLetters: dead.List(r.URL.Query().Get("job")), Total: dead.Len()})
+	return nil
+}
+
+// ReplayDeadLetters godoc
+// @Summary      Replay dead-lettered jobs
+// @Description  Queues the selected dead letters again and removes them from the list; a job that fails
+// @Description  again comes back with its replay count raised. Items that cannot be queued stay listed.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request body ReplayDeadLettersRequest true "Dead letter IDs"
+// @Success      200 {object} ReplayResult
+// @Failure      400 {object} map[string]interface{}
+// @Router       /admin/dead-letters/replay [post]
+func (h *DeadLetterHandler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	var req ReplayDeadLettersRequest
+	errs, err := validate.BindAndValidate(r, &req)
+	if err != nil {
+		return bindError(err)
+	}
+	if errs != nil {
+		return errs
+	}
+
+	res := ReplayResult{Replayed: []string{}}
+	for _, id := range req.IDs {
+		if err := h.pool.Replay(id); err != nil {
+			if res.Failed == nil {
+				res.Failed = map[string]string{}
+			}
+			res.Failed[id] = err.Error()
+			continue
+		}
+		res.Replayed = append(res.Replayed, id)
+	}
+	h.logger.Info("dead letters replayed", slog.Int("replayed", len(res.Replayed)), slog.Int("failed", len(res.Failed)))
+	response.JSON(w, r, http.StatusOK, res)
+	return nil
+}
+
+// PurgeDeadLetters godoc
+// @Summary      Purge old dead letters
+// @Tags         admin
+// @Produce      json
+// @Param        older_than query string true "Remove dead letters that failed longer ago than this, e.g. 72h; 0 removes all"
+// @Success      200 {object} PurgeResult
+// @Failure      400 {object} map[string]interface{}
+// @Router       /admin/dead-letters [delete]
+func (h *DeadLetterHandler) PurgeDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	age, err := time.ParseDuration(r.URL.Query().Get("older_than"))
+	if err != nil || age < 0 {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "older_than must be a duration such as 72h")
+	}
+	n, _ := h.pool.DeadLetters().Purge(r.Context(), time.Now().Add(-age), false)
+	h.logger.Info("dead letters purged", slog.Int("purged", n), slog.Duration("older_than", age))
+	response.JSON(w, r, http.StatusOK, PurgeResult{Purged: n})
+	return nil
+}
+
+// DeleteDeadLetter godoc
+// @Summary      Discard a dead letter
+// @Tags         admin
+// @Param        id path string true "Dead letter ID"
+// @Success      204 "No Content"
+// @Failure      404 {object} map[string]interface{}
+// @Router       /admin/dead-letters/{id} [delete]
+func (h *DeadLetterHandler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) error {
+	if err := h.pool.DeadLetters().Delete(chi.URLParam(r, "id")); err != nil {
+		return err
+	}
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+type ErasureHandler struct {
+	erasure     services.ErasureService
+	userService services.UserService
+	ops         *operations.Store
+	logger      *slog.Logger
+}
+
+func NewErasureHandler(erasure services.ErasureService, userService services.UserService, ops *operations.Store, logger *slog.Logger) *ErasureHandler {
+	return &ErasureHandler{
+		erasure:     erasure,
+		userService: userService,
+		ops:         ops,
+		logger:      logger,
+	}
+}
+
+// EraseUser godoc
+// @Summary      Erase a user
+// @Description  Starts erasing everything stored about the user (notification preferences, then the user).
+// @Description  Answers 202 with an operation; poll its Location for the result.
+// @Tags         users
+// @Produce      json
+// @Param        userID path string true "User ID"
+// @Success      202 {object} operations.Operation
+// @Failure      404 {object} map[string]interface{}
+// @Failure      429 {object} map[string]interface{}
+// @Failure      503 {object} map[string]interface{}
+// @Router       /api/v1/users/{userID}/erasure [post]
+func (h *ErasureHandler) EraseUser(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := h.userService.GetUserByID(r.Context(), userID); err != nil {
+		return err
+	}
+
+	op, err := h.ops.Start(r.Context(), "users.erase", func(ctx context.Context, t *operations.Tracker) (any, error) {
+		return h.erasure.Erase(ctx, userID, t)
+	})
+	if err != nil {
+		return err
+	}
+	h.logger.Info("user erasure started", slog.String("user_id", userID), slog.String("operation_id", op.ID))
+	writeOperation(w, r, op)
+	return nil
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	"github.com/mikko-kohtala/go-api/internal/idempotency"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/webhooks"
+	"github.com/mikko-kohtala/go-api/pkg/timestamp"
+)
+
+// Service errors answered through response.FromError.
+func init() {
+	errmap.Register(services.ErrUserNotFound, http.StatusNotFound, "not_found", "User not found")
+	errmap.Register(services.ErrInvalidUserID, http.StatusBadRequest, "invalid_request", "Invalid user ID")
+	errmap.Register(services.ErrEmailAlreadyExists, http.StatusConflict, "duplicate_email", "Email already exists")
+	errmap.Register(services.ErrInvalidEmail, http.StatusBadRequest, "invalid_email", "Invalid email address")
+	errmap.Register(services.ErrAPIKeyNotFound, http.StatusNotFound, "not_found", "API key not found")
+	errmap.Register(services.ErrInvalidScope, http.StatusBadRequest, "invalid_scope", "Scopes are lowercase words, optionally qualified as resource:action")
+	errmap.Register(services.ErrTaskNotFound, http.StatusNotFound, "not_found", "Task not found")
+	errmap.Register(services.ErrTaskAlreadyDone, http.StatusConflict, "task_already_done", "Task is already done")
+	errmap.Register(operations.ErrNotFound, http.StatusNotFound, "not_found", "Operation not found")
+	errmap.Register(jobs.ErrDeadLetterNotFound, http.StatusNotFound, "not_found", "Dead letter not found")
+	errmap.Register(webhooks.ErrNotFound, http.StatusNotFound, "not_found", "Webhook subscription not found")
+	errmap.Register(webhooks.ErrKeyNotFound, http.StatusNotFound, "not_found", "Signing key not found")
+	errmap.Register(webhooks.ErrLastKey, http.StatusConflict, "last_signing_key", "A subscription needs at least one signing key; rotate before retiring this one")
+	errmap.Register(webhooks.ErrInvalidURL, http.StatusBadRequest, "invalid_url", "")
+	errmap.Register(webhooks.ErrUnknownEvent, http.StatusBadRequest, "unknown_event", "")
+	errmap.Register(idempotency.ErrKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request")
+	errmap.Register(timestamp.ErrInvalid, http.StatusBadRequest, timestamp.Code(timestamp.ErrInvalid), "Timestamp is malformed")
+	errmap.Register(timestamp.ErrExpired, http.StatusBadRequest, timestamp.Code(timestamp.ErrExpired), "Timestamp has expired")
+	errmap.Register(timestamp.ErrNotYetValid, http.StatusBadRequest, timestamp.Code(timestamp.ErrNotYetValid), "Timestamp is not yet valid")
+	errmap.Register(timestamp.ErrTooOld, http.StatusBadRequest, timestamp.Code(timestamp.ErrTooOld), "Timestamp is too old")
+	errmap.Register(timestamp.ErrInFuture, http.StatusBadRequest, timestamp.Code(timestamp.ErrInFuture), "Timestamp is in the future; check the client clock")
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/signedurl"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+// tus resumable upload protocol (https://tus.io/protocols/resumable-upload)
+// defaultSignedURLTTL is used when a signed URL request does not specify a lifetime.
+const defaultSignedURLTTL = 15 * time.Minute
+
+const (
+	tusVersion         = "1.0.0"
+	tusExtensions      = "creation,expiration,termination"
+	tusChunkMediaType  = "application/offset+octet-stream"
+	maxUploadMetaBytes = 4096
+)
+
+type FileHandler struct {
+	fileService services.FileService
+	signer      *signedurl.Signer
+	logger      *slog.Logger
+}
+
+func NewFileHandler(fileService services.FileService, signer *signedurl.Signer, logger *slog.Logger) *FileHandler {
+	return &FileHandler{
+		fileService: fileService,
+		signer:      signer,
+		logger:      logger,
+	}
+}
+
+type SignedURLRequest struct {
+	ExpiresIn int `json:"expires_in,omitempty" validate:"omitempty,min=1"` // seconds
+}
+
+type SignedURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RequireTus rejects requests that do not speak a supported tus version with 412.
+// OPTIONS is exempt so clients can discover the server's capabilities.
+func RequireTus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			response.Error(w, r, http.StatusPreconditionFailed, "unsupported_protocol",
+				"Tus-Resumable header must be "+tusVersion, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Options godoc
+// @Summary      Discover upload capabilities
+// @Description  Returns the supported tus version, extensions and maximum upload size
+// @Tags         files
+// @Success      204
+// @Router       /api/v1/files [options]
+func (h *FileHandler) Options(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.fileService.MaxSize(), 10))
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
+
+// CreateUpload godoc
+// @Summary      Create a resumable upload
+// @Description  Registers a tus upload of Upload-Length bytes; chunks are then sent with PATCH to the returned Location
+// @Tags         files
+// @Param        Tus-Resumable header string true "tus protocol version (1.0.0)"
+// @Param        Upload-Length header int true "Total upload size in bytes"
+// @Param        Upload-Metadata header string false "Comma separated key/base64 value pairs, e.g. filename and filetype"
+// @Success      201
+// @Failure      400 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Failure      412 {object} map[string]interface{}
+// @Failure      413 {object} map[string]interface{}
+// @Router       /api/v1/files [post]
+func (h *FileHandler) CreateUpload(w http.ResponseWriter, r *http.Request) error {
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "Upload-Length header must be a non-negative integer")
+	}
+	meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "Invalid Upload-Metadata header")
+	}
+
+	upload := services.NewUpload{Size: size, Metadata: meta}
+	upload.Name = meta["filename"]
+	if ct := meta["filetype"]; ct != "" {
+		if _, _, err := mime.ParseMediaType(ct); err == nil {
+			upload.ContentType = ct
+		}
+	}
+
+	file, err := h.fileService.CreateUpload(r.Context(), upload)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadTooLarge) {
+			return &http.MaxBytesError{Limit: h.fileService.MaxSize()}
+		}
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Failed to create upload")
+	}
+
+	h.logger.Info("upload created", slog.String("file_id", file.ID), slog.Int64("size", file.Size))
+	setUploadHeaders(w, file)
+	w.Header().Set("Location", "/api/v1/files/"+file.ID)
+	response.NoBody(w, r, http.StatusCreated)
+	return nil
+}
+
+// UploadOffset godoc
+// @Summary      Get upload offset
+// @Description  Returns how many bytes of the upload have been received in the Upload-Offset header
+// @Tags         files
+// @Param        fileID path string true "File ID"
+// @Param        Tus-Resumable header string true "tus protocol version (1.0.0)"
+// @Success      200
+// @Failure      404
+// @Failure      410
+// @Router       /api/v1/files/{fileID} [head]
+func (h *FileHandler) UploadOffset(w http.ResponseWriter, r *http.Request) error {
+	file, err := h.fileService.GetFile(r.Context(), chi.URLParam(r, "fileID"))
+	if err != nil {
+		return h.fileError(err)
+	}
+	setUploadHeaders(w, file)
+	response.NoStore(w)
+	response.NoBody(w, r, http.StatusOK)
+	return nil
+}
+
+// AppendChunk godoc
+// @Summary      Upload a chunk
+// @Description  Appends the request body at Upload-Offset, which must match the current offset
+// @Tags         files
+// @Accept       application/offset+octet-stream
+// @Param        fileID path string true "File ID"
+// @Param        Tus-Resumable header string true "tus protocol version (1.0.0)"
+// @Param        Upload-Offset header int true "Offset the chunk starts at"
+// @Success      204
+// @Failure      400 {object} map[string]interface{}
+// @Failure      404 {object} map[string]interface{}
+// @Failure      409 {object} map[string]interface{}
+// @Failure      410 {object} map[string]interface{}
+// @Failure      415 {object} map[string]interface{}
+// @Failure      423 {object} map[string]interface{}
+// @Router       /api/v1/files/{fileID} [patch]
+func (h *FileHandler) AppendChunk(w http.ResponseWriter, r *http.Request) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != tusChunkMediaType {
+		return httpabort.New(http.StatusUnsupportedMediaType, "unsupported_media_type",
+			"Content-Type must be "+tusChunkMediaType)
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "Upload-Offset header must be a non-negative integer")
+	}
+
+	fileID := chi.URLParam(r, "fileID")
+	file, err := h.fileService.AppendChunk(r.Context(), fileID, offset, r.Body)
+	if err != nil {
+		if file != nil && errors.Is(err, services.ErrOffsetMismatch) {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(file.Offset, 10))
+		}
+		if file != nil && !isUploadStateError(err) {
+			// The body was cut off or could not be stored; the received bytes are kept
+			// and the client resumes from the offset reported by HEAD.
+			h.logger.Warn("upload chunk interrupted",
+				slog.String("file_id", fileID),
+				slog.Int64("offset", file.Offset),
+				slog.String("error", err.Error()))
+			if _, ok := validate.TooLarge(err); ok {
+				return err
+			}
+			return httpabort.New(http.StatusInternalServerError, "internal_error", "Failed to store upload chunk")
+		}
+		return h.fileError(err)
+	}
+
+	setUploadHeaders(w, file)
+	if file.Complete {
+		h.logger.Info("upload complete", slog.String("file_id", file.ID), slog.Int64("size", file.Size))
+	}
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
+
+// GetFile godoc
+// @Summary      Get file metadata
+// @Tags         files
+// @Produce      json
+// @Param        fileID path string true "File ID"
+// @Success      200 {object} services.File
+// @Failure      404 {object} map[string]interface{}
+// @Failure      410 {object} map[string]interface{}
+// @Router       /api/v1/files/{fileID} [get]
+func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) error {
+	file, err := h.fileService.GetFile(r.Context(), chi.URLParam(r, "fileID"))
+	if err != nil {
+		return h.fileError(err)
+	}
+	response.JSON(w, r, http.StatusOK, file)
+	return nil
+}
+
+// DownloadFile godoc
+// @Summary      Download file contents
+// @Description  Streams a completed file. Supports byte ranges (206 Partial Content), If-Range and conditional requests so downloads can be resumed and media seeked.
+// @Tags         files
+// @Produce      octet-stream
+// @Param        fileID path string true "File ID"
+// @Param        Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success      200
+// @Success      206
+// @Failure      404 {object} map[string]interface{}
+// @Failure      409 {object} map[string]interface{}
+// @Failure      416
+// @Router       /api/v1/files/{fileID}/content [get]
+func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) error {
+	content, file, err := h.fileService.Open(r.Context(), chi.URLParam(r, "fileID"))
+	if err != nil {
+		if errors.Is(err, services.ErrUploadIncomplete) {
+			return httpabort.New(http.StatusConflict, "upload_incomplete", "File upload is not complete")
+		}
+		return h.fileError(err)
+	}
+	defer content.Close()
+
+	// Completed files never change, so the ID is a strong validator for If-Range
+	w.Header().Set("ETag", `"`+file.ID+`"`)
+	if file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	}
+	disposition := "inline"
+	if file.Name != "" {
+		disposition = mime.FormatMediaType("inline", map[string]string{"filename": file.Name})
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent sets Accept-Ranges and Content-Length and answers Range,
+	// If-Range and the other conditional headers.
+	http.ServeContent(w, r, file.Name, file.UpdatedAt, content)
+	return nil
+}
+
+// CreateSignedURL godoc
+// @Summary      Mint a signed download URL
+// @Description  Returns a temporary /files/{fileID} URL that downloads the file without auth headers. expires_in defaults to 15 minutes.
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        fileID path string true "File ID"
+// @Param        request body SignedURLRequest false "URL lifetime"
+// @Success      201 {object} SignedURLResponse
+// @Failure      400 {object} map[string]interface{}
+// @Failure      404 {object} map[string]interface{}
+// @Failure      409 {object} map[string]interface{}
+// @Router       /api/v1/files/{fileID}/signed-url [post]
+func (h *FileHandler) CreateSignedURL(w http.ResponseWriter, r *http.Request) error {
+	var req SignedURLRequest
+	if hasRequestBody(r) {
+		errs, err := validate.BindAndValidate(r, &req)
+		if err != nil {
+			return bindError(err)
+		}
+		if errs != nil {
+			return errs
+		}
+	}
+	ttl := defaultSignedURLTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > h.signer.MaxTTL() {
+		maxTTL := int(h.signer.MaxTTL().Seconds())
+		return validate.Errors{{
+			Pointer: validate.Pointer("expires_in"),
+			Field:   "expires_in",
+			Rule:    "max",
+			Params:  map[string]any{"max": maxTTL},
+			Value:   req.ExpiresIn,
+			Message: "must be at most " + strconv.Itoa(maxTTL),
+		}}
+	}
+
+	file, err := h.fileService.GetFile(r.Context(), chi.URLParam(r, "fileID"))
+	if err != nil {
+		return h.fileError(err)
+	}
+	if !file.Complete {
+		return httpabort.New(http.StatusConflict, "upload_incomplete", "File upload is not complete")
+	}
+
+	expires := time.Now().Add(ttl)
+	response.JSON(w, r, http.StatusCreated, SignedURLResponse{
+		URL:       h.signer.Sign("/files/"+file.ID, expires),
+		ExpiresAt: expires.UTC(),
+	})
+	return nil
+}
+
+// DeleteFile godoc
+// @Summary      Delete a file
+// @Description  Deletes a file or terminates an incomplete upload
+// @Tags         files
+// @Param        fileID path string true "File ID"
+// @Success      204
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/files/{fileID} [delete]
+func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) error {
+	fileID := chi.URLParam(r, "fileID")
+	if err := h.fileService.DeleteFile(r.Context(), fileID); err != nil {
+		return h.fileError(err)
+	}
+	h.logger.Info("file deleted", slog.String("file_id", fileID))
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
+
+// fileError maps a file service error to its response.
+func (h *FileHandler) fileError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		return httpabort.New(http.StatusNotFound, "not_found", "File not found")
+	case errors.Is(err, services.ErrUploadExpired):
+		return httpabort.New(http.StatusGone, "upload_expired", "Upload expired before it was completed")
+	case errors.Is(err, services.ErrOffsetMismatch):
+		return httpabort.New(http.StatusConflict, "offset_mismatch", "Upload-Offset does not match the current offset")
+	case errors.Is(err, services.ErrUploadComplete):
+		return httpabort.New(http.StatusConflict, "upload_complete", "Upload is already complete")
+	case errors.Is(err, services.ErrUploadLocked):
+		return httpabort.New(http.StatusLocked, "upload_locked", "Another chunk for this upload is in progress")
+	default:
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "File operation failed")
+	}
+}
+
+func hasRequestBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+func isUploadStateError(err error) bool {
+	return errors.Is(err, services.ErrFileNotFound) ||
+		errors.Is(err, services.ErrUploadExpired) ||
+		errors.Is(err, services.ErrOffsetMismatch) ||
+		errors.Is(err, services.ErrUploadComplete) ||
+		errors.Is(err, services.ErrUploadLocked)
+}
+
+func setUploadHeaders(w http.ResponseWriter, f *services.File) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(f.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(f.Size, 10))
+	if f.ExpiresAt != nil {
+		w.Header().Set("Upload-Expires", f.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header: comma separated
+// "key base64value" pairs, where the value may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+	if len(header) > maxUploadMetaBytes {
+		return nil, errors.New("metadata too large")
+	}
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		meta[key] = string(decoded)
+	}
+	return meta, nil
+}
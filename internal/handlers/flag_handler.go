@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/featureflags"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+type FlagHandler struct {
+	flags  *featureflags.Client
+	logger *slog.Logger
+}
+
+func NewFlagHandler(flags *featureflags.Client, logger *slog.Logger) *FlagHandler {
+	return &FlagHandler{
+		flags:  flags,
+		logger: logger,
+	}
+}
+
+// FlagResponse is the evaluation of one flag for the caller.
+type FlagResponse struct {
+	Flag     string `json:"flag"`
+	Value    any    `json:"value"`
+	Provider string `json:"provider"`
+	featureflags.Detail
+}
+
+// GetFlag godoc
+// @Summary      Evaluate a feature flag
+// @Description  Evaluates a flag for the caller's context (API key, country, client class) so clients can
+// @Description  toggle features consistently with the server. Unknown flags return 404.
+// @Tags         flags
+// @Produce      json
+// @Param        flag path string true "Flag key"
+// @Success      200 {object} FlagResponse
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/flags/{flag} [get]
+func (h *FlagHandler) GetFlag(w http.ResponseWriter, r *http.Request) error {
+	flag := chi.URLParam(r, "flag")
+	res := h.flags.Object(r.Context(), flag, nil)
+	if res.ErrorCode == featureflags.ErrorFlagNotFound {
+		return httpabort.New(http.StatusNotFound, "not_found", "Flag not found")
+	}
+	if res.ErrorCode != "" {
+		h.logger.Warn("flag evaluation failed", slog.String("flag", flag), slog.String("error_code", string(res.ErrorCode)))
+	}
+	response.JSON(w, r, http.StatusOK, FlagResponse{
+		Flag:     flag,
+		Value:    res.Value,
+		Provider: h.flags.Provider().Metadata().Name,
+		Detail:   res.Detail,
+	})
+	return nil
+}
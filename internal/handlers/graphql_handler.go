@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	gqlgen "github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/graphql"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// GraphQLHandler serves GraphQL queries over the user and stats services.
+type GraphQLHandler struct {
+	resolver *graphql.Resolver
+	exec     *executor.Executor
+	logger   *slog.Logger
+}
+
+// GraphQLLimits bound the queries GraphQLHandler runs; zero values keep the
+// graphql package's defaults.
+type GraphQLLimits struct {
+	MaxDepth      int
+	MaxSelections int
+}
+
+// GraphQLRequest is a GraphQL request.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+func NewGraphQLHandler(userService services.UserService, statsService services.StatsService, limits GraphQLLimits, logger *slog.Logger) *GraphQLHandler {
+	resolver := &graphql.Resolver{UserService: userService, StatsService: statsService}
+	return &GraphQLHandler{
+		resolver: resolver,
+		exec: graphql.NewExecutor(resolver, graphql.Options{
+			MaxDepth:      limits.MaxDepth,
+			MaxSelections: limits.MaxSelections,
+			PresentError:  presentGraphQLError,
+		}),
+		logger: logger,
+	}
+}
+
+// Query godoc
+// @Summary      Run a GraphQL query
+// @Description  Runs the query of a GraphQL request (query, operationName, variables) against the schema served at
+// @Description  /graphql/schema. GET takes them as query parameters, variables as JSON. Field errors are returned
+// @Description  next to the data with 200; a query that does not parse or validate is answered with 400 and
+// @Description  errors alone. Only queries are supported.
+// @Tags         graphql
+// @Accept       json
+// @Produce      json
+// @Param        request body GraphQLRequest false "GraphQL request (POST)"
+// @Param        query query string false "Query (GET)"
+// @Param        operationName query string false "Operation to run (GET)"
+// @Param        variables query string false "Variables as a JSON object (GET)"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]interface{}
+// @Router       /graphql [post]
+// @Router       /graphql [get]
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) error {
+	start := gqlgen.Now()
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := decodeGraphQLJSON([]byte(v), &req.Variables); err != nil {
+				return h.badRequest(w, r, "variables must be a JSON object")
+			}
+		}
+	} else {
+		var body bytes.Buffer
+		if _, err := body.ReadFrom(r.Body); err != nil {
+			return bindError(err)
+		}
+		if err := decodeGraphQLJSON(body.Bytes(), &req); err != nil {
+			return h.badRequest(w, r, "The body must be a JSON object with a query")
+		}
+	}
+	if req.Query == "" {
+		return h.badRequest(w, r, "Missing query")
+	}
+
+	ctx := h.resolver.WithLoaders(gqlgen.StartOperationTrace(r.Context()))
+	oc, errs := h.exec.CreateOperationContext(ctx, &gqlgen.RawParams{
+		Query:         req.Query,
+		OperationName: req.OperationName,
+		Variables:     req.Variables,
+		ReadTime:      gqlgen.TraceTiming{Start: start, End: gqlgen.Now()},
+	})
+	if errs != nil {
+		// Requests that do not parse or validate have errors alone
+		writeGraphQL(w, r, http.StatusBadRequest, graphQLErrors{Errors: errs})
+		return nil
+	}
+	handle, ctx := h.exec.DispatchOperation(ctx, oc)
+	writeGraphQL(w, r, http.StatusOK, handle(ctx))
+	return nil
+}
+
+// Schema godoc
+// @Summary      Get the GraphQL schema
+// @Description  The schema of /graphql in the GraphQL schema definition language. It does not answer
+// @Description  introspection queries; generate clients from this document instead.
+// @Tags         graphql
+// @Produce      plain
+// @Success      200 {string} string
+// @Router       /graphql/schema [get]
+func (h *GraphQLHandler) Schema(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(graphql.SDL))
+	return nil
+}
+
+// badRequest answers a request that is not a GraphQL request in the GraphQL
+// error format, which GraphQL clients read.
+func (h *GraphQLHandler) badRequest(w http.ResponseWriter, r *http.Request, message string) error {
+	writeGraphQL(w, r, http.StatusBadRequest, graphQLErrors{Errors: gqlerror.List{{Message: message, Extensions: map[string]any{"code": "invalid_request"}}}})
+	return nil
+}
+
+type graphQLErrors struct {
+	Errors gqlerror.List `json:"errors"`
+}
+
+// writeGraphQL writes v as gqlgen marshals it: GraphQL names its fields
+// itself, so JSON_FIELD_NAMES does not apply.
+func writeGraphQL(w http.ResponseWriter, r *http.Request, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("encode graphql response failed", slog.String("error", err.Error()))
+		b = []byte(`{"errors":[{"message":"Internal server error","extensions":{"code":"internal_error"}}]}`)
+	}
+	response.JSON(w, r, status, json.RawMessage(b))
+}
+
+// decodeGraphQLJSON decodes data into dst keeping numbers as json.Number, so
+// large integer variables keep their precision.
+func decodeGraphQLJSON(data []byte, dst any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(dst)
+}
+
+// presentGraphQLError shows resolver errors the way the REST endpoints do:
+// mapped service errors and client errors with their code and message,
+// anything else as an internal error, logged. Errors gqlgen or a resolver
+// made for clients are shown as they are.
+func presentGraphQLError(ctx context.Context, err error) *gqlerror.Error {
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		gqlErr = gqlerror.WrapPath(gqlgen.GetPath(ctx), err)
+	}
+	cause := gqlErr.Unwrap()
+	if cause == nil {
+		return gqlErr
+	}
+	if m, ok := errmap.Lookup(cause); ok && m.Status < http.StatusInternalServerError {
+		gqlErr.Message, gqlErr.Extensions = m.Message, map[string]any{"code": m.Code}
+		return gqlErr
+	}
+	var apiErr *apierrors.APIError
+	if errors.As(cause, &apiErr) && apiErr.Status < http.StatusInternalServerError {
+		gqlErr.Message, gqlErr.Extensions = apiErr.Message, map[string]any{"code": apiErr.Code}
+		return gqlErr
+	}
+	logger.FromContext(ctx).Error("graphql field failed", slog.String("error", cause.Error()))
+	gqlErr.Message, gqlErr.Extensions = "Internal server error", map[string]any{"code": "internal_error"}
+	return gqlErr
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// HandlerFunc is a handler that returns its failure instead of writing it.
+// response.FromError answers a returned error, so every failure is rendered
+// and logged in one place, with the request's logger: APIErrors (httpabort's
+// too) and errors registered with errmap with their status and code,
+// validate.Errors as a 400 listing the violations, anything else as a logged
+// 500. Handlers therefore neither log nor write their errors; to keep the
+// cause of a 500 in the log, return httpabort.Wrap(err, ...). Each returned
+// error is counted in api_handler_errors_total.
+//
+// A handler returns an error only before it has written a response; one
+// returned afterwards is logged, since the client can no longer be told.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := f(w, r)
+	if err == nil {
+		return
+	}
+	ww := respwriter.Wrap(w)
+	if ww.Written() {
+		pkglogger.FromContext(r.Context()).Error("handler failed after responding",
+			slog.String("error", err.Error()),
+			slog.Int("status", ww.Status()))
+	} else {
+		response.FromError(ww, r, err)
+	}
+	metrics.ObserveHandlerError(r, ww.Status(), errorKind(err))
+}
+
+// errorKind classifies err the way response.FromError answers it.
+func errorKind(err error) string {
+	var (
+		verrs      validate.Errors
+		exceeded   *quota.ExceededError
+		overloaded *jobs.OverloadedError
+		apiErr     *apierrors.APIError
+	)
+	if errors.As(err, &verrs) {
+		return "validation"
+	}
+	if _, ok := validate.TooLarge(err); ok || errors.As(err, &exceeded) || errors.As(err, &overloaded) {
+		return "refused"
+	}
+	if errors.As(err, &apiErr) {
+		return "abort"
+	}
+	if _, ok := errmap.Lookup(err); ok {
+		return "mapped"
+	}
+	return "unexpected"
+}
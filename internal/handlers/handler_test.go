@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+func TestHandlerFunc_RendersReturnedErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{httpabort.New(http.StatusUnprocessableEntity, "task_archived", "Archived tasks cannot change"), http.StatusUnprocessableEntity, "task_archived"},
+		{services.ErrTaskNotFound, http.StatusNotFound, "not_found"},
+		{errors.New("disk on fire"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tc := range cases {
+		h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return tc.err })
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != tc.status || !strings.Contains(rr.Body.String(), `"`+tc.code+`"`) {
+			t.Errorf("%v: got %d %s, want %d %q", tc.err, rr.Code, rr.Body.String(), tc.status, tc.code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Fatalf("expected the handler's own response, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandlerFunc_LogsOnceWithRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	serve := func(h HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(pkglogger.IntoContext(req.Context(), log))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(respwriter.Wrap(rr), req)
+		return rr
+	}
+
+	rr := serve(func(w http.ResponseWriter, r *http.Request) error {
+		return httpabort.Wrap(errors.New("disk on fire"), http.StatusInternalServerError, "internal_error", "Failed to save")
+	})
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "disk on fire") {
+		t.Fatalf("expected a 500 without the cause, got %d %s", rr.Code, rr.Body.String())
+	}
+	if n := strings.Count(buf.String(), "disk on fire"); n != 1 {
+		t.Fatalf("expected the cause logged once, got %d in %s", n, buf.String())
+	}
+
+	buf.Reset()
+	rr = serve(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("stream broke")
+	})
+	if rr.Code != http.StatusAccepted || !strings.Contains(buf.String(), "handler failed after responding") {
+		t.Fatalf("expected the late error logged and the response kept, got %d %s", rr.Code, buf.String())
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
-	"github.com/mikko-kohtala/go-api/internal/response"
 	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/shutdown"
 )
 
 // Health godoc
@@ -11,17 +13,25 @@ import (
 // @Tags         health
 // @Success      200 {object} map[string]string
 // @Router       /healthz [get]
-func Health(w http.ResponseWriter, r *http.Request) {
+func Health(w http.ResponseWriter, r *http.Request) error {
 	response.JSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+	return nil
 }
 
 // Ready godoc
 // @Summary      Readiness probe
-// @Description  Indicates whether the service is ready to accept traffic.
+// @Description  Indicates whether the service is ready to accept traffic; 503 while it drains before shutting down.
 // @Tags         health
 // @Success      200 {object} map[string]string
+// @Failure      503 {object} map[string]string
 // @Router       /readyz [get]
-func Ready(w http.ResponseWriter, r *http.Request) {
+func Ready(w http.ResponseWriter, r *http.Request) error {
+	if shutdown.Draining() {
+		w.Header().Set("Connection", "close")
+		response.JSON(w, r, http.StatusServiceUnavailable, map[string]string{"ready": "false", "reason": "draining"})
+		return nil
+	}
 	// In a real app, check dependencies (DB, cache, etc.)
 	response.JSON(w, r, http.StatusOK, map[string]string{"ready": "true"})
+	return nil
 }
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/shutdown"
+)
+
+func TestReady_FailsWhileDraining(t *testing.T) {
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		_ = Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	shutdown.SetDraining(true)
+	defer shutdown.SetDraining(false)
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", code)
+	}
+}
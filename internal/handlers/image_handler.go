@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/imaging"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+// imageQueueWait bounds how long a request waits for a free transform slot.
+const imageQueueWait = 2 * time.Second
+
+type ImageHandler struct {
+	fileService services.FileService
+	processor   *imaging.Processor
+	logger      *slog.Logger
+}
+
+func NewImageHandler(fileService services.FileService, processor *imaging.Processor, logger *slog.Logger) *ImageHandler {
+	return &ImageHandler{
+		fileService: fileService,
+		processor:   processor,
+		logger:      logger,
+	}
+}
+
+// GetImage godoc
+// @Summary      Resize, crop or convert a stored image
+// @Description  Transforms an uploaded JPEG, PNG or GIF on the fly. Variants are cached; when all transform slots are busy the request fails with 503.
+// @Tags         images
+// @Produce      image/jpeg
+// @Produce      image/png
+// @Produce      image/gif
+// @Param        fileID path string true "File ID of the source image"
+// @Param        w query int false "Width in pixels"
+// @Param        h query int false "Height in pixels"
+// @Param        fit query string false "contain (default) or cover" Enums(contain, cover)
+// @Param        fmt query string false "Output format; defaults to the source format" Enums(jpeg, png, gif)
+// @Param        q query int false "JPEG quality (1-100)"
+// @Success      200
+// @Failure      400 {object} map[string]interface{}
+// @Failure      404 {object} map[string]interface{}
+// @Failure      413 {object} map[string]interface{}
+// @Failure      415 {object} map[string]interface{}
+// @Failure      503 {object} map[string]interface{}
+// @Router       /api/v1/images/{fileID} [get]
+func (h *ImageHandler) GetImage(w http.ResponseWriter, r *http.Request) error {
+	opts, err := parseImageOptions(r)
+	if err == nil {
+		opts, err = opts.Normalize(h.processor.Limits())
+	}
+	if err != nil {
+		return h.imageError(w, err)
+	}
+
+	fileID := chi.URLParam(r, "fileID")
+	file, err := h.fileService.GetFile(r.Context(), fileID)
+	if err != nil {
+		return h.imageError(w, err)
+	}
+
+	etag := `"` + file.ID + "-" + opts.Key() + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		response.NoBody(w, r, http.StatusNotModified)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), imageQueueWait)
+	defer cancel()
+	variant, hit, err := h.processor.Process(ctx, file.ID, opts, func() (io.ReadSeekCloser, error) {
+		content, _, err := h.fileService.Open(r.Context(), file.ID)
+		return content, err
+	})
+	if err != nil {
+		return h.imageError(w, err)
+	}
+
+	cacheStatus := "MISS"
+	if hit {
+		cacheStatus = "HIT"
+	}
+	w.Header().Set("Content-Type", variant.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(variant.Data)))
+	response.Cacheable(w, 24*time.Hour)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Cache", cacheStatus)
+	response.Bytes(w, r, http.StatusOK, variant.Data)
+	return nil
+}
+
+func parseImageOptions(r *http.Request) (imaging.Options, error) {
+	q := r.URL.Query()
+	opts := imaging.Options{Fit: q.Get("fit"), Format: q.Get("fmt")}
+	for name, dst := range map[string]*int{"w": &opts.Width, "h": &opts.Height, "q": &opts.Quality} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("%w: %s must be an integer", imaging.ErrInvalidOptions, name)
+			}
+			*dst = n
+		}
+	}
+	return opts, nil
+}
+
+// imageError maps an imaging or file service error to its response.
+func (h *ImageHandler) imageError(w http.ResponseWriter, err error) error {
+	switch {
+	case errors.Is(err, imaging.ErrInvalidOptions):
+		return httpabort.New(http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return httpabort.New(http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error())
+	case errors.Is(err, imaging.ErrTooLarge):
+		return httpabort.New(http.StatusRequestEntityTooLarge, "image_too_large", "Source image exceeds the processing limits")
+	case errors.Is(err, imaging.ErrBusy):
+		w.Header().Set("Retry-After", "1")
+		return httpabort.New(http.StatusServiceUnavailable, "busy", "Image processing is at capacity, retry shortly")
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrUploadExpired):
+		return httpabort.New(http.StatusNotFound, "not_found", "Image not found")
+	case errors.Is(err, services.ErrUploadIncomplete):
+		return httpabort.New(http.StatusConflict, "upload_incomplete", "File upload is not complete")
+	default:
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Image processing failed")
+	}
+}
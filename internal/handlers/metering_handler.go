@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/metering"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+type MeteringHandler struct {
+	aggregator *metering.Aggregator
+	logger     *slog.Logger
+}
+
+func NewMeteringHandler(aggregator *metering.Aggregator, logger *slog.Logger) *MeteringHandler {
+	return &MeteringHandler{
+		aggregator: aggregator,
+		logger:     logger,
+	}
+}
+
+// MeteringExport is the body of GET /admin/metering.
+type MeteringExport struct {
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	Rollups []metering.Rollup `json:"rollups"`
+}
+
+// GetRollups godoc
+// @Summary      Export hourly metering rollups
+// @Description  Returns billable usage per hour, API key and event type (request.served, storage.bytes,
+// @Description  job.executed) for hours starting in [from, to). Defaults to the last 24 hours. Billing exports
+// @Description  should only take rollups marked complete.
+// @Tags         admin
+// @Produce      json
+// @Param        from query string false "Start (RFC 3339)"
+// @Param        to   query string false "End (RFC 3339)"
+// @Success      200 {object} MeteringExport
+// @Failure      400 {object} map[string]interface{}
+// @Router       /admin/metering [get]
+func (h *MeteringHandler) GetRollups(w http.ResponseWriter, r *http.Request) error {
+	now := time.Now().UTC()
+	from, to := now.Add(-24*time.Hour), now
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return httpabort.New(http.StatusBadRequest, "invalid_request", "from must be an RFC 3339 time")
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return httpabort.New(http.StatusBadRequest, "invalid_request", "to must be an RFC 3339 time")
+		}
+	}
+
+	response.JSON(w, r, http.StatusOK, MeteringExport{
+		From:    from,
+		To:      to,
+		Rollups: h.aggregator.Rollups(from, to),
+	})
+	return nil
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+type NotificationHandler struct {
+	prefs       notify.PreferenceStore
+	userService services.UserService
+	logger      *slog.Logger
+}
+
+func NewNotificationHandler(prefs notify.PreferenceStore, userService services.UserService, logger *slog.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		prefs:       prefs,
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+// GetPreferences godoc
+// @Summary      Get notification preferences
+// @Description  Returns which channels (email, sms, push, slack) the user receives notifications on
+// @Tags         users
+// @Produce      json
+// @Param        userID path string true "User ID"
+// @Success      200 {object} map[string]bool
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/users/{userID}/notification-preferences [get]
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.requireUser(r)
+	if err != nil {
+		return err
+	}
+	prefs, err := h.prefs.Get(r.Context(), userID)
+	if err != nil {
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Failed to load preferences")
+	}
+	response.JSON(w, r, http.StatusOK, prefs)
+	return nil
+}
+
+// UpdatePreferences godoc
+// @Summary      Update notification preferences
+// @Description  Switches channels on or off; channels not included are left unchanged
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        userID path string true "User ID"
+// @Param        request body map[string]bool true "Channel switches, e.g. {\"sms\": true}"
+// @Success      200 {object} map[string]bool
+// @Failure      400 {object} map[string]interface{}
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/users/{userID}/notification-preferences [put]
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.requireUser(r)
+	if err != nil {
+		return err
+	}
+	var req map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return bindError(err)
+	}
+	prefs := notify.Preferences{}
+	var errs validate.Errors
+	for name, on := range req {
+		c := notify.Channel(name)
+		if !isChannel(c) {
+			errs = append(errs, validate.Violation{Pointer: validate.Pointer(name), Field: name, Rule: "channel", Message: "unknown channel"})
+			continue
+		}
+		prefs[c] = on
+	}
+	if errs != nil {
+		slices.SortFunc(errs, func(a, b validate.Violation) int { return strings.Compare(a.Pointer, b.Pointer) })
+		return errs
+	}
+
+	if err := h.prefs.Set(r.Context(), userID, prefs); err != nil {
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Failed to save preferences")
+	}
+	return h.GetPreferences(w, r)
+}
+
+// requireUser resolves the userID path parameter, answering 404 for unknown users.
+func (h *NotificationHandler) requireUser(r *http.Request) (string, error) {
+	userID := chi.URLParam(r, "userID")
+	if _, err := h.userService.GetUserByID(r.Context(), userID); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) || errors.Is(err, services.ErrInvalidUserID) {
+			return "", httpabort.New(http.StatusNotFound, "not_found", "User not found")
+		}
+		return "", httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Failed to retrieve user")
+	}
+	return userID, nil
+}
+
+func isChannel(c notify.Channel) bool {
+	for _, known := range notify.Channels {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
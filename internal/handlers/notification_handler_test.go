@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+func testNotificationRouter() http.Handler {
+	h := NewNotificationHandler(notify.NewMemoryPreferences(), services.NewUserService(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := chi.NewRouter()
+	r.Get("/users/{userID}/notification-preferences", HandlerFunc(h.GetPreferences).ServeHTTP)
+	r.Put("/users/{userID}/notification-preferences", HandlerFunc(h.UpdatePreferences).ServeHTTP)
+	return r
+}
+
+func TestNotificationHandler_Preferences(t *testing.T) {
+	r := testNotificationRouter()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/usr_001/notification-preferences", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"email":true`) || !strings.Contains(rr.Body.String(), `"sms":false`) {
+		t.Fatalf("unexpected default preferences %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/users/usr_001/notification-preferences", bytes.NewBufferString(`{"sms":true,"email":false}`)))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"sms":true`) || !strings.Contains(rr.Body.String(), `"email":false`) {
+		t.Fatalf("unexpected updated preferences %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/users/usr_001/notification-preferences", bytes.NewBufferString(`{"fax":true}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown channel, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/usr_999/notification-preferences", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown user, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// operationPollInterval is the Retry-After suggested while an operation runs.
+const operationPollInterval = "1"
+
+type OperationHandler struct {
+	ops    *operations.Store
+	logger *slog.Logger
+}
+
+func NewOperationHandler(ops *operations.Store, logger *slog.Logger) *OperationHandler {
+	return &OperationHandler{
+		ops:    ops,
+		logger: logger,
+	}
+}
+
+// GetOperation godoc
+// @Summary      Get an operation
+// @Description  Status, progress and, once completed, the result of a long-running operation started by
+// @Description  another endpoint's 202 response. Poll the Location of that response until status is
+// @Description  completed or failed; Retry-After suggests how long to wait in between.
+// @Tags         operations
+// @Produce      json
+// @Param        operationID path string true "Operation ID"
+// @Success      200 {object} operations.Operation
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/operations/{operationID} [get]
+func (h *OperationHandler) GetOperation(w http.ResponseWriter, r *http.Request) error {
+	op, err := h.ops.Get(chi.URLParam(r, "operationID"))
+	if err != nil {
+		return err
+	}
+	if !op.Done() {
+		w.Header().Set("Retry-After", operationPollInterval)
+	}
+	response.JSON(w, r, http.StatusOK, op)
+	return nil
+}
+
+// preferAsync reports whether the client asked for the work to run in the
+// background with Prefer: respond-async (RFC 7240).
+func preferAsync(r *http.Request) bool {
+	for _, v := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// operationLocation is where the operation with id is polled.
+func operationLocation(id string) string {
+	return "/api/v1/operations/" + id
+}
+
+// writeOperation answers a request that started op with 202, the operation
+// and its Location.
+func writeOperation(w http.ResponseWriter, r *http.Request, op operations.Operation) {
+	w.Header().Set("Location", operationLocation(op.ID))
+	w.Header().Set("Retry-After", operationPollInterval)
+	response.JSON(w, r, http.StatusAccepted, op)
+}
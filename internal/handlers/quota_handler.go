@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+type QuotaHandler struct {
+	quotas *quota.Manager
+	logger *slog.Logger
+}
+
+func NewQuotaHandler(quotas *quota.Manager, logger *slog.Logger) *QuotaHandler {
+	return &QuotaHandler{
+		quotas: quotas,
+		logger: logger,
+	}
+}
+
+// GetQuota godoc
+// @Summary      Get quota status for a user
+// @Description  Returns the limits and current usage of a user, charged for requests with verified credentials.
+// @Tags         admin
+// @Produce      json
+// @Param        key path string true "User ID"
+// @Success      200 {object} quota.Status
+// @Router       /admin/quotas/{key} [get]
+func (h *QuotaHandler) GetQuota(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, h.quotas.Status(chi.URLParam(r, "key")))
+	return nil
+}
+
+// SetQuota godoc
+// @Summary      Override quotas for a user
+// @Description  Replaces the default limits for a user; 0 means unlimited.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        key path string true "User ID"
+// @Param        limits body quota.Limits true "Limits"
+// @Success      200 {object} quota.Status
+// @Failure      400 {object} map[string]interface{}
+// @Router       /admin/quotas/{key} [put]
+func (h *QuotaHandler) SetQuota(w http.ResponseWriter, r *http.Request) error {
+	var req quota.Limits
+	errs, err := validate.BindAndValidate(r, &req)
+	if err != nil {
+		return bindError(err)
+	}
+	if errs != nil {
+		return errs
+	}
+
+	key := chi.URLParam(r, "key")
+	h.quotas.SetLimits(key, req)
+	h.logger.Info("quota limits updated", slog.String("key", key))
+	response.JSON(w, r, http.StatusOK, h.quotas.Status(key))
+	return nil
+}
+
+// ResetQuota godoc
+// @Summary      Restore default quotas for a user
+// @Tags         admin
+// @Param        key path string true "User ID"
+// @Success      204 "No Content"
+// @Router       /admin/quotas/{key} [delete]
+func (h *QuotaHandler) ResetQuota(w http.ResponseWriter, r *http.Request) error {
+	key := chi.URLParam(r, "key")
+	h.quotas.ResetLimits(key)
+	h.logger.Info("quota limits reset", slog.String("key", key))
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
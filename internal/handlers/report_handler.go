@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+type ReportHandler struct {
+	reportService services.ReportService
+	logger        *slog.Logger
+}
+
+func NewReportHandler(reportService services.ReportService, logger *slog.Logger) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+		logger:        logger,
+	}
+}
+
+type CreateReportRequest struct {
+	Type   string `json:"type" validate:"required,oneof=users stats"`
+	Format string `json:"format" validate:"required,oneof=csv pdf"`
+}
+
+// ReportResponse is a report with a download link once it has completed.
+type ReportResponse struct {
+	services.Report
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func newReportResponse(r *services.Report) ReportResponse {
+	resp := ReportResponse{Report: *r}
+	if r.Status == services.ReportCompleted {
+		resp.DownloadURL = "/api/v1/files/" + r.FileID + "/content"
+	}
+	return resp
+}
+
+// CreateReport godoc
+// @Summary      Generate a report
+// @Description  Queues generation of a users or stats report as CSV or PDF. Poll the returned Location until status is completed.
+// @Description  The generation is also the operation in operation_id, linked with rel="monitor".
+// @Tags         reports
+// @Accept       json
+// @Produce      json
+// @Param        request body CreateReportRequest true "Report type and format"
+// @Success      202 {object} ReportResponse
+// @Failure      400 {object} map[string]interface{}
+// @Failure      429 {object} map[string]interface{}
+// @Failure      503 {object} map[string]interface{}
+// @Router       /api/v1/reports [post]
+func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) error {
+	var req CreateReportRequest
+	errs, err := validate.BindAndValidate(r, &req)
+	if err != nil {
+		return bindError(err)
+	}
+	if errs != nil {
+		return errs
+	}
+
+	report, err := h.reportService.CreateReport(r.Context(), req.Type, req.Format)
+	if err != nil {
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Failed to queue report")
+	}
+
+	h.logger.Info("report queued", slog.String("report_id", report.ID), slog.String("type", report.Type), slog.String("format", report.Format))
+	w.Header().Set("Location", "/api/v1/reports/"+report.ID)
+	w.Header().Set("Link", "<"+operationLocation(report.OperationID)+`>; rel="monitor"`)
+	response.JSON(w, r, http.StatusAccepted, newReportResponse(report))
+	return nil
+}
+
+// GetReport godoc
+// @Summary      Get report status
+// @Description  Returns the report's status and, once completed, a download_url for the artifact
+// @Tags         reports
+// @Produce      json
+// @Param        reportID path string true "Report ID"
+// @Success      200 {object} ReportResponse
+// @Failure      404 {object} map[string]interface{}
+// @Router       /api/v1/reports/{reportID} [get]
+func (h *ReportHandler) GetReport(w http.ResponseWriter, r *http.Request) error {
+	report, err := h.reportService.GetReport(r.Context(), chi.URLParam(r, "reportID"))
+	if err != nil {
+		if errors.Is(err, services.ErrReportNotFound) {
+			return httpabort.New(http.StatusNotFound, "not_found", "Report not found")
+		}
+		return httpabort.Wrap(err, http.StatusInternalServerError, "internal_error", "Failed to retrieve report")
+	}
+	response.JSON(w, r, http.StatusOK, newReportResponse(report))
+	return nil
+}
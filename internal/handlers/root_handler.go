@@ -10,10 +10,21 @@ import (
 type RootResponse struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
-	Docs    string `json:"docs"`
+	Docs    string `json:"docs,omitempty"` // absent when the docs are not served
 	Status  string `json:"status"`
 }
 
+// RootHandler serves the API index.
+type RootHandler struct {
+	docs string
+}
+
+// NewRootHandler creates a RootHandler linking to the API docs at docs, or
+// to none when docs is empty.
+func NewRootHandler(docs string) *RootHandler {
+	return &RootHandler{docs: docs}
+}
+
 // Root godoc
 // @Summary      API root endpoint
 // @Description  Returns basic API information
@@ -21,7 +32,7 @@ type RootResponse struct {
 // @Produce      json
 // @Success      200 {object} RootResponse
 // @Router       / [get]
-func Root(w http.ResponseWriter, r *http.Request) {
+func (h *RootHandler) Root(w http.ResponseWriter, r *http.Request) error {
 	// Get logger from context
 	if l := pkglogger.FromContext(r.Context()); l != nil {
 		l.Info("Root endpoint accessed")
@@ -31,9 +42,10 @@ func Root(w http.ResponseWriter, r *http.Request) {
 	resp := RootResponse{
 		Name:    "go-api",
 		Version: "1.0.0",
-		Docs:    "/swagger/index.html",
+		Docs:    h.docs,
 		Status:  "healthy",
 	}
 
 	response.JSON(w, r, http.StatusOK, resp)
+	return nil
 }
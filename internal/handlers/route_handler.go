@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+// RouteHandler lists the declared routes to operators.
+type RouteHandler struct {
+	table  *routemeta.Table
+	logger *slog.Logger
+}
+
+func NewRouteHandler(table *routemeta.Table, logger *slog.Logger) *RouteHandler {
+	return &RouteHandler{
+		table:  table,
+		logger: logger,
+	}
+}
+
+// RouteList is the route table.
+type RouteList struct {
+	Routes []routemeta.Route `json:"routes"`
+}
+
+// ListRoutes godoc
+// @Summary      List routes
+// @Description  Admin view: every route served by this instance with its name, description, access, admission class, stability and deprecation.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} RouteList
+// @Router       /admin/routes [get]
+func (h *RouteHandler) ListRoutes(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, RouteList{Routes: h.table.Routes()})
+	return nil
+}
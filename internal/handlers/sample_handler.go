@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/sampling"
+)
+
+// SampleHandler serves the anonymized request/response samples.
+type SampleHandler struct {
+	sampler *sampling.Sampler
+	logger  *slog.Logger
+}
+
+func NewSampleHandler(sampler *sampling.Sampler, logger *slog.Logger) *SampleHandler {
+	return &SampleHandler{
+		sampler: sampler,
+		logger:  logger,
+	}
+}
+
+// SampleReport lists sampled requests.
+type SampleReport struct {
+	Enabled bool              `json:"enabled"`
+	Samples []sampling.Sample `json:"samples"`
+}
+
+// ListSamples godoc
+// @Summary      List sampled requests
+// @Description  Admin view: the latest BODY_SAMPLE_RATE share of requests, BODY_SAMPLE_PER_ROUTE per route, newest
+// @Description  first, with their status and anonymized bodies: JSON keeps its structure, numbers and booleans,
+// @Description  strings show only their shape (letters as x, digits as 9) and secret-looking fields are redacted.
+// @Tags         admin
+// @Produce      json
+// @Param        route query string false "Route pattern, e.g. /api/v1/users/"
+// @Success      200 {object} SampleReport
+// @Router       /admin/samples [get]
+func (h *SampleHandler) ListSamples(w http.ResponseWriter, r *http.Request) error {
+	samples := h.sampler.Recent(r.URL.Query().Get("route"))
+	if samples == nil {
+		samples = []sampling.Sample{}
+	}
+	response.JSON(w, r, http.StatusOK, SampleReport{Enabled: h.sampler.Enabled(), Samples: samples})
+	return nil
+}
+
+// ClearSamples godoc
+// @Summary      Drop sampled requests
+// @Description  Admin action: drops every sample, e.g. after a fix, so the next ones show its effect.
+// @Tags         admin
+// @Success      204
+// @Router       /admin/samples [delete]
+func (h *SampleHandler) ClearSamples(w http.ResponseWriter, r *http.Request) error {
+	h.sampler.Clear()
+	h.logger.Info("request samples cleared")
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
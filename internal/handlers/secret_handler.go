@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/secrets"
+)
+
+// SecretHandler serves the secrets cache, never the secrets themselves.
+type SecretHandler struct {
+	resolver *secrets.Resolver
+	logger   *slog.Logger
+}
+
+func NewSecretHandler(resolver *secrets.Resolver, logger *slog.Logger) *SecretHandler {
+	return &SecretHandler{
+		resolver: resolver,
+		logger:   logger,
+	}
+}
+
+// ListCachedSecrets godoc
+// @Summary      List cached secrets
+// @Description  Admin view: the secret keys resolved since they were last read from SECRETS_SOURCE, whether each
+// @Description  was found and when it was read. Values are never shown.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {array} secrets.Cached
+// @Router       /admin/secrets [get]
+func (h *SecretHandler) ListCachedSecrets(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, h.resolver.Cached())
+	return nil
+}
+
+// RefreshSecrets godoc
+// @Summary      Refresh cached secrets
+// @Description  Admin action: drops the cached values of a secret in every scope, or of every secret without name,
+// @Description  so the next request reads a rotated value before SECRETS_CACHE_TTL passes.
+// @Tags         admin
+// @Param        name query string false "Secret name, e.g. billing_api_key"
+// @Success      204
+// @Router       /admin/secrets [delete]
+func (h *SecretHandler) RefreshSecrets(w http.ResponseWriter, r *http.Request) error {
+	name := r.URL.Query().Get("name")
+	h.resolver.Invalidate(name)
+	h.logger.Info("secrets cache cleared", slog.String("name", name))
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/snapshot"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+// SnapshotHandler exports and imports the state of the in-memory stores.
+type SnapshotHandler struct {
+	registry *snapshot.Registry
+	logger   *slog.Logger
+}
+
+func NewSnapshotHandler(registry *snapshot.Registry, logger *slog.Logger) *SnapshotHandler {
+	return &SnapshotHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// RestoreResult names the stores an import replaced.
+type RestoreResult struct {
+	Restored []string `json:"restored"`
+}
+
+// ExportSnapshot godoc
+// @Summary      Export in-memory state
+// @Description  Admin view: a snapshot of the in-memory stores (users, tasks), in the format SNAPSHOT_FILE holds.
+// @Description  Stores holding secrets, such as the API key hashes, are left out. Requires the admin scope.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} snapshot.Document
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Failure      500 {object} map[string]interface{}
+// @Router       /admin/snapshot [get]
+func (h *SnapshotHandler) ExportSnapshot(w http.ResponseWriter, r *http.Request) error {
+	doc, err := h.registry.ExportPublic()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="snapshot.json"`)
+	response.JSON(w, r, http.StatusOK, doc)
+	return nil
+}
+
+// ImportSnapshot godoc
+// @Summary      Import in-memory state
+// @Description  Replaces the state of every store in the snapshot; stores it leaves out keep theirs.
+// @Description  Nothing is replaced when any store's data is invalid or the snapshot holds a store of secrets,
+// @Description  which are only restored from SNAPSHOT_FILE. Requires the admin scope.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        snapshot body snapshot.Document true "Snapshot from GET /admin/snapshot"
+// @Success      200 {object} RestoreResult
+// @Failure      400 {object} map[string]interface{}
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /admin/snapshot [put]
+func (h *SnapshotHandler) ImportSnapshot(w http.ResponseWriter, r *http.Request) error {
+	var doc snapshot.Document
+	if _, err := validate.BindAndValidate(r, &doc); err != nil {
+		return bindError(err)
+	}
+	restored, err := h.registry.ImportPublic(doc)
+	if err != nil {
+		return httpabort.New(http.StatusBadRequest, "invalid_snapshot", err.Error())
+	}
+	h.logger.Info("snapshot imported", slog.String("stores", strings.Join(restored, ",")))
+	response.JSON(w, r, http.StatusOK, RestoreResult{Restored: restored})
+	return nil
+}
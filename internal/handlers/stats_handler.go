@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/mikko-kohtala/go-api/internal/degradation"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
 	"github.com/mikko-kohtala/go-api/internal/response"
 	"github.com/mikko-kohtala/go-api/internal/services"
 )
@@ -28,15 +33,14 @@ func NewStatsHandler(statsService services.StatsService, logger *slog.Logger) *S
 // @Success      200 {object} services.SystemStats
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/stats/system [get]
-func (h *StatsHandler) GetSystemStats(w http.ResponseWriter, r *http.Request) {
+func (h *StatsHandler) GetSystemStats(w http.ResponseWriter, r *http.Request) error {
 	stats, err := h.statsService.GetSystemStats(r.Context())
 	if err != nil {
-		h.logger.Error("failed to get system stats", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to retrieve system stats", nil)
-		return
+		return err
 	}
 
 	response.JSON(w, r, http.StatusOK, stats)
+	return nil
 }
 
 // GetAPIStats godoc
@@ -47,13 +51,141 @@ func (h *StatsHandler) GetSystemStats(w http.ResponseWriter, r *http.Request) {
 // @Success      200 {object} map[string]interface{}
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/stats/api [get]
-func (h *StatsHandler) GetAPIStats(w http.ResponseWriter, r *http.Request) {
+func (h *StatsHandler) GetAPIStats(w http.ResponseWriter, r *http.Request) error {
 	stats, err := h.statsService.GetAPIStats(r.Context())
 	if err != nil {
-		h.logger.Error("failed to get API stats", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to retrieve API stats", nil)
-		return
+		return err
 	}
 
 	response.JSON(w, r, http.StatusOK, stats)
+	return nil
+}
+
+// StreamStats godoc
+// @Summary      Stream system statistics
+// @Description  Server-sent events: a "stats" event with the system statistics every interval seconds (default 5,
+// @Description  1 to 60), numbered by its id. Clients should send Accept: text/event-stream, as EventSource does;
+// @Description  other requests end with REQUEST_TIMEOUT. A "close" event ends the stream when the server shuts down.
+// @Tags         stats
+// @Produce      text/event-stream
+// @Param        interval query int false "Seconds between events"
+// @Success      200 {object} services.SystemStats
+// @Failure      400 {object} map[string]interface{}
+// @Failure      503 {object} map[string]interface{}
+// @Router       /api/v1/events [get]
+func (h *StatsHandler) StreamStats(w http.ResponseWriter, r *http.Request) error {
+	interval := 5 * time.Second
+	if v := r.URL.Query().Get("interval"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 60 {
+			return httpabort.New(http.StatusBadRequest, "invalid_request", "interval must be between 1 and 60 seconds")
+		}
+		interval = time.Duration(n) * time.Second
+	}
+	response.Stream(w, r, func(ctx context.Context, send func(response.Event) error) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for seq := 1; ; seq++ {
+			stats, err := h.statsService.GetSystemStats(ctx)
+			if err != nil {
+				return err
+			}
+			if err := send(response.Event{ID: strconv.Itoa(seq), Event: "stats", Data: stats}); err != nil {
+				return err
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+// GetDependencyStats godoc
+// @Summary      Get dependency health
+// @Description  The state of every checked dependency and of every feature configured to degrade while one
+// @Description  of its dependencies is down. Degraded features answer writes with 503 dependency_unavailable
+// @Description  and reads from their last successful responses.
+// @Tags         stats
+// @Produce      json
+// @Success      200 {object} degradation.Status
+// @Router       /api/v1/stats/dependencies [get]
+func (h *StatsHandler) GetDependencyStats(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, degradation.Default.Status())
+	return nil
+}
+
+// GetMemStats godoc
+// @Summary      Get runtime memory statistics
+// @Description  Admin view: the full runtime.MemStats, the memory limit, and the last GC pauses (most recent
+// @Description  first) with their quantiles. Reading the statistics briefly stops the world. Requires the admin scope.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} services.MemStatsReport
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /admin/runtime/memstats [get]
+func (h *StatsHandler) GetMemStats(w http.ResponseWriter, r *http.Request) error {
+	report, err := h.statsService.GetMemStats(r.Context())
+	if err != nil {
+		return err
+	}
+	response.JSON(w, r, http.StatusOK, report)
+	return nil
+}
+
+// RunGC godoc
+// @Summary      Force a garbage collection
+// @Description  Admin action: runs a blocking garbage collection and reports the heap before and after.
+// @Description  With free_os_memory=true it also returns as much memory to the OS as possible.
+// @Description  Requires the admin scope.
+// @Tags         admin
+// @Produce      json
+// @Param        free_os_memory query bool false "Also return freed memory to the OS"
+// @Success      200 {object} services.GCResult
+// @Failure      400 {object} map[string]interface{}
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /admin/runtime/gc [post]
+func (h *StatsHandler) RunGC(w http.ResponseWriter, r *http.Request) error {
+	free := false
+	if v := r.URL.Query().Get("free_os_memory"); v != "" {
+		var err error
+		if free, err = strconv.ParseBool(v); err != nil {
+			return httpabort.New(http.StatusBadRequest, "invalid_request", "free_os_memory must be true or false")
+		}
+	}
+	result, err := h.statsService.RunGC(r.Context(), free)
+	if err != nil {
+		return err
+	}
+	h.logger.Info("garbage collection forced",
+		slog.Bool("free_os_memory", free),
+		slog.Duration("duration", result.Duration),
+		slog.Uint64("heap_alloc_before", result.HeapAllocBefore),
+		slog.Uint64("heap_alloc_after", result.HeapAllocAfter))
+	response.JSON(w, r, http.StatusOK, result)
+	return nil
+}
+
+// GetGoroutines godoc
+// @Summary      Dump goroutine stacks
+// @Description  Admin view: the stacks of all goroutines as plain text, in the format of an unrecovered panic.
+// @Description  Requires the admin scope.
+// @Tags         admin
+// @Produce      plain
+// @Success      200 {string} string
+// @Failure      401 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Router       /admin/runtime/goroutines [get]
+func (h *StatsHandler) GetGoroutines(w http.ResponseWriter, r *http.Request) error {
+	dump, err := h.statsService.GoroutineDump(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	response.Bytes(w, r, http.StatusOK, dump)
+	return nil
 }
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/mikko-kohtala/go-api/internal/services"
@@ -15,7 +17,7 @@ func TestStatsHandler_GetSystemStats(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats/system", nil)
-	handler.GetSystemStats(rr, req)
+	HandlerFunc(handler.GetSystemStats).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
@@ -27,9 +29,46 @@ func TestStatsHandler_GetAPIStats(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats/api", nil)
-	handler.GetAPIStats(rr, req)
+	HandlerFunc(handler.GetAPIStats).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
 }
+
+func TestStatsHandler_RunGCAndMemStats(t *testing.T) {
+	handler := NewStatsHandler(services.NewStatsService(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rr := httptest.NewRecorder()
+	HandlerFunc(handler.RunGC).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/runtime/gc?free_os_memory=true", nil))
+	var gc services.GCResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &gc); rr.Code != http.StatusOK || err != nil || gc.NumGC == 0 || !gc.FreedOSMemory {
+		t.Fatalf("expected a completed GC, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	HandlerFunc(handler.GetMemStats).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/runtime/memstats", nil))
+	var report services.MemStatsReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected 200 with a report, got %d %v", rr.Code, err)
+	}
+	if report.MemStats.NumGC < gc.NumGC || len(report.GCPauses) == 0 || len(report.PauseQuantiles) != 5 {
+		t.Fatalf("expected the forced GC in the pause history, got num_gc=%d pauses=%d", report.MemStats.NumGC, len(report.GCPauses))
+	}
+
+	rr = httptest.NewRecorder()
+	HandlerFunc(handler.RunGC).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/runtime/gc?free_os_memory=maybe", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid flag, got %d", rr.Code)
+	}
+}
+
+func TestStatsHandler_GetGoroutines(t *testing.T) {
+	handler := NewStatsHandler(services.NewStatsService(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rr := httptest.NewRecorder()
+	HandlerFunc(handler.GetGoroutines).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/runtime/goroutines", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine ") {
+		t.Fatalf("expected a goroutine dump, got %d %.200s", rr.Code, rr.Body.String())
+	}
+}
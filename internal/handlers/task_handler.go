@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/resource"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+// TaskHandler is the reference resource: the standard CRUD routes come from
+// resource.Register over the TaskService, and actions that are not plain
+// CRUD are ordinary handlers.
+type TaskHandler struct {
+	taskService services.TaskService
+	logger      *slog.Logger
+}
+
+func NewTaskHandler(taskService services.TaskService, logger *slog.Logger) *TaskHandler {
+	return &TaskHandler{
+		taskService: taskService,
+		logger:      logger,
+	}
+}
+
+type CreateTaskRequest struct {
+	Title       string     `json:"title" validate:"required,min=1,max=200"`
+	Description string     `json:"description,omitempty" validate:"max=2000"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+}
+
+type UpdateTaskRequest struct {
+	Title       *string    `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
+	Description *string    `json:"description,omitempty" validate:"omitempty,max=2000"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+}
+
+// Register mounts the task routes on r:
+//
+//	GET    /tasks                 list (limit, offset; Last-Modified, If-Modified-Since)
+//	POST   /tasks                 create
+//	GET    /tasks/{id}            get (ETag, If-None-Match)
+//	PUT    /tasks/{id}            update (If-Match)
+//	DELETE /tasks/{id}            delete (If-Match)
+//	POST   /tasks/{id}/complete   mark done
+func (h *TaskHandler) Register(r chi.Router) []resource.Operation {
+	return resource.Register[*services.Task, CreateTaskRequest, UpdateTaskRequest](r, taskStore{h.taskService}, resource.Options{
+		Name:   "task",
+		Path:   "/tasks",
+		Logger: h.logger,
+		ItemRoutes: func(r chi.Router) {
+			r.Method(http.MethodPost, "/complete", HandlerFunc(h.CompleteTask))
+		},
+	})
+}
+
+// CompleteTask godoc
+// @Summary      Complete a task
+// @Description  Marks an open task as done
+// @Tags         tasks
+// @Produce      json
+// @Param        id path string true "Task ID"
+// @Success      200 {object} services.Task
+// @Failure      404 {object} map[string]interface{}
+// @Failure      409 {object} map[string]interface{}
+// @Failure      500 {object} map[string]interface{}
+// @Router       /api/v1/tasks/{id}/complete [post]
+func (h *TaskHandler) CompleteTask(w http.ResponseWriter, r *http.Request) error {
+	task, err := h.taskService.CompleteTask(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info("task completed", slog.String("id", task.ID))
+	response.JSON(w, r, http.StatusOK, task)
+	return nil
+}
+
+// taskStore adapts TaskService to resource.Store, translating the HTTP
+// request types into service inputs.
+type taskStore struct {
+	tasks services.TaskService
+}
+
+func (s taskStore) List(ctx context.Context, page resource.Page) ([]*services.Task, int, error) {
+	tasks, total, err := s.tasks.ListTasks(ctx, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]*services.Task, len(tasks))
+	for i := range tasks {
+		out[i] = &tasks[i]
+	}
+	return out, total, nil
+}
+
+func (s taskStore) Get(ctx context.Context, id string) (*services.Task, error) {
+	return s.tasks.GetTask(ctx, id)
+}
+
+func (s taskStore) Create(ctx context.Context, req CreateTaskRequest) (*services.Task, error) {
+	return s.tasks.CreateTask(ctx, services.NewTask{Title: req.Title, Description: req.Description, DueAt: req.DueAt})
+}
+
+func (s taskStore) Update(ctx context.Context, id string, req UpdateTaskRequest) (*services.Task, error) {
+	return s.tasks.UpdateTask(ctx, id, services.TaskChanges{Title: req.Title, Description: req.Description, DueAt: req.DueAt})
+}
+
+func (s taskStore) Delete(ctx context.Context, id string) error {
+	return s.tasks.DeleteTask(ctx, id)
+}
+
+func (s taskStore) LastModified(ctx context.Context) (time.Time, bool) {
+	return s.tasks.LastModified(ctx)
+}
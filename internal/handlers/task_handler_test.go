@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+func testTaskRouter() http.Handler {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewTaskHandler(services.NewTaskService(services.NewMemoryTaskRepository(), nil), logger)
+	r := chi.NewRouter()
+	r.Route("/api/v1", func(r chi.Router) { h.Register(r) })
+	return r
+}
+
+func TestTaskHandler_CreateAndComplete(t *testing.T) {
+	router := testTaskRouter()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(`{"title":"Ship it"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d %s", rr.Code, rr.Body.String())
+	}
+	var task services.Task
+	if err := json.Unmarshal(rr.Body.Bytes(), &task); err != nil {
+		t.Fatalf("failed to unmarshal task: %v", err)
+	}
+	if loc := rr.Header().Get("Location"); loc != "/api/v1/tasks/"+task.ID {
+		t.Fatalf("unexpected Location %q", loc)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+task.ID+"/complete", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 completing task, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+task.ID+"/complete", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 completing a done task, got %d", rr.Code)
+	}
+}
+
+func TestTaskHandler_NotFoundAndValidation(t *testing.T) {
+	router := testTaskRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/tsk_missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/tasks/tsk_missing", bytes.NewBufferString(`{"title":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty title, got %d", rr.Code)
+	}
+}
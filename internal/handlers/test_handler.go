@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
 	"github.com/mikko-kohtala/go-api/internal/response"
 	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
 )
@@ -29,11 +31,10 @@ type TestLogResponse struct {
 // @Param        count  query    int      false  "Number of iterations (1-10, default: 1)"
 // @Success      200    {object} TestLogResponse
 // @Router       /test/logs [get]
-func TestLogs(w http.ResponseWriter, r *http.Request) {
+func TestLogs(w http.ResponseWriter, r *http.Request) error {
 	l := pkglogger.FromContext(r.Context())
 	if l == nil {
-		http.Error(w, "Logger not available", http.StatusInternalServerError)
-		return
+		return httpabort.New(http.StatusInternalServerError, "internal_error", "Logger not available")
 	}
 
 	// Parse query parameters
@@ -159,18 +160,23 @@ func TestLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response.JSON(w, r, http.StatusOK, resp)
+	return nil
 }
 
+// maxTestSleep bounds how long /test/sleep may hold a request open.
+const maxTestSleep = 30 * time.Second
+
 // TestSleep godoc
 // @Summary      Simulate a long-running request for testing shutdown behavior
-// @Description  Sleeps for the requested duration before returning.
+// @Description  Sleeps for the requested duration (capped at 30s) before returning. The sleep is aborted
+// @Description  when the request context is cancelled, so the timeout middleware owns the response.
 // @Tags         test
 // @Produce      json
 // @Param        duration_ms query int false "Sleep duration in milliseconds"
 // @Param        duration    query string false "Sleep duration (Go duration format, e.g. 250ms)"
 // @Success      200 {object} map[string]interface{}
 // @Router       /test/sleep [get]
-func TestSleep(w http.ResponseWriter, r *http.Request) {
+func TestSleep(w http.ResponseWriter, r *http.Request) error {
 	l := pkglogger.FromContext(r.Context())
 	query := r.URL.Query()
 
@@ -185,9 +191,23 @@ func TestSleep(w http.ResponseWriter, r *http.Request) {
 			sleepFor = parsed
 		}
 	}
+	if sleepFor > maxTestSleep {
+		sleepFor = maxTestSleep
+	}
 
 	if sleepFor > 0 {
-		time.Sleep(sleepFor)
+		timer := time.NewTimer(sleepFor)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			// Leave the response to whoever cancelled us (e.g. the timeout middleware)
+			err := requestctx.Check(r.Context(), "sleep")
+			if l != nil {
+				l.Info("Test sleep aborted", slog.Duration("requested", sleepFor), slog.String("reason", err.Error()))
+			}
+			return nil
+		}
 	}
 
 	if l != nil {
@@ -197,4 +217,5 @@ func TestSleep(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, r, http.StatusOK, map[string]interface{}{
 		"slept_ms": sleepFor.Milliseconds(),
 	})
+	return nil
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+)
+
+type UsageHandler struct {
+	tracker *usage.Tracker
+	logger  *slog.Logger
+}
+
+func NewUsageHandler(tracker *usage.Tracker, logger *slog.Logger) *UsageHandler {
+	return &UsageHandler{
+		tracker: tracker,
+		logger:  logger,
+	}
+}
+
+// UsageReport is the admin view of usage across all API keys.
+type UsageReport struct {
+	Window string        `json:"window"`
+	Total  usage.Usage   `json:"total"`
+	Keys   []usage.Usage `json:"keys"`
+}
+
+// GetUsage godoc
+// @Summary      Get usage for the calling API key
+// @Description  Returns request count, error rate and data volume over the rolling usage window
+// @Description  for the API key in X-API-Key (requests without a key are reported as "anonymous").
+// @Tags         usage
+// @Produce      json
+// @Param        X-API-Key header string false "API key"
+// @Success      200 {object} usage.Usage
+// @Router       /api/v1/usage [get]
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, h.tracker.Usage(usage.KeyFromRequest(r)))
+	return nil
+}
+
+// GetAllUsage godoc
+// @Summary      Get usage for all API keys
+// @Description  Admin view: per-key usage over the rolling window, busiest first, plus the total.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} UsageReport
+// @Router       /admin/usage [get]
+func (h *UsageHandler) GetAllUsage(w http.ResponseWriter, r *http.Request) error {
+	keys, total := h.tracker.All()
+	response.JSON(w, r, http.StatusOK, UsageReport{
+		Window: h.tracker.Window().String(),
+		Total:  total,
+		Keys:   keys,
+	})
+	return nil
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/i18n"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
 	"github.com/mikko-kohtala/go-api/internal/response"
 	"github.com/mikko-kohtala/go-api/internal/services"
 	"github.com/mikko-kohtala/go-api/internal/validate"
@@ -13,12 +20,14 @@ import (
 
 type UserHandler struct {
 	userService services.UserService
+	ops         *operations.Store
 	logger      *slog.Logger
 }
 
-func NewUserHandler(userService services.UserService, logger *slog.Logger) *UserHandler {
+func NewUserHandler(userService services.UserService, ops *operations.Store, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		ops:         ops,
 		logger:      logger,
 	}
 }
@@ -34,26 +43,45 @@ type UpdateUserRequest struct {
 	Role  string `json:"role,omitempty" validate:"omitempty,oneof=admin user moderator"`
 }
 
+// UserResponse is a user as the user endpoints return it, with display
+// fields in the request's language.
+type UserResponse struct {
+	services.User
+	// RoleDisplay is the role's name in the request's language.
+	RoleDisplay string `json:"role_display" example:"Administrator"`
+}
+
+func localizeUser(ctx context.Context, user services.User) UserResponse {
+	return UserResponse{User: user, RoleDisplay: i18n.T(ctx, "role."+user.Role)}
+}
+
 // GetAllUsers godoc
 // @Summary      Get all users
-// @Description  Returns a list of all users
+// @Description  Returns a list of all users; If-Modified-Since is answered with 304 while they are unchanged
 // @Tags         users
 // @Produce      json
-// @Success      200 {array} services.User
+// @Success      200 {array} UserResponse
+// @Success      304 "Not Modified"
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/users [get]
-func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) error {
+	if lastModified, ok := h.userService.LastModified(r.Context()); ok && response.NotModified(w, r, lastModified) {
+		return nil
+	}
 	users, err := h.userService.GetAllUsers(r.Context())
 	if err != nil {
-		h.logger.Error("failed to get users", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to retrieve users", nil)
-		return
+		return err
 	}
 
+	localized := make([]UserResponse, len(users))
+	for i, u := range users {
+		localized[i] = localizeUser(r.Context(), u)
+	}
 	response.JSON(w, r, http.StatusOK, map[string]interface{}{
-		"users": users,
+		"users": localized,
 		"count": len(users),
 	})
+	return nil
 }
 
 // GetUserByID godoc
@@ -62,30 +90,23 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 // @Tags         users
 // @Produce      json
 // @Param        userID path string true "User ID"
-// @Success      200 {object} services.User
+// @Success      200 {object} UserResponse
 // @Failure      404 {object} map[string]interface{}
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/users/{userID} [get]
-func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) error {
 	userID := chi.URLParam(r, "userID")
 	if userID == "" {
-		response.Error(w, r, http.StatusBadRequest, "invalid_request", "User ID is required", nil)
-		return
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "User ID is required")
 	}
 
 	user, err := h.userService.GetUserByID(r.Context(), userID)
 	if err != nil {
-		if errors.Is(err, services.ErrUserNotFound) {
-			h.logger.Debug("user not found", slog.String("user_id", userID))
-			response.Error(w, r, http.StatusNotFound, "not_found", "User not found", nil)
-			return
-		}
-		h.logger.Error("failed to get user", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to retrieve user", nil)
-		return
+		return err
 	}
 
-	response.JSON(w, r, http.StatusOK, user)
+	response.JSON(w, r, http.StatusOK, localizeUser(r.Context(), *user))
+	return nil
 }
 
 // CreateUser godoc
@@ -95,40 +116,132 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        user body CreateUserRequest true "User information"
-// @Success      201 {object} services.User
+// @Success      201 {object} UserResponse
 // @Failure      400 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
 // @Failure      409 {object} map[string]interface{}
+// @Failure      413 {object} map[string]interface{}
+// @Failure      415 {object} map[string]interface{}
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/users [post]
-func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) error {
 	var req CreateUserRequest
 	errs, err := validate.BindAndValidate(r, &req)
 	if err != nil {
-		response.Error(w, r, http.StatusBadRequest, "invalid_request", "Invalid JSON", nil)
-		return
+		return bindError(err)
 	}
 	if errs != nil {
-		response.Error(w, r, http.StatusBadRequest, "validation_error", "Validation failed", errs)
-		return
+		return errs
 	}
 
 	user, err := h.userService.CreateUser(r.Context(), req.Email, req.Name)
 	if err != nil {
-		if errors.Is(err, services.ErrEmailAlreadyExists) {
-			response.Error(w, r, http.StatusConflict, "duplicate_email", "Email already exists", nil)
-			return
+		return err
+	}
+
+	h.logger.Info("user created", slog.String("user_id", user.ID), slog.String("email", user.Email))
+	response.JSON(w, r, http.StatusCreated, localizeUser(r.Context(), *user))
+	return nil
+}
+
+// importMaxErrors stops a bulk import after this many rejected items.
+const importMaxErrors = 50
+
+// ImportUsers godoc
+// @Summary      Bulk import users
+// @Description  Streams a JSON array of users, validating and creating them one at a time so large
+// @Description  payloads are processed in bounded memory. Stops after 50 rejected items.
+// @Description  With `Prefer: respond-async` the import runs in the background instead: the answer is
+// @Description  202 with an operation whose result is the summary, and whose progress counts the items processed.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        users body []CreateUserRequest true "Users to create"
+// @Param        Prefer header string false "respond-async to import in the background"
+// @Success      200 {object} validate.StreamResult
+// @Success      202 {object} operations.Operation
+// @Failure      400 {object} map[string]interface{}
+// @Failure      413 {object} map[string]interface{}
+// @Failure      422 {object} validate.StreamResult
+// @Router       /api/v1/users/import [post]
+func (h *UserHandler) ImportUsers(w http.ResponseWriter, r *http.Request) error {
+	if r.Body == nil {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "Request body is required")
+	}
+	if preferAsync(r) {
+		return h.importUsersAsync(w, r)
+	}
+
+	res, err := h.importUsers(r.Context(), r.Body, func() error { return requestctx.Check(r.Context(), "import_item") })
+	if err != nil {
+		if errors.Is(err, requestctx.ErrAbandoned) {
+			// Nobody is waiting for the summary; the users created so far are kept
+			h.logger.Warn("user import abandoned", slog.Int("processed", res.Processed), slog.Int("created", res.Accepted))
+			return nil
 		}
-		if errors.Is(err, services.ErrInvalidEmail) {
-			response.Error(w, r, http.StatusBadRequest, "invalid_email", "Invalid email address", nil)
-			return
+		return importError(err)
+	}
+
+	status := http.StatusOK
+	if res.Aborted {
+		status = http.StatusUnprocessableEntity
+	}
+	response.JSON(w, r, status, res)
+	return nil
+}
+
+// importUsersAsync reads the whole body, so it outlives the request, and
+// imports it in an operation.
+func (h *UserHandler) importUsersAsync(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return bindError(err)
+	}
+	op, err := h.ops.Start(r.Context(), "users.import", func(ctx context.Context, t *operations.Tracker) (any, error) {
+		processed := 0
+		res, err := h.importUsers(ctx, bytes.NewReader(body), func() error {
+			t.Progress(processed, 0)
+			processed++
+			return nil
+		})
+		if err != nil {
+			return nil, importError(err)
 		}
-		h.logger.Error("failed to create user", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to create user", nil)
-		return
+		t.Progress(res.Processed, res.Processed)
+		return res, nil
+	})
+	if err != nil {
+		return err
 	}
+	w.Header().Set("Preference-Applied", "respond-async")
+	writeOperation(w, r, op)
+	return nil
+}
 
-	h.logger.Info("user created", slog.String("user_id", user.ID), slog.String("email", user.Email))
-	response.JSON(w, r, http.StatusCreated, user)
+// importUsers creates the users in body, calling stop before each one.
+func (h *UserHandler) importUsers(ctx context.Context, body io.Reader, stop func() error) (validate.StreamResult, error) {
+	opts := validate.StreamOptions{MaxErrors: importMaxErrors, Stop: stop}
+	res, err := validate.DecodeStream(body, opts,
+		func(_ int, req *CreateUserRequest) error {
+			_, err := h.userService.CreateUser(ctx, req.Email, req.Name)
+			return err
+		})
+	if err == nil {
+		h.logger.Info("users imported",
+			slog.Int("processed", res.Processed),
+			slog.Int("created", res.Accepted),
+			slog.Int("rejected", len(res.Errors)),
+			slog.Bool("aborted", res.Aborted))
+	}
+	return res, err
+}
+
+// importError is the error for an import body that could not be read.
+func importError(err error) error {
+	if errors.Is(err, validate.ErrNotArray) || errors.Is(err, validate.ErrTooManyItems) {
+		return httpabort.New(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	return bindError(err)
 }
 
 // UpdateUser godoc
@@ -139,27 +252,26 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        userID path string true "User ID"
 // @Param        user body UpdateUserRequest true "User update information"
-// @Success      200 {object} services.User
+// @Success      200 {object} UserResponse
 // @Failure      400 {object} map[string]interface{}
 // @Failure      404 {object} map[string]interface{}
+// @Failure      413 {object} map[string]interface{}
+// @Failure      415 {object} map[string]interface{}
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/users/{userID} [put]
-func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) error {
 	userID := chi.URLParam(r, "userID")
 	if userID == "" {
-		response.Error(w, r, http.StatusBadRequest, "invalid_request", "User ID is required", nil)
-		return
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "User ID is required")
 	}
 
 	var req UpdateUserRequest
 	errs, err := validate.BindAndValidate(r, &req)
 	if err != nil {
-		response.Error(w, r, http.StatusBadRequest, "invalid_request", "Invalid JSON", nil)
-		return
+		return bindError(err)
 	}
 	if errs != nil {
-		response.Error(w, r, http.StatusBadRequest, "validation_error", "Validation failed", errs)
-		return
+		return errs
 	}
 
 	// Convert request to map for updates
@@ -176,21 +288,12 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.userService.UpdateUser(r.Context(), userID, updates)
 	if err != nil {
-		if errors.Is(err, services.ErrUserNotFound) {
-			response.Error(w, r, http.StatusNotFound, "not_found", "User not found", nil)
-			return
-		}
-		if errors.Is(err, services.ErrEmailAlreadyExists) {
-			response.Error(w, r, http.StatusConflict, "duplicate_email", "Email already exists", nil)
-			return
-		}
-		h.logger.Error("failed to update user", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to update user", nil)
-		return
+		return err
 	}
 
 	h.logger.Info("user updated", slog.String("user_id", user.ID))
-	response.JSON(w, r, http.StatusOK, user)
+	response.JSON(w, r, http.StatusOK, localizeUser(r.Context(), *user))
+	return nil
 }
 
 // DeleteUser godoc
@@ -202,24 +305,17 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 // @Failure      404 {object} map[string]interface{}
 // @Failure      500 {object} map[string]interface{}
 // @Router       /api/v1/users/{userID} [delete]
-func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
 	userID := chi.URLParam(r, "userID")
 	if userID == "" {
-		response.Error(w, r, http.StatusBadRequest, "invalid_request", "User ID is required", nil)
-		return
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "User ID is required")
 	}
 
-	err := h.userService.DeleteUser(r.Context(), userID)
-	if err != nil {
-		if errors.Is(err, services.ErrUserNotFound) {
-			response.Error(w, r, http.StatusNotFound, "not_found", "User not found", nil)
-			return
-		}
-		h.logger.Error("failed to delete user", slog.String("error", err.Error()))
-		response.Error(w, r, http.StatusInternalServerError, "internal_error", "Failed to delete user", nil)
-		return
+	if err := h.userService.DeleteUser(r.Context(), userID); err != nil {
+		return err
 	}
 
 	h.logger.Info("user deleted", slog.String("user_id", userID))
-	w.WriteHeader(http.StatusNoContent)
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
 }
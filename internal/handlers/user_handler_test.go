@@ -11,13 +11,14 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/operations"
 	"github.com/mikko-kohtala/go-api/internal/services"
 )
 
 func testUserHandler() (*UserHandler, services.UserService) {
 	svc := services.NewUserService()
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	return NewUserHandler(svc, logger), svc
+	return NewUserHandler(svc, operations.NewStore(nil), logger), svc
 }
 
 func TestUserHandler_CreateUserSuccess(t *testing.T) {
@@ -27,7 +28,7 @@ func TestUserHandler_CreateUserSuccess(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
 
-	handler.CreateUser(rr, req)
+	HandlerFunc(handler.CreateUser).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected 201 Created, got %d", rr.Code)
@@ -40,7 +41,7 @@ func TestUserHandler_CreateUserValidation(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(`{"email": "invalid"}`))
 	req.Header.Set("Content-Type", "application/json")
 
-	handler.CreateUser(rr, req)
+	HandlerFunc(handler.CreateUser).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected 400, got %d", rr.Code)
@@ -57,7 +58,7 @@ func TestUserHandler_GetUserByID(t *testing.T) {
 	rctx.URLParams.Add("userID", user.ID)
 	req = req.WithContext(contextWithRoute(req.Context(), rctx))
 
-	handler.GetUserByID(rr, req)
+	HandlerFunc(handler.GetUserByID).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
@@ -73,7 +74,7 @@ func TestUserHandler_GetUserByID_NotFound(t *testing.T) {
 	rctx.URLParams.Add("userID", "unknown")
 	req = req.WithContext(contextWithRoute(req.Context(), rctx))
 
-	handler.GetUserByID(rr, req)
+	HandlerFunc(handler.GetUserByID).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusNotFound {
 		t.Fatalf("expected 404, got %d", rr.Code)
@@ -92,7 +93,7 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 	rctx.URLParams.Add("userID", user.ID)
 	req = req.WithContext(contextWithRoute(req.Context(), rctx))
 
-	handler.UpdateUser(rr, req)
+	HandlerFunc(handler.UpdateUser).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
@@ -109,7 +110,7 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 	rctx.URLParams.Add("userID", user.ID)
 	req = req.WithContext(contextWithRoute(req.Context(), rctx))
 
-	handler.DeleteUser(rr, req)
+	HandlerFunc(handler.DeleteUser).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d", rr.Code)
@@ -119,3 +120,34 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 func contextWithRoute(ctx context.Context, routeCtx *chi.Context) context.Context {
 	return context.WithValue(ctx, chi.RouteCtxKey, routeCtx)
 }
+
+func TestUserHandler_ImportUsers(t *testing.T) {
+	handler, svc := testUserHandler()
+	rr := httptest.NewRecorder()
+	body := `[{"email":"import1@example.com","name":"One"},{"email":"bad","name":"Two"},{"email":"john.doe@example.com","name":"Dup"}]`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/import", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	HandlerFunc(handler.ImportUsers).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Processed int `json:"processed"`
+		Accepted  int `json:"accepted"`
+		Errors    []struct {
+			Index int `json:"index"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if res.Processed != 3 || res.Accepted != 1 || len(res.Errors) != 2 {
+		t.Fatalf("unexpected import summary: %+v", res)
+	}
+	users, _ := svc.GetAllUsers(context.Background())
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users after import, got %d", len(users))
+	}
+}
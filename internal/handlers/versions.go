@@ -0,0 +1,31 @@
+package handlers
+
+import "github.com/mikko-kohtala/go-api/internal/apiversion"
+
+// CurrentAPIVersion is the API version of the handlers' request and response
+// shapes. Bump it with every breaking shape change and add the migration that
+// translates the previous shape below.
+const CurrentAPIVersion = "2026-10-15"
+
+// apiMigrations translate bodies for clients on older API versions, so
+// handlers only deal with the current shapes. Each entry carries the version
+// that introduced the change, e.g.
+//
+//	{
+//		Version:     "2026-11-01",
+//		Method:      http.MethodPost,
+//		Route:       "/api/v1/tasks",
+//		Description: "name renamed to title",
+//		Request:     func(b map[string]any) error { return apiversion.Rename(b, "name", "title") },
+//		Response:    func(b map[string]any) error { return apiversion.Rename(b, "title", "name") },
+//	}
+var apiMigrations = []apiversion.Migration{}
+
+// APIVersions returns the version registry of the API routes.
+func APIVersions() *apiversion.Registry {
+	reg, err := apiversion.NewRegistry(CurrentAPIVersion, apiMigrations...)
+	if err != nil {
+		panic("handlers: " + err.Error())
+	}
+	return reg
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+	"github.com/mikko-kohtala/go-api/internal/webhooks"
+)
+
+type WebhookHandler struct {
+	registry *webhooks.Registry
+	logger   *slog.Logger
+}
+
+func NewWebhookHandler(registry *webhooks.Registry, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+}
+
+// WebhookSubscriptionList lists the subscriptions and the events they can choose from.
+type WebhookSubscriptionList struct {
+	Subscriptions []webhooks.Subscription `json:"subscriptions"`
+	Events        []string                `json:"events"`
+}
+
+// WebhookKeyResponse is a subscription with a new signing key, whose secret is
+// shown only in this response.
+type WebhookKeyResponse struct {
+	Subscription webhooks.Subscription `json:"subscription"`
+	Key          webhooks.NewKey       `json:"key"`
+}
+
+// ListWebhooks godoc
+// @Summary      List webhook subscriptions
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} WebhookSubscriptionList
+// @Router       /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) error {
+	response.JSON(w, r, http.StatusOK, WebhookSubscriptionList{Subscriptions: h.registry.List(), Events: h.registry.Events()})
+	return nil
+}
+
+// CreateWebhook godoc
+// @Summary      Subscribe an endpoint to events
+// @Description  Creates a subscription with a first signing key. The key's secret is only returned here;
+// @Description  the consumer verifies deliveries with it (see pkg/webhook). Use "*" to receive every event.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        subscription body CreateWebhookRequest true "Subscription"
+// @Success      201 {object} WebhookKeyResponse
+// @Failure      400 {object} map[string]interface{}
+// @Router       /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	var req CreateWebhookRequest
+	errs, err := validate.BindAndValidate(r, &req)
+	if err != nil {
+		return bindError(err)
+	}
+	if errs != nil {
+		return errs
+	}
+
+	sub, key, err := h.registry.Create(req.URL, req.Events)
+	if err != nil {
+		return err
+	}
+	h.logger.Info("webhook subscription created", slog.String("id", sub.ID), slog.Any("events", sub.Events))
+	w.Header().Set("Location", "/admin/webhooks/"+sub.ID)
+	response.JSON(w, r, http.StatusCreated, WebhookKeyResponse{Subscription: sub, Key: key})
+	return nil
+}
+
+// GetWebhook godoc
+// @Summary      Get a webhook subscription
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "Subscription ID"
+// @Success      200 {object} webhooks.Subscription
+// @Failure      404 {object} map[string]interface{}
+// @Router       /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) error {
+	sub, err := h.registry.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		return err
+	}
+	response.JSON(w, r, http.StatusOK, sub)
+	return nil
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook subscription
+// @Tags         admin
+// @Param        id path string true "Subscription ID"
+// @Success      204 "No Content"
+// @Failure      404 {object} map[string]interface{}
+// @Router       /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+	if err := h.registry.Delete(id); err != nil {
+		return err
+	}
+	h.logger.Info("webhook subscription deleted", slog.String("id", id))
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
+
+// RotateWebhookKey godoc
+// @Summary      Rotate the signing key of a subscription
+// @Description  Adds a new signing key. Deliveries are signed with the old and the new key until the old one
+// @Description  expires (WEBHOOK_KEY_ROTATION_GRACE) or is retired, so the consumer can switch without failures.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "Subscription ID"
+// @Success      201 {object} WebhookKeyResponse
+// @Failure      404 {object} map[string]interface{}
+// @Router       /admin/webhooks/{id}/keys [post]
+func (h *WebhookHandler) RotateWebhookKey(w http.ResponseWriter, r *http.Request) error {
+	sub, key, err := h.registry.RotateKey(chi.URLParam(r, "id"))
+	if err != nil {
+		return err
+	}
+	h.logger.Info("webhook signing key rotated", slog.String("id", sub.ID), slog.String("key", key.ID))
+	response.JSON(w, r, http.StatusCreated, WebhookKeyResponse{Subscription: sub, Key: key})
+	return nil
+}
+
+// RetireWebhookKey godoc
+// @Summary      Retire a signing key
+// @Description  Stops signing deliveries with a key before it expires. The last key cannot be retired.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "Subscription ID"
+// @Param        keyID path string true "Key ID, e.g. v1"
+// @Success      200 {object} webhooks.Subscription
+// @Failure      404 {object} map[string]interface{}
+// @Failure      409 {object} map[string]interface{}
+// @Router       /admin/webhooks/{id}/keys/{keyID} [delete]
+func (h *WebhookHandler) RetireWebhookKey(w http.ResponseWriter, r *http.Request) error {
+	sub, err := h.registry.RetireKey(chi.URLParam(r, "id"), chi.URLParam(r, "keyID"))
+	if err != nil {
+		return err
+	}
+	h.logger.Info("webhook signing key retired", slog.String("id", sub.ID), slog.String("key", chi.URLParam(r, "keyID")))
+	response.JSON(w, r, http.StatusOK, sub)
+	return nil
+}
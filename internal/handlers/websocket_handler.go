@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/streams"
+	"github.com/mikko-kohtala/go-api/internal/ws"
+)
+
+// WebSocketHandler opens the realtime connections of the hub.
+type WebSocketHandler struct {
+	hub    *ws.Hub
+	logger *slog.Logger
+}
+
+func NewWebSocketHandler(hub *ws.Hub, logger *slog.Logger) *WebSocketHandler {
+	return &WebSocketHandler{
+		hub:    hub,
+		logger: logger,
+	}
+}
+
+// Connect godoc
+// @Summary      Open a WebSocket connection
+// @Description  Upgrades to a WebSocket carrying JSON text messages. Send {"type":"join","room":"tasks"} to receive
+// @Description  a room's broadcasts (the tasks room gets task.created, task.updated, task.completed and task.deleted),
+// @Description  {"type":"leave","room":"..."} to stop, and {"type":"publish","room":"...","data":{...}} to send data
+// @Description  to the other members of a room you joined. The server pings every WS_PING_INTERVAL and closes
+// @Description  connections with 1001 when it shuts down.
+// @Tags         realtime
+// @Success      101
+// @Failure      400 {object} map[string]interface{}
+// @Failure      403 {object} map[string]interface{}
+// @Failure      503 {object} map[string]interface{}
+// @Router       /api/v1/ws [get]
+func (h *WebSocketHandler) Connect(w http.ResponseWriter, r *http.Request) error {
+	err := h.hub.Accept(w, r)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ws.ErrBadHandshake):
+		w.Header().Set("Upgrade", "websocket")
+		return httpabort.New(http.StatusBadRequest, "websocket_required", "Expected a WebSocket handshake")
+	case errors.Is(err, ws.ErrOriginNotAllowed):
+		return httpabort.New(http.StatusForbidden, "origin_not_allowed", "Origin may not open WebSockets")
+	case errors.Is(err, streams.ErrDraining):
+		return httpabort.New(http.StatusServiceUnavailable, "draining", "Server is shutting down")
+	default:
+		h.logger.Warn("websocket upgrade failed", slog.String("error", err.Error()))
+		return nil // the connection was taken over; nothing can be written
+	}
+}
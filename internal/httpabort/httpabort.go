@@ -0,0 +1,48 @@
+// Package httpabort stops a request with a client-facing error. Handlers
+// return one (see handlers.HandlerFunc); code deep below a handler, where
+// returning it is awkward, may panic with it instead:
+//
+//	httpabort.Abort(http.StatusUnprocessableEntity, "task_archived", "Archived tasks cannot change")
+//
+// The recoverer answers such a panic through response.FromError, exactly as
+// if the handler had returned the error, and neither logs nor alerts it as a
+// crash. Only *errors.APIError panic values abort; any other value is still a
+// panic and a 500.
+package httpabort
+
+import (
+	"fmt"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+)
+
+// New returns an error answered with status, code and message, carrying the
+// caller's stack.
+func New(status int, code, message string) *apierrors.APIError {
+	return apierrors.NewDepth(1, status, code, message)
+}
+
+// Errorf is New with a formatted message.
+func Errorf(status int, code, format string, args ...any) *apierrors.APIError {
+	return apierrors.NewDepth(1, status, code, fmt.Sprintf(format, args...))
+}
+
+// Wrap returns an error answered with status, code and message that keeps
+// err as its cause, so the cause is logged with the request while clients
+// only see message. Quota and job pool refusals in err's chain are still
+// answered as such.
+func Wrap(err error, status int, code, message string) *apierrors.APIError {
+	return apierrors.WrapDepth(1, err, status, code, message)
+}
+
+// Abort panics with New(status, code, message).
+func Abort(status int, code, message string) {
+	panic(apierrors.NewDepth(1, status, code, message))
+}
+
+// Recovered returns the error a recovered panic value aborted with, if it is
+// an abort.
+func Recovered(v any) (*apierrors.APIError, bool) {
+	err, ok := v.(*apierrors.APIError)
+	return err, ok && err != nil
+}
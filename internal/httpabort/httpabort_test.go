@@ -0,0 +1,52 @@
+package httpabort
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+)
+
+func archiveWidget() error {
+	return New(http.StatusUnprocessableEntity, "widget_archived", "Archived widgets cannot change")
+}
+
+func TestNew_StackStartsAtCaller(t *testing.T) {
+	err := archiveWidget()
+	var apiErr *apierrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnprocessableEntity || apiErr.Code != "widget_archived" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if stack := apierrors.Stack(err); len(stack) == 0 || !strings.Contains(stack[0], "archiveWidget") {
+		t.Fatalf("expected stack to start at archiveWidget, got %v", stack)
+	}
+}
+
+func TestWrap_KeepsCause(t *testing.T) {
+	cause := errors.New("disk on fire")
+	err := Wrap(cause, http.StatusInternalServerError, "internal_error", "Failed to save")
+	if !errors.Is(err, cause) || err.Message != "Failed to save" {
+		t.Fatalf("expected the cause behind the client message, got %v", err)
+	}
+}
+
+func TestAbort_RecoveredOnlyForAbortErrors(t *testing.T) {
+	recovered := func(fn func()) (v any) {
+		defer func() { v = recover() }()
+		fn()
+		return nil
+	}
+
+	v := recovered(func() { Abort(http.StatusConflict, "conflict", "Already there") })
+	if err, ok := Recovered(v); !ok || err.Status != http.StatusConflict {
+		t.Fatalf("expected an abort, got %v", v)
+	}
+	if _, ok := Recovered(recovered(func() { panic("boom") })); ok {
+		t.Fatal("expected a plain panic not to be an abort")
+	}
+	if _, ok := Recovered((*apierrors.APIError)(nil)); ok {
+		t.Fatal("expected a nil error not to be an abort")
+	}
+}
@@ -0,0 +1,57 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/tokensource"
+)
+
+// defaultAuth is the credential policy of Transport; nil when disabled.
+var defaultAuth *authPolicy
+
+// authPolicy attaches a token to requests for the configured hosts. Other
+// hosts, such as third-party webhooks, never see it.
+type authPolicy struct {
+	source tokensource.Source
+	hosts  []string
+}
+
+// applies reports whether req should carry the token. A request that already
+// has an Authorization header is left alone.
+func (p *authPolicy) applies(req *http.Request) bool {
+	if p == nil || req.Header.Get("Authorization") != "" {
+		return false
+	}
+	return matchHost(p.hosts, req.URL.Hostname())
+}
+
+// matchHost reports whether host is one of hosts, or *.domain patterns.
+func matchHost(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range hosts {
+		if h == host || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// attach sets the Authorization header of req, which the caller has cloned.
+func (p *authPolicy) attach(req *http.Request) error {
+	tok, err := p.source.Token(req.Context())
+	if err != nil {
+		return fmt.Errorf("service token: %w", err)
+	}
+	req.Header.Set("Authorization", tok.Header())
+	return nil
+}
+
+// rejected drops a cached token the receiver refused, so the next request
+// fetches a new one.
+func (p *authPolicy) rejected() {
+	if c, ok := p.source.(interface{ Invalidate() }); ok {
+		c.Invalidate()
+	}
+}
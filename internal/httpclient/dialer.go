@@ -0,0 +1,128 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Dialer opens outbound connections through a Resolver. New connections to a
+// host are spread round-robin over its addresses, and an address whose dial
+// failed is skipped for the cooldown unless no other address is left. Hosts
+// with static endpoints are never resolved.
+//
+// Balancing happens per connection: requests reusing a kept-alive connection
+// stay on its instance.
+type Dialer struct {
+	resolver  Resolver
+	endpoints map[string][]string
+	cooldown  time.Duration
+	dialer    net.Dialer
+	now       func() time.Time
+
+	mu   sync.Mutex
+	next map[string]int
+	down map[string]time.Time
+}
+
+// NewDialer returns a Dialer. endpoints maps a host to static instance
+// addresses ("ip" or "ip:port"; the request's port is used when omitted).
+func NewDialer(resolver Resolver, endpoints map[string][]string, cooldown time.Duration) *Dialer {
+	return &Dialer{
+		resolver:  resolver,
+		endpoints: endpoints,
+		cooldown:  cooldown,
+		dialer:    net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		now:       time.Now,
+		next:      make(map[string]int),
+		down:      make(map[string]time.Time),
+	}
+}
+
+// DialContext has the signature of http.Transport.DialContext.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	candidates, err := d.candidates(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, a := range d.order(host, candidates) {
+		conn, err := d.dialer.DialContext(ctx, network, a)
+		if err == nil {
+			d.markUp(a)
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+		d.markDown(a)
+		metrics.ObserveDialFailure(host)
+	}
+	return nil, lastErr
+}
+
+// candidates returns the instance addresses (host:port) of host.
+func (d *Dialer) candidates(ctx context.Context, host, port string) ([]string, error) {
+	static, ok := d.endpoints[host]
+	if !ok {
+		ips, _, err := d.resolver.Lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		static = ips
+	}
+	out := make([]string, 0, len(static))
+	for _, a := range static {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			a = net.JoinHostPort(a, port)
+		}
+		out = append(out, a)
+	}
+	if len(out) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	return out, nil
+}
+
+// order rotates candidates round-robin per host and moves addresses in their
+// cooldown to the end.
+func (d *Dialer) order(host string, candidates []string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	start := d.next[host] % len(candidates)
+	d.next[host] = start + 1
+
+	now := d.now()
+	healthy := make([]string, 0, len(candidates))
+	var cooling []string
+	for i := range candidates {
+		a := candidates[(start+i)%len(candidates)]
+		if until, ok := d.down[a]; ok && now.Before(until) {
+			cooling = append(cooling, a)
+			continue
+		}
+		healthy = append(healthy, a)
+	}
+	return append(healthy, cooling...)
+}
+
+func (d *Dialer) markDown(addr string) {
+	d.mu.Lock()
+	d.down[addr] = d.now().Add(d.cooldown)
+	d.mu.Unlock()
+}
+
+func (d *Dialer) markUp(addr string) {
+	d.mu.Lock()
+	delete(d.down, addr)
+	d.mu.Unlock()
+}
@@ -0,0 +1,146 @@
+// Package httpclient provides the instrumented HTTP client for outbound
+// calls: requests carry the caller's request ID and remaining deadline
+// budget, and are counted per host in Prometheus. Connections go through a
+// caching resolver and are balanced over the instances of a host, calls to
+// internal services carry this service's own credentials, and requests that
+// are safe to repeat are retried.
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/deadline"
+	"github.com/mikko-kohtala/go-api/internal/idempotency"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/tokensource"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Options configures how outbound connections reach upstreams.
+type Options struct {
+	DNSCacheMaxTTL   time.Duration       // longest reuse of resolved addresses (0 disables the cache)
+	DNSFallbackTTL   time.Duration       // reuse of addresses whose record TTL is unknown
+	Endpoints        map[string][]string // static instance addresses per host
+	EndpointCooldown time.Duration       // an instance is skipped this long after a failed dial
+	Auth             tokensource.Source  // credentials attached to requests to AuthHosts
+	AuthHosts        []string            // hosts, or *.domain patterns, that receive Auth
+	Retries          int                 // attempts after a failed one, for requests safe to repeat
+	RetryBackoff     time.Duration       // wait before the first retry, doubled for each one after
+	IdempotentHosts  []string            // hosts, or *.domain patterns, whose POST and PATCH requests get an Idempotency-Key and are retried
+}
+
+// defaultTransport is the base of Transport(nil).
+var defaultTransport http.RoundTripper = http.DefaultTransport
+
+// Configure sets the connection behaviour of New and Transport(nil). It is
+// called once at startup, before clients are created.
+func Configure(o Options) {
+	var resolver Resolver = NewDNSResolver(o.DNSFallbackTTL)
+	if o.DNSCacheMaxTTL > 0 {
+		resolver = NewCachingResolver(resolver, o.DNSCacheMaxTTL)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = NewDialer(resolver, o.Endpoints, o.EndpointCooldown).DialContext
+	defaultTransport = t
+	defaultAuth = nil
+	if o.Auth != nil && len(o.AuthHosts) > 0 {
+		defaultAuth = &authPolicy{source: o.Auth, hosts: o.AuthHosts}
+	}
+	defaultRetry = nil
+	if o.Retries > 0 {
+		defaultRetry = &retryPolicy{retries: o.Retries, backoff: o.RetryBackoff, hosts: o.IdempotentHosts}
+	}
+}
+
+// New returns a client using Transport with an overall timeout.
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport(nil)}
+}
+
+// Transport wraps base, or the configured default transport when nil.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = defaultTransport
+	}
+	return &transport{base: base, auth: defaultAuth, retry: defaultRetry}
+}
+
+type transport struct {
+	base  http.RoundTripper
+	auth  *authPolicy
+	retry *retryPolicy
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.retry.keys(req) {
+		// One key for every attempt, so the receiver applies the request once
+		req = req.Clone(ctx)
+		req.Header.Set(idempotency.Header, idempotency.NewKey())
+	}
+	retry := t.retry.allows(req)
+	for attempt := 0; ; attempt++ {
+		out := req
+		if attempt > 0 {
+			if err := t.retry.wait(ctx, attempt); err != nil {
+				return nil, err
+			}
+			out = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				out.Body = body
+			}
+		}
+		resp, err := t.send(out)
+		reason := retryReason(resp, err)
+		if !retry || reason == "" || attempt >= t.retry.retries || ctx.Err() != nil {
+			return resp, err
+		}
+		discard(resp)
+		metrics.ObserveOutboundRetry(req.URL.Host, reason)
+	}
+}
+
+// send makes one attempt of req.
+func (t *transport) send(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	rid := pkglogger.RequestIDFromContext(ctx)
+	remaining, hasDeadline := deadline.Remaining(ctx)
+	authorize := t.auth.applies(req)
+	sc := tracing.SpanContextFromContext(ctx)
+	if rid != "" || hasDeadline || authorize || sc.IsValid() {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(ctx)
+		if rid != "" && req.Header.Get("X-Request-ID") == "" {
+			req.Header.Set("X-Request-ID", rid)
+		}
+		if sc.IsValid() && req.Header.Get(tracing.Header) == "" {
+			req.Header.Set(tracing.Header, sc.Traceparent())
+		}
+		if hasDeadline {
+			req.Header.Set(deadline.Header, deadline.Format(remaining))
+		}
+		if authorize {
+			if err := t.auth.attach(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+	}
+	metrics.ObserveOutbound(req.URL.Host, status, time.Since(start))
+	if authorize && status == http.StatusUnauthorized {
+		t.auth.rejected()
+	}
+	return resp, err
+}
@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/tokensource"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+func TestTransport_ForwardsRequestIDAndBudget(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	exp := tracing.NewExporter(tracing.ExporterOptions{Endpoint: "http://127.0.0.1:0"})
+	defer exp.Shutdown(context.Background())
+	ctx, span := tracing.New(exp, 0).Start(pkglogger.WithRequestID(context.Background(), "rid-42"), "op", tracing.KindServer)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := New(5 * time.Second).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got.Get("X-Request-ID") != "rid-42" {
+		t.Fatalf("expected request ID to be forwarded, got %q", got.Get("X-Request-ID"))
+	}
+	ms, err := strconv.Atoi(got.Get("X-Request-Timeout"))
+	if err != nil || ms <= 0 || ms > 2000 {
+		t.Fatalf("expected remaining budget of at most 2000ms, got %q", got.Get("X-Request-Timeout"))
+	}
+	if got.Get(tracing.Header) != span.SpanContext().Traceparent() {
+		t.Fatalf("expected the trace to be propagated, got %q", got.Get(tracing.Header))
+	}
+	if req.Header.Get("X-Request-Timeout") != "" {
+		t.Fatal("expected the caller's request to be left unmodified")
+	}
+}
+
+func TestTransport_NoBudgetWithoutDeadline(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	resp, err := (&http.Client{Transport: Transport(nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got.Get("X-Request-Timeout") != "" {
+		t.Fatalf("expected no budget header, got %q", got.Get("X-Request-Timeout"))
+	}
+}
+
+type staticToken string
+
+func (s staticToken) Token(ctx context.Context) (tokensource.Token, error) {
+	return tokensource.Token{AccessToken: string(s)}, nil
+}
+
+func TestTransport_AttachesTokenToConfiguredHosts(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	policy := &authPolicy{source: staticToken("svc-token"), hosts: []string{"127.0.0.1"}}
+	client := &http.Client{Transport: &transport{base: http.DefaultTransport, auth: policy}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got != "Bearer svc-token" {
+		t.Fatalf("expected the service token, got %q", got)
+	}
+
+	policy.hosts = []string{"*.internal"}
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got != "" {
+		t.Fatalf("expected no token for other hosts, got %q", got)
+	}
+}
+
+func TestTransport_RetriesPostsWithOneIdempotencyKey(t *testing.T) {
+	var keys, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		keys, bodies = append(keys, r.Header.Get("Idempotency-Key")), append(bodies, string(b))
+		if len(keys) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	retry := &retryPolicy{retries: 2, hosts: []string{"127.0.0.1"}}
+	client := &http.Client{Transport: &transport{base: http.DefaultTransport, retry: retry}}
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"n":1}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || len(keys) != 3 {
+		t.Fatalf("expected success on the third attempt, got %d after %d", resp.StatusCode, len(keys))
+	}
+	if !strings.HasPrefix(keys[0], "idk_") || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Fatalf("expected one generated key on every attempt, got %q", keys)
+	}
+	if bodies[2] != `{"n":1}` {
+		t.Fatalf("expected the body sent again, got %q", bodies[2])
+	}
+
+	// POSTs to other hosts are not safe to repeat
+	keys = nil
+	retry.hosts = []string{"billing.internal"}
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || len(keys) != 1 || keys[0] != "" {
+		t.Fatalf("expected a single unkeyed attempt, got %d attempts with %q", len(keys), keys)
+	}
+}
@@ -0,0 +1,286 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Resolver looks up the addresses of a host and how long they may be reused.
+type Resolver interface {
+	Lookup(ctx context.Context, host string) (addrs []string, ttl time.Duration, err error)
+}
+
+// dnsResolver asks the nameservers of /etc/resolv.conf directly, because the
+// standard library resolver does not expose record TTLs. Names it cannot
+// resolve that way (single-label names that rely on search domains,
+// /etc/hosts entries, truncated answers) go to the system resolver and are
+// given fallbackTTL.
+type dnsResolver struct {
+	servers     []string
+	fallbackTTL time.Duration
+	system      *net.Resolver
+}
+
+// NewDNSResolver returns a Resolver reporting record TTLs where it can, and
+// fallbackTTL otherwise.
+func NewDNSResolver(fallbackTTL time.Duration) Resolver {
+	return &dnsResolver{servers: systemNameservers("/etc/resolv.conf"), fallbackTTL: fallbackTTL, system: net.DefaultResolver}
+}
+
+func (d *dnsResolver) Lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	if len(d.servers) > 0 && strings.Contains(strings.TrimSuffix(host, "."), ".") {
+		for _, server := range d.servers {
+			if addrs, ttl, err := d.query(ctx, server, host); err == nil && len(addrs) > 0 {
+				return addrs, ttl, nil
+			}
+			if ctx.Err() != nil {
+				return nil, 0, ctx.Err()
+			}
+		}
+	}
+	addrs, err := d.system.LookupHost(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	return addrs, d.fallbackTTL, nil
+}
+
+// query asks server for the A and AAAA records of host and returns the
+// addresses with the smallest TTL among them.
+func (d *dnsResolver) query(ctx context.Context, server, host string) ([]string, time.Duration, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		addrs  []string
+		minTTL uint32
+	)
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, ttl, err := exchange(ctx, server, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(found) > 0 && (len(addrs) == 0 || ttl < minTTL) {
+			minTTL = ttl
+		}
+		addrs = append(addrs, found...)
+	}
+	return addrs, time.Duration(minTTL) * time.Second, nil
+}
+
+// exchange sends one UDP query and parses the answer records of qtype.
+func exchange(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]string, uint32, error) {
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dnsQueryTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, 1232)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(buf[:n])
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case h.ID != id:
+		return nil, 0, errors.New("dns: response ID mismatch")
+	case h.Truncated:
+		return nil, 0, errors.New("dns: truncated response")
+	case h.RCode != dnsmessage.RCodeSuccess:
+		return nil, 0, fmt.Errorf("dns: %s", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, err
+	}
+	var (
+		addrs  []string
+		minTTL uint32
+	)
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var ip net.IP
+		switch {
+		case rh.Type == dnsmessage.TypeA && qtype == dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			ip = r.A[:]
+		case rh.Type == dnsmessage.TypeAAAA && qtype == dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			ip = r.AAAA[:]
+		default:
+			// CNAMEs on the way to the records are followed by the server
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if len(addrs) == 0 || rh.TTL < minTTL {
+			minTTL = rh.TTL
+		}
+		addrs = append(addrs, ip.String())
+	}
+	return addrs, minTTL, nil
+}
+
+// dnsQueryTimeout bounds a query when the context has no deadline.
+const dnsQueryTimeout = 2 * time.Second
+
+// systemNameservers reads the nameserver lines of a resolv.conf file.
+func systemNameservers(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	return servers
+}
+
+// staleRetry is how long expired addresses are reused after a failed refresh
+// before the lookup is tried again.
+const staleRetry = 5 * time.Second
+
+// lookupTimeout bounds a shared lookup, which outlives the request that
+// started it.
+const lookupTimeout = 5 * time.Second
+
+// CachingResolver caches the addresses of next for their TTL, capped by
+// maxTTL. Concurrent lookups of a host share one query, and the previous
+// addresses are served while a refresh fails.
+type CachingResolver struct {
+	next   Resolver
+	maxTTL time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	inflight map[string]*pendingLookup
+}
+
+type cacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+type pendingLookup struct {
+	done  chan struct{}
+	addrs []string
+	ttl   time.Duration
+	err   error
+}
+
+// NewCachingResolver wraps next with a cache.
+func NewCachingResolver(next Resolver, maxTTL time.Duration) *CachingResolver {
+	return &CachingResolver{
+		next:     next,
+		maxTTL:   maxTTL,
+		now:      time.Now,
+		entries:  make(map[string]cacheEntry),
+		inflight: make(map[string]*pendingLookup),
+	}
+}
+
+func (c *CachingResolver) Lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	c.mu.Lock()
+	now := c.now()
+	e, cached := c.entries[host]
+	if cached && now.Before(e.expires) {
+		c.mu.Unlock()
+		metrics.ObserveDNSLookup("hit")
+		return e.addrs, e.expires.Sub(now), nil
+	}
+	if p, ok := c.inflight[host]; ok {
+		c.mu.Unlock()
+		select {
+		case <-p.done:
+			return p.addrs, p.ttl, p.err
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+	p := &pendingLookup{done: make(chan struct{})}
+	c.inflight[host] = p
+	c.mu.Unlock()
+
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lookupTimeout)
+	p.addrs, p.ttl, p.err = c.next.Lookup(lookupCtx, host)
+	cancel()
+
+	c.mu.Lock()
+	delete(c.inflight, host)
+	now = c.now()
+	switch {
+	case p.err == nil:
+		p.ttl = min(p.ttl, c.maxTTL)
+		c.entries[host] = cacheEntry{addrs: p.addrs, expires: now.Add(p.ttl)}
+		metrics.ObserveDNSLookup("miss")
+	case cached:
+		p.addrs, p.ttl, p.err = e.addrs, staleRetry, nil
+		c.entries[host] = cacheEntry{addrs: e.addrs, expires: now.Add(staleRetry)}
+		metrics.ObserveDNSLookup("stale")
+	default:
+		metrics.ObserveDNSLookup("error")
+	}
+	c.mu.Unlock()
+	close(p.done)
+	return p.addrs, p.ttl, p.err
+}
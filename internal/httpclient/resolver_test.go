@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	calls atomic.Int32
+	addrs []string
+	ttl   time.Duration
+	err   error
+}
+
+func (f *fakeResolver) Lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	f.calls.Add(1)
+	return f.addrs, f.ttl, f.err
+}
+
+func TestCachingResolver_RespectsTTL(t *testing.T) {
+	next := &fakeResolver{addrs: []string{"10.0.0.1"}, ttl: 30 * time.Second}
+	c := NewCachingResolver(next, time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.Lookup(context.Background(), "api.example.com"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := next.calls.Load(); n != 1 {
+		t.Fatalf("expected one lookup within the TTL, got %d", n)
+	}
+
+	now = now.Add(31 * time.Second)
+	if _, _, err := c.Lookup(context.Background(), "api.example.com"); err != nil || next.calls.Load() != 2 {
+		t.Fatalf("expected a new lookup after the TTL, got %d calls (%v)", next.calls.Load(), err)
+	}
+}
+
+func TestCachingResolver_ServesStaleOnError(t *testing.T) {
+	next := &fakeResolver{addrs: []string{"10.0.0.1"}, ttl: time.Second}
+	c := NewCachingResolver(next, time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	_, _, _ = c.Lookup(context.Background(), "api.example.com")
+
+	now = now.Add(2 * time.Second)
+	next.err = errors.New("nameserver down")
+	addrs, _, err := c.Lookup(context.Background(), "api.example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+		t.Fatalf("expected the previous addresses while the resolver fails, got %v %v", addrs, err)
+	}
+
+	if _, _, err := c.Lookup(context.Background(), "other.example.com"); err == nil {
+		t.Fatal("expected an error for a host never resolved")
+	}
+}
+
+func TestSystemNameservers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	_ = os.WriteFile(path, []byte("# comment\nsearch svc.cluster.local\nnameserver 10.96.0.10\nnameserver ::1\nnameserver bogus\n"), 0o644)
+	got := systemNameservers(path)
+	if len(got) != 2 || got[0] != "10.96.0.10:53" || got[1] != "[::1]:53" {
+		t.Fatalf("unexpected nameservers: %v", got)
+	}
+}
+
+func TestDialer_BalancesAndSkipsFailedInstances(t *testing.T) {
+	var hits [2]atomic.Int32
+	var addrs []string
+	for i := range hits {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits[i].Add(1) }))
+		defer srv.Close()
+		addrs = append(addrs, srv.Listener.Addr().String())
+	}
+	// A closed listener stands in for a dead instance
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := l.Addr().String()
+	l.Close()
+
+	d := NewDialer(&fakeResolver{}, map[string][]string{"upstream.internal": {addrs[0], dead, addrs[1]}}, time.Minute)
+	client := &http.Client{Transport: &http.Transport{DialContext: d.DialContext, DisableKeepAlives: true}}
+	for i := 0; i < 6; i++ {
+		resp, err := client.Get("http://upstream.internal/")
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if hits[0].Load() == 0 || hits[1].Load() == 0 {
+		t.Fatalf("expected both live instances to be used, got %d and %d", hits[0].Load(), hits[1].Load())
+	}
+	if order := d.order("upstream.internal", []string{addrs[0], dead, addrs[1]}); order[len(order)-1] != dead {
+		t.Fatalf("expected the failed instance to be tried last, got %v", order)
+	}
+}
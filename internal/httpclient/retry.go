@@ -0,0 +1,88 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/idempotency"
+)
+
+// defaultRetry is the retry policy of Transport; nil when disabled.
+var defaultRetry *retryPolicy
+
+// retryPolicy repeats requests that failed to connect or got 502, 503 or 504
+// when repeating them is safe: idempotent methods, and requests with an
+// Idempotency-Key. POST and PATCH requests to the configured internal hosts
+// get a key, the same on every attempt, so the receiver applies them once.
+type retryPolicy struct {
+	retries int           // attempts after the first
+	backoff time.Duration // before the first retry, doubled for each one after
+	hosts   []string      // hosts, or *.domain patterns, that honour Idempotency-Key
+}
+
+// keys reports whether req should get an Idempotency-Key. A request that
+// has one already keeps it.
+func (p *retryPolicy) keys(req *http.Request) bool {
+	return p != nil && (req.Method == http.MethodPost || req.Method == http.MethodPatch) &&
+		req.Header.Get(idempotency.Header) == "" && matchHost(p.hosts, req.URL.Hostname())
+}
+
+// allows reports whether req may be sent again: it is safe to repeat and its
+// body can be read again.
+func (p *retryPolicy) allows(req *http.Request) bool {
+	if p == nil || p.retries <= 0 {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(idempotency.Header) != ""
+}
+
+// wait sleeps before retry number attempt, with jitter so the instances of a
+// service do not retry in step, or returns ctx's error when it ends first.
+func (p *retryPolicy) wait(ctx context.Context, attempt int) error {
+	d := p.backoff << (attempt - 1)
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryReason returns why the outcome of an attempt is worth retrying, or
+// "" when it is not.
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		return "error"
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return strconv.Itoa(resp.StatusCode)
+	}
+	return ""
+}
+
+// discard drains a little of the body of a response that will be retried, so
+// its connection can be reused, and closes it.
+func discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// RecordAbandoned counts and logs requests whose client disconnected before
+// the handler finished, with the stage where the handler noticed (recorded
+// by requestctx.Check) or "unchecked" when it ran to completion regardless.
+// Timeouts are not abandonment and are left to the timeout middleware.
+func RecordAbandoned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		if !errors.Is(r.Context().Err(), context.Canceled) {
+			return
+		}
+
+		route := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		stage := requestctx.AbandonedStage(r.Context())
+		if stage == "" {
+			stage = "unchecked"
+		}
+		metrics.ObserveAbandoned(route, stage)
+		pkglogger.FromContext(r.Context()).Warn("request abandoned by client",
+			slog.String("route", route),
+			slog.String("stage", stage),
+			slog.Duration("elapsed", time.Since(start)))
+	})
+}
@@ -0,0 +1,40 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+func TestRecordAbandoned_LogsStage(t *testing.T) {
+	var buf bytes.Buffer
+	h := RecordAbandoned(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = requestctx.Check(r.Context(), "export_page")
+	}))
+
+	ctx, cancel := context.WithCancel(requestctx.New(context.Background()))
+	cancel() // the client has gone
+	ctx = pkglogger.IntoContext(ctx, slog.New(slog.NewJSONHandler(&buf, nil)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/export", nil).WithContext(ctx))
+
+	if logged := buf.String(); !strings.Contains(logged, "request abandoned by client") || !strings.Contains(logged, `"stage":"export_page"`) {
+		t.Fatalf("expected abandonment to be logged with its stage, got %s", logged)
+	}
+}
+
+func TestRecordAbandoned_IgnoresCompletedRequests(t *testing.T) {
+	var buf bytes.Buffer
+	h := RecordAbandoned(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ctx := pkglogger.IntoContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing logged, got %s", buf.String())
+	}
+}
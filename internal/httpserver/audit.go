@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/audit"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// AuditTrail records every state-changing API request (POST, PUT, PATCH,
+// DELETE under /api/) to sink once the response status is known. The actor
+// is the authenticated user, or the client address for anonymous requests.
+// What services changed, recorded with audit.RecordChange, is listed under
+// the "changes" detail. Write failures are logged and never fail the request.
+func AuditTrail(sink audit.Sink) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isMutation(r.Method) || !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ww := respwriter.Wrap(w)
+			r = r.WithContext(audit.WithChanges(r.Context()))
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			outcome := "success"
+			if status >= 400 {
+				outcome = "failure"
+			}
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			rec := audit.Record{
+				Time:      time.Now().UTC(),
+				Actor:     r.RemoteAddr,
+				Action:    r.Method + " " + route,
+				Resource:  r.URL.Path,
+				Outcome:   outcome,
+				RequestID: response.RequestID(r),
+				Details:   map[string]any{"status": status},
+			}
+			if changes := audit.Changes(r.Context()); len(changes) > 0 {
+				rec.Details["changes"] = changes
+			}
+			if p, ok := requestctx.Principal(r.Context()); ok {
+				rec.Actor = p.UserID
+				rec.Tenant = p.Tenant
+			}
+			if err := sink.Write(r.Context(), rec); err != nil {
+				pkglogger.FromContext(r.Context()).Error("audit write failed", slog.String("error", err.Error()))
+			}
+		})
+	}
+}
+
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/audit"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+type recordingSink struct{ records []audit.Record }
+
+func (s *recordingSink) Write(_ context.Context, rec audit.Record) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestAuditTrail_RecordsMutations(t *testing.T) {
+	sink := &recordingSink{}
+	r := chi.NewRouter()
+	r.Use(AuditTrail(sink))
+	r.Get("/api/v1/users/{userID}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Delete("/api/v1/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/api/v1/users/u1", nil)
+		req.Header.Set("X-Request-ID", "rid-1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("expected only the DELETE to be audited, got %d records", len(sink.records))
+	}
+	rec := sink.records[0]
+	if rec.Action != "DELETE /api/v1/users/{userID}" || rec.Resource != "/api/v1/users/u1" {
+		t.Fatalf("unexpected action/resource: %q %q", rec.Action, rec.Resource)
+	}
+	if rec.Outcome != "failure" || rec.Details["status"] != http.StatusNotFound || rec.RequestID != "rid-1" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
+
+func TestAuditTrail_ActorIsAuthenticatedPrincipal(t *testing.T) {
+	sink := &recordingSink{}
+	r := chi.NewRouter()
+	r.Use(RequestID, AuditTrail(sink))
+	r.Post("/api/v1/tasks", func(w http.ResponseWriter, r *http.Request) {
+		requestctx.SetPrincipal(r.Context(), requestctx.Identity{UserID: "usr_001", Tenant: "acme"})
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/tasks", nil))
+
+	if len(sink.records) != 1 || sink.records[0].Actor != "usr_001" || sink.records[0].Tenant != "acme" {
+		t.Fatalf("expected record attributed to the principal, got %+v", sink.records)
+	}
+}
+
+func TestAuditTrail_RecordsUserChanges(t *testing.T) {
+	sink := &recordingSink{}
+	users := services.NewAuditedUserService(services.NewUserService())
+	r := chi.NewRouter()
+	r.Use(AuditTrail(sink))
+	r.Put("/api/v1/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := users.UpdateUser(r.Context(), chi.URLParam(r, "userID"), map[string]interface{}{"name": "Johnny Doe"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/api/v1/users/usr_001", nil))
+
+	changes, _ := sink.records[0].Details["changes"].([]audit.Change)
+	if len(changes) != 1 || changes[0].Resource != "user" || changes[0].ID != "usr_001" {
+		t.Fatalf("expected the user change, got %+v", sink.records[0].Details)
+	}
+	if got := changes[0].Fields; len(got) != 1 || got["name"].Before != "John Doe" || got["name"].After != "Johnny Doe" {
+		t.Fatalf("expected only the name changed, got %+v", got)
+	}
+}
@@ -1,17 +1,58 @@
 package httpserver
 
 import (
+	"mime"
 	"net/http"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/routes"
 )
 
 // BodyLimit returns middleware that limits request body size using http.MaxBytesReader.
+// Requests that declare a Content-Length above the limit are rejected with 413
+// before the handler runs; streamed bodies are cut off while being read. Route
+// groups with a BodyLimit in the exposure matrix replace maxBytes.
 func BodyLimit(maxBytes int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if maxBytes > 0 && r.Body != nil {
-				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			limit := routes.BodyLimit(r.URL.Path, maxBytes)
+			if limit > 0 && r.ContentLength > limit {
+				response.PayloadTooLarge(w, r, limit)
+				return
+			}
+			if limit > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// RequireJSON rejects request bodies that are not JSON with 415. Requests
+// without a body (e.g. GET, DELETE) pass through.
+func RequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasBody(r) && !isJSONContentType(r.Header.Get("Content-Type")) {
+			response.Error(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type",
+				"Content-Type must be application/json", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	return r.ContentLength != 0
+}
+
+func isJSONContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/canary"
+	"github.com/mikko-kohtala/go-api/internal/httpclient"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+)
+
+// CanaryRouting decides each request's variant and records per-variant
+// metrics so error rates and latency can be compared. Canary requests are
+// sent to upstream when it is set; otherwise they continue down the chain,
+// where canary.Switch picks the in-process implementation. The variant is
+// returned in the X-Canary header.
+func CanaryRouting(opts canary.Options, upstream http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			variant := opts.Decide(r, canaryUnit(r))
+			w.Header().Set("X-Canary", variant)
+
+			start := time.Now()
+			ww := respwriter.Wrap(w)
+			r = r.WithContext(canary.IntoContext(r.Context(), variant))
+			if variant == canary.Canary && upstream != nil {
+				upstream.ServeHTTP(ww, r)
+			} else {
+				next.ServeHTTP(ww, r)
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			metrics.ObserveCanary(variant, status, time.Since(start))
+		})
+	}
+}
+
+// canaryUnit identifies the caller for the percentage split: the API key,
+// or the client IP for anonymous requests.
+func canaryUnit(r *http.Request) string {
+	if key := usage.KeyFromRequest(r); key != usage.Anonymous {
+		return key
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// newCanaryProxy forwards canary requests to target, keeping the request
+// path, ID and deadline budget. Unreachable upstreams get a JSON 502.
+func newCanaryProxy(target *url.URL, appLogger *slog.Logger) http.Handler {
+	return &httputil.ReverseProxy{
+		Transport: httpclient.Transport(nil),
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Request-ID", response.RequestID(pr.In))
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			appLogger.Error("canary upstream failed",
+				slog.String("upstream", target.Host),
+				slog.String("request_id", response.RequestID(r)),
+				slog.String("error", err.Error()))
+			response.Error(w, r, http.StatusBadGateway, "bad_gateway", "Upstream unavailable", nil)
+		},
+	}
+}
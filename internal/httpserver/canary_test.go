@@ -0,0 +1,50 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/canary"
+)
+
+func TestCanaryRouting_ProxiesCanaryRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "canary "+r.URL.Path+" "+r.Header.Get("X-Request-ID"))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	h := CanaryRouting(canary.Options{Header: "X-Canary"}, newCanaryProxy(target, testLogger()))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "stable")
+		}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil))
+	if rr.Body.String() != "stable" || rr.Header().Get("X-Canary") != canary.Stable {
+		t.Fatalf("expected stable response, got %q (%s)", rr.Body.String(), rr.Header().Get("X-Canary"))
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	req.Header.Set("X-Canary", "canary")
+	req.Header.Set("X-Request-ID", "rid-1")
+	h.ServeHTTP(rr, req)
+	if rr.Body.String() != "canary /api/v1/ping rid-1" || rr.Header().Get("X-Canary") != canary.Canary {
+		t.Fatalf("expected proxied canary response, got %q (%s)", rr.Body.String(), rr.Header().Get("X-Canary"))
+	}
+}
+
+func TestCanaryRouting_UnreachableUpstream(t *testing.T) {
+	target, _ := url.Parse("http://127.0.0.1:1")
+	h := CanaryRouting(canary.Options{Percent: 100}, newCanaryProxy(target, testLogger()))(http.NotFoundHandler())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
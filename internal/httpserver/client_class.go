@@ -0,0 +1,34 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/useragent"
+)
+
+// ClassifyClient parses the User-Agent header, stores the result in the request
+// context, and counts traffic per client class.
+func ClassifyClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := useragent.Parse(r.UserAgent())
+		metrics.ObserveClientClass(string(info.Class))
+		next.ServeHTTP(w, r.WithContext(useragent.IntoContext(r.Context(), info)))
+	})
+}
+
+// unidentifiedOnly applies limit to bots and unidentified clients and passes
+// everyone else straight through.
+func unidentifiedOnly(limit func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := limit(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch useragent.FromContext(r.Context()).Class {
+			case useragent.ClassBot, useragent.ClassUnknown:
+				limited.ServeHTTP(w, r)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+)
+
+// uncompressedPathPrefixes are served byte for byte so that Content-Length and
+// byte ranges refer to the stored representation.
+var uncompressedPathPrefixes = []string{"/api/v1/files/", "/files/"}
+
+// Compress wraps chi's compression middleware, bypassing it for range requests,
+// stored file downloads and server-sent event streams, whose events would
+// wait in the compressor. Responses handled by cache (nil disables it) are
+// compressed once per distinct body. chi's writer hands Flush, Hijack and Push
+// to the writer below it only when that writer has them, so it gets a
+// respwriter.Writer, which always does. Response bodies are counted as
+// written by the handler and as sent on the wire.
+func Compress(level int, cache *CompressionCache) func(http.Handler) http.Handler {
+	compress := middleware.Compress(level)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := respwriter.Wrap(w)
+			sent := ww.BytesWritten()
+			if r.Header.Get("Range") != "" || hasAnyPrefix(r.URL.Path, uncompressedPathPrefixes) || response.AcceptsEventStream(r) {
+				next.ServeHTTP(ww, r)
+				n := ww.BytesWritten() - sent
+				metrics.ObserveBodyBytes("response", n, n)
+				return
+			}
+
+			var decoded *respwriter.Writer
+			counted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				decoded = &respwriter.Writer{ResponseWriter: w}
+				next.ServeHTTP(decoded, r)
+			})
+			defer func() {
+				if decoded != nil {
+					metrics.ObserveBodyBytes("response", ww.BytesWritten()-sent, decoded.BytesWritten())
+				}
+			}()
+			if cache.handles(r) {
+				cache.serve(ww, r, counted)
+				return
+			}
+			compress(counted).ServeHTTP(ww, r)
+		})
+	}
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
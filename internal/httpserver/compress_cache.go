@@ -0,0 +1,226 @@
+package httpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"crypto/sha256"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// CompressionCache keeps gzipped copies of hot responses keyed by the hash of
+// their uncompressed bytes, so identical bodies such as the OpenAPI document
+// are compressed once rather than on every request. Handlers still run; only
+// the compression is skipped. It is an LRU bounded by the compressed size.
+type CompressionCache struct {
+	level    int
+	prefixes []string // paths whose responses are cached
+	maxEntry int      // larger bodies are sent uncompressed
+
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is most recently used
+	items    map[[sha256.Size]byte]*list.Element
+}
+
+type compressedEntry struct {
+	key  [sha256.Size]byte
+	data []byte
+}
+
+// NewCompressionCache caches responses under prefixes in maxBytes of memory,
+// or returns nil when maxBytes or prefixes are empty.
+func NewCompressionCache(level int, maxBytes int64, prefixes []string) *CompressionCache {
+	if maxBytes <= 0 || len(prefixes) == 0 {
+		return nil
+	}
+	return &CompressionCache{
+		level:    level,
+		prefixes: prefixes,
+		maxEntry: int(min(maxBytes/4, 8<<20)),
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// handles reports whether the response to r goes through the cache.
+func (c *CompressionCache) handles(r *http.Request) bool {
+	return c != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		acceptsGzip(r.Header.Get("Accept-Encoding")) && hasAnyPrefix(r.URL.Path, c.prefixes)
+}
+
+// serve runs next into a buffer and writes its body gzipped from the cache.
+// Responses that are not 200, not compressible, already encoded or too large
+// are written as they are.
+func (c *CompressionCache) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	bw := &compressBuffer{ResponseWriter: w, limit: c.maxEntry}
+	next.ServeHTTP(bw, r)
+	if bw.passthrough {
+		return
+	}
+	h := w.Header()
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if bw.status != http.StatusOK || h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		w.WriteHeader(bw.status)
+		_, _ = w.Write(bw.buf.Bytes())
+		return
+	}
+
+	body := bw.buf.Bytes()
+	var data []byte
+	if response.Storable(h) {
+		key := sha256.Sum256(body)
+		var ok bool
+		if data, ok = c.get(key); ok {
+			metrics.ObserveCompressionCache("hit")
+		} else {
+			metrics.ObserveCompressionCache("miss")
+			data = gzipLevel(body, c.level)
+			c.add(key, data)
+		}
+	} else {
+		data = gzipLevel(body, c.level) // marked response.NoStore: compressed, not kept
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Add("Vary", "Accept-Encoding")
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+func (c *CompressionCache) get(key [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*compressedEntry).data, true
+}
+
+func (c *CompressionCache) add(key [sha256.Size]byte, data []byte) {
+	n := int64(len(data))
+	if n > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	c.items[key] = c.order.PushFront(&compressedEntry{key: key, data: data})
+	c.size += n
+	for c.size > c.maxBytes {
+		el := c.order.Back()
+		e := el.Value.(*compressedEntry)
+		c.order.Remove(el)
+		delete(c.items, e.key)
+		c.size -= int64(len(e.data))
+	}
+}
+
+func gzipLevel(b []byte, level int) []byte {
+	var out bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&out, level)
+	if err != nil {
+		zw = gzip.NewWriter(&out)
+	}
+	_, _ = zw.Write(b)
+	_ = zw.Close()
+	return out.Bytes()
+}
+
+// acceptsGzip reports whether an Accept-Encoding value allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// compressible reports whether a content type benefits from gzip.
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mt, "text/") || mt == "application/json" || mt == "application/javascript" ||
+		strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") || mt == "application/xml" || mt == "image/svg+xml"
+}
+
+// compressBuffer buffers a response up to limit bytes, then gives up and
+// streams the rest as it is.
+type compressBuffer struct {
+	http.ResponseWriter
+	limit       int
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *compressBuffer) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressBuffer) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.buf.Len()+len(b) <= w.limit {
+		return w.buf.Write(b)
+	}
+	// Too large to cache: send what we have uncompressed and stream the rest
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	w.buf = bytes.Buffer{}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush gives up on caching, like a body over the limit: a handler that
+// flushes wants the client to see what it wrote so far.
+func (w *compressBuffer) Flush() {
+	if !w.passthrough {
+		w.passthrough = true
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+			return
+		}
+		w.buf = bytes.Buffer{}
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressBuffer) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,79 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+func TestCompress_CachesIdenticalBodies(t *testing.T) {
+	doc := `{"openapi":"3.0.0","paths":{}}` + strings.Repeat(" ", 512)
+	calls := 0
+	cache := NewCompressionCache(5, 1<<20, []string{"/swagger/"})
+	h := Compress(5, cache)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, doc)
+	}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil)
+		req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+			t.Fatalf("expected a gzipped response with its length, got headers %v", rec.Header())
+		}
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("invalid gzip: %v", err)
+		}
+		if body, _ := io.ReadAll(zr); string(body) != doc {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+	if calls != 3 || len(cache.items) != 1 {
+		t.Fatalf("expected the handler to run each time and one cached entry, got %d calls and %d entries", calls, len(cache.items))
+	}
+}
+
+func TestCompress_CacheSkipsOtherResponses(t *testing.T) {
+	cache := NewCompressionCache(5, 1<<20, []string{"/swagger/"})
+	h := Compress(5, cache)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":"not_found"}`)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/swagger/missing.json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || rec.Body.String() != `{"error":"not_found"}` || len(cache.items) != 0 {
+		t.Fatalf("expected the 404 to pass through uncached, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCompress_CacheSkipsNoStoreResponses(t *testing.T) {
+	cache := NewCompressionCache(5, 1<<20, []string{"/swagger/"})
+	h := Compress(5, cache)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.NoStore(w)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"openapi":"3.0.0"}`+strings.Repeat(" ", 512))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" || len(cache.items) != 0 {
+		t.Fatalf("expected the no-store response compressed but not cached, got %v and %d entries", rec.Header(), len(cache.items))
+	}
+}
@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/contract"
+)
+
+// providerStates sets up the data consumer contracts rely on.
+var providerStates = map[string]contract.State{
+	"a task exists": func(h http.Handler) (map[string]string, error) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(`{"title":"Contract task"}`))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			return nil, fmt.Errorf("create task: %d %s", rec.Code, rec.Body.String())
+		}
+		var task struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+			return nil, err
+		}
+		return map[string]string{"task_id": task.ID}, nil
+	},
+}
+
+// TestContracts verifies the router against every consumer contract in
+// CONTRACTS_DIR (the repository's contracts directory by default), so a
+// handler change that breaks an existing consumer fails the build.
+func TestContracts(t *testing.T) {
+	dir := os.Getenv("CONTRACTS_DIR")
+	if dir == "" {
+		dir = "../../contracts"
+	}
+	contracts, err := contract.LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load contracts: %v", err)
+	}
+	if len(contracts) == 0 {
+		t.Skipf("no contracts in %s", dir)
+	}
+	for _, c := range contracts {
+		t.Run(c.Consumer, func(t *testing.T) {
+			// A fresh router per consumer keeps provider states independent
+			for _, m := range contract.Verify(notFoundTestRouter("test"), c, providerStates) {
+				t.Error(m.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/cors"
+
+	"github.com/mikko-kohtala/go-api/internal/corsreport"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// maxOriginCacheEntries bounds the dynamic origin decision cache.
+const maxOriginCacheEntries = 1024
+
+// CORSPolicy describes the CORS rules for a group of routes.
+type CORSPolicy struct {
+	// AllowedOrigins lists exact origins, "*" for any origin, or subdomain
+	// patterns such as "https://*.example.com".
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// AllowOriginFunc decides dynamically whether an origin may access the routes
+	// (e.g. a tenant registry lookup). When set it takes precedence over AllowedOrigins.
+	AllowOriginFunc func(r *http.Request, origin string) bool
+	// OriginCacheTTL caches AllowOriginFunc decisions per origin; 0 disables caching.
+	OriginCacheTTL time.Duration
+	// MaxAge is how long browsers may cache preflight responses; 0 uses the default of 5 minutes.
+	MaxAge time.Duration
+}
+
+func (p CORSPolicy) handler(group string) func(http.Handler) http.Handler {
+	maxAge := p.MaxAge
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	opts := cors.Options{
+		AllowedMethods:   p.AllowedMethods,
+		AllowedHeaders:   p.AllowedHeaders,
+		ExposedHeaders:   []string{"Link", "Location", "Tus-Resumable", "Upload-Offset", "Upload-Length", "Upload-Expires", "X-Experiments", "X-Canary", "X-Response-Time", "API-Version"},
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           int(maxAge.Seconds()),
+	}
+
+	if p.AllowOriginFunc == nil && containsWildcard(p.AllowedOrigins) {
+		// Let the library answer with "*" rather than echoing each origin
+		opts.AllowedOrigins = []string{"*"}
+		handler := cors.Handler(opts)
+		return func(next http.Handler) http.Handler {
+			h := handler(next)
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if isPreflight(r) {
+					metrics.ObserveCORSPreflight(group, "allowed")
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	allow := p.AllowOriginFunc
+	if allow == nil {
+		patterns := p.AllowedOrigins
+		allow = func(_ *http.Request, origin string) bool { return matchOrigin(patterns, origin) }
+	} else if p.OriginCacheTTL > 0 {
+		allow = newOriginCache(allow, p.OriginCacheTTL).allow
+	}
+	opts.AllowOriginFunc = func(r *http.Request, origin string) bool {
+		preflight := isPreflight(r)
+		if allow(r, origin) {
+			if preflight {
+				metrics.ObserveCORSPreflight(group, "allowed")
+			}
+			return true
+		}
+		if preflight {
+			metrics.ObserveCORSPreflight(group, "rejected")
+		}
+		if !sameOrigin(r, origin) {
+			metrics.ObserveCORSRejected(group)
+			// Origins are unbounded, so they go to the log and the admin API
+			// rather than metric labels; each is logged once while remembered
+			if corsreport.Default.Record(group, origin, r.URL.Path, preflight) {
+				pkglogger.FromContext(r.Context()).Warn("cors origin rejected",
+					slog.String("origin", origin),
+					slog.String("policy", group),
+					slog.String("path", r.URL.Path),
+					slog.Bool("preflight", preflight))
+			}
+		}
+		return false
+	}
+	return cors.Handler(opts)
+}
+
+// CORS applies def to all requests except those under a path prefix in
+// overrides, which use the policy registered for the longest matching prefix.
+// Dispatch happens before routing so preflight requests see the same policy as
+// the actual request.
+func CORS(def CORSPolicy, overrides map[string]CORSPolicy) func(http.Handler) http.Handler {
+	prefixes := make([]string, 0, len(overrides))
+	for p := range overrides {
+		prefixes = append(prefixes, strings.TrimSuffix(p, "/"))
+	}
+	// Longest prefix first so the most specific group wins
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	return func(next http.Handler) http.Handler {
+		defHandler := def.handler("default")(next)
+		byPrefix := make(map[string]http.Handler, len(overrides))
+		for p, policy := range overrides {
+			p = strings.TrimSuffix(p, "/")
+			byPrefix[p] = policy.handler(p)(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range prefixes {
+				if r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") {
+					byPrefix[p].ServeHTTP(w, r)
+					return
+				}
+			}
+			defHandler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsWildcard(origins []string) bool {
+	for _, o := range origins {
+		if strings.TrimSpace(o) == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin reports whether origin matches one of the patterns. A pattern of
+// the form "scheme://*.domain" matches any subdomain depth of domain but not the
+// bare domain itself.
+func matchOrigin(patterns []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "*" || p == origin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(p, "://*.")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			// Reject tricks like "https://evil.com/.example.com" by requiring a bare host
+			sub := strings.TrimSuffix(strings.TrimPrefix(origin, scheme+"://"), "."+host)
+			if sub != "" && !strings.ContainsAny(sub, "/?#@") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// sameOrigin reports whether origin points at the host serving the request, in
+// which case a CORS refusal is not a misconfiguration worth counting.
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// originCache memoizes dynamic origin decisions for a TTL.
+type originCache struct {
+	fn  func(r *http.Request, origin string) bool
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]originDecision
+}
+
+type originDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+func newOriginCache(fn func(r *http.Request, origin string) bool, ttl time.Duration) *originCache {
+	return &originCache{fn: fn, ttl: ttl, entries: make(map[string]originDecision)}
+}
+
+func (c *originCache) allow(r *http.Request, origin string) bool {
+	now := time.Now()
+	c.mu.Lock()
+	if d, ok := c.entries[origin]; ok && now.Before(d.expires) {
+		c.mu.Unlock()
+		return d.allowed
+	}
+	c.mu.Unlock()
+
+	allowed := c.fn(r, origin)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxOriginCacheEntries {
+		// Cheap bound: start over rather than tracking recency
+		c.entries = make(map[string]originDecision)
+	}
+	c.entries[origin] = originDecision{allowed: allowed, expires: now.Add(c.ttl)}
+	return allowed
+}
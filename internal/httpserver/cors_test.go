@@ -0,0 +1,122 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/corsreport"
+)
+
+func TestCORS_PerRouteOverrides(t *testing.T) {
+	def := CORSPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "POST"}}
+	admin := def
+	admin.AllowedOrigins = []string{"https://ops.example.com"}
+	tenant := def
+	tenant.AllowedOrigins = nil
+	tenant.AllowOriginFunc = func(r *http.Request, origin string) bool { return origin == "https://acme.example.com" }
+
+	h := CORS(def, map[string]CORSPolicy{"/admin": admin, "/api/v1/tenants/": tenant})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	cases := []struct {
+		path, origin, want string
+	}{
+		{"/api/v1/ping", "https://anything.example.org", "*"},
+		{"/admin/config", "https://ops.example.com", "https://ops.example.com"},
+		{"/admin/config", "https://evil.example.org", ""},
+		{"/administrator", "https://evil.example.org", "*"},
+		{"/api/v1/tenants/42", "https://acme.example.com", "https://acme.example.com"},
+		{"/api/v1/tenants/42", "https://ops.example.com", ""},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, tc.path, nil)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		h.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tc.want {
+			t.Errorf("preflight %s from %s: Access-Control-Allow-Origin=%q, want %q", tc.path, tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestMatchOrigin_SubdomainPatterns(t *testing.T) {
+	patterns := []string{"https://*.example.com", "http://localhost:3000"}
+	cases := map[string]bool{
+		"https://app.example.com":              true,
+		"https://a.b.example.com":              true,
+		"https://APP.Example.com":              true,
+		"https://example.com":                  false,
+		"http://app.example.com":               false,
+		"https://evilexample.com":              false,
+		"https://evil.com/.example.com":        false,
+		"http://localhost:3000":                true,
+		"http://localhost:3001":                false,
+		"https://app.example.com.evil.org":     false,
+		"https://user@app.example.com":         false,
+		"https://app.example.com?.example.com": false,
+	}
+	for origin, want := range cases {
+		if got := matchOrigin(patterns, origin); got != want {
+			t.Errorf("matchOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCORS_DynamicOriginDecisionsAreCached(t *testing.T) {
+	calls := 0
+	policy := CORSPolicy{
+		AllowedMethods: []string{"GET"},
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			calls++
+			return origin == "https://tenant.example.com"
+		},
+		OriginCacheTTL: time.Minute,
+		MaxAge:         time.Hour,
+	}
+	h := CORS(policy, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/ping", nil)
+		req.Header.Set("Origin", "https://tenant.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		h.ServeHTTP(rr, req)
+		if rr.Header().Get("Access-Control-Allow-Origin") != "https://tenant.example.com" {
+			t.Fatalf("expected origin to be allowed")
+		}
+		if rr.Header().Get("Access-Control-Max-Age") != "3600" {
+			t.Fatalf("expected preflight max age of 3600, got %q", rr.Header().Get("Access-Control-Max-Age"))
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single AllowOriginFunc call, got %d", calls)
+	}
+}
+
+func TestCORS_RecordsRejectedOrigins(t *testing.T) {
+	saved := corsreport.Default
+	corsreport.Default = corsreport.New(10)
+	defer func() { corsreport.Default = saved }()
+
+	policy := CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{"GET", "POST"}}
+	h := CORS(policy, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	for _, origin := range []string{"https://app.example.com", "https://stale.example.com", "https://stale.example.com"} {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/tasks", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Same-origin requests are not a misconfiguration
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/v1/tasks", nil)
+	req.Header.Set("Origin", "http://api.example.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := corsreport.Default.Recent()
+	if len(got) != 1 || got[0].Origin != "https://stale.example.com" || got[0].Count != 2 || !got[0].Preflight || got[0].Policy != "default" {
+		t.Fatalf("unexpected rejections %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// amplificationFloor is the decoded size below which the ratio of a gzip body
+// is not checked: small repetitive payloads compress well and are harmless.
+const amplificationFloor = 64 << 10 // 64 KiB
+
+// DecompressRequest transparently decodes gzip request bodies. It must run
+// before BodyLimit so the limit applies to the decompressed size.
+// Unsupported encodings are rejected with 415.
+//
+// Bodies are counted as received on the wire and as decoded for the
+// handler. A gzip body decoding to more than maxRatio times its wire size
+// (0 disables the check) is cut off with a warning: reading it fails with an
+// error handlers answer with 413, like any body over the limit.
+func DecompressRequest(maxRatio int, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" {
+				body := &countingBody{ReadCloser: r.Body}
+				r.Body = body
+				next.ServeHTTP(w, r)
+				metrics.ObserveBodyBytes("request", body.n, body.n)
+				return
+			}
+			if encoding != "gzip" && encoding != "x-gzip" {
+				response.Error(w, r, http.StatusUnsupportedMediaType, "unsupported_encoding",
+					"Content-Encoding "+encoding+" is not supported; use gzip", nil)
+				return
+			}
+
+			wire := &countingBody{ReadCloser: r.Body}
+			zr, err := gzip.NewReader(wire)
+			if err != nil {
+				response.Error(w, r, http.StatusBadRequest, "invalid_encoding", "Request body is not valid gzip", nil)
+				return
+			}
+			body := &gzipBody{Reader: zr, wire: wire, maxRatio: maxRatio}
+			r.Body = body
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+
+			metrics.ObserveBodyBytes("request", wire.n, body.decoded)
+			metrics.ObserveDecompression(wire.n, body.decoded, body.err != nil)
+			if body.err != nil {
+				logger.Warn("request body decompression amplification exceeded",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.Int64("wire_bytes", wire.n),
+					slog.Int64("decoded_bytes", body.decoded),
+					slog.Int("max_ratio", maxRatio))
+			}
+		})
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// gzipBody decodes a gzip request body, counting the decoded bytes, and
+// closes both the gzip stream and the underlying wire body.
+type gzipBody struct {
+	*gzip.Reader
+	wire     *countingBody
+	maxRatio int
+	decoded  int64
+	err      *amplificationError
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.Reader.Read(p)
+	b.decoded += int64(n)
+	if b.maxRatio > 0 && b.decoded > amplificationFloor && b.decoded > int64(b.maxRatio)*b.wire.n {
+		b.err = &amplificationError{ratio: b.maxRatio, limit: http.MaxBytesError{Limit: int64(b.maxRatio) * b.wire.n}}
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *gzipBody) Close() error {
+	_ = b.Reader.Close()
+	return b.wire.Close()
+}
+
+// amplificationError is returned reading a gzip body that decodes to more
+// than the allowed multiple of its wire size. It unwraps to an
+// http.MaxBytesError holding the decoded size the wire size allowed.
+type amplificationError struct {
+	ratio int
+	limit http.MaxBytesError
+}
+
+func (e *amplificationError) Error() string {
+	return "request body decompresses to more than " + strconv.Itoa(e.ratio) + " times its size"
+}
+
+func (e *amplificationError) Unwrap() error { return &e.limit }
@@ -0,0 +1,114 @@
+package httpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressRequest_GzipBodyIsDecoded(t *testing.T) {
+	h := notFoundTestRouter("test")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(gzipBytes(t, `{"title":"zipped"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), "zipped") {
+		t.Fatalf("expected task created from decompressed body, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDecompressRequest_LimitAppliesToDecompressedSize(t *testing.T) {
+	var payload []byte
+	h := DecompressRequest(0, testLogger())(BodyLimit(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		payload, err = io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+	})))
+
+	// 1000 bytes of zeros compress to far less than 64 bytes on the wire
+	body := gzipBytes(t, strings.Repeat("0", 1000))
+	if len(body) >= 64 {
+		t.Fatalf("test precondition: compressed body should be under the limit, got %d", len(body))
+	}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected decompressed size to trip the limit, got %d (read %d bytes)", rr.Code, len(payload))
+	}
+}
+
+func TestDecompressRequest_RejectsInvalidAndUnsupported(t *testing.T) {
+	h := DecompressRequest(0, testLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for corrupt gzip, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "br")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for unsupported encoding, got %d", rr.Code)
+	}
+}
+
+func TestDecompressRequest_CutsOffAmplifiedBodies(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	var readErr error
+	h := DecompressRequest(100, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+	serve := func(payload string) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipBytes(t, payload)))
+		req.Header.Set("Content-Encoding", "gzip")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Repetitive but small bodies are not checked
+	serve(strings.Repeat("0", 32<<10))
+	if readErr != nil {
+		t.Fatalf("expected a small body read in full, got %v", readErr)
+	}
+
+	// 4 MiB of zeros are a few KiB of gzip
+	serve(strings.Repeat("0", 4<<20))
+	if _, ok := validate.TooLarge(readErr); !ok {
+		t.Fatalf("expected an amplified body cut off as too large, got %v", readErr)
+	}
+	if logged := buf.String(); !strings.Contains(logged, "decompression amplification exceeded") || !strings.Contains(logged, `"max_ratio":100`) {
+		t.Fatalf("expected a warning, got %s", logged)
+	}
+}
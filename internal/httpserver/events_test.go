@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+// TestEvents_StreamOutlivesRequestTimeout reads /api/v1/events through the
+// whole middleware chain: events arrive uncompressed and one by one, and the
+// stream lasts past REQUEST_TIMEOUT.
+func TestEvents_StreamOutlivesRequestTimeout(t *testing.T) {
+	cfg := &config.Config{
+		Env:                   "test",
+		RequestTimeout:        100 * time.Millisecond,
+		BodyLimitBytes:        1 << 20,
+		CORSAllowedOrigins:    []string{"*"},
+		CORSAllowedMethods:    []string{"GET"},
+		CORSAllowedHeaders:    []string{"*"},
+		CompressionLevel:      5,
+		ResponseGuard:         "strict",
+		ResponseGuardMaxItems: 1,
+		ResponseGuardMaxBytes: 1,
+	}
+	srv := httptest.NewServer(NewRouter(cfg, testLogger()))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/events?interval=1", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an uncompressed event stream, got %v", resp.Header)
+	}
+
+	br := bufio.NewReader(resp.Body)
+	for _, id := range []string{"id: 1\n", "id: 2\n"} {
+		line, err := br.ReadString('\n')
+		if err != nil || line != id {
+			t.Fatalf("expected %q, got %q (err %v)", id, line, err)
+		}
+		for line != "\n" {
+			if line, err = br.ReadString('\n'); err != nil {
+				t.Fatalf("read event: %v", err)
+			}
+			if strings.HasPrefix(line, "data: ") && !strings.Contains(line, "goroutines") {
+				t.Fatalf("expected system stats, got %q", line)
+			}
+		}
+	}
+}
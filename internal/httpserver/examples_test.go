@@ -0,0 +1,194 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/docs/examples"
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+var updateExamples = flag.Bool("update", false, "rewrite the golden responses in internal/docs/examples")
+
+// goldenExamples are the requests whose responses the API documentation shows
+// as examples. They run in order against one router, so later requests see
+// what earlier ones created.
+var goldenExamples = []struct {
+	route  string
+	status int
+	method string
+	path   string
+	body   string
+}{
+	{"health.live", http.StatusOK, http.MethodGet, "/healthz", ""},
+	{"users.list", http.StatusOK, http.MethodGet, "/api/v1/users", ""},
+	{"users.get", http.StatusOK, http.MethodGet, "/api/v1/users/usr_001", ""},
+	{"users.get", http.StatusNotFound, http.MethodGet, "/api/v1/users/usr_missing", ""},
+	{"users.create", http.StatusCreated, http.MethodPost, "/api/v1/users", `{"email":"ada@example.com","name":"Ada Lovelace"}`},
+	{"users.create", http.StatusBadRequest, http.MethodPost, "/api/v1/users", `{"email":"not-an-email"}`},
+	{"tasks.create", http.StatusCreated, http.MethodPost, "/api/v1/tasks", `{"title":"Write the release notes"}`},
+	{"tasks.list", http.StatusOK, http.MethodGet, "/api/v1/tasks", ""},
+}
+
+// Generated IDs and timestamps differ on every run; golden responses hold
+// these in their place.
+const (
+	exampleID   = "019b796d-d600-7000-8000-000000000000" // a UUIDv7 of exampleTime
+	exampleTime = "2026-01-01T12:00:00Z"
+)
+
+// TestGoldenExamples replays goldenExamples and compares each response with
+// its golden file, so the documented examples are what the handlers return.
+// Run with -update to rewrite the files after an intended change.
+func TestGoldenExamples(t *testing.T) {
+	h := notFoundTestRouter("test")
+	seen := map[string]bool{}
+	for _, ex := range goldenExamples {
+		file := fmt.Sprintf("%s.%d.json", ex.route, ex.status)
+		seen[file] = true
+		if _, ok := routemeta.Default.Lookup(ex.method, routePattern(t, ex.route)); !ok {
+			t.Fatalf("%s: no %s route named %s", file, ex.method, ex.route)
+		}
+
+		req := httptest.NewRequest(ex.method, ex.path, strings.NewReader(ex.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", "req_example")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != ex.status {
+			t.Fatalf("%s: expected %d, got %d %s", file, ex.status, rec.Code, rec.Body.String())
+		}
+		got, err := normalizeExample(rec.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+
+		path := filepath.Join("..", "docs", "examples", file)
+		if *updateExamples {
+			if err := os.WriteFile(path, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := fs.ReadFile(examples.FS, file)
+		if err != nil {
+			t.Errorf("%s: missing golden file; run with -update", file)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: response differs from the golden file; run with -update if intended\ngot:\n%s\nwant:\n%s", file, got, want)
+		}
+	}
+
+	files, _ := fs.Glob(examples.FS, "*.json")
+	for _, file := range files {
+		if !seen[file] && !*updateExamples {
+			t.Errorf("%s: no golden example request produces it", file)
+		}
+	}
+
+	// The served document shows them
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Examples map[string]any `json:"examples"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &spec)
+	if spec.Paths["/api/v1/users"]["get"].Responses["200"].Examples["application/json"] == nil {
+		t.Fatalf("users.list example missing from the swagger document")
+	}
+}
+
+func TestWithExamples_SetsResponseExamples(t *testing.T) {
+	doc := []byte(`{"paths":{"/api/v1/users/{id}":{"get":{"operationId":"users.get","responses":{"200":{"description":"OK"}}}}}}`)
+	fsys := fstest.MapFS{
+		"users.get.200.json":  {Data: []byte(`{"id":"usr_001"}`)},
+		"users.get.404.json":  {Data: []byte(`{"error":"not_found"}`)},
+		"users.gone.200.json": {Data: []byte(`{}`)},
+	}
+	out, err := withExamples(doc, fsys)
+	if err != nil {
+		t.Fatalf("withExamples: %v", err)
+	}
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Description string                     `json:"description"`
+				Examples    map[string]json.RawMessage `json:"examples"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	_ = json.Unmarshal(out, &spec)
+	responses := spec.Paths["/api/v1/users/{id}"]["get"].Responses
+	if string(responses["200"].Examples["application/json"]) != `{"id":"usr_001"}` || responses["200"].Description != "OK" {
+		t.Fatalf("200 example not set: %+v", responses["200"])
+	}
+	if string(responses["404"].Examples["application/json"]) != `{"error":"not_found"}` || responses["404"].Description != "Not Found" {
+		t.Fatalf("undocumented 404 not added with its example: %+v", responses["404"])
+	}
+}
+
+func routePattern(t *testing.T, name string) string {
+	t.Helper()
+	for _, route := range routemeta.Default.Routes() {
+		if route.Name == name {
+			return route.Pattern
+		}
+	}
+	t.Fatalf("no route named %s", name)
+	return ""
+}
+
+// normalizeExample replaces generated IDs and timestamps in body with
+// exampleID and exampleTime and indents it.
+func normalizeExample(body []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(normalizeValue(v), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalizeValue(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = normalizeValue(e)
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return exampleTime
+		}
+		if _, ok := ids.Time(v); ok {
+			if i := strings.LastIndexByte(v, '_'); i >= 0 {
+				return v[:i+1] + exampleID
+			}
+			return exampleID
+		}
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/experiments"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+)
+
+// experimentCookie identifies browsers without an API key across requests.
+const experimentCookie = "exp_uid"
+
+// AssignExperiments buckets every request into the configured experiments.
+// The unit is the caller's API key, falling back to a long-lived cookie that
+// is issued on first contact. Assignments are stored in the request context
+// (and so appear in the request log), returned in the X-Experiments header,
+// and published as exposure events on bus.
+func AssignExperiments(exps []experiments.Experiment, bus *events.Bus) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(exps) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			unit := usage.KeyFromRequest(r)
+			if unit == usage.Anonymous {
+				unit = experimentUnitCookie(w, r)
+			}
+			assigned := experiments.AssignAll(exps, unit)
+			w.Header().Set("X-Experiments", assigned.String())
+
+			now := time.Now().UTC()
+			rid := GetRequestID(r.Context())
+			for _, a := range assigned {
+				bus.Publish(r.Context(), experiments.ExposureTopic, experiments.Exposure{
+					Experiment: a.Experiment,
+					Variant:    a.Variant,
+					Unit:       unit,
+					RequestID:  rid,
+					Time:       now,
+				})
+			}
+			next.ServeHTTP(w, r.WithContext(experiments.IntoContext(r.Context(), assigned)))
+		})
+	}
+}
+
+// experimentUnitCookie returns the request's experiment cookie, issuing a new
+// one when it is missing.
+func experimentUnitCookie(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(experimentCookie); err == nil && len(c.Value) == 32 {
+		return "cookie_" + c.Value
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     experimentCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return "cookie_" + id
+}
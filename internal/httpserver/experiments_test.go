@@ -0,0 +1,59 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/experiments"
+)
+
+func TestAssignExperiments(t *testing.T) {
+	exps, _ := experiments.Parse("checkout=control:50,green:50")
+	bus := events.NewBus()
+	var exposures []experiments.Exposure
+	bus.Subscribe(experiments.ExposureTopic, func(_ context.Context, p any) {
+		exposures = append(exposures, p.(experiments.Exposure))
+	})
+	var seen string
+	h := AssignExperiments(exps, bus)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = experiments.FromContext(r.Context()).Variant("checkout")
+	}))
+
+	// First contact from a browser issues a cookie that keeps the assignment stable
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != experimentCookie {
+		t.Fatalf("expected experiment cookie, got %v", cookies)
+	}
+	first := rr.Header().Get("X-Experiments")
+	if first != "checkout="+seen || seen == "" {
+		t.Fatalf("header %q does not match context variant %q", first, seen)
+	}
+	for i := 0; i < 5; i++ {
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		h.ServeHTTP(rr, req)
+		if rr.Header().Get("X-Experiments") != first || len(rr.Result().Cookies()) != 0 {
+			t.Fatalf("assignment changed or cookie reissued on request %d", i)
+		}
+	}
+
+	if len(exposures) != 6 || !strings.HasPrefix(exposures[0].Unit, "cookie_") || exposures[0].Experiment != "checkout" {
+		t.Fatalf("unexpected exposures: %+v", exposures)
+	}
+
+	// API keys are bucketed by key, without cookies
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "k")
+	h.ServeHTTP(rr, req)
+	if len(rr.Result().Cookies()) != 0 || !strings.HasPrefix(exposures[6].Unit, "key_") {
+		t.Fatalf("expected API key unit without cookie, got %+v", exposures[6])
+	}
+}
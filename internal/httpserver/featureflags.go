@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/featureflags"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+	"github.com/mikko-kohtala/go-api/internal/useragent"
+)
+
+// FlagContext builds the feature flag evaluation context from request
+// attributes: the API key is both the targeting key and the tenant, the
+// country comes from countryHeader (set by the CDN or load balancer), plus
+// the client class and IP. There is no authenticated user yet.
+func FlagContext(countryHeader string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := usage.KeyFromRequest(r)
+			ec := featureflags.EvaluationContext{
+				Attributes: map[string]any{
+					"tenant":       tenant,
+					"client_class": string(useragent.FromContext(r.Context()).Class),
+					"ip":           r.RemoteAddr,
+				},
+			}
+			if tenant != usage.Anonymous {
+				ec.TargetingKey = tenant
+			}
+			if c := strings.ToUpper(strings.TrimSpace(r.Header.Get(countryHeader))); c != "" {
+				ec.Attributes["country"] = c
+			}
+			next.ServeHTTP(w, r.WithContext(featureflags.IntoContext(r.Context(), ec)))
+		})
+	}
+}
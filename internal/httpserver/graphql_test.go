@@ -0,0 +1,71 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+func graphqlTestConfig() *config.Config {
+	return &config.Config{
+		Env:                  "test",
+		RequestTimeout:       5 * time.Second,
+		BodyLimitBytes:       1 << 20,
+		CORSAllowedOrigins:   []string{"*"},
+		CORSAllowedMethods:   []string{"GET", "POST"},
+		CORSAllowedHeaders:   []string{"*"},
+		CompressionLevel:     5,
+		GraphQLMaxDepth:      2,
+		GraphQLMaxSelections: 100,
+	}
+}
+
+// TestGraphQL_QueriesUsersThroughTheMiddlewareChain posts a query to
+// /graphql: unknown users are null or left out of lists, not errors.
+func TestGraphQL_QueriesUsersThroughTheMiddlewareChain(t *testing.T) {
+	r := NewRouter(graphqlTestConfig(), testLogger())
+
+	body := `{"query":"query($id: ID!) { a: user(id: $id) { name } b: user(id: \"usr_404\") { name } users(ids: [\"usr_002\", \"usr_404\"]) { id role } }","variables":{"id":"usr_001"}}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	want := `{"data":{"a":{"name":"John Doe"},"b":null,"users":[{"id":"usr_002","role":"user"}]}}`
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != want {
+		t.Fatalf("expected 200 %s, got %d %s", want, rr.Code, rr.Body.String())
+	}
+}
+
+func TestGraphQL_RejectsInvalidQueries(t *testing.T) {
+	r := NewRouter(graphqlTestConfig(), testLogger())
+
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{`{ user(id: "usr_001") { password } }`, `Cannot query field \"password\" on type \"User\".`},
+		{`{ stats { system { cpus } } }`, `deeper than 2 levels`},
+		{``, "Missing query"},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(tc.query), nil))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tc.want) || strings.Contains(rr.Body.String(), `"data"`) {
+			t.Fatalf("%q: expected 400 with %q and no data, got %d %s", tc.query, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestGraphQL_ServesTheSchema(t *testing.T) {
+	r := NewRouter(graphqlTestConfig(), testLogger())
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "user(id: ID!): User") {
+		t.Fatalf("expected the SDL, got %d %s", rr.Code, rr.Body.String())
+	}
+}
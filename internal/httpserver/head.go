@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AutoHead answers HEAD requests for routes that only register GET with the
+// GET handler, sending its headers without the body. Content-Length is the
+// length of the body GET sends, unless the handler set one or flushed
+// before finishing. Routes registering HEAD themselves keep their handler.
+// It runs outside compression so the length is that of the encoded body.
+func AutoHead(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		if r.Method != http.MethodHead || rctx == nil || rctx.Routes == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Mux.Match reports a match for any method of a route in a mounted
+		// router, so look at the route table instead
+		methods := routeMethods(rctx.Routes, r.URL.Path)
+		if methods[http.MethodHead] || !methods[http.MethodGet] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rctx.RouteMethod = http.MethodGet
+		hw := &headWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		hw.send(true)
+	})
+}
+
+// headWriter discards the body and holds the header back until the handler
+// is done, when the body length is known.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+	sent   bool
+}
+
+func (w *headWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.n += int64(len(b))
+	return len(b), nil
+}
+
+// Flush sends the header without a Content-Length; a streaming handler's
+// body has no length known up front.
+func (w *headWriter) Flush() {
+	w.send(false)
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *headWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *headWriter) send(done bool) {
+	if w.sent {
+		return
+	}
+	w.sent = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if done && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" && bodyAllowed(w.status) {
+		h.Set("Content-Length", strconv.FormatInt(w.n, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// bodyAllowed reports whether a response with status has a body.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+)
+
+// HeaderPolicy holds the standard headers set on every response.
+type HeaderPolicy struct {
+	Server       string            // Server header value; removed when empty
+	ResponseTime bool              // add X-Response-Time, the time to the first byte
+	Headers      map[string]string // set on every response
+	CacheControl map[string]string // default Cache-Control per route class
+}
+
+// Route classes of HeaderPolicy.CacheControl.
+const (
+	routeClassAPI  = "api"
+	routeClassOps  = "ops"
+	routeClassDocs = "docs"
+)
+
+// newHeaderPolicy builds the policy from the configuration.
+func newHeaderPolicy(cfg *config.Config) HeaderPolicy {
+	return HeaderPolicy{
+		Server:       cfg.ServerHeader,
+		ResponseTime: cfg.ResponseTimeHeader,
+		Headers:      cfg.ResponseHeaders,
+		CacheControl: map[string]string{
+			routeClassAPI:  cfg.CacheControlAPI,
+			routeClassOps:  cfg.CacheControlOps,
+			routeClassDocs: cfg.CacheControlDocs,
+		},
+	}
+}
+
+// ResponseHeaders applies p to every response just before its header is
+// written, so handlers no longer set these headers themselves. A
+// Cache-Control set by the handler is kept.
+func ResponseHeaders(p HeaderPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			cacheControl := p.CacheControl[routeClass(r.URL.Path)]
+			ww := respwriter.Wrap(w)
+			ww.OnHeader(func(int) {
+				h := ww.Header()
+				for name, value := range p.Headers {
+					h.Set(name, value)
+				}
+				if p.Server != "" {
+					h.Set("Server", p.Server)
+				} else {
+					h.Del("Server")
+				}
+				if cacheControl != "" && h.Get("Cache-Control") == "" {
+					h.Set("Cache-Control", cacheControl)
+				}
+				if p.ResponseTime {
+					h.Set("X-Response-Time", time.Since(start).String())
+				}
+			})
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// routeClass groups paths that share header defaults.
+func routeClass(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/"):
+		return routeClassAPI
+	case strings.HasPrefix(path, "/swagger/"), path == "/api-docs", strings.HasPrefix(path, "/api-docs/"):
+		return routeClassDocs
+	case path == "/healthz", path == "/readyz", path == "/metrics", strings.HasPrefix(path, "/admin/"):
+		return routeClassOps
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseHeaders_AppliesPolicy(t *testing.T) {
+	policy := HeaderPolicy{
+		ResponseTime: true,
+		Headers:      map[string]string{"X-Org": "acme"},
+		CacheControl: map[string]string{routeClassAPI: "private, no-cache", routeClassOps: "no-store"},
+	}
+	h := ResponseHeaders(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "leaky/1.0")
+		if r.URL.Path == "/api/v1/images/1" {
+			w.Header().Set("Cache-Control", "private, max-age=86400")
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	cases := []struct{ path, cacheControl string }{
+		{"/api/v1/tasks", "private, no-cache"},
+		{"/api/v1/images/1", "private, max-age=86400"},
+		{"/healthz", "no-store"},
+		{"/", ""},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if got := rr.Header().Get("Cache-Control"); got != tc.cacheControl {
+			t.Fatalf("%s: expected Cache-Control %q, got %q", tc.path, tc.cacheControl, got)
+		}
+		if rr.Header().Get("Server") != "" || rr.Header().Get("X-Org") != "acme" || rr.Header().Get("X-Response-Time") == "" {
+			t.Fatalf("%s: unexpected headers %v", tc.path, rr.Header())
+		}
+	}
+}
+
+func TestResponseHeaders_ServerOverride(t *testing.T) {
+	h := ResponseHeaders(HeaderPolicy{Server: "api"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil))
+	if rr.Header().Get("Server") != "api" || rr.Header().Get("X-Response-Time") != "" {
+		t.Fatalf("unexpected headers %v", rr.Header())
+	}
+}
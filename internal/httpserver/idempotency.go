@@ -0,0 +1,190 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/mikko-kohtala/go-api/internal/idempotency"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// maxIdempotentBody bounds the response kept for a key; the key of a larger
+// response is released, so its retries run again.
+const maxIdempotentBody = 1 << 20
+
+// Idempotency makes POST and PATCH requests with an Idempotency-Key run once
+// per key: later attempts with the same key, method, URL and body get the
+// first response again with Idempotent-Replayed: true, and attempts arriving
+// while the first runs wait for it. The same key with a different request
+// gets 422 idempotency_key_reused. Keys are scoped to the credentials the
+// request carries, since this runs before authentication. Responses with
+// status 408, 429 or 5xx are not kept, so retries of them run again.
+func Idempotency(store *idempotency.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.Header)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !idempotency.ValidKey(key) {
+				response.Error(w, r, http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be 1 to 255 printable ASCII characters", nil)
+				return
+			}
+			scoped := credentialScope(r) + " " + key
+
+			prior, err := store.Claim(r.Context(), scoped)
+			if err != nil {
+				w.Header().Set("Retry-After", "1")
+				response.Error(w, r, http.StatusConflict, "idempotency_in_progress", "A request with this Idempotency-Key is still being processed", nil)
+				return
+			}
+			if prior != nil {
+				body := sha256.New()
+				if r.Body != nil {
+					_, _ = io.Copy(body, r.Body)
+				}
+				if fingerprint(r, body) != prior.Fingerprint {
+					response.FromError(w, r, idempotency.ErrKeyReused)
+					return
+				}
+				replay(w, r, prior)
+				return
+			}
+
+			body := sha256.New()
+			if r.Body != nil {
+				r.Body = &hashingReader{ReadCloser: r.Body, hash: body}
+			}
+			cw := &captureWriter{ResponseWriter: w, before: slices.Collect(maps.Keys(w.Header()))}
+			completed := false
+			defer func() {
+				if !completed {
+					store.Release(scoped) // a panic must not leave waiting attempts hanging
+				}
+			}()
+			next.ServeHTTP(cw, r)
+			if r.Body != nil {
+				_, _ = io.Copy(io.Discard, r.Body) // the fingerprint covers the whole body
+			}
+
+			completed = true
+			status := cw.status()
+			if cw.overflow || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500 {
+				store.Release(scoped)
+				return
+			}
+			store.Complete(scoped, &idempotency.Response{
+				Fingerprint: fingerprint(r, body),
+				Status:      status,
+				Header:      cw.handlerHeader(),
+				Body:        bytes.Clone(cw.body.Bytes()),
+			})
+		})
+	}
+}
+
+// credentialScope identifies the credentials of r without keeping them.
+func credentialScope(r *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, r.Header.Get("Authorization"))
+	io.WriteString(h, "\n")
+	io.WriteString(h, r.Header.Get("X-API-Key"))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// fingerprint identifies a request by its method, URL and the hash of its
+// body.
+func fingerprint(r *http.Request, body hash.Hash) string {
+	return r.Method + " " + r.URL.RequestURI() + " " + hex.EncodeToString(body.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, r *http.Request, prior *idempotency.Response) {
+	logger.FromContext(r.Context()).Debug("idempotent request replayed", slog.Int("status", prior.Status))
+	h := w.Header()
+	for k, v := range prior.Header {
+		h[k] = slices.Clone(v)
+	}
+	h.Set(idempotency.ReplayedHeader, "true")
+	response.Bytes(w, r, prior.Status, prior.Body)
+}
+
+// hashingReader hashes a request body as the handler reads it.
+type hashingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.ReadCloser.Read(p)
+	h.hash.Write(p[:n])
+	return n, err
+}
+
+// captureWriter passes a response through while keeping its status, up to
+// maxIdempotentBody of its body, and which headers were set before the
+// handler ran.
+type captureWriter struct {
+	http.ResponseWriter
+	before   []string
+	code     int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.code == 0 {
+		c.code = http.StatusOK
+	}
+	if !c.overflow {
+		if c.body.Len()+len(b) > maxIdempotentBody {
+			c.overflow = true
+			c.body = bytes.Buffer{}
+		} else {
+			c.body.Write(b)
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *captureWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+func (c *captureWriter) status() int {
+	if c.code == 0 {
+		return http.StatusOK
+	}
+	return c.code
+}
+
+// handlerHeader returns the headers the handler set; those set before it,
+// such as X-Request-ID, belong to the request and are set again on replay.
+func (c *captureWriter) handlerHeader() http.Header {
+	out := make(http.Header)
+	for k, v := range c.Header() {
+		if !slices.Contains(c.before, k) {
+			out[k] = slices.Clone(v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/idempotency"
+)
+
+func TestIdempotency_ReplaysTheFirstResponse(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	h := Idempotency(idempotency.NewStore(time.Hour, 100))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Location", "/api/v1/users/usr_"+strconv.Itoa(int(n)))
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, `{"n":`+strconv.Itoa(int(n))+`}`)
+	}))
+	post := func(key, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		req.Header.Set("Authorization", "Bearer service")
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Retryable outcomes are not kept, so the retry runs
+	if rr := post("k1", `{"name":"a"}`); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+	status = http.StatusCreated
+	first := post("k1", `{"name":"a"}`)
+	if first.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Fatalf("expected the retry to run, got %d after %d calls", first.Code, calls.Load())
+	}
+
+	again := post("k1", `{"name":"a"}`)
+	if again.Code != http.StatusCreated || again.Body.String() != first.Body.String() || again.Header().Get("Location") != first.Header().Get("Location") {
+		t.Fatalf("expected the first response again, got %d %v %s", again.Code, again.Header(), again.Body.String())
+	}
+	if again.Header().Get(idempotency.ReplayedHeader) != "true" || calls.Load() != 2 {
+		t.Fatalf("expected a replay without running the handler (%d calls)", calls.Load())
+	}
+	if rr := post("k1", `{"name":"b"}`); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "idempotency_key_reused") {
+		t.Fatalf("expected 422 for a reused key, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := post("k2", `{"name":"a"}`); rr.Code != http.StatusCreated || calls.Load() != 3 {
+		t.Fatalf("expected a new key to run, got %d", rr.Code)
+	}
+	if rr := post(strings.Repeat("k", 256), `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid key, got %d", rr.Code)
+	}
+}
+
+func TestIdempotency_ConcurrentAttemptsWaitForTheFirst(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	h := Idempotency(idempotency.NewStore(time.Hour, 100))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+	post := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "hedged")
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 2)
+	wg.Add(1)
+	go func() { defer wg.Done(); results[0] = post() }()
+	<-started
+	wg.Add(1)
+	go func() { defer wg.Done(); results[1] = post() }()
+	time.Sleep(20 * time.Millisecond) // the hedged attempt is waiting
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || results[0].Code != http.StatusCreated || results[1].Code != http.StatusCreated {
+		t.Fatalf("expected one run answering both attempts, got %d calls, %d and %d", calls.Load(), results[0].Code, results[1].Code)
+	}
+}
@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/mikko-kohtala/go-api/internal/assets"
+	"github.com/mikko-kohtala/go-api/internal/i18n"
+)
+
+// NegotiateLanguage picks the response language of every request from its
+// Accept-Language header and the catalog's languages. The language is stored
+// in the request context for i18n.T and returned in Content-Language.
+func NegotiateLanguage(catalog *i18n.Catalog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang := catalog.Negotiate(r.Header.Get("Accept-Language"))
+			w.Header().Add("Vary", "Accept-Language")
+			w.Header().Set("Content-Language", lang)
+			next.ServeHTTP(w, r.WithContext(i18n.WithLanguage(r.Context(), lang)))
+		})
+	}
+}
+
+// loadCatalogs adds the message catalogs of ASSETS_DIR to i18n.Default,
+// loading them again whenever it changes. Catalogs that fail to load are
+// logged and the previous ones kept.
+func loadCatalogs(appLogger *slog.Logger) {
+	if assets.Default.Dir() != "" {
+		load := func() {
+			dir, err := fs.Sub(assets.Default, i18n.CatalogDir)
+			if err == nil {
+				err = i18n.Default.Load(dir)
+			}
+			if err != nil {
+				appLogger.Error("message catalogs not loaded", slog.String("error", err.Error()))
+			}
+		}
+		load()
+		assets.Default.OnChange(load)
+	}
+	if !slices.Contains(i18n.Default.Languages(), i18n.Default.Fallback()) {
+		appLogger.Warn("no message catalog for DEFAULT_LANGUAGE; responses show message keys",
+			slog.String("language", i18n.Default.Fallback()))
+	}
+}
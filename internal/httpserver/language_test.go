@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestNegotiateLanguage_LocalizesUserRoles reads a user in the language of
+// Accept-Language, over REST and GraphQL.
+func TestNegotiateLanguage_LocalizesUserRoles(t *testing.T) {
+	h := notFoundTestRouter("test")
+
+	for header, want := range map[string]string{"": "Administrator", "fi-FI, en;q=0.8": "Ylläpitäjä", "sv": "Administrator"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/usr_001", nil)
+		req.Header.Set("Accept-Language", header)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		var user struct {
+			RoleDisplay string `json:"role_display"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &user); err != nil || user.RoleDisplay != want {
+			t.Fatalf("%q: expected role_display %q, got %d %s", header, want, rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(strings.Join(rr.Header().Values("Vary"), ","), "Accept-Language") {
+			t.Fatalf("expected Vary: Accept-Language, got %v", rr.Header().Values("Vary"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ users { roleDisplay } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fi")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if want := `{"data":{"users":[{"roleDisplay":"Ylläpitäjä"},{"roleDisplay":"Käyttäjä"}]}}`; strings.TrimSpace(rr.Body.String()) != want || rr.Header().Get("Content-Language") != "fi" {
+		t.Fatalf("expected %s in fi, got %s in %q", want, rr.Body.String(), rr.Header().Get("Content-Language"))
+	}
+}
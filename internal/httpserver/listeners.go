@@ -0,0 +1,153 @@
+package httpserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/proxyproto"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/routes"
+)
+
+// adminPathPrefixes are only served on the admin listener when one is configured.
+var adminPathPrefixes = []string{"/metrics", "/admin"}
+
+// docsPathPrefixes join them with DOCS_EXPOSURE=admin.
+var docsPathPrefixes = []string{"/swagger", "/api-docs"}
+
+// Listener describes one address the shared router is served on, along with
+// middleware that only applies to that listener.
+type Listener struct {
+	Name       string
+	Addr       string
+	CertFile   string
+	KeyFile    string
+	Middleware []func(http.Handler) http.Handler
+	// ProxyProtocol enables PROXY header parsing, honoured only from ProxyTrusted peers (none when empty).
+	ProxyProtocol bool
+	ProxyTrusted  []*net.IPNet
+}
+
+// TLS reports whether the listener serves HTTPS.
+func (l Listener) TLS() bool {
+	return l.CertFile != "" && l.KeyFile != ""
+}
+
+// Listeners derives the set of listeners declared in config. The plain HTTP
+// listener is always present; TLS and admin listeners are added when their port is set.
+func Listeners(cfg *config.Config) []Listener {
+	var public []func(http.Handler) http.Handler
+	if cfg.AdminPort > 0 {
+		hidden := adminPathPrefixes
+		if cfg.DocsExposure == routes.DocsAdmin {
+			hidden = append(slices.Clone(hidden), docsPathPrefixes...)
+		}
+		public = append(public, hidePaths(hidden))
+	}
+
+	httpMW := append([]func(http.Handler) http.Handler{}, public...)
+	if cfg.TLSPort > 0 && cfg.TLSRedirect {
+		httpMW = append([]func(http.Handler) http.Handler{RedirectToTLS(cfg.TLSPort)}, httpMW...)
+	}
+
+	// Config validated the CIDRs already
+	trusted, _ := proxyproto.ParseCIDRs(cfg.ProxyProtocolTrustedCIDRs)
+
+	listeners := []Listener{{
+		Name:          "http",
+		Addr:          fmt.Sprintf(":%d", cfg.Port),
+		Middleware:    httpMW,
+		ProxyProtocol: cfg.ProxyProtocol,
+		ProxyTrusted:  trusted,
+	}}
+	if cfg.TLSPort > 0 {
+		listeners = append(listeners, Listener{
+			Name:          "https",
+			Addr:          fmt.Sprintf(":%d", cfg.TLSPort),
+			CertFile:      cfg.TLSCertFile,
+			KeyFile:       cfg.TLSKeyFile,
+			Middleware:    public,
+			ProxyProtocol: cfg.ProxyProtocol,
+			ProxyTrusted:  trusted,
+		})
+	}
+	if cfg.AdminPort > 0 {
+		listeners = append(listeners, Listener{
+			Name: "admin",
+			Addr: fmt.Sprintf(":%d", cfg.AdminPort),
+		})
+	}
+	return listeners
+}
+
+// Listen opens the network listener for l, wrapping it for PROXY protocol when enabled.
+func (l Listener) Listen() (net.Listener, error) {
+	ln, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if l.ProxyProtocol {
+		return proxyproto.NewListener(ln, l.ProxyTrusted), nil
+	}
+	return ln, nil
+}
+
+// NewServer wraps handler with the listener's middleware and returns a server
+// configured with the API's standard timeouts.
+func NewServer(l Listener, handler http.Handler) *http.Server {
+	for i := len(l.Middleware) - 1; i >= 0; i-- {
+		handler = l.Middleware[i](handler)
+	}
+	return &http.Server{
+		Addr:              l.Addr,
+		Handler:           handler,
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20, // 1 MiB
+	}
+}
+
+// RedirectToTLS redirects requests to the TLS listener on tlsPort. Health probes
+// are passed through so load balancers can keep checking the plain listener.
+func RedirectToTLS(tlsPort int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if tlsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(tlsPort))
+			}
+			target := "https://" + host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		})
+	}
+}
+
+// hidePaths answers 404 for the given path prefixes.
+func hidePaths(prefixes []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range prefixes {
+				if r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") {
+					response.Error(w, r, http.StatusNotFound, "not_found", "Resource not found", nil)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+func TestListeners_DeclaredFromConfig(t *testing.T) {
+	cfg := &config.Config{Port: 8080, TLSPort: 8443, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", TLSRedirect: true, AdminPort: 9090, DocsExposure: "admin"}
+
+	ls := Listeners(cfg)
+	if len(ls) != 3 {
+		t.Fatalf("expected 3 listeners, got %d", len(ls))
+	}
+	if ls[0].Name != "http" || ls[0].TLS() || ls[1].Name != "https" || !ls[1].TLS() || ls[2].Name != "admin" {
+		t.Fatalf("unexpected listeners: %+v", ls)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	plain := NewServer(ls[0], ok).Handler
+	admin := NewServer(ls[2], ok).Handler
+
+	rr := httptest.NewRecorder()
+	plain.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://api.example.com:8080/api/v1/ping?x=1", nil))
+	if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != "https://api.example.com:8443/api/v1/ping?x=1" {
+		t.Fatalf("expected redirect to TLS listener, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = httptest.NewRecorder()
+	plain.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected health probe to bypass redirect, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	NewServer(ls[1], ok).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected /metrics hidden on public listener, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	NewServer(ls[1], ok).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected admin-only docs hidden on public listener, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected /metrics on admin listener, got %d", rr.Code)
+	}
+}
+
+func TestListeners_DefaultIsSinglePlainListener(t *testing.T) {
+	ls := Listeners(&config.Config{Port: 8080})
+	if len(ls) != 1 || ls[0].Addr != ":8080" || len(ls[0].Middleware) != 0 {
+		t.Fatalf("unexpected default listeners: %+v", ls)
+	}
+}
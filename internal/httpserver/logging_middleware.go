@@ -7,7 +7,11 @@ import (
 	"os"
 	"time"
 
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/mikko-kohtala/go-api/internal/experiments"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+	"github.com/mikko-kohtala/go-api/internal/useragent"
 	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
 )
 
@@ -18,13 +22,19 @@ func LoggingMiddleware(logger *slog.Logger) func(next http.Handler) http.Handler
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww := respwriter.Wrap(w)
 			// Create request-scoped logger with request_id if available
 			rid := GetRequestID(r.Context())
 			reqLogger := logger
 			if rid != "" {
 				reqLogger = logger.With(slog.String("request_id", rid))
 			}
+			if assigned := experiments.FromContext(r.Context()); len(assigned) > 0 {
+				reqLogger = reqLogger.With(slog.String("experiments", assigned.String()))
+			}
+			if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+				reqLogger = reqLogger.With(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+			}
 
 			// Check if pretty logging is enabled
 			prettyLogs := os.Getenv("PRETTY_LOGS") == "true"
@@ -55,13 +65,25 @@ func LoggingMiddleware(logger *slog.Logger) func(next http.Handler) http.Handler
 				)
 			} else {
 				// Full logging for production/JSON logs
-				reqLogger.Info("request",
+				attrs := []any{
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
 					slog.Int("status", ww.Status()),
-					slog.Int("bytes", ww.BytesWritten()),
+					slog.Int64("bytes", ww.BytesWritten()),
 					slog.String("duration", duration.String()),
-				)
+					slog.String("client_class", string(useragent.FromContext(r.Context()).Class)),
+				}
+				// The handler and principal are set further down the chain
+				if h := requestctx.Handler(r.Context()); h != "" {
+					attrs = append(attrs, slog.String("handler", h))
+				}
+				if p, ok := requestctx.Principal(r.Context()); ok {
+					attrs = append(attrs, slog.String("user_id", p.UserID))
+					if p.Tenant != "" {
+						attrs = append(attrs, slog.String("tenant", p.Tenant))
+					}
+				}
+				reqLogger.Info("request", attrs...)
 			}
 		}
 		return http.HandlerFunc(fn)
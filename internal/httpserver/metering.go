@@ -0,0 +1,29 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/metering"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+)
+
+// MeterRequests emits a metering.RequestServed event for every request once
+// it has been handled, charged like quotas to the user identify verifies, or
+// to usage.Anonymous.
+func MeterRequests(bus *events.Bus, identify func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := identify(r)
+			if !ok {
+				key = usage.Anonymous
+			}
+			next.ServeHTTP(w, r)
+			metering.Emit(r.Context(), bus, metering.Event{
+				Type:     metering.RequestServed,
+				Key:      key,
+				Quantity: 1,
+			})
+		})
+	}
+}
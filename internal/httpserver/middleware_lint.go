@@ -0,0 +1,106 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Names of the global middleware, as checked by LintMiddleware.
+const (
+	mwHeaders    = "response_headers"
+	mwTimeout    = "timeout"
+	mwDecompress = "decompress"
+	mwBodyLimit  = "body_limit"
+	mwRequestID  = "request_id"
+	mwRealIP     = "real_ip"
+	mwTrace      = "trace"
+	mwClient     = "client_class"
+	mwFlags      = "feature_flags"
+	mwExperiment = "experiments"
+	mwLanguage   = "language"
+	mwMetrics    = "metrics"
+	mwHead       = "auto_head"
+	mwCompress   = "compress"
+	mwLogging    = "logging"
+	mwAbandon    = "abandonment"
+	mwPriority   = "priority"
+	mwAdmission  = "admission"
+	mwAlerts     = "alerts"
+	mwRecoverer  = "recoverer"
+	mwAudit      = "audit"
+	mwGuard      = "response_guard"
+	mwSampler    = "body_sampler"
+	mwCORS       = "cors"
+)
+
+// namedMiddleware is one entry of the global middleware chain.
+type namedMiddleware struct {
+	name string
+	mw   func(http.Handler) http.Handler
+}
+
+// Hazard is a known-unsafe property of the middleware chain.
+type Hazard struct {
+	Rule    string
+	Message string
+}
+
+func (h Hazard) String() string { return h.Rule + ": " + h.Message }
+
+// bodyReaders are middleware that look into response bodies.
+var bodyReaders = []string{mwGuard, mwSampler}
+
+// responseWriters are middleware that may write a response themselves.
+var responseWriters = []string{mwDecompress, mwBodyLimit, mwAdmission, mwCompress, mwRecoverer, mwCORS}
+
+// LintMiddleware checks a middleware chain, outermost first, for ordering
+// mistakes that fail silently at runtime.
+func LintMiddleware(chain []string) []Hazard {
+	at := func(name string) int { return slices.Index(chain, name) }
+	var hazards []Hazard
+
+	if at(mwRecoverer) < 0 {
+		hazards = append(hazards, Hazard{"recovery_missing", "no recoverer; a panicking handler drops the connection without a response or log"})
+	}
+	if t := at(mwTimeout); t >= 0 {
+		for _, w := range responseWriters {
+			if i := at(w); i >= 0 && i < t {
+				hazards = append(hazards, Hazard{"timeout_order", fmt.Sprintf("%s writes responses but runs outside the timeout, so its work is not bounded by REQUEST_TIMEOUT", w)})
+			}
+		}
+	}
+	if c, m := at(mwCompress), at(mwMetrics); c >= 0 && m > c {
+		hazards = append(hazards, Hazard{"compress_before_metrics", "compression wraps metrics, so metrics observe uncompressed responses and exclude compression time"})
+	}
+	if h, c := at(mwHead), at(mwCompress); h >= 0 && c >= 0 && h > c {
+		hazards = append(hazards, Hazard{"head_inside_compress", "HEAD bodies are discarded before compression, so Content-Length is that of the uncompressed body"})
+	}
+	switch b, d := at(mwBodyLimit), at(mwDecompress); {
+	case b < 0:
+		hazards = append(hazards, Hazard{"body_limit_missing", "no body limit; request bodies are unbounded"})
+	case d > b:
+		hazards = append(hazards, Hazard{"body_limit_before_decompress", "the body limit counts compressed bytes, so decompressed bodies are unbounded"})
+	}
+	if l, id := at(mwLogging), at(mwRequestID); l >= 0 && (id < 0 || id > l) {
+		hazards = append(hazards, Hazard{"logging_before_request_id", "the request logger is created before the request ID is assigned"})
+	}
+	if l, t := at(mwLogging), at(mwTrace); l >= 0 && t > l {
+		hazards = append(hazards, Hazard{"logging_before_trace", "the request logger is created before the span is started, so request logs lack the trace ID"})
+	}
+	for _, b := range bodyReaders {
+		if i, c := at(b), at(mwCompress); i >= 0 && c > i {
+			hazards = append(hazards, Hazard{b + "_outside_compress", fmt.Sprintf("%s sees compressed response bodies, which it cannot read", b)})
+		}
+	}
+	return hazards
+}
+
+// chainNames returns the names of chain, outermost first.
+func chainNames(chain []namedMiddleware) []string {
+	names := make([]string, len(chain))
+	for i, m := range chain {
+		names[i] = m.name
+	}
+	return names
+}
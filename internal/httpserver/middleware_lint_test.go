@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/auth"
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+func TestLintMiddleware(t *testing.T) {
+	cases := []struct {
+		chain []string
+		rules []string
+	}{
+		{[]string{mwTimeout, mwDecompress, mwBodyLimit, mwRequestID, mwMetrics, mwHead, mwCompress, mwLogging, mwRecoverer, mwCORS}, nil},
+		{[]string{mwTimeout, mwBodyLimit, mwRequestID}, []string{"recovery_missing"}},
+		{[]string{mwCompress, mwTimeout, mwBodyLimit, mwRecoverer}, []string{"timeout_order"}},
+		{[]string{mwBodyLimit, mwCompress, mwMetrics, mwRecoverer}, []string{"compress_before_metrics"}},
+		{[]string{mwBodyLimit, mwCompress, mwHead, mwRecoverer}, []string{"head_inside_compress"}},
+		{[]string{mwBodyLimit, mwDecompress, mwRecoverer}, []string{"body_limit_before_decompress"}},
+		{[]string{mwRecoverer}, []string{"body_limit_missing"}},
+		{[]string{mwBodyLimit, mwLogging, mwRequestID, mwRecoverer}, []string{"logging_before_request_id"}},
+		{[]string{mwBodyLimit, mwRequestID, mwLogging, mwTrace, mwRecoverer}, []string{"logging_before_trace"}},
+		{[]string{mwBodyLimit, mwGuard, mwCompress, mwRecoverer}, []string{"response_guard_outside_compress"}},
+		{[]string{mwBodyLimit, mwSampler, mwCompress, mwRecoverer}, []string{"body_sampler_outside_compress"}},
+	}
+	for _, tc := range cases {
+		hazards := LintMiddleware(tc.chain)
+		if len(hazards) != len(tc.rules) {
+			t.Errorf("%v: expected %v, got %v", tc.chain, tc.rules, hazards)
+			continue
+		}
+		for i, h := range hazards {
+			if h.Rule != tc.rules[i] {
+				t.Errorf("%v: expected %v, got %v", tc.chain, tc.rules, hazards)
+			}
+		}
+	}
+}
+
+func TestNewCheckedRouter_DefaultChainIsClean(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "test",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		CompressionLevel:   5,
+		MiddlewareLint:     "strict",
+	}
+	if _, err := NewCheckedRouter(cfg, testLogger()); err != nil {
+		t.Fatalf("default middleware chain should have no hazards: %v", err)
+	}
+}
+
+func TestNewCheckedRouter_FailsOnUnusableJWTSettings(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "test",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		CompressionLevel:   5,
+		JWTIssuer:          "https://issuer.example.com",
+	}
+	if _, err := NewCheckedRouter(cfg, testLogger()); !errors.Is(err, auth.ErrNoKeys) {
+		t.Fatalf("expected JWT_ISSUER without signing keys to fail startup, got %v", err)
+	}
+	cfg.JWTIssuer = ""
+	if _, err := NewCheckedRouter(cfg, testLogger()); err != nil {
+		t.Fatalf("expected API keys only without JWT settings, got %v", err)
+	}
+}
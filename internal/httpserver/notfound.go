@@ -0,0 +1,172 @@
+package httpserver
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// maxSuggestionDistance is the largest edit distance for a "did you mean" hint.
+const maxSuggestionDistance = 4
+
+// notFoundHandler answers unmatched routes with the JSON error envelope. When
+// suggest is set, the closest registered route is offered as a hint.
+func notFoundHandler(routes chi.Routes, suggest bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := response.ErrorResponse{
+			Error:     "not_found",
+			Message:   "Resource not found",
+			RequestID: response.RequestID(r),
+		}
+		if suggest {
+			if s := closestRoute(routes, r.URL.Path); s != "" {
+				resp.Hint = "Did you mean " + s + "?"
+			}
+		}
+		response.JSON(w, r, http.StatusNotFound, resp)
+	}
+}
+
+// methodNotAllowedHandler answers with 405, listing the methods the path
+// supports in Allow. OPTIONS requests for paths without an OPTIONS route of
+// their own get a 204 with the same Allow; CORS preflights are answered by
+// the CORS middleware before they get here.
+func methodNotAllowedHandler(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(routes, r.URL.Path)
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+		if r.Method == http.MethodOptions {
+			response.NoBody(w, r, http.StatusNoContent)
+			return
+		}
+		response.Error(w, r, http.StatusMethodNotAllowed, "method_not_allowed",
+			"Method "+r.Method+" is not allowed for this resource", nil)
+	}
+}
+
+// allowedMethods returns the methods path can be requested with, in
+// conventional order.
+func allowedMethods(routes chi.Routes, path string) []string {
+	seen := routeMethods(routes, path)
+	out := make([]string, 0, len(seen)+2)
+	for method := range seen {
+		out = append(out, method)
+	}
+	// AutoHead serves HEAD wherever there is GET, and OPTIONS is always answered
+	if seen[http.MethodGet] && !seen[http.MethodHead] {
+		out = append(out, http.MethodHead)
+	}
+	if len(out) > 0 && !seen[http.MethodOptions] {
+		out = append(out, http.MethodOptions)
+	}
+	sort.Slice(out, func(i, j int) bool { return methodOrder(out[i]) < methodOrder(out[j]) })
+	return out
+}
+
+// routeMethods returns the methods registered for routes matching path.
+func routeMethods(routes chi.Routes, path string) map[string]bool {
+	seen := map[string]bool{}
+	_ = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if patternMatches(route, path) {
+			seen[method] = true
+		}
+		return nil
+	})
+	return seen
+}
+
+// methodOrder sorts methods in the conventional GET, POST, PUT, PATCH, DELETE order.
+func methodOrder(m string) int {
+	for i, o := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+		if m == o {
+			return i
+		}
+	}
+	return 100
+}
+
+// patternMatches reports whether a chi route pattern matches path, ignoring
+// trailing slashes. {param} matches one segment and * matches the remainder.
+func patternMatches(pattern, path string) bool {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range ps {
+		if p == "*" {
+			return true
+		}
+		if i >= len(segs) {
+			return false
+		}
+		if strings.HasPrefix(p, "{") && segs[i] != "" {
+			continue
+		}
+		if p != segs[i] {
+			return false
+		}
+	}
+	return len(ps) == len(segs)
+}
+
+// closestRoute returns the registered route pattern nearest to path, with URL
+// parameters filled in from path, or "" when nothing is close enough.
+func closestRoute(routes chi.Routes, path string) string {
+	best, bestDist := "", maxSuggestionDistance+1
+	seen := map[string]bool{}
+	_ = chi.Walk(routes, func(_ string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		candidate := fillParams(strings.TrimSuffix(route, "/*"), path)
+		if candidate != "/" {
+			candidate = strings.TrimSuffix(candidate, "/")
+		}
+		if seen[candidate] {
+			return nil
+		}
+		seen[candidate] = true
+		if d := levenshtein(strings.ToLower(path), strings.ToLower(candidate)); d < bestDist {
+			best, bestDist = candidate, d
+		}
+		return nil
+	})
+	if bestDist == 0 {
+		// Path exists in some form (e.g. trailing slash); nothing useful to suggest
+		return ""
+	}
+	return best
+}
+
+// fillParams substitutes {param} segments of pattern with the matching segment of path.
+func fillParams(pattern, path string) string {
+	ps := strings.Split(pattern, "/")
+	segs := strings.Split(path, "/")
+	for i, p := range ps {
+		if strings.HasPrefix(p, "{") && i < len(segs) && segs[i] != "" {
+			ps[i] = segs[i]
+		}
+	}
+	return strings.Join(ps, "/")
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+func notFoundTestRouter(env string) http.Handler {
+	cfg := &config.Config{
+		Env:                env,
+		Port:               0,
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimitEnabled:   false,
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+		JWTHS256Secrets:    []string{testJWTSecret},
+	}
+	return NewRouter(cfg, testLogger())
+}
+
+func TestNotFound_JSONWithSuggestion(t *testing.T) {
+	h := notFoundTestRouter("development")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/user/usr_001", nil)
+	req.Header.Set("X-Request-ID", "nf-1")
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	var resp response.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected JSON body, got %q", rr.Body.String())
+	}
+	if resp.Error != "not_found" || resp.RequestID != "nf-1" {
+		t.Fatalf("unexpected error envelope: %+v", resp)
+	}
+	if resp.Hint != "Did you mean /api/v1/users/usr_001?" {
+		t.Fatalf("unexpected hint: %q", resp.Hint)
+	}
+}
+
+func TestNotFound_NoSuggestionInProduction(t *testing.T) {
+	h := notFoundTestRouter("production")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/pingg", nil))
+
+	var resp response.ErrorResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusNotFound || resp.Hint != "" {
+		t.Fatalf("expected bare 404 in production, got %d %+v", rr.Code, resp)
+	}
+}
+
+func TestMethodNotAllowed_JSONWithAllowHeader(t *testing.T) {
+	h := notFoundTestRouter("development")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/api/v1/users/usr_001", nil))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Allow"); got != "GET, HEAD, PUT, DELETE, OPTIONS" {
+		t.Fatalf("unexpected Allow header: %q", got)
+	}
+	var resp response.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error != "method_not_allowed" {
+		t.Fatalf("expected JSON error envelope, got %q", rr.Body.String())
+	}
+}
+
+func TestOptions_ListsAllowedMethods(t *testing.T) {
+	h := notFoundTestRouter("development")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/api/v1/users/usr_001", nil))
+
+	if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Fatalf("expected empty 204, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Allow"); got != "GET, HEAD, PUT, DELETE, OPTIONS" {
+		t.Fatalf("unexpected Allow header: %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/api/v1/nope", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown path, got %d", rr.Code)
+	}
+}
+
+func TestHead_ServesGETHeadersWithContentLength(t *testing.T) {
+	h := notFoundTestRouter("development")
+
+	get := httptest.NewRecorder()
+	h.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	head := httptest.NewRecorder()
+	h.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/api/v1/users", nil))
+
+	if head.Code != get.Code || head.Body.Len() != 0 {
+		t.Fatalf("expected HEAD to answer %d without a body, got %d %q", get.Code, head.Code, head.Body.String())
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+		t.Fatalf("Content-Length = %q, want %q", got, want)
+	}
+	if head.Header().Get("Content-Type") != get.Header().Get("Content-Type") {
+		t.Fatalf("expected GET's Content-Type, got %q", head.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,207 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	docs "github.com/mikko-kohtala/go-api/internal/docs"
+	"github.com/mikko-kohtala/go-api/internal/docs/examples"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+	"github.com/mikko-kohtala/go-api/internal/routes"
+)
+
+// bodyMethods are the operations whose request body limit is documented.
+var bodyMethods = []string{"post", "put", "patch"}
+
+// swaggerDocHandler serves the Swagger document annotated from the route
+// table, with the request body limit of every operation that takes a body:
+// as an x-body-limit extension and as a documented 413 response. Response
+// examples come from the golden responses in internal/docs/examples.
+func swaggerDocHandler(defaultLimit int64, table *routemeta.Table) (http.HandlerFunc, error) {
+	doc, err := withRouteMeta([]byte(docs.SwaggerInfo.ReadDoc()), table)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = withExamples(doc, examples.FS); err != nil {
+		return nil, err
+	}
+	if doc, err = withBodyLimits(doc, defaultLimit); err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		response.Bytes(w, r, http.StatusOK, doc)
+	}, nil
+}
+
+// withBodyLimits adds the limits from routes.BodyLimit to doc.
+func withBodyLimits(doc []byte, defaultLimit int64) ([]byte, error) {
+	var spec map[string]any
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("parse swagger document: %w", err)
+	}
+	paths, _ := spec["paths"].(map[string]any)
+	for path, item := range paths {
+		ops, _ := item.(map[string]any)
+		limit := routes.BodyLimit(path, defaultLimit)
+		for _, method := range bodyMethods {
+			op, ok := ops[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			op["x-body-limit"] = limit
+			responses, _ := op["responses"].(map[string]any)
+			if responses == nil {
+				responses = map[string]any{}
+				op["responses"] = responses
+			}
+			if _, ok := responses["413"]; !ok {
+				responses["413"] = map[string]any{"description": fmt.Sprintf("Request body exceeds the %d byte limit", limit)}
+			}
+		}
+	}
+	return json.MarshalIndent(spec, "", "    ")
+}
+
+// withExamples sets the example of every response with a golden file in
+// fsys, named <operationId>.<status>.json, so the documented examples are the
+// verified outputs of the handlers. It runs after withRouteMeta, which sets
+// the operation IDs; files of operations the document lacks are ignored.
+func withExamples(doc []byte, fsys fs.FS) ([]byte, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return doc, nil
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("parse swagger document: %w", err)
+	}
+	ops := map[string]map[string]any{}
+	paths, _ := spec["paths"].(map[string]any)
+	for _, item := range paths {
+		methods, _ := item.(map[string]any)
+		for _, v := range methods {
+			if op, ok := v.(map[string]any); ok {
+				if id, ok := op["operationId"].(string); ok {
+					ops[id] = op
+				}
+			}
+		}
+	}
+	for _, file := range files {
+		base := strings.TrimSuffix(file, ".json")
+		i := strings.LastIndexByte(base, '.')
+		if i < 0 {
+			return nil, fmt.Errorf("example %s: name is not <operationId>.<status>.json", file)
+		}
+		name, status := base[:i], base[i+1:]
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, fmt.Errorf("example %s: name is not <operationId>.<status>.json", file)
+		}
+		op := ops[name]
+		if op == nil {
+			continue
+		}
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var example any
+		if err := json.Unmarshal(body, &example); err != nil {
+			return nil, fmt.Errorf("example %s: %w", file, err)
+		}
+		responses, _ := op["responses"].(map[string]any)
+		if responses == nil {
+			responses = map[string]any{}
+			op["responses"] = responses
+		}
+		resp, _ := responses[status].(map[string]any)
+		if resp == nil {
+			resp = map[string]any{"description": http.StatusText(code)}
+			responses[status] = resp
+		}
+		resp["examples"] = map[string]any{"application/json": example}
+	}
+	return json.Marshal(spec)
+}
+
+// withRouteMeta annotates doc with the declared routes: every operation gets
+// its route's name as operationId, x-stability and x-rate-class extensions,
+// the deprecated flag and, when it needs a principal, a security requirement.
+// Declared routes the generated document lacks are added with their
+// description, so it lists every route served.
+func withRouteMeta(doc []byte, table *routemeta.Table) ([]byte, error) {
+	var spec map[string]any
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("parse swagger document: %w", err)
+	}
+	paths, _ := spec["paths"].(map[string]any)
+	if paths == nil {
+		paths = map[string]any{}
+		spec["paths"] = paths
+	}
+	for _, route := range table.Routes() {
+		if strings.Contains(route.Pattern, "*") {
+			continue // wildcards are not OpenAPI paths
+		}
+		item, _ := paths[route.Pattern].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[route.Pattern] = item
+		}
+		method := strings.ToLower(route.Method)
+		op, _ := item[method].(map[string]any)
+		if op == nil {
+			op = map[string]any{
+				"summary":   route.Description,
+				"responses": map[string]any{"default": map[string]any{"description": "See the error format in the README"}},
+			}
+			if params := pathParams(route.Pattern); len(params) > 0 {
+				op["parameters"] = params
+			}
+			item[method] = op
+		}
+		op["operationId"] = route.Name
+		op["x-stability"] = route.Stability
+		if route.RateClass != "" {
+			op["x-rate-class"] = route.RateClass
+		}
+		if route.Deprecated {
+			op["deprecated"] = true
+		}
+		if route.Auth == routemeta.Authenticated {
+			op["security"] = []any{map[string]any{"principal": []any{}}}
+			defs, _ := spec["securityDefinitions"].(map[string]any)
+			if defs == nil {
+				defs = map[string]any{}
+				spec["securityDefinitions"] = defs
+			}
+			if _, ok := defs["principal"]; !ok {
+				defs["principal"] = map[string]any{"type": "apiKey", "in": "header", "name": "Authorization"}
+			}
+		}
+	}
+	return json.Marshal(spec)
+}
+
+// pathParams documents the {name} segments of pattern as string parameters.
+func pathParams(pattern string) []any {
+	var params []any
+	for _, seg := range strings.Split(pattern, "/") {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.Trim(seg, "{}"), ":")
+		params = append(params, map[string]any{"name": name, "in": "path", "required": true, "type": "string"})
+	}
+	return params
+}
@@ -0,0 +1,153 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+	"github.com/mikko-kohtala/go-api/internal/routes"
+)
+
+func TestSwaggerDoc_DocumentsBodyLimits(t *testing.T) {
+	h := filesTestRouter() // BODY_LIMIT_BYTES is 1024
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+	var spec struct {
+		Paths map[string]map[string]struct {
+			BodyLimit *int64                     `json:"x-body-limit"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected the swagger document, got %d %v", rr.Code, err)
+	}
+
+	users := spec.Paths["/api/v1/users"]["post"]
+	if users.BodyLimit == nil || *users.BodyLimit != 1024 || users.Responses["413"] == nil {
+		t.Fatalf("expected POST /api/v1/users documented with the 1024 byte limit and a 413, got %+v", users)
+	}
+	if get := spec.Paths["/api/v1/users"]["get"]; get.BodyLimit != nil {
+		t.Fatalf("expected no body limit on GET, got %d", *get.BodyLimit)
+	}
+}
+
+func TestWithBodyLimits_UsesRouteGroupLimits(t *testing.T) {
+	doc, err := withBodyLimits([]byte(`{"paths":{"/api/v1/files/{fileID}":{"patch":{"responses":{"204":{}}}}}}`), 1024)
+	if err != nil {
+		t.Fatalf("withBodyLimits: %v", err)
+	}
+	var spec map[string]map[string]map[string]map[string]any
+	_ = json.Unmarshal(doc, &spec)
+	op := spec["paths"]["/api/v1/files/{fileID}"]["patch"]
+	if op["x-body-limit"] != float64(100<<20) {
+		t.Fatalf("expected the uploads group's limit, got %v", op["x-body-limit"])
+	}
+}
+
+func TestRouteTable_CoversEveryRouteAndFeedsTheDocs(t *testing.T) {
+	h := notFoundTestRouter("development")
+
+	// Every route chi serves is declared, under a unique name
+	names := map[string]string{}
+	for _, route := range routemeta.Default.Routes() {
+		if other, dup := names[route.Name]; dup && other != route.Method+" "+route.Pattern {
+			t.Fatalf("route name %q used by %s and %s %s", route.Name, other, route.Method, route.Pattern)
+		}
+		names[route.Name] = route.Method + " " + route.Pattern
+	}
+	_ = chi.Walk(h.(chi.Routes), func(method, pattern string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if _, ok := routemeta.Default.Lookup(method, pattern); !ok {
+			t.Errorf("%s %s has no route metadata", method, pattern)
+		}
+		return nil
+	})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, asAdmin(httptest.NewRequest(http.MethodGet, "/admin/routes", nil)))
+	var list struct {
+		Routes []routemeta.Route `json:"routes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); rr.Code != http.StatusOK || err != nil || len(list.Routes) == 0 {
+		t.Fatalf("expected the route table from /admin/routes, got %d %v", rr.Code, err)
+	}
+	for _, route := range list.Routes {
+		if route.Name == "users.get" && route.Handler != "handlers.(*UserHandler).GetUserByID" {
+			t.Fatalf("expected users.get to name its handler, got %q", route.Handler)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Stability   string `json:"x-stability"`
+			RateClass   string `json:"x-rate-class"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode swagger document: %v", err)
+	}
+	if op := spec.Paths["/api/v1/reports"]["post"]; op.OperationID != "reports.create" || op.Stability != "beta" || op.RateClass != "bulk" {
+		t.Fatalf("expected POST /api/v1/reports annotated from its route, got %+v", op)
+	}
+	if op := spec.Paths["/admin/routes"]["get"]; op.OperationID != "admin.routes" {
+		t.Fatalf("expected declared routes documented, got %+v", op)
+	}
+}
+
+func TestDocsExposure(t *testing.T) {
+	router := func(exposure, publicURL string) http.Handler {
+		cfg := &config.Config{
+			Env:              "production",
+			RequestTimeout:   time.Second,
+			BodyLimitBytes:   1 << 20,
+			RateLimitPeriod:  "1m",
+			CompressionLevel: 5,
+			DocsExposure:     exposure,
+			DocsPublicURL:    publicURL,
+		}
+		return NewRouter(cfg, testLogger())
+	}
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	rr := get(router(routes.DocsPublic, "https://api.example.com/edge/"), "/swagger/doc.json")
+	var spec struct {
+		Host     string   `json:"host"`
+		Schemes  []string `json:"schemes"`
+		BasePath string   `json:"basePath"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected the public document, got %d %v", rr.Code, err)
+	}
+	if spec.Host != "api.example.com" || len(spec.Schemes) != 1 || spec.Schemes[0] != "https" || spec.BasePath != "/edge" {
+		t.Fatalf("expected the server from DOCS_PUBLIC_URL, got %+v", spec)
+	}
+
+	h := router(routes.DocsAdmin, "")
+	for _, path := range []string{"/swagger/doc.json", "/api-docs/index.html"} {
+		if rr := get(h, path); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected %s to need credentials, got %d", path, rr.Code)
+		}
+	}
+
+	h = router(routes.DocsDisabled, "")
+	if rr := get(h, "/swagger/doc.json"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected disabled docs not served, got %d", rr.Code)
+	}
+	if rr := get(h, "/"); strings.Contains(rr.Body.String(), `"docs"`) {
+		t.Fatalf("expected the index not to link disabled docs: %s", rr.Body.String())
+	}
+}
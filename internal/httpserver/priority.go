@@ -0,0 +1,131 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/admission"
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/proxyproto"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+// PriorityPolicy decides the admission class of a request.
+type PriorityPolicy struct {
+	Header       string           // class override, honoured from trusted callers only
+	Trusted      []*net.IPNet     // callers whose Header is honoured
+	BulkPrefixes []string         // path prefixes of bulk traffic
+	Routes       *routemeta.Table // declared rate classes; nil skips them
+}
+
+// newPriorityPolicy builds the policy from the configuration.
+func newPriorityPolicy(cfg *config.Config) PriorityPolicy {
+	// Validated by config.Load
+	trusted, _ := proxyproto.ParseCIDRs(cfg.PriorityTrustedCIDRs)
+	return PriorityPolicy{
+		Header:       cfg.PriorityHeader,
+		Trusted:      trusted,
+		BulkPrefixes: cfg.PriorityBulkRoutes,
+		Routes:       routemeta.Default,
+	}
+}
+
+// Classify returns the class of r: the header of a trusted caller when it
+// names a known class, otherwise bulk for the bulk routes, the class the
+// matching route declares, critical for health, metrics and admin endpoints,
+// interactive for other reads and normal for everything else.
+func (p PriorityPolicy) Classify(r *http.Request) admission.Class {
+	if p.Header != "" && p.trusted(r.RemoteAddr) {
+		if c := admission.Class(strings.ToLower(strings.TrimSpace(r.Header.Get(p.Header)))); c.Valid() {
+			return c
+		}
+	}
+	if hasAnyPrefix(r.URL.Path, p.BulkPrefixes) {
+		return admission.Bulk
+	}
+	if p.Routes != nil {
+		if route, ok := p.Routes.Match(r.Method, r.URL.Path); ok && route.RateClass.Valid() {
+			return route.RateClass
+		}
+	}
+	switch {
+	case routeClass(r.URL.Path) == routeClassOps:
+		return admission.Critical
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return admission.Interactive
+	}
+	return admission.Normal
+}
+
+func (p PriorityPolicy) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.Trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prioritize stores the request's admission class in its context.
+func Prioritize(p PriorityPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(admission.WithClass(r.Context(), p.Classify(r))))
+		})
+	}
+}
+
+// LimitConcurrency serves at most limit requests at once. Requests over the
+// limit wait up to queueTimeout for a slot, admitted by weighted fair queuing
+// over their priority classes, and get 503 when the queue is full or the wait
+// times out. A limit of 0 disables the limiter.
+func LimitConcurrency(limit, queueSize int, queueTimeout time.Duration) func(http.Handler) http.Handler {
+	if limit <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limiter := admission.NewLimiter(limit, queueSize, admission.DefaultWeights)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			class := admission.ClassFromContext(r.Context())
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(r.Context(), queueTimeout)
+			release, err := limiter.Acquire(ctx, class)
+			cancel()
+			wait := time.Since(start)
+			switch {
+			case err == nil:
+				metrics.ObserveAdmission(string(class), "admitted", wait)
+				defer release()
+				next.ServeHTTP(w, r)
+			case errors.Is(err, admission.ErrQueueFull):
+				metrics.ObserveAdmission(string(class), "queue_full", wait)
+				writeOverloaded(w, r)
+			case r.Context().Err() != nil:
+				// The request itself ended while queued; nobody is waiting for a response
+				metrics.ObserveAdmission(string(class), "abandoned", wait)
+			default:
+				metrics.ObserveAdmission(string(class), "timeout", wait)
+				writeOverloaded(w, r)
+			}
+		})
+	}
+}
+
+func writeOverloaded(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	response.Error(w, r, http.StatusServiceUnavailable, "overloaded", "Server is at capacity, retry shortly", nil)
+}
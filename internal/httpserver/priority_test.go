@@ -0,0 +1,76 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/admission"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+func TestPriorityPolicy_Classify(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	table := routemeta.NewTable()
+	table.Add(routemeta.Route{Method: http.MethodGet, Pattern: "/api/v1/images/{fileID}", Name: "images.get", RateClass: admission.Bulk})
+	p := PriorityPolicy{Header: "X-Priority", Trusted: []*net.IPNet{trusted}, BulkPrefixes: []string{"/api/v1/reports"}, Routes: table}
+
+	cases := []struct {
+		method, path, remote, header string
+		want                         admission.Class
+	}{
+		{http.MethodGet, "/healthz", "203.0.113.7:1234", "", admission.Critical},
+		{http.MethodGet, "/api/v1/tasks", "203.0.113.7:1234", "", admission.Interactive},
+		{http.MethodPost, "/api/v1/tasks", "203.0.113.7:1234", "", admission.Normal},
+		{http.MethodPost, "/api/v1/reports", "203.0.113.7:1234", "", admission.Bulk},
+		{http.MethodPost, "/api/v1/reports", "203.0.113.7:1234", "critical", admission.Bulk}, // untrusted caller
+		{http.MethodPost, "/api/v1/reports", "10.1.2.3:1234", "Critical", admission.Critical},
+		{http.MethodGet, "/api/v1/tasks", "10.1.2.3:1234", "urgent", admission.Interactive}, // unknown class
+		{http.MethodGet, "/api/v1/images/f_1", "203.0.113.7:1234", "", admission.Bulk},      // declared by the route
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		r.RemoteAddr = tc.remote
+		if tc.header != "" {
+			r.Header.Set("X-Priority", tc.header)
+		}
+		if got := p.Classify(r); got != tc.want {
+			t.Fatalf("%s %s from %s (%q): expected %s, got %s", tc.method, tc.path, tc.remote, tc.header, tc.want, got)
+		}
+	}
+}
+
+func TestLimitConcurrency_RejectsWhenQueueTimesOut(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	h := LimitConcurrency(1, 10, 20*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After while the only slot is held, got %d", rec.Code)
+	}
+
+	close(unblock)
+	<-done
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 once the slot is free, got %d", rec.Code)
+	}
+}
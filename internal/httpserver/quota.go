@@ -0,0 +1,32 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// EnforceQuota counts requests from callers identify verifies against their
+// user's daily quota, refusing them with 429 once it is used up, and
+// attributes the request to the user so services can charge storage and user
+// quotas. Anonymous requests, and credentials that fail verification, are left
+// to the per-IP rate limiter, so made-up keys cannot create quota counters.
+func EnforceQuota(quotas *quota.Manager, identify func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := identify(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var exceeded *quota.ExceededError
+			if err := quotas.Consume(key, 1); errors.As(err, &exceeded) {
+				response.QuotaExceeded(w, r, exceeded)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(quota.WithKey(r.Context(), key)))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+// rateLimitKey keys requests by the user their credentials verify as, so
+// callers sharing an address, such as behind a NAT, get their own limits, and
+// one caller gets one limit across addresses. Requests identify cannot
+// verify are keyed by client IP.
+func rateLimitKey(identify func(*http.Request) (string, bool)) httprate.KeyFunc {
+	return func(r *http.Request) (string, error) {
+		if identify != nil {
+			if user, ok := identify(r); ok {
+				return "user:" + user, nil
+			}
+		}
+		ip, err := httprate.KeyByIP(r)
+		return "ip:" + ip, err
+	}
+}
+
+// routeLimit is the limiter of one RATE_LIMIT_ROUTES entry.
+type routeLimit struct {
+	config.RouteRateLimit
+	limit func(http.Handler) http.Handler
+}
+
+func (rl routeLimit) matches(r *http.Request) bool {
+	if rl.Method != "" && rl.Method != r.Method {
+		return false
+	}
+	return r.URL.Path == rl.Prefix || strings.HasPrefix(r.URL.Path, rl.Prefix+"/")
+}
+
+// moreSpecific reports whether rl takes precedence over other: a longer
+// prefix, or the same one for a single method.
+func (rl routeLimit) moreSpecific(other routeLimit) bool {
+	if len(rl.Prefix) != len(other.Prefix) {
+		return len(rl.Prefix) > len(other.Prefix)
+	}
+	return rl.Method != "" && other.Method == ""
+}
+
+// limitRoutes applies the limiter of the most specific route limit matching
+// a request, and fallback to the others. It works on the raw path, like
+// PRIORITY_BULK_ROUTES, so it needs no routing.
+func limitRoutes(fallback func(http.Handler) http.Handler, routes []routeLimit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		def := fallback(next)
+		limited := make([]http.Handler, len(routes))
+		for i, rl := range routes {
+			limited[i] = rl.limit(next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			best := -1
+			for i, rl := range routes {
+				if rl.matches(r) && (best < 0 || rl.moreSpecific(routes[best])) {
+					best = i
+				}
+			}
+			if best < 0 {
+				def.ServeHTTP(w, r)
+				return
+			}
+			limited[best].ServeHTTP(w, r)
+		})
+	}
+}
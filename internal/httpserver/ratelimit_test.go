@@ -0,0 +1,59 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+func TestRateLimiting_RouteLimitsAndUserKeys(t *testing.T) {
+	routes, err := config.ParseRateLimitRoutes("POST /api/v1/users=1; /api/v1/files=3/1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{RateLimitEnabled: true, RateLimit: 2, RateLimitPeriod: "1m", RateLimitBackend: "memory", RateLimitRoutes: routes}
+	identify := func(r *http.Request) (string, bool) {
+		user := r.Header.Get("X-Test-User")
+		return user, user != ""
+	}
+	h := setupRateLimiting(cfg, testLogger(), identify)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The route's own limit applies instead of RATE_LIMIT
+	if rr := serve(http.MethodPost, "/api/v1/users", ""); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Fatalf("first POST /users: %d limit %q", rr.Code, rr.Header().Get("X-RateLimit-Limit"))
+	}
+	if rr := serve(http.MethodPost, "/api/v1/users", ""); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the stricter POST /users limit, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/api/v1/users", ""); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("GET /users should get RATE_LIMIT, got %d limit %q", rr.Code, rr.Header().Get("X-RateLimit-Limit"))
+	}
+	if rr := serve(http.MethodPut, "/api/v1/files/f1", ""); rr.Header().Get("X-RateLimit-Limit") != "3" {
+		t.Fatalf("expected the files prefix limit, got %q", rr.Header().Get("X-RateLimit-Limit"))
+	}
+	if rr := serve(http.MethodGet, "/api/v1/filesystem", ""); rr.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatal("a prefix matched a longer path segment")
+	}
+
+	// Identified users get their own limits, even from the same address
+	for _, user := range []string{"usr_001", "usr_002"} {
+		if rr := serve(http.MethodPost, "/api/v1/users", user); rr.Code != http.StatusOK {
+			t.Fatalf("%s limited by the address's requests: %d", user, rr.Code)
+		}
+	}
+	if rr := serve(http.MethodPost, "/api/v1/users", "usr_001"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected usr_001 limited, got %d", rr.Code)
+	}
+}
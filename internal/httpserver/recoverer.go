@@ -0,0 +1,61 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/mikko-kohtala/go-api/internal/alert"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/scrub"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Recoverer recovers from handler panics, logs the panic value, stack trace, and
+// request dump with secrets scrubbed, and answers with a JSON 500. Panics with
+// an httpabort error are answered with that error instead, and not logged.
+func Recoverer(next http.Handler) http.Handler {
+	return RecovererWithAlerts(nil)(next)
+}
+
+// RecovererWithAlerts is Recoverer that also reports each panic to alerter.
+func RecovererWithAlerts(alerter *alert.Alerter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return recoverer(alerter, next)
+	}
+}
+
+func recoverer(alerter *alert.Alerter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// Abort the response to the client without logging, like net/http does
+				panic(rvr)
+			}
+			if err, ok := httpabort.Recovered(rvr); ok {
+				response.FromError(w, r, err)
+				return
+			}
+
+			pkglogger.FromContext(r.Context()).Error("panic recovered",
+				slog.String("panic", scrub.Value(rvr)),
+				slog.String("handler", requestctx.Handler(r.Context())),
+				slog.String("stack", scrub.String(string(debug.Stack()))),
+				slog.String("request", scrub.DumpRequest(r)),
+			)
+			alerter.Panic(r, rvr)
+
+			if r.Header.Get("Connection") != "Upgrade" {
+				response.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error", nil)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
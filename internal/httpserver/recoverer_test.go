@@ -0,0 +1,108 @@
+package httpserver
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/alert"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/testutil/mockupstream"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+func TestRecoverer_ScrubsSecretsFromPanicLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("upstream rejected token=abc123 for " + r.Header.Get("Authorization"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	req.Header.Set("Authorization", "Bearer very-secret")
+	req = req.WithContext(pkglogger.IntoContext(req.Context(), log))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "internal_error") {
+		t.Fatalf("expected JSON 500, got %d %s", rr.Code, rr.Body.String())
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, "panic recovered") {
+		t.Fatalf("expected panic to be logged, got %s", logged)
+	}
+	if strings.Contains(logged, "very-secret") || strings.Contains(logged, "abc123") {
+		t.Fatalf("expected secrets to be scrubbed, got %s", logged)
+	}
+}
+
+func TestRecoverer_AnswersAbortWithItsError(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpabort.Abort(http.StatusUnprocessableEntity, "task_archived", "Archived tasks cannot change")
+	}))
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/tasks/tsk_1", nil)
+	req = req.WithContext(pkglogger.IntoContext(req.Context(), log))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "task_archived") {
+		t.Fatalf("expected the abort's 422, got %d %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(buf.String(), "panic recovered") {
+		t.Fatalf("expected an abort not to be logged as a panic, got %s", buf.String())
+	}
+}
+
+func TestRecovererWithAlerts_PostsToWebhook(t *testing.T) {
+	up := mockupstream.New(t)
+	up.On(http.MethodPost, "/alerts", mockupstream.Response{Status: http.StatusOK})
+	alerter := alert.New(alert.Options{
+		Sender: notify.WebhookProvider{URL: up.URL() + "/alerts", Client: up.Client(), Slack: true},
+		Logger: testLogger(),
+	})
+
+	h := RecovererWithAlerts(alerter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	req = req.WithContext(pkglogger.IntoContext(req.Context(), testLogger()))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	deadline := time.Now().Add(time.Second)
+	for len(up.RequestsFor(http.MethodPost, "/alerts")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected panic alert to be posted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if body := string(up.LastRequest(t).Body); !strings.Contains(body, "Panic recovered") || !strings.Contains(body, "boom") {
+		t.Fatalf("unexpected alert payload %s", body)
+	}
+}
+
+func TestRecoverer_LogsHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestctx.SetHandler(r.Context(), "handlers.(*UserHandler).GetUserByID")
+		panic("nil map")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/usr_1", nil)
+	req = req.WithContext(pkglogger.IntoContext(requestctx.New(req.Context()), log))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if logged := buf.String(); !strings.Contains(logged, `"handler":"handlers.(*UserHandler).GetUserByID"`) {
+		t.Fatalf("expected the panic log to name the handler, got %s", logged)
+	}
+}
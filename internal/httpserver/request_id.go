@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
 )
 
 type ctxKey string
@@ -14,12 +16,14 @@ type ctxKey string
 const requestIDKey ctxKey = "request_id"
 
 // RequestID middleware trusts an incoming X-Request-ID or X-Correlation-ID header
-// from the client. If absent, it generates a secure random ID.
+// from the client. If absent, it generates a secure random ID. It also starts
+// the request's requestctx state, so the metrics, logging and audit middleware
+// that run inside it see the principal set by authentication.
 func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rid := pickRequestID(r)
 		w.Header().Set("X-Request-ID", rid)
-		ctx := context.WithValue(r.Context(), requestIDKey, rid)
+		ctx := context.WithValue(requestctx.New(r.Context()), requestIDKey, rid)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
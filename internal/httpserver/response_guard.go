@@ -0,0 +1,261 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Response guard modes, as set by RESPONSE_GUARD.
+const (
+	responseGuardWarn   = "warn"
+	responseGuardStrict = "strict"
+)
+
+// ResponseGuardPolicy is what GuardLargeResponses checks.
+type ResponseGuardPolicy struct {
+	MaxItems int   // most items in a list of a response
+	MaxBytes int64 // largest response body
+	Truncate bool  // cut lists over MaxItems instead of only reporting them
+}
+
+// GuardLargeResponses catches handlers that return unbounded collections, the
+// kind of endpoint that works in development and dumps a whole table once the
+// data grows. JSON GET responses under /api/ are buffered up to MaxBytes and
+// their longest list, the body itself or a field of the top-level object, is
+// counted. A list over MaxItems or a body over MaxBytes is logged as a "large
+// response" warning with the route and counted in api_large_responses_total.
+// With Truncate, a list over MaxItems is cut to MaxItems and the response
+// becomes a 206 with Content-Range: items 0-999/5000, so clients can tell it
+// is partial. Larger bodies and flushed JSON responses are only reported;
+// other content, such as event streams and downloads, and connection upgrades
+// (WebSockets) are not checked.
+func GuardLargeResponses(p ResponseGuardPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/api/") || upgradeRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &guardBuffer{ResponseWriter: w, limit: p.MaxBytes}
+			next.ServeHTTP(gw, r)
+			if gw.hijacked {
+				return
+			}
+			logger := pkglogger.FromContext(r.Context())
+			if gw.passthrough {
+				if gw.json && gw.total > p.MaxBytes {
+					metrics.ObserveLargeResponse(r, "bytes", "warned")
+					logger.Warn("large response", slog.String("route", requestRoute(r)), slog.Int64("bytes", gw.total), slog.Int64("max_bytes", p.MaxBytes))
+				}
+				return
+			}
+
+			status, body := gw.status, gw.buf.Bytes()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status == http.StatusOK {
+				if list, ok := largestList(body); ok && len(list.items) > p.MaxItems {
+					action := "warned"
+					if p.Truncate {
+						action = "truncated"
+						body = truncateList(body, list, p.MaxItems)
+						status = http.StatusPartialContent
+						h := w.Header()
+						h.Set("Content-Range", fmt.Sprintf("items 0-%d/%d", p.MaxItems-1, len(list.items)))
+						h.Del("Content-Length")
+						h.Del("ETag") // it names the full list
+					}
+					metrics.ObserveLargeResponse(r, "items", action)
+					logger.Warn("large response", slog.String("route", requestRoute(r)), slog.String("field", list.field),
+						slog.Int("items", len(list.items)), slog.Int("max_items", p.MaxItems), slog.Bool("truncated", p.Truncate))
+				}
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+		})
+	}
+}
+
+// upgradeRequest reports whether r asks to switch protocols, as WebSocket
+// handshakes do.
+func upgradeRequest(r *http.Request) bool {
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestRoute returns the route pattern r matched, or its path.
+func requestRoute(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return r.URL.Path
+}
+
+// jsonList is a list in a JSON body: its items and where it is in the body.
+type jsonList struct {
+	field      string // empty for a body that is a list itself
+	items      []json.RawMessage
+	start, end int
+}
+
+// largestList returns the longest list of a JSON body: the body itself when
+// it is an array, otherwise the longest array among the fields of the
+// top-level object. It reports false for other bodies.
+func largestList(body []byte) (jsonList, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	tok, err := dec.Token()
+	if err != nil {
+		return jsonList{}, false
+	}
+	if tok == json.Delim('[') {
+		var items []json.RawMessage
+		start := bytes.IndexByte(body, '[')
+		if err := json.NewDecoder(bytes.NewReader(body[start:])).Decode(&items); err != nil {
+			return jsonList{}, false
+		}
+		return jsonList{items: items, start: start, end: len(bytes.TrimRight(body, " \t\r\n"))}, true
+	}
+	if tok != json.Delim('{') {
+		return jsonList{}, false
+	}
+	var longest jsonList
+	found := false
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return jsonList{}, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return jsonList{}, false
+		}
+		if len(value) == 0 || value[0] != '[' {
+			continue
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil {
+			return jsonList{}, false
+		}
+		if !found || len(items) > len(longest.items) {
+			end := int(dec.InputOffset())
+			longest = jsonList{field: key.(string), items: items, start: end - len(value), end: end}
+			found = true
+		}
+	}
+	return longest, found
+}
+
+// truncateList returns body with list cut to its first max items; the rest of
+// the body is kept byte for byte.
+func truncateList(body []byte, list jsonList, max int) []byte {
+	items, _ := json.Marshal(list.items[:max])
+	out := make([]byte, 0, list.start+len(items)+len(body)-list.end)
+	out = append(out, body[:list.start]...)
+	out = append(out, items...)
+	return append(out, body[list.end:]...)
+}
+
+// guardBuffer buffers a JSON response up to limit bytes. Other responses,
+// those over the limit and those flushed are streamed as they are, counting
+// their bytes.
+type guardBuffer struct {
+	http.ResponseWriter
+	limit       int64
+	status      int
+	buf         bytes.Buffer
+	total       int64
+	json        bool
+	passthrough bool
+	hijacked    bool // the handler took over the connection; nothing is written
+}
+
+// start decides, when the header is about to be written, whether the
+// response is buffered.
+func (w *guardBuffer) start(code int) {
+	if w.status != 0 || w.passthrough {
+		return
+	}
+	w.status = code
+	mt, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.json = mt == "application/json" || strings.HasSuffix(mt, "+json")
+	if !w.json {
+		w.stream()
+	}
+}
+
+// stream writes the header and what is buffered, then passes writes through.
+func (w *guardBuffer) stream() {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+}
+
+func (w *guardBuffer) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.start(code)
+}
+
+func (w *guardBuffer) Write(b []byte) (int, error) {
+	w.start(http.StatusOK)
+	w.total += int64(len(b))
+	if !w.passthrough && int64(w.buf.Len()+len(b)) > w.limit {
+		w.stream()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// Flush gives up on buffering: a handler that flushes wants the client to
+// see what it wrote so far.
+func (w *guardBuffer) Flush() {
+	w.start(http.StatusOK)
+	if !w.passthrough {
+		w.stream()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection to the handler, which then owns it.
+func (w *guardBuffer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *guardBuffer) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// newResponseGuard returns the guard configured by RESPONSE_GUARD, or nil
+// when it is off.
+func newResponseGuard(mode string, maxItems int, maxBytes int64) func(http.Handler) http.Handler {
+	if mode != responseGuardWarn && mode != responseGuardStrict {
+		return nil
+	}
+	return GuardLargeResponses(ResponseGuardPolicy{MaxItems: maxItems, MaxBytes: maxBytes, Truncate: mode == responseGuardStrict})
+}
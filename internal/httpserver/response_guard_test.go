@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+func guardedList(p ResponseGuardPolicy, body string) (*httptest.ResponseRecorder, string) {
+	var buf bytes.Buffer
+	h := GuardLargeResponses(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(body))
+	}))
+	ctx := pkglogger.IntoContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil).WithContext(ctx))
+	return rec, buf.String()
+}
+
+func TestGuardLargeResponses_WarnsAboutLongLists(t *testing.T) {
+	body := `{"users":[1,2,3,4],"total":4}`
+	rec, logged := guardedList(ResponseGuardPolicy{MaxItems: 3, MaxBytes: 1 << 20}, body)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("expected the response unchanged, got %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(logged, "large response") || !strings.Contains(logged, `"field":"users"`) || !strings.Contains(logged, `"items":4`) {
+		t.Fatalf("expected a warning naming the list, got %s", logged)
+	}
+}
+
+func TestGuardLargeResponses_StrictTruncates(t *testing.T) {
+	rec, logged := guardedList(ResponseGuardPolicy{MaxItems: 2, MaxBytes: 1 << 20, Truncate: true}, `{"page":1,"users":[{"id":1},{"id":2},{"id":3}],"tags":["a"]}`)
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != `{"page":1,"users":[{"id":1},{"id":2}],"tags":["a"]}` {
+		t.Fatalf("expected the list cut to 2 items, got %s", got)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "items 0-1/3" {
+		t.Fatalf("expected Content-Range items 0-1/3, got %q", got)
+	}
+	if rec.Header().Get("ETag") != "" {
+		t.Fatal("expected the full list's ETag to be dropped")
+	}
+	if !strings.Contains(logged, `"truncated":true`) {
+		t.Fatalf("expected the truncation to be logged, got %s", logged)
+	}
+}
+
+func TestGuardLargeResponses_TruncatesTopLevelArrays(t *testing.T) {
+	rec, _ := guardedList(ResponseGuardPolicy{MaxItems: 1, MaxBytes: 1 << 20, Truncate: true}, "[1,2]\n")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "[1]\n" {
+		t.Fatalf("expected [1] as a 206, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGuardLargeResponses_LeavesSmallResponses(t *testing.T) {
+	body := `{"users":[1,2],"total":2}`
+	rec, logged := guardedList(ResponseGuardPolicy{MaxItems: 2, MaxBytes: 1 << 20, Truncate: true}, body)
+	if rec.Code != http.StatusOK || rec.Body.String() != body || logged != "" {
+		t.Fatalf("expected the response untouched and nothing logged, got %d %s %s", rec.Code, rec.Body.String(), logged)
+	}
+}
+
+func TestGuardLargeResponses_WarnsAboutLargeBodies(t *testing.T) {
+	body := `{"data":"` + strings.Repeat("x", 64) + `"}`
+	rec, logged := guardedList(ResponseGuardPolicy{MaxItems: 10, MaxBytes: 32, Truncate: true}, body)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("expected large bodies to pass through, got %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(logged, `"max_bytes":32`) {
+		t.Fatalf("expected a byte limit warning, got %s", logged)
+	}
+}
+
+func TestGuardLargeResponses_LeavesHijackedConnections(t *testing.T) {
+	var guarded []bool
+	h := GuardLargeResponses(ResponseGuardPolicy{MaxItems: 1, MaxBytes: 1 << 20})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, buffered := w.(*guardBuffer)
+		guarded = append(guarded, buffered)
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		_ = rw.Flush()
+		conn.Close()
+	}))
+	var errs bytes.Buffer
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.ErrorLog = log.New(&errs, "", 0)
+	srv.Start()
+	defer srv.Close()
+
+	for _, connection := range []string{"keep-alive, Upgrade", ""} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/ws", nil)
+		if connection != "" {
+			req.Header.Set("Connection", connection)
+			req.Header.Set("Upgrade", "websocket")
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("expected 101, got %v (err %v)", resp, err)
+		}
+		resp.Body.Close()
+	}
+	if len(guarded) != 2 || guarded[0] || !guarded[1] {
+		t.Fatalf("expected only the request without Connection: Upgrade to be buffered, got %v", guarded)
+	}
+	if errs.Len() > 0 {
+		t.Fatalf("expected nothing written to the hijacked connections, got %s", errs.String())
+	}
+}
@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/sampling"
+	"github.com/mikko-kohtala/go-api/internal/scrub"
+)
+
+// SampleBodies records the requests s picks, with their bodies as the
+// handler read and wrote them, as anonymized samples for GET /admin/samples.
+// It sits inside DecompressRequest and Compress, so bodies are plain. Event
+// streams are never sampled; they have no end to record.
+func SampleBodies(s *sampling.Sampler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.Pick() || response.AcceptsEventStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			req := &bodyCapture{limit: s.MaxBodyBytes()}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &capturedBody{ReadCloser: r.Body, capture: req}
+			}
+			sw := &sampleWriter{ResponseWriter: w, body: bodyCapture{limit: s.MaxBodyBytes()}}
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			s.Record(sampling.Sample{
+				Time:      start,
+				Route:     requestRoute(r),
+				Method:    r.Method,
+				URL:       scrub.URL(r.URL),
+				RequestID: response.RequestID(r),
+				Status:    status,
+				Duration:  time.Since(start),
+				Request:   sampling.Anonymize(r.Header.Get("Content-Type"), req.buf.Bytes(), req.size),
+				Response:  sampling.Anonymize(w.Header().Get("Content-Type"), sw.body.buf.Bytes(), sw.body.size),
+			})
+		})
+	}
+}
+
+// bodyCapture keeps the first limit bytes of a body and counts all of them.
+type bodyCapture struct {
+	limit int
+	buf   bytes.Buffer
+	size  int64
+}
+
+func (c *bodyCapture) add(b []byte) {
+	c.size += int64(len(b))
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(b[:min(room, len(b))])
+	}
+}
+
+// capturedBody is a request body capturing what the handler reads.
+type capturedBody struct {
+	io.ReadCloser
+	capture *bodyCapture
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.capture.add(p[:n])
+	return n, err
+}
+
+// sampleWriter captures the status and body of a response.
+type sampleWriter struct {
+	http.ResponseWriter
+	status int
+	body   bodyCapture
+}
+
+func (w *sampleWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sampleWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.add(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sampleWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *sampleWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
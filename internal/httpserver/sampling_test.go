@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/sampling"
+)
+
+func TestSampleBodies_RecordsAnonymizedPairs(t *testing.T) {
+	s := sampling.New(sampling.Options{Rate: 1})
+	h := SampleBodies(s)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"usr_1","name":"Alice"}`))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users?token=abc", strings.NewReader(`{"name":"Alice","password":"pw"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	samples := s.Recent("")
+	if len(samples) != 1 {
+		t.Fatalf("expected one sample, got %d", len(samples))
+	}
+	got := samples[0]
+	if got.Status != http.StatusCreated || strings.Contains(got.URL, "abc") {
+		t.Fatalf("unexpected sample %+v", got)
+	}
+	if string(got.Request.JSON) != `{"name":"xxxxx","password":"[REDACTED]"}` || string(got.Response.JSON) != `{"id":"xxx_9","name":"xxxxx"}` {
+		t.Fatalf("expected anonymized bodies, got %s and %s", got.Request.JSON, got.Response.JSON)
+	}
+}
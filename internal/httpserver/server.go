@@ -1,74 +1,274 @@
 package httpserver
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 	"github.com/go-chi/httprate"
 	docs "github.com/mikko-kohtala/go-api/internal/docs"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 
+	"github.com/mikko-kohtala/go-api/internal/alert"
+	"github.com/mikko-kohtala/go-api/internal/apiversion"
+	"github.com/mikko-kohtala/go-api/internal/assets"
+	"github.com/mikko-kohtala/go-api/internal/audit"
+	"github.com/mikko-kohtala/go-api/internal/auth"
+	"github.com/mikko-kohtala/go-api/internal/boot"
+	"github.com/mikko-kohtala/go-api/internal/canary"
 	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/featureflags"
+	"github.com/mikko-kohtala/go-api/internal/handlers"
+	"github.com/mikko-kohtala/go-api/internal/httpclient"
+	"github.com/mikko-kohtala/go-api/internal/i18n"
+	"github.com/mikko-kohtala/go-api/internal/idempotency"
+	"github.com/mikko-kohtala/go-api/internal/imaging"
+	"github.com/mikko-kohtala/go-api/internal/invalidation"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/metering"
 	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/ratelimit"
+	"github.com/mikko-kohtala/go-api/internal/redis"
+	"github.com/mikko-kohtala/go-api/internal/repository"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/retention"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
 	"github.com/mikko-kohtala/go-api/internal/routes"
+	"github.com/mikko-kohtala/go-api/internal/sampling"
 	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/shutdown"
+	"github.com/mikko-kohtala/go-api/internal/signedurl"
+	"github.com/mikko-kohtala/go-api/internal/snapshot"
+	"github.com/mikko-kohtala/go-api/internal/storage"
+	"github.com/mikko-kohtala/go-api/internal/tokensource"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+	"github.com/mikko-kohtala/go-api/internal/webhooks"
+	"github.com/mikko-kohtala/go-api/internal/ws"
+	"github.com/mikko-kohtala/go-api/pkg/safego"
 )
 
 // NewRouter assembles the chi router with middleware and routes.
 // This function only builds the server structure - all handlers are defined in the handlers package.
+// It panics where NewCheckedRouter would fail for a misconfiguration.
 func NewRouter(cfg *config.Config, appLogger *slog.Logger) http.Handler {
+	h, _, err := newRouter(cfg, appLogger)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// NewCheckedRouter is NewRouter, but fails when the JWT settings cannot be
+// used, or when MIDDLEWARE_LINT=strict and the middleware chain has known
+// hazards.
+func NewCheckedRouter(cfg *config.Config, appLogger *slog.Logger) (http.Handler, error) {
+	h, hazards, err := newRouter(cfg, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MiddlewareLint == "strict" && len(hazards) > 0 {
+		return nil, fmt.Errorf("middleware chain has %d hazard(s): %v", len(hazards), hazards)
+	}
+	return h, nil
+}
+
+// newRouter builds the router and returns it with its middleware hazards,
+// which are logged unless MIDDLEWARE_LINT=off.
+func newRouter(cfg *config.Config, appLogger *slog.Logger) (http.Handler, []Hazard, error) {
+	// Outbound clients created below share the resolver and instance balancing
+	httpclient.Configure(httpclient.Options{
+		DNSCacheMaxTTL:   cfg.OutboundDNSCacheMaxTTL,
+		DNSFallbackTTL:   cfg.OutboundDNSFallbackTTL,
+		Endpoints:        cfg.OutboundEndpoints,
+		EndpointCooldown: cfg.OutboundEndpointCooldown,
+		Auth:             newTokenSource(cfg),
+		AuthHosts:        cfg.S2SAuthHosts,
+		Retries:          cfg.OutboundRetries,
+		RetryBackoff:     cfg.OutboundRetryBackoff,
+		IdempotentHosts:  cfg.OutboundIdempotentHosts,
+	})
+
 	// Initialize services
-	userService := services.NewUserService()
+	quotas := newQuotaManager(cfg, appLogger)
+	bus := events.NewBus()
+	meteringAggregator := metering.NewAggregator(cfg.MeteringRetention)
+	meteringAggregator.Subscribe(bus)
+	jobs.Default.SetObserver(func(ctx context.Context, job string, _ error) {
+		metering.Emit(ctx, bus, metering.Event{Type: metering.JobExecuted, Key: metering.Consumer(ctx), Quantity: 1})
+	})
+	notificationPrefs := notify.NewMemoryPreferences()
+	auditSink, auditFile := newAuditSink(cfg, appLogger)
+	users := services.NewUserServiceWithRepository(newUserRepository(cfg, appLogger), newNotifier(cfg, notificationPrefs, appLogger))
+	userService := services.NewQuotaUserService(users, quotas)
+	if auditSink != nil {
+		userService = services.NewAuditedUserService(userService)
+	}
+	taskService := services.NewTaskService(newTaskRepository(cfg, bus), bus)
+	if cfg.SeedData {
+		seedTasks(taskService, appLogger)
+	}
 	statsService := services.NewStatsService()
+	if tracing.Default.Enabled() {
+		userService = services.NewTracedUserService(userService)
+		statsService = services.NewTracedStatsService(statsService)
+	}
+	fileService := services.NewMeteredFileService(
+		services.NewQuotaFileService(services.NewFileService(newFileStore(cfg, appLogger), cfg.UploadMaxBytes, cfg.UploadExpiry), quotas),
+		bus)
+	imageProcessor := imaging.NewProcessor(imaging.Limits{
+		MaxSourcePixels: cfg.ImageMaxSourcePixels,
+		MaxDimension:    cfg.ImageMaxDimension,
+	}, cfg.ImageMaxConcurrency, cfg.ImageCacheBytes)
+	reportService := services.NewReportService(userService, statsService, fileService, operations.Default)
+	usageTracker := usage.NewTracker(cfg.UsageWindow, cfg.UsageMaxKeys)
+	apiKeys := services.NewAPIKeyService()
+	if s, ok := apiKeys.(snapshot.Store); ok {
+		snapshot.Default.RegisterSecret("apikeys", s)
+	}
+	registerRetentionPolicies(cfg, auditFile, fileService)
+	loadCatalogs(appLogger)
+	broadcastTaskEvents(bus, ws.Default)
 
-	// Determine whether to include debugging/test routes
-	includeTestRoutes := cfg.Env != "production" && cfg.Env != "prod"
+	// Route suggestions and stack traces stay out of production
+	production := routes.NormalizeEnv(cfg.Env) == routes.EnvProduction
+	response.ExposeStacks(cfg.Env == "development")
+	// Validated by config.Load
+	_ = response.SetEncoding(response.Encoding{FieldNames: cfg.JSONFieldNames, Times: cfg.JSONTimeFormat, UTC: cfg.JSONTimeUTC})
 
 	// Initialize routes with services
-	routesHandler := routes.NewRoutesWithTests(appLogger, userService, statsService, includeTestRoutes)
+	routesHandler := routes.NewRoutesForEnv(appLogger, userService, taskService, statsService, fileService, newSigner(cfg, appLogger), imageProcessor, reportService, notificationPrefs, usageTracker, quotas, meteringAggregator, newFlagClient(cfg, appLogger), config.Describe(cfg), newWebhooks(cfg, bus, appLogger), apiKeys, cfg.Env)
+
+	authenticate, identify, err := newAuthenticator(cfg, apiKeys, appLogger)
+	if err != nil {
+		return nil, nil, err
+	}
+	routesHandler.SetAuthentication(authenticate, cfg.AuthGroups...)
+	if cfg.BootReportEndpoint {
+		routesHandler.ServeBootReport()
+	}
+	routesHandler.SetDocsExposure(cfg.DocsExposure)
+	routesHandler.SetGraphQLLimits(handlers.GraphQLLimits{MaxDepth: cfg.GraphQLMaxDepth, MaxSelections: cfg.GraphQLMaxSelections})
 
 	r := chi.NewRouter()
 
+	// Panics in background goroutines are counted and alerted like handler panics
+	alerter := newAlerter(cfg, appLogger)
+	safego.SetReporter(func(ctx context.Context, p safego.Panic) {
+		metrics.ObserveGoroutinePanic(p.Name)
+		alerter.BackgroundPanic(ctx, p.Name, p.Value)
+	})
+
 	// Setup middleware
-	setupMiddleware(r, cfg, appLogger)
+	middlewareNames, hazards := setupMiddleware(r, cfg, appLogger, alerter, auditSink, bus)
+	if cfg.MiddlewareLint == "off" {
+		hazards = nil
+	}
+	for _, h := range hazards {
+		appLogger.Warn("middleware chain hazard", slog.String("rule", h.Rule), slog.String("detail", h.Message))
+	}
 
 	// Setup rate limiting
-	apiRate := setupRateLimiting(cfg, appLogger)
+	apiRate := setupRateLimiting(cfg, appLogger, identify)
 
 	// Setup all routes
-	setupRoutes(r, routesHandler, apiRate)
+	// /api/v1 middleware in order: accounting before the limiter so rejected requests count too,
+	// and idempotent replays before it so retries of applied requests are not limited again
+	setupRoutes(r, routesHandler, apiRate,
+		TrackUsage(usageTracker), MeterRequests(bus, identify), Idempotency(idempotency.NewStore(cfg.IdempotencyTTL, cfg.IdempotencyMaxKeys)),
+		apiRate, EnforceQuota(quotas, identify), newCanaryRouting(cfg, appLogger),
+		newAPIVersioning(cfg, appLogger))
 
 	// Setup Swagger documentation
-	setupSwagger(r, routesHandler)
-
-	return r
-}
-
-// setupMiddleware configures all middleware for the router
-func setupMiddleware(r chi.Router, cfg *config.Config, appLogger *slog.Logger) {
-	// Core middleware (place timeout early to bound all work)
-	r.Use(middleware.Timeout(cfg.RequestTimeout))
-	r.Use(BodyLimit(cfg.BodyLimitBytes))
-	r.Use(RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(metrics.Middleware)
-	r.Use(middleware.Compress(cfg.CompressionLevel))
-	r.Use(LoggingMiddleware(appLogger))
-	r.Use(middleware.Recoverer)
-
-	// CORS configuration
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   cfg.CORSAllowedOrigins,
-		AllowedMethods:   cfg.CORSAllowedMethods,
-		AllowedHeaders:   cfg.CORSAllowedHeaders,
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	setupSwagger(r, routesHandler, cfg)
+
+	// JSON 404/405 handlers; route suggestions would leak the route table in production
+	r.NotFound(notFoundHandler(r, !production))
+	r.MethodNotAllowed(methodNotAllowedHandler(r))
+
+	boot.Default.SetRouter(middlewareNames, enabledFeatures(cfg, auditSink != nil), len(routemeta.Default.Routes()))
+	return r, hazards, nil
+}
+
+// setupMiddleware configures all middleware for the router and returns the
+// names of the chain, outermost first, and its hazards found by
+// LintMiddleware.
+func setupMiddleware(r chi.Router, cfg *config.Config, appLogger *slog.Logger, alerter *alert.Alerter, auditSink audit.Sink, bus *events.Bus) ([]string, []Hazard) {
+	chain := []namedMiddleware{
+		// Standard response headers; outermost so X-Response-Time covers all work
+		{mwHeaders, ResponseHeaders(newHeaderPolicy(cfg))},
+		// Core middleware (place timeout early to bound all work)
+		{mwTimeout, Timeout(cfg.RequestTimeout, cfg.RequestBudgetEnabled)},
+		{mwDecompress, DecompressRequest(cfg.DecompressionMaxRatio, appLogger)}, // before BodyLimit so the limit counts decompressed bytes
+		{mwBodyLimit, BodyLimit(cfg.BodyLimitBytes)},
+		{mwRequestID, RequestID},
+		{mwRealIP, middleware.RealIP},
+		{mwClient, ClassifyClient},
+		{mwFlags, FlagContext(cfg.FeatureFlagsCountryHeader)},
+		{mwExperiment, AssignExperiments(cfg.Experiments, bus)},
+		{mwLanguage, NegotiateLanguage(i18n.Default)},
+		{mwMetrics, metrics.Middleware},
+		{mwHead, AutoHead},
+		{mwCompress, Compress(cfg.CompressionLevel, NewCompressionCache(cfg.CompressionLevel, cfg.CompressionCacheBytes, cfg.CompressionCacheRoutes))},
+		{mwLogging, LoggingMiddleware(appLogger)},
+		{mwAbandon, RecordAbandoned},
+		// Queue for a slot after logging and metrics so waits and rejections are observed
+		{mwPriority, Prioritize(newPriorityPolicy(cfg))},
+		{mwAdmission, LimitConcurrency(cfg.MaxConcurrentRequests, cfg.AdmissionQueueSize, cfg.AdmissionQueueTimeout)},
+		{mwAlerts, alerter.Middleware}, // sees the 500s written by the recoverer below
+		{mwRecoverer, RecovererWithAlerts(alerter)},
+	}
+	if tracing.Default.Enabled() {
+		// After real_ip for the client address, before logging for the trace ID
+		at := slices.IndexFunc(chain, func(m namedMiddleware) bool { return m.name == mwRealIP }) + 1
+		chain = slices.Insert(chain, at, namedMiddleware{mwTrace, Trace(tracing.Default)})
+	}
+	if guard := newResponseGuard(cfg.ResponseGuard, cfg.ResponseGuardMaxItems, cfg.ResponseGuardMaxBytes); guard != nil {
+		// Inside compress and logging: it counts plain JSON and logs with the request ID
+		chain = append(chain, namedMiddleware{mwGuard, guard})
+	}
+	if sampling.Default.Enabled() {
+		// Inside compress and decompress, so it sees bodies as handlers do
+		chain = append(chain, namedMiddleware{mwSampler, SampleBodies(sampling.Default)})
+	}
+	if auditSink != nil {
+		chain = append(chain, namedMiddleware{mwAudit, AuditTrail(auditSink)})
+	}
+
+	// CORS configuration: global policy plus per route group origin overrides
+	corsPolicy := CORSPolicy{
+		AllowedOrigins: cfg.CORSAllowedOrigins,
+		AllowedMethods: cfg.CORSAllowedMethods,
+		AllowedHeaders: cfg.CORSAllowedHeaders,
+		MaxAge:         cfg.CORSMaxAge,
+	}
+	corsOverrides := make(map[string]CORSPolicy, len(cfg.CORSRouteOrigins))
+	for prefix, origins := range cfg.CORSRouteOrigins {
+		p := corsPolicy
+		p.AllowedOrigins = origins
+		corsOverrides[prefix] = p
+	}
+	chain = append(chain, namedMiddleware{mwCORS, CORS(corsPolicy, corsOverrides)})
+
+	for _, m := range chain {
+		r.Use(m.mw)
+	}
 
 	// Warn if permissive CORS in production
 	if cfg.Env == "production" || cfg.Env == "prod" {
@@ -79,10 +279,14 @@ func setupMiddleware(r chi.Router, cfg *config.Config, appLogger *slog.Logger) {
 			}
 		}
 	}
+	names := chainNames(chain)
+	return names, LintMiddleware(names)
 }
 
-// setupRateLimiting configures rate limiting middleware
-func setupRateLimiting(cfg *config.Config, appLogger *slog.Logger) func(http.Handler) http.Handler {
+// setupRateLimiting configures rate limiting middleware. Callers identify
+// verifies are limited per user, others per IP; RATE_LIMIT_ROUTES replace
+// RATE_LIMIT on the routes they match.
+func setupRateLimiting(cfg *config.Config, appLogger *slog.Logger, identify func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
 	if !cfg.RateLimitEnabled {
 		return func(h http.Handler) http.Handler { return h }
 	}
@@ -95,42 +299,561 @@ func setupRateLimiting(cfg *config.Config, appLogger *slog.Logger) func(http.Han
 		return func(h http.Handler) http.Handler { return h }
 	}
 
-	return httprate.LimitByIP(cfg.RateLimit, period)
+	// All limiters share one Redis connection, keeping their counters apart by name
+	var client *redis.Client
+	if cfg.RateLimitBackend == ratelimit.Redis {
+		opts, _ := redis.ParseURL(cfg.RedisURL) // validated by config.Load
+		client = redis.New(opts)
+		shutdown.Default.Register(shutdown.Hook{Name: "ratelimit:redis", Stage: shutdown.StageStorage, Timeout: cfg.ShutdownStopTimeout, Stop: func(context.Context) error { return client.Close() }})
+	}
+	newLimit := func(name string, n int, period time.Duration, key httprate.KeyFunc) func(http.Handler) http.Handler {
+		if client == nil {
+			return ratelimit.Limit(n, period, key, ratelimit.NewMemoryCounter(name, cfg.RateLimitMaxKeys))
+		}
+		return ratelimit.Limit(n, period, key, ratelimit.NewRedisCounter(client, name, cfg.RateLimitMaxKeys, appLogger))
+	}
+
+	key := rateLimitKey(identify)
+	limit := newLimit("api", cfg.RateLimit, period, key)
+	if len(cfg.RateLimitRoutes) > 0 {
+		routes := make([]routeLimit, len(cfg.RateLimitRoutes))
+		for i, rl := range cfg.RateLimitRoutes {
+			if rl.Period == 0 {
+				rl.Period = period
+			}
+			routes[i] = routeLimit{RouteRateLimit: rl, limit: newLimit("route:"+rl.Method+rl.Prefix, rl.Limit, rl.Period, key)}
+		}
+		limit = limitRoutes(limit, routes)
+	}
+	if cfg.BotRateLimit <= 0 {
+		return limit
+	}
+
+	// Bots and unidentified clients must pass both the stricter and the regular limit
+	botLimit := unidentifiedOnly(newLimit("bots", cfg.BotRateLimit, period, httprate.KeyByIP))
+	return func(h http.Handler) http.Handler { return limit(botLimit(h)) }
+}
+
+// enabledFeatures names the optional subsystems cfg turns on, for the boot
+// report.
+func enabledFeatures(cfg *config.Config, audited bool) []string {
+	features := []string{}
+	add := func(name string, on bool) {
+		if on {
+			features = append(features, name)
+		}
+	}
+	add("rate_limit_"+cfg.RateLimitBackend, cfg.RateLimitEnabled)
+	add("bot_rate_limit", cfg.RateLimitEnabled && cfg.BotRateLimit > 0)
+	add("route_rate_limits", cfg.RateLimitEnabled && len(cfg.RateLimitRoutes) > 0)
+	add("admission_control", cfg.MaxConcurrentRequests > 0)
+	add("api_key_auth", len(cfg.AuthGroups) > 0)
+	add("jwt_auth", len(cfg.AuthGroups) > 0 && cfg.JWTJWKSURL != "")
+	add("audit_log", audited)
+	add("tracing", cfg.OTELEndpoint != "")
+	add("database", cfg.DatabaseURL != "")
+	add("file_storage", cfg.StorageDir != "")
+	add("signed_urls", cfg.SignedURLSecret != "")
+	add("cache_invalidation", cfg.RedisURL != "")
+	add("degradation", cfg.DependencyCheckInterval > 0 && len(cfg.DegradedFeatures) > 0)
+	add("retention", cfg.RetentionInterval > 0)
+	add("snapshot", cfg.SnapshotFile != "")
+	add("feature_flags", cfg.FeatureFlagsProvider != "" && cfg.FeatureFlagsProvider != "none")
+	add("experiments", len(cfg.Experiments) > 0)
+	add("canary", cfg.CanaryPercent > 0)
+	add("alerts", cfg.AlertWebhookURL != "")
+	add("tls", cfg.TLSPort > 0)
+	add("admin_listener", cfg.AdminPort > 0)
+	add("proxy_protocol", cfg.ProxyProtocol)
+	return features
 }
 
-// setupRoutes configures all application routes
-func setupRoutes(r chi.Router, routesHandler *routes.Routes, apiRate func(http.Handler) http.Handler) {
+// setupRoutes configures all application routes. Routes.Mount leaves out the
+// groups the exposure matrix does not serve in this environment.
+func setupRoutes(r chi.Router, routesHandler *routes.Routes, apiRate func(http.Handler) http.Handler, apiMiddleware ...func(http.Handler) http.Handler) {
 	// Health endpoints (no rate limiting)
-	r.Group(func(r chi.Router) {
-		routesHandler.SetupHealthRoutes(r)
-	})
+	routesHandler.Mount(r, routes.GroupHealth, routesHandler.SetupHealthRoutes)
 
 	// API v1 routes (with rate limiting)
-	r.Route("/api/v1", func(r chi.Router) {
-		r.Use(apiRate)
+	routesHandler.Mount(r, routes.GroupAPI, func(r routes.Router) {
+		r.Use(RequireJSON)
 		routesHandler.SetupAPIV1Routes(r)
-	})
+	}, apiMiddleware...)
 
-	// Test routes (development only)
-	if routesHandler.IncludeTestRoutes() {
-		r.Route("/test", func(r chi.Router) {
-			routesHandler.SetupTestRoutes(r)
-		})
-	}
+	// GraphQL over the same services, behind the same middleware as /api/v1
+	routesHandler.Mount(r, routes.GroupGraphQL, func(r routes.Router) {
+		r.Use(RequireJSON)
+		routesHandler.SetupGraphQLRoutes(r)
+	}, apiMiddleware...)
+
+	// File uploads carry raw chunks rather than JSON, with a larger body limit
+	routesHandler.Mount(r, routes.GroupUploads, routesHandler.SetupFileRoutes, apiMiddleware...)
+
+	// Signed download URLs carry their own authorization
+	routesHandler.Mount(r, routes.GroupSignedFiles, routesHandler.SetupSignedFileRoutes, apiRate)
+
+	// Test routes (not in production)
+	routesHandler.Mount(r, routes.GroupTest, routesHandler.SetupTestRoutes)
 
 	// Metrics endpoint (no rate limiting)
-	r.Handle("/metrics", metrics.Handler())
+	routesHandler.Mount(r, routes.GroupMetrics, routesHandler.SetupMetricsRoutes)
+
+	// Operator endpoints (admin listener only when ADMIN_PORT is set)
+	routesHandler.Mount(r, routes.GroupAdmin, routesHandler.SetupAdminRoutes)
+
+	// Packaged static files
+	routesHandler.Mount(r, routes.GroupStatic, routesHandler.SetupStaticRoutes)
 
 	// Root route
-	routesHandler.SetupRootRoute(r)
+	routesHandler.Mount(r, routes.GroupRoot, routesHandler.SetupRootRoute)
+}
+
+// newAuditSink opens the AUDIT_SINKS, returning them as one sink and the file
+// sink, which retention prunes, on its own. Both are nil when auditing is
+// disabled; a sink that cannot be opened is logged and left out.
+func newAuditSink(cfg *config.Config, appLogger *slog.Logger) (audit.Sink, *audit.ChainedFileSink) {
+	var sinks []audit.Sink
+	var file *audit.ChainedFileSink
+	for _, name := range cfg.AuditSinks {
+		var sink audit.Sink
+		var err error
+		switch name {
+		case "file":
+			if file, err = audit.OpenChainedFile(cfg.AuditLogFile); err == nil {
+				sink = file
+			}
+		case "log":
+			sink = audit.NewLogSink(appLogger)
+		case "database":
+			sink, err = newPostgresAudit(cfg)
+		}
+		if err != nil {
+			appLogger.Error("audit sink unavailable; left out of the audit trail", slog.String("sink", name), slog.String("error", err.Error()))
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	sink := audit.Multi(sinks...)
+	shutdown.Default.Register(shutdown.Hook{Name: "audit", Stage: shutdown.StageStorage, Timeout: cfg.ShutdownStopTimeout, Stop: func(context.Context) error { return sink.Close() }})
+	return sink, file
+}
+
+func newPostgresAudit(cfg *config.Config) (*repository.PostgresAudit, error) {
+	ctx := context.Background()
+	db, err := repository.Open(ctx, cfg.DatabaseURL, cfg.DatabaseConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := repository.NewPostgresAudit(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+// registerRetentionPolicies adds the configured retention policies to
+// retention.Default; main starts the schedule.
+func registerRetentionPolicies(cfg *config.Config, auditSink *audit.ChainedFileSink, fileService services.FileService) {
+	if auditSink != nil {
+		retention.Default.Add(retention.Policy{
+			Name:   "audit-log",
+			Action: retention.ActionPurge,
+			MaxAge: cfg.RetentionAuditLogMaxAge,
+			Target: retention.TargetFunc(auditSink.Prune),
+		})
+	}
+	retention.Default.Add(retention.Policy{
+		Name:   "files",
+		Action: retention.ActionPurge,
+		MaxAge: cfg.RetentionFilesMaxAge,
+		Target: retention.TargetFunc(fileService.PurgeOlderThan),
+	})
+	retention.Default.Add(retention.Policy{
+		Name:   "dead-letters",
+		Action: retention.ActionPurge,
+		MaxAge: cfg.RetentionDeadLettersMaxAge,
+		Target: retention.TargetFunc(jobs.Default.DeadLetters().Purge),
+	})
+}
+
+// newQuotaManager returns the quota manager, persisting counters to
+// QUOTA_STATE_FILE every few seconds and on shutdown. An unreadable state file
+// is logged and counting starts over.
+func newQuotaManager(cfg *config.Config, appLogger *slog.Logger) *quota.Manager {
+	opts := quota.Options{
+		Defaults: quota.Limits{
+			RequestsPerDay: cfg.QuotaRequestsPerDay,
+			StorageBytes:   cfg.QuotaStorageBytes,
+			Users:          cfg.QuotaUsers,
+		},
+		StatePath:  cfg.QuotaStateFile,
+		FlushEvery: 5 * time.Second,
+		Logger:     appLogger,
+	}
+	m, err := quota.NewManager(opts)
+	if err != nil {
+		appLogger.Error("quota state unreadable; starting with empty counters",
+			slog.String("path", cfg.QuotaStateFile),
+			slog.String("error", err.Error()))
+		opts.StatePath = ""
+		m, _ = quota.NewManager(opts)
+	}
+	m.Start()
+	shutdown.Default.Register(shutdown.Hook{Name: "quota", Stage: shutdown.StageStorage, Timeout: cfg.ShutdownStopTimeout, Stop: func(context.Context) error { m.Stop(); return nil }})
+	return m
+}
+
+// newFlagClient returns a client for FEATURE_FLAGS_PROVIDER. A provider that
+// fails to start is logged and replaced by one that serves flag defaults.
+func newFlagClient(cfg *config.Config, appLogger *slog.Logger) *featureflags.Client {
+	name := cfg.FeatureFlagsProvider
+	if name == "" {
+		name = "none"
+	}
+	p, err := featureflags.New(name, cfg.FeatureFlagsConfig)
+	if err != nil {
+		appLogger.Error("feature flag provider unavailable; serving flag defaults",
+			slog.String("provider", name),
+			slog.String("error", err.Error()))
+		p = featureflags.NoopProvider{}
+	}
+	return featureflags.NewClient(p)
+}
+
+// newCanaryRouting returns the canary split for /api/v1, or a pass-through
+// when neither CANARY_PERCENT nor CANARY_UPSTREAM_URL is set.
+func newCanaryRouting(cfg *config.Config, appLogger *slog.Logger) func(http.Handler) http.Handler {
+	if cfg.CanaryPercent == 0 && cfg.CanaryUpstreamURL == "" {
+		return func(h http.Handler) http.Handler { return h }
+	}
+	var upstream http.Handler
+	if cfg.CanaryUpstreamURL != "" {
+		target, _ := url.Parse(cfg.CanaryUpstreamURL) // validated by config.Load
+		upstream = newCanaryProxy(target, appLogger)
+	}
+	return CanaryRouting(canary.Options{
+		Percent: cfg.CanaryPercent,
+		Header:  cfg.CanaryHeader,
+		Cookie:  cfg.CanaryCookie,
+	}, upstream)
+}
+
+// newFileStore returns the disk store under STORAGE_DIR, or an in-memory store
+// when no directory is configured (or it cannot be created).
+func newFileStore(cfg *config.Config, appLogger *slog.Logger) storage.Store {
+	if cfg.StorageDir == "" {
+		return storage.NewMemory()
+	}
+	store, err := storage.NewDisk(cfg.StorageDir)
+	if err != nil {
+		appLogger.Error("storage directory unavailable, keeping files in memory",
+			slog.String("dir", cfg.StorageDir),
+			slog.String("error", err.Error()))
+		return storage.NewMemory()
+	}
+	return store
+}
+
+// newUserRepository returns the Postgres users repository when DATABASE_URL
+// is set, otherwise an in-memory one holding the sample users, which is part
+// of snapshots. A database that cannot be reached is logged and users are
+// kept in memory.
+func newUserRepository(cfg *config.Config, appLogger *slog.Logger) services.UserRepository {
+	if cfg.DatabaseURL != "" {
+		repo, err := newPostgresUsers(cfg)
+		if err == nil {
+			return repo
+		}
+		appLogger.Error("database unavailable, keeping users in memory", slog.String("error", err.Error()))
+	}
+	repo := services.NewMemoryUserRepository(services.SampleUsers()...)
+	if s, ok := repo.(snapshot.Store); ok {
+		snapshot.Default.Register("users", s)
+	}
+	return repo
+}
+
+func newPostgresUsers(cfg *config.Config) (*repository.PostgresUsers, error) {
+	ctx := context.Background()
+	db, err := repository.Open(ctx, cfg.DatabaseURL, cfg.DatabaseConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := repository.NewPostgresUsers(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	shutdown.Default.Register(shutdown.Hook{Name: "database", Stage: shutdown.StageStorage, Timeout: cfg.ShutdownStopTimeout, Stop: func(context.Context) error { return db.Close() }})
+	return repo, nil
+}
+
+// newTaskRepository returns the task repository, behind a read cache unless
+// TASK_CACHE_TTL is 0. Swap the in-memory repository for a database-backed
+// one here; the service and handler do not change. Task events invalidate
+// the cache on every replica through invalidation.Default.
+func newTaskRepository(cfg *config.Config, bus *events.Bus) services.TaskRepository {
+	repo := services.NewMemoryTaskRepository()
+	store, _ := repo.(snapshot.Store)
+	if cfg.TaskCacheTTL <= 0 {
+		snapshot.Default.Register("tasks", store)
+		return repo
+	}
+	cached := services.NewCachedTaskRepository(repo, cfg.TaskCacheTTL)
+	// A restore replaces every task behind the cache
+	snapshot.Default.Register("tasks", snapshot.Then(store, func() { cached.Invalidate() }))
+	invalidation.Default.Register(services.TaskTopic, cached.Invalidate)
+	bus.Subscribe(services.TaskTopic, func(_ context.Context, payload any) {
+		if ev, ok := payload.(services.TaskEvent); ok {
+			invalidation.Default.Invalidate(services.TaskTopic, ev.Task.ID)
+		}
+	})
+	return cached
+}
+
+// newAuthenticator returns the authentication middleware of authenticated
+// routes: API keys, and JWT bearer tokens when signing keys are configured.
+// identify checks the same credentials for the rate limiter, which keys
+// identified callers by user. JWT_ISSUER or JWT_AUDIENCE without signing keys
+// is an error rather than a silent fallback to API keys only.
+func newAuthenticator(cfg *config.Config, apiKeys services.APIKeyService, appLogger *slog.Logger) (authenticate func(http.Handler) http.Handler, identify func(*http.Request) (string, bool), err error) {
+	secrets := make([][]byte, len(cfg.JWTHS256Secrets))
+	for i, s := range cfg.JWTHS256Secrets {
+		secrets[i] = []byte(s)
+	}
+	verifier, err := auth.NewVerifier(auth.Options{
+		HMACSecrets:  secrets,
+		JWKSURL:      cfg.JWTJWKSURL,
+		JWKSCacheTTL: cfg.JWTJWKSCacheTTL,
+		Client:       httpclient.New(10 * time.Second),
+		Issuer:       cfg.JWTIssuer,
+		Audiences:    cfg.JWTAudiences,
+		TenantClaim:  cfg.JWTTenantClaim,
+	})
+	for _, g := range cfg.AuthGroups {
+		if !slices.ContainsFunc(routes.Groups, func(rg routes.Group) bool { return rg.Name == g }) {
+			appLogger.Error("AUTH_GROUPS names an unknown route group", slog.String("group", g))
+		}
+	}
+	switch {
+	case err == nil:
+		return auth.Chain(auth.APIKeys(apiKeys), verifier.Authenticate), auth.Identify(apiKeys, verifier), nil
+	case cfg.JWTIssuer != "" || len(cfg.JWTAudiences) > 0:
+		// Bearer tokens would be ignored without a trace
+		appLogger.Error("JWT settings present but tokens cannot be verified", slog.String("error", err.Error()))
+		return nil, nil, fmt.Errorf("JWT settings: %w", err)
+	default:
+		return auth.APIKeys(apiKeys), auth.Identify(apiKeys, nil), nil
+	}
+}
+
+// newSigner returns the signer for download URLs. Without a configured secret a
+// random one is used, so URLs only verify on the instance that minted them.
+func newSigner(cfg *config.Config, appLogger *slog.Logger) *signedurl.Signer {
+	key := []byte(cfg.SignedURLSecret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		if cfg.Env == "production" || cfg.Env == "prod" {
+			appLogger.Warn("SIGNED_URL_SECRET not set; signed URLs will not verify across instances or restarts")
+		}
+	}
+	return signedurl.New(key, cfg.SignedURLClockSkew, cfg.SignedURLMaxTTL)
+}
+
+// newAPIVersioning migrates bodies of older API versions; it runs after canary
+// routing so proxied requests are migrated by the canary itself.
+func newAPIVersioning(cfg *config.Config, appLogger *slog.Logger) func(http.Handler) http.Handler {
+	reg := handlers.APIVersions()
+	def := cfg.APIVersionDefault
+	if def > reg.Current() {
+		appLogger.Warn("API_VERSION_DEFAULT is newer than the current API version; using the current version",
+			slog.String("default", def), slog.String("current", reg.Current()))
+		def = ""
+	}
+	return apiversion.Middleware(reg, def)
+}
+
+// newTokenSource returns the credentials presented to internal services, or
+// nil when S2S_AUTH=none.
+func newTokenSource(cfg *config.Config) tokensource.Source {
+	var src tokensource.Source
+	switch cfg.S2SAuth {
+	case "client_credentials":
+		src = tokensource.ClientCredentials{
+			TokenURL:     cfg.S2STokenURL,
+			ClientID:     cfg.S2SClientID,
+			ClientSecret: cfg.S2SClientSecret,
+			Scopes:       cfg.S2SScopes,
+			Audience:     cfg.S2SAudience,
+		}
+	case "kubernetes":
+		src = tokensource.File{Path: cfg.S2STokenFile}
+	case "gcp":
+		src = tokensource.GCPMetadata{Audience: cfg.S2SAudience}
+	case "aws":
+		src = tokensource.AWSMetadata{}
+	default:
+		return nil
+	}
+	return tokensource.NewCached(src, cfg.S2SAuth, cfg.S2SRefreshBefore)
+}
+
+// newAlerter returns the panic/error spike alerter, or nil when no webhook is configured.
+func newAlerter(cfg *config.Config, appLogger *slog.Logger) *alert.Alerter {
+	if cfg.AlertWebhookURL == "" {
+		return nil
+	}
+	return alert.New(alert.Options{
+		Sender: notify.WebhookProvider{
+			URL:    cfg.AlertWebhookURL,
+			Client: httpclient.New(10 * time.Second),
+			Slack:  cfg.AlertWebhookFormat == "slack",
+		},
+		Service:            cfg.Env,
+		TraceURL:           cfg.AlertTraceURL,
+		ErrorRateThreshold: cfg.AlertErrorRate,
+		ErrorWindow:        cfg.AlertErrorWindow,
+		MinRequests:        cfg.AlertMinRequests,
+		DedupWindow:        cfg.AlertDedupWindow,
+		Logger:             appLogger,
+	})
+}
+
+// newWebhooks returns the webhook subscription registry and delivers task
+// events to its subscriptions.
+func newWebhooks(cfg *config.Config, bus *events.Bus, appLogger *slog.Logger) *webhooks.Registry {
+	registry := webhooks.NewRegistry([]string{services.TaskCreated, services.TaskUpdated, services.TaskCompleted, services.TaskDeleted}, cfg.WebhookKeyRotationGrace)
+	service := webhooks.NewService(registry, jobs.Default, httpclient.New(cfg.WebhookTimeout), webhooks.Options{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		RetryDelay:  cfg.WebhookRetryDelay,
+	})
+	bus.Subscribe(services.TaskTopic, func(ctx context.Context, payload any) {
+		ev, ok := payload.(services.TaskEvent)
+		if !ok {
+			return
+		}
+		if err := service.Publish(ctx, ev.Type, ev.Task); err != nil {
+			appLogger.Warn("webhook deliveries not queued", slog.String("event", ev.Type), slog.String("error", err.Error()))
+		}
+	})
+	return registry
+}
+
+// broadcastTaskEvents sends task events to the WebSocket connections in the
+// tasks room of hub.
+func broadcastTaskEvents(bus *events.Bus, hub *ws.Hub) {
+	bus.Subscribe(services.TaskTopic, func(_ context.Context, payload any) {
+		if ev, ok := payload.(services.TaskEvent); ok {
+			hub.Broadcast(services.TaskTopic, ev.Type, ev.Task)
+		}
+	})
+}
+
+// newNotifier wires the configured notification transports. Outside production,
+// channels without a transport are written to the log instead.
+func newNotifier(cfg *config.Config, prefs notify.PreferenceStore, appLogger *slog.Logger) notify.Notifier {
+	client := httpclient.New(10 * time.Second)
+	providers := map[notify.Channel]notify.Provider{}
+	if cfg.NotifySMTPAddr != "" {
+		var auth smtp.Auth
+		if cfg.NotifySMTPUsername != "" {
+			host, _, _ := net.SplitHostPort(cfg.NotifySMTPAddr)
+			auth = smtp.PlainAuth("", cfg.NotifySMTPUsername, cfg.NotifySMTPPassword, host)
+		}
+		providers[notify.ChannelEmail] = notify.SMTPProvider{Addr: cfg.NotifySMTPAddr, From: cfg.NotifySMTPFrom, Auth: auth}
+	}
+	if cfg.NotifySMSWebhookURL != "" {
+		providers[notify.ChannelSMS] = notify.WebhookProvider{URL: cfg.NotifySMSWebhookURL, Client: client}
+	}
+	if cfg.NotifyPushWebhookURL != "" {
+		providers[notify.ChannelPush] = notify.WebhookProvider{URL: cfg.NotifyPushWebhookURL, Client: client}
+	}
+	if cfg.NotifySlackWebhookURL != "" {
+		providers[notify.ChannelSlack] = notify.WebhookProvider{URL: cfg.NotifySlackWebhookURL, Client: client, Slack: true}
+	}
+	if cfg.Env != "production" && cfg.Env != "prod" {
+		for _, c := range notify.Channels {
+			if providers[c] == nil {
+				providers[c] = notify.LogProvider{}
+			}
+		}
+	}
+	return notify.NewService(providers, newTemplates(appLogger), prefs, jobs.Default, notify.Options{})
 }
 
-// setupSwagger configures Swagger documentation endpoints
-func setupSwagger(r chi.Router, routesHandler *routes.Routes) {
-	// Configure Swagger info
+// newTemplates returns the notification templates, loaded again from
+// ASSETS_DIR whenever it changes. Templates that fail to parse are logged and
+// the previous ones kept.
+func newTemplates(appLogger *slog.Logger) *notify.Templates {
+	templates := notify.NewTemplates()
+	if assets.Default.Dir() == "" {
+		return templates
+	}
+	load := func() {
+		dir, err := fs.Sub(assets.Default, notify.TemplateDir)
+		if err == nil {
+			err = templates.Load(dir)
+		}
+		if err != nil {
+			appLogger.Error("notification templates not loaded", slog.String("error", err.Error()))
+		}
+	}
+	load()
+	assets.Default.OnChange(load)
+	return templates
+}
+
+// seedTasks creates the tasks listed in the seed/tasks.json asset.
+func seedTasks(tasks services.TaskService, appLogger *slog.Logger) {
+	var seed []struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		DueAt       *time.Time `json:"due_at"`
+	}
+	data, err := fs.ReadFile(assets.Default, "seed/tasks.json")
+	if err == nil {
+		err = json.Unmarshal(data, &seed)
+	}
+	if err != nil {
+		appLogger.Error("seed data not loaded", slog.String("error", err.Error()))
+		return
+	}
+	for _, t := range seed {
+		if _, err := tasks.CreateTask(context.Background(), services.NewTask{Title: t.Title, Description: t.Description, DueAt: t.DueAt}); err != nil {
+			appLogger.Error("seed task not created", slog.String("title", t.Title), slog.String("error", err.Error()))
+		}
+	}
+	appLogger.Info("seed data loaded", slog.Int("tasks", len(seed)))
+}
+
+// setupSwagger configures Swagger documentation endpoints, unless
+// DOCS_EXPOSURE disables them.
+func setupSwagger(r chi.Router, routesHandler *routes.Routes, cfg *config.Config) {
+	if cfg.DocsExposure == routes.DocsDisabled {
+		return
+	}
+
+	// Configure Swagger info; without DOCS_PUBLIC_URL clients use the host
+	// and scheme they loaded the document from
 	docs.SwaggerInfo.Title = "Init Codex API"
 	docs.SwaggerInfo.Version = "1.0"
+	docs.SwaggerInfo.Host = ""
+	docs.SwaggerInfo.Schemes = []string{}
 	docs.SwaggerInfo.BasePath = "/"
+	if u, err := url.Parse(cfg.DocsPublicURL); err == nil && u.Host != "" { // validated by config.Load
+		docs.SwaggerInfo.Host = u.Host
+		docs.SwaggerInfo.Schemes = []string{u.Scheme}
+		if p := strings.TrimSuffix(u.Path, "/"); p != "" {
+			docs.SwaggerInfo.BasePath = p
+		}
+	}
 
 	// Create Swagger handler
 	swaggerHandler := httpSwagger.Handler(
@@ -140,6 +863,14 @@ func setupSwagger(r chi.Router, routesHandler *routes.Routes) {
 		httpSwagger.DomID("swagger-ui"),
 	)
 
+	// The document itself is served with the body limits of the route groups
+	docHandler, err := swaggerDocHandler(cfg.BodyLimitBytes, routemeta.Default)
+	if err != nil {
+		panic(err) // the generated document is always valid JSON
+	}
+
 	// Setup Swagger routes
-	routesHandler.SetupSwaggerRoutes(r, swaggerHandler)
+	routesHandler.Mount(r, routes.GroupDocs, func(r routes.Router) {
+		routesHandler.SetupSwaggerRoutes(r, swaggerHandler, docHandler)
+	})
 }
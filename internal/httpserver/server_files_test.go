@@ -0,0 +1,360 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+)
+
+func filesTestRouter() http.Handler {
+	cfg := &config.Config{
+		Env:                "test",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1024,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET", "POST", "PATCH", "HEAD", "DELETE"},
+		CORSAllowedHeaders: []string{"Content-Type"},
+		RateLimitEnabled:   false,
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+		UploadMaxBytes:     1 << 20,
+		UploadExpiry:       time.Hour,
+		SignedURLMaxTTL:    time.Hour,
+
+		ImageMaxConcurrency:  1,
+		ImageCacheBytes:      1 << 20,
+		ImageMaxSourcePixels: 1 << 20,
+		ImageMaxDimension:    100,
+	}
+	return NewRouter(cfg, testLogger())
+}
+
+func tusRequest(method, target string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	return req
+}
+
+func TestFiles_ResumableUpload(t *testing.T) {
+	h := filesTestRouter()
+
+	// Create an 11 byte upload with a filename
+	rr := httptest.NewRecorder()
+	req := tusRequest(http.MethodPost, "/api/v1/files", "")
+	req.Header.Set("Upload-Length", "11")
+	req.Header.Set("Upload-Metadata", "filename aGVsbG8udHh0,filetype dGV4dC9wbGFpbg==")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location := rr.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/v1/files/file_") || rr.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("unexpected create headers: %v", rr.Header())
+	}
+
+	// First chunk
+	rr = httptest.NewRecorder()
+	req = tusRequest(http.MethodPatch, location, "hello ")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("expected 204 with offset 6, got %d offset %q", rr.Code, rr.Header().Get("Upload-Offset"))
+	}
+
+	// A client resuming after a dropped connection asks for the offset
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, tusRequest(http.MethodHead, location, ""))
+	if rr.Code != http.StatusOK || rr.Header().Get("Upload-Offset") != "6" || rr.Header().Get("Upload-Length") != "11" {
+		t.Fatalf("unexpected HEAD response %d %v", rr.Code, rr.Header())
+	}
+
+	// Chunk at a stale offset is refused with the current offset
+	rr = httptest.NewRecorder()
+	req = tusRequest(http.MethodPatch, location, "hello ")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for offset mismatch, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = tusRequest(http.MethodPatch, location, "world")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "6")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Upload-Offset") != "11" {
+		t.Fatalf("expected 204 with offset 11, got %d offset %q", rr.Code, rr.Header().Get("Upload-Offset"))
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, location, nil))
+	if rr.Code != http.StatusOK || !bytes.Contains(rr.Body.Bytes(), []byte(`"name":"hello.txt"`)) || !bytes.Contains(rr.Body.Bytes(), []byte(`"complete":true`)) {
+		t.Fatalf("unexpected file metadata %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestFiles_RequiresTusHeaders(t *testing.T) {
+	h := filesTestRouter()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files", nil)
+	req.Header.Set("Upload-Length", "10")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPreconditionFailed || rr.Header().Get("Tus-Version") != "1.0.0" {
+		t.Fatalf("expected 412 with Tus-Version, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/api/v1/files", nil))
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Tus-Max-Size") != "1048576" {
+		t.Fatalf("unexpected OPTIONS response %d %v", rr.Code, rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	req = tusRequest(http.MethodPost, "/api/v1/files", "")
+	req.Header.Set("Upload-Length", "2097152")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 above the upload limit, got %d", rr.Code)
+	}
+}
+
+// uploadTestFile uploads body in a single tus chunk and returns the file location.
+func uploadTestFile(t *testing.T, h http.Handler, body string) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := tusRequest(http.MethodPost, "/api/v1/files", "")
+	req.Header.Set("Upload-Length", strconv.Itoa(len(body)))
+	req.Header.Set("Upload-Metadata", "filename ZGF0YS50eHQ=")
+	h.ServeHTTP(rr, req)
+	location := rr.Header().Get("Location")
+
+	rr = httptest.NewRecorder()
+	req = tusRequest(http.MethodPatch, location, body)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("upload failed: %d %s", rr.Code, rr.Body.String())
+	}
+	return location
+}
+
+func TestFiles_DownloadRanges(t *testing.T) {
+	h := filesTestRouter()
+	content := uploadTestFile(t, h, "0123456789abcdef") + "/content"
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, content, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "0123456789abcdef" {
+		t.Fatalf("unexpected full download %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Accept-Ranges") != "bytes" || rr.Header().Get("Content-Length") != "16" || rr.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("unexpected download headers: %v", rr.Header())
+	}
+	etag := rr.Header().Get("ETag")
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, content, nil)
+	req.Header.Set("Range", "bytes=10-")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "abcdef" || rr.Header().Get("Content-Range") != "bytes 10-15/16" {
+		t.Fatalf("unexpected ranged download %d %q %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+
+	// If-Range with a matching validator resumes; a stale one restarts from scratch
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, content, nil)
+	req.Header.Set("Range", "bytes=0-3")
+	req.Header.Set("If-Range", etag)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "0123" {
+		t.Fatalf("expected 206 for matching If-Range, got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	req.Header.Set("If-Range", `"stale"`)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.Len() != 16 {
+		t.Fatalf("expected full 200 for stale If-Range, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, content, nil)
+	req.Header.Set("Range", "bytes=100-200")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", rr.Code)
+	}
+}
+
+func TestFiles_SignedURL(t *testing.T) {
+	h := filesTestRouter()
+	location := uploadTestFile(t, h, "signed contents")
+
+	// Unsigned access to the public path is refused
+	fileID := strings.TrimPrefix(location, "/api/v1/files/")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/"+fileID, nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without signature, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, location+"/signed-url", strings.NewReader(`{"expires_in":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var minted struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &minted); err != nil || !strings.HasPrefix(minted.URL, "/files/"+fileID+"?") {
+		t.Fatalf("unexpected signed url response %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, minted.URL, nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "signed contents" {
+		t.Fatalf("expected signed download, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, minted.URL+"0", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for tampered signature, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, location+"/signed-url", strings.NewReader(`{"expires_in":7200}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 above SIGNED_URL_MAX_TTL, got %d", rr.Code)
+	}
+}
+
+func TestImages_ResizeAndCache(t *testing.T) {
+	h := filesTestRouter()
+	var src bytes.Buffer
+	if err := png.Encode(&src, image.NewGray(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	fileID := strings.TrimPrefix(uploadTestFile(t, h, src.String()), "/api/v1/files/")
+
+	for _, want := range []string{"MISS", "HIT"} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/images/"+fileID+"?w=10&fmt=jpeg", nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/jpeg" || rr.Header().Get("X-Cache") != want {
+			t.Fatalf("expected 200 image/jpeg cache %s, got %d %v", want, rr.Code, rr.Header())
+		}
+		cfg, _, err := image.DecodeConfig(rr.Body)
+		if err != nil || cfg.Width != 10 || cfg.Height != 5 {
+			t.Fatalf("expected 10x5 image, got %+v (%v)", cfg, err)
+		}
+	}
+
+	cases := map[string]int{
+		"?w=10&fmt=webp": http.StatusUnsupportedMediaType,
+		"?w=1000":        http.StatusBadRequest,
+		"?w=abc":         http.StatusBadRequest,
+		"":               http.StatusBadRequest,
+	}
+	for query, status := range cases {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/images/"+fileID+query, nil))
+		if rr.Code != status {
+			t.Fatalf("%q: expected %d, got %d", query, status, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/images/file_missing?w=10", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown image, got %d", rr.Code)
+	}
+}
+
+func TestReports_GenerateAndDownload(t *testing.T) {
+	h := filesTestRouter()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(`{"type":"users","format":"pdf"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location := rr.Header().Get("Location")
+	if link := rr.Header().Get("Link"); !strings.HasPrefix(link, "</api/v1/operations/op_") || !strings.HasSuffix(link, `rel="monitor"`) {
+		t.Fatalf("expected the report's operation linked, got %q", link)
+	}
+
+	var report struct {
+		Status      string `json:"status"`
+		DownloadURL string `json:"download_url"`
+	}
+	for deadline := time.Now().Add(2 * time.Second); report.Status != "completed"; {
+		if time.Now().After(deadline) {
+			t.Fatalf("report did not complete, last status %q", report.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, location, nil))
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, report.DownloadURL, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(rr.Body.String(), "%PDF-") {
+		t.Fatalf("unexpected report download %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestFiles_ChunksUseTheUploadBodyLimit(t *testing.T) {
+	h := filesTestRouter() // BODY_LIMIT_BYTES is 1024
+
+	rr := httptest.NewRecorder()
+	req := tusRequest(http.MethodPost, "/api/v1/files", "")
+	req.Header.Set("Upload-Length", "4096")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	location := rr.Header().Get("Location")
+
+	rr = httptest.NewRecorder()
+	req = tusRequest(http.MethodPatch, location, strings.Repeat("a", 4096))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected a 4 KiB chunk within the uploads group's limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// JSON endpoints keep the server-wide limit
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{"name":"`+strings.Repeat("a", 2048)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "1024 byte limit") {
+		t.Fatalf("expected 413 naming the 1024 byte limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
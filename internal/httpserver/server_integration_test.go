@@ -2,9 +2,17 @@ package httpserver
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,11 +23,28 @@ import (
 // minimal logger for tests
 func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(testDiscard{}, nil)) }
 
+// testJWTSecret signs the bearer tokens of test operators; routers that serve
+// admin routes to tests set it as JWT_HS256_SECRETS.
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// asAdmin returns r with a bearer token of an operator with the admin scope.
+func asAdmin(r *http.Request) *http.Request {
+	segment := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(map[string]string{"sub": "usr_operator", "scope": "admin"})
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(signed))
+	r.Header.Set("Authorization", "Bearer "+signed+"."+base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
 type testDiscard struct{}
 
 func (testDiscard) Write(p []byte) (int, error) { return len(p), nil }
 
-func TestBodyLimit_EchoTooLarge(t *testing.T) {
+func TestBodyLimit_TaskTooLarge(t *testing.T) {
 	cfg := &config.Config{
 		Env:                "test",
 		Port:               0,
@@ -37,11 +62,57 @@ func TestBodyLimit_EchoTooLarge(t *testing.T) {
 
 	// Body > 10 bytes triggers MaxBytesReader error during JSON decode
 	rr := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", bytes.NewBufferString(`{"message":"0123456789ABC"}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(`{"title":"0123456789ABC"}`))
 	req.Header.Set("Content-Type", "application/json")
 	h.ServeHTTP(rr, req)
-	if rr.Code != http.StatusBadRequest {
-		t.Fatalf("expected 400 for too large body, got %d", rr.Code)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for too large body, got %d", rr.Code)
+	}
+
+	// Streamed body without Content-Length is cut off during decode
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/tasks", io.MultiReader(bytes.NewBufferString(`{"title":"0123456789ABC"}`)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for too large streamed body, got %d", rr.Code)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("payload_too_large")) {
+		t.Fatalf("expected structured error, got %s", rr.Body.String())
+	}
+}
+
+func TestRequireJSON_RejectsOtherContentTypes(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "test",
+		Port:               0,
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET", "POST"},
+		CORSAllowedHeaders: []string{"Content-Type"},
+		RateLimitEnabled:   false,
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+	}
+	h := NewRouter(cfg, testLogger())
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(`title=hi`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(`{"title":"hi"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for JSON with charset, got %d", rr.Code)
 	}
 }
 
@@ -108,13 +179,15 @@ func TestMetricsEndpointAvailable(t *testing.T) {
 		RateLimit:          1,
 		RateLimitPeriod:    "1m",
 		CompressionLevel:   5,
+		JWTHS256Secrets:    []string{testJWTSecret},
 	}
 
 	h := NewRouter(cfg, testLogger())
 	server := httptest.NewServer(h)
 	defer server.Close()
 
-	resp, err := http.Get(server.URL + "/metrics")
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/metrics", nil)
+	resp, err := http.DefaultClient.Do(asAdmin(req))
 	if err != nil {
 		t.Fatalf("GET /metrics failed: %v", err)
 	}
@@ -132,3 +205,588 @@ func TestMetricsEndpointAvailable(t *testing.T) {
 		t.Fatalf("expected metrics output to contain api_requests_total, got %s", string(body))
 	}
 }
+
+func TestBotRateLimit_AppliesOnlyToUnidentifiedClients(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "test",
+		Port:               0,
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimitEnabled:   true,
+		RateLimit:          100,
+		BotRateLimit:       1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+	}
+	h := NewRouter(cfg, testLogger())
+
+	do := func(ua string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+		req.Header.Set("User-Agent", ua)
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := do("mystery-agent"); code != http.StatusOK {
+		t.Fatalf("expected first unidentified request to pass, got %d", code)
+	}
+	if code := do("mystery-agent"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected second unidentified request to be limited, got %d", code)
+	}
+	if code := do("curl/8.4.0"); code != http.StatusOK {
+		t.Fatalf("expected identified client to use the regular limit, got %d", code)
+	}
+}
+
+func TestUsage_PerAPIKey(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "test",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1024,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+		UsageWindow:        time.Hour,
+		UsageMaxKeys:       10,
+		JWTHS256Secrets:    []string{testJWTSecret},
+	}
+	h := NewRouter(cfg, testLogger())
+
+	get := func(path, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		} else {
+			req = asAdmin(req)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	get("/api/v1/ping", "key-one")
+	get("/api/v1/missing", "key-one")
+	get("/api/v1/ping", "key-two")
+
+	rr := get("/api/v1/usage", "key-one")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"requests":2`)) || !bytes.Contains(rr.Body.Bytes(), []byte(`"errors":1`)) {
+		t.Fatalf("unexpected usage for key-one: %s", rr.Body.String())
+	}
+
+	rr = get("/admin/usage", "")
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"total":{"window":"1h0m0s","requests":4`)) {
+		t.Fatalf("unexpected admin usage: %s", rr.Body.String())
+	}
+}
+
+func TestQuota_EnforcedPerUser(t *testing.T) {
+	cfg := &config.Config{
+		Env:                 "test",
+		RequestTimeout:      time.Second,
+		BodyLimitBytes:      1024,
+		CORSAllowedOrigins:  []string{"*"},
+		CORSAllowedMethods:  []string{"GET", "POST", "PUT"},
+		CORSAllowedHeaders:  []string{"*"},
+		RateLimit:           1,
+		RateLimitPeriod:     "1m",
+		CompressionLevel:    5,
+		UploadMaxBytes:      1 << 20,
+		UploadExpiry:        time.Hour,
+		QuotaRequestsPerDay: 3,
+		QuotaStorageBytes:   100,
+		JWTHS256Secrets:     []string{testJWTSecret},
+	}
+	h := NewRouter(cfg, testLogger())
+
+	do := func(method, path, key string, headers map[string]string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Quotas are charged to the owner of a verified key
+	rr := httptest.NewRecorder()
+	issue := asAdmin(httptest.NewRequest(http.MethodPost, "/admin/apikeys", bytes.NewBufferString(`{"owner_id":"usr_quota","name":"quota","scopes":[]}`)))
+	issue.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, issue)
+	var issued struct{ Key string }
+	if rr.Code != http.StatusCreated || json.Unmarshal(rr.Body.Bytes(), &issued) != nil {
+		t.Fatalf("expected an issued key, got %d %s", rr.Code, rr.Body.String())
+	}
+	key := issued.Key
+
+	tus := map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "101"}
+	rr = do(http.MethodPost, "/api/v1/files", key, tus)
+	if rr.Code != http.StatusForbidden || !bytes.Contains(rr.Body.Bytes(), []byte(`"quota":"storage_bytes"`)) {
+		t.Fatalf("expected 403 storage quota error, got %d: %s", rr.Code, rr.Body.String())
+	}
+	tus["Upload-Length"] = "100"
+	if rr := do(http.MethodPost, "/api/v1/files", key, tus); rr.Code != http.StatusCreated {
+		t.Fatalf("expected upload within quota to be created, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/api/v1/ping", key, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected third request to pass, got %d", rr.Code)
+	}
+	rr = do(http.MethodGet, "/api/v1/ping", key, nil)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", rr.Code)
+	}
+	for _, other := range []string{"", "gak_000000000000_forged"} {
+		if rr := do(http.MethodGet, "/api/v1/ping", other, nil); rr.Code != http.StatusOK {
+			t.Fatalf("requests without verified credentials are not subject to quotas, got %d for %q", rr.Code, other)
+		}
+	}
+
+	// Raising the limit through the admin API lets the user continue
+	rr = httptest.NewRecorder()
+	put := asAdmin(httptest.NewRequest(http.MethodPut, "/admin/quotas/usr_quota", bytes.NewBufferString(`{"requests_per_day":10}`)))
+	put.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, put)
+	if rr.Code != http.StatusOK || !bytes.Contains(rr.Body.Bytes(), []byte(`"requests_today":3`)) {
+		t.Fatalf("unexpected admin response %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/api/v1/ping", key, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected request under the raised limit to pass, got %d", rr.Code)
+	}
+}
+
+func TestMetering_RollsUpRequestsAndStoredBytes(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "test",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1024,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET", "POST", "PATCH"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+		UploadMaxBytes:     1 << 20,
+		UploadExpiry:       time.Hour,
+		MeteringRetention:  time.Hour,
+		JWTHS256Secrets:    []string{testJWTSecret},
+	}
+	h := NewRouter(cfg, testLogger())
+
+	rr := httptest.NewRecorder()
+	issue := asAdmin(httptest.NewRequest(http.MethodPost, "/admin/apikeys", bytes.NewBufferString(`{"owner_id":"usr_metered","name":"metered","scopes":[]}`)))
+	issue.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, issue)
+	var issued struct{ Key string }
+	if rr.Code != http.StatusCreated || json.Unmarshal(rr.Body.Bytes(), &issued) != nil {
+		t.Fatalf("expected an issued key, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		req.Header.Set("X-API-Key", issued.Key)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	do(httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil))
+	create := httptest.NewRequest(http.MethodPost, "/api/v1/files", nil)
+	create.Header.Set("Tus-Resumable", "1.0.0")
+	create.Header.Set("Upload-Length", "5")
+	location := do(create).Header().Get("Location")
+	patch := httptest.NewRequest(http.MethodPatch, location, bytes.NewBufferString("hello"))
+	patch.Header.Set("Tus-Resumable", "1.0.0")
+	patch.Header.Set("Upload-Offset", "0")
+	patch.Header.Set("Content-Type", "application/offset+octet-stream")
+	if rr := do(patch); rr.Code != http.StatusNoContent {
+		t.Fatalf("upload failed: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, asAdmin(httptest.NewRequest(http.MethodGet, "/admin/metering", nil)))
+	var export struct {
+		Rollups []struct {
+			Key      string `json:"key"`
+			Type     string `json:"type"`
+			Events   int64  `json:"events"`
+			Quantity int64  `json:"quantity"`
+		} `json:"rollups"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	totals := map[string]int64{}
+	for _, r := range export.Rollups {
+		if r.Key == "usr_metered" {
+			totals[r.Type] += r.Quantity
+		}
+	}
+	if totals["request.served"] != 3 || totals["storage.bytes"] != 5 {
+		t.Fatalf("unexpected metering totals %v from %s", totals, rr.Body.String())
+	}
+}
+
+func TestFeatureFlags_EvaluatedWithRequestContext(t *testing.T) {
+	flagsFile := filepath.Join(t.TempDir(), "flags.json")
+	flags := `{"flags": {"beta": {"variants": {"on": true, "off": false}, "defaultVariant": "off",
+		"rules": [{"match": {"country": ["FI"]}, "variant": "on"}]}}}`
+	if err := os.WriteFile(flagsFile, []byte(flags), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Env:                       "test",
+		RequestTimeout:            time.Second,
+		BodyLimitBytes:            1024,
+		CORSAllowedOrigins:        []string{"*"},
+		CORSAllowedMethods:        []string{"GET"},
+		CORSAllowedHeaders:        []string{"*"},
+		RateLimit:                 1,
+		RateLimitPeriod:           "1m",
+		CompressionLevel:          5,
+		FeatureFlagsProvider:      "file",
+		FeatureFlagsConfig:        flagsFile,
+		FeatureFlagsCountryHeader: "CF-IPCountry",
+	}
+	h := NewRouter(cfg, testLogger())
+
+	get := func(path, country string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if country != "" {
+			req.Header.Set("CF-IPCountry", country)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get("/api/v1/flags/beta", "fi"); !bytes.Contains(rr.Body.Bytes(), []byte(`"value":true`)) {
+		t.Fatalf("expected beta on for FI, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get("/api/v1/flags/beta", "US"); !bytes.Contains(rr.Body.Bytes(), []byte(`"value":false`)) {
+		t.Fatalf("expected beta off for US, got %s", rr.Body.String())
+	}
+	if rr := get("/api/v1/flags/missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown flag, got %d", rr.Code)
+	}
+}
+
+func TestSnapshot_ExportAndImportThroughAdmin(t *testing.T) {
+	h := notFoundTestRouter("development")
+	anonymous := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := asAdmin(httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := anonymous(http.MethodGet, "/admin/snapshot", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 exporting anonymously, got %d", rr.Code)
+	}
+	if rr := anonymous(http.MethodPut, "/admin/snapshot", `{"version":1,"stores":{"tasks":[]}}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 importing anonymously, got %d", rr.Code)
+	}
+	if rr := call(http.MethodPost, "/admin/apikeys", `{"owner_id":"svc_backup","name":"backup","scopes":[]}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected an issued key, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	created := call(http.MethodPost, "/api/v1/tasks", `{"title":"Keep me"}`)
+	var task struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(created.Body.Bytes(), &task); created.Code != http.StatusCreated || err != nil {
+		t.Fatalf("expected a created task, got %d %s", created.Code, created.Body.String())
+	}
+
+	export := call(http.MethodGet, "/admin/snapshot", "")
+	if export.Code != http.StatusOK || !strings.Contains(export.Body.String(), "Keep me") {
+		t.Fatalf("expected the task in the export, got %d %s", export.Code, export.Body.String())
+	}
+	if strings.Contains(export.Body.String(), "svc_backup") || strings.Contains(export.Body.String(), `"hash"`) {
+		t.Fatalf("expected the API keys left out of the export, got %s", export.Body.String())
+	}
+	if rr := call(http.MethodPut, "/admin/snapshot", `{"version":1,"stores":{"apikeys":[]}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected importing API keys through the admin API to be refused, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := call(http.MethodDelete, "/api/v1/tasks/"+task.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected the task deleted, got %d", rr.Code)
+	}
+	if rr := call(http.MethodPut, "/admin/snapshot", export.Body.String()); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"tasks"`) {
+		t.Fatalf("expected the snapshot imported, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(http.MethodGet, "/api/v1/tasks/"+task.ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected the task restored, got %d", rr.Code)
+	}
+	if rr := call(http.MethodPut, "/admin/snapshot", `{"version":1,"stores":{"tasks":[{"title":"no id"}]}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid snapshot rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestOperations_AsyncImportAndErasure(t *testing.T) {
+	h := notFoundTestRouter("development")
+	call := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	type operation struct {
+		ID       string             `json:"id"`
+		Status   string             `json:"status"`
+		Progress struct{ Done int } `json:"progress"`
+		Result   json.RawMessage    `json:"result"`
+	}
+	wait := func(rr *httptest.ResponseRecorder) operation {
+		t.Helper()
+		location := rr.Header().Get("Location")
+		if rr.Code != http.StatusAccepted || !strings.HasPrefix(location, "/api/v1/operations/op_") {
+			t.Fatalf("expected 202 with an operation Location, got %d %q %s", rr.Code, location, rr.Body.String())
+		}
+		var op operation
+		for deadline := time.Now().Add(2 * time.Second); op.Status != "completed"; time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) || op.Status == "failed" {
+				t.Fatalf("operation did not complete, last status %q", op.Status)
+			}
+			if err := json.Unmarshal(call(http.MethodGet, location, "").Body.Bytes(), &op); err != nil {
+				t.Fatalf("decode operation: %v", err)
+			}
+		}
+		return op
+	}
+
+	op := wait(call(http.MethodPost, "/api/v1/users/import",
+		`[{"email":"async1@example.com","name":"A"},{"email":"async2@example.com","name":"B"}]`, "Prefer", "respond-async"))
+	var summary struct{ Accepted int }
+	if err := json.Unmarshal(op.Result, &summary); err != nil || summary.Accepted != 2 || op.Progress.Done != 2 {
+		t.Fatalf("expected both users imported, got %s progress %d", op.Result, op.Progress.Done)
+	}
+
+	var users struct {
+		Users []struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"users"`
+	}
+	_ = json.Unmarshal(call(http.MethodGet, "/api/v1/users", "").Body.Bytes(), &users)
+	userID := ""
+	for _, u := range users.Users {
+		if u.Email == "async1@example.com" {
+			userID = u.ID
+		}
+	}
+	if userID == "" {
+		t.Fatalf("expected the imported user listed, got %+v", users)
+	}
+
+	op = wait(call(http.MethodPost, "/api/v1/users/"+userID+"/erasure", ""))
+	if !strings.Contains(string(op.Result), `"notification_preferences","user"`) {
+		t.Fatalf("expected every step erased, got %s", op.Result)
+	}
+	if rr := call(http.MethodGet, "/api/v1/users/"+userID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the user erased, got %d", rr.Code)
+	}
+	if rr := call(http.MethodPost, "/api/v1/users/"+userID+"/erasure", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected erasing an unknown user to be refused, got %d", rr.Code)
+	}
+	if rr := call(http.MethodGet, "/api/v1/operations/op_missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown operation to be 404, got %d", rr.Code)
+	}
+}
+
+func TestAPIKeys_IssueAuthenticateAndRevoke(t *testing.T) {
+	h := notFoundTestRouter("development")
+	call := func(method, path, body, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		} else if strings.HasPrefix(path, "/admin/") {
+			req = asAdmin(req)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	issue := func(rr *httptest.ResponseRecorder) (id, key string) {
+		t.Helper()
+		var issued struct{ ID, Key string }
+		if rr.Code != http.StatusCreated || json.Unmarshal(rr.Body.Bytes(), &issued) != nil || !strings.HasPrefix(issued.Key, "gak_") {
+			t.Fatalf("expected an issued key, got %d %s", rr.Code, rr.Body.String())
+		}
+		return issued.ID, issued.Key
+	}
+
+	if rr := call(http.MethodGet, "/api/v1/apikeys", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", rr.Code)
+	}
+	if rr := call(http.MethodGet, "/api/v1/apikeys", "", "gak_000000000000_forged"); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "invalid_api_key") {
+		t.Fatalf("expected 401 invalid_api_key for an unknown key, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Only an operator issues keys for other principals
+	anonymous := httptest.NewRecorder()
+	h.ServeHTTP(anonymous, httptest.NewRequest(http.MethodPost, "/admin/apikeys", bytes.NewBufferString(`{"owner_id":"svc_billing","name":"billing","scopes":["admin"]}`)))
+	if anonymous.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 issuing a key anonymously, got %d %s", anonymous.Code, anonymous.Body.String())
+	}
+	manageID, manage := issue(call(http.MethodPost, "/admin/apikeys", `{"owner_id":"svc_billing","name":"billing","scopes":["apikeys"]}`, ""))
+	if rr := call(http.MethodPost, "/admin/apikeys", `{"owner_id":"usr_001","name":"takeover","scopes":["admin"]}`, manage); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 issuing a key with a key lacking the admin scope, got %d %s", rr.Code, rr.Body.String())
+	}
+	narrowID, narrow := issue(call(http.MethodPost, "/api/v1/apikeys", `{"name":"read only","scopes":[]}`, manage))
+
+	rr := call(http.MethodGet, "/api/v1/apikeys", "", manage)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), manageID) || !strings.Contains(rr.Body.String(), narrowID) || strings.Contains(rr.Body.String(), `"hash"`) {
+		t.Fatalf("expected both keys of the owner without hashes, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(http.MethodGet, "/api/v1/apikeys", "", narrow); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "insufficient_scope") {
+		t.Fatalf("expected 403 for a key without the apikeys scope, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(http.MethodPost, "/api/v1/apikeys", `{"name":"wider","scopes":["admin"]}`, manage); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 granting a scope the key lacks, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := call(http.MethodDelete, "/api/v1/apikeys/"+manageID, "", manage); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "revoked_at") {
+		t.Fatalf("expected the key revoked, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(http.MethodGet, "/api/v1/apikeys", "", manage); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a revoked key, got %d", rr.Code)
+	}
+}
+
+func TestBootReport_ServedWhenEnabled(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "development",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimitEnabled:   true,
+		RateLimit:          100,
+		RateLimitPeriod:    "1m",
+		RateLimitBackend:   "memory",
+		CompressionLevel:   5,
+		BootReportEndpoint: true,
+		JWTHS256Secrets:    []string{testJWTSecret},
+	}
+	h := NewRouter(cfg, testLogger())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, asAdmin(httptest.NewRequest(http.MethodGet, "/admin/boot", nil)))
+	var report struct {
+		Middleware []string `json:"middleware"`
+		Features   []string `json:"features"`
+		Routes     int      `json:"routes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected the boot report, got %d %s", rr.Code, rr.Body.String())
+	}
+	if len(report.Middleware) == 0 || report.Middleware[0] != mwHeaders || report.Routes == 0 || !slices.Contains(report.Features, "rate_limit_memory") {
+		t.Fatalf("unexpected boot report %+v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	notFoundTestRouter("development").ServeHTTP(rr, asAdmin(httptest.NewRequest(http.MethodGet, "/admin/boot", nil)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected /admin/boot off unless BOOT_REPORT_ENDPOINT, got %d", rr.Code)
+	}
+}
+
+func TestAdminConfig_OnlyForAdmins(t *testing.T) {
+	h := notFoundTestRouter("production")
+	call := func(method, path, body, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		} else {
+			req = asAdmin(req)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := call(http.MethodPost, "/admin/apikeys", `{"owner_id":"svc_reports","name":"reports","scopes":["apikeys"]}`, "")
+	var issued struct{ Key string }
+	if rr.Code != http.StatusCreated || json.Unmarshal(rr.Body.Bytes(), &issued) != nil {
+		t.Fatalf("expected an issued key, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := call(http.MethodGet, "/admin/config", "", issued.Key); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "insufficient_scope") {
+		t.Fatalf("expected 403 for a key without the admin scope, got %d %s", rr.Code, rr.Body.String())
+	}
+	rr = call(http.MethodGet, "/admin/config", "", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "JWT_HS256_SECRETS") || strings.Contains(rr.Body.String(), testJWTSecret) {
+		t.Fatalf("expected the configuration with secrets redacted, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestOperatorRoutes_RefuseAnonymousCallersWithoutAnAdminListener(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "production",
+		RequestTimeout:     time.Second,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+	}
+	ls := Listeners(cfg)
+	if len(ls) != 1 {
+		t.Fatalf("expected only the public listener without ADMIN_PORT, got %d", len(ls))
+	}
+	h := NewServer(ls[0], NewRouter(cfg, testLogger())).Handler
+	for _, path := range []string{"/admin/config", "/admin/routes", "/metrics"} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for an anonymous GET %s, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestAdminRuntime_OnlyForAdmins(t *testing.T) {
+	h := notFoundTestRouter("production")
+	call := func(method, path string, admin bool) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if admin {
+			req = asAdmin(req)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/admin/runtime/memstats"},
+		{http.MethodGet, "/admin/runtime/goroutines"},
+		{http.MethodPost, "/admin/runtime/gc"},
+	} {
+		if rr := call(route.method, route.path, false); rr.Code != http.StatusUnauthorized || strings.Contains(rr.Body.String(), "goroutine") {
+			t.Errorf("expected 401 for an anonymous %s %s, got %d", route.method, route.path, rr.Code)
+		}
+		if rr := call(route.method, route.path, true); rr.Code != http.StatusOK {
+			t.Errorf("expected an admin to get %s %s, got %d", route.method, route.path, rr.Code)
+		}
+	}
+}
@@ -70,9 +70,9 @@ func TestLogger_APIRequestResponse(t *testing.T) {
 		testAPICall(t, log, server.URL, "GET", "/healthz", nil, http.StatusOK)
 	})
 
-	t.Run("POST_Echo", func(t *testing.T) {
-		payload := map[string]string{"message": "Hello, World!"}
-		testAPICall(t, log, server.URL, "POST", "/api/v1/echo", payload, http.StatusOK)
+	t.Run("POST_Task", func(t *testing.T) {
+		payload := map[string]string{"title": "Hello, World!"}
+		testAPICall(t, log, server.URL, "POST", "/api/v1/tasks", payload, http.StatusCreated)
 	})
 
 	t.Run("GET_Example", func(t *testing.T) {
@@ -85,8 +85,8 @@ func TestLogger_APIRequestResponse(t *testing.T) {
 		for i := range largeData {
 			largeData[i] = 'A'
 		}
-		payload := map[string]string{"message": string(largeData)}
-		testAPICall(t, log, server.URL, "POST", "/api/v1/echo", payload, http.StatusOK)
+		payload := map[string]string{"title": "Large", "description": string(largeData)}
+		testAPICall(t, log, server.URL, "POST", "/api/v1/tasks", payload, http.StatusCreated)
 	})
 
 	t.Run("GET_NotFound", func(t *testing.T) {
@@ -95,7 +95,7 @@ func TestLogger_APIRequestResponse(t *testing.T) {
 
 	t.Run("POST_InvalidJSON", func(t *testing.T) {
 		// Send invalid JSON
-		req, _ := http.NewRequest("POST", server.URL+"/api/v1/echo", bytes.NewBufferString("{invalid json"))
+		req, _ := http.NewRequest("POST", server.URL+"/api/v1/tasks", bytes.NewBufferString("{invalid json"))
 		req.Header.Set("Content-Type", "application/json")
 
 		startTime := time.Now()
@@ -137,12 +137,12 @@ func TestLogger_APIRequestResponse(t *testing.T) {
 				defer func() { done <- true }()
 
 				payload := map[string]interface{}{
-					"message": fmt.Sprintf("Concurrent request %d", requestNum),
+					"title": fmt.Sprintf("Concurrent request %d", requestNum),
 				}
 
 				// Create a sub-test to avoid race conditions with t.Helper()
 				body, _ := json.Marshal(payload)
-				req, err := http.NewRequest("POST", server.URL+"/api/v1/echo", bytes.NewBuffer(body))
+				req, err := http.NewRequest("POST", server.URL+"/api/v1/tasks", bytes.NewBuffer(body))
 				if err != nil {
 					errors <- err
 					return
@@ -158,9 +158,9 @@ func TestLogger_APIRequestResponse(t *testing.T) {
 				}
 				defer resp.Body.Close()
 
-				if resp.StatusCode != http.StatusOK {
+				if resp.StatusCode != http.StatusCreated {
 					respBody, _ := io.ReadAll(resp.Body)
-					errors <- fmt.Errorf("request %d: expected status 200, got %d. Body: %s", requestNum, resp.StatusCode, string(respBody))
+					errors <- fmt.Errorf("request %d: expected status 201, got %d. Body: %s", requestNum, resp.StatusCode, string(respBody))
 				}
 			}(i)
 		}
@@ -2,12 +2,15 @@ package httpserver
 
 import (
 	"context"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
 )
 
 func TestGracefulShutdownCompletesInFlightRequests(t *testing.T) {
@@ -59,7 +62,7 @@ func TestGracefulShutdownCompletesInFlightRequests(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := Shutdown(ctx, srv, testLogger()); err != nil {
 		t.Fatalf("shutdown failed: %v", err)
 	}
 
@@ -69,7 +72,48 @@ func TestGracefulShutdownCompletesInFlightRequests(t *testing.T) {
 		t.Fatal("in-flight request did not finish before timeout")
 	}
 
+	if n := metrics.InFlight(); n != 0 {
+		t.Fatalf("expected no in-flight requests after drain, got %d", n)
+	}
+
 	if _, err := http.Get(baseURL + "/healthz"); err == nil {
 		t.Fatalf("expected server to be stopped")
 	}
 }
+
+func TestTimeoutMiddlewareWritesSingleResponse(t *testing.T) {
+	cfg := &config.Config{
+		Env:                "development",
+		Port:               0,
+		RequestTimeout:     100 * time.Millisecond,
+		BodyLimitBytes:     1048576,
+		CORSAllowedOrigins: []string{"*"},
+		CORSAllowedMethods: []string{"GET"},
+		CORSAllowedHeaders: []string{"*"},
+		RateLimitEnabled:   false,
+		RateLimit:          1,
+		RateLimitPeriod:    "1m",
+		CompressionLevel:   5,
+	}
+
+	server := httptest.NewServer(NewRouter(cfg, testLogger()))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "/test/sleep?duration=2s")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if len(body) != 0 {
+		t.Fatalf("expected empty body from timeout, got %q", body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("sleep was not aborted by timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// drainLogInterval controls how often drain progress is logged during shutdown.
+var drainLogInterval = time.Second
+
+// Shutdown drains srv gracefully. Keep-alives are disabled first so clients
+// stop reusing connections, idle connections are closed by srv.Shutdown, and the
+// number of in-flight requests is logged until it reaches zero or ctx expires.
+func Shutdown(ctx context.Context, srv *http.Server, logger *slog.Logger) error {
+	start := time.Now()
+	srv.SetKeepAlivesEnabled(false)
+	metrics.SetDraining(true)
+	defer metrics.SetDraining(false)
+
+	logger.Info("draining connections", slog.Int64("in_flight", metrics.InFlight()))
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Shutdown(ctx) }()
+
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			attrs := []any{
+				slog.Duration("drain_duration", time.Since(start)),
+				slog.Int64("in_flight", metrics.InFlight()),
+			}
+			if err != nil {
+				logger.Warn("drain incomplete", append(attrs, slog.String("error", err.Error()))...)
+				return err
+			}
+			logger.Info("drain complete", attrs...)
+			return nil
+		case <-ticker.C:
+			logger.Info("draining connections",
+				slog.Int64("in_flight", metrics.InFlight()),
+				slog.Duration("elapsed", time.Since(start)),
+			)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/deadline"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+)
+
+// Timeout bounds each request's context by d. With budget set, a caller's
+// shorter X-Request-Timeout budget is used instead, so work stops when the
+// caller gives up; the outbound client forwards what is left of it.
+// When the deadline passes before the handler has written anything, the
+// client gets a bare 504. The respwriter.Writer passed down ignores repeated
+// WriteHeader calls, so the status is written exactly once however many
+// layers try to answer; the response helpers already skip writing once the
+// context is done. Server-sent event streams are not bounded: they last
+// until the client leaves or the server shuts down.
+func Timeout(d time.Duration, budget bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if response.AcceptsEventStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timeout := d
+			if budget {
+				timeout = deadline.Budget(r.Header.Get(deadline.Header), d)
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			ww := respwriter.Wrap(w)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && !ww.Written() {
+				ww.WriteHeader(http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
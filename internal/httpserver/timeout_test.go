@@ -0,0 +1,133 @@
+package httpserver
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// headerCounter counts WriteHeader calls reaching the underlying writer.
+type headerCounter struct {
+	*httptest.ResponseRecorder
+	calls int
+}
+
+func (h *headerCounter) WriteHeader(code int) {
+	h.calls++
+	h.ResponseRecorder.WriteHeader(code)
+}
+
+func TestTimeout_SlowHandlerGetsSingle504(t *testing.T) {
+	h := Timeout(20*time.Millisecond, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		response.JSON(w, r, http.StatusOK, map[string]string{"late": "true"})
+	}))
+
+	rec := &headerCounter{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rec.Code != http.StatusGatewayTimeout || rec.Body.Len() != 0 {
+		t.Fatalf("expected empty 504, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.calls != 1 {
+		t.Fatalf("expected one WriteHeader, got %d", rec.calls)
+	}
+}
+
+func TestTimeout_KeepsResponseWrittenBeforeDeadline(t *testing.T) {
+	h := Timeout(20*time.Millisecond, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	rec := &headerCounter{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rec.Code != http.StatusAccepted || rec.calls != 1 {
+		t.Fatalf("expected a single 202, got %d after %d WriteHeader calls", rec.Code, rec.calls)
+	}
+}
+
+func TestTimeout_HonoursCallerBudget(t *testing.T) {
+	var remaining time.Duration
+	h := Timeout(time.Minute, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dl, _ := r.Context().Deadline()
+		remaining = time.Until(dl)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Timeout", "250")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if remaining <= 0 || remaining > 250*time.Millisecond {
+		t.Fatalf("expected deadline from the caller's 250ms budget, got %v", remaining)
+	}
+
+	req.Header.Set("X-Request-Timeout", "3600000")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if remaining <= 250*time.Millisecond || remaining > time.Minute {
+		t.Fatalf("expected budget capped at REQUEST_TIMEOUT, got %v", remaining)
+	}
+}
+
+func TestMiddlewareChain_StreamsAndHijacksThroughEveryWriter(t *testing.T) {
+	chain := func(h http.Handler) http.Handler {
+		h = LoggingMiddleware(slog.New(slog.NewJSONHandler(io.Discard, nil)))(h)
+		h = Compress(5, nil)(h)
+		h = metrics.Middleware(h)
+		h = Timeout(time.Minute, false)(h)
+		return ResponseHeaders(HeaderPolicy{Headers: map[string]string{"X-Policy": "on"}})(h)
+	}
+	srv := httptest.NewServer(chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if r.URL.Path == "/upgrade" {
+			conn, buf, err := rc.Hijack()
+			if err != nil {
+				t.Errorf("Hijack: %v", err)
+				return
+			}
+			defer conn.Close()
+			_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+			_ = buf.Flush()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: first\n\n"))
+		if err := rc.Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		<-r.Context().Done() // until the client has read the first event
+	})))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	resp.Body.Close()
+	if err != nil || line != "data: first\n" || resp.Header.Get("X-Policy") != "on" {
+		t.Fatalf("streamed %q (err %v), X-Policy %q", line, err, resp.Header.Get("X-Policy"))
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/upgrade", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err = srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /upgrade: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+)
+
+// Trace starts a server span for each request, continuing the caller's trace
+// when it sends a traceparent header. The span is named after the route the
+// request matched once the router has run, like otelhttp does, and fails on
+// 5xx responses. It must run before LoggingMiddleware so request logs carry
+// the trace ID.
+func Trace(tracer *tracing.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(tracing.Extract(r.Context(), r.Header), r.Method, tracing.KindServer,
+				slog.String("http.request.method", r.Method),
+				slog.String("url.path", r.URL.Path),
+				slog.String("client.address", r.RemoteAddr))
+			defer span.End()
+			ww := respwriter.Wrap(w)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			span.SetAttributes(slog.Int("http.response.status_code", status))
+			if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetAttributes(slog.String("http.route", rctx.RoutePattern()))
+			}
+			if h := requestctx.Handler(ctx); h != "" {
+				span.SetAttributes(slog.String("code.function", h))
+			}
+			if status >= 500 {
+				span.SetFailed(http.StatusText(status))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+)
+
+func TestTrace_ContinuesTraceAndCorrelatesLogs(t *testing.T) {
+	exp := tracing.NewExporter(tracing.ExporterOptions{Endpoint: "http://127.0.0.1:0", Logger: testLogger()})
+	defer exp.Shutdown(context.Background())
+	var logs bytes.Buffer
+	var span *tracing.Span
+
+	r := chi.NewRouter()
+	r.Use(Trace(tracing.New(exp, 0)), LoggingMiddleware(slog.New(slog.NewJSONHandler(&logs, nil))))
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		span = tracing.SpanFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/u1", nil)
+	req.Header.Set(tracing.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	sc := span.SpanContext()
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !sc.Sampled {
+		t.Fatalf("caller's trace not continued: %+v", sc)
+	}
+	if !strings.Contains(logs.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) || !strings.Contains(logs.String(), `"span_id":"`+sc.SpanID.String()+`"`) {
+		t.Fatalf("request log not correlated: %s", logs.String())
+	}
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+)
+
+// TrackUsage records each request's status and body sizes against the
+// caller's API key. Request bytes are counted after decompression.
+func TrackUsage(tracker *usage.Tracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &countingReader{ReadCloser: r.Body}
+			if r.Body != nil {
+				r.Body = body
+			}
+			ww := respwriter.Wrap(w)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			tracker.Record(usage.KeyFromRequest(r), status, body.n, ww.BytesWritten())
+		})
+	}
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
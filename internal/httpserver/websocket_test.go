@@ -0,0 +1,92 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/ws"
+)
+
+// TestWebSocket_ThroughMiddleware upgrades /api/v1/ws through the whole
+// middleware chain, every layer of which must let the connection be taken
+// over, and receives the event of a task created afterwards.
+func TestWebSocket_ThroughMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		Env:                   "test",
+		RequestTimeout:        time.Second,
+		BodyLimitBytes:        1 << 20,
+		CORSAllowedOrigins:    []string{"*"},
+		CORSAllowedMethods:    []string{"GET", "POST"},
+		CORSAllowedHeaders:    []string{"*"},
+		CompressionLevel:      5,
+		ResponseGuard:         "strict",
+		ResponseGuardMaxItems: 100,
+		ResponseGuardMaxBytes: 1 << 20,
+	}
+	srv := httptest.NewServer(NewRouter(cfg, testLogger()))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Accept-Encoding", "gzip")
+	_ = req.Write(conn)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %v (err %v)", resp, err)
+	}
+
+	// A masked text frame joining the tasks room
+	join := []byte(`{"type":"join","room":"tasks"}`)
+	frame := append([]byte{0x81, 0x80 | byte(len(join)), 0, 0, 0, 0}, join...) // a zero mask
+	_, _ = conn.Write(frame)
+	readMessage := func() ws.Message {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var head [2]byte
+		if _, err := io.ReadFull(br, head[:]); err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		n := int(head[1])
+		if n == 126 {
+			var ext [2]byte
+			_, _ = io.ReadFull(br, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, n)
+		_, _ = io.ReadFull(br, payload)
+		var msg ws.Message
+		_ = json.Unmarshal(payload, &msg)
+		return msg
+	}
+	if msg := readMessage(); msg.Type != "joined" {
+		t.Fatalf("expected joined, got %+v", msg)
+	}
+
+	res, err := http.Post(srv.URL+"/api/v1/tasks", "application/json", bytes.NewBufferString(`{"title":"Realtime"}`))
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	res.Body.Close()
+	if msg := readMessage(); msg.Type != "task.created" || msg.Room != "tasks" || !strings.Contains(string(msg.Data), "Realtime") {
+		t.Fatalf("expected the task.created event, got %+v", msg)
+	}
+}
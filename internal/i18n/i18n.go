@@ -0,0 +1,214 @@
+// Package i18n localizes the display text of responses. Message catalogs are
+// JSON files in the locales assets, one per language (fi.json), mapping
+// message keys to text. A request's language is negotiated from its
+// Accept-Language header against the catalogs' languages and carried in its
+// context, so handlers translate with T(ctx, "role.admin").
+package i18n
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikko-kohtala/go-api/internal/assets"
+)
+
+// CatalogDir is the directory of the message catalogs in the assets.
+const CatalogDir = "locales"
+
+// Catalog holds the messages of every language.
+type Catalog struct {
+	fallback string
+
+	mu       sync.RWMutex
+	messages map[string]map[string]string // language -> key -> text
+}
+
+// Default is the catalog of the server; main replaces it with one falling
+// back to DEFAULT_LANGUAGE.
+var Default = NewCatalog("en")
+
+// NewCatalog returns a catalog of the embedded messages that falls back to
+// language fallback when a request's language has none.
+func NewCatalog(fallback string) *Catalog {
+	c := &Catalog{fallback: strings.ToLower(fallback), messages: map[string]map[string]string{}}
+	builtin, err := fs.Sub(assets.Embedded(), CatalogDir)
+	if err == nil {
+		err = c.Load(builtin)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load adds (or replaces) the catalogs in the *.json files of fsys, named by
+// their language tag. Nothing is replaced when any file is invalid.
+func (c *Catalog) Load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return err
+	}
+	loaded := map[string]map[string]string{}
+	for _, file := range files {
+		lang := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		if !ValidTag(lang) {
+			return fmt.Errorf("catalog %s: %q is not a language tag", file, lang)
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("catalog %s: %w", file, err)
+		}
+		loaded[lang] = messages
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for lang, messages := range loaded {
+		c.messages[lang] = messages
+	}
+	return nil
+}
+
+// Fallback returns the language used when no catalog matches a request.
+func (c *Catalog) Fallback() string {
+	return c.fallback
+}
+
+// Languages returns the languages that have a catalog, sorted.
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Translate returns the text of key in lang, in lang's primary language
+// (fi for fi-fi), or in the fallback language, formatted with args when
+// given. A key none of them has is returned as it is.
+func (c *Catalog) Translate(lang, key string, args ...any) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	primary, _, _ := strings.Cut(lang, "-")
+	for _, l := range []string{lang, primary, c.fallback} {
+		if text, ok := c.messages[l][key]; ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(text, args...)
+			}
+			return text
+		}
+	}
+	return key
+}
+
+// Negotiate returns the language of the catalogs best matching an
+// Accept-Language header, or the fallback language. Ranges are tried by
+// quality, first in the header on ties; a range matches a catalog of the same
+// tag, of its primary language (fi-FI takes fi) or of a subtag of it (en takes
+// en-gb).
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	langs := c.Languages()
+	for _, r := range parseAcceptLanguage(acceptLanguage) {
+		if r == "*" {
+			return c.fallback
+		}
+		if slices.Contains(langs, r) {
+			return r
+		}
+		if primary, _, ok := strings.Cut(r, "-"); ok && slices.Contains(langs, primary) {
+			return primary
+		}
+		for _, l := range langs {
+			if strings.HasPrefix(l, r+"-") {
+				return l
+			}
+		}
+	}
+	return c.fallback
+}
+
+// parseAcceptLanguage returns the language ranges of an Accept-Language
+// header, lower-cased and ordered by quality; ranges with q=0 or a malformed
+// tag are left out.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "*" && !ValidTag(tag) {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || f > 1 {
+				continue
+			}
+			q = f
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{tag, q})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b weighted) int { return cmp.Compare(b.q, a.q) })
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
+
+// ValidTag reports whether tag is a language tag: subtags of 1 to 8 letters
+// or digits separated by hyphens, the first of letters.
+func ValidTag(tag string) bool {
+	for i, sub := range strings.Split(tag, "-") {
+		if len(sub) < 1 || len(sub) > 8 {
+			return false
+		}
+		for _, r := range sub {
+			letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+			if !letter && (i == 0 || r < '0' || r > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type ctxKey struct{}
+
+// WithLanguage returns ctx carrying lang as the request's language.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// Language returns the language of ctx, or Default's fallback language.
+func Language(ctx context.Context) string {
+	if lang, ok := ctx.Value(ctxKey{}).(string); ok {
+		return lang
+	}
+	return Default.Fallback()
+}
+
+// T translates key into the language of ctx with Default.
+func T(ctx context.Context, key string, args ...any) string {
+	return Default.Translate(Language(ctx), key, args...)
+}
@@ -0,0 +1,91 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c := NewCatalog("en")
+	err := c.Load(fstest.MapFS{
+		"en-GB.json": {Data: []byte(`{"greeting": "Good day, %s"}`)},
+		"pt-BR.json": {Data: []byte(`{"role.user": "Usuário"}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNegotiate(t *testing.T) {
+	c := testCatalog(t)
+	for header, want := range map[string]string{
+		"":                              "en",
+		"fi":                            "fi",
+		"fi-FI, en;q=0.5":               "fi",
+		"sv, de;q=0.8, fi;q=0.9":        "fi",
+		"PT-br":                         "pt-br",
+		"pt":                            "pt-br",
+		"sv, *;q=0.1":                   "en",
+		"de;q=0, fi;q=0.2":              "fi",
+		"de;q=abc, fi;q=0.2, x_y, sv":   "fi",
+		"en-US":                         "en",
+		"en-gb;q=0.9, en;q=0.9, de;q=1": "de",
+	} {
+		if got := c.Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestTranslate_FallsBack(t *testing.T) {
+	c := testCatalog(t)
+	for _, tc := range []struct {
+		lang, key, want string
+		args            []any
+	}{
+		{"fi", "role.admin", "Ylläpitäjä", nil},
+		{"fi-fi", "role.user", "Käyttäjä", nil},
+		{"pt-br", "role.admin", "Administrator", nil}, // fallback language
+		{"en-gb", "greeting", "Good day, Ada", []any{"Ada"}},
+		{"fi", "role.unknown", "role.unknown", nil},
+	} {
+		if got := c.Translate(tc.lang, tc.key, tc.args...); got != tc.want {
+			t.Errorf("Translate(%q, %q) = %q, want %q", tc.lang, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestLoad_RejectsInvalidCatalogs(t *testing.T) {
+	c := testCatalog(t)
+	for name, data := range map[string]string{
+		"fi.json":        `{"role.admin": 1}`,
+		"f_i.json":       `{}`,
+		"languages.json": `{}`,
+		"sv-SE.json":     `[]`,
+	} {
+		if err := c.Load(fstest.MapFS{name: {Data: []byte(data)}, "sv.json": {Data: []byte(`{}`)}}); err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+	if got := c.Translate("fi", "role.admin"); got != "Ylläpitäjä" {
+		t.Fatalf("expected the previous catalog to be kept, got %q", got)
+	}
+	for _, lang := range c.Languages() {
+		if lang == "sv" {
+			t.Fatal("expected no catalog of a failed load to be added")
+		}
+	}
+}
+
+func TestLanguage_DefaultsToFallback(t *testing.T) {
+	if got := Language(context.Background()); got != Default.Fallback() {
+		t.Fatalf("expected %q, got %q", Default.Fallback(), got)
+	}
+	ctx := WithLanguage(context.Background(), "fi")
+	if got := T(ctx, "role.moderator"); got != "Moderaattori" {
+		t.Fatalf("expected the Finnish text, got %q", got)
+	}
+}
@@ -0,0 +1,183 @@
+// Package idempotency lets clients repeat unsafe requests without them being
+// applied twice. A client, or the outbound client on its retries, sends the
+// same Idempotency-Key with every attempt of one logical request; the first
+// attempt runs and its response is kept, and the others get that response
+// again. Attempts arriving while the first still runs, such as hedged
+// requests, wait for it rather than running alongside it.
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Header carries the key of a request.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set to "true" on responses repeated from an earlier
+// attempt.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// MaxKeyLength bounds the keys clients may send.
+const MaxKeyLength = 255
+
+var (
+	// ErrInvalidKey is returned for a key that is empty, too long or not
+	// printable ASCII.
+	ErrInvalidKey = errors.New("invalid idempotency key")
+	// ErrKeyReused is returned when a key comes back with a different
+	// request than the one it was first used with.
+	ErrKeyReused = errors.New("idempotency key reused for a different request")
+)
+
+// NewKey returns a random key for a request that will be retried.
+func NewKey() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return "idk_" + hex.EncodeToString(b[:])
+}
+
+// ValidKey reports whether key may be used: 1 to MaxKeyLength printable
+// ASCII characters.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Response is the outcome of the first attempt with a key.
+type Response struct {
+	Fingerprint string // identifies the request, e.g. its method, path and a hash of its body
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// Store remembers the responses of keyed requests for a while.
+type Store struct {
+	ttl   time.Duration
+	limit int
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // oldest claim at the front
+}
+
+type entry struct {
+	key     string
+	done    chan struct{} // closed once the first attempt completes or releases the key
+	resp    *Response     // nil while in flight, or when released
+	expires time.Time
+}
+
+// NewStore keeps responses for ttl and at most limit keys; the oldest
+// completed ones are dropped first.
+func NewStore(ttl time.Duration, limit int) *Store {
+	return &Store{ttl: ttl, limit: limit, now: time.Now, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Claim makes the caller the first attempt with key, returning nil, in which
+// case it must call Complete or Release. When another attempt has the key it
+// waits for that one and returns its response, or claims the key itself when
+// that attempt released it. It returns ctx's error when ctx ends first.
+func (s *Store) Claim(ctx context.Context, key string) (*Response, error) {
+	for {
+		s.mu.Lock()
+		s.expireLocked()
+		el, ok := s.entries[key]
+		if !ok {
+			s.entries[key] = s.order.PushBack(&entry{key: key, done: make(chan struct{})})
+			s.evictLocked()
+			s.mu.Unlock()
+			return nil, nil
+		}
+		e := el.Value.(*entry)
+		s.mu.Unlock()
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.resp != nil {
+			return e.resp, nil
+		}
+	}
+}
+
+// Complete records the response of the attempt that claimed key and hands it
+// to the attempts waiting for it.
+func (s *Store) Complete(key string, resp *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	e := el.Value.(*entry)
+	e.resp, e.expires = resp, s.now().Add(s.ttl)
+	close(e.done)
+}
+
+// Release gives key up without a response, so the next attempt runs again;
+// for outcomes worth retrying, such as 503.
+func (s *Store) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	s.order.Remove(el)
+	delete(s.entries, key)
+	close(el.Value.(*entry).done)
+}
+
+// Len returns the number of keys held.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// expireLocked drops the completed entries past their expiry; claims are
+// in order, so it stops at the first one still valid or in flight.
+func (s *Store) expireLocked() {
+	now := s.now()
+	for el := s.order.Front(); el != nil; {
+		e := el.Value.(*entry)
+		if e.resp == nil || now.Before(e.expires) {
+			return
+		}
+		next := el.Next()
+		s.order.Remove(el)
+		delete(s.entries, e.key)
+		el = next
+	}
+}
+
+// evictLocked drops the oldest completed entries while over the limit. In
+// flight ones are kept, so their attempts never run twice.
+func (s *Store) evictLocked() {
+	for el := s.order.Front(); el != nil && len(s.entries) > s.limit; {
+		next := el.Next()
+		if e := el.Value.(*entry); e.resp != nil {
+			s.order.Remove(el)
+			delete(s.entries, e.key)
+		}
+		el = next
+	}
+}
@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStore_ExpiresAndEvictsCompletedKeys(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewStore(time.Minute, 2)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b"} {
+		if prior, err := s.Claim(ctx, key); prior != nil || err != nil {
+			t.Fatalf("Claim(%s) = %v, %v", key, prior, err)
+		}
+	}
+	s.Complete("a", &Response{Status: 201})
+	if _, err := s.Claim(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	// "a" made way for "c"; "b" is still in flight and kept
+	if s.Len() != 2 {
+		t.Fatalf("expected 2 keys, got %d", s.Len())
+	}
+	if prior, _ := s.Claim(ctx, "a"); prior != nil {
+		t.Fatal("expected the evicted key to be claimable again")
+	}
+
+	s.Complete("b", &Response{Status: 200})
+	if prior, _ := s.Claim(ctx, "b"); prior == nil || prior.Status != 200 {
+		t.Fatalf("expected the completed response, got %+v", prior)
+	}
+	now = now.Add(time.Minute)
+	if prior, _ := s.Claim(ctx, "b"); prior != nil {
+		t.Fatal("expected the expired key to be claimable again")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.Claim(cancelled, "c"); err == nil {
+		t.Fatal("expected waiting on an in-flight key to end with the context")
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	for key, want := range map[string]bool{"": false, NewKey(): true, "order-42": true, "tab\tkey": false, string(make([]byte, 256)): false} {
+		if got := ValidKey(key); got != want {
+			t.Errorf("ValidKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package ids generates the IDs of the resources the API creates. IDs embed
+// their creation time ahead of random bits, in one of the UUIDv7, ULID or
+// KSUID formats, so they are unique across replicas without coordination,
+// reveal nothing about how many resources exist and sort in creation order,
+// which lets list endpoints page with the last ID seen as the cursor. Each
+// resource type has a prefix, e.g. usr_01926b8c-... for users.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Strategy is the format of generated IDs.
+type Strategy string
+
+const (
+	UUIDv7 Strategy = "uuidv7" // RFC 9562 version 7, millisecond time and 74 random bits
+	ULID   Strategy = "ulid"   // millisecond time and 80 random bits, 26 Crockford base32 characters
+	KSUID  Strategy = "ksuid"  // second time and 128 random bits, 27 base62 characters
+)
+
+// Prefixes of the resource types.
+const (
+	User   = "usr"
+	Task   = "tsk"
+	Report = "rpt"
+	Socket = "ws" // WebSocket connections
+)
+
+// Generator generates IDs in one format.
+type Generator struct {
+	strategy Strategy
+	prefixed bool
+	now      func() time.Time
+}
+
+// Default generates the IDs of all services. main replaces it with one
+// configured by ID_STRATEGY and ID_PREFIXED.
+var Default = New(UUIDv7, true)
+
+// New returns a generator of strategy IDs, which start with the resource's
+// prefix and an underscore when prefixed is set. config.Load validates the
+// strategy; an unknown one generates UUIDv7s.
+func New(strategy Strategy, prefixed bool) *Generator {
+	return &Generator{strategy: strategy, prefixed: prefixed, now: time.Now}
+}
+
+// Strategy returns the format of the IDs g generates.
+func (g *Generator) Strategy() Strategy { return g.strategy }
+
+// New returns a new ID for a resource of the type with the given prefix.
+func (g *Generator) New(prefix string) (string, error) {
+	now := g.now()
+	var id string
+	switch g.strategy {
+	case ULID:
+		var b [16]byte
+		if _, err := rand.Read(b[6:]); err != nil {
+			return "", err
+		}
+		putMillis(b[:], now)
+		id = encodeULID(b)
+	case KSUID:
+		var b [20]byte
+		if _, err := rand.Read(b[4:]); err != nil {
+			return "", err
+		}
+		binary.BigEndian.PutUint32(b[:4], uint32(now.Unix()-ksuidEpoch))
+		id = encodeKSUID(b)
+	default:
+		var b [16]byte
+		if _, err := rand.Read(b[6:]); err != nil {
+			return "", err
+		}
+		putMillis(b[:], now)
+		b[6] = b[6]&0x0f | 0x70 // version 7
+		b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+		id = encodeUUID(b)
+	}
+	if g.prefixed && prefix != "" {
+		id = prefix + "_" + id
+	}
+	return id, nil
+}
+
+// Time returns when id was generated, for IDs in any of the formats, with or
+// without a prefix. It reports false for other IDs, such as the usr_001
+// style IDs of the sample data.
+func Time(id string) (time.Time, bool) {
+	body := id[strings.LastIndexByte(id, '_')+1:]
+	switch len(body) {
+	case 36:
+		b, ok := decodeUUID(body)
+		if !ok || b[6]>>4 != 7 {
+			return time.Time{}, false
+		}
+		return millis(b[:6]), true
+	case 26:
+		b, ok := decodeULID(body)
+		if !ok {
+			return time.Time{}, false
+		}
+		return millis(b[:6]), true
+	case 27:
+		b, ok := decodeKSUID(body)
+		if !ok {
+			return time.Time{}, false
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(b[:4]))+ksuidEpoch, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// Compare orders IDs by creation time, then by their bytes, and is the order
+// cursors over lists of generated IDs follow. IDs without a time sort first,
+// so resources created before the format changed keep their place.
+// Generated IDs of one format and prefix compare the same as strings, except
+// within a millisecond (a second for KSUIDs), where the random bits decide.
+func Compare(a, b string) int {
+	ta, oka := Time(a)
+	tb, okb := Time(b)
+	switch {
+	case oka && okb && !ta.Equal(tb):
+		return ta.Compare(tb)
+	case oka != okb:
+		if oka {
+			return 1
+		}
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func millis(b []byte) time.Time {
+	var ms int64
+	for _, c := range b[:6] {
+		ms = ms<<8 | int64(c)
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
+func encodeUUID(b [16]byte) string {
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func decodeUUID(s string) ([16]byte, bool) {
+	var b [16]byte
+	if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return b, false
+	}
+	_, err := hex.Decode(b[:], []byte(strings.ReplaceAll(s, "-", "")))
+	return b, err == nil
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// encodeULID writes the 128 bits of b as 26 five-bit digits, most significant
+// first; the first digit holds only the top three bits.
+func encodeULID(b [16]byte) string {
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+func decodeULID(s string) ([16]byte, bool) {
+	var b [16]byte
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(crockford, s[i])
+		if d < 0 || (i == 0 && d > 7) {
+			return b, false
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(d)
+	}
+	binary.BigEndian.PutUint64(b[:8], hi)
+	binary.BigEndian.PutUint64(b[8:], lo)
+	return b, true
+}
+
+// ksuidEpoch is the KSUID epoch, 2014-05-13T16:53:20Z, in Unix seconds.
+const ksuidEpoch = 1400000000
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encodeKSUID writes b in base62, padded to 27 digits so KSUIDs sort as
+// strings.
+func encodeKSUID(b [20]byte) string {
+	n := new(big.Int).SetBytes(b[:])
+	var out [27]byte
+	radix, digit := big.NewInt(62), new(big.Int)
+	for i := 26; i >= 0; i-- {
+		n.DivMod(n, radix, digit)
+		out[i] = base62[digit.Int64()]
+	}
+	return string(out[:])
+}
+
+func decodeKSUID(s string) ([20]byte, bool) {
+	var b [20]byte
+	n, radix := new(big.Int), big.NewInt(62)
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(base62, s[i])
+		if d < 0 {
+			return b, false
+		}
+		n.Mul(n, radix).Add(n, big.NewInt(int64(d)))
+	}
+	if n.BitLen() > 160 {
+		return b, false
+	}
+	n.FillBytes(b[:])
+	return b, true
+}
@@ -0,0 +1,73 @@
+package ids
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerator_Formats(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 30, 45, 123e6, time.UTC)
+	cases := []struct {
+		strategy Strategy
+		pattern  string
+		time     time.Time
+	}{
+		{UUIDv7, `^usr_[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, at},
+		{ULID, `^usr_[0-7][0-9A-HJKMNP-TV-Z]{25}$`, at},
+		{KSUID, `^usr_[0-9A-Za-z]{27}$`, at.Truncate(time.Second)},
+	}
+	for _, tc := range cases {
+		g := New(tc.strategy, true)
+		g.now = func() time.Time { return at }
+		id, err := g.New(User)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(tc.pattern).MatchString(id) {
+			t.Errorf("%s: unexpected ID %q", tc.strategy, id)
+		}
+		if got, ok := Time(id); !ok || !got.Equal(tc.time) {
+			t.Errorf("%s: Time(%q) = %v, %v", tc.strategy, id, got, ok)
+		}
+		other, _ := g.New(User)
+		if other == id {
+			t.Errorf("%s: generated %q twice", tc.strategy, id)
+		}
+	}
+
+	id, _ := New(ULID, false).New(Task)
+	if strings.Contains(id, "_") || len(id) != 26 {
+		t.Fatalf("unprefixed ID %q", id)
+	}
+}
+
+func TestCompare_OrdersByCreation(t *testing.T) {
+	for _, strategy := range []Strategy{UUIDv7, ULID, KSUID} {
+		g := New(strategy, true)
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		var created []string
+		for i := range 50 {
+			g.now = func() time.Time { return start.Add(time.Duration(i) * 1100 * time.Millisecond) }
+			id, _ := g.New(Task)
+			created = append(created, id)
+		}
+		sorted := slices.Clone(created)
+		slices.Reverse(sorted)
+		slices.SortFunc(sorted, Compare)
+		if !slices.Equal(sorted, created) {
+			t.Errorf("%s: IDs not ordered by creation", strategy)
+		}
+		if !slices.IsSorted(created) {
+			t.Errorf("%s: IDs do not sort as strings", strategy)
+		}
+		if Compare("usr_001", created[0]) >= 0 {
+			t.Errorf("%s: IDs without a time should sort first", strategy)
+		}
+	}
+	if _, ok := Time("usr_001"); ok {
+		t.Fatal("a sample ID has no time")
+	}
+}
@@ -0,0 +1,191 @@
+// Package imaging resizes, crops and re-encodes images using only the standard
+// library decoders and encoders (JPEG, PNG and GIF).
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidOptions    = errors.New("invalid image options")
+	ErrTooLarge          = errors.New("image too large")
+)
+
+// Fit modes for when both width and height are requested.
+const (
+	FitContain = "contain" // scale to fit inside the box, keeping the aspect ratio
+	FitCover   = "cover"   // scale to fill the box, cropping the overflow around the centre
+)
+
+const defaultJPEGQuality = 85
+
+// Options describes a transform. A zero Width or Height is derived from the
+// other dimension; an empty Format keeps the source format.
+type Options struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int // JPEG only, 1-100
+}
+
+// Limits bound the work a single transform may do.
+type Limits struct {
+	MaxSourcePixels int // checked from the header before decoding
+	MaxDimension    int // largest output width or height
+}
+
+// Key returns a stable identifier for the transform, for caching variants.
+func (o Options) Key() string {
+	return strconv.Itoa(o.Width) + "x" + strconv.Itoa(o.Height) + "_" + o.Fit + "_" + o.Format + "_q" + strconv.Itoa(o.Quality)
+}
+
+// Normalize validates o against limits and fills in defaults.
+func (o Options) Normalize(limits Limits) (Options, error) {
+	if o.Width < 0 || o.Height < 0 || (o.Width == 0 && o.Height == 0) {
+		return o, fmt.Errorf("%w: width or height is required", ErrInvalidOptions)
+	}
+	if o.Width > limits.MaxDimension || o.Height > limits.MaxDimension {
+		return o, fmt.Errorf("%w: dimensions are limited to %d pixels", ErrInvalidOptions, limits.MaxDimension)
+	}
+	switch o.Fit {
+	case "":
+		o.Fit = FitContain
+	case FitContain, FitCover:
+	default:
+		return o, fmt.Errorf("%w: fit must be contain or cover", ErrInvalidOptions)
+	}
+	switch o.Format {
+	case "", "png", "gif":
+	case "jpeg", "jpg":
+		o.Format = "jpeg"
+	default:
+		return o, fmt.Errorf("%w: cannot encode %q (supported: jpeg, png, gif)", ErrUnsupportedFormat, o.Format)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return o, fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidOptions)
+	}
+	if o.Format == "jpeg" && o.Quality == 0 {
+		o.Quality = defaultJPEGQuality
+	}
+	return o, nil
+}
+
+// Transform decodes src, applies opts (which must be normalized) and returns the
+// encoded result with its content type.
+func Transform(src io.ReadSeeker, opts Options, limits Limits) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if cfg.Width*cfg.Height > limits.MaxSourcePixels {
+		return nil, "", fmt.Errorf("%w: %dx%d source", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	dst := resize(img, opts)
+	if opts.Format == "" {
+		opts.Format = format
+		if format == "jpeg" && opts.Quality == 0 {
+			opts.Quality = defaultJPEGQuality
+		}
+	}
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: opts.Quality})
+	case "png":
+		err = png.Encode(&buf, dst)
+	case "gif":
+		err = gif.Encode(&buf, dst, nil)
+	default:
+		return nil, "", fmt.Errorf("%w: cannot encode %q", ErrUnsupportedFormat, opts.Format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/" + opts.Format, nil
+}
+
+// resize scales img to the requested box, cropping for FitCover.
+func resize(img image.Image, opts Options) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	w, h := opts.Width, opts.Height
+	switch {
+	case w == 0:
+		w = max(1, sw*h/sh)
+	case h == 0:
+		h = max(1, sh*w/sw)
+	}
+
+	// Scaled size before cropping
+	scale := min(float64(w)/float64(sw), float64(h)/float64(sh))
+	if opts.Fit == FitCover {
+		scale = max(float64(w)/float64(sw), float64(h)/float64(sh))
+	}
+	tw, th := max(1, int(float64(sw)*scale+0.5)), max(1, int(float64(sh)*scale+0.5))
+	if opts.Fit == FitContain || opts.Width == 0 || opts.Height == 0 {
+		w, h = tw, th
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	scaled := scaleArea(src, tw, th)
+
+	// Centre crop to the requested box
+	x0, y0 := (tw-w)/2, (th-h)/2
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), scaled, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// scaleArea resamples src to w x h by averaging the source pixels covered by
+// each destination pixel (nearest neighbour when enlarging).
+func scaleArea(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy0 := y * sh / h
+		sy1 := max(sy0+1, (y+1)*sh/h)
+		for x := 0; x < w; x++ {
+			sx0 := x * sw / w
+			sx1 := max(sx0+1, (x+1)*sw/w)
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+var testLimits = Limits{MaxSourcePixels: 1 << 20, MaxDimension: 500}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func transformSize(t *testing.T, src []byte, opts Options) (int, int, string) {
+	t.Helper()
+	opts, err := opts.Normalize(testLimits)
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	data, ct, err := Transform(bytes.NewReader(src), opts, testLimits)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	return cfg.Width, cfg.Height, ct
+}
+
+func TestTransform_Dimensions(t *testing.T) {
+	src := testPNG(t, 200, 100)
+
+	cases := []struct {
+		opts  Options
+		w, h  int
+		ctype string
+	}{
+		{Options{Width: 50}, 50, 25, "image/png"},
+		{Options{Height: 50}, 100, 50, "image/png"},
+		{Options{Width: 50, Height: 50}, 50, 25, "image/png"},
+		{Options{Width: 50, Height: 50, Fit: FitCover}, 50, 50, "image/png"},
+		{Options{Width: 40, Format: "jpg"}, 40, 20, "image/jpeg"},
+		{Options{Width: 40, Format: "gif"}, 40, 20, "image/gif"},
+	}
+	for _, tc := range cases {
+		w, h, ct := transformSize(t, src, tc.opts)
+		if w != tc.w || h != tc.h || ct != tc.ctype {
+			t.Fatalf("%+v: expected %dx%d %s, got %dx%d %s", tc.opts, tc.w, tc.h, tc.ctype, w, h, ct)
+		}
+	}
+}
+
+func TestNormalize_RejectsInvalidOptions(t *testing.T) {
+	cases := []Options{
+		{},
+		{Width: 501},
+		{Width: 10, Fit: "stretch"},
+		{Width: 10, Quality: 101},
+	}
+	for _, o := range cases {
+		if _, err := o.Normalize(testLimits); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("%+v: expected ErrInvalidOptions, got %v", o, err)
+		}
+	}
+	if _, err := (Options{Width: 10, Format: "webp"}).Normalize(testLimits); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat for webp, got %v", err)
+	}
+}
+
+func TestTransform_RejectsHugeSources(t *testing.T) {
+	src := testPNG(t, 200, 100)
+	limits := Limits{MaxSourcePixels: 100 * 100, MaxDimension: 500}
+	if _, _, err := Transform(bytes.NewReader(src), Options{Width: 10, Fit: FitContain}, limits); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+type readSeekCloser struct{ *bytes.Reader }
+
+func (readSeekCloser) Close() error { return nil }
+
+func TestProcessor_CachesVariants(t *testing.T) {
+	src := testPNG(t, 64, 64)
+	p := NewProcessor(testLimits, 1, 1<<20)
+	opens := 0
+	open := func() (io.ReadSeekCloser, error) {
+		opens++
+		return readSeekCloser{bytes.NewReader(src)}, nil
+	}
+	opts, _ := Options{Width: 16}.Normalize(testLimits)
+
+	if _, hit, err := p.Process(context.Background(), "img", opts, open); err != nil || hit {
+		t.Fatalf("expected miss, got hit=%v err=%v", hit, err)
+	}
+	if _, hit, err := p.Process(context.Background(), "img", opts, open); err != nil || !hit {
+		t.Fatalf("expected hit, got hit=%v err=%v", hit, err)
+	}
+	if opens != 1 {
+		t.Fatalf("expected source to be opened once, got %d", opens)
+	}
+}
+
+func TestProcessor_BusyWhenSlotsExhausted(t *testing.T) {
+	p := NewProcessor(testLimits, 1, 0)
+	p.slots <- struct{}{} // occupy the only slot
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts, _ := Options{Width: 16}.Normalize(testLimits)
+	_, _, err := p.Process(ctx, "img", opts, func() (io.ReadSeekCloser, error) {
+		t.Fatalf("source must not be opened while at capacity")
+		return nil, nil
+	})
+	if err != ErrBusy {
+		t.Fatalf("expected ErrBusy, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package imaging
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrBusy is returned when no transform slot frees up before the context ends.
+var ErrBusy = errors.New("image processing at capacity")
+
+// Variant is an encoded transform result.
+type Variant struct {
+	Data        []byte
+	ContentType string
+}
+
+// Processor runs transforms with a concurrency cap and caches the results.
+type Processor struct {
+	limits Limits
+	slots  chan struct{}
+	cache  *variantCache
+}
+
+// NewProcessor allows at most concurrency transforms at once and keeps up to
+// cacheBytes of encoded variants in memory (0 disables caching).
+func NewProcessor(limits Limits, concurrency int, cacheBytes int64) *Processor {
+	return &Processor{
+		limits: limits,
+		slots:  make(chan struct{}, max(1, concurrency)),
+		cache:  newVariantCache(cacheBytes),
+	}
+}
+
+// Limits returns the processor's transform limits.
+func (p *Processor) Limits() Limits {
+	return p.limits
+}
+
+// Process returns the variant of the source identified by sourceKey. open is
+// only called on a cache miss. The boolean reports whether the cache was hit.
+func (p *Processor) Process(ctx context.Context, sourceKey string, opts Options, open func() (io.ReadSeekCloser, error)) (Variant, bool, error) {
+	key := sourceKey + "/" + opts.Key()
+	if v, ok := p.cache.get(key); ok {
+		return v, true, nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	case <-ctx.Done():
+		return Variant{}, false, ErrBusy
+	}
+	// Another request may have produced the variant while we waited
+	if v, ok := p.cache.get(key); ok {
+		return v, true, nil
+	}
+
+	src, err := open()
+	if err != nil {
+		return Variant{}, false, err
+	}
+	defer src.Close()
+	data, contentType, err := Transform(src, opts, p.limits)
+	if err != nil {
+		return Variant{}, false, err
+	}
+	v := Variant{Data: data, ContentType: contentType}
+	p.cache.add(key, v)
+	return v, false, nil
+}
+
+// variantCache is an LRU cache bounded by the total size of the cached data.
+type variantCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	variant Variant
+}
+
+func newVariantCache(maxBytes int64) *variantCache {
+	return &variantCache{maxBytes: maxBytes, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *variantCache) get(key string) (Variant, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return Variant{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).variant, true
+}
+
+func (c *variantCache) add(key string, v Variant) {
+	n := int64(len(v.Data))
+	if n > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, variant: v})
+	c.size += n
+	for c.size > c.maxBytes {
+		el := c.order.Back()
+		e := el.Value.(*cacheEntry)
+		c.order.Remove(el)
+		delete(c.items, e.key)
+		c.size -= int64(len(e.variant.Data))
+	}
+}
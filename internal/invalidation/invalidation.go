@@ -0,0 +1,289 @@
+// Package invalidation keeps in-process caches consistent across replicas.
+// Writes invalidate the local cache right away and queue the invalidation in
+// an outbox; a relay publishes the outbox on a broker channel every replica
+// subscribes to, and each replica drops the keys from its own cache of the
+// same name. A replica that may have missed messages, because its outbox
+// overflowed or its subscription dropped, clears the whole cache instead, so
+// stale entries never outlive the outage.
+package invalidation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Broker carries invalidation messages between replicas.
+type Broker interface {
+	// Publish sends payload to every subscriber, including this replica's.
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe calls fn with every payload published until ctx ends,
+	// returning nil, or the subscription fails. It calls ready once
+	// messages are being received.
+	Subscribe(ctx context.Context, ready func(), fn func(payload []byte)) error
+}
+
+// Message is an invalidation on the wire. No keys means the whole cache.
+type Message struct {
+	Origin string   `json:"origin"` // replica that sent it
+	Cache  string   `json:"cache"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+// Options configure an Invalidator.
+type Options struct {
+	Outbox        int           // invalidations waiting to be published, default 1024
+	RetryDelay    time.Duration // first delay after a broker error, doubled up to MaxRetryDelay; default 500ms
+	MaxRetryDelay time.Duration // default 10s
+}
+
+// InvalidateFunc drops keys from a cache, or everything when keys is empty.
+type InvalidateFunc func(keys ...string)
+
+// Invalidator fans invalidations out to the caches registered with it on
+// every replica.
+type Invalidator struct {
+	broker Broker
+	opts   Options
+	origin string
+	outbox chan Message
+
+	mu         sync.Mutex
+	caches     map[string]InvalidateFunc
+	overflowed map[string]bool // caches with invalidations lost to a full outbox
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// Default is the process-wide invalidator. main replaces it with one using
+// the configured broker before building the router, which registers caches.
+// Without a broker invalidations stay local.
+var Default = New(nil, Options{})
+
+// New returns an invalidator publishing on broker, or only invalidating
+// locally when broker is nil.
+func New(broker Broker, opts Options) *Invalidator {
+	if opts.Outbox <= 0 {
+		opts.Outbox = 1024
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 500 * time.Millisecond
+	}
+	if opts.MaxRetryDelay < opts.RetryDelay {
+		opts.MaxRetryDelay = max(10*time.Second, opts.RetryDelay)
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return &Invalidator{
+		broker:     broker,
+		opts:       opts,
+		origin:     hex.EncodeToString(b[:]),
+		outbox:     make(chan Message, opts.Outbox),
+		caches:     make(map[string]InvalidateFunc),
+		overflowed: make(map[string]bool),
+	}
+}
+
+// Register names a cache; invalidations of that name, local or from another
+// replica, call fn. It replaces an earlier registration of the name.
+func (inv *Invalidator) Register(cache string, fn InvalidateFunc) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.caches[cache] = fn
+}
+
+// Invalidate drops keys (all of them when none are given) from the named
+// cache here and queues the invalidation for the other replicas. It never
+// blocks: when the outbox is full the other replicas are told to clear the
+// whole cache once the relay catches up.
+func (inv *Invalidator) Invalidate(cache string, keys ...string) {
+	inv.apply(cache, keys)
+	if inv.broker == nil {
+		return
+	}
+	select {
+	case inv.outbox <- Message{Origin: inv.origin, Cache: cache, Keys: keys}:
+	default:
+		metrics.ObserveCacheInvalidation(cache, "dropped")
+		inv.mu.Lock()
+		inv.overflowed[cache] = true
+		inv.mu.Unlock()
+	}
+}
+
+// Start runs the outbox relay and the subscription in the background until
+// Stop. It is a no-op without a broker or when already running.
+func (inv *Invalidator) Start(logger *slog.Logger) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.broker == nil || inv.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	inv.cancel, inv.done = cancel, make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		inv.relay(ctx, logger)
+	}()
+	go func() {
+		defer wg.Done()
+		inv.subscribe(ctx, logger)
+	}()
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(inv.done)
+}
+
+// Stop ends the relay and the subscription and waits for them to exit.
+// Invalidations still in the outbox are not published.
+func (inv *Invalidator) Stop() {
+	inv.mu.Lock()
+	cancel, done := inv.cancel, inv.done
+	inv.cancel, inv.done = nil, nil
+	inv.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// relay publishes the outbox in order, retrying each message until the
+// broker takes it.
+func (inv *Invalidator) relay(ctx context.Context, logger *slog.Logger) {
+	for {
+		var msg Message
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-inv.outbox:
+		}
+		if !inv.publish(ctx, msg, logger) {
+			return
+		}
+		if len(inv.outbox) > 0 {
+			continue
+		}
+		// Caught up: make up for what the outbox dropped meanwhile
+		inv.mu.Lock()
+		lost := inv.overflowed
+		inv.overflowed = make(map[string]bool)
+		inv.mu.Unlock()
+		for cache := range lost {
+			if !inv.publish(ctx, Message{Origin: inv.origin, Cache: cache}, logger) {
+				return
+			}
+		}
+	}
+}
+
+// publish sends msg, backing off while the broker fails. It returns false
+// when ctx ends first.
+func (inv *Invalidator) publish(ctx context.Context, msg Message, logger *slog.Logger) bool {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return true
+	}
+	delay := inv.opts.RetryDelay
+	for {
+		err := inv.broker.Publish(ctx, payload)
+		if err == nil {
+			metrics.ObserveCacheInvalidation(msg.Cache, "sent")
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		metrics.ObserveCacheInvalidation(msg.Cache, "failed")
+		logger.Warn("cache invalidation not published, retrying",
+			slog.String("cache", msg.Cache),
+			slog.Duration("retry_in", delay),
+			slog.String("error", err.Error()))
+		if !sleep(ctx, delay) {
+			return false
+		}
+		delay = min(delay*2, inv.opts.MaxRetryDelay)
+	}
+}
+
+// subscribe applies the other replicas' invalidations, resubscribing after a
+// failure. Messages published while it was not subscribed are lost, so every
+// cache is cleared when it resubscribes.
+func (inv *Invalidator) subscribe(ctx context.Context, logger *slog.Logger) {
+	delay := inv.opts.RetryDelay
+	first := true
+	for {
+		ready := func() {
+			delay = inv.opts.RetryDelay
+			if !first {
+				inv.clearAll()
+			}
+			first = false
+		}
+		err := inv.broker.Subscribe(ctx, ready, inv.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warn("cache invalidation subscription failed, resubscribing",
+				slog.Duration("retry_in", delay),
+				slog.String("error", err.Error()))
+		}
+		// Count a drop after a successful subscription as a gap too
+		first = false
+		if !sleep(ctx, delay) {
+			return
+		}
+		delay = min(delay*2, inv.opts.MaxRetryDelay)
+	}
+}
+
+func (inv *Invalidator) receive(payload []byte) {
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Origin == inv.origin {
+		return // malformed, or ours and already applied
+	}
+	metrics.ObserveCacheInvalidation(msg.Cache, "received")
+	inv.apply(msg.Cache, msg.Keys)
+}
+
+func (inv *Invalidator) apply(cache string, keys []string) {
+	inv.mu.Lock()
+	fn := inv.caches[cache]
+	inv.mu.Unlock()
+	if fn != nil {
+		fn(keys...)
+	}
+}
+
+func (inv *Invalidator) clearAll() {
+	inv.mu.Lock()
+	fns := make([]InvalidateFunc, 0, len(inv.caches))
+	for _, fn := range inv.caches {
+		fns = append(fns, fn)
+	}
+	inv.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,148 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBroker is a pub/sub channel shared by the replicas of a test.
+type memBroker struct {
+	mu   sync.Mutex
+	subs map[int]func([]byte)
+	next int
+	down bool
+}
+
+func (b *memBroker) Publish(_ context.Context, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.down {
+		return errors.New("broker down")
+	}
+	for _, fn := range b.subs {
+		fn(payload)
+	}
+	return nil
+}
+
+func (b *memBroker) Subscribe(ctx context.Context, ready func(), fn func([]byte)) error {
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[int]func([]byte))
+	}
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+	b.mu.Unlock()
+	ready()
+	<-ctx.Done()
+	b.mu.Lock()
+	delete(b.subs, id)
+	b.mu.Unlock()
+	return nil
+}
+
+// cache records what a replica was told to invalidate.
+type cache struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (c *cache) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(keys) == 0 {
+		keys = []string{"*"}
+	}
+	c.keys = append(c.keys, keys...)
+}
+
+func (c *cache) seen() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.keys)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestInvalidator_ReachesOtherReplicas(t *testing.T) {
+	broker := &memBroker{}
+	a, b := New(broker, Options{}), New(broker, Options{})
+	var cacheA, cacheB cache
+	a.Register("tasks", cacheA.invalidate)
+	b.Register("tasks", cacheB.invalidate)
+	a.Start(discard)
+	defer a.Stop()
+	b.Start(discard)
+	defer b.Stop()
+	waitFor(t, func() bool { broker.mu.Lock(); defer broker.mu.Unlock(); return len(broker.subs) == 2 })
+
+	a.Invalidate("tasks", "t1")
+	a.Invalidate("users", "u1") // not cached anywhere
+	waitFor(t, func() bool { return len(cacheB.seen()) == 1 })
+	if got := cacheB.seen(); got[0] != "t1" {
+		t.Fatalf("replica b invalidated %v, want [t1]", got)
+	}
+	// The writer invalidated locally and ignores its own message
+	time.Sleep(20 * time.Millisecond)
+	if got := cacheA.seen(); !slices.Equal(got, []string{"t1"}) {
+		t.Fatalf("replica a invalidated %v, want [t1]", got)
+	}
+}
+
+func TestInvalidator_RetriesAndRecoversFromOverflow(t *testing.T) {
+	broker := &memBroker{down: true}
+	a := New(broker, Options{Outbox: 1, RetryDelay: time.Millisecond})
+	b := New(broker, Options{})
+	var cacheB cache
+	b.Register("tasks", cacheB.invalidate)
+	b.Start(discard)
+	defer b.Stop()
+	a.Start(discard)
+	defer a.Stop()
+
+	// With the broker down the relay holds one message and the outbox one
+	// more; the rest overflow
+	for _, id := range []string{"t1", "t2", "t3", "t4"} {
+		a.Invalidate("tasks", id)
+		time.Sleep(5 * time.Millisecond)
+	}
+	broker.mu.Lock()
+	broker.down = false
+	broker.mu.Unlock()
+
+	// What was queued arrives, then the whole cache is cleared for the rest
+	waitFor(t, func() bool { return slices.Contains(cacheB.seen(), "*") })
+	if got := cacheB.seen(); !slices.Equal(got, []string{"t1", "t2", "*"}) {
+		t.Fatalf("replica b invalidated %v, want [t1 t2 *]", got)
+	}
+}
+
+func TestInvalidator_WithoutBrokerIsLocal(t *testing.T) {
+	inv := New(nil, Options{})
+	var c cache
+	inv.Register("tasks", c.invalidate)
+	inv.Start(discard)
+	defer inv.Stop()
+	inv.Invalidate("tasks")
+	if got := c.seen(); !slices.Equal(got, []string{"*"}) {
+		t.Fatalf("invalidated %v, want [*]", got)
+	}
+}
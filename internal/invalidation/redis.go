@@ -0,0 +1,35 @@
+package invalidation
+
+import (
+	"context"
+
+	"github.com/mikko-kohtala/go-api/internal/redis"
+)
+
+// RedisBroker is a Broker on a Redis pub/sub channel.
+type RedisBroker struct {
+	opts    redis.Options
+	channel string
+	client  *redis.Client
+}
+
+// NewRedisBroker returns a broker publishing on channel of the server at opts.
+func NewRedisBroker(opts redis.Options, channel string) *RedisBroker {
+	return &RedisBroker{opts: opts, channel: channel, client: redis.New(opts)}
+}
+
+// Publish publishes payload on the channel.
+func (b *RedisBroker) Publish(ctx context.Context, payload []byte) error {
+	_, err := b.client.Do(ctx, "PUBLISH", b.channel, payload)
+	return err
+}
+
+// Subscribe subscribes to the channel on a connection of its own.
+func (b *RedisBroker) Subscribe(ctx context.Context, ready func(), fn func(payload []byte)) error {
+	return redis.Subscribe(ctx, b.opts, b.channel, ready, fn)
+}
+
+// Close closes the publishing connection.
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
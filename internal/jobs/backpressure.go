@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Bounds of the Retry-After estimate of an OverloadedError.
+const (
+	minRetryAfter = time.Second
+	maxRetryAfter = time.Minute
+)
+
+// OverloadedError is returned by Admit when the pool should not take on more
+// work. Err is ErrQueueFull or ErrStopped when the job could not be queued at
+// all, and nil when the queue is merely over its high-water mark.
+type OverloadedError struct {
+	Pending    int
+	HighWater  int
+	RetryAfter time.Duration // estimated time for the queue to drain below the mark
+	Err        error
+}
+
+func (e *OverloadedError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("job queue is over its high-water mark (%d of %d pending)", e.Pending, e.HighWater)
+}
+
+func (e *OverloadedError) Unwrap() error { return e.Err }
+
+// SetHighWater sets how many pending jobs Admit accepts; n <= 0 or beyond the
+// queue capacity means the capacity. The room above the mark is kept for
+// retries and replays, which use Submit. Call it before submitting jobs.
+func (p *Pool) SetHighWater(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.highWater = n
+}
+
+// HighWater returns the number of pending jobs above which Admit refuses work.
+func (p *Pool) HighWater() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.highWater <= 0 || p.highWater > cap(p.queue) {
+		return cap(p.queue)
+	}
+	return p.highWater
+}
+
+// Admit is Submit for new work a client is waiting to hear about: it refuses
+// the job with an *OverloadedError once the high-water mark is reached, so the
+// caller can tell the client to come back later instead of accepting work the
+// pool cannot get to.
+func (p *Pool) Admit(ctx context.Context, job Job) error {
+	pending, mark := p.Pending(), p.HighWater()
+	var err error
+	if pending < mark {
+		if err = p.Submit(ctx, job); err == nil {
+			return nil
+		}
+	}
+	reason := "overloaded"
+	switch err {
+	case ErrQueueFull:
+		reason = "queue_full"
+	case ErrStopped:
+		reason = "stopped"
+	}
+	metrics.ObserveJobRejected(job.Name, reason)
+	return &OverloadedError{Pending: pending, HighWater: mark, RetryAfter: p.retryAfter(pending - mark + 1), Err: err}
+}
+
+// retryAfter estimates how long the workers need to run excess more jobs,
+// from the average run time so far.
+func (p *Pool) retryAfter(excess int) time.Duration {
+	avg := time.Duration(p.avgRun.Load())
+	d := avg * time.Duration(max(1, excess)) / time.Duration(p.workers)
+	return min(max(d, minRetryAfter), maxRetryAfter)
+}
+
+// observeRun folds d into the moving average of job run times.
+func (p *Pool) observeRun(d time.Duration) {
+	for {
+		old := p.avgRun.Load()
+		next := int64(d)
+		if old != 0 {
+			next = old + (int64(d)-old)/8
+		}
+		if p.avgRun.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
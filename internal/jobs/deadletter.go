@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// ErrDeadLetterNotFound is returned for an unknown dead letter ID.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// defaultDeadLetterLimit bounds a pool's dead letters; the oldest go first.
+const defaultDeadLetterLimit = 1000
+
+// DeadLetter is a job that failed for good and was set aside so an operator
+// can see why and replay it.
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Job      string    `json:"job"`
+	Detail   string    `json:"detail,omitempty"`
+	Error    string    `json:"error"`
+	Replays  int       `json:"replays"` // times it was replayed before this failure
+	FailedAt time.Time `json:"failed_at"`
+
+	ctx context.Context
+	job Job
+}
+
+// DeadLetters holds the failed jobs of a pool, oldest first.
+type DeadLetters struct {
+	limit int
+
+	mu    sync.Mutex
+	items []*DeadLetter
+}
+
+func newDeadLetters(limit int) *DeadLetters {
+	return &DeadLetters{limit: limit}
+}
+
+func (d *DeadLetters) add(q queued, err error) {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	dl := &DeadLetter{
+		ID:       "dlq_" + hex.EncodeToString(b[:]),
+		Job:      q.job.Name,
+		Detail:   q.job.Detail,
+		Error:    err.Error(),
+		Replays:  q.replays,
+		FailedAt: time.Now().UTC(),
+		ctx:      q.ctx,
+		job:      q.job,
+	}
+	metrics.ObserveDeadLetter(dl.Job)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.items) >= d.limit {
+		d.items = slices.Delete(d.items, 0, len(d.items)-d.limit+1)
+	}
+	d.items = append(d.items, dl)
+}
+
+// List returns the dead letters of the named job, or of every job when job
+// is empty, most recent first.
+func (d *DeadLetters) List(job string) []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DeadLetter, 0, len(d.items))
+	for i := len(d.items) - 1; i >= 0; i-- {
+		if job == "" || d.items[i].Job == job {
+			out = append(out, *d.items[i])
+		}
+	}
+	return out
+}
+
+// Len returns the number of dead letters.
+func (d *DeadLetters) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items)
+}
+
+// Delete removes the dead letter with id.
+func (d *DeadLetters) Delete(id string) error {
+	_, err := d.take(id)
+	return err
+}
+
+// Purge removes the dead letters that failed before cutoff and returns how
+// many it removed, or with dryRun how many it would. Its signature fits a
+// retention.TargetFunc.
+func (d *DeadLetters) Purge(_ context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for n < len(d.items) && d.items[n].FailedAt.Before(cutoff) {
+		n++
+	}
+	if !dryRun {
+		d.items = slices.Delete(d.items, 0, n)
+	}
+	return n, nil
+}
+
+func (d *DeadLetters) take(id string) (*DeadLetter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := slices.IndexFunc(d.items, func(dl *DeadLetter) bool { return dl.ID == id })
+	if i < 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	dl := d.items[i]
+	d.items = slices.Delete(d.items, i, i+1)
+	return dl, nil
+}
+
+func (d *DeadLetters) restore(dl *DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i, _ := slices.BinarySearchFunc(d.items, dl.FailedAt, func(e *DeadLetter, t time.Time) int { return e.FailedAt.Compare(t) })
+	d.items = slices.Insert(d.items, i, dl)
+}
@@ -0,0 +1,205 @@
+// Package jobs runs background work on a fixed pool of workers fed by a
+// bounded queue, so request handlers can hand off slow tasks and return.
+// Jobs that fail are kept as dead letters until they are replayed or purged.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+var (
+	ErrQueueFull = errors.New("job queue is full")
+	ErrStopped   = errors.New("job pool is shut down")
+)
+
+// Job is a unit of background work. Run receives a context that is not
+// cancelled with the submitting request but carries its values, such as the
+// logger (and so its request id).
+type Job struct {
+	Name string
+	// Detail says what this run is about, e.g. which event a webhook job
+	// delivers, when the job ends up in the dead letters.
+	Detail string
+	Run    func(ctx context.Context) error
+}
+
+type queued struct {
+	ctx     context.Context
+	job     Job
+	replays int
+}
+
+// Pool executes jobs on a fixed number of workers. Workers start on the first
+// Submit, so an unused pool costs nothing.
+type Pool struct {
+	workers int
+	queue   chan queued
+
+	startOnce sync.Once
+	mu        sync.RWMutex // guards stopped against concurrent Submit
+	stopped   bool
+	wg        sync.WaitGroup
+	observer  func(ctx context.Context, job string, err error)
+	highWater int
+	dead      *DeadLetters
+	avgRun    atomic.Int64 // moving average of job run time, in ns
+	running   atomic.Int64
+}
+
+// Default is the process-wide pool used by handlers and drained by main.
+var Default = NewPool(4, 256)
+
+// NewPool creates a pool with the given worker count and queue capacity.
+func NewPool(workers, queueSize int) *Pool {
+	return &Pool{
+		workers: max(1, workers),
+		queue:   make(chan queued, max(0, queueSize)),
+		dead:    newDeadLetters(defaultDeadLetterLimit),
+	}
+}
+
+// Submit enqueues job without blocking. It returns ErrQueueFull when the queue
+// is at capacity and ErrStopped after Shutdown. Work refused here is lost, so
+// handlers taking new work from clients use Admit.
+func (p *Pool) Submit(ctx context.Context, job Job) error {
+	return p.enqueue(queued{ctx: jobContext(ctx, job), job: job})
+}
+
+func (p *Pool) enqueue(q queued) error {
+	p.startOnce.Do(p.start)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return ErrStopped
+	}
+	select {
+	case p.queue <- q:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// jobContext detaches ctx from the submitting request, keeping its values,
+// and names the job in its logger.
+func jobContext(ctx context.Context, job Job) context.Context {
+	return pkglogger.IntoContext(context.WithoutCancel(ctx), pkglogger.FromContext(ctx).With(slog.String("job", job.Name)))
+}
+
+// DeadLetters returns the jobs that failed on this pool.
+func (p *Pool) DeadLetters() *DeadLetters {
+	return p.dead
+}
+
+// Bury adds job to the dead letters without running it, for work that could
+// not even be queued, such as a retry dropped because the queue was full.
+func (p *Pool) Bury(ctx context.Context, job Job, err error) {
+	p.dead.add(queued{ctx: jobContext(ctx, job), job: job}, err)
+}
+
+// Replay queues the dead letter with id again, removing it from the dead
+// letters. If it fails again it comes back with its replay count raised.
+func (p *Pool) Replay(id string) error {
+	dl, err := p.dead.take(id)
+	if err != nil {
+		return err
+	}
+	if err := p.enqueue(queued{ctx: dl.ctx, job: dl.job, replays: dl.Replays + 1}); err != nil {
+		p.dead.restore(dl)
+		return err
+	}
+	return nil
+}
+
+// SetObserver registers fn to be called after every job run with the job's
+// context, name and error (nil on success). Call it before submitting jobs.
+func (p *Pool) SetObserver(fn func(ctx context.Context, job string, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observer = fn
+}
+
+// Pending returns the number of queued jobs that have not started yet.
+func (p *Pool) Pending() int {
+	return len(p.queue)
+}
+
+// Running returns the number of jobs being run.
+func (p *Pool) Running() int {
+	return int(p.running.Load())
+}
+
+// Shutdown stops accepting jobs and waits for queued and running jobs to finish
+// or for ctx to end.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.startOnce.Do(p.start)
+
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
+
+func (p *Pool) start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for q := range p.queue {
+		p.run(q)
+	}
+}
+
+func (p *Pool) run(q queued) {
+	l := pkglogger.FromContext(q.ctx)
+	start := time.Now()
+	p.running.Add(1)
+	var err error
+	defer func() {
+		p.running.Add(-1)
+		p.observeRun(time.Since(start))
+		if rec := recover(); rec != nil {
+			l.Error("job panicked", slog.Any("panic", rec))
+			err = fmt.Errorf("job panicked: %v", rec)
+		}
+		if err != nil {
+			p.dead.add(q, err)
+		}
+		p.mu.RLock()
+		observe := p.observer
+		p.mu.RUnlock()
+		if observe != nil {
+			observe(q.ctx, q.job.Name, err)
+		}
+	}()
+	if err = q.job.Run(q.ctx); err != nil {
+		l.Error("job failed", slog.String("error", err.Error()))
+	}
+}
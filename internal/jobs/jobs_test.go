@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_RunsJobsAndDrainsOnShutdown(t *testing.T) {
+	p := NewPool(2, 10)
+	var ran atomic.Int32
+	for i := 0; i < 5; i++ {
+		err := p.Submit(context.Background(), Job{Name: "count", Run: func(ctx context.Context) error {
+			time.Sleep(5 * time.Millisecond)
+			ran.Add(1)
+			return nil
+		}})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if ran.Load() != 5 {
+		t.Fatalf("expected all 5 queued jobs to run before shutdown returned, got %d", ran.Load())
+	}
+	if err := p.Submit(context.Background(), Job{Name: "late", Run: func(context.Context) error { return nil }}); err != ErrStopped {
+		t.Fatalf("expected ErrStopped after shutdown, got %v", err)
+	}
+}
+
+func TestPool_QueueFull(t *testing.T) {
+	p := NewPool(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	block := Job{Name: "block", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}}
+	noop := Job{Name: "noop", Run: func(context.Context) error { return nil }}
+
+	if err := p.Submit(context.Background(), block); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	<-started
+	if err := p.Submit(context.Background(), noop); err != nil {
+		t.Fatalf("expected queued job to fit, got %v", err)
+	}
+	if err := p.Submit(context.Background(), noop); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err == nil {
+		t.Fatalf("expected shutdown to time out while a job is blocked")
+	}
+	close(release)
+}
+
+func TestPool_RecoversPanics(t *testing.T) {
+	p := NewPool(1, 2)
+	_ = p.Submit(context.Background(), Job{Name: "panic", Run: func(context.Context) error { panic("boom") }})
+	done := make(chan struct{})
+	_ = p.Submit(context.Background(), Job{Name: "after", Run: func(context.Context) error { close(done); return nil }})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("worker did not survive a panicking job")
+	}
+	_ = p.Shutdown(context.Background())
+}
+
+func TestPool_DeadLettersAndReplay(t *testing.T) {
+	p := NewPool(1, 4)
+	var calls atomic.Int32
+	done := make(chan struct{}, 2)
+	flaky := Job{Name: "flaky", Detail: "task 1", Run: func(context.Context) error {
+		defer func() { done <- struct{}{} }()
+		if calls.Add(1) == 1 {
+			return errors.New("upstream down")
+		}
+		return nil
+	}}
+	if err := p.Submit(context.Background(), flaky); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	<-done
+	waitFor(t, func() bool { return p.DeadLetters().Len() == 1 })
+
+	dl := p.DeadLetters().List("flaky")
+	if len(dl) != 1 || dl[0].Error != "upstream down" || dl[0].Detail != "task 1" || dl[0].Replays != 0 {
+		t.Fatalf("unexpected dead letters: %+v", dl)
+	}
+	if got := p.DeadLetters().List("other"); len(got) != 0 {
+		t.Fatalf("expected no dead letters for another job, got %+v", got)
+	}
+
+	if err := p.Replay(dl[0].ID); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	<-done
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if calls.Load() != 2 || p.DeadLetters().Len() != 0 {
+		t.Fatalf("expected the replay to run and succeed, calls=%d dead=%d", calls.Load(), p.DeadLetters().Len())
+	}
+	if err := p.Replay(dl[0].ID); err != ErrDeadLetterNotFound {
+		t.Fatalf("expected ErrDeadLetterNotFound, got %v", err)
+	}
+}
+
+func TestDeadLetters_Purge(t *testing.T) {
+	p := NewPool(1, 1)
+	p.Bury(context.Background(), Job{Name: "old"}, errors.New("queue full"))
+	cutoff := time.Now().Add(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	p.Bury(context.Background(), Job{Name: "new"}, errors.New("queue full"))
+
+	if n, _ := p.DeadLetters().Purge(context.Background(), cutoff, true); n != 1 || p.DeadLetters().Len() != 2 {
+		t.Fatalf("dry run: expected 1 to purge and 2 kept, got %d and %d", n, p.DeadLetters().Len())
+	}
+	if n, _ := p.DeadLetters().Purge(context.Background(), cutoff, false); n != 1 {
+		t.Fatalf("expected 1 purged, got %d", n)
+	}
+	if left := p.DeadLetters().List(""); len(left) != 1 || left[0].Job != "new" {
+		t.Fatalf("expected only the newer dead letter, got %+v", left)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool_AdmitRefusesAboveHighWater(t *testing.T) {
+	p := NewPool(1, 4)
+	p.SetHighWater(2)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	block := Job{Name: "block", Run: func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}}
+	noop := Job{Name: "noop", Run: func(context.Context) error { return nil }}
+
+	if err := p.Admit(context.Background(), block); err != nil {
+		t.Fatalf("admit: %v", err)
+	}
+	<-started
+	for i := 0; i < 2; i++ {
+		if err := p.Admit(context.Background(), noop); err != nil {
+			t.Fatalf("expected job %d below the mark to be admitted, got %v", i, err)
+		}
+	}
+	err := p.Admit(context.Background(), noop)
+	var overloaded *OverloadedError
+	if !errors.As(err, &overloaded) || overloaded.Err != nil || overloaded.Pending != 2 || overloaded.RetryAfter < time.Second {
+		t.Fatalf("expected an OverloadedError at the mark, got %#v", err)
+	}
+	if err := p.Submit(context.Background(), noop); err != nil {
+		t.Fatalf("expected Submit to use the room above the mark, got %v", err)
+	}
+
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := p.Admit(context.Background(), noop); !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected an OverloadedError wrapping ErrStopped, got %v", err)
+	}
+}
+
+func TestPool_RunningAndPendingOnShutdown(t *testing.T) {
+	p := NewPool(1, 10)
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		_ = p.Submit(context.Background(), Job{Name: "block", Run: func(context.Context) error {
+			<-release
+			return nil
+		}})
+	}
+	deadline := time.Now().Add(time.Second)
+	for p.Running() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if p.Running() != 1 || p.Pending() != 2 {
+		t.Fatalf("expected 1 running and 2 pending, got %d and %d", p.Running(), p.Pending())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err == nil {
+		t.Fatal("expected shutdown to give up on the blocked jobs")
+	}
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil || p.Running() != 0 || p.Pending() != 0 {
+		t.Fatalf("expected all jobs finished, got %v, %d running, %d pending", err, p.Running(), p.Pending())
+	}
+}
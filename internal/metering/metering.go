@@ -0,0 +1,188 @@
+// Package metering publishes billable usage events on the event bus and rolls
+// them up per hour, consumer and event type for export to a billing system.
+package metering
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+)
+
+// Topic is the event bus topic metering events are published on.
+const Topic = "metering"
+
+// Type is the kind of billable usage an event records.
+type Type string
+
+const (
+	RequestServed Type = "request.served" // Quantity: 1 per request
+	BytesStored   Type = "storage.bytes"  // Quantity: bytes of a completed file
+	JobExecuted   Type = "job.executed"   // Quantity: 1 per background job run
+)
+
+// Event is one billable occurrence. ID is its idempotency key: an event
+// delivered more than once is only counted the first time.
+type Event struct {
+	ID       string    `json:"id"`
+	Type     Type      `json:"type"`
+	Key      string    `json:"key"` // consumer (the user of verified credentials, or anonymous)
+	Quantity int64     `json:"quantity"`
+	Time     time.Time `json:"time"`
+}
+
+// Emit publishes e, assigning a random ID and the current time when unset.
+func Emit(ctx context.Context, bus *events.Bus, e Event) {
+	if bus == nil {
+		return
+	}
+	if e.ID == "" {
+		b := make([]byte, 12)
+		_, _ = rand.Read(b)
+		e.ID = "evt_" + hex.EncodeToString(b)
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	bus.Publish(ctx, Topic, e)
+}
+
+// Rollup totals the events of one type for one consumer within an hour.
+type Rollup struct {
+	Hour     time.Time `json:"hour"` // start of the hour (UTC)
+	Key      string    `json:"key"`
+	Type     Type      `json:"type"`
+	Events   int64     `json:"events"`
+	Quantity int64     `json:"quantity"`
+	Complete bool      `json:"complete"` // the hour has ended; totals are final
+}
+
+type rollupKey struct {
+	hour time.Time
+	key  string
+	typ  Type
+}
+
+// Aggregator consumes metering events and keeps hourly rollups for retain.
+type Aggregator struct {
+	retain time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	rollups map[rollupKey]*Rollup
+	seen    map[string]time.Time // idempotency keys by event hour
+	pruned  time.Time            // hour of the last prune
+}
+
+// NewAggregator creates an aggregator keeping rollups (and idempotency keys)
+// for retain.
+func NewAggregator(retain time.Duration) *Aggregator {
+	return &Aggregator{
+		retain:  retain,
+		now:     time.Now,
+		rollups: make(map[rollupKey]*Rollup),
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// Subscribe attaches the aggregator to bus.
+func (a *Aggregator) Subscribe(bus *events.Bus) (unsubscribe func()) {
+	return bus.Subscribe(Topic, func(_ context.Context, payload any) {
+		if e, ok := payload.(Event); ok {
+			a.Add(e)
+		}
+	})
+}
+
+// Add counts e unless an event with the same ID was already counted. It
+// reports whether e was counted.
+func (a *Aggregator) Add(e Event) bool {
+	hour := e.Time.UTC().Truncate(time.Hour)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pruneLocked()
+	if _, dup := a.seen[e.ID]; dup {
+		return false
+	}
+	if hour.Before(a.now().Add(-a.retain)) {
+		return false // too old to be exported any more
+	}
+	a.seen[e.ID] = hour
+
+	k := rollupKey{hour: hour, key: e.Key, typ: e.Type}
+	r, ok := a.rollups[k]
+	if !ok {
+		r = &Rollup{Hour: hour, Key: e.Key, Type: e.Type}
+		a.rollups[k] = r
+	}
+	r.Events++
+	r.Quantity += e.Quantity
+	return true
+}
+
+// Rollups returns the rollups for hours starting in [from, to), ordered by
+// hour, consumer and type.
+func (a *Aggregator) Rollups(from, to time.Time) []Rollup {
+	current := a.now().UTC().Truncate(time.Hour)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pruneLocked()
+	out := make([]Rollup, 0, len(a.rollups))
+	for _, r := range a.rollups {
+		if r.Hour.Before(from) || !r.Hour.Before(to) {
+			continue
+		}
+		c := *r
+		c.Complete = c.Hour.Before(current)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Hour.Equal(out[j].Hour) {
+			return out[i].Hour.Before(out[j].Hour)
+		}
+		if out[i].Key != out[j].Key {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].Type < out[j].Type
+	})
+	return out
+}
+
+// pruneLocked drops rollups and idempotency keys older than retain, at most
+// once an hour. Callers hold a.mu.
+func (a *Aggregator) pruneLocked() {
+	now := a.now().UTC()
+	if now.Truncate(time.Hour).Equal(a.pruned) {
+		return
+	}
+	a.pruned = now.Truncate(time.Hour)
+	cutoff := now.Add(-a.retain)
+	for k := range a.rollups {
+		if k.hour.Before(cutoff) {
+			delete(a.rollups, k)
+		}
+	}
+	for id, hour := range a.seen {
+		if hour.Before(cutoff) {
+			delete(a.seen, id)
+		}
+	}
+}
+
+// Consumer returns the consumer ctx is attributed to (see quota.WithKey), or
+// usage.Anonymous.
+func Consumer(ctx context.Context) string {
+	if key := quota.KeyFromContext(ctx); key != "" {
+		return key
+	}
+	return usage.Anonymous
+}
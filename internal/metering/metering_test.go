@@ -0,0 +1,39 @@
+package metering
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+)
+
+func TestAggregator_HourlyRollupsWithIdempotency(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	a := NewAggregator(48 * time.Hour)
+	a.now = func() time.Time { return now }
+	bus := events.NewBus()
+	a.Subscribe(bus)
+	ctx := context.Background()
+
+	Emit(ctx, bus, Event{Type: RequestServed, Key: "key_a", Quantity: 1, Time: now.Add(-time.Hour)})
+	Emit(ctx, bus, Event{Type: RequestServed, Key: "key_a", Quantity: 1, Time: now})
+	Emit(ctx, bus, Event{Type: RequestServed, Key: "key_a", Quantity: 1, Time: now})
+	Emit(ctx, bus, Event{ID: "file:1", Type: BytesStored, Key: "key_a", Quantity: 500, Time: now})
+	Emit(ctx, bus, Event{ID: "file:1", Type: BytesStored, Key: "key_a", Quantity: 500, Time: now}) // redelivery
+	Emit(ctx, bus, Event{Type: JobExecuted, Key: "key_a", Quantity: 1, Time: now.Add(-72 * time.Hour)})
+
+	got := a.Rollups(now.Add(-24*time.Hour), now.Add(time.Hour))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 rollups, got %+v", got)
+	}
+	if got[0].Hour != time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC) || !got[0].Complete || got[0].Events != 1 {
+		t.Fatalf("unexpected previous-hour rollup: %+v", got[0])
+	}
+	if got[1].Type != RequestServed || got[1].Events != 2 || got[1].Complete {
+		t.Fatalf("unexpected current-hour requests rollup: %+v", got[1])
+	}
+	if got[2].Type != BytesStored || got[2].Events != 1 || got[2].Quantity != 500 {
+		t.Fatalf("duplicate event was counted: %+v", got[2])
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -16,6 +20,48 @@ var (
 	requestLatency   *prometheus.HistogramVec
 	requestTotal     *prometheus.CounterVec
 	requestsInFlight prometheus.Gauge
+	streamsActive    prometheus.Gauge
+	draining         prometheus.Gauge
+	clientRequests   *prometheus.CounterVec
+	corsRejected     *prometheus.CounterVec
+	corsPreflight    *prometheus.CounterVec
+	webhookDelivery  *prometheus.CounterVec
+	deadLettered     *prometheus.CounterVec
+	jobsRejected     *prometheus.CounterVec
+	invalidations    *prometheus.CounterVec
+	retentionRecords *prometheus.CounterVec
+	retentionRuns    *prometheus.CounterVec
+	retentionLastRun *prometheus.GaugeVec
+	canaryRequests   *prometheus.CounterVec
+	canaryLatency    *prometheus.HistogramVec
+	goroutinePanics  *prometheus.CounterVec
+	clientGone       *prometheus.CounterVec
+	resourceOps      *prometheus.CounterVec
+	handlerErrors    *prometheus.CounterVec
+	outboundRequests *prometheus.CounterVec
+	outboundLatency  *prometheus.HistogramVec
+	outboundRetries  *prometheus.CounterVec
+	abandoned        *prometheus.CounterVec
+	admissions       *prometheus.CounterVec
+	admissionWait    *prometheus.HistogramVec
+	dnsLookups       *prometheus.CounterVec
+	dialFailures     *prometheus.CounterVec
+	tokenRefreshes   *prometheus.CounterVec
+	compressionCache *prometheus.CounterVec
+	dependencyUp     *prometheus.GaugeVec
+	featureDegraded  *prometheus.GaugeVec
+	degradedServed   *prometheus.CounterVec
+	bodyBytes        *prometheus.CounterVec
+	decompression    prometheus.Histogram
+	decompressCutOff prometheus.Counter
+	traceSpans       *prometheus.CounterVec
+	largeResponses   *prometheus.CounterVec
+	secretRefreshes  *prometheus.CounterVec
+	rateLimitKeys    *prometheus.GaugeVec
+	rateLimitEvicted *prometheus.CounterVec
+
+	// inFlight mirrors requestsInFlight so shutdown can report drain progress.
+	inFlight atomic.Int64
 )
 
 func ensureMetrics() {
@@ -27,7 +73,7 @@ func ensureMetrics() {
 				Help:      "Duration of HTTP requests.",
 				Buckets:   prometheus.DefBuckets,
 			},
-			[]string{"method", "route", "status"},
+			[]string{"method", "route", "operation", "status", "authenticated"},
 		)
 
 		requestTotal = prometheus.NewCounterVec(
@@ -36,7 +82,7 @@ func ensureMetrics() {
 				Name:      "requests_total",
 				Help:      "Total number of HTTP requests processed.",
 			},
-			[]string{"method", "route", "status"},
+			[]string{"method", "route", "operation", "status", "authenticated"},
 		)
 
 		requestsInFlight = prometheus.NewGauge(
@@ -47,7 +93,363 @@ func ensureMetrics() {
 			},
 		)
 
-		prometheus.MustRegister(requestLatency, requestTotal, requestsInFlight)
+		streamsActive = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: "api",
+				Name:      "streams_active",
+				Help:      "Current number of open streaming connections (SSE, WebSocket).",
+			},
+		)
+
+		draining = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: "api",
+				Name:      "draining",
+				Help:      "Set to 1 while the server is draining connections during shutdown.",
+			},
+		)
+
+		clientRequests = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "requests_by_client_total",
+				Help:      "Total number of HTTP requests by client class (browser, bot, cli, unknown).",
+			},
+			[]string{"class"},
+		)
+
+		corsRejected = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "cors_rejected_total",
+				Help:      "Total number of cross-origin requests from origins not allowed by the CORS policy.",
+			},
+			[]string{"policy"},
+		)
+
+		corsPreflight = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "cors_preflight_total",
+				Help:      "Total number of CORS preflight requests by policy and origin decision (allowed, rejected).",
+			},
+			[]string{"policy", "outcome"},
+		)
+
+		webhookDelivery = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "webhook_deliveries_total",
+				Help:      "Total number of webhook delivery attempts by event type and outcome (delivered, retried, failed).",
+			},
+			[]string{"event", "outcome"},
+		)
+
+		deadLettered = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "jobs_dead_lettered_total",
+				Help:      "Total number of background jobs moved to the dead letters, by job name.",
+			},
+			[]string{"job"},
+		)
+
+		jobsRejected = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "jobs_rejected_total",
+				Help:      "Total number of background jobs refused for backpressure, by job name and reason (overloaded, queue_full, stopped).",
+			},
+			[]string{"job", "reason"},
+		)
+
+		invalidations = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "cache_invalidations_total",
+				Help:      "Total number of cache invalidations shared across replicas, by cache and outcome (sent, received, dropped, failed).",
+			},
+			[]string{"cache", "outcome"},
+		)
+
+		retentionRecords = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "retention_records_total",
+				Help:      "Total number of records purged or anonymized by retention policies (or that would be, in dry-run mode).",
+			},
+			[]string{"policy", "action", "dry_run"},
+		)
+
+		retentionRuns = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "retention_runs_total",
+				Help:      "Total number of retention policy runs by result (ok, error).",
+			},
+			[]string{"policy", "result"},
+		)
+
+		retentionLastRun = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "api",
+				Name:      "retention_last_success_timestamp_seconds",
+				Help:      "Unix time of the last successful run of each retention policy.",
+			},
+			[]string{"policy"},
+		)
+
+		canaryRequests = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "canary_requests_total",
+				Help:      "Total number of requests subject to canary routing by variant (stable, canary) and status class.",
+			},
+			[]string{"variant", "status_class"},
+		)
+
+		canaryLatency = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "api",
+				Name:      "canary_request_duration_seconds",
+				Help:      "Duration of requests subject to canary routing by variant.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"variant"},
+		)
+
+		goroutinePanics = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "goroutine_panics_total",
+				Help:      "Total number of panics recovered in background goroutines started with safego.",
+			},
+			[]string{"goroutine"},
+		)
+
+		clientGone = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "client_disconnects_total",
+				Help:      "Total number of responses not written because the client disconnected (canceled) or the request timed out (timeout).",
+			},
+			[]string{"reason"},
+		)
+
+		resourceOps = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "resource_operations_total",
+				Help:      "Total number of CRUD operations on resources registered with the resource package, by status class.",
+			},
+			[]string{"resource", "operation", "status_class"},
+		)
+
+		handlerErrors = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "handler_errors_total",
+				Help:      "Total number of errors returned by handlers, by operation, answered status and kind (abort, mapped, validation, refused, unexpected).",
+			},
+			[]string{"operation", "status", "kind"},
+		)
+
+		outboundRequests = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "outbound_requests_total",
+				Help:      "Total number of outbound HTTP requests by host and status class (error when no response was received).",
+			},
+			[]string{"host", "status_class"},
+		)
+
+		outboundLatency = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "api",
+				Name:      "outbound_request_duration_seconds",
+				Help:      "Duration of outbound HTTP requests until response headers by host.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"host"},
+		)
+
+		abandoned = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "requests_abandoned_total",
+				Help:      "Total number of requests whose client disconnected before the handler finished, by the stage where the handler stopped.",
+			},
+			[]string{"route", "stage"},
+		)
+
+		admissions = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "admission_requests_total",
+				Help:      "Total number of requests seen by the concurrency limiter by priority class and outcome (admitted, queue_full, timeout or abandoned).",
+			},
+			[]string{"class", "outcome"},
+		)
+
+		admissionWait = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "api",
+				Name:      "admission_wait_seconds",
+				Help:      "Time requests waited for a concurrency limiter slot by priority class.",
+				Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+			},
+			[]string{"class"},
+		)
+
+		dnsLookups = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "outbound_dns_lookups_total",
+				Help:      "Total number of outbound DNS cache lookups by result (hit, miss, stale or error).",
+			},
+			[]string{"result"},
+		)
+
+		dialFailures = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "outbound_dial_failures_total",
+				Help:      "Total number of failed outbound connection attempts by host; the failing instance is skipped for a cooldown.",
+			},
+			[]string{"host"},
+		)
+
+		tokenRefreshes = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "service_token_refreshes_total",
+				Help:      "Total number of service-to-service token fetches by source and result.",
+			},
+			[]string{"source", "result"},
+		)
+
+		compressionCache = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "compression_cache_total",
+				Help:      "Total number of responses served through the compression cache by result (hit or miss).",
+			},
+			[]string{"result"},
+		)
+
+		outboundRetries = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "outbound_retries_total",
+				Help:      "Total number of outbound requests sent again by host and reason (error, or the status code).",
+			},
+			[]string{"host", "reason"},
+		)
+
+		dependencyUp = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "api",
+				Name:      "dependency_up",
+				Help:      "Whether a dependency passed its last health check (1) or failed it (0).",
+			},
+			[]string{"dependency"},
+		)
+
+		featureDegraded = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "api",
+				Name:      "feature_degraded",
+				Help:      "Set to 1 while a feature is degraded because a dependency it needs is down.",
+			},
+			[]string{"feature"},
+		)
+
+		degradedServed = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "degraded_responses_total",
+				Help:      "Total number of requests to a degraded feature by outcome (cached or rejected).",
+			},
+			[]string{"feature", "outcome"},
+		)
+
+		bodyBytes = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "body_bytes_total",
+				Help:      "Total bytes of request and response bodies by direction and form (wire as transferred, decoded as read or written by handlers).",
+			},
+			[]string{"direction", "form"},
+		)
+
+		decompression = prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "api",
+				Name:      "request_decompression_ratio",
+				Help:      "Ratio of decoded to wire size of compressed request bodies.",
+				Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+			},
+		)
+
+		decompressCutOff = prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "request_decompression_rejected_total",
+				Help:      "Total number of compressed request bodies cut off for decoding to more than DECOMPRESSION_MAX_RATIO times their size.",
+			},
+		)
+
+		traceSpans = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "trace_spans_total",
+				Help:      "Total number of finished trace spans by export outcome (exported, failed or dropped).",
+			},
+			[]string{"outcome"},
+		)
+
+		largeResponses = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "large_responses_total",
+				Help:      "Total number of responses over the response guard's thresholds by operation, limit exceeded (items or bytes) and action (warned or truncated).",
+			},
+			[]string{"operation", "limit", "action"},
+		)
+
+		secretRefreshes = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "secret_refreshes_total",
+				Help:      "Total number of secret lookups past the cache by result (ok, not_found or error).",
+			},
+			[]string{"result"},
+		)
+
+		rateLimitKeys = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "api",
+				Name:      "ratelimit_keys",
+				Help:      "Number of client keys tracked by the in-process counters of each rate limiter.",
+			},
+			[]string{"limiter"},
+		)
+
+		rateLimitEvicted = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "api",
+				Name:      "ratelimit_evictions_total",
+				Help:      "Total number of client keys dropped by the in-process rate limit counters by limiter and reason (expired or capacity).",
+			},
+			[]string{"limiter", "reason"},
+		)
+
+		prometheus.MustRegister(requestLatency, requestTotal, requestsInFlight, streamsActive, draining, clientRequests, corsRejected, corsPreflight, webhookDelivery, deadLettered, jobsRejected, invalidations,
+			retentionRecords, retentionRuns, retentionLastRun, canaryRequests, canaryLatency, goroutinePanics, clientGone, resourceOps, handlerErrors,
+			outboundRequests, outboundLatency, abandoned, admissions, admissionWait,
+			dnsLookups, dialFailures, tokenRefreshes, compressionCache, outboundRetries, dependencyUp, featureDegraded, degradedServed,
+			bodyBytes, decompression, decompressCutOff, traceSpans, largeResponses, secretRefreshes,
+			rateLimitKeys, rateLimitEvicted)
 	})
 }
 
@@ -57,42 +459,316 @@ func Middleware(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		ww := respwriter.Wrap(w)
 
 		requestsInFlight.Inc()
-		defer requestsInFlight.Dec()
+		inFlight.Add(1)
+		defer func() {
+			requestsInFlight.Dec()
+			inFlight.Add(-1)
+		}()
 
-		next.ServeHTTP(recorder, r)
+		next.ServeHTTP(ww, r)
 
-		route := chi.RouteContext(r.Context())
-		pattern := r.URL.Path
-		if route != nil {
-			if rp := route.RoutePattern(); rp != "" {
-				pattern = rp
-			}
+		pattern := routePattern(r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
 		}
-
-		labels := []string{r.Method, pattern, strconv.Itoa(recorder.status)}
+		labels := []string{r.Method, pattern, operationName(r.Method, pattern), strconv.Itoa(status), strconv.FormatBool(requestctx.Authenticated(r.Context()))}
 
 		duration := time.Since(start).Seconds()
-		requestLatency.WithLabelValues(labels...).Observe(duration)
+		observeWithHandler(requestLatency.WithLabelValues(labels...), duration, requestctx.Handler(r.Context()))
 		requestTotal.WithLabelValues(labels...).Inc()
 	})
 }
 
-// Handler exposes the Prometheus metrics endpoint.
-func Handler() http.Handler {
+// observeWithHandler observes v with the handler that served the request as
+// its exemplar, so a latency point leads to the handler even when several
+// serve one route, e.g. a canary's. Exemplars are exposed in the OpenMetrics
+// format only.
+func observeWithHandler(o prometheus.Observer, v float64, handler string) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && handler != "" && len(handler) <= 120 {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"handler": handler})
+		return
+	}
+	o.Observe(v)
+}
+
+// routePattern returns the chi route pattern r matched, or its path when it
+// matched none.
+func routePattern(r *http.Request) string {
+	if route := chi.RouteContext(r.Context()); route != nil {
+		if rp := route.RoutePattern(); rp != "" {
+			return rp
+		}
+	}
+	return r.URL.Path
+}
+
+// operationName returns the declared name of the route; unmatched requests
+// have none.
+func operationName(method, pattern string) string {
+	operation, _ := routemeta.Default.Lookup(method, pattern)
+	return operation.Name
+}
+
+// SetStreamsActive records the number of open streaming connections.
+func SetStreamsActive(n int) {
+	ensureMetrics()
+	streamsActive.Set(float64(n))
+}
+
+// InFlight returns the number of requests currently being served.
+func InFlight() int64 {
+	return inFlight.Load()
+}
+
+// SetDraining flags whether the server is draining connections.
+func SetDraining(on bool) {
+	ensureMetrics()
+	if on {
+		draining.Set(1)
+		return
+	}
+	draining.Set(0)
+}
+
+// ObserveClientClass counts a request from the given client class.
+func ObserveClientClass(class string) {
+	ensureMetrics()
+	clientRequests.WithLabelValues(class).Inc()
+}
+
+// ObserveCORSRejected counts a request whose origin was refused by the named CORS policy.
+func ObserveCORSRejected(policy string) {
+	ensureMetrics()
+	corsRejected.WithLabelValues(policy).Inc()
+}
+
+// ObserveCORSPreflight counts a preflight request answered by the named CORS
+// policy; outcome is "allowed" or "rejected".
+func ObserveCORSPreflight(policy, outcome string) {
+	ensureMetrics()
+	corsPreflight.WithLabelValues(policy, outcome).Inc()
+}
+
+// ObserveWebhookDelivery counts a webhook delivery attempt; outcome is
+// "delivered", "retried" or "failed".
+func ObserveWebhookDelivery(event, outcome string) {
+	ensureMetrics()
+	webhookDelivery.WithLabelValues(event, outcome).Inc()
+}
+
+// ObserveDeadLetter counts a job that failed for good.
+func ObserveDeadLetter(job string) {
+	ensureMetrics()
+	deadLettered.WithLabelValues(job).Inc()
+}
+
+// ObserveJobRejected counts a job refused because the pool is overloaded.
+func ObserveJobRejected(job, reason string) {
+	ensureMetrics()
+	jobsRejected.WithLabelValues(job, reason).Inc()
+}
+
+// ObserveCacheInvalidation counts an invalidation of cache sent to or received
+// from the other replicas.
+func ObserveCacheInvalidation(cache, outcome string) {
+	ensureMetrics()
+	invalidations.WithLabelValues(cache, outcome).Inc()
+}
+
+// ObserveRetention records one run of a retention policy.
+func ObserveRetention(policy, action string, dryRun bool, affected int, err error) {
+	ensureMetrics()
+	if err != nil {
+		retentionRuns.WithLabelValues(policy, "error").Inc()
+		return
+	}
+	retentionRuns.WithLabelValues(policy, "ok").Inc()
+	retentionRecords.WithLabelValues(policy, action, strconv.FormatBool(dryRun)).Add(float64(affected))
+	retentionLastRun.WithLabelValues(policy).SetToCurrentTime()
+}
+
+// ObserveCanary records a request served by a canary routing variant.
+func ObserveCanary(variant string, status int, d time.Duration) {
+	ensureMetrics()
+	canaryRequests.WithLabelValues(variant, strconv.Itoa(status/100)+"xx").Inc()
+	canaryLatency.WithLabelValues(variant).Observe(d.Seconds())
+}
+
+// ObserveGoroutinePanic counts a panic recovered in the named background goroutine.
+func ObserveGoroutinePanic(name string) {
+	ensureMetrics()
+	goroutinePanics.WithLabelValues(name).Inc()
+}
+
+// ObserveClientDisconnect counts a response skipped because the request's context was done.
+func ObserveClientDisconnect(reason string) {
+	ensureMetrics()
+	clientGone.WithLabelValues(reason).Inc()
+}
+
+// ObserveResourceOperation counts a CRUD operation (list, get, create, update
+// or delete) on resource that was answered with status.
+func ObserveResourceOperation(resource, operation string, status int) {
+	ensureMetrics()
+	resourceOps.WithLabelValues(resource, operation, strconv.Itoa(status/100)+"xx").Inc()
+}
+
+// ObserveHandlerError counts an error the handler of r returned, answered
+// with status.
+func ObserveHandlerError(r *http.Request, status int, kind string) {
+	ensureMetrics()
+	handlerErrors.WithLabelValues(operationName(r.Method, routePattern(r)), strconv.Itoa(status), kind).Inc()
+}
+
+// ObserveOutbound records an outbound request to host; status 0 means the
+// request failed without a response.
+func ObserveOutbound(host string, status int, d time.Duration) {
+	ensureMetrics()
+	class := "error"
+	if status > 0 {
+		class = strconv.Itoa(status/100) + "xx"
+	}
+	outboundRequests.WithLabelValues(host, class).Inc()
+	outboundLatency.WithLabelValues(host).Observe(d.Seconds())
+}
+
+// ObserveAbandoned counts a request on route abandoned by its client at stage.
+func ObserveAbandoned(route, stage string) {
+	ensureMetrics()
+	abandoned.WithLabelValues(route, stage).Inc()
+}
+
+// ObserveAdmission records how a request of class left the concurrency
+// limiter queue and how long it waited.
+func ObserveAdmission(class, outcome string, wait time.Duration) {
+	ensureMetrics()
+	admissions.WithLabelValues(class, outcome).Inc()
+	admissionWait.WithLabelValues(class).Observe(wait.Seconds())
+}
+
+// ObserveDNSLookup counts an outbound DNS cache lookup by result.
+func ObserveDNSLookup(result string) {
+	ensureMetrics()
+	dnsLookups.WithLabelValues(result).Inc()
+}
+
+// ObserveDialFailure counts a failed connection attempt to an instance of host.
+func ObserveDialFailure(host string) {
+	ensureMetrics()
+	dialFailures.WithLabelValues(host).Inc()
+}
+
+// ObserveTokenRefresh counts a token fetch of the named source.
+func ObserveTokenRefresh(source string, err error) {
+	ensureMetrics()
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	tokenRefreshes.WithLabelValues(source, result).Inc()
+}
+
+// ObserveCompressionCache counts a response served through the compression cache.
+func ObserveCompressionCache(result string) {
+	ensureMetrics()
+	compressionCache.WithLabelValues(result).Inc()
+}
+
+// ObserveOutboundRetry counts an outbound request sent again to host.
+func ObserveOutboundRetry(host, reason string) {
+	ensureMetrics()
+	outboundRetries.WithLabelValues(host, reason).Inc()
+}
+
+// SetDependencyUp records whether a dependency passed its last health check.
+func SetDependencyUp(dependency string, up bool) {
+	ensureMetrics()
+	if up {
+		dependencyUp.WithLabelValues(dependency).Set(1)
+		return
+	}
+	dependencyUp.WithLabelValues(dependency).Set(0)
+}
+
+// SetFeatureDegraded flags whether a feature is degraded.
+func SetFeatureDegraded(feature string, on bool) {
+	ensureMetrics()
+	if on {
+		featureDegraded.WithLabelValues(feature).Set(1)
+		return
+	}
+	featureDegraded.WithLabelValues(feature).Set(0)
+}
+
+// ObserveDegradedResponse counts a request to a degraded feature.
+func ObserveDegradedResponse(feature, outcome string) {
+	ensureMetrics()
+	degradedServed.WithLabelValues(feature, outcome).Inc()
+}
+
+// ObserveBodyBytes counts the bytes of a request or response body as
+// transferred on the wire and as decoded for or written by its handler.
+func ObserveBodyBytes(direction string, wire, decoded int64) {
+	ensureMetrics()
+	bodyBytes.WithLabelValues(direction, "wire").Add(float64(wire))
+	bodyBytes.WithLabelValues(direction, "decoded").Add(float64(decoded))
+}
+
+// ObserveDecompression records the ratio of a compressed request body and
+// whether it was cut off for exceeding the allowed ratio.
+func ObserveDecompression(wire, decoded int64, rejected bool) {
+	ensureMetrics()
+	if wire > 0 {
+		decompression.Observe(float64(decoded) / float64(wire))
+	}
+	if rejected {
+		decompressCutOff.Inc()
+	}
+}
+
+// ObserveSpans counts n trace spans by export outcome.
+func ObserveSpans(outcome string, n int) {
 	ensureMetrics()
-	return promhttp.Handler()
+	traceSpans.WithLabelValues(outcome).Add(float64(n))
 }
 
-// statusRecorder captures the status code written by a handler.
-type statusRecorder struct {
-	http.ResponseWriter
-	status int
+// ObserveLargeResponse counts a response to r over the response guard's
+// limit (items or bytes) and what was done about it, by r's operation.
+func ObserveLargeResponse(r *http.Request, limit, action string) {
+	ensureMetrics()
+	largeResponses.WithLabelValues(operationName(r.Method, routePattern(r)), limit, action).Inc()
 }
 
-func (s *statusRecorder) WriteHeader(code int) {
-	s.status = code
-	s.ResponseWriter.WriteHeader(code)
+// ObserveSecretRefresh counts a secret read from its source once its cached
+// value expired.
+func ObserveSecretRefresh(result string) {
+	ensureMetrics()
+	secretRefreshes.WithLabelValues(result).Inc()
+}
+
+// SetRateLimitKeys records the number of client keys the in-process counters
+// of limiter track.
+func SetRateLimitKeys(limiter string, n int) {
+	ensureMetrics()
+	rateLimitKeys.WithLabelValues(limiter).Set(float64(n))
+}
+
+// ObserveRateLimitEvictions counts n client keys dropped by the in-process
+// counters of limiter, because their windows passed or to stay under
+// RATE_LIMIT_MAX_KEYS.
+func ObserveRateLimitEvictions(limiter, reason string, n int) {
+	ensureMetrics()
+	rateLimitEvicted.WithLabelValues(limiter, reason).Add(float64(n))
+}
+
+// Handler exposes the Prometheus metrics endpoint, in the OpenMetrics format
+// with exemplars to scrapers asking for it.
+func Handler() http.Handler {
+	ensureMetrics()
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 }
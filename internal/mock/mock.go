@@ -0,0 +1,261 @@
+// Package mock serves example responses generated from the API's Swagger
+// (OpenAPI 2.0) document, so clients can be built against the contract before
+// the endpoints exist. Documented examples are used where present; otherwise
+// an example is derived from the response schema.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/response"
+)
+
+// Spec is the subset of a Swagger 2.0 document the mock server uses.
+type Spec struct {
+	BasePath    string                          `json:"basePath"`
+	Paths       map[string]map[string]Operation `json:"paths"`
+	Definitions map[string]*Schema              `json:"definitions"`
+}
+
+// Operation is one method of a path.
+type Operation struct {
+	Summary   string              `json:"summary"`
+	Responses map[string]Response `json:"responses"`
+}
+
+// Response is a documented response of an operation.
+type Response struct {
+	Description string         `json:"description"`
+	Schema      *Schema        `json:"schema"`
+	Examples    map[string]any `json:"examples"`
+}
+
+// Schema is a JSON schema as used by Swagger 2.0.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Items                *Schema            `json:"items"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	AllOf                []*Schema          `json:"allOf"`
+	Example              any                `json:"example"`
+	Enum                 []any              `json:"enum"`
+}
+
+// ParseSpec parses a Swagger 2.0 JSON document.
+func ParseSpec(doc []byte) (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(doc, &s); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	if len(s.Paths) == 0 {
+		return nil, fmt.Errorf("parse spec: no paths")
+	}
+	return &s, nil
+}
+
+// Options injects latency and errors into the mock responses.
+type Options struct {
+	Latency   time.Duration // added to every response
+	Jitter    time.Duration // up to this much more, at random
+	ErrorRate float64       // share of requests answered with a documented error
+}
+
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+
+// Handler returns a router serving every operation of spec. A request may
+// pick a documented response with "Prefer: code=404".
+func Handler(spec *Spec, opts Options) http.Handler {
+	r := chi.NewRouter()
+	base := strings.TrimSuffix(spec.BasePath, "/")
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		for method, op := range spec.Paths[p] {
+			method = strings.ToUpper(method)
+			if !slices.Contains(methods, method) {
+				continue
+			}
+			r.Method(method, base+p, operationHandler(spec, op, opts))
+		}
+	}
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		response.Error(w, r, http.StatusNotFound, "not_found", "No such operation in the API specification", nil)
+	})
+	return r
+}
+
+func operationHandler(spec *Spec, op Operation, opts Options) http.HandlerFunc {
+	success, failures := statuses(op)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sleep(r.Context(), opts) {
+			return
+		}
+		status := success
+		if code, ok := preferredCode(r); ok {
+			if _, documented := op.Responses[strconv.Itoa(code)]; !documented {
+				response.Error(w, r, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Response %d is not documented for this operation", code), nil)
+				return
+			}
+			status = code
+		} else if opts.ErrorRate > 0 && rand.Float64() < opts.ErrorRate {
+			status = http.StatusInternalServerError
+			if len(failures) > 0 {
+				status = failures[rand.IntN(len(failures))]
+			}
+		}
+
+		resp := op.Responses[strconv.Itoa(status)]
+		w.Header().Set("X-Mock", "true")
+		if status >= 400 && resp.Schema == nil && resp.Examples == nil {
+			response.Error(w, r, status, "mock_error", http.StatusText(status), nil)
+			return
+		}
+		if status == http.StatusNoContent || status == http.StatusNotModified || (resp.Schema == nil && resp.Examples == nil) {
+			response.NoBody(w, r, status)
+			return
+		}
+		response.JSON(w, r, status, Example(spec, resp))
+	}
+}
+
+// statuses returns the first documented 2xx status (200 when there is none)
+// and the documented error statuses.
+func statuses(op Operation) (int, []int) {
+	success := 0
+	var failures []int
+	for code := range op.Responses {
+		n, err := strconv.Atoi(code)
+		if err != nil {
+			continue
+		}
+		switch {
+		case n/100 == 2 && (success == 0 || n < success):
+			success = n
+		case n >= 400:
+			failures = append(failures, n)
+		}
+	}
+	if success == 0 {
+		success = http.StatusOK
+	}
+	sort.Ints(failures)
+	return success, failures
+}
+
+// preferredCode reads "Prefer: code=NNN".
+func preferredCode(r *http.Request) (int, bool) {
+	for _, part := range strings.Split(r.Header.Get("Prefer"), ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(part), "code="); ok {
+			n, err := strconv.Atoi(v)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// sleep waits for the configured latency; false when the request ended first.
+func sleep(ctx context.Context, opts Options) bool {
+	d := opts.Latency
+	if opts.Jitter > 0 {
+		d += rand.N(opts.Jitter)
+	}
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Example returns the documented JSON example of resp, or one generated from
+// its schema.
+func Example(spec *Spec, resp Response) any {
+	if ex, ok := resp.Examples["application/json"]; ok {
+		return ex
+	}
+	return exampleOf(spec, resp.Schema, 0)
+}
+
+// maxDepth stops recursive schemas.
+const maxDepth = 8
+
+func exampleOf(spec *Spec, s *Schema, depth int) any {
+	if s == nil || depth > maxDepth {
+		return nil
+	}
+	if s.Ref != "" {
+		return exampleOf(spec, spec.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")], depth+1)
+	}
+	if s.Example != nil {
+		return s.Example
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+	if len(s.AllOf) > 0 {
+		merged := map[string]any{}
+		for _, part := range s.AllOf {
+			if m, ok := exampleOf(spec, part, depth+1).(map[string]any); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "2026-01-01T00:00:00Z"
+		case "date":
+			return "2026-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		}
+		return "string"
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return true
+	case "array":
+		return []any{exampleOf(spec, s.Items, depth+1)}
+	}
+	// Objects, including those typed only by their properties
+	out := map[string]any{}
+	for name, prop := range s.Properties {
+		out[name] = exampleOf(spec, prop, depth+1)
+	}
+	if len(s.AdditionalProperties) > 0 && len(s.Properties) == 0 {
+		var extra Schema
+		if json.Unmarshal(s.AdditionalProperties, &extra) == nil && (extra.Type != "" || extra.Ref != "") {
+			out["key"] = exampleOf(spec, &extra, depth+1)
+		}
+	}
+	return out
+}
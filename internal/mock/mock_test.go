@@ -0,0 +1,88 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/docs"
+)
+
+const testSpec = `{
+  "basePath": "/",
+  "paths": {
+    "/api/v1/tasks/{id}": {
+      "get": {
+        "responses": {
+          "200": {"description": "OK", "schema": {"$ref": "#/definitions/Task"}},
+          "404": {"description": "Not Found", "schema": {"type": "object", "additionalProperties": true}}
+        }
+      },
+      "delete": {"responses": {"204": {"description": "No Content"}}}
+    }
+  },
+  "definitions": {
+    "Task": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "string", "example": "tsk_123"},
+        "status": {"type": "string", "enum": ["open", "done"]},
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "created_at": {"type": "string", "format": "date-time"}
+      }
+    }
+  }
+}`
+
+func TestHandler_ServesSchemaExamples(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := Handler(spec, Options{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/abc", nil))
+	var task map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &task)
+	if rec.Code != http.StatusOK || task["id"] != "tsk_123" || task["status"] != "open" || task["created_at"] != "2026-01-01T00:00:00Z" {
+		t.Fatalf("unexpected example: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/tasks/abc", nil))
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty 204, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_InjectsErrors(t *testing.T) {
+	spec, _ := ParseSpec([]byte(testSpec))
+
+	rec := httptest.NewRecorder()
+	Handler(spec, Options{ErrorRate: 1}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/abc", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the documented error, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/abc", nil)
+	req.Header.Set("Prefer", "code=418")
+	rec = httptest.NewRecorder()
+	Handler(spec, Options{}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected undocumented preferred codes to be refused, got %d", rec.Code)
+	}
+}
+
+func TestParseSpec_BuiltInDocument(t *testing.T) {
+	spec, err := ParseSpec([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		t.Fatalf("the built-in document must be servable: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	Handler(spec, Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a mock user list, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,172 @@
+// Package notify delivers user notifications over email, SMS, push and Slack.
+// Callers describe what happened (a template and its data); the package decides
+// which channels to use from the user's preferences, renders the message and
+// hands it to the channel's provider on the background job pool, retrying
+// failed deliveries.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Channel is a delivery transport.
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+	ChannelSlack Channel = "slack"
+)
+
+// Channels lists every supported channel.
+var Channels = []Channel{ChannelEmail, ChannelSMS, ChannelPush, ChannelSlack}
+
+// Recipient holds the addresses a user can be reached at; empty fields mean the
+// channel is unavailable for that user.
+type Recipient struct {
+	Email     string
+	Phone     string
+	PushToken string
+	SlackID   string
+}
+
+// Address returns the recipient's address on channel c.
+func (r Recipient) Address(c Channel) string {
+	switch c {
+	case ChannelEmail:
+		return r.Email
+	case ChannelSMS:
+		return r.Phone
+	case ChannelPush:
+		return r.PushToken
+	case ChannelSlack:
+		return r.SlackID
+	}
+	return ""
+}
+
+// Notification is a transport-agnostic request to notify a user.
+type Notification struct {
+	UserID   string
+	To       Recipient
+	Template string
+	Data     map[string]any
+}
+
+// Message is a rendered notification for a single channel.
+type Message struct {
+	Channel Channel
+	To      string
+	Subject string
+	Body    string
+}
+
+// Provider sends messages over one channel.
+type Provider interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Notifier emits notifications. Notify returns once deliveries are queued.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Discard is a Notifier that drops every notification.
+var Discard Notifier = discard{}
+
+type discard struct{}
+
+func (discard) Notify(context.Context, Notification) error { return nil }
+
+// Options configure a Service.
+type Options struct {
+	MaxAttempts int           // delivery attempts per message, default 3
+	RetryDelay  time.Duration // delay before the first retry, doubled for each further attempt; default 1s
+}
+
+// Service is the Notifier implementation used by the application.
+type Service struct {
+	providers map[Channel]Provider
+	templates *Templates
+	prefs     PreferenceStore
+	pool      *jobs.Pool
+	opts      Options
+}
+
+// NewService creates a notifier delivering through providers on pool.
+// Channels without a provider are skipped.
+func NewService(providers map[Channel]Provider, templates *Templates, prefs PreferenceStore, pool *jobs.Pool, opts Options) *Service {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+	return &Service{providers: providers, templates: templates, prefs: prefs, pool: pool, opts: opts}
+}
+
+// Notify renders n for every channel the user has enabled and can be reached
+// on, and queues a delivery for each.
+func (s *Service) Notify(ctx context.Context, n Notification) error {
+	prefs, err := s.prefs.Get(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range Channels {
+		to := n.To.Address(c)
+		if to == "" || !prefs.Enabled(c) || s.providers[c] == nil {
+			continue
+		}
+		msg, err := s.templates.Render(n.Template, c, n.Data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		msg.To = to
+		if err := s.submit(ctx, msg, 1); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) submit(ctx context.Context, msg Message, attempt int) error {
+	return s.pool.Submit(ctx, jobs.Job{
+		Name: "notify_" + string(msg.Channel),
+		Run: func(ctx context.Context) error {
+			return s.deliver(ctx, msg, attempt)
+		},
+	})
+}
+
+func (s *Service) deliver(ctx context.Context, msg Message, attempt int) error {
+	err := s.providers[msg.Channel].Send(ctx, msg)
+	if err == nil || attempt >= s.opts.MaxAttempts || apierrors.IsTerminal(err) {
+		return err
+	}
+
+	// Requeue after a backoff instead of holding a worker while waiting
+	delay := s.opts.RetryDelay << (attempt - 1)
+	l := pkglogger.FromContext(ctx)
+	l.Warn("notification delivery failed, retrying",
+		slog.String("channel", string(msg.Channel)),
+		slog.Int("attempt", attempt),
+		slog.Duration("retry_in", delay),
+		slog.String("error", err.Error()))
+	time.AfterFunc(delay, func() {
+		if err := s.submit(ctx, msg, attempt+1); err != nil {
+			l.Error("notification dropped", slog.String("channel", string(msg.Channel)), slog.String("error", err.Error()))
+		}
+	})
+	return nil
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/testutil/mockupstream"
+)
+
+type recordingProvider struct {
+	mu       sync.Mutex
+	sent     []Message
+	failures int
+	done     chan struct{}
+}
+
+func newRecordingProvider(failures int) *recordingProvider {
+	return &recordingProvider{failures: failures, done: make(chan struct{}, 10)}
+}
+
+func (p *recordingProvider) Send(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("transient failure")
+	}
+	p.sent = append(p.sent, msg)
+	p.done <- struct{}{}
+	return nil
+}
+
+func (p *recordingProvider) wait(t *testing.T) Message {
+	t.Helper()
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		t.Fatalf("message was not delivered")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sent[len(p.sent)-1]
+}
+
+func TestService_RespectsPreferencesAndChannelTemplates(t *testing.T) {
+	email, sms := newRecordingProvider(0), newRecordingProvider(0)
+	prefs := NewMemoryPreferences()
+	pool := jobs.NewPool(1, 10)
+	defer pool.Shutdown(context.Background())
+	svc := NewService(map[Channel]Provider{ChannelEmail: email, ChannelSMS: sms}, NewTemplates(), prefs, pool, Options{})
+
+	n := Notification{
+		UserID:   "usr_1",
+		To:       Recipient{Email: "ann@example.com", Phone: "+3581234"},
+		Template: "welcome",
+		Data:     map[string]any{"Name": "Ann", "Email": "ann@example.com"},
+	}
+	if err := svc.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	msg := email.wait(t)
+	if msg.To != "ann@example.com" || msg.Subject != "Welcome, Ann" {
+		t.Fatalf("unexpected email %+v", msg)
+	}
+
+	// SMS is opt-in and uses its own shorter template
+	_ = prefs.Set(context.Background(), "usr_1", Preferences{ChannelSMS: true, ChannelEmail: false})
+	if err := svc.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	if msg := sms.wait(t); msg.Body != "Welcome Ann! Your account is ready." || msg.To != "+3581234" {
+		t.Fatalf("unexpected sms %+v", msg)
+	}
+	email.mu.Lock()
+	defer email.mu.Unlock()
+	if len(email.sent) != 1 {
+		t.Fatalf("expected email to be skipped after opting out, got %d emails", len(email.sent))
+	}
+}
+
+func TestService_RetriesFailedDeliveries(t *testing.T) {
+	email := newRecordingProvider(2)
+	pool := jobs.NewPool(1, 10)
+	defer pool.Shutdown(context.Background())
+	svc := NewService(map[Channel]Provider{ChannelEmail: email}, NewTemplates(), NewMemoryPreferences(), pool,
+		Options{MaxAttempts: 3, RetryDelay: time.Millisecond})
+
+	err := svc.Notify(context.Background(), Notification{
+		UserID: "usr_1", To: Recipient{Email: "a@example.com"}, Template: "welcome",
+		Data: map[string]any{"Name": "A", "Email": "a@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	email.wait(t)
+}
+
+func TestService_RejectsUnknownTemplateAndMissingData(t *testing.T) {
+	pool := jobs.NewPool(1, 10)
+	defer pool.Shutdown(context.Background())
+	svc := NewService(map[Channel]Provider{ChannelEmail: newRecordingProvider(0)}, NewTemplates(), NewMemoryPreferences(), pool, Options{})
+
+	to := Recipient{Email: "a@example.com"}
+	if err := svc.Notify(context.Background(), Notification{UserID: "u", To: to, Template: "nope"}); err == nil {
+		t.Fatalf("expected error for unknown template")
+	}
+	if err := svc.Notify(context.Background(), Notification{UserID: "u", To: to, Template: "welcome", Data: map[string]any{}}); err == nil {
+		t.Fatalf("expected error for missing template data")
+	}
+}
+
+func TestWebhookProvider_SlackPayload(t *testing.T) {
+	up := mockupstream.New(t)
+	up.On(http.MethodPost, "/hook", mockupstream.Response{Status: http.StatusOK})
+
+	p := WebhookProvider{URL: up.URL() + "/hook", Client: up.Client(), Slack: true}
+	if err := p.Send(context.Background(), Message{Channel: ChannelSlack, To: "U123", Subject: "Hi", Body: "there"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	req := up.LastRequest(t)
+	if string(req.Body) != `{"channel":"U123","text":"*Hi*\nthere"}` {
+		t.Fatalf("unexpected slack payload %s", req.Body)
+	}
+
+	up.On(http.MethodPost, "/hook", mockupstream.Response{Status: http.StatusInternalServerError})
+	if err := p.Send(context.Background(), Message{Channel: ChannelSlack, Body: "x"}); !apierrors.IsRetryable(err) {
+		t.Fatalf("expected retryable error for a 500, got %v", err)
+	}
+	up.On(http.MethodPost, "/hook", mockupstream.Response{Status: http.StatusNotFound})
+	if err := p.Send(context.Background(), Message{Channel: ChannelSlack, Body: "x"}); !apierrors.IsTerminal(err) {
+		t.Fatalf("expected terminal error for a 404, got %v", err)
+	}
+}
+
+func TestTemplates_LoadFromFiles(t *testing.T) {
+	templates := NewTemplates()
+	err := templates.Load(fstest.MapFS{
+		"welcome.tmpl":       {Data: []byte("Subject: Hello {{.Name}}\n\nCustom body")},
+		"welcome.slack.tmpl": {Data: []byte("Hey {{.Name}}")},
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	email, err := templates.Render("welcome", ChannelEmail, map[string]any{"Name": "Ada"})
+	if err != nil || email.Subject != "Hello Ada" || email.Body != "Custom body" {
+		t.Fatalf("unexpected email message %+v, %v", email, err)
+	}
+	slack, err := templates.Render("welcome", ChannelSlack, map[string]any{"Name": "Ada"})
+	if err != nil || slack.Subject != "" || slack.Body != "Hey Ada" {
+		t.Fatalf("unexpected slack message %+v, %v", slack, err)
+	}
+
+	if err := templates.Load(fstest.MapFS{"orphan.sms.tmpl": {Data: []byte("x")}}); err == nil {
+		t.Fatalf("expected an override without a default template to fail")
+	}
+}
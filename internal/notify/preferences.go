@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Preferences records which channels a user wants notifications on.
+// Channels missing from the map fall back to DefaultPreferences.
+type Preferences map[Channel]bool
+
+// DefaultPreferences apply to users who have not chosen otherwise: email only.
+var DefaultPreferences = Preferences{ChannelEmail: true}
+
+// Enabled reports whether channel c is switched on.
+func (p Preferences) Enabled(c Channel) bool {
+	if on, ok := p[c]; ok {
+		return on
+	}
+	return DefaultPreferences[c]
+}
+
+// PreferenceStore persists per-user preferences.
+type PreferenceStore interface {
+	Get(ctx context.Context, userID string) (Preferences, error)
+	Set(ctx context.Context, userID string, prefs Preferences) error
+	// Delete forgets the user's preferences.
+	Delete(ctx context.Context, userID string) error
+}
+
+// MemoryPreferences is an in-memory PreferenceStore.
+type MemoryPreferences struct {
+	mu    sync.RWMutex
+	prefs map[string]Preferences
+}
+
+// NewMemoryPreferences creates an empty store.
+func NewMemoryPreferences() *MemoryPreferences {
+	return &MemoryPreferences{prefs: make(map[string]Preferences)}
+}
+
+// Get returns the user's preferences with defaults filled in for every channel.
+func (m *MemoryPreferences) Get(ctx context.Context, userID string) (Preferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(Preferences, len(Channels))
+	for _, c := range Channels {
+		out[c] = m.prefs[userID].Enabled(c)
+	}
+	return out, nil
+}
+
+// Set merges prefs into the user's stored preferences.
+func (m *MemoryPreferences) Set(ctx context.Context, userID string, prefs Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.prefs[userID]
+	if stored == nil {
+		stored = make(Preferences)
+		m.prefs[userID] = stored
+	}
+	for c, on := range prefs {
+		stored[c] = on
+	}
+	return nil
+}
+
+// Delete forgets the user's preferences, so defaults apply again.
+func (m *MemoryPreferences) Delete(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.prefs, userID)
+	return nil
+}
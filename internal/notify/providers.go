@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// LogProvider writes messages to the log instead of sending them; useful in
+// development and for channels without a configured transport.
+type LogProvider struct{}
+
+func (LogProvider) Send(ctx context.Context, msg Message) error {
+	pkglogger.FromContext(ctx).Info("notification",
+		slog.String("channel", string(msg.Channel)),
+		slog.String("subject", msg.Subject))
+	return nil
+}
+
+// WebhookProvider POSTs each message as JSON to a URL. It suits Slack incoming
+// webhooks and HTTP gateways for SMS or push services.
+type WebhookProvider struct {
+	URL    string
+	Client *http.Client
+	// Slack formats the payload as a Slack message ({"text": ...}).
+	Slack bool
+}
+
+func (p WebhookProvider) Send(ctx context.Context, msg Message) error {
+	var payload any = msg
+	if p.Slack {
+		text := msg.Body
+		if msg.Subject != "" {
+			text = "*" + msg.Subject + "*\n" + msg.Body
+		}
+		payload = map[string]string{"text": text, "channel": msg.To}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return apierrors.Retryablef("webhook responded %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return apierrors.Terminalf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPProvider sends email through an SMTP relay.
+type SMTPProvider struct {
+	Addr string // host:port
+	From string
+	Auth smtp.Auth
+}
+
+func (p SMTPProvider) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return apierrors.Terminalf("invalid email header value")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n", p.From, msg.To, msg.Subject)
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	err := smtp.SendMail(p.Addr, p.Auth, p.From, []string{msg.To}, []byte(b.String()))
+	// 5xx replies are permanent (unknown mailbox, rejected sender), 4xx transient
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return apierrors.Terminal(err)
+	}
+	return err
+}
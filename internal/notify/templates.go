@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/mikko-kohtala/go-api/internal/assets"
+)
+
+// Template is the source for one notification. Per-channel overrides replace
+// the default subject and body, e.g. a shorter SMS text.
+type Template struct {
+	Subject  string
+	Body     string
+	Channels map[Channel]Template
+}
+
+// Templates is a registry of parsed notification templates.
+type Templates struct {
+	mu     sync.RWMutex
+	parsed map[string]map[Channel]*template.Template // "" holds the default
+}
+
+// TemplateDir is the directory of the notification templates in the assets.
+const TemplateDir = "templates/notify"
+
+// NewTemplates creates a registry containing the built-in templates.
+func NewTemplates() *Templates {
+	t := &Templates{parsed: make(map[string]map[Channel]*template.Template)}
+	builtin, err := fs.Sub(assets.Embedded(), TemplateDir)
+	if err == nil {
+		err = t.Load(builtin)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load registers (or replaces) the templates in the *.tmpl files of fsys.
+// name.tmpl holds template name and name.<channel>.tmpl its override for that
+// channel. A file starts with a "Subject: ..." line and a blank line, or is
+// only a body, for channels without a subject.
+func (t *Templates) Load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return err
+	}
+	sources := map[string]*Template{}
+	var overrides []string
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".tmpl")
+		if strings.Contains(name, ".") {
+			overrides = append(overrides, file)
+			continue
+		}
+		src, err := readTemplate(fsys, file)
+		if err != nil {
+			return err
+		}
+		sources[name] = &src
+	}
+	for _, file := range overrides {
+		name, channel, _ := strings.Cut(strings.TrimSuffix(file, ".tmpl"), ".")
+		src, ok := sources[name]
+		if !ok {
+			return fmt.Errorf("template %s overrides %s.tmpl, which does not exist", file, name)
+		}
+		override, err := readTemplate(fsys, file)
+		if err != nil {
+			return err
+		}
+		if src.Channels == nil {
+			src.Channels = map[Channel]Template{}
+		}
+		src.Channels[Channel(channel)] = override
+	}
+	for name, src := range sources {
+		if err := t.Register(name, *src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTemplate(fsys fs.FS, file string) (Template, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return Template{}, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "Subject:"); ok {
+		subject, body, _ := strings.Cut(rest, "\n")
+		return Template{Subject: strings.TrimSpace(subject), Body: strings.TrimSpace(body)}, nil
+	}
+	return Template{Body: strings.TrimSpace(text)}, nil
+}
+
+// Register parses and adds (or replaces) the template called name.
+func (t *Templates) Register(name string, src Template) error {
+	parsed := map[Channel]*template.Template{}
+	def, err := parseTemplate(name, src)
+	if err != nil {
+		return err
+	}
+	parsed[""] = def
+	for c, override := range src.Channels {
+		if parsed[c], err = parseTemplate(name+"."+string(c), override); err != nil {
+			return err
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.parsed[name] = parsed
+	return nil
+}
+
+// Render executes the template for channel c with data.
+func (t *Templates) Render(name string, c Channel, data map[string]any) (Message, error) {
+	t.mu.RLock()
+	variants, ok := t.parsed[name]
+	t.mu.RUnlock()
+	if !ok {
+		return Message{}, fmt.Errorf("unknown notification template %q", name)
+	}
+	tmpl := variants[c]
+	if tmpl == nil {
+		tmpl = variants[""]
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, fmt.Errorf("render %s body: %w", name, err)
+	}
+	return Message{Channel: c, Subject: strings.TrimSpace(subject.String()), Body: strings.TrimSpace(body.String())}, nil
+}
+
+func parseTemplate(name string, src Template) (*template.Template, error) {
+	tmpl := template.New(name).Option("missingkey=error")
+	if _, err := tmpl.New("subject").Parse(src.Subject); err != nil {
+		return nil, fmt.Errorf("parse %s subject: %w", name, err)
+	}
+	if _, err := tmpl.New("body").Parse(src.Body); err != nil {
+		return nil, fmt.Errorf("parse %s body: %w", name, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,216 @@
+// Package operations tracks long-running operations: work a request starts
+// on the job pool and answers with 202 and an Operation, which the client
+// polls at GET /api/v1/operations/{id} for status, progress and the result.
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+)
+
+// ErrNotFound is returned for an unknown operation ID.
+var ErrNotFound = errors.New("operation not found")
+
+// defaultLimit bounds the operations a store keeps; the oldest finished ones
+// go first.
+const defaultLimit = 1000
+
+// Operation statuses
+const (
+	Pending   = "pending"
+	Running   = "running"
+	Completed = "completed"
+	Failed    = "failed"
+)
+
+// Operation is the state of a long-running operation.
+type Operation struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"` // what it does, e.g. "users.import"
+	Status      string     `json:"status"`
+	Progress    *Progress  `json:"progress,omitempty"`
+	Result      any        `json:"result,omitempty"` // set once completed
+	Error       *Error     `json:"error,omitempty"`  // set once failed
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Done reports whether the operation has completed or failed.
+func (o Operation) Done() bool {
+	return o.Status == Completed || o.Status == Failed
+}
+
+// Progress counts the units of work done; Total is 0 when unknown.
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total,omitempty"`
+}
+
+// Error is why an operation failed, as clients see it.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Func is the work of an operation. It reports progress through t and
+// returns the result clients get once it completes. A returned APIError or
+// error registered with errmap fails the operation with its code and
+// message; any other error as internal_error.
+type Func func(ctx context.Context, t *Tracker) (result any, err error)
+
+// Store holds operations and runs them on a job pool.
+type Store struct {
+	pool  *jobs.Pool
+	limit int
+
+	mu    sync.Mutex
+	ops   map[string]*Operation
+	order []string // IDs, oldest first
+}
+
+// NewStore returns a store running operations on pool; a nil pool means
+// jobs.Default at the time each operation starts.
+func NewStore(pool *jobs.Pool) *Store {
+	return &Store{pool: pool, limit: defaultLimit, ops: make(map[string]*Operation)}
+}
+
+// Default is the store the handlers start operations on.
+var Default = NewStore(nil)
+
+// Start records a pending operation of kind and queues fn on the job pool. It
+// returns the pool's *jobs.OverloadedError when the pool refuses the work, in
+// which case nothing is recorded.
+func (s *Store) Start(ctx context.Context, kind string, fn Func) (Operation, error) {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return Operation{}, err
+	}
+	now := time.Now().UTC()
+	op := &Operation{ID: "op_" + hex.EncodeToString(b[:]), Kind: kind, Status: Pending, CreatedAt: now, UpdatedAt: now}
+	s.add(op)
+
+	pool := s.pool
+	if pool == nil {
+		pool = jobs.Default
+	}
+	err := pool.Admit(ctx, jobs.Job{Name: "operation_" + kind, Detail: op.ID, Run: func(ctx context.Context) error {
+		return s.run(ctx, op.ID, fn)
+	}})
+	if err != nil {
+		s.remove(op.ID)
+		return Operation{}, err
+	}
+	return s.Get(op.ID)
+}
+
+// Get returns the operation with id.
+func (s *Store) Get(id string) (Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return Operation{}, ErrNotFound
+	}
+	out := *op
+	if op.Progress != nil {
+		p := *op.Progress
+		out.Progress = &p
+	}
+	return out, nil
+}
+
+func (s *Store) add(op *Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.order) >= s.limit {
+		// Drop the oldest finished operation; running ones are kept so their
+		// clients can still poll them.
+		for i, id := range s.order {
+			if s.ops[id].Done() {
+				delete(s.ops, id)
+				s.order = slices.Delete(s.order, i, i+1)
+				break
+			}
+		}
+	}
+	s.ops[op.ID] = op
+	s.order = append(s.order, op.ID)
+}
+
+func (s *Store) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ops, id)
+	s.order = slices.DeleteFunc(s.order, func(v string) bool { return v == id })
+}
+
+func (s *Store) update(id string, fn func(op *Operation)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if op, ok := s.ops[id]; ok {
+		fn(op)
+		op.UpdatedAt = time.Now().UTC()
+	}
+}
+
+// run runs fn for the operation with id. Its error is returned to the pool,
+// which logs it and keeps the job as a dead letter that may be replayed.
+func (s *Store) run(ctx context.Context, id string, fn Func) error {
+	s.update(id, func(op *Operation) {
+		op.Status, op.Error = Running, nil
+	})
+	result, err := fn(ctx, &Tracker{store: s, id: id})
+	s.update(id, func(op *Operation) {
+		now := time.Now().UTC()
+		op.CompletedAt = &now
+		if err != nil {
+			op.Status, op.Error = Failed, clientError(err)
+			return
+		}
+		op.Status, op.Result = Completed, result
+	})
+	return err
+}
+
+// clientError returns what clients are told about err.
+func clientError(err error) *Error {
+	var apiErr *apierrors.APIError
+	if errors.As(err, &apiErr) {
+		return &Error{Code: apiErr.Code, Message: apiErr.Message}
+	}
+	if m, ok := errmap.Lookup(err); ok {
+		return &Error{Code: m.Code, Message: m.Message}
+	}
+	return &Error{Code: "internal_error", Message: "Operation failed"}
+}
+
+// Tracker reports the progress of one operation.
+type Tracker struct {
+	store *Store
+	id    string
+}
+
+// ID returns the operation's ID.
+func (t *Tracker) ID() string { return t.id }
+
+// Progress records that done of total units of work are done; total is 0
+// when unknown. On a nil Tracker, for work run outside an operation, it does
+// nothing.
+func (t *Tracker) Progress(done, total int) {
+	if t == nil {
+		return
+	}
+	t.store.update(t.id, func(op *Operation) {
+		op.Progress = &Progress{Done: done, Total: total}
+	})
+}
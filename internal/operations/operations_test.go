@@ -0,0 +1,92 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+)
+
+func waitDone(t *testing.T, s *Store, id string) Operation {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		op, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if op.Done() {
+			return op
+		}
+	}
+	t.Fatalf("operation %s did not finish", id)
+	return Operation{}
+}
+
+func TestStore_RunsOperationsToTheirResult(t *testing.T) {
+	pool := jobs.NewPool(1, 4)
+	defer pool.Shutdown(context.Background())
+	s := NewStore(pool)
+
+	release := make(chan struct{})
+	op, err := s.Start(context.Background(), "widgets.count", func(ctx context.Context, t *Tracker) (any, error) {
+		t.Progress(1, 2)
+		<-release
+		t.Progress(2, 2)
+		return map[string]int{"widgets": 2}, nil
+	})
+	if err != nil || op.Status != Pending || op.Kind != "widgets.count" {
+		t.Fatalf("expected a pending operation, got %+v %v", op, err)
+	}
+	close(release)
+	op = waitDone(t, s, op.ID)
+	if op.Status != Completed || op.Progress.Done != 2 || op.Result.(map[string]int)["widgets"] != 2 || op.CompletedAt == nil {
+		t.Fatalf("expected the operation completed with its result, got %+v", op)
+	}
+
+	failed, _ := s.Start(context.Background(), "widgets.archive", func(context.Context, *Tracker) (any, error) {
+		return nil, apierrors.New(http.StatusConflict, "widget_locked", "Widget is locked")
+	})
+	if op := waitDone(t, s, failed.ID); op.Status != Failed || op.Error.Code != "widget_locked" {
+		t.Fatalf("expected the APIError's code, got %+v", op)
+	}
+	crashed, _ := s.Start(context.Background(), "widgets.archive", func(context.Context, *Tracker) (any, error) {
+		return nil, errors.New("disk on fire")
+	})
+	if op := waitDone(t, s, crashed.ID); op.Error.Code != "internal_error" || op.Error.Message != "Operation failed" {
+		t.Fatalf("expected internal details hidden, got %+v", op.Error)
+	}
+	if _, err := s.Get("op_missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_RefusedWorkIsNotRecorded(t *testing.T) {
+	pool := jobs.NewPool(1, 4)
+	pool.Shutdown(context.Background())
+	s := NewStore(pool)
+
+	_, err := s.Start(context.Background(), "widgets.count", func(context.Context, *Tracker) (any, error) { return nil, nil })
+	var overloaded *jobs.OverloadedError
+	if !errors.As(err, &overloaded) || len(s.ops) != 0 {
+		t.Fatalf("expected the refusal and nothing recorded, got %v %d", err, len(s.ops))
+	}
+}
+
+func TestStore_DropsOldestFinishedFirst(t *testing.T) {
+	s := NewStore(nil)
+	s.limit = 2
+	running := &Operation{ID: "op_running", Status: Running}
+	s.add(running)
+	s.add(&Operation{ID: "op_done", Status: Completed})
+	s.add(&Operation{ID: "op_new", Status: Pending})
+	if _, err := s.Get("op_done"); err == nil {
+		t.Fatal("expected the finished operation dropped")
+	}
+	if _, err := s.Get("op_running"); err != nil {
+		t.Fatal("expected the running operation kept")
+	}
+}
@@ -0,0 +1,147 @@
+// Package preflight verifies that the dependencies the server needs are
+// available before it starts accepting traffic.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check is one dependency to verify.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Error aggregates the failed checks of a run.
+type Error struct {
+	Failed []Result
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d preflight check(s) failed:", len(e.Failed))
+	for _, r := range e.Failed {
+		fmt.Fprintf(&b, "\n  - %s: %v", r.Name, r.Err)
+	}
+	return b.String()
+}
+
+// Run executes the checks concurrently, each bounded by timeout, and returns
+// every result in the order given. The error is an *Error listing the
+// failures, or nil when all checks passed.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) ([]Result, error) {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := runCheck(cctx, c)
+			results[i] = Result{Name: c.Name, Err: err, Duration: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+
+	var failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &Error{Failed: failed}
+	}
+	return results, nil
+}
+
+// runCheck runs c, giving up when ctx expires even if c ignores it.
+func runCheck(ctx context.Context, c Check) error {
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errors.New("timed out")
+		}
+		return ctx.Err()
+	}
+}
+
+// TCP checks that addr (host:port) accepts connections.
+func TCP(name, addr string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}}
+}
+
+// URL checks that the host of rawURL resolves and accepts TCP connections on
+// the URL's port (or the scheme's default: 443 for https, 5432 for postgres,
+// 80 otherwise).
+func URL(name, rawURL string) Check {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Check{Name: name, Run: func(context.Context) error {
+			return fmt.Errorf("invalid URL %q", rawURL)
+		}}
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "postgres", "postgresql":
+			port = "5432"
+		default:
+			port = "80"
+		}
+	}
+	return TCP(name, net.JoinHostPort(u.Hostname(), port))
+}
+
+// WritableDir checks that files can be created in dir.
+func WritableDir(name, dir string) Check {
+	return Check{Name: name, Run: func(context.Context) error {
+		f, err := os.CreateTemp(dir, ".preflight-*")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}}
+}
+
+// ReadableFile checks that path can be opened for reading.
+func ReadableFile(name, path string) Check {
+	return Check{Name: name, Run: func(context.Context) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}}
+}
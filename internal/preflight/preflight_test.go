@@ -0,0 +1,48 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_AggregatesFailures(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	dir := t.TempDir()
+
+	checks := []Check{
+		TCP("up", ln.Addr().String()),
+		WritableDir("storage", dir),
+		WritableDir("missing", filepath.Join(dir, "nope")),
+		{Name: "hangs", Run: func(context.Context) error { time.Sleep(time.Second); return nil }},
+		{Name: "fails", Run: func(context.Context) error { return errors.New("boom") }},
+	}
+	results, err := Run(context.Background(), checks, 50*time.Millisecond)
+	if len(results) != len(checks) || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	var pe *Error
+	if !errors.As(err, &pe) || len(pe.Failed) != 3 {
+		t.Fatalf("expected 3 failures, got %v", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"3 preflight check(s) failed", "missing:", "hangs: timed out", "fails: boom"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q lacks %q", msg, want)
+		}
+	}
+}
+
+func TestRun_AllPass(t *testing.T) {
+	if _, err := Run(context.Background(), []Check{WritableDir("tmp", t.TempDir())}, time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Package proxyproto implements the receiving side of the HAProxy PROXY
+// protocol (v1 text and v2 binary) as a net.Listener wrapper, so RemoteAddr
+// reflects the original client instead of the load balancer.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+	// ErrInvalidHeader is returned when a connection starts with a malformed PROXY header.
+	ErrInvalidHeader = errors.New("proxyproto: invalid header")
+)
+
+const (
+	v1MaxLength = 107
+	// defaultHeaderTimeout bounds how long a connection may take to send its header.
+	defaultHeaderTimeout = 5 * time.Second
+)
+
+// Listener wraps a net.Listener and parses PROXY headers on accepted connections.
+// Headers are only honoured from peers in Trusted (none when Trusted is empty), so
+// clients connecting directly cannot claim another address; connections without a
+// header are passed through unchanged.
+type Listener struct {
+	net.Listener
+	Trusted       []*net.IPNet
+	HeaderTimeout time.Duration
+}
+
+// NewListener wraps ln, honouring headers from the given trusted networks.
+func NewListener(ln net.Listener, trusted []*net.IPNet) *Listener {
+	return &Listener{Listener: ln, Trusted: trusted, HeaderTimeout: defaultHeaderTimeout}
+}
+
+// ParseCIDRs parses a list of CIDR strings; bare IPs are treated as single hosts.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", v, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Accept returns the next connection. The PROXY header is parsed lazily on first
+// use so a slow client cannot stall the accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, trusted: l.trusts(conn.RemoteAddr()), timeout: l.HeaderTimeout}, nil
+}
+
+func (l *Listener) trusts(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.Trusted {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection whose addresses come from the PROXY header when present.
+type Conn struct {
+	net.Conn
+	trusted bool
+	timeout time.Duration
+
+	once   sync.Once
+	reader *bufio.Reader
+	src    net.Addr
+	dst    net.Addr
+	err    error
+}
+
+// Read reads from the connection after the PROXY header.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the original client address when a header was received.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.src != nil {
+		return c.src
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the original destination address when a header was received.
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.dst != nil {
+		return c.dst
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *Conn) readHeader() {
+	c.reader = bufio.NewReader(c.Conn)
+	if !c.trusted {
+		return
+	}
+	if c.timeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+	}
+
+	// Peek the longest signature; short reads mean the client sent less, which is fine
+	peek, err := c.reader.Peek(len(v2Signature))
+	switch {
+	case bytes.Equal(peek, v2Signature):
+		c.src, c.dst, c.err = parseV2(c.reader)
+	case bytes.HasPrefix(peek, v1Prefix):
+		c.src, c.dst, c.err = parseV1(c.reader)
+	case err != nil && len(peek) == 0:
+		c.err = err
+		return
+	}
+	if c.err != nil {
+		_ = c.Conn.Close()
+	}
+}
+
+func parseV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, ErrInvalidHeader
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, ErrInvalidHeader
+	}
+	src, err := tcpAddr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := tcpAddr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func tcpAddr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	p, err := strconv.Atoi(port)
+	if ip == nil || err != nil || p < 0 || p > 65535 {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: p}, nil
+}
+
+func parseV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, nil, ErrInvalidHeader
+	}
+	verCmd, fam := hdr[12], hdr[13]
+	length := int(binary.BigEndian.Uint16(hdr[14:16]))
+	if verCmd>>4 != 2 {
+		return nil, nil, ErrInvalidHeader
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	switch verCmd & 0x0F {
+	case 0x0: // LOCAL: health checks from the proxy itself, keep the real addresses
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+
+	switch fam >> 4 {
+	case 0x1: // AF_INET
+		if len(payload) < 12 {
+			return nil, nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}, nil
+	case 0x2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}, nil
+	default: // AF_UNSPEC / AF_UNIX: nothing usable for RemoteAddr
+		return nil, nil, nil
+	}
+}
@@ -0,0 +1,84 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func serveOne(t *testing.T, trusted []*net.IPNet, header []byte) (remote string, payload string) {
+	t.Helper()
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln := NewListener(raw, trusted)
+	defer ln.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", raw.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, _ = c.Write(append(header, []byte("hello")...))
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer conn.Close()
+	body, _ := io.ReadAll(conn)
+	return conn.RemoteAddr().String(), string(body)
+}
+
+var loopback, _ = ParseCIDRs([]string{"127.0.0.1"})
+
+func TestListener_V1(t *testing.T) {
+	remote, body := serveOne(t, loopback, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\n"))
+	if remote != "203.0.113.7:51234" || body != "hello" {
+		t.Fatalf("unexpected remote=%s body=%q", remote, body)
+	}
+}
+
+func TestListener_V2(t *testing.T) {
+	hdr := append([]byte{}, v2Signature...)
+	hdr = append(hdr, 0x21, 0x11, 0x00, 12)
+	hdr = append(hdr, 198, 51, 100, 9, 10, 0, 0, 1)
+	hdr = binary.BigEndian.AppendUint16(hdr, 40000)
+	hdr = binary.BigEndian.AppendUint16(hdr, 443)
+
+	remote, body := serveOne(t, loopback, hdr)
+	if remote != "198.51.100.9:40000" || body != "hello" {
+		t.Fatalf("unexpected remote=%s body=%q", remote, body)
+	}
+}
+
+func TestListener_NoHeaderPassesThrough(t *testing.T) {
+	remote, body := serveOne(t, nil, []byte("GET / HTTP/1.1\r\n"))
+	if remote[:10] != "127.0.0.1:" || body != "GET / HTTP/1.1\r\nhello" {
+		t.Fatalf("unexpected remote=%s body=%q", remote, body)
+	}
+}
+
+func TestListener_UntrustedHeaderIgnored(t *testing.T) {
+	trusted, _ := ParseCIDRs([]string{"10.0.0.0/8"})
+	for _, trusted := range [][]*net.IPNet{trusted, nil} {
+		remote, body := serveOne(t, trusted, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\n"))
+		if remote[:10] != "127.0.0.1:" || body[:6] != "PROXY " {
+			t.Fatalf("expected header from untrusted peer to be left alone with %v trusted, got remote=%s body=%q", trusted, remote, body)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", " 192.168.1.5 ", ""})
+	if err != nil || len(nets) != 2 || !nets[1].Contains(net.ParseIP("192.168.1.5")) {
+		t.Fatalf("unexpected result: %v %v", nets, err)
+	}
+	if _, err := ParseCIDRs([]string{"nope"}); err == nil {
+		t.Fatalf("expected error for invalid entry")
+	}
+}
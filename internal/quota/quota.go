@@ -0,0 +1,424 @@
+// Package quota enforces per-consumer quotas (requests per day, stored bytes,
+// users created) and persists the counters so they survive restarts.
+// Consumers are authenticated users; counters are kept in memory and written
+// to the state file in the background, never while a request waits.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind names a quota.
+type Kind string
+
+const (
+	RequestsPerDay Kind = "requests_per_day"
+	StorageBytes   Kind = "storage_bytes"
+	Users          Kind = "users"
+)
+
+// ErrExceeded is matched by every *ExceededError.
+var ErrExceeded = errors.New("quota exceeded")
+
+// ExceededError reports which quota refused an operation.
+type ExceededError struct {
+	Kind      Kind
+	Limit     int64
+	Used      int64
+	Requested int64
+	ResetAt   time.Time // zero unless the quota resets on its own
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %d of %d used, %d requested", e.Kind, e.Used, e.Limit, e.Requested)
+}
+
+// Is makes errors.Is(err, ErrExceeded) true.
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// Limits caps each quota kind; zero means unlimited.
+type Limits struct {
+	RequestsPerDay int64 `json:"requests_per_day" validate:"min=0"`
+	StorageBytes   int64 `json:"storage_bytes" validate:"min=0"`
+	Users          int64 `json:"users" validate:"min=0"`
+}
+
+func (l Limits) of(kind Kind) int64 {
+	switch kind {
+	case RequestsPerDay:
+		return l.RequestsPerDay
+	case StorageBytes:
+		return l.StorageBytes
+	case Users:
+		return l.Users
+	}
+	return 0
+}
+
+// Usage is the current consumption of each quota kind.
+type Usage struct {
+	RequestsToday int64 `json:"requests_today"`
+	StorageBytes  int64 `json:"storage_bytes"`
+	Users         int64 `json:"users"`
+}
+
+// Status describes a key's limits and usage.
+type Status struct {
+	Key      string    `json:"key"`
+	Limits   Limits    `json:"limits"`
+	Custom   bool      `json:"custom"` // limits override the defaults
+	Usage    Usage     `json:"usage"`
+	ResetsAt time.Time `json:"resets_at"` // when the daily request count resets
+}
+
+// allocation records who holds a counted resource so it can be released.
+type allocation struct {
+	Key    string `json:"key"`
+	Kind   Kind   `json:"kind"`
+	Amount int64  `json:"amount"`
+}
+
+type counters struct {
+	Day      string `json:"day"` // UTC date RequestsToday belongs to
+	Requests int64  `json:"requests"`
+	Storage  int64  `json:"storage"`
+	Users    int64  `json:"users"`
+}
+
+// state is the persisted form of a Manager.
+type state struct {
+	Limits      map[string]Limits     `json:"limits"`
+	Counters    map[string]*counters  `json:"counters"`
+	Allocations map[string]allocation `json:"allocations"` // by kind + ":" + resource ID
+}
+
+// Options configure a Manager.
+type Options struct {
+	Defaults   Limits
+	StatePath  string        // JSON file the counters are persisted to; memory only when empty
+	FlushEvery time.Duration // how often Start writes changes back
+	Logger     *slog.Logger
+}
+
+// Manager tracks usage against limits.
+type Manager struct {
+	opts Options
+	now  func() time.Time
+
+	mu    sync.Mutex
+	st    state
+	dirty bool
+
+	// flushMu serializes flushes, so an older state never replaces a newer
+	// one; the file is written outside mu
+	flushMu sync.Mutex
+
+	loopMu sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewManager creates a manager, loading persisted state from opts.StatePath
+// when the file exists.
+func NewManager(opts Options) (*Manager, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	m := &Manager{
+		opts: opts,
+		now:  time.Now,
+		st: state{
+			Limits:      make(map[string]Limits),
+			Counters:    make(map[string]*counters),
+			Allocations: make(map[string]allocation),
+		},
+	}
+	if opts.StatePath == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.st); err != nil {
+		return nil, fmt.Errorf("quota state %s: %w", opts.StatePath, err)
+	}
+	// Sections missing from the file decode as nil maps
+	if m.st.Limits == nil {
+		m.st.Limits = make(map[string]Limits)
+	}
+	if m.st.Counters == nil {
+		m.st.Counters = make(map[string]*counters)
+	}
+	if m.st.Allocations == nil {
+		m.st.Allocations = make(map[string]allocation)
+	}
+	return m, nil
+}
+
+// Consume counts n requests against key's daily quota.
+func (m *Manager) Consume(key string, n int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.countersLocked(key)
+	if limit := m.limitsLocked(key).RequestsPerDay; limit > 0 && c.Requests+n > limit {
+		return &ExceededError{Kind: RequestsPerDay, Limit: limit, Used: c.Requests, Requested: n, ResetAt: m.resetAt()}
+	}
+	c.Requests += n
+	m.dirty = true
+	return nil
+}
+
+// Allocate charges amount of kind to key for resource id, e.g. the bytes of
+// an uploaded file. Release undoes it.
+func (m *Manager) Allocate(key string, kind Kind, id string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.countersLocked(key)
+	used := c.allocated(kind)
+	if limit := m.limitsLocked(key).of(kind); limit > 0 && *used+amount > limit {
+		return &ExceededError{Kind: kind, Limit: limit, Used: *used, Requested: amount}
+	}
+	*used += amount
+	m.st.Allocations[string(kind)+":"+id] = allocation{Key: key, Kind: kind, Amount: amount}
+	m.dirty = true
+	return nil
+}
+
+// Release returns the allocation of kind held for id, if any.
+func (m *Manager) Release(kind Kind, id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ref := string(kind) + ":" + id
+	a, ok := m.st.Allocations[ref]
+	if !ok {
+		return
+	}
+	delete(m.st.Allocations, ref)
+	used := m.countersLocked(a.Key).allocated(kind)
+	*used -= a.Amount
+	if *used < 0 {
+		*used = 0
+	}
+	m.dirty = true
+}
+
+// Rebind moves the allocation of kind held for oldID to newID, so a resource
+// can be reserved before its ID is known.
+func (m *Manager) Rebind(kind Kind, oldID, newID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.st.Allocations[string(kind)+":"+oldID]
+	if !ok {
+		return
+	}
+	delete(m.st.Allocations, string(kind)+":"+oldID)
+	m.st.Allocations[string(kind)+":"+newID] = a
+	m.dirty = true
+}
+
+// Allocated returns the resource IDs holding an allocation of kind.
+func (m *Manager) Allocated(kind Kind) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	prefix := string(kind) + ":"
+	for ref := range m.st.Allocations {
+		if id, ok := strings.CutPrefix(ref, prefix); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Status returns key's limits and current usage.
+func (m *Manager) Status(key string) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := counters{}
+	if kc, ok := m.st.Counters[key]; ok {
+		c = *kc
+		if c.Day != m.today() {
+			c.Requests = 0
+		}
+	}
+	_, custom := m.st.Limits[key]
+	return Status{
+		Key:      key,
+		Limits:   m.limitsLocked(key),
+		Custom:   custom,
+		Usage:    Usage{RequestsToday: c.Requests, StorageBytes: c.Storage, Users: c.Users},
+		ResetsAt: m.resetAt(),
+	}
+}
+
+// SetLimits overrides the default limits for key.
+func (m *Manager) SetLimits(key string, l Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.st.Limits[key] = l
+	m.dirty = true
+}
+
+// ResetLimits reverts key to the default limits.
+func (m *Manager) ResetLimits(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.st.Limits, key)
+	m.dirty = true
+}
+
+// Start writes changes to the state file every FlushEvery in the background
+// until Stop. It is a no-op without a state file or when already running.
+func (m *Manager) Start() {
+	m.loopMu.Lock()
+	defer m.loopMu.Unlock()
+	if m.opts.StatePath == "" || m.opts.FlushEvery <= 0 || m.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel, m.done = cancel, make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(m.opts.FlushEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.Flush()
+			}
+		}
+	}(m.done)
+}
+
+// Stop ends the background loop and writes the last changes.
+func (m *Manager) Stop() {
+	m.loopMu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.loopMu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	m.Flush()
+}
+
+// Flush drops the counters of past days that hold no allocations and writes
+// pending changes to the state file.
+func (m *Manager) Flush() {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	m.mu.Lock()
+	m.pruneLocked()
+	if !m.dirty || m.opts.StatePath == "" {
+		m.dirty = false
+		m.mu.Unlock()
+		return
+	}
+	data, err := json.Marshal(m.st)
+	m.dirty = false
+	m.mu.Unlock()
+
+	if err == nil {
+		err = m.write(data)
+	}
+	if err != nil {
+		m.mu.Lock()
+		m.dirty = true
+		m.mu.Unlock()
+		m.opts.Logger.Error("failed to persist quota state", slog.String("path", m.opts.StatePath), slog.String("error", err.Error()))
+	}
+}
+
+func (m *Manager) limitsLocked(key string) Limits {
+	if l, ok := m.st.Limits[key]; ok {
+		return l
+	}
+	return m.opts.Defaults
+}
+
+// countersLocked returns key's counters, starting a new day's request count
+// when the UTC date has changed.
+func (m *Manager) countersLocked(key string) *counters {
+	day := m.today()
+	c, ok := m.st.Counters[key]
+	if !ok {
+		c = &counters{Day: day}
+		m.st.Counters[key] = c
+	}
+	if c.Day != day {
+		c.Day, c.Requests = day, 0
+	}
+	return c
+}
+
+func (c *counters) allocated(kind Kind) *int64 {
+	if kind == Users {
+		return &c.Users
+	}
+	return &c.Storage
+}
+
+// pruneLocked drops the counters of keys not seen today that hold nothing,
+// so every consumer ever seen is not kept forever.
+func (m *Manager) pruneLocked() {
+	day := m.today()
+	for key, c := range m.st.Counters {
+		if c.Day != day && c.Storage == 0 && c.Users == 0 {
+			delete(m.st.Counters, key)
+			m.dirty = true
+		}
+	}
+}
+
+// today is the UTC date daily request counts belong to.
+func (m *Manager) today() string {
+	return m.now().UTC().Format(time.DateOnly)
+}
+
+// resetAt is the next UTC midnight, when daily request counts restart.
+func (m *Manager) resetAt() time.Time {
+	y, mo, d := m.now().UTC().Date()
+	return time.Date(y, mo, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// write replaces the state file atomically with data. Failures are logged by
+// Flush and retried on the next one: losing a few counts is preferable to
+// failing requests.
+func (m *Manager) write(data []byte) error {
+	tmp := m.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.opts.StatePath)
+}
+
+type ctxKey struct{}
+
+// WithKey attaches the consumer key quotas are charged to.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, key)
+}
+
+// KeyFromContext returns the consumer key, or "" for work not attributed to a
+// consumer (e.g. background jobs), which quotas do not apply to.
+func KeyFromContext(ctx context.Context) string {
+	k, _ := ctx.Value(ctxKey{}).(string)
+	return k
+}
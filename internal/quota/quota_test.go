@@ -0,0 +1,131 @@
+package quota
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testManager(t *testing.T, path string, defaults Limits) *Manager {
+	t.Helper()
+	m, err := NewManager(Options{Defaults: defaults, StatePath: path, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestManager_DailyRequestsResetAtMidnight(t *testing.T) {
+	m := testManager(t, "", Limits{RequestsPerDay: 2})
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := m.Consume("key_a", 1); err != nil {
+			t.Fatalf("request %d refused: %v", i, err)
+		}
+	}
+	err := m.Consume("key_a", 1)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, ErrExceeded) {
+		t.Fatalf("expected ExceededError, got %v", err)
+	}
+	if exceeded.Kind != RequestsPerDay || !exceeded.ResetAt.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected error details: %+v", exceeded)
+	}
+	if err := m.Consume("key_b", 1); err != nil {
+		t.Fatalf("other keys must have their own quota: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := m.Consume("key_a", 1); err != nil {
+		t.Fatalf("expected the quota to reset on a new day: %v", err)
+	}
+}
+
+func TestManager_AllocationsAndOverrides(t *testing.T) {
+	m := testManager(t, "", Limits{StorageBytes: 100})
+	if err := m.Allocate("key_a", StorageBytes, "tmp", 60); err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	m.Rebind(StorageBytes, "tmp", "file_1")
+	if err := m.Allocate("key_a", StorageBytes, "file_2", 60); !errors.Is(err, ErrExceeded) {
+		t.Fatalf("expected storage quota to be exceeded, got %v", err)
+	}
+
+	m.SetLimits("key_a", Limits{StorageBytes: 200})
+	if err := m.Allocate("key_a", StorageBytes, "file_2", 60); err != nil {
+		t.Fatalf("override should allow the allocation: %v", err)
+	}
+	m.Release(StorageBytes, "file_1")
+	m.Release(StorageBytes, "file_1") // idempotent
+	if st := m.Status("key_a"); st.Usage.StorageBytes != 60 || !st.Custom {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	m.ResetLimits("key_a")
+	if st := m.Status("key_a"); st.Limits.StorageBytes != 100 || st.Custom {
+		t.Fatalf("expected default limits after reset: %+v", st)
+	}
+}
+
+func TestManager_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	m := testManager(t, path, Limits{})
+	_ = m.Consume("key_a", 3)
+	m.SetLimits("key_a", Limits{Users: 5})
+	_ = m.Allocate("key_a", Users, "usr_9", 1)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected nothing written before a flush, got %v", err)
+	}
+	m.Flush()
+
+	m = testManager(t, path, Limits{})
+	st := m.Status("key_a")
+	if st.Usage.RequestsToday != 3 || st.Usage.Users != 1 || st.Limits.Users != 5 {
+		t.Fatalf("state not restored: %+v", st)
+	}
+	if ids := m.Allocated(Users); len(ids) != 1 || ids[0] != "usr_9" {
+		t.Fatalf("allocations not restored: %v", ids)
+	}
+}
+
+func TestManager_DropsIdleCountersOfPastDays(t *testing.T) {
+	m := testManager(t, "", Limits{})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	_ = m.Consume("key_a", 1)
+	_ = m.Consume("key_b", 1)
+	_ = m.Allocate("key_b", StorageBytes, "file_1", 10)
+
+	now = now.Add(24 * time.Hour)
+	m.Flush()
+	if _, ok := m.st.Counters["key_a"]; ok {
+		t.Fatal("expected yesterday's request count to be dropped")
+	}
+	if st := m.Status("key_b"); st.Usage.StorageBytes != 10 || st.Usage.RequestsToday != 0 {
+		t.Fatalf("expected the allocation to be kept, got %+v", st.Usage)
+	}
+	m.Status("key_c")
+	if len(m.st.Counters) != 1 {
+		t.Fatalf("expected Status to leave counters alone, got %v", m.st.Counters)
+	}
+}
+
+func TestManager_StopWritesPendingChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	m, err := NewManager(Options{StatePath: path, FlushEvery: time.Hour, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	_ = m.Consume("key_a", 2)
+	m.Stop()
+
+	if st := testManager(t, path, Limits{}).Status("key_a"); st.Usage.RequestsToday != 2 {
+		t.Fatalf("expected the count written on stop, got %+v", st.Usage)
+	}
+}
@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"hash/maphash"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// shardCount is the number of shards of a MemoryCounter and of the limiters
+// behind Limit.
+const shardCount = 16
+
+// Limit is httprate.Limit spread over shards: each key is limited by one of
+// shardCount httprate limiters, picked by its hash. httprate serializes every
+// request of a limiter on one lock, which many distinct clients contend for;
+// here only clients of the same shard do. The shards share counter. Headers,
+// 429 responses and counter keys are those of httprate.Limit.
+func Limit(n int, window time.Duration, key httprate.KeyFunc, counter httprate.LimitCounter) func(http.Handler) http.Handler {
+	var shards [shardCount]*httprate.RateLimiter
+	for i := range shards {
+		shards[i] = httprate.NewRateLimiter(n, window, httprate.WithLimitCounter(counter))
+	}
+	seed := maphash.MakeSeed()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, err := key(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusPreconditionRequired)
+				return
+			}
+			// As httprate.WithKeyFuncs composes it, so Redis counters keep their keys
+			k += ":"
+			if shards[maphash.String(seed, k)%shardCount].RespondOnLimit(w, r, k) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MemoryCounter is an httprate.LimitCounter counting in process, for
+// httprate's sliding window. Keys are spread over shards, each with its own
+// lock, and only the current and previous window are kept: older counts are
+// dropped when a shard is next written to in a later window. At most maxKeys
+// counts are kept (a client in both windows has two); past that a new one
+// evicts another, from the previous window when it has any. An evicted client
+// starts counting from zero again, so maxKeys should exceed the number of
+// clients active in two windows. Counts kept and evicted are exported as
+// api_ratelimit_keys and api_ratelimit_evictions_total.
+type MemoryCounter struct {
+	name     string
+	maxShard int // counts per shard; 0 means unbounded
+	seed     maphash.Seed
+	window   time.Duration
+	keys     atomic.Int64
+	shards   [shardCount]counterShard
+}
+
+type counterShard struct {
+	mu       sync.Mutex
+	latest   time.Time // the window of current
+	current  map[uint64]int
+	previous map[uint64]int
+}
+
+var _ httprate.LimitCounter = (*MemoryCounter)(nil)
+
+// NewMemoryCounter returns a counter for the limiter called name tracking at
+// most maxKeys keys, or any number with 0.
+func NewMemoryCounter(name string, maxKeys int) *MemoryCounter {
+	c := &MemoryCounter{name: name, seed: maphash.MakeSeed()}
+	if maxKeys > 0 {
+		c.maxShard = max(1, maxKeys/shardCount)
+	}
+	for i := range c.shards {
+		c.shards[i].current = map[uint64]int{}
+		c.shards[i].previous = map[uint64]int{}
+	}
+	return c
+}
+
+// Config is called by httprate with the limiter's window, once per limiter
+// before it serves requests.
+func (c *MemoryCounter) Config(requestLimit int, windowLength time.Duration) {
+	c.window = windowLength
+}
+
+func (c *MemoryCounter) Increment(key string, currentWindow time.Time) error {
+	return c.IncrementBy(key, currentWindow, 1)
+}
+
+func (c *MemoryCounter) IncrementBy(key string, currentWindow time.Time, amount int) error {
+	h := maphash.String(c.seed, key)
+	s := &c.shards[h%shardCount]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := c.advance(s, currentWindow)
+	if counts == nil {
+		return nil
+	}
+	n, ok := counts[h]
+	if !ok {
+		c.makeRoom(s)
+		c.observeKeys(1)
+	}
+	counts[h] = n + amount
+	return nil
+}
+
+func (c *MemoryCounter) Get(key string, currentWindow, previousWindow time.Time) (int, int, error) {
+	h := maphash.String(c.seed, key)
+	s := &c.shards[h%shardCount]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.latest.Equal(currentWindow):
+		return s.current[h], s.previous[h], nil
+	case s.latest.Equal(previousWindow):
+		return 0, s.current[h], nil
+	}
+	return 0, 0, nil
+}
+
+// Keys returns the number of keys tracked.
+func (c *MemoryCounter) Keys() int {
+	return int(c.keys.Load())
+}
+
+// advance moves s to window, dropping the counts of windows before the
+// previous one, and returns the counts of window. A request that read the
+// clock just before another moved s on counts in the previous window; older
+// ones are not counted. Callers hold s.mu.
+func (c *MemoryCounter) advance(s *counterShard, window time.Time) map[uint64]int {
+	switch {
+	case s.latest.Equal(window):
+		return s.current
+	case window.Before(s.latest):
+		if window.Equal(s.latest.Add(-c.window)) {
+			return s.previous
+		}
+		return nil
+	}
+	expired := len(s.previous)
+	if !s.latest.Equal(window.Add(-c.window)) {
+		expired += len(s.current)
+		clear(s.current)
+	}
+	clear(s.previous)
+	s.current, s.previous = s.previous, s.current
+	s.latest = window
+	if expired > 0 {
+		c.observeKeys(-expired)
+		metrics.ObserveRateLimitEvictions(c.name, "expired", expired)
+	}
+	return s.current
+}
+
+// makeRoom evicts a count of s when adding one would exceed the shard's
+// share of maxKeys. Callers hold s.mu.
+func (c *MemoryCounter) makeRoom(s *counterShard) {
+	if c.maxShard == 0 || len(s.current)+len(s.previous) < c.maxShard {
+		return
+	}
+	for _, from := range []map[uint64]int{s.previous, s.current} {
+		for k := range from {
+			delete(from, k)
+			c.observeKeys(-1)
+			metrics.ObserveRateLimitEvictions(c.name, "capacity", 1)
+			return
+		}
+	}
+}
+
+func (c *MemoryCounter) observeKeys(delta int) {
+	metrics.SetRateLimitKeys(c.name, int(c.keys.Add(int64(delta))))
+}
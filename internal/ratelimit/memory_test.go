@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/mikko-kohtala/go-api/internal/redis"
+)
+
+func TestMemoryCounter_KeepsTwoWindows(t *testing.T) {
+	c := NewMemoryCounter("test", 0)
+	c.Config(10, time.Minute)
+	w0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w1, w2, w4 := w0.Add(time.Minute), w0.Add(2*time.Minute), w0.Add(4*time.Minute)
+
+	c.IncrementBy("a", w0, 3)
+	c.Increment("b", w0)
+	c.Increment("a", w1)
+	if cur, prev, _ := c.Get("a", w1, w0); cur != 1 || prev != 3 {
+		t.Fatalf("expected 1 and 3 in the current and previous window, got %d and %d", cur, prev)
+	}
+	if cur, prev, _ := c.Get("a", w2, w1); cur != 0 || prev != 1 {
+		t.Fatalf("expected the current window to become the previous one, got %d and %d", cur, prev)
+	}
+	// A request that read the clock before the window moved on counts in the previous one
+	c.Increment("a", w0)
+	if cur, prev, _ := c.Get("a", w1, w0); cur != 1 || prev != 4 {
+		t.Fatalf("expected the late increment in the previous window, got %d and %d", cur, prev)
+	}
+	if c.Keys() != 3 {
+		t.Fatalf("expected 3 counts kept, got %d", c.Keys())
+	}
+
+	c.Increment("a", w2)
+	if cur, prev, _ := c.Get("b", w2, w1); cur != 0 || prev != 0 {
+		t.Fatalf("expected b to expire, got %d and %d", cur, prev)
+	}
+	// Every shard is written to, dropping what it kept
+	for i := range 1000 {
+		c.Increment(strconv.Itoa(i), w4)
+	}
+	if c.Keys() != 1000 {
+		t.Fatalf("expected only the counts of window 4 after a gap, got %d", c.Keys())
+	}
+}
+
+func TestMemoryCounter_EvictsPastMaxKeys(t *testing.T) {
+	c := NewMemoryCounter("test", shardCount)
+	c.Config(10, time.Minute)
+	w0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w1 := w0.Add(time.Minute)
+
+	for i := range 1000 {
+		c.Increment(strconv.Itoa(i), w0)
+	}
+	if c.Keys() != shardCount {
+		t.Fatalf("expected one count per shard, got %d", c.Keys())
+	}
+	// The newest key of a full shard is kept
+	c.IncrementBy("last", w0, 5)
+	if cur, _, _ := c.Get("last", w0, w0.Add(-time.Minute)); cur != 5 {
+		t.Fatalf("expected the new key to be counted, got %d", cur)
+	}
+	// Counts of the previous window go first
+	c.IncrementBy("last", w1, 2)
+	if cur, prev, _ := c.Get("last", w1, w0); cur != 2 || prev != 0 || c.Keys() != shardCount {
+		t.Fatalf("expected the previous count evicted for the current one, got %d and %d of %d", cur, prev, c.Keys())
+	}
+}
+
+func TestLimit_LimitsEachKey(t *testing.T) {
+	limit := Limit(2, time.Minute, httprate.KeyByIP, NewMemoryCounter("test", 0))
+	h := limit(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(ip string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var wg sync.WaitGroup
+	codes := make([][3]int, 200)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip := "10.0." + strconv.Itoa(i/100) + "." + strconv.Itoa(i%100)
+			for j := range codes[i] {
+				codes[i][j] = serve(ip).Code
+			}
+		}()
+	}
+	wg.Wait()
+	for i, c := range codes {
+		if c != [3]int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+			t.Fatalf("client %d: expected two requests and a 429, got %v", i, c)
+		}
+	}
+
+	rr := serve("10.0.0.0")
+	if rr.Header().Get("X-RateLimit-Limit") != "2" || rr.Header().Get("X-RateLimit-Remaining") != "0" || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected the rate limit headers, got %v", rr.Header())
+	}
+}
+
+func TestLimit_KeysCountersAsHttprate(t *testing.T) {
+	ln := fakeRedis(t)
+	client := redis.New(redis.Options{Addr: ln.Addr().String()})
+	defer client.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	ours := Limit(1, time.Minute, httprate.KeyByIP, NewRedisCounter(client, "api", 0, logger))(ok)
+	theirs := httprate.Limit(1, time.Minute, httprate.WithKeyFuncs(httprate.KeyByIP),
+		httprate.WithLimitCounter(NewRedisCounter(client, "api", 0, logger)))(ok)
+
+	for i, h := range []http.Handler{ours, theirs} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		h.ServeHTTP(rr, req)
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; rr.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package ratelimit provides the counters behind the httprate limiters. The
+// default, MemoryCounter, keeps them in process, so each replica enforces the
+// limit on its own; RedisCounter keeps them in Redis, so the limit holds
+// across replicas.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/mikko-kohtala/go-api/internal/redis"
+)
+
+// Backends of RATE_LIMIT_BACKEND
+const (
+	Memory = "memory"
+	Redis  = "redis"
+)
+
+// commandTimeout bounds each Redis command; requests wait on them.
+const commandTimeout = 250 * time.Millisecond
+
+// incrementScript adds to a window's count and lets the key expire once the
+// window can no longer be the previous one.
+const incrementScript = `local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return n`
+
+// RedisCounter is an httprate.LimitCounter keeping the count of each key and
+// window in Redis, for httprate's sliding window. While Redis fails it falls
+// back to counting in process, so requests are limited per replica rather
+// than refused or let through unchecked.
+type RedisCounter struct {
+	client *redis.Client
+	prefix string
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	window   time.Duration
+	fallback *MemoryCounter
+	failing  bool
+}
+
+var _ httprate.LimitCounter = (*RedisCounter)(nil)
+
+// NewRedisCounter returns a counter for the limiter called name; limiters
+// sharing a client need different names. While Redis fails it counts at most
+// maxKeys keys in process, as NewMemoryCounter.
+func NewRedisCounter(client *redis.Client, name string, maxKeys int, logger *slog.Logger) *RedisCounter {
+	return &RedisCounter{client: client, prefix: "ratelimit:" + name + ":", logger: logger, fallback: NewMemoryCounter(name, maxKeys)}
+}
+
+// Config is called by httprate with the limiter's window.
+func (c *RedisCounter) Config(requestLimit int, windowLength time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.window = windowLength
+	c.fallback.Config(requestLimit, windowLength)
+}
+
+func (c *RedisCounter) Increment(key string, currentWindow time.Time) error {
+	return c.IncrementBy(key, currentWindow, 1)
+}
+
+func (c *RedisCounter) IncrementBy(key string, currentWindow time.Time, amount int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	ttl := 2 * c.window
+	_, err := c.client.Do(ctx, "EVAL", incrementScript, 1, c.key(key, currentWindow), amount, ttl.Milliseconds())
+	if c.observe(err) {
+		return c.fallback.IncrementBy(key, currentWindow, amount)
+	}
+	return nil
+}
+
+func (c *RedisCounter) Get(key string, currentWindow, previousWindow time.Time) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	reply, err := c.client.Do(ctx, "MGET", c.key(key, currentWindow), c.key(key, previousWindow))
+	if c.observe(err) {
+		return c.fallback.Get(key, currentWindow, previousWindow)
+	}
+	counts, _ := reply.([]any)
+	if len(counts) != 2 {
+		return 0, 0, nil
+	}
+	return count(counts[0]), count(counts[1]), nil
+}
+
+func (c *RedisCounter) key(key string, window time.Time) string {
+	return c.prefix + key + ":" + strconv.FormatInt(window.Unix(), 10)
+}
+
+// observe reports whether err means falling back, logging when Redis starts
+// and stops failing.
+func (c *RedisCounter) observe(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err != nil && !c.failing:
+		c.failing = true
+		c.logger.Warn("rate limit counters unavailable; limiting per replica", slog.String("limiter", c.prefix), slog.String("error", err.Error()))
+	case err == nil && c.failing:
+		c.failing = false
+		c.logger.Info("rate limit counters recovered", slog.String("limiter", c.prefix))
+	}
+	return err != nil
+}
+
+// count converts a reply of MGET to a count; missing keys are 0.
+func count(v any) int {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
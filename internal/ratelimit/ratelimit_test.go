@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/mikko-kohtala/go-api/internal/redis"
+)
+
+// fakeRedis serves the commands RedisCounter sends from a map.
+func fakeRedis(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	counts := map[string]int{}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				rd := bufio.NewReader(c)
+				for {
+					args, err := readCommand(rd)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					var reply string
+					switch args[0] {
+					case "EVAL": // EVAL script 1 key amount ttl
+						n, _ := strconv.Atoi(args[4])
+						counts[args[3]] += n
+						reply = fmt.Sprintf(":%d\r\n", counts[args[3]])
+					case "MGET":
+						reply = fmt.Sprintf("*%d\r\n", len(args)-1)
+						for _, k := range args[1:] {
+							if n, ok := counts[k]; ok {
+								v := strconv.Itoa(n)
+								reply += fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+							} else {
+								reply += "$-1\r\n"
+							}
+						}
+					default:
+						reply = "-ERR unknown command\r\n"
+					}
+					mu.Unlock()
+					if _, err := io.WriteString(c, reply); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		v := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, v); err != nil {
+			return nil, err
+		}
+		args[i] = string(v[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCounter_LimitHoldsAcrossReplicas(t *testing.T) {
+	ln := fakeRedis(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	replica := func() http.Handler {
+		client := redis.New(redis.Options{Addr: ln.Addr().String()})
+		t.Cleanup(func() { client.Close() })
+		limit := httprate.Limit(2, time.Minute, httprate.WithKeyByIP(),
+			httprate.WithLimitCounter(NewRedisCounter(client, "api", 0, logger)))
+		return limit(ok)
+	}
+	a, b := replica(), replica()
+	serve := func(h http.Handler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(a); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("first request: %d remaining %q", rr.Code, rr.Header().Get("X-RateLimit-Remaining"))
+	}
+	if rr := serve(b); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("second request on the other replica: %d remaining %q", rr.Code, rr.Header().Get("X-RateLimit-Remaining"))
+	}
+	rr := serve(a)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" || rr.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("expected 429 with rate limit headers, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestRedisCounter_FallsBackWhileRedisIsDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client := redis.New(redis.Options{Addr: addr})
+	defer client.Close()
+	limit := httprate.Limit(1, time.Minute, httprate.WithKeyByIP(),
+		httprate.WithLimitCounter(NewRedisCounter(client, "api", 0, slog.New(slog.NewTextHandler(io.Discard, nil)))))
+	h := limit(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	codes := make([]int, 2)
+	for i := range codes {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes[i] = rr.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("expected the replica to limit on its own, got %v", codes)
+	}
+}
@@ -0,0 +1,281 @@
+// Package redis is a minimal Redis client speaking RESP2 over TCP: commands
+// with their replies and pub/sub subscriptions, which is all the API needs
+// from Redis, without a driver dependency.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error is an error reply from the server, e.g. "WRONGTYPE ...".
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// ErrNil is returned by the typed helpers for a nil reply.
+var ErrNil = errors.New("redis: nil reply")
+
+// defaultTimeout bounds dialing and each command when the context has no
+// earlier deadline.
+const defaultTimeout = 5 * time.Second
+
+// Options locate and authenticate a server.
+type Options struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	DB       int
+}
+
+// ParseURL parses redis://[[user]:password@]host[:port][/db].
+func ParseURL(raw string) (Options, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "redis" || u.Hostname() == "" {
+		return Options{}, fmt.Errorf("redis: invalid URL %q, want redis://[:password@]host[:port][/db]", raw)
+	}
+	opts := Options{Addr: u.Host}
+	if u.Port() == "" {
+		opts.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if opts.DB, err = strconv.Atoi(db); err != nil || opts.DB < 0 {
+			return Options{}, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return opts, nil
+}
+
+// Client runs commands over a single connection, one at a time, dialing
+// again after a connection error.
+type Client struct {
+	opts Options
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// New returns a client for opts; it connects on the first command.
+func New(opts Options) *Client {
+	return &Client{opts: opts}
+}
+
+// Do sends the command args and returns its reply: string for simple and
+// bulk strings, int64 for integers, []any for arrays and nil for a nil reply.
+// An error reply is returned as an Error.
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		cn, err := dial(ctx, c.opts)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = cn
+	}
+	reply, err := c.conn.do(ctx, args...)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state; start over next time
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// Int runs the command and returns its integer reply.
+func (c *Client) Int(ctx context.Context, args ...any) (int64, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, fmt.Errorf("redis: unexpected reply %T", reply)
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Subscribe subscribes to channel on a connection of its own and calls fn
+// with every message, once subscribed calling ready if it is not nil. It
+// blocks until ctx ends, returning nil, or the connection fails.
+func Subscribe(ctx context.Context, opts Options, channel string, ready func(), fn func(payload []byte)) error {
+	cn, err := dial(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer cn.Close()
+	stop := context.AfterFunc(ctx, func() { cn.Close() })
+	defer stop()
+
+	if err := cn.write("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	_ = cn.SetDeadline(time.Time{})
+	for {
+		reply, err := cn.read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		msg, ok := reply.([]any)
+		if !ok || len(msg) != 3 {
+			continue
+		}
+		switch kind, _ := msg[0].(string); kind {
+		case "subscribe":
+			if ready != nil {
+				ready()
+			}
+		case "message":
+			if payload, ok := msg[2].(string); ok {
+				fn([]byte(payload))
+			}
+		}
+	}
+}
+
+type conn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+func dial(ctx context.Context, opts Options) (*conn, error) {
+	d := net.Dialer{Timeout: defaultTimeout}
+	nc, err := d.DialContext(ctx, "tcp", opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	cn := &conn{Conn: nc, rd: bufio.NewReader(nc)}
+	if opts.Password != "" {
+		args := []any{"AUTH", opts.Password}
+		if opts.Username != "" {
+			args = []any{"AUTH", opts.Username, opts.Password}
+		}
+		if _, err := cn.do(ctx, args...); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if opts.DB != 0 {
+		if _, err := cn.do(ctx, "SELECT", opts.DB); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (cn *conn) do(ctx context.Context, args ...any) (any, error) {
+	deadline := time.Now().Add(defaultTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = cn.SetDeadline(deadline)
+	if err := cn.write(args...); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+// write sends args as an array of bulk strings.
+func (cn *conn) write(args ...any) error {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		var s string
+		switch v := a.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			s = fmt.Sprint(v)
+		}
+		b.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+	}
+	_, err := io.WriteString(cn.Conn, b.String())
+	return err
+}
+
+// read parses one reply.
+func (cn *conn) read() (any, error) {
+	line, err := cn.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = cn.read(); err != nil {
+				var replyErr Error
+				if !errors.As(err, &replyErr) {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
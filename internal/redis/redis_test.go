@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers each command with the reply reply returns for it.
+func fakeServer(t *testing.T, reply func(args []string) string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				cn := &conn{Conn: c, rd: bufio.NewReader(c)}
+				for {
+					v, err := cn.read()
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, a := range v.([]any) {
+						args = append(args, a.(string))
+					}
+					if _, err := c.Write([]byte(reply(args))); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestParseURL(t *testing.T) {
+	opts, err := ParseURL("redis://:secret@cache.internal/2")
+	if err != nil || opts.Addr != "cache.internal:6379" || opts.Password != "secret" || opts.DB != 2 {
+		t.Fatalf("unexpected options %+v err=%v", opts, err)
+	}
+	for _, bad := range []string{"http://host", "redis://", "redis://host/x"} {
+		if _, err := ParseURL(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	var seen []string
+	addr := fakeServer(t, func(args []string) string {
+		seen = append(seen, strings.Join(args, " "))
+		switch args[0] {
+		case "AUTH", "SELECT":
+			return "+OK\r\n"
+		case "PUBLISH":
+			return ":2\r\n"
+		case "GET":
+			return "$5\r\nhello\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	c := New(Options{Addr: addr, Password: "pw", DB: 1})
+	defer c.Close()
+	ctx := context.Background()
+
+	if n, err := c.Int(ctx, "PUBLISH", "ch", []byte("msg")); err != nil || n != 2 {
+		t.Fatalf("PUBLISH = %d, %v", n, err)
+	}
+	if v, err := c.Do(ctx, "GET", "k"); err != nil || v != "hello" {
+		t.Fatalf("GET = %v, %v", v, err)
+	}
+	var replyErr Error
+	if _, err := c.Do(ctx, "NOPE"); !errors.As(err, &replyErr) {
+		t.Fatalf("expected an error reply, got %v", err)
+	}
+	// An error reply keeps the connection; AUTH and SELECT ran once
+	if _, err := c.Do(ctx, "GET", "k"); err != nil {
+		t.Fatalf("GET after error reply: %v", err)
+	}
+	if want := "AUTH pw,SELECT 1,PUBLISH ch msg,GET k,NOPE,GET k"; strings.Join(seen, ",") != want {
+		t.Fatalf("commands = %q, want %q", strings.Join(seen, ","), want)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	addr := fakeServer(t, func(args []string) string {
+		if args[0] != "SUBSCRIBE" {
+			return "-ERR unexpected\r\n"
+		}
+		return "*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$3\r\none\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$3\r\ntwo\r\n"
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []string
+	ready := false
+	err := Subscribe(ctx, Options{Addr: addr}, "ch", func() { ready = true }, func(p []byte) {
+		got = append(got, string(p))
+		if len(got) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe returned %v", err)
+	}
+	if !ready || strings.Join(got, ",") != "one,two" {
+		t.Fatalf("ready=%v messages=%v", ready, got)
+	}
+}
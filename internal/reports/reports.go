@@ -0,0 +1,186 @@
+// Package reports renders tabular data as downloadable CSV or PDF documents.
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Table is a titled grid of string cells.
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+}
+
+// Formats supported by Render, mapped to their content types.
+var ContentTypes = map[string]string{
+	"csv": "text/csv",
+	"pdf": "application/pdf",
+}
+
+// Render writes t in the given format ("csv" or "pdf").
+func Render(w io.Writer, t Table, format string) error {
+	switch format {
+	case "csv":
+		return WriteCSV(w, t)
+	case "pdf":
+		return WritePDF(w, t)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// WriteCSV writes the header row followed by the data rows.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		// Neutralise spreadsheet formulas so exported data cannot execute in Excel
+		safe := make([]string, len(row))
+		for i, cell := range row {
+			if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+				cell = "'" + cell
+			}
+			safe[i] = cell
+		}
+		if err := cw.Write(safe); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// PDF layout: US Letter in points, monospaced text.
+const (
+	pageWidth     = 612
+	pageHeight    = 792
+	margin        = 40
+	fontSize      = 9
+	lineHeight    = 12
+	maxCellWidth  = 32
+	linesPerPage  = (pageHeight - 2*margin) / lineHeight
+	charsPerLine  = (pageWidth - 2*margin) * 10 / (fontSize * 6) // Courier glyphs are 0.6em wide
+	columnSpacing = 2
+)
+
+// WritePDF renders t as a plain text table in a minimal PDF 1.4 document using
+// the built-in Courier font, so no font embedding or external library is needed.
+func WritePDF(w io.Writer, t Table) error {
+	lines := tableLines(t)
+	var pages [][]string
+	for len(lines) > 0 {
+		n := min(linesPerPage, len(lines))
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	// Objects 1-3 are the catalog, page tree and font; each page adds a page and a content stream
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 5+2*i))
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", fontSize, lineHeight, margin, pageHeight-margin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// tableLines lays the table out as fixed width text lines.
+func tableLines(t Table) []string {
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = min(maxCellWidth, len([]rune(c)))
+	}
+	for _, row := range t.Rows {
+		for i := 0; i < len(row) && i < len(widths); i++ {
+			widths[i] = max(widths[i], min(maxCellWidth, len([]rune(row[i]))))
+		}
+	}
+
+	format := func(cells []string) string {
+		var sb strings.Builder
+		for i, w := range widths {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			r := []rune(cell)
+			if len(r) > w {
+				r = append(r[:w-1], '~')
+			}
+			sb.WriteString(string(r))
+			sb.WriteString(strings.Repeat(" ", w-len(r)+columnSpacing))
+		}
+		line := []rune(strings.TrimRight(sb.String(), " "))
+		if len(line) > charsPerLine {
+			line = line[:charsPerLine]
+		}
+		return string(line)
+	}
+
+	lines := []string{t.Title, "", format(t.Columns)}
+	rule := make([]string, len(widths))
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+	lines = append(lines, format(rule))
+	for _, row := range t.Rows {
+		lines = append(lines, format(row))
+	}
+	return lines
+}
+
+// pdfEscape escapes string delimiters and maps text to WinAnsi (Latin-1),
+// replacing characters the standard fonts cannot show.
+func pdfEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r > 0xff:
+			sb.WriteByte('?')
+		case r >= 0x80:
+			fmt.Fprintf(&sb, "\\%03o", r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV_EscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, Table{Columns: []string{"name", "note"}, Rows: [][]string{{"Ann", "=HYPERLINK(\"x\")"}, {"Bob", "a,b"}}})
+	if err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	want := "name,note\nAnn,\"'=HYPERLINK(\"\"x\"\")\"\nBob,\"a,b\"\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected csv:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWritePDF_Paginates(t *testing.T) {
+	rows := make([][]string, 150)
+	for i := range rows {
+		rows[i] = []string{fmt.Sprint(i), "name (with parens) \\ and ü"}
+	}
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, Table{Title: "Users", Columns: []string{"id", "name"}, Rows: rows}); err != nil {
+		t.Fatalf("write pdf: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("missing PDF header or trailer")
+	}
+	if !strings.Contains(out, "/Count 3") {
+		t.Fatalf("expected 154 lines to span 3 pages")
+	}
+	if !strings.Contains(out, `name \(with parens\) \\ and \374`) {
+		t.Fatalf("expected escaped text in content stream")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build pgx
+
+package repository
+
+// Registers the "pgx" database/sql driver.
+import _ "github.com/jackc/pgx/v5/stdlib"
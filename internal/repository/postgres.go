@@ -0,0 +1,43 @@
+// Package repository holds the database-backed repositories the services
+// store their data in when a database is configured.
+//
+// The Postgres repositories use database/sql with the pgx driver, which is
+// linked in only when the binary is built with -tags pgx (after
+// go get github.com/jackc/pgx/v5); without it Open fails and the API keeps
+// its data in memory.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// driverName is the database/sql driver the Postgres repositories use.
+const driverName = "pgx"
+
+// ErrNoDriver is returned by Open when the binary was built without the
+// Postgres driver.
+var ErrNoDriver = errors.New("postgres driver not linked in; build with -tags pgx")
+
+// Open connects to the Postgres database at url and checks that it answers
+// within timeout.
+func Open(ctx context.Context, url string, timeout time.Duration) (*sql.DB, error) {
+	if !slices.Contains(sql.Drivers(), driverName) {
+		return nil, ErrNoDriver
+	}
+	db, err := sql.Open(driverName, url)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,16 @@
+//go:build !pgx
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpen_WithoutDriver(t *testing.T) {
+	if _, err := Open(context.Background(), "postgres://localhost/api", time.Second); !errors.Is(err, ErrNoDriver) {
+		t.Fatalf("expected ErrNoDriver in a build without -tags pgx, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/services"
+)
+
+// usersSchema creates the users table PostgresUsers stores users in, and
+// adds the tenant column to tables created before it, whose users go to the
+// shared partition. IDs are unique across tenants, emails within a tenant.
+var usersSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+	id         TEXT PRIMARY KEY,
+	tenant     TEXT NOT NULL DEFAULT '',
+	email      TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS users_tenant_email_key ON users (tenant, email)`,
+}
+
+// ErrUserIDTaken is returned by Insert when a user with the ID exists.
+var ErrUserIDTaken = errors.New("user id already taken")
+
+// PostgresUsers is a services.UserRepository on a Postgres users table. Like
+// the in-memory repository it keeps each tenant's users apart: every query
+// is restricted to the tenant of services.PartitionOf, so users of another
+// tenant are not listed and their IDs answer services.ErrUserNotFound.
+type PostgresUsers struct {
+	db *sql.DB
+}
+
+// NewPostgresUsers returns a repository on db, creating or migrating its
+// table.
+func NewPostgresUsers(ctx context.Context, db *sql.DB) (*PostgresUsers, error) {
+	for _, stmt := range usersSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+	return &PostgresUsers{db: db}, nil
+}
+
+var _ services.UserRepository = (*PostgresUsers)(nil)
+
+func (p *PostgresUsers) List(ctx context.Context) ([]services.User, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT id, email, name, role, created_at FROM users WHERE tenant = $1 ORDER BY created_at, id`, services.PartitionOf(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []services.User
+	for rows.Next() {
+		var u services.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (p *PostgresUsers) Get(ctx context.Context, id string) (*services.User, error) {
+	var u services.User
+	err := p.db.QueryRowContext(ctx, `SELECT id, email, name, role, created_at FROM users WHERE tenant = $1 AND id = $2`, services.PartitionOf(ctx), id).
+		Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, services.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (p *PostgresUsers) GetMany(ctx context.Context, ids []string) ([]services.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := []any{services.PartitionOf(ctx)}
+	for i, id := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+2)
+		args = append(args, id)
+	}
+	rows, err := p.db.QueryContext(ctx, `SELECT id, email, name, role, created_at FROM users WHERE tenant = $1 AND id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []services.User
+	for rows.Next() {
+		var u services.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (p *PostgresUsers) Count(ctx context.Context) (int, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, `SELECT count(*) FROM users WHERE tenant = $1`, services.PartitionOf(ctx)).Scan(&n)
+	return n, err
+}
+
+func (p *PostgresUsers) Insert(ctx context.Context, user *services.User) error {
+	res, err := p.db.ExecContext(ctx,
+		`INSERT INTO users (id, tenant, email, name, role, created_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING`,
+		user.ID, services.PartitionOf(ctx), user.Email, user.Name, user.Role, user.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return err
+	}
+	// Nothing inserted: either the email or the ID is taken
+	taken, err := p.emailTaken(ctx, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return services.ErrEmailAlreadyExists
+	}
+	return ErrUserIDTaken
+}
+
+func (p *PostgresUsers) Save(ctx context.Context, user *services.User) error {
+	taken, err := p.emailTaken(ctx, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return services.ErrEmailAlreadyExists
+	}
+	res, err := p.db.ExecContext(ctx,
+		`UPDATE users SET email = $3, name = $4, role = $5 WHERE tenant = $1 AND id = $2`,
+		services.PartitionOf(ctx), user.ID, user.Email, user.Name, user.Role)
+	if err != nil {
+		return err
+	}
+	return affectedOne(res)
+}
+
+func (p *PostgresUsers) Delete(ctx context.Context, id string) error {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM users WHERE tenant = $1 AND id = $2`, services.PartitionOf(ctx), id)
+	if err != nil {
+		return err
+	}
+	return affectedOne(res)
+}
+
+// Shared reports that other instances write to the table too, so the user
+// service cannot track its changes itself.
+func (p *PostgresUsers) Shared() bool { return true }
+
+// emailTaken reports whether a user of ctx's tenant other than id has email.
+func (p *PostgresUsers) emailTaken(ctx context.Context, email, id string) (bool, error) {
+	var taken bool
+	err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE tenant = $1 AND email = $2 AND id <> $3)`,
+		services.PartitionOf(ctx), email, id).Scan(&taken)
+	return taken, err
+}
+
+// affectedOne returns services.ErrUserNotFound unless res changed a row.
+func affectedOne(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return services.ErrUserNotFound
+	}
+	return nil
+}
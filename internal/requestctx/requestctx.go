@@ -0,0 +1,148 @@
+// Package requestctx carries facts about a request that are established deep
+// in the handler chain, such as the authenticated principal or where a client
+// abandoned it, back out to the middleware that logs, meters and audits the
+// request.
+package requestctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Identity is the authenticated caller of a request, its principal.
+type Identity struct {
+	UserID string `json:"user_id"`
+	Tenant string `json:"tenant,omitempty"`
+	Method string `json:"method,omitempty"` // how the caller authenticated, e.g. "api_key" or "jwt"
+	// Scopes limit what the principal may do to routes declaring one of
+	// them; nil means unrestricted.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasScope reports whether the principal may use a route requiring scope.
+func (p Identity) HasScope(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+type stateKey struct{}
+
+// state is shared by every context derived from the one New returned, so a
+// principal set by an inner handler is visible to outer middleware.
+type state struct {
+	mu        sync.Mutex
+	principal *Identity
+	stage     string // where the handler noticed the client had gone
+	handler   string // see SetHandler
+}
+
+// New returns ctx with an empty per-request state. The outermost middleware
+// that needs to read the principal after the handler returns calls it.
+func New(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stateKey{}, &state{})
+}
+
+// SetPrincipal records p as the request's principal and returns ctx with the
+// request logger enriched with user_id and tenant, so every later log line
+// carries them. Authentication middleware calls it once the caller is known.
+func SetPrincipal(ctx context.Context, p Identity) context.Context {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		s = &state{}
+		ctx = context.WithValue(ctx, stateKey{}, s)
+	}
+	s.mu.Lock()
+	s.principal = &p
+	s.mu.Unlock()
+
+	attrs := []any{slog.String("user_id", p.UserID)}
+	if p.Tenant != "" {
+		attrs = append(attrs, slog.String("tenant", p.Tenant))
+	}
+	return pkglogger.IntoContext(ctx, pkglogger.FromContext(ctx).With(attrs...))
+}
+
+// Principal returns the request's authenticated principal, if any.
+func Principal(ctx context.Context) (Identity, bool) {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return Identity{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.principal == nil {
+		return Identity{}, false
+	}
+	return *s.principal, true
+}
+
+// Authenticated reports whether the request has a principal.
+func Authenticated(ctx context.Context) bool {
+	_, ok := Principal(ctx)
+	return ok
+}
+
+// SetHandler records name as the handler serving the request, for the
+// middleware that logs, meters and recovers it. Routes call it with the name
+// resolved when they were registered; a handler serving the request in
+// place of another, such as a canary's, calls it again.
+func SetHandler(ctx context.Context, name string) {
+	if s, ok := ctx.Value(stateKey{}).(*state); ok {
+		s.mu.Lock()
+		s.handler = name
+		s.mu.Unlock()
+	}
+}
+
+// Handler returns the name recorded by SetHandler, or "".
+func Handler(ctx context.Context) string {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handler
+}
+
+// ErrAbandoned is returned by Check once the client has disconnected.
+var ErrAbandoned = errors.New("request abandoned by client")
+
+// Check returns an error when ctx is done so long-running handlers can stop
+// between steps instead of finishing work nobody will receive. When the
+// client disconnected the error wraps ErrAbandoned, and stage (e.g.
+// "import_item") is recorded for the abandonment metric; a timeout returns
+// context.DeadlineExceeded.
+func Check(ctx context.Context, stage string) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if s, ok := ctx.Value(stateKey{}).(*state); ok {
+		s.mu.Lock()
+		if s.stage == "" {
+			s.stage = stage
+		}
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("%w: %w", ErrAbandoned, err)
+}
+
+// AbandonedStage returns the stage recorded by the first failing Check, or "".
+func AbandonedStage(ctx context.Context) string {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stage
+}
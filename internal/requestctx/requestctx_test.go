@@ -0,0 +1,66 @@
+package requestctx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+func TestSetPrincipal_VisibleToOuterContextAndLogs(t *testing.T) {
+	var buf bytes.Buffer
+	outer := pkglogger.IntoContext(New(context.Background()), slog.New(slog.NewJSONHandler(&buf, nil)))
+	if Authenticated(outer) {
+		t.Fatal("expected no principal before authentication")
+	}
+
+	inner := SetPrincipal(outer, Identity{UserID: "usr_001", Tenant: "acme", Method: "api_key"})
+	pkglogger.FromContext(inner).Info("handled")
+
+	if p, ok := Principal(outer); !ok || p.UserID != "usr_001" || p.Tenant != "acme" {
+		t.Fatalf("expected principal on outer context, got %+v %v", p, ok)
+	}
+	if logged := buf.String(); !strings.Contains(logged, `"user_id":"usr_001"`) || !strings.Contains(logged, `"tenant":"acme"`) {
+		t.Fatalf("expected log line enriched with principal, got %s", logged)
+	}
+}
+
+func TestSetPrincipal_WithoutState(t *testing.T) {
+	ctx := SetPrincipal(context.Background(), Identity{UserID: "usr_002"})
+	if p, ok := Principal(ctx); !ok || p.UserID != "usr_002" {
+		t.Fatalf("expected principal, got %+v %v", p, ok)
+	}
+}
+
+func TestCheck_RecordsAbandonedStage(t *testing.T) {
+	outer := New(context.Background())
+	inner, cancel := context.WithCancel(outer)
+
+	if err := Check(inner, "step_1"); err != nil {
+		t.Fatalf("expected nil before cancellation, got %v", err)
+	}
+	cancel()
+	if err := Check(inner, "step_2"); !errors.Is(err, ErrAbandoned) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ErrAbandoned wrapping context.Canceled, got %v", err)
+	}
+	_ = Check(inner, "step_3")
+	if got := AbandonedStage(outer); got != "step_2" {
+		t.Fatalf("expected first abandoned stage, got %q", got)
+	}
+}
+
+func TestCheck_TimeoutIsNotAbandonment(t *testing.T) {
+	ctx, cancel := context.WithDeadline(New(context.Background()), time.Now().Add(-time.Second))
+	defer cancel()
+	if err := Check(ctx, "step"); !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrAbandoned) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if got := AbandonedStage(ctx); got != "" {
+		t.Fatalf("expected no stage for a timeout, got %q", got)
+	}
+}
@@ -0,0 +1,339 @@
+// Package resource wires the standard CRUD routes for a resource type from a
+// Store: request validation, limit/offset pagination, ETags with conditional
+// requests, per-operation metrics and a description of the operations for
+// API documentation. Adding a resource takes a model, its create/update
+// request types and a store.
+package resource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	"github.com/mikko-kohtala/go-api/internal/httpabort"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/respwriter"
+	"github.com/mikko-kohtala/go-api/internal/validate"
+)
+
+// Errors a Store returns (possibly wrapped) to get a standard response.
+var (
+	ErrNotFound = errors.New("resource not found")
+	ErrConflict = errors.New("resource conflict")
+)
+
+func init() {
+	errmap.Register(ErrNotFound, http.StatusNotFound, "not_found", "Resource not found")
+	errmap.Register(ErrConflict, http.StatusConflict, "conflict", "Resource conflicts with an existing one")
+}
+
+// Entity is implemented by resource models.
+type Entity interface {
+	ResourceID() string
+}
+
+// Page selects a slice of a listing.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Store persists resources of type T, created from C and updated from U.
+// Request values passed to Create and Update have been validated.
+type Store[T Entity, C, U any] interface {
+	// List returns the items of page and the total number of items.
+	List(ctx context.Context, page Page) ([]T, int, error)
+	Get(ctx context.Context, id string) (T, error)
+	Create(ctx context.Context, req C) (T, error)
+	Update(ctx context.Context, id string, req U) (T, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// ChangeTracker is implemented by stores that know when their collection last
+// changed. Their list operation sends Last-Modified and answers
+// If-Modified-Since with 304 without listing. ok is false when the time is
+// unknown.
+type ChangeTracker interface {
+	LastModified(ctx context.Context) (t time.Time, ok bool)
+}
+
+// Paginate returns the items of page from all, for stores that keep their
+// items in memory.
+func Paginate[T any](all []T, page Page) []T {
+	if page.Offset >= len(all) {
+		return []T{}
+	}
+	end := min(page.Offset+page.Limit, len(all))
+	return all[page.Offset:end]
+}
+
+// Options configure a registered resource.
+type Options struct {
+	Name            string // singular name, e.g. "task"; labels metrics and logs
+	Path            string // route prefix, e.g. "/tasks"
+	Tag             string // documentation tag; defaults to Path without the slash
+	DefaultPageSize int    // default 20
+	MaxPageSize     int    // default 100
+	Logger          *slog.Logger
+
+	// ItemRoutes, when set, mounts extra routes under Path/{id}, such as
+	// actions like POST /{id}/complete. The ID is chi.URLParam(r, "id").
+	ItemRoutes func(r chi.Router)
+}
+
+// List is the response body of the list operation.
+type List[T any] struct {
+	Items  []T `json:"items"`
+	Count  int `json:"count"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Operation describes one registered route for API documentation.
+type Operation struct {
+	Name     string `json:"name"` // e.g. "tasks.get"
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Summary  string `json:"summary"`
+	Tag      string `json:"tag"`
+	Status   int    `json:"status"`   // success status
+	Failures []int  `json:"failures"` // documented error statuses
+}
+
+// Register mounts GET and POST on opts.Path and GET, PUT and DELETE on
+// opts.Path/{id}, and returns the operations it registered. Routes added by
+// opts.ItemRoutes are not included.
+func Register[T Entity, C, U any](r chi.Router, store Store[T, C, U], opts Options) []Operation {
+	if opts.DefaultPageSize <= 0 {
+		opts.DefaultPageSize = 20
+	}
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = 100
+	}
+	if opts.Tag == "" {
+		opts.Tag = strings.TrimPrefix(opts.Path, "/")
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	h := &handler[T, C, U]{store: store, opts: opts}
+
+	r.Route(opts.Path, func(r chi.Router) {
+		r.Get("/", h.observe("list", h.list))
+		r.Post("/", h.observe("create", h.create))
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", h.observe("get", h.get))
+			r.Put("/", h.observe("update", h.update))
+			r.Delete("/", h.observe("delete", h.delete))
+			if opts.ItemRoutes != nil {
+				opts.ItemRoutes(r)
+			}
+		})
+	})
+
+	item := opts.Path + "/{id}"
+	listFailures := []int{400, 500}
+	if _, ok := store.(ChangeTracker); ok {
+		listFailures = []int{304, 400, 500}
+	}
+	return []Operation{
+		{opts.Tag + ".list", http.MethodGet, opts.Path, "List " + opts.Tag, opts.Tag, http.StatusOK, listFailures},
+		{opts.Tag + ".create", http.MethodPost, opts.Path, "Create a " + opts.Name, opts.Tag, http.StatusCreated, []int{400, 409, 413, 415, 500}},
+		{opts.Tag + ".get", http.MethodGet, item, "Get a " + opts.Name, opts.Tag, http.StatusOK, []int{304, 404, 500}},
+		{opts.Tag + ".update", http.MethodPut, item, "Update a " + opts.Name, opts.Tag, http.StatusOK, []int{400, 404, 409, 412, 413, 415, 500}},
+		{opts.Tag + ".delete", http.MethodDelete, item, "Delete a " + opts.Name, opts.Tag, http.StatusNoContent, []int{404, 412, 500}},
+	}
+}
+
+type handler[T Entity, C, U any] struct {
+	store Store[T, C, U]
+	opts  Options
+}
+
+// observe answers an operation's returned error through response.FromError
+// and counts each answered operation in api_resource_operations_total.
+func (h *handler[T, C, U]) observe(op string, next func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ww := respwriter.Wrap(w)
+		if err := next(ww, r); err != nil {
+			response.FromError(ww, r, err)
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.ObserveResourceOperation(h.opts.Name, op, status)
+	}
+}
+
+func (h *handler[T, C, U]) list(w http.ResponseWriter, r *http.Request) error {
+	page, errs := h.page(r)
+	if errs != nil {
+		err := httpabort.New(http.StatusBadRequest, "invalid_request", "Invalid pagination")
+		err.Fields = errs
+		return err
+	}
+	if tracker, ok := h.store.(ChangeTracker); ok {
+		if lastModified, ok := tracker.LastModified(r.Context()); ok && response.NotModified(w, r, lastModified) {
+			return nil
+		}
+	}
+	items, total, err := h.store.List(r.Context(), page)
+	if err != nil {
+		return err
+	}
+	response.JSON(w, r, http.StatusOK, List[T]{Items: items, Count: len(items), Total: total, Limit: page.Limit, Offset: page.Offset})
+	return nil
+}
+
+// page reads the limit and offset query parameters.
+func (h *handler[T, C, U]) page(r *http.Request) (Page, map[string]string) {
+	page := Page{Limit: h.opts.DefaultPageSize}
+	errs := map[string]string{}
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > h.opts.MaxPageSize {
+			errs["limit"] = "must be between 1 and " + strconv.Itoa(h.opts.MaxPageSize)
+		}
+		page.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs["offset"] = "must be a non-negative integer"
+		}
+		page.Offset = n
+	}
+	if len(errs) > 0 {
+		return page, errs
+	}
+	return page, nil
+}
+
+func (h *handler[T, C, U]) get(w http.ResponseWriter, r *http.Request) error {
+	item, err := h.store.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		return err
+	}
+	etag := ETag(item)
+	w.Header().Set("ETag", etag)
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		response.NoBody(w, r, http.StatusNotModified)
+		return nil
+	}
+	response.JSON(w, r, http.StatusOK, item)
+	return nil
+}
+
+func (h *handler[T, C, U]) create(w http.ResponseWriter, r *http.Request) error {
+	var req C
+	if err := bind(r, &req); err != nil {
+		return err
+	}
+	item, err := h.store.Create(r.Context(), req)
+	if err != nil {
+		return err
+	}
+	h.opts.Logger.Info(h.opts.Name+" created", slog.String("id", item.ResourceID()))
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+item.ResourceID())
+	w.Header().Set("ETag", ETag(item))
+	response.JSON(w, r, http.StatusCreated, item)
+	return nil
+}
+
+func (h *handler[T, C, U]) update(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+	var req U
+	if err := bind(r, &req); err != nil {
+		return err
+	}
+	if err := h.precondition(r, id); err != nil {
+		return err
+	}
+	item, err := h.store.Update(r.Context(), id, req)
+	if err != nil {
+		return err
+	}
+	h.opts.Logger.Info(h.opts.Name+" updated", slog.String("id", id))
+	w.Header().Set("ETag", ETag(item))
+	response.JSON(w, r, http.StatusOK, item)
+	return nil
+}
+
+func (h *handler[T, C, U]) delete(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+	if err := h.precondition(r, id); err != nil {
+		return err
+	}
+	if err := h.store.Delete(r.Context(), id); err != nil {
+		return err
+	}
+	h.opts.Logger.Info(h.opts.Name+" deleted", slog.String("id", id))
+	response.NoBody(w, r, http.StatusNoContent)
+	return nil
+}
+
+// precondition checks If-Match against the current item, failing with 412
+// when it does not match. The check and the write are not atomic; stores that
+// need strict optimistic concurrency should also compare versions themselves.
+func (h *handler[T, C, U]) precondition(r *http.Request, id string) error {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return nil
+	}
+	current, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if !matchETag(ifMatch, ETag(current)) {
+		return httpabort.New(http.StatusPreconditionFailed, "precondition_failed", "The "+h.opts.Name+" has been modified")
+	}
+	return nil
+}
+
+// bind decodes and validates the JSON body into dst, failing with 400, 413
+// or 415 when it cannot.
+func bind(r *http.Request, dst any) error {
+	errs, err := validate.BindAndValidate(r, dst)
+	if err != nil {
+		if _, ok := validate.TooLarge(err); ok {
+			return err
+		}
+		return httpabort.New(http.StatusBadRequest, "invalid_request", "Invalid JSON")
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// ETag returns a strong entity tag for the JSON representation of v.
+func ETag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// matchETag reports whether an If-Match or If-None-Match header value
+// matches etag. Weak validators compare equal to their strong form.
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
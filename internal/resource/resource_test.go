@@ -0,0 +1,195 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type note struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+func (n note) ResourceID() string { return n.ID }
+
+type createNote struct {
+	Text string `json:"text" validate:"required,max=20"`
+}
+
+type updateNote struct {
+	Text string `json:"text" validate:"required,max=20"`
+}
+
+type noteStore struct {
+	mu    sync.Mutex
+	notes []note
+}
+
+func (s *noteStore) List(_ context.Context, page Page) ([]note, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Paginate(s.notes, page), len(s.notes), nil
+}
+
+func (s *noteStore) Get(_ context.Context, id string) (note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.notes {
+		if n.ID == id {
+			return n, nil
+		}
+	}
+	return note{}, ErrNotFound
+}
+
+func (s *noteStore) Create(_ context.Context, req createNote) (note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := note{ID: fmt.Sprintf("n%d", len(s.notes)+1), Text: req.Text}
+	s.notes = append(s.notes, n)
+	return n, nil
+}
+
+func (s *noteStore) Update(_ context.Context, id string, req updateNote) (note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.notes {
+		if s.notes[i].ID == id {
+			s.notes[i].Text = req.Text
+			return s.notes[i], nil
+		}
+	}
+	return note{}, ErrNotFound
+}
+
+func (s *noteStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.notes {
+		if s.notes[i].ID == id {
+			s.notes = append(s.notes[:i], s.notes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func newNotesRouter(t *testing.T) http.Handler {
+	t.Helper()
+	r := chi.NewRouter()
+	ops := Register[note, createNote, updateNote](r, &noteStore{}, Options{Name: "note", Path: "/notes", DefaultPageSize: 2})
+	if len(ops) != 5 {
+		t.Fatalf("expected 5 operations, got %d", len(ops))
+	}
+	return r
+}
+
+func do(h http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRegister_CRUD(t *testing.T) {
+	h := newNotesRouter(t)
+
+	rr := do(h, http.MethodPost, "/notes", `{"text":"first"}`, nil)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Location") != "/notes/n1" {
+		t.Fatalf("expected 201 with Location, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	etag := rr.Header().Get("ETag")
+
+	if rr := do(h, http.MethodGet, "/notes/n1", "", map[string]string{"If-None-Match": etag}); rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching ETag, got %d", rr.Code)
+	}
+	if rr := do(h, http.MethodPut, "/notes/n1", `{"text":"second"}`, map[string]string{"If-Match": `"stale"`}); rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for stale If-Match, got %d", rr.Code)
+	}
+	if rr := do(h, http.MethodPut, "/notes/n1", `{"text":"second"}`, map[string]string{"If-Match": etag}); rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Fatalf("expected 200 with a new ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if rr := do(h, http.MethodDelete, "/notes/n1", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := do(h, http.MethodGet, "/notes/n1", "", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rr.Code)
+	}
+}
+
+func TestRegister_ValidatesBodies(t *testing.T) {
+	h := newNotesRouter(t)
+
+	rr := do(h, http.MethodPost, "/notes", `{"text":""}`, nil)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "validation_error") {
+		t.Fatalf("expected validation error, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(h, http.MethodPost, "/notes", `{"unknown":1}`, nil); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown field, got %d", rr.Code)
+	}
+}
+
+func TestRegister_Paginates(t *testing.T) {
+	h := newNotesRouter(t)
+	for i := 0; i < 3; i++ {
+		do(h, http.MethodPost, "/notes", fmt.Sprintf(`{"text":"note %d"}`, i), nil)
+	}
+
+	var page List[note]
+	rr := do(h, http.MethodGet, "/notes?offset=2", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if page.Total != 3 || page.Count != 1 || page.Limit != 2 || page.Items[0].ID != "n3" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if rr := do(h, http.MethodGet, "/notes?limit=500", "", nil); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for limit above maximum, got %d", rr.Code)
+	}
+}
+
+// trackedNoteStore is a noteStore that knows when its notes last changed.
+type trackedNoteStore struct {
+	noteStore
+	changed time.Time
+}
+
+func (s *trackedNoteStore) LastModified(context.Context) (time.Time, bool) { return s.changed, true }
+
+func TestRegister_ListAnswersIfModifiedSince(t *testing.T) {
+	r := chi.NewRouter()
+	store := &trackedNoteStore{changed: time.Now().Add(-time.Hour)}
+	ops := Register[note, createNote, updateNote](r, store, Options{Name: "note", Path: "/notes"})
+	if ops[0].Failures[0] != http.StatusNotModified {
+		t.Fatalf("expected 304 documented for the list, got %v", ops[0].Failures)
+	}
+
+	rr := do(r, http.MethodGet, "/notes", "", nil)
+	lastModified := rr.Header().Get("Last-Modified")
+	if rr.Code != http.StatusOK || lastModified != store.changed.UTC().Format(http.TimeFormat) {
+		t.Fatalf("expected 200 with Last-Modified, got %d %q", rr.Code, lastModified)
+	}
+	if rr := do(r, http.MethodGet, "/notes?offset=1", "", map[string]string{"If-Modified-Since": lastModified}); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Fatalf("expected 304 for an unchanged collection, got %d", rr.Code)
+	}
+
+	store.changed = time.Now().Add(-time.Minute)
+	if rr := do(r, http.MethodGet, "/notes", "", map[string]string{"If-Modified-Since": lastModified}); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 after a change, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package response
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cacheable lets the caller's client keep the response for maxAge before
+// revalidating it. API responses depend on the caller, so shared caches are
+// not allowed to keep them. With a maxAge of 0 the client keeps the response
+// but revalidates it on every use. Either way the ETag or Last-Modified the
+// handler sets is what revalidation sends back: a response without one is
+// downloaded again in full once it is stale.
+func Cacheable(w http.ResponseWriter, maxAge time.Duration) {
+	if maxAge <= 0 {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge/time.Second)))
+}
+
+// NoStore forbids keeping the response anywhere: clients and proxies do not
+// store it, and neither do the server's own caches, such as the copies kept
+// to answer reads while a dependency is down. Validators set so far are
+// removed, since nothing is kept to revalidate, and NotModified sets none
+// afterwards.
+func NoStore(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Del("ETag")
+	h.Del("Last-Modified")
+}
+
+// Storable reports whether a response with header h may be kept by a cache,
+// i.e. its Cache-Control has no no-store directive. Server-side caches check
+// it before keeping a response.
+func Storable(h http.Header) bool {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), "no-store") {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package response
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Field naming policies
+const (
+	SnakeCase = "snake_case" // the json tags as written
+	CamelCase = "camelCase"
+)
+
+// Time formats
+const (
+	RFC3339     = "rfc3339"      // time.Time's own format, with fractional seconds
+	EpochMillis = "epoch_millis" // milliseconds since the Unix epoch, as a number
+)
+
+// Encoding is how JSON writes Go values. The json tags stay the single
+// source of field names; the policy is applied on top of them, to struct
+// fields only, so map keys (field errors, template data, ...) are sent as
+// they are.
+type Encoding struct {
+	FieldNames string // SnakeCase or CamelCase
+	Times      string // RFC3339 or EpochMillis
+	UTC        bool   // convert times to UTC before formatting
+}
+
+// DefaultEncoding writes values exactly as encoding/json does.
+var DefaultEncoding = Encoding{FieldNames: SnakeCase, Times: RFC3339}
+
+var currentEncoding atomic.Pointer[Encoding]
+
+func init() {
+	e := DefaultEncoding
+	currentEncoding.Store(&e)
+}
+
+// SetEncoding sets how JSON writes responses. It returns an error for an
+// unknown policy or format.
+func SetEncoding(e Encoding) error {
+	if e.FieldNames != SnakeCase && e.FieldNames != CamelCase {
+		return fmt.Errorf("unknown field naming %q (want %s or %s)", e.FieldNames, SnakeCase, CamelCase)
+	}
+	if e.Times != RFC3339 && e.Times != EpochMillis {
+		return fmt.Errorf("unknown time format %q (want %s or %s)", e.Times, RFC3339, EpochMillis)
+	}
+	currentEncoding.Store(&e)
+	return nil
+}
+
+// encodable returns v as encoding/json should write it under the current
+// encoding: v itself for the default, otherwise a tree of maps and slices
+// with the policy applied.
+func encodable(v any) any {
+	e := currentEncoding.Load()
+	if *e == DefaultEncoding {
+		return v
+	}
+	return e.convert(reflect.ValueOf(v))
+}
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+	textType      = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// convert mirrors encoding/json's rules for v: json tags, omitempty,
+// embedded structs, and Marshalers, which are written as they marshal.
+func (e *Encoding) convert(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == timeType {
+		return e.time(v.Interface().(time.Time))
+	}
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface &&
+		(v.Type().Implements(marshalerType) || v.Type().Implements(textType)) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Pointer && v.Elem().Type() != timeType &&
+			(v.Type().Implements(marshalerType) || v.Type().Implements(textType)) {
+			return v.Interface()
+		}
+		return e.convert(v.Elem())
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		e.fields(v, out)
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[mapKey(iter.Key())] = e.convert(iter.Value())
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface() // []byte is written as base64
+		}
+		fallthrough
+	case reflect.Array:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = e.convert(v.Index(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// fields adds the exported fields of struct v to out, promoting those of
+// untagged embedded structs like encoding/json.
+func (e *Encoding) fields(v reflect.Value, out map[string]any) {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				ft, fv = ft.Elem(), fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				e.fields(fv, out)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmpty(fv) {
+			continue
+		}
+		if e.FieldNames == CamelCase {
+			name = camel(name)
+		}
+		out[name] = e.convert(fv)
+	}
+}
+
+func (e *Encoding) time(t time.Time) any {
+	if e.UTC {
+		t = t.UTC()
+	}
+	if e.Times == EpochMillis {
+		return t.UnixMilli()
+	}
+	return t
+}
+
+// isEmpty is encoding/json's omitempty test.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+func mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		b, _ := tm.MarshalText()
+		return string(b)
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// camel turns a snake_case name into camelCase: created_at -> createdAt.
+func camel(name string) string {
+	if !strings.Contains(name, "_") {
+		return name
+	}
+	var b strings.Builder
+	upper := false
+	for i, r := range name {
+		switch {
+		case r == '_' && i > 0:
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type encodingBase struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type encodingSample struct {
+	encodingBase
+	UserID    string            `json:"user_id"`
+	DueAt     *time.Time        `json:"due_at,omitempty"`
+	Skipped   string            `json:"skipped,omitempty"`
+	Fields    map[string]string `json:"fields"`
+	Raw       json.RawMessage   `json:"raw"`
+	Items     []any             `json:"items"`
+	internal  string
+	Untouched string `json:"-"`
+}
+
+func encodeWith(t *testing.T, e Encoding, v any) map[string]any {
+	t.Helper()
+	if err := SetEncoding(e); err != nil {
+		t.Fatalf("SetEncoding: %v", err)
+	}
+	t.Cleanup(func() { _ = SetEncoding(DefaultEncoding) })
+
+	rr := httptest.NewRecorder()
+	JSON(rr, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, v)
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", rr.Body, err)
+	}
+	return got
+}
+
+func TestJSON_Encoding(t *testing.T) {
+	helsinki := time.FixedZone("EET", 2*60*60)
+	created := time.Date(2026, 1, 2, 12, 0, 0, 0, helsinki)
+	v := encodingSample{
+		encodingBase: encodingBase{CreatedAt: created},
+		UserID:       "usr_001",
+		Fields:       map[string]string{"due_at": "must be in the future"},
+		Raw:          json.RawMessage(`{"kept_as":"is"}`),
+		Items:        []any{encodingBase{CreatedAt: created}},
+		internal:     "x",
+		Untouched:    "y",
+	}
+
+	got := encodeWith(t, Encoding{FieldNames: CamelCase, Times: EpochMillis, UTC: true}, v)
+	if got["userId"] != "usr_001" || got["createdAt"] != float64(created.UnixMilli()) {
+		t.Fatalf("expected camelCase names and epoch millis, got %v", got)
+	}
+	for _, key := range []string{"user_id", "dueAt", "skipped", "internal", "Untouched"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("unexpected key %q in %v", key, got)
+		}
+	}
+	if fields := got["fields"].(map[string]any); fields["due_at"] == nil {
+		t.Errorf("map keys must not be renamed, got %v", fields)
+	}
+	if raw := got["raw"].(map[string]any); raw["kept_as"] != "is" {
+		t.Errorf("marshalers must be written as they marshal, got %v", raw)
+	}
+	if item := got["items"].([]any)[0].(map[string]any); item["createdAt"] != float64(created.UnixMilli()) {
+		t.Errorf("nested values must be converted, got %v", item)
+	}
+
+	got = encodeWith(t, Encoding{FieldNames: SnakeCase, Times: RFC3339, UTC: true}, v)
+	if got["created_at"] != "2026-01-02T10:00:00Z" {
+		t.Fatalf("expected a UTC RFC 3339 time, got %v", got["created_at"])
+	}
+}
+
+func TestSetEncoding_RejectsUnknown(t *testing.T) {
+	if err := SetEncoding(Encoding{FieldNames: "kebab-case", Times: RFC3339}); err == nil {
+		t.Fatal("expected an error for an unknown field naming")
+	}
+	if err := SetEncoding(Encoding{FieldNames: SnakeCase, Times: "unix"}); err == nil {
+		t.Fatal("expected an error for an unknown time format")
+	}
+}
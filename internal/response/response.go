@@ -1,10 +1,22 @@
 package response
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/validate"
 	"github.com/mikko-kohtala/go-api/pkg/logger"
 )
 
@@ -14,33 +26,135 @@ import (
 // - Error: stable machine‑readable error code (e.g., "invalid_request", "validation_error").
 // - Message: human‑readable message safe to show to clients.
 // - Fields: optional field‑level messages for validation errors.
+// - Violations: validation errors with a JSON Pointer to each rejected value.
+// - Hint: optional guidance for the client (e.g. a suggested route).
 // - RequestID: echoes client request id when present.
+// - Stack: where an APIError was created; only when ExposeStacks is on.
 type ErrorResponse struct {
-	Error     string            `json:"error"`
-	Message   string            `json:"message,omitempty"`
-	Fields    map[string]string `json:"fields,omitempty"`
-	RequestID string            `json:"request_id,omitempty"`
+	Error      string               `json:"error"`
+	Message    string               `json:"message,omitempty"`
+	Fields     map[string]string    `json:"fields,omitempty"`
+	Violations []validate.Violation `json:"violations,omitempty"`
+	Hint       string               `json:"hint,omitempty"`
+	RequestID  string               `json:"request_id,omitempty"`
+	Stack      []string             `json:"stack,omitempty"`
+}
+
+// exposeStacks adds APIError stack traces to error responses; development only.
+var exposeStacks atomic.Bool
+
+// ExposeStacks sets whether FromError includes stack traces in responses.
+// Stacks are always logged.
+func ExposeStacks(on bool) {
+	exposeStacks.Store(on)
+}
+
+// skipWrite reports whether the request's context is done, in which case no
+// response should be written: the client has disconnected, or the request
+// timed out and the Timeout middleware answers instead. Skips are counted in
+// api_client_disconnects_total.
+func skipWrite(r *http.Request) bool {
+	err := r.Context().Err()
+	if err == nil {
+		return false
+	}
+	reason := "canceled"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "timeout"
+	}
+	metrics.ObserveClientDisconnect(reason)
+	logger.FromContext(r.Context()).Debug("skip response: context done", slog.String("reason", err.Error()))
+	return true
 }
 
 // JSON writes a JSON response with a status code and logs encoding failures.
+// Field names and times follow the encoding set with SetEncoding.
 func JSON(w http.ResponseWriter, r *http.Request, status int, v any) {
-	if err := r.Context().Err(); err != nil {
-		if l := logger.FromContext(r.Context()); l != nil {
-			l.Debug("skip json response: context done", slog.String("reason", err.Error()))
-		}
+	if skipWrite(r) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
+	if err := json.NewEncoder(w).Encode(encodable(v)); err != nil {
 		if l := logger.FromContext(r.Context()); l != nil {
 			l.Error("encode json response failed", slog.String("error", err.Error()))
 		}
 	}
 }
 
+// Marshal returns v as JSON the way JSON writes it, for payloads sent
+// outside HTTP responses, such as WebSocket messages.
+func Marshal(v any) ([]byte, error) {
+	return json.Marshal(encodable(v))
+}
+
+// NoBody writes a status without a body, e.g. 201 or 204, unless the
+// request's context is done.
+func NoBody(w http.ResponseWriter, r *http.Request, status int) {
+	if skipWrite(r) {
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// NotModified sets Last-Modified to lastModified and answers 304 when the
+// If-Modified-Since of a GET or HEAD request shows the client's copy is
+// current; the caller writes the response only when it returns false.
+// Last-Modified has one second resolution, so a change within the current
+// second gets no validator: a second change in that second would go unseen.
+// Responses marked with NoStore get no validator either.
+func NotModified(w http.ResponseWriter, r *http.Request, lastModified time.Time) bool {
+	if lastModified.IsZero() || !lastModified.Before(time.Now().Truncate(time.Second)) || !Storable(w.Header()) {
+		return false
+	}
+	lastModified = lastModified.Truncate(time.Second)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+	NoBody(w, r, http.StatusNotModified)
+	return true
+}
+
+// Bytes writes data with status unless the request's context is done. The
+// caller sets Content-Type and other headers.
+func Bytes(w http.ResponseWriter, r *http.Request, status int, data []byte) {
+	if skipWrite(r) {
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
 // Error writes a standardized error response.
 func Error(w http.ResponseWriter, r *http.Request, status int, code, message string, fields map[string]string) {
+	JSON(w, r, status, ErrorResponse{
+		Error:     code,
+		Message:   message,
+		Fields:    fields,
+		RequestID: RequestID(r),
+	})
+}
+
+// ValidationFailed writes the 400 for a request body that failed
+// validation, listing every violation.
+func ValidationFailed(w http.ResponseWriter, r *http.Request, errs validate.Errors) {
+	JSON(w, r, http.StatusBadRequest, ErrorResponse{
+		Error:      "validation_error",
+		Message:    "Validation failed",
+		Fields:     errs.Fields(),
+		Violations: errs,
+		RequestID:  RequestID(r),
+	})
+}
+
+// RequestID returns the request id to echo in error responses: the client's
+// X-Request-ID/X-Correlation-ID header, or the id generated for the request.
+func RequestID(r *http.Request) string {
 	rid := r.Header.Get("X-Request-ID")
 	if rid == "" {
 		rid = r.Header.Get("X-Correlation-ID")
@@ -48,10 +162,96 @@ func Error(w http.ResponseWriter, r *http.Request, status int, code, message str
 	if rid == "" {
 		rid = logger.RequestIDFromContext(r.Context())
 	}
-	JSON(w, r, status, ErrorResponse{
-		Error:     code,
-		Message:   message,
-		Fields:    fields,
-		RequestID: rid,
+	return rid
+}
+
+// PayloadTooLarge writes a 413 error stating the applicable body size limit.
+func PayloadTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	Error(w, r, http.StatusRequestEntityTooLarge, "payload_too_large",
+		fmt.Sprintf("Request body exceeds the %d byte limit", limit), nil)
+}
+
+// QuotaExceeded writes the error for a refused quota: 429 with Retry-After for
+// quotas that reset on their own, otherwise 403.
+func QuotaExceeded(w http.ResponseWriter, r *http.Request, e *quota.ExceededError) {
+	status := http.StatusForbidden
+	if !e.ResetAt.IsZero() {
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(e.ResetAt).Seconds())+1))
+	}
+	Error(w, r, status, "quota_exceeded", fmt.Sprintf("The %s quota of %d has been reached", e.Kind, e.Limit), map[string]string{
+		"quota": string(e.Kind),
+		"limit": strconv.FormatInt(e.Limit, 10),
+		"used":  strconv.FormatInt(e.Used, 10),
 	})
 }
+
+// Overloaded writes the error for background work refused by a job pool: 429
+// while its queue is over the high-water mark, 503 when the job could not be
+// queued at all, both with a Retry-After estimate.
+func Overloaded(w http.ResponseWriter, r *http.Request, e *jobs.OverloadedError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((e.RetryAfter+time.Second-1)/time.Second)))
+	if e.Err != nil {
+		Error(w, r, http.StatusServiceUnavailable, "busy", "Background work cannot be queued right now, retry shortly", nil)
+		return
+	}
+	Error(w, r, http.StatusTooManyRequests, "busy", "Too much background work is queued, retry later", nil)
+}
+
+// FromError writes the response for a failed operation: quota refusals as
+// QuotaExceeded, job pool refusals as Overloaded, validation errors as
+// ValidationFailed, bodies over their limit as PayloadTooLarge, APIErrors and
+// errors registered with errmap with their status, code and fields, and
+// anything else as a logged 500.
+func FromError(w http.ResponseWriter, r *http.Request, err error) {
+	var verrs validate.Errors
+	if errors.As(err, &verrs) {
+		ValidationFailed(w, r, verrs)
+		return
+	}
+	if limit, ok := validate.TooLarge(err); ok {
+		PayloadTooLarge(w, r, limit)
+		return
+	}
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		QuotaExceeded(w, r, exceeded)
+		return
+	}
+	var overloaded *jobs.OverloadedError
+	if errors.As(err, &overloaded) {
+		Overloaded(w, r, overloaded)
+		return
+	}
+	var apiErr *apierrors.APIError
+	if errors.As(err, &apiErr) {
+		stack := apierrors.Stack(apiErr)
+		level := slog.LevelDebug
+		if apiErr.Status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.FromContext(r.Context()).Log(r.Context(), level, "request failed",
+			slog.String("error", err.Error()),
+			slog.Int("status", apiErr.Status),
+			slog.Any("stack", stack))
+		resp := ErrorResponse{
+			Error:     apiErr.Code,
+			Message:   apiErr.Message,
+			Fields:    apiErr.Fields,
+			RequestID: RequestID(r),
+		}
+		if exposeStacks.Load() {
+			resp.Stack = stack
+		}
+		JSON(w, r, apiErr.Status, resp)
+		return
+	}
+	if m, ok := errmap.Lookup(err); ok {
+		Error(w, r, m.Status, m.Code, m.Message, errmap.Fields(err))
+		return
+	}
+	if l := logger.FromContext(r.Context()); l != nil {
+		l.Error("request failed", slog.String("error", err.Error()))
+	}
+	Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error", nil)
+}
@@ -3,10 +3,18 @@ package response
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/mikko-kohtala/go-api/internal/errmap"
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/validate"
 	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
 )
 
@@ -47,6 +55,25 @@ func TestErrorPrefersHeaderRequestID(t *testing.T) {
 	}
 }
 
+func TestValidationFailedListsViolations(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ValidationFailed(rr, httptest.NewRequest(http.MethodPost, "/", nil), validate.Errors{
+		{Pointer: "/address/street/0", Field: "street", Rule: "min", Params: map[string]any{"min": 3}, Value: "x", Message: "must be at least 3"},
+	})
+
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || rr.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	v := body["violations"].([]any)[0].(map[string]any)
+	if body["error"] != "validation_error" || v["pointer"] != "/address/street/0" || v["value"] != "x" || v["params"].(map[string]any)["min"] != 3.0 {
+		t.Fatalf("unexpected violations: %s", rr.Body.String())
+	}
+	if body["fields"].(map[string]any)["street"] != "must be at least 3" {
+		t.Fatalf("expected the flat fields map too: %s", rr.Body.String())
+	}
+}
+
 func TestJSONSkipsWhenContextCanceled(t *testing.T) {
 	rr := &recordingResponseWriter{ResponseWriter: httptest.NewRecorder()}
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -61,6 +88,21 @@ func TestJSONSkipsWhenContextCanceled(t *testing.T) {
 	}
 }
 
+func TestNoBodyAndBytesSkipWhenDeadlineExceeded(t *testing.T) {
+	rr := &recordingResponseWriter{ResponseWriter: httptest.NewRecorder()}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, cancel := context.WithDeadline(req.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	NoBody(rr, req, http.StatusNoContent)
+	Bytes(rr, req, http.StatusOK, []byte("data"))
+
+	if rr.writeHeaderCalls != 0 {
+		t.Fatalf("expected WriteHeader not to be called, got %d", rr.writeHeaderCalls)
+	}
+}
+
 type recordingResponseWriter struct {
 	http.ResponseWriter
 	writeHeaderCalls int
@@ -70,3 +112,112 @@ func (rw *recordingResponseWriter) WriteHeader(statusCode int) {
 	rw.writeHeaderCalls++
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
+
+func TestFromError(t *testing.T) {
+	errMissing := errors.New("widget missing")
+	errmap.Register(errMissing, http.StatusNotFound, "not_found", "Widget not found")
+
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("get widget: %w", errMissing), http.StatusNotFound, "not_found"},
+		{&quota.ExceededError{Kind: quota.StorageBytes, Limit: 1, Used: 1}, http.StatusForbidden, "quota_exceeded"},
+		{fmt.Errorf("queue report: %w", &jobs.OverloadedError{Pending: 8, HighWater: 8, RetryAfter: time.Second}), http.StatusTooManyRequests, "busy"},
+		{&jobs.OverloadedError{Err: jobs.ErrStopped, RetryAfter: time.Second}, http.StatusServiceUnavailable, "busy"},
+		{fmt.Errorf("bind: %w", validate.Errors{{Pointer: "/email", Field: "email", Rule: "email", Message: "must be a valid email"}}), http.StatusBadRequest, "validation_error"},
+		{fmt.Errorf("read body: %w", &http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge, "payload_too_large"},
+		{errors.New("disk on fire"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		FromError(rr, httptest.NewRequest(http.MethodGet, "/", nil), tc.err)
+		var resp ErrorResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if rr.Code != tc.status || resp.Error != tc.code {
+			t.Errorf("%v: got %d %q, want %d %q", tc.err, rr.Code, resp.Error, tc.status, tc.code)
+		}
+		if tc.status == http.StatusInternalServerError && resp.Message != "Internal server error" {
+			t.Errorf("internal error details leaked: %q", resp.Message)
+		}
+	}
+}
+
+func TestFromError_APIErrorStackOnlyWhenExposed(t *testing.T) {
+	err := apierrors.New(http.StatusConflict, "conflict", "Widget changed")
+	for _, expose := range []bool{false, true} {
+		ExposeStacks(expose)
+		rr := httptest.NewRecorder()
+		FromError(rr, httptest.NewRequest(http.MethodGet, "/", nil), err)
+		var resp ErrorResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if rr.Code != http.StatusConflict || resp.Error != "conflict" || (len(resp.Stack) > 0) != expose {
+			t.Errorf("expose=%v: got %d %+v", expose, rr.Code, resp)
+		}
+	}
+	ExposeStacks(false)
+}
+
+func TestNotModified(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	check := func(method string, header map[string]string, lastModified time.Time) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(method, "/api/v1/tasks", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		return rr, NotModified(rr, req, lastModified)
+	}
+
+	rr, done := check(http.MethodGet, nil, changed)
+	if done || rr.Header().Get("Last-Modified") != "Sun, 01 Mar 2026 12:00:00 GMT" {
+		t.Fatalf("expected Last-Modified only, got %v %q", done, rr.Header().Get("Last-Modified"))
+	}
+	for _, tc := range []struct {
+		method string
+		header map[string]string
+		want   bool
+	}{
+		{http.MethodGet, map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 12:00:00 GMT"}, true},
+		{http.MethodHead, map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 13:00:00 GMT"}, true},
+		{http.MethodGet, map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 11:59:59 GMT"}, false},
+		{http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, false},
+		{http.MethodGet, map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 12:00:00 GMT", "If-None-Match": `"x"`}, false},
+		{http.MethodPost, map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 12:00:00 GMT"}, false},
+	} {
+		rr, done := check(tc.method, tc.header, changed)
+		if done != tc.want || (done && rr.Code != http.StatusNotModified) {
+			t.Errorf("%s %v: got %v %d, want %v", tc.method, tc.header, done, rr.Code, tc.want)
+		}
+	}
+
+	// A change within the current second gets no validator yet
+	if rr, done := check(http.MethodGet, map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, time.Now()); done || rr.Header().Get("Last-Modified") != "" {
+		t.Fatalf("expected no validator for a change this second, got %v %q", done, rr.Header().Get("Last-Modified"))
+	}
+}
+
+func TestCachingHints(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Cacheable(rr, 5*time.Minute)
+	if got := rr.Header().Get("Cache-Control"); got != "private, max-age=300" {
+		t.Fatalf("Cacheable: %q", got)
+	}
+	Cacheable(rr, 0)
+	if got := rr.Header().Get("Cache-Control"); got != "private, no-cache" || !Storable(rr.Header()) {
+		t.Fatalf("Cacheable(0): %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	rr.Header().Set("ETag", `"v1"`)
+	NoStore(rr)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	req.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	if NotModified(rr, req, time.Now().Add(-time.Hour)) || rr.Header().Get("ETag") != "" || rr.Header().Get("Last-Modified") != "" {
+		t.Fatalf("expected no validators on a no-store response: %v", rr.Header())
+	}
+	if Storable(rr.Header()) || Storable(http.Header{"Cache-Control": {"private, No-Store"}}) {
+		t.Fatal("no-store responses are not storable")
+	}
+}
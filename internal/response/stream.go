@@ -0,0 +1,189 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/streams"
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Event is one server-sent event. Data that is not a string is written as
+// JSON, the way JSON writes response bodies; a string is sent as it is.
+type Event struct {
+	ID    string        // sent back by reconnecting clients as Last-Event-ID
+	Event string        // the event type; clients see those without one as "message"
+	Data  any           // the payload
+	Retry time.Duration // how long clients wait before reconnecting; 0 leaves it as it is
+}
+
+const (
+	// streamKeepalive is how often an idle stream sends a comment, so
+	// proxies and load balancers do not close it as idle.
+	streamKeepalive = 15 * time.Second
+	// streamWriteTimeout bounds each event written, so a client that stops
+	// reading ends its stream instead of holding it open.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// errStreamClosed is returned by send once the stream has ended.
+var errStreamClosed = errors.New("event stream closed")
+
+// AcceptsEventStream reports whether r asks for server-sent events, as
+// EventSource does. The Timeout and Compress middleware leave such requests
+// alone: streams outlive REQUEST_TIMEOUT and every event must reach the
+// client as soon as it is sent.
+func AcceptsEventStream(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			if mt, _, err := mime.ParseMediaType(part); err == nil && mt == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Stream answers r with a text/event-stream and calls produce with a send
+// function writing one event and flushing it. It returns when produce does,
+// when the client goes away, or when the server shuts down, which ends the
+// stream with a "close" event asking the client to reconnect (to another
+// replica) a second later. ctx, passed to produce, is canceled in the last
+// two cases and send fails from then on. While the server drains no stream
+// is started and r is answered with 503. Idle streams send a comment every
+// 15 seconds. A produce error is logged; the client only sees the stream
+// end, since the status has been sent.
+func Stream(w http.ResponseWriter, r *http.Request, produce func(ctx context.Context, send func(Event) error) error) {
+	if skipWrite(r) {
+		return
+	}
+	stream, err := streams.Default.Open()
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		Error(w, r, http.StatusServiceUnavailable, "draining", "Server is shutting down", nil)
+		return
+	}
+	defer stream.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("X-Accel-Buffering", "no") // nginx would buffer the events
+	h.Del("Content-Length")
+	NoStore(w)
+	rc := http.NewResponseController(w)
+	w.WriteHeader(http.StatusOK)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	var (
+		mu     sync.Mutex
+		closed bool
+	)
+	write := func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return errStreamClosed
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := w.Write(data); err != nil {
+			cancel()
+			return err
+		}
+		if err := rc.Flush(); err != nil {
+			cancel()
+			return err
+		}
+		return nil
+	}
+	// end keeps produce from writing once Stream has returned
+	end := func(last []byte) {
+		if last != nil {
+			_ = write(last)
+		}
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+		cancel()
+	}
+	if err := rc.Flush(); err != nil {
+		logger.FromContext(r.Context()).Warn("event stream not supported", slog.String("error", err.Error()))
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- produce(ctx, func(ev Event) error {
+			data, err := ev.encode()
+			if err != nil {
+				return err
+			}
+			return write(data)
+		})
+	}()
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case err := <-done:
+			end(nil)
+			if err != nil && ctx.Err() == nil {
+				logger.FromContext(r.Context()).Warn("event stream failed", slog.String("error", err.Error()))
+			}
+			return
+		case <-keepalive.C:
+			_ = write([]byte(": keepalive\n\n"))
+		case <-ctx.Done():
+			end(nil)
+			return
+		case <-stream.Shutdown():
+			last, _ := Event{Event: "close", Data: "server shutting down", Retry: time.Second}.encode()
+			end(last)
+			return
+		}
+	}
+}
+
+// encode returns e in the text/event-stream format.
+func (e Event) encode() ([]byte, error) {
+	var data string
+	switch v := e.Data.(type) {
+	case string:
+		data = strings.ReplaceAll(v, "\r\n", "\n")
+	default:
+		b, err := json.Marshal(encodable(v))
+		if err != nil {
+			return nil, err
+		}
+		data = string(b)
+	}
+	var b bytes.Buffer
+	if e.ID != "" {
+		b.WriteString("id: " + oneLine(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + oneLine(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// oneLine drops line breaks, which would end a field early.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
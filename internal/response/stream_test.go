@@ -0,0 +1,137 @@
+package response
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/streams"
+)
+
+func TestEvent_Encode(t *testing.T) {
+	got, err := Event{ID: "7", Event: "stats", Data: "line one\nline two", Retry: 1500 * time.Millisecond}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 7\nevent: stats\nretry: 1500\ndata: line one\ndata: line two\n\n"
+	if string(got) != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	got, _ = Event{Event: "in\njected", Data: map[string]int{"n": 1}}.encode()
+	if string(got) != "event: injected\ndata: {\"n\":1}\n\n" {
+		t.Fatalf("unexpected encoding %q", got)
+	}
+}
+
+func TestAcceptsEventStream(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json, text/event-stream;q=0.9")
+	if !AcceptsEventStream(r) {
+		t.Fatal("expected the request to accept an event stream")
+	}
+	r.Header.Set("Accept", "application/json")
+	if AcceptsEventStream(r) {
+		t.Fatal("expected a JSON request not to")
+	}
+}
+
+// withTracker swaps streams.Default for a tracker of the test's own, which
+// it can drain.
+func withTracker(t *testing.T) *streams.Tracker {
+	t.Helper()
+	prev := streams.Default
+	streams.Default = streams.NewTracker()
+	t.Cleanup(func() { streams.Default = prev })
+	return streams.Default
+}
+
+func TestStream_SendsEventsUntilShutdown(t *testing.T) {
+	tracker := withTracker(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(w, r, func(ctx context.Context, send func(Event) error) error {
+			if err := send(Event{Event: "hello", Data: "world"}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return nil
+		})
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+	br := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read event: %v", err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	if ev := readEvent(); ev != "event: hello\ndata: world\n" {
+		t.Fatalf("unexpected first event %q", ev)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := tracker.Drain(ctx); err != nil {
+		t.Fatalf("expected the stream to end on shutdown: %v", err)
+	}
+	if ev := readEvent(); ev != "event: close\nretry: 1000\ndata: server shutting down\n" {
+		t.Fatalf("expected the close event, got %q", ev)
+	}
+
+	rec := httptest.NewRecorder()
+	Stream(rec, httptest.NewRequest(http.MethodGet, "/", nil), func(context.Context, func(Event) error) error {
+		t.Fatal("expected no stream while draining")
+		return nil
+	})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", rec.Code)
+	}
+}
+
+func TestStream_EndsWhenClientLeaves(t *testing.T) {
+	tracker := withTracker(t)
+	ended := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(w, r, func(ctx context.Context, send func(Event) error) error {
+			_ = send(Event{Data: "first"})
+			<-ctx.Done()
+			return nil
+		})
+		close(ended)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = bufio.NewReader(resp.Body).ReadString('\n')
+	resp.Body.Close()
+	select {
+	case <-ended:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stream to end after the client left")
+	}
+	if tracker.Active() != 0 {
+		t.Fatal("expected the stream to be released")
+	}
+}
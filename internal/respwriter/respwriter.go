@@ -0,0 +1,139 @@
+// Package respwriter is the response writer wrapper shared by the middleware
+// that needs to observe or adjust a response: logging, metrics, timeouts,
+// header policy and compression. Wrapping once and reusing the wrapper keeps
+// the chain from growing a layer per middleware, and the wrapper passes
+// Flush, Hijack, Push and io.ReaderFrom through to the writer below, so
+// streaming responses, upgrades and sendfile keep working however the chain
+// is ordered.
+package respwriter
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Writer records the status and body size of a response, writes its header
+// exactly once and runs hooks just before the header goes out.
+type Writer struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+	hooks    []func(status int)
+}
+
+// Wrap returns w when it already is a *Writer, and a new Writer around w
+// otherwise. Middleware that wraps w in writers of its own in between gets a
+// new Writer below them, which is what it observes.
+func Wrap(w http.ResponseWriter) *Writer {
+	if rw, ok := w.(*Writer); ok {
+		return rw
+	}
+	return &Writer{ResponseWriter: w}
+}
+
+// Status returns the status written, or 0 when nothing was written yet.
+func (w *Writer) Status() int { return w.status }
+
+// BytesWritten returns the number of body bytes written.
+func (w *Writer) BytesWritten() int64 { return w.bytes }
+
+// Written reports whether the header was written or the connection hijacked,
+// after which nothing can be sent through w.
+func (w *Writer) Written() bool { return w.status != 0 || w.hijacked }
+
+// OnHeader registers fn to run, in registration order, just before the
+// header is written, when headers can still be changed.
+func (w *Writer) OnHeader(fn func(status int)) {
+	w.hooks = append(w.hooks, fn)
+}
+
+// WriteHeader writes the header the first time; later calls, from a handler
+// answering after a timeout or from several layers answering, are ignored.
+// Informational 1xx headers other than 101 pass through.
+func (w *Writer) WriteHeader(code int) {
+	if w.Written() {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	for _, fn := range w.hooks {
+		fn(code)
+	}
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom lets io.Copy use the underlying writer's ReadFrom, i.e. sendfile
+// for files.
+func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(onlyWriter{w.ResponseWriter}, r)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// Flush writes the header if needed and flushes buffered data to the client.
+func (w *Writer) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is Flush reporting whether the writer below supports it; it is
+// what http.ResponseController calls.
+func (w *Writer) FlushError() error {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection; afterwards w ignores writes of headers.
+func (w *Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push, or returns http.ErrNotSupported.
+func (w *Writer) Push(target string, opts *http.PushOptions) error {
+	for rw := w.ResponseWriter; rw != nil; {
+		if p, ok := rw.(http.Pusher); ok {
+			return p.Push(target, opts)
+		}
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		rw = u.Unwrap()
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *Writer) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// onlyWriter hides ReadFrom so io.Copy does not recurse into it.
+type onlyWriter struct{ io.Writer }
@@ -0,0 +1,84 @@
+package respwriter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// plainWriter hides everything but http.ResponseWriter.
+type plainWriter struct{ http.ResponseWriter }
+
+func TestWriter_WritesHeaderOnceAndRunsHooks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := Wrap(rec)
+	if Wrap(w) != w {
+		t.Fatal("Wrap should reuse a Writer")
+	}
+	var hooked []int
+	w.OnHeader(func(status int) {
+		hooked = append(hooked, status)
+		w.Header().Set("X-Hooked", "yes")
+	})
+
+	_, _ = io.Copy(w, strings.NewReader("hello"))
+	w.WriteHeader(http.StatusInternalServerError)
+
+	if w.Status() != http.StatusOK || rec.Code != http.StatusOK || w.BytesWritten() != 5 {
+		t.Fatalf("status=%d code=%d bytes=%d", w.Status(), rec.Code, w.BytesWritten())
+	}
+	if len(hooked) != 1 || hooked[0] != http.StatusOK || rec.Header().Get("X-Hooked") != "yes" {
+		t.Fatalf("hooks ran with %v, header %q", hooked, rec.Header().Get("X-Hooked"))
+	}
+}
+
+func TestWriter_FlushReachesThroughOtherWrappers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := Wrap(&plainWriter{rec})
+	if err := w.FlushError(); err == nil {
+		t.Fatal("expected an error from a writer that cannot flush")
+	}
+
+	rec = httptest.NewRecorder()
+	w = Wrap(Wrap(rec))
+	w.Flush()
+	if !rec.Flushed || rec.Code != http.StatusOK || w.Status() != http.StatusOK {
+		t.Fatalf("flushed=%v code=%d status=%d", rec.Flushed, rec.Code, w.Status())
+	}
+	if err := w.Push("/app.js", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported from Push, got %v", err)
+	}
+}
+
+func TestWriter_Hijack(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := Wrap(rw)
+		conn, buf, err := w.Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		w.WriteHeader(http.StatusInternalServerError) // ignored once hijacked
+		if !w.Written() || w.Status() != 0 {
+			t.Errorf("written=%v status=%d after hijack", w.Written(), w.Status())
+		}
+		_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = buf.Flush()
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(bufio.NewReader(resp.Body))
+	if string(body) != "hijacked" {
+		t.Fatalf("body = %q", body)
+	}
+}
@@ -0,0 +1,167 @@
+// Package retention enforces data retention policies: on a fixed interval each
+// policy purges or anonymizes the records of its target that are older than
+// the policy's maximum age.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Actions recorded in logs and metrics.
+const (
+	ActionPurge     = "purge"
+	ActionAnonymize = "anonymize"
+)
+
+// Target applies a policy to a data set.
+type Target interface {
+	// Apply handles records created before cutoff and returns how many were
+	// affected, or with dryRun how many would be.
+	Apply(ctx context.Context, cutoff time.Time, dryRun bool) (int, error)
+}
+
+// TargetFunc adapts a function to Target.
+type TargetFunc func(ctx context.Context, cutoff time.Time, dryRun bool) (int, error)
+
+// Apply calls f.
+func (f TargetFunc) Apply(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	return f(ctx, cutoff, dryRun)
+}
+
+// Policy removes or anonymizes records older than MaxAge.
+type Policy struct {
+	Name   string // unique; used in logs and metric labels
+	Action string // ActionPurge or ActionAnonymize
+	MaxAge time.Duration
+	Target Target
+}
+
+// Result is the outcome of applying one policy.
+type Result struct {
+	Policy   string
+	Action   string
+	Affected int
+	DryRun   bool
+	Err      error
+}
+
+// Options configure a Scheduler.
+type Options struct {
+	Interval time.Duration // time between runs; 0 disables scheduling
+	DryRun   bool          // report what would be affected without changing data
+}
+
+// Scheduler runs the registered policies periodically.
+type Scheduler struct {
+	opts Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	policies map[string]Policy
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Default is the process-wide scheduler. main replaces it with one configured
+// from the environment before building the router, which registers policies.
+var Default = NewScheduler(Options{})
+
+// NewScheduler creates a scheduler with no policies.
+func NewScheduler(opts Options) *Scheduler {
+	return &Scheduler{opts: opts, now: time.Now, policies: make(map[string]Policy)}
+}
+
+// Add registers p, replacing any policy with the same name. Policies with a
+// non-positive MaxAge are ignored.
+func (s *Scheduler) Add(p Policy) {
+	if p.MaxAge <= 0 || p.Target == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies[p.Name] = p
+}
+
+// Policies returns the registered policies ordered by name.
+func (s *Scheduler) Policies() []Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Policy, 0, len(s.policies))
+	for _, p := range s.policies {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// RunOnce applies every policy once, logging and recording metrics for each.
+func (s *Scheduler) RunOnce(ctx context.Context, logger *slog.Logger) []Result {
+	now := s.now()
+	var results []Result
+	for _, p := range s.Policies() {
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := p.Target.Apply(ctx, now.Add(-p.MaxAge), s.opts.DryRun)
+		res := Result{Policy: p.Name, Action: p.Action, Affected: n, DryRun: s.opts.DryRun, Err: err}
+		results = append(results, res)
+		metrics.ObserveRetention(p.Name, p.Action, s.opts.DryRun, n, err)
+
+		attrs := []any{
+			slog.String("policy", p.Name),
+			slog.String("action", p.Action),
+			slog.Int("affected", n),
+			slog.Bool("dry_run", s.opts.DryRun),
+		}
+		if err != nil {
+			logger.Error("retention policy failed", append(attrs, slog.String("error", err.Error()))...)
+			continue
+		}
+		logger.Info("retention policy applied", attrs...)
+	}
+	return results
+}
+
+// Start runs the policies every Interval in the background until Stop. It is a
+// no-op when the interval is zero or the scheduler is already running.
+func (s *Scheduler) Start(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opts.Interval <= 0 || s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done = cancel, make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RunOnce(ctx, logger)
+			}
+		}
+	}(s.done)
+}
+
+// Stop cancels a run in progress and waits for the background loop to exit.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,60 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func TestScheduler_RunOnceAppliesPoliciesWithCutoff(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := NewScheduler(Options{DryRun: true})
+	s.now = func() time.Time { return now }
+
+	var gotCutoff time.Time
+	var gotDryRun bool
+	s.Add(Policy{Name: "b-files", Action: ActionPurge, MaxAge: 30 * 24 * time.Hour, Target: TargetFunc(
+		func(_ context.Context, cutoff time.Time, dryRun bool) (int, error) {
+			gotCutoff, gotDryRun = cutoff, dryRun
+			return 3, nil
+		})})
+	s.Add(Policy{Name: "a-broken", Action: ActionAnonymize, MaxAge: time.Hour, Target: TargetFunc(
+		func(context.Context, time.Time, bool) (int, error) { return 0, errors.New("boom") })})
+	s.Add(Policy{Name: "disabled", MaxAge: 0, Target: TargetFunc(
+		func(context.Context, time.Time, bool) (int, error) { t.Fatal("disabled policy ran"); return 0, nil })})
+
+	results := s.RunOnce(context.Background(), testLogger())
+	if len(results) != 2 || results[0].Policy != "a-broken" || results[0].Err == nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].Affected != 3 || !results[1].DryRun {
+		t.Fatalf("unexpected files result: %+v", results[1])
+	}
+	if !gotDryRun || !gotCutoff.Equal(now.Add(-30*24*time.Hour)) {
+		t.Fatalf("unexpected cutoff %v / dry run %v", gotCutoff, gotDryRun)
+	}
+}
+
+func TestScheduler_StartRunsOnIntervalUntilStop(t *testing.T) {
+	s := NewScheduler(Options{Interval: 10 * time.Millisecond})
+	ran := make(chan struct{}, 10)
+	s.Add(Policy{Name: "p", Action: ActionPurge, MaxAge: time.Hour, Target: TargetFunc(
+		func(context.Context, time.Time, bool) (int, error) {
+			ran <- struct{}{}
+			return 0, nil
+		})})
+
+	s.Start(testLogger())
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("policy did not run")
+	}
+	s.Stop()
+	s.Stop() // idempotent
+}
@@ -0,0 +1,200 @@
+// Package routemeta holds what routes declare about themselves when they are
+// registered: a stable name, a description, the access they require, their
+// admission class, stability and deprecation. The route table is the single
+// source the OpenAPI document, request metrics, the /admin/routes endpoint,
+// admission classification and access checks read it from.
+//
+// It depends on nothing else in the application so that middleware running
+// before routing, and the metrics package, can consult it.
+package routemeta
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/admission"
+)
+
+// Access is the authentication a route requires.
+type Access string
+
+const (
+	// Public serves anyone. Routes that check their own credentials, such as
+	// signed download URLs, are public here.
+	Public Access = "public"
+	// Authenticated requires a principal set by authentication middleware;
+	// other requests get 401.
+	Authenticated Access = "authenticated"
+)
+
+// Stability is the compatibility promise made for a route.
+type Stability string
+
+const (
+	Stable       Stability = "stable"       // changes are backward compatible
+	Beta         Stability = "beta"         // may change with notice
+	Experimental Stability = "experimental" // may change or go away at any time
+)
+
+// Route is the metadata of one registered method and pattern.
+type Route struct {
+	Method      string          `json:"method"`
+	Pattern     string          `json:"pattern"` // full chi pattern, e.g. /api/v1/users/{userID}
+	Group       string          `json:"group"`   // route group from the exposure matrix
+	Name        string          `json:"name"`    // stable identifier, e.g. "users.get"; labels metrics
+	Description string          `json:"description"`
+	Auth        Access          `json:"auth"`
+	Scope       string          `json:"scope,omitempty"`      // what a scoped principal, such as an API key, needs; implies Authenticated
+	RateClass   admission.Class `json:"rate_class,omitempty"` // empty derives it from the path and method
+	Feature     string          `json:"feature,omitempty"`    // degrades while a dependency of the feature is down
+	Stability   Stability       `json:"stability"`
+	Deprecated  bool            `json:"deprecated,omitempty"`
+	Sunset      *time.Time      `json:"sunset,omitempty"`    // when a deprecated route goes away
+	Successor   string          `json:"successor,omitempty"` // path of the route replacing a deprecated one
+	Handler     string          `json:"handler,omitempty"`   // see HandlerName; empty for routes registered by other means
+}
+
+// Table is a set of routes keyed by method and pattern.
+type Table struct {
+	mu     sync.RWMutex
+	routes map[string]Route
+}
+
+// NewTable returns an empty table.
+func NewTable() *Table {
+	return &Table{routes: make(map[string]Route)}
+}
+
+// Default is the table Routes.Mount registers into.
+var Default = NewTable()
+
+// Add records route, replacing an earlier route with the same method and
+// pattern. Its pattern is normalized first.
+func (t *Table) Add(route Route) {
+	route.Pattern = Normalize(route.Pattern)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes[key(route.Method, route.Pattern)] = route
+}
+
+// Lookup returns the route registered for method and pattern, as reported by
+// chi's RoutePattern. HEAD falls back to the GET route answering it.
+func (t *Table) Lookup(method, pattern string) (Route, bool) {
+	pattern = Normalize(pattern)
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	route, ok := t.routes[key(method, pattern)]
+	if !ok && method == http.MethodHead {
+		route, ok = t.routes[key(http.MethodGet, pattern)]
+	}
+	return route, ok
+}
+
+// Match returns the route a request for method and path would be routed to,
+// for use before routing. Static segments win over parameters, as in chi.
+func (t *Table) Match(method, path string) (Route, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var best Route
+	bestScore := -1
+	for _, route := range t.routes {
+		if route.Method != method && (method != http.MethodHead || route.Method != http.MethodGet) {
+			continue
+		}
+		score, ok := matchScore(route.Pattern, path)
+		// An explicit HEAD route beats the GET answering HEAD
+		if ok && route.Method == method {
+			score++
+		}
+		if ok && score > bestScore {
+			best, bestScore = route, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// Routes returns every route ordered by pattern and method.
+func (t *Table) Routes() []Route {
+	t.mu.RLock()
+	out := make([]Route, 0, len(t.routes))
+	for _, route := range t.routes {
+		out = append(out, route)
+	}
+	t.mu.RUnlock()
+	slices.SortFunc(out, func(a, b Route) int {
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return out
+}
+
+// HandlerName returns the name of the function or type serving h as the
+// runtime and profiles know it, without the import path, e.g.
+// "handlers.(*UserHandler).GetUser". Resolve it once, when the route is
+// registered; it is too slow for every request.
+func HandlerName(h any) string {
+	v := reflect.ValueOf(h)
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() != reflect.Func {
+		return reflect.TypeOf(h).String() // e.g. *resource.handler
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name() // e.g. github.com/org/app/internal/handlers.(*UserHandler).GetUser-fm
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm") // method values
+}
+
+// Normalize drops the trailing slash chi leaves on patterns registered as
+// "/" inside a sub-router, so /users/ and /users name the same route.
+func Normalize(pattern string) string {
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if pattern == "" {
+		return "/"
+	}
+	return pattern
+}
+
+func key(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// matchScore reports whether pattern matches path, scoring static segments
+// twice as high as parameters so the most specific pattern wins.
+func matchScore(pattern, path string) (int, bool) {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	score := 0
+	for i, p := range ps {
+		if p == "*" {
+			return score, true
+		}
+		if i >= len(segs) {
+			return 0, false
+		}
+		switch {
+		case strings.HasPrefix(p, "{") && segs[i] != "":
+			score += 2
+		case p == segs[i]:
+			score += 4
+		default:
+			return 0, false
+		}
+	}
+	return score, len(ps) == len(segs)
+}
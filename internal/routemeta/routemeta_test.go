@@ -0,0 +1,70 @@
+package routemeta
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTable_LookupAndMatch(t *testing.T) {
+	table := NewTable()
+	table.Add(Route{Method: http.MethodGet, Pattern: "/users/", Name: "users.list"})
+	table.Add(Route{Method: http.MethodGet, Pattern: "/users/{userID}/", Name: "users.get"})
+	table.Add(Route{Method: http.MethodGet, Pattern: "/users/export", Name: "users.export"})
+	table.Add(Route{Method: http.MethodHead, Pattern: "/files/{fileID}", Name: "files.offset"})
+	table.Add(Route{Method: http.MethodGet, Pattern: "/files/{fileID}", Name: "files.get"})
+	table.Add(Route{Method: http.MethodGet, Pattern: "/static/*", Name: "static"})
+
+	for _, tc := range []struct{ method, pattern, want string }{
+		{http.MethodGet, "/users", "users.list"},
+		{http.MethodGet, "/users/{userID}/", "users.get"},
+		{http.MethodHead, "/users/{userID}", "users.get"}, // answered by GET
+		{http.MethodHead, "/files/{fileID}", "files.offset"},
+		{http.MethodPost, "/users", ""},
+	} {
+		if got, _ := table.Lookup(tc.method, tc.pattern); got.Name != tc.want {
+			t.Errorf("Lookup(%s %s) = %q, want %q", tc.method, tc.pattern, got.Name, tc.want)
+		}
+	}
+
+	for _, tc := range []struct{ method, path, want string }{
+		{http.MethodGet, "/users/usr_1", "users.get"},
+		{http.MethodGet, "/users/export", "users.export"}, // static beats {userID}
+		{http.MethodHead, "/files/f_1", "files.offset"},
+		{http.MethodGet, "/static/css/app.css", "static"},
+		{http.MethodGet, "/users/usr_1/extra", ""},
+		{http.MethodDelete, "/users/usr_1", ""},
+	} {
+		if got, _ := table.Match(tc.method, tc.path); got.Name != tc.want {
+			t.Errorf("Match(%s %s) = %q, want %q", tc.method, tc.path, got.Name, tc.want)
+		}
+	}
+
+	routes := table.Routes()
+	if len(routes) != 6 || routes[0].Pattern != "/files/{fileID}" || routes[0].Method != http.MethodGet {
+		t.Fatalf("expected routes ordered by pattern and method, got %+v", routes)
+	}
+}
+
+type namedHandler struct{}
+
+func (namedHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func (*namedHandler) Get(http.ResponseWriter, *http.Request) {}
+
+func TestHandlerName(t *testing.T) {
+	h := &namedHandler{}
+	for _, tc := range []struct {
+		h    any
+		want string
+	}{
+		{h.Get, "routemeta.(*namedHandler).Get"},
+		{http.HandlerFunc(h.Get), "routemeta.(*namedHandler).Get"},
+		{TestHandlerName, "routemeta.TestHandlerName"},
+		{namedHandler{}, "routemeta.namedHandler"},
+		{nil, ""},
+	} {
+		if got := HandlerName(tc.h); got != tc.want {
+			t.Errorf("HandlerName(%T) = %q, want %q", tc.h, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/degradation"
+	"github.com/mikko-kohtala/go-api/internal/handlers"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+// Meta is what a route declares about itself. Method, Pattern and Group are
+// filled in when it is registered; an empty Auth takes the group's access
+// (a Scope requires authentication), an empty Scope the group's scope and an
+// empty Stability means stable. A Feature is guarded by degradation.Default.
+type Meta = routemeta.Route
+
+// Router registers routes on a chi router together with their metadata.
+// Get, Post and the other method helpers take error-returning handlers (see
+// handlers.HandlerFunc); Method takes any http.Handler.
+// Every route lands in the route table and is served behind the access check
+// and deprecation headers its metadata asks for.
+type Router struct {
+	mux          chi.Router
+	prefix       string // full path of mux, e.g. /api/v1/users
+	group        Group
+	table        *routemeta.Table
+	authenticate func(http.Handler) http.Handler // see Routes.SetAuthentication
+}
+
+// Get registers h for GET requests to pattern.
+func (r Router) Get(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodGet, pattern, h, meta)
+}
+
+// Post registers h for POST requests to pattern.
+func (r Router) Post(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodPost, pattern, h, meta)
+}
+
+// Put registers h for PUT requests to pattern.
+func (r Router) Put(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodPut, pattern, h, meta)
+}
+
+// Patch registers h for PATCH requests to pattern.
+func (r Router) Patch(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodPatch, pattern, h, meta)
+}
+
+// Delete registers h for DELETE requests to pattern.
+func (r Router) Delete(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodDelete, pattern, h, meta)
+}
+
+// Head registers h for HEAD requests to pattern. GET routes answer HEAD
+// already; this is for routes whose HEAD differs.
+func (r Router) Head(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodHead, pattern, h, meta)
+}
+
+// Options registers h for OPTIONS requests to pattern.
+func (r Router) Options(pattern string, h handlers.HandlerFunc, meta Meta) {
+	r.Method(http.MethodOptions, pattern, h, meta)
+}
+
+// Method registers h for method requests to pattern and records meta in the
+// route table. It panics on a route without a name, which is a programming
+// error.
+func (r Router) Method(method, pattern string, h http.Handler, meta Meta) {
+	meta.Handler = routemeta.HandlerName(h)
+	meta = r.Declare(method, pattern, meta)
+	if meta.Feature != "" {
+		h = degradation.Default.Guard(meta.Feature, h)
+	}
+	if meta.Scope != "" && meta.Scope != r.group.Scope {
+		h = RequireScope(meta.Scope, h) // Mount checks the group's scope already
+	}
+	if meta.Auth == AccessAuthenticated && r.group.Access != AccessAuthenticated {
+		h = authenticated(r.authenticate, h) // Mount checks whole groups already
+	}
+	r.mux.Method(method, pattern, serveRoute(meta, h))
+}
+
+// Declare records meta for a route registered on Mux by other means, such as
+// resource.Register, and returns the completed metadata. Such routes get no
+// access check or deprecation headers of their own.
+func (r Router) Declare(method, pattern string, meta Meta) Meta {
+	if meta.Name == "" {
+		panic("routes: " + method + " " + r.join(pattern) + " has no name")
+	}
+	meta.Method = method
+	meta.Pattern = r.join(pattern)
+	meta.Group = r.group.Name
+	if meta.Scope == "" {
+		meta.Scope = r.group.Scope
+	}
+	if meta.Scope != "" {
+		meta.Auth = AccessAuthenticated
+	}
+	if meta.Auth == "" || r.group.Access == AccessAuthenticated {
+		meta.Auth = r.group.Access
+	}
+	if meta.Stability == "" {
+		meta.Stability = routemeta.Stable
+	}
+	r.table.Add(meta)
+	return meta
+}
+
+// Route mounts a sub-router on pattern, as chi's Route does.
+func (r Router) Route(pattern string, fn func(r Router)) {
+	r.mux.Route(pattern, func(mux chi.Router) {
+		fn(Router{mux: mux, prefix: r.join(pattern), group: r.group, table: r.table, authenticate: r.authenticate})
+	})
+}
+
+// With returns a router adding middlewares to the routes registered on it.
+func (r Router) With(middlewares ...func(http.Handler) http.Handler) Router {
+	r.mux = r.mux.With(middlewares...)
+	return r
+}
+
+// Use adds middlewares to every route of the router.
+func (r Router) Use(middlewares ...func(http.Handler) http.Handler) {
+	r.mux.Use(middlewares...)
+}
+
+// RequiresScope reports whether every route of r is served only to
+// principals with scope, as its group demands.
+func (r Router) RequiresScope(scope string) bool {
+	return r.group.Access == AccessAuthenticated && r.group.Scope == scope
+}
+
+// Mux returns the underlying chi router.
+func (r Router) Mux() chi.Router {
+	return r.mux
+}
+
+func (r Router) join(pattern string) string {
+	return routemeta.Normalize(strings.TrimSuffix(r.prefix, "/") + "/" + strings.TrimPrefix(pattern, "/"))
+}
+
+// serveRoute records the route's handler name for the middleware that logs,
+// meters and recovers requests, and sets the Deprecation, Sunset and
+// successor Link headers on the responses of a deprecated route.
+func serveRoute(meta Meta, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestctx.SetHandler(req.Context(), meta.Handler)
+		if !meta.Deprecated {
+			h.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Deprecation", "true")
+		if meta.Sunset != nil {
+			w.Header().Set("Sunset", meta.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if meta.Successor != "" {
+			w.Header().Add("Link", "<"+meta.Successor+`>; rel="successor-version"`)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+func TestRouter_DeclaresAndEnforcesMetadata(t *testing.T) {
+	table := routemeta.NewTable()
+	sunset := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)
+	ok := func(w http.ResponseWriter, r *http.Request) error { w.WriteHeader(http.StatusOK); return nil }
+
+	mux := chi.NewRouter()
+	mux.Route("/api/v1", func(mux chi.Router) {
+		r := Router{mux: mux, prefix: "/api/v1", group: Group{Name: GroupAPI, Access: AccessPublic}, table: table}
+		r.Route("/things", func(r Router) {
+			r.Get("/", ok, Meta{Name: "things.list"})
+			r.Get("/mine", ok, Meta{Name: "things.mine", Auth: AccessAuthenticated})
+			r.Get("/old", ok, Meta{Name: "things.old", Deprecated: true, Sunset: &sunset, Successor: "/api/v1/things"})
+		})
+	})
+
+	list, found := table.Lookup(http.MethodGet, "/api/v1/things/")
+	if !found || list.Name != "things.list" || list.Group != GroupAPI || list.Auth != AccessPublic || list.Stability != routemeta.Stable {
+		t.Fatalf("expected things.list declared with the group's defaults, got %+v (found %v)", list, found)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := serve("/api/v1/things/mine"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on an authenticated route without a principal, got %d", rec.Code)
+	}
+	rec := serve("/api/v1/things/old")
+	if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Sunset") != "Sun, 31 Jan 2027 00:00:00 GMT" ||
+		rec.Header().Get("Link") != `</api/v1/things>; rel="successor-version"` {
+		t.Fatalf("expected deprecation headers, got %v", rec.Header())
+	}
+	if rec := serve("/api/v1/things"); rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("expected a plain 200, got %d %v", rec.Code, rec.Header())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic declaring a route without a name")
+		}
+	}()
+	Router{mux: chi.NewRouter(), table: table}.Get("/", ok, Meta{})
+}
@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+// Environments route groups are limited to. APP_ENV values are normalized
+// first, so "prod" and "dev" match too.
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Access is the authentication a route group or route requires.
+type Access = routemeta.Access
+
+const (
+	// AccessPublic serves anyone. Groups that check their own credentials,
+	// such as signed download URLs, are public here.
+	AccessPublic = routemeta.Public
+	// AccessAuthenticated requires a principal set by authentication
+	// middleware; other requests get 401.
+	AccessAuthenticated = routemeta.Authenticated
+)
+
+// Route group names.
+const (
+	GroupHealth      = "health"
+	GroupAPI         = "api"
+	GroupGraphQL     = "graphql"
+	GroupUploads     = "uploads"
+	GroupSignedFiles = "signed-files"
+	GroupTest        = "test"
+	GroupMetrics     = "metrics"
+	GroupAdmin       = "admin"
+	GroupDocs        = "docs"
+	GroupStatic      = "static"
+	GroupRoot        = "root"
+)
+
+// Who the API docs are served to, as DOCS_EXPOSURE says.
+const (
+	DocsPublic   = "public"
+	DocsAdmin    = "admin" // principals with AdminScope
+	DocsDisabled = "disabled"
+)
+
+// AdminScope is the scope of operator-only routes: those of the admin and
+// metrics groups and, with DOCS_EXPOSURE=admin, the API docs. Principals without scope
+// restrictions have it too.
+const AdminScope = "admin"
+
+// Group declares where a route group is mounted and in which environments,
+// at what access level and with what request body limit it is served.
+type Group struct {
+	Name      string
+	Prefix    string   // mount point; empty mounts the routes as they are
+	Only      []string // environments the group is served in; empty means all
+	Except    []string // environments the group is never served in
+	Access    Access
+	Scope     string // scope every route of the group requires; implies AccessAuthenticated
+	BodyLimit int64  // largest request body in bytes; 0 keeps BODY_LIMIT_BYTES
+}
+
+// Groups is the route exposure matrix. Every group is mounted through
+// Routes.Mount, which applies its row; a group not listed here cannot be
+// mounted.
+var Groups = []Group{
+	{Name: GroupHealth, Access: AccessPublic},
+	{Name: GroupAPI, Prefix: "/api/v1", Access: AccessPublic},
+	{Name: GroupGraphQL, Prefix: "/graphql", Access: AccessPublic, BodyLimit: 64 << 10},       // queries are small
+	{Name: GroupUploads, Prefix: "/api/v1/files", Access: AccessPublic, BodyLimit: 100 << 20}, // tus chunks
+	{Name: GroupSignedFiles, Access: AccessPublic},
+	{Name: GroupTest, Prefix: "/test", Except: []string{EnvProduction}, Access: AccessPublic},
+	{Name: GroupMetrics, Access: AccessAuthenticated, Scope: AdminScope},                 // admin listener only when ADMIN_PORT is set
+	{Name: GroupAdmin, Prefix: "/admin", Access: AccessAuthenticated, Scope: AdminScope}, // likewise
+	{Name: GroupDocs, Access: AccessPublic},
+	{Name: GroupStatic, Prefix: "/static", Access: AccessPublic},
+	{Name: GroupRoot, Access: AccessPublic},
+}
+
+// NormalizeEnv maps APP_ENV aliases to the environment names above.
+func NormalizeEnv(env string) string {
+	env = strings.ToLower(strings.TrimSpace(env))
+	switch env {
+	case "prod":
+		return EnvProduction
+	case "dev", "":
+		return EnvDevelopment
+	case "stage":
+		return EnvStaging
+	}
+	return env
+}
+
+// ServedIn reports whether g is served in env.
+func (g Group) ServedIn(env string) bool {
+	env = NormalizeEnv(env)
+	if slices.Contains(g.Except, env) {
+		return false
+	}
+	return len(g.Only) == 0 || slices.Contains(g.Only, env)
+}
+
+func lookupGroup(name string) (Group, bool) {
+	for _, g := range Groups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return Group{}, false
+}
+
+// BodyLimit returns the request body limit for path: that of the group with
+// the longest matching prefix among those with a BodyLimit, or defaultLimit.
+// It works on the raw path so the limit can be enforced before routing.
+func BodyLimit(path string, defaultLimit int64) int64 {
+	limit, matched := defaultLimit, -1
+	for _, g := range Groups {
+		if g.BodyLimit <= 0 || len(g.Prefix) <= matched {
+			continue
+		}
+		if path == g.Prefix || strings.HasPrefix(path, g.Prefix+"/") || g.Prefix == "" {
+			limit, matched = g.BodyLimit, len(g.Prefix)
+		}
+	}
+	return limit
+}
+
+// Mount registers the named group on r when the exposure matrix serves it in
+// the routes' environment: setup runs under the group's prefix, behind
+// middlewares and the group's access and scope checks, and declares its routes into
+// routemeta.Default. It panics on a group missing from Groups, which is a
+// programming error.
+func (rt *Routes) Mount(r chi.Router, name string, setup func(Router), middlewares ...func(http.Handler) http.Handler) {
+	g, ok := lookupGroup(name)
+	if !ok {
+		panic("routes: group " + name + " is not in the exposure matrix")
+	}
+	if !g.ServedIn(rt.env) {
+		return
+	}
+	if slices.Contains(rt.authGroups, g.Name) || g.Scope != "" {
+		g.Access = AccessAuthenticated
+	}
+	mount := func(r chi.Router) {
+		r.Use(middlewares...)
+		if g.Access == AccessAuthenticated {
+			r.Use(rt.authenticated)
+		}
+		if g.Scope != "" {
+			r.Use(func(next http.Handler) http.Handler { return RequireScope(g.Scope, next) })
+		}
+		setup(Router{mux: r, prefix: g.Prefix, group: g, table: routemeta.Default, authenticate: rt.authenticate})
+	}
+	if g.Prefix == "" {
+		r.Group(mount)
+		return
+	}
+	r.Route(g.Prefix, mount)
+}
+
+// SetAuthentication makes authenticate, middleware that sets the principal
+// of requests with valid credentials (such as auth.Verifier.Authenticate),
+// run before the access check of authenticated groups and routes, and
+// switches the named groups to AccessAuthenticated. It must be called before
+// Mount.
+func (rt *Routes) SetAuthentication(authenticate func(http.Handler) http.Handler, groups ...string) {
+	rt.authenticate = authenticate
+	rt.authGroups = groups
+}
+
+// authenticated runs the authenticator, if any, and then
+// RequireAuthenticated.
+func (rt *Routes) authenticated(next http.Handler) http.Handler {
+	return authenticated(rt.authenticate, next)
+}
+
+func authenticated(authenticate func(http.Handler) http.Handler, next http.Handler) http.Handler {
+	if authenticate == nil {
+		return RequireAuthenticated(next)
+	}
+	return authenticate(RequireAuthenticated(next))
+}
+
+// RequireScope answers 403 to requests whose principal is restricted to
+// scopes other than scope. It runs after the access check.
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := requestctx.Principal(r.Context()); ok && !p.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			response.Error(w, r, http.StatusForbidden, "insufficient_scope", "The credentials lack the "+scope+" scope", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAuthenticated answers 401 to requests without a principal.
+func RequireAuthenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requestctx.Authenticated(r.Context()) {
+			response.Error(w, r, http.StatusUnauthorized, "unauthorized", "Authentication required", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+)
+
+func TestGroup_ServedIn(t *testing.T) {
+	g := Group{Only: []string{EnvDevelopment, EnvStaging}, Except: []string{EnvStaging}}
+	for env, want := range map[string]bool{"dev": true, "": true, "staging": false, "prod": false, "test": false} {
+		if got := g.ServedIn(env); got != want {
+			t.Fatalf("ServedIn(%q) = %v, want %v", env, got, want)
+		}
+	}
+}
+
+func TestMount_AppliesExposureMatrix(t *testing.T) {
+	saved := Groups
+	defer func() { Groups = saved }()
+	Groups = []Group{
+		{Name: "debug", Prefix: "/debug", Except: []string{EnvProduction}, Access: AccessPublic},
+		{Name: "account", Prefix: "/account", Access: AccessAuthenticated},
+	}
+	ok := func(r Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) error { w.WriteHeader(http.StatusOK); return nil }, Meta{Name: "ok"})
+	}
+
+	serve := func(env, path string, authenticate bool) int {
+		rt := &Routes{env: env}
+		r := chi.NewRouter()
+		rt.Mount(r, "debug", ok)
+		rt.Mount(r, "account", ok)
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authenticate {
+			req = req.WithContext(requestctx.SetPrincipal(req.Context(), requestctx.Identity{UserID: "usr_1"}))
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("development", "/debug", false); code != http.StatusOK {
+		t.Fatalf("expected debug routes in development, got %d", code)
+	}
+	if code := serve("prod", "/debug", false); code != http.StatusNotFound {
+		t.Fatalf("expected debug routes to be absent in production, got %d", code)
+	}
+	if code := serve("prod", "/account", false); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a principal, got %d", code)
+	}
+	if code := serve("prod", "/account", true); code != http.StatusOK {
+		t.Fatalf("expected authenticated access, got %d", code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic mounting an undeclared group")
+		}
+	}()
+	(&Routes{}).Mount(chi.NewRouter(), "undeclared", ok)
+}
+
+func TestGroups_OperatorGroupsRequireTheAdminScope(t *testing.T) {
+	for _, name := range []string{GroupAdmin, GroupMetrics} {
+		if g, ok := lookupGroup(name); !ok || g.Access != AccessAuthenticated || g.Scope != AdminScope {
+			t.Errorf("expected the %s group to require the admin scope, got %+v", name, g)
+		}
+	}
+}
+
+func TestSetupAdminRoutes_IssuesKeysOnlyBehindTheAdminScope(t *testing.T) {
+	saved := Groups
+	defer func() { Groups = saved }()
+	table := routemeta.Default
+	defer func() { routemeta.Default = table }()
+
+	for _, tc := range []struct {
+		group Group
+		want  bool
+	}{
+		{Group{Name: GroupAdmin, Prefix: "/admin", Access: AccessAuthenticated, Scope: AdminScope}, true},
+		{Group{Name: GroupAdmin, Prefix: "/admin", Access: AccessAuthenticated}, false},
+		{Group{Name: GroupAdmin, Prefix: "/admin", Access: AccessPublic}, false},
+	} {
+		Groups = []Group{tc.group}
+		routemeta.Default = routemeta.NewTable()
+		rt := &Routes{env: EnvProduction}
+		rt.Mount(chi.NewRouter(), GroupAdmin, rt.SetupAdminRoutes)
+		if _, ok := routemeta.Default.Lookup(http.MethodPost, "/admin/apikeys"); ok != tc.want {
+			t.Errorf("admin group %+v: POST /admin/apikeys mounted = %v, want %v", tc.group, ok, tc.want)
+		}
+	}
+}
+
+func TestMount_RequiresTheGroupScope(t *testing.T) {
+	saved := Groups
+	defer func() { Groups = saved }()
+	Groups = []Group{{Name: "ops", Prefix: "/ops", Access: AccessPublic, Scope: AdminScope}}
+	table := routemeta.Default
+	defer func() { routemeta.Default = table }()
+	routemeta.Default = routemeta.NewTable()
+
+	rt := &Routes{env: EnvProduction}
+	r := chi.NewRouter()
+	rt.Mount(r, "ops", func(r Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) error { w.WriteHeader(http.StatusOK); return nil }, Meta{Name: "ops"})
+	})
+	serve := func(p *requestctx.Identity) int {
+		req := httptest.NewRequest(http.MethodGet, "/ops", nil)
+		if p != nil {
+			req = req.WithContext(requestctx.SetPrincipal(req.Context(), *p))
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		principal *requestctx.Identity
+		want      int
+	}{
+		{nil, http.StatusUnauthorized},
+		{&requestctx.Identity{UserID: "svc_1", Scopes: []string{}}, http.StatusForbidden},
+		{&requestctx.Identity{UserID: "svc_1", Scopes: []string{"apikeys"}}, http.StatusForbidden},
+		{&requestctx.Identity{UserID: "usr_ops", Scopes: []string{AdminScope}}, http.StatusOK},
+		{&requestctx.Identity{UserID: "usr_ops"}, http.StatusOK},
+	} {
+		if code := serve(tc.principal); code != tc.want {
+			t.Errorf("GET /ops as %+v: got %d, want %d", tc.principal, code, tc.want)
+		}
+	}
+	if route, ok := routemeta.Default.Lookup(http.MethodGet, "/ops"); !ok || route.Scope != AdminScope || route.Auth != AccessAuthenticated {
+		t.Fatalf("expected the route declared with the group's scope, got %+v", route)
+	}
+}
+
+func TestSetAuthentication_OptsGroupsIn(t *testing.T) {
+	saved := Groups
+	defer func() { Groups = saved }()
+	Groups = []Group{
+		{Name: "public", Prefix: "/public", Access: AccessPublic},
+		{Name: "private", Prefix: "/private", Access: AccessPublic},
+	}
+	ok := func(r Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) error { w.WriteHeader(http.StatusOK); return nil }, Meta{Name: "ok"})
+		r.Get("/own", func(w http.ResponseWriter, r *http.Request) error { w.WriteHeader(http.StatusOK); return nil }, Meta{Name: "own", Auth: AccessAuthenticated})
+	}
+	// Stands in for auth.Verifier.Authenticate
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "Bearer valid" {
+				r = r.WithContext(requestctx.SetPrincipal(r.Context(), requestctx.Identity{UserID: "usr_1", Method: "jwt"}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	rt := &Routes{env: EnvProduction}
+	rt.SetAuthentication(authenticate, "private")
+	r := chi.NewRouter()
+	rt.Mount(r, "public", ok)
+	rt.Mount(r, "private", ok)
+	serve := func(path, authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	cases := []struct {
+		path, authorization string
+		want                int
+	}{
+		{"/public", "", http.StatusOK},
+		{"/public/own", "", http.StatusUnauthorized},
+		{"/public/own", "Bearer valid", http.StatusOK},
+		{"/private", "", http.StatusUnauthorized},
+		{"/private", "Bearer valid", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if code := serve(tc.path, tc.authorization); code != tc.want {
+			t.Errorf("GET %s with %q: got %d, want %d", tc.path, tc.authorization, code, tc.want)
+		}
+	}
+}
+
+func TestBodyLimit_LongestMatchingPrefix(t *testing.T) {
+	saved := Groups
+	defer func() { Groups = saved }()
+	Groups = []Group{
+		{Name: "api", Prefix: "/api/v1", BodyLimit: 1 << 10},
+		{Name: "files", Prefix: "/api/v1/files", BodyLimit: 1 << 20},
+		{Name: "docs", Prefix: "/docs"},
+	}
+	for path, want := range map[string]int64{
+		"/api/v1/users":     1 << 10,
+		"/api/v1/files":     1 << 20,
+		"/api/v1/files/f_1": 1 << 20,
+		"/api/v1/filesx":    1 << 10,
+		"/docs/a":           64,
+		"/":                 64,
+	} {
+		if got := BodyLimit(path, 64); got != want {
+			t.Errorf("BodyLimit(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestBodyLimit_GraphQLQueriesAreSmall(t *testing.T) {
+	if got := BodyLimit("/graphql", 10<<20); got != 64<<10 {
+		t.Fatalf("BodyLimit(/graphql) = %d, want %d", got, 64<<10)
+	}
+}
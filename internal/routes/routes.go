@@ -4,97 +4,375 @@ import (
 	"log/slog"
 	"net/http"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/mikko-kohtala/go-api/internal/admission"
+	"github.com/mikko-kohtala/go-api/internal/assets"
+	"github.com/mikko-kohtala/go-api/internal/config"
+	"github.com/mikko-kohtala/go-api/internal/corsreport"
+	"github.com/mikko-kohtala/go-api/internal/featureflags"
 	"github.com/mikko-kohtala/go-api/internal/handlers"
+	"github.com/mikko-kohtala/go-api/internal/imaging"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/metering"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/quota"
+	"github.com/mikko-kohtala/go-api/internal/routemeta"
+	"github.com/mikko-kohtala/go-api/internal/sampling"
+	"github.com/mikko-kohtala/go-api/internal/secrets"
 	"github.com/mikko-kohtala/go-api/internal/services"
+	"github.com/mikko-kohtala/go-api/internal/signedurl"
+	"github.com/mikko-kohtala/go-api/internal/snapshot"
+	"github.com/mikko-kohtala/go-api/internal/usage"
+	"github.com/mikko-kohtala/go-api/internal/webhooks"
+	"github.com/mikko-kohtala/go-api/internal/ws"
+)
+
+// Features the routes belong to, for DEGRADED_FEATURES; see package
+// degradation.
+const (
+	FeatureUsers = "users"
+	FeatureFiles = "files"
 )
 
 type Routes struct {
-	logger       *slog.Logger
-	userService  services.UserService
-	statsService services.StatsService
-	userHandler  *handlers.UserHandler
-	statsHandler *handlers.StatsHandler
-	includeTest  bool
+	logger        *slog.Logger
+	userService   services.UserService
+	statsService  services.StatsService
+	fileService   services.FileService
+	userHandler   *handlers.UserHandler
+	taskHandler   *handlers.TaskHandler
+	statsHandler  *handlers.StatsHandler
+	fileHandler   *handlers.FileHandler
+	imageHandler  *handlers.ImageHandler
+	reportHandler *handlers.ReportHandler
+	opHandler     *handlers.OperationHandler
+	eraseHandler  *handlers.ErasureHandler
+	notifyHandler *handlers.NotificationHandler
+	usageHandler  *handlers.UsageHandler
+	quotaHandler  *handlers.QuotaHandler
+	meterHandler  *handlers.MeteringHandler
+	flagHandler   *handlers.FlagHandler
+	configHandler *handlers.ConfigHandler
+	corsHandler   *handlers.CORSHandler
+	hookHandler   *handlers.WebhookHandler
+	deadHandler   *handlers.DeadLetterHandler
+	assetHandler  *handlers.AssetHandler
+	routeHandler  *handlers.RouteHandler
+	snapHandler   *handlers.SnapshotHandler
+	keyHandler    *handlers.APIKeyHandler
+	wsHandler     *handlers.WebSocketHandler
+	sampleHandler *handlers.SampleHandler
+	secretHandler *handlers.SecretHandler
+	signer        *signedurl.Signer
+	env           string // decides which groups Mount serves
+
+	authenticate func(http.Handler) http.Handler // see SetAuthentication
+	authGroups   []string
+	bootReport   bool                   // see ServeBootReport
+	docs         string                 // see SetDocsExposure
+	graphql      handlers.GraphQLLimits // see SetGraphQLLimits
 }
 
 func NewRoutes(
 	logger *slog.Logger,
 	userService services.UserService,
+	taskService services.TaskService,
 	statsService services.StatsService,
+	fileService services.FileService,
+	signer *signedurl.Signer,
+	imageProcessor *imaging.Processor,
+	reportService services.ReportService,
+	notificationPrefs notify.PreferenceStore,
+	usageTracker *usage.Tracker,
+	quotas *quota.Manager,
+	meteringAggregator *metering.Aggregator,
+	flags *featureflags.Client,
+	settings []config.Setting,
+	webhookRegistry *webhooks.Registry,
+	apiKeys services.APIKeyService,
 ) *Routes {
-	return NewRoutesWithTests(logger, userService, statsService, true)
+	return NewRoutesForEnv(logger, userService, taskService, statsService, fileService, signer, imageProcessor, reportService, notificationPrefs, usageTracker, quotas, meteringAggregator, flags, settings, webhookRegistry, apiKeys, EnvDevelopment)
 }
 
-func NewRoutesWithTests(
+// NewRoutesForEnv returns routes that serve the groups the exposure matrix
+// allows in env (APP_ENV).
+func NewRoutesForEnv(
 	logger *slog.Logger,
 	userService services.UserService,
+	taskService services.TaskService,
 	statsService services.StatsService,
-	includeTest bool,
+	fileService services.FileService,
+	signer *signedurl.Signer,
+	imageProcessor *imaging.Processor,
+	reportService services.ReportService,
+	notificationPrefs notify.PreferenceStore,
+	usageTracker *usage.Tracker,
+	quotas *quota.Manager,
+	meteringAggregator *metering.Aggregator,
+	flags *featureflags.Client,
+	settings []config.Setting,
+	webhookRegistry *webhooks.Registry,
+	apiKeys services.APIKeyService,
+	env string,
 ) *Routes {
 	return &Routes{
-		logger:       logger,
-		userService:  userService,
-		statsService: statsService,
-		userHandler:  handlers.NewUserHandler(userService, logger),
-		statsHandler: handlers.NewStatsHandler(statsService, logger),
-		includeTest:  includeTest,
+		logger:        logger,
+		userService:   userService,
+		statsService:  statsService,
+		fileService:   fileService,
+		userHandler:   handlers.NewUserHandler(userService, operations.Default, logger),
+		taskHandler:   handlers.NewTaskHandler(taskService, logger),
+		statsHandler:  handlers.NewStatsHandler(statsService, logger),
+		fileHandler:   handlers.NewFileHandler(fileService, signer, logger),
+		imageHandler:  handlers.NewImageHandler(fileService, imageProcessor, logger),
+		reportHandler: handlers.NewReportHandler(reportService, logger),
+		opHandler:     handlers.NewOperationHandler(operations.Default, logger),
+		eraseHandler:  handlers.NewErasureHandler(services.NewErasureService(userService, notificationPrefs), userService, operations.Default, logger),
+		notifyHandler: handlers.NewNotificationHandler(notificationPrefs, userService, logger),
+		usageHandler:  handlers.NewUsageHandler(usageTracker, logger),
+		quotaHandler:  handlers.NewQuotaHandler(quotas, logger),
+		meterHandler:  handlers.NewMeteringHandler(meteringAggregator, logger),
+		flagHandler:   handlers.NewFlagHandler(flags, logger),
+		configHandler: handlers.NewConfigHandler(settings, logger),
+		corsHandler:   handlers.NewCORSHandler(corsreport.Default, logger),
+		hookHandler:   handlers.NewWebhookHandler(webhookRegistry, logger),
+		deadHandler:   handlers.NewDeadLetterHandler(jobs.Default, logger),
+		assetHandler:  handlers.NewAssetHandler(assets.Default, logger),
+		routeHandler:  handlers.NewRouteHandler(routemeta.Default, logger),
+		snapHandler:   handlers.NewSnapshotHandler(snapshot.Default, logger),
+		keyHandler:    handlers.NewAPIKeyHandler(apiKeys, logger),
+		wsHandler:     handlers.NewWebSocketHandler(ws.Default, logger),
+		sampleHandler: handlers.NewSampleHandler(sampling.Default, logger),
+		secretHandler: handlers.NewSecretHandler(secrets.Default, logger),
+		signer:        signer,
+		env:           env,
 	}
 }
 
-// IncludeTestRoutes reports whether debug/test routes should be registered.
-func (rt *Routes) IncludeTestRoutes() bool {
-	return rt.includeTest
-}
-
 // SetupHealthRoutes configures health check endpoints
-func (rt *Routes) SetupHealthRoutes(r chi.Router) {
-	r.Get("/healthz", handlers.Health)
-	r.Get("/readyz", handlers.Ready)
+func (rt *Routes) SetupHealthRoutes(r Router) {
+	r.Get("/healthz", handlers.Health, Meta{Name: "health.live", Description: "Liveness probe", RateClass: admission.Critical})
+	r.Get("/readyz", handlers.Ready, Meta{Name: "health.ready", Description: "Readiness probe", RateClass: admission.Critical})
 }
 
 // SetupAPIV1Routes configures API v1 endpoints
-func (rt *Routes) SetupAPIV1Routes(r chi.Router) {
-	// Example endpoints (existing)
-	r.Get("/ping", handlers.Ping)
-	r.Post("/echo", handlers.Echo)
+func (rt *Routes) SetupAPIV1Routes(r Router) {
+	// Example endpoint
+	r.Get("/ping", handlers.Ping, Meta{Name: "ping", Description: "Answers pong"})
+
+	// Tasks: the reference resource (repository, service, handler, events, caching)
+	for _, op := range rt.taskHandler.Register(r.Mux()) {
+		r.Declare(op.Method, op.Path, Meta{Name: op.Name, Description: op.Summary})
+	}
+	r.Declare(http.MethodPost, "/tasks/{id}/complete", Meta{Name: "tasks.complete", Description: "Complete a task"})
 
 	// User endpoints (new)
-	r.Route("/users", func(r chi.Router) {
-		r.Get("/", rt.userHandler.GetAllUsers)
-		r.Post("/", rt.userHandler.CreateUser)
-		r.Route("/{userID}", func(r chi.Router) {
-			r.Get("/", rt.userHandler.GetUserByID)
-			r.Put("/", rt.userHandler.UpdateUser)
-			r.Delete("/", rt.userHandler.DeleteUser)
+	r.Route("/users", func(r Router) {
+		r.Get("/", rt.userHandler.GetAllUsers, Meta{Name: "users.list", Description: "List users", Feature: FeatureUsers})
+		r.Post("/", rt.userHandler.CreateUser, Meta{Name: "users.create", Description: "Create a user", Feature: FeatureUsers})
+		r.Post("/import", rt.userHandler.ImportUsers, Meta{Name: "users.import", Description: "Import users from an NDJSON stream", Feature: FeatureUsers, RateClass: admission.Bulk})
+		r.Route("/{userID}", func(r Router) {
+			r.Get("/", rt.userHandler.GetUserByID, Meta{Name: "users.get", Description: "Get a user", Feature: FeatureUsers})
+			r.Put("/", rt.userHandler.UpdateUser, Meta{Name: "users.update", Description: "Update a user", Feature: FeatureUsers})
+			r.Delete("/", rt.userHandler.DeleteUser, Meta{Name: "users.delete", Description: "Delete a user", Feature: FeatureUsers})
+			r.Post("/erasure", rt.eraseHandler.EraseUser, Meta{Name: "users.erase", Description: "Start erasing everything stored about a user", Feature: FeatureUsers, RateClass: admission.Bulk})
+			r.Get("/notification-preferences", rt.notifyHandler.GetPreferences, Meta{Name: "users.notification_preferences.get", Description: "Get a user's notification preferences", Feature: FeatureUsers})
+			r.Put("/notification-preferences", rt.notifyHandler.UpdatePreferences, Meta{Name: "users.notification_preferences.update", Description: "Update a user's notification preferences", Feature: FeatureUsers})
 		})
 	})
 
+	// Image transforms of stored files
+	r.Get("/images/{fileID}", rt.imageHandler.GetImage, Meta{Name: "images.get", Description: "Resize, crop or convert a stored image", RateClass: admission.Bulk, Stability: routemeta.Beta})
+
+	// Asynchronously generated reports
+	r.Route("/reports", func(r Router) {
+		r.Post("/", rt.reportHandler.CreateReport, Meta{Name: "reports.create", Description: "Start generating a report", RateClass: admission.Bulk, Stability: routemeta.Beta})
+		r.Get("/{reportID}", rt.reportHandler.GetReport, Meta{Name: "reports.get", Description: "Get a report's status or result", Stability: routemeta.Beta})
+	})
+
+	// Long-running operations started by the endpoints above
+	r.Get("/operations/{operationID}", rt.opHandler.GetOperation, Meta{Name: "operations.get", Description: "Get a long-running operation's status, progress or result"})
+
+	// API keys of the caller, for machine clients
+	r.Route("/apikeys", func(r Router) {
+		r.Get("/", rt.keyHandler.ListAPIKeys, Meta{Name: "apikeys.list", Description: "List the caller's API keys", Scope: handlers.APIKeyScope})
+		r.Post("/", rt.keyHandler.CreateAPIKey, Meta{Name: "apikeys.create", Description: "Create an API key", Scope: handlers.APIKeyScope})
+		r.Get("/{keyID}", rt.keyHandler.GetAPIKey, Meta{Name: "apikeys.get", Description: "Get one of the caller's API keys", Scope: handlers.APIKeyScope})
+		r.Delete("/{keyID}", rt.keyHandler.RevokeAPIKey, Meta{Name: "apikeys.revoke", Description: "Revoke one of the caller's API keys", Scope: handlers.APIKeyScope})
+	})
+
+	// Server-sent events
+	r.Get("/events", rt.statsHandler.StreamStats, Meta{Name: "events.stats", Description: "Stream system statistics as server-sent events", Stability: routemeta.Beta})
+
+	// Realtime connections; task events are broadcast to the tasks room
+	r.Get("/ws", rt.wsHandler.Connect, Meta{Name: "ws.connect", Description: "Open a WebSocket connection", Stability: routemeta.Beta})
+
+	// Feature flag evaluation for the caller
+	r.Get("/flags/{flag}", rt.flagHandler.GetFlag, Meta{Name: "flags.get", Description: "Evaluate a feature flag for the caller"})
+
+	// Usage of the calling API key
+	r.Get("/usage", rt.usageHandler.GetUsage, Meta{Name: "usage.get", Description: "Usage of the calling API key"})
+
 	// Stats endpoints (new)
-	r.Route("/stats", func(r chi.Router) {
-		r.Get("/system", rt.statsHandler.GetSystemStats)
-		r.Get("/api", rt.statsHandler.GetAPIStats)
+	r.Route("/stats", func(r Router) {
+		r.Get("/system", rt.statsHandler.GetSystemStats, Meta{Name: "stats.system", Description: "System statistics"})
+		r.Get("/api", rt.statsHandler.GetAPIStats, Meta{Name: "stats.api", Description: "API statistics"})
+		r.Get("/dependencies", rt.statsHandler.GetDependencyStats, Meta{Name: "stats.dependencies", Description: "Dependency health and degraded features"})
+	})
+}
+
+// SetupFileRoutes configures file endpoints under /api/v1/files. Uploads
+// follow the tus resumable upload protocol, so chunk bodies are raw bytes
+// rather than JSON.
+func (rt *Routes) SetupFileRoutes(r Router) {
+	tus := r.With(handlers.RequireTus)
+	tus.Options("/", rt.fileHandler.Options, Meta{Name: "files.upload_options", Description: "tus protocol capabilities", Feature: FeatureFiles})
+	tus.Post("/", rt.fileHandler.CreateUpload, Meta{Name: "files.upload_create", Description: "Start a resumable upload", Feature: FeatureFiles})
+	r.Route("/{fileID}", func(r Router) {
+		r.Get("/", rt.fileHandler.GetFile, Meta{Name: "files.get", Description: "Get a file's metadata", Feature: FeatureFiles})
+		r.Delete("/", rt.fileHandler.DeleteFile, Meta{Name: "files.delete", Description: "Delete a file", Feature: FeatureFiles})
+		r.With(handlers.RequireTus).Head("/", rt.fileHandler.UploadOffset, Meta{Name: "files.upload_offset", Description: "Offset of a resumable upload", Feature: FeatureFiles})
+		r.With(handlers.RequireTus).Patch("/", rt.fileHandler.AppendChunk, Meta{Name: "files.upload_append", Description: "Append a chunk to a resumable upload", Feature: FeatureFiles})
+		r.Get("/content", rt.fileHandler.DownloadFile, Meta{Name: "files.download", Description: "Download a file's content", Feature: FeatureFiles})
+		r.Head("/content", rt.fileHandler.DownloadFile, Meta{Name: "files.download_head", Description: "Headers of a file download", Feature: FeatureFiles})
+		r.Post("/signed-url", rt.fileHandler.CreateSignedURL, Meta{Name: "files.signed_url", Description: "Mint a signed, expiring download URL", Feature: FeatureFiles})
 	})
 }
 
+// SetupSignedFileRoutes configures downloads through signed, expiring URLs
+// minted by POST /api/v1/files/{fileID}/signed-url.
+func (rt *Routes) SetupSignedFileRoutes(r Router) {
+	r.With(rt.signer.Require).Get("/files/{fileID}", rt.fileHandler.DownloadFile, Meta{Name: "files.signed_download", Description: "Download a file through a signed URL", Feature: FeatureFiles})
+}
+
+// ServeBootReport adds GET /admin/boot, serving boot.Default. It must be
+// called before Mount.
+func (rt *Routes) ServeBootReport() {
+	rt.bootReport = true
+}
+
+// SetDocsExposure decides who SetupSwaggerRoutes serves the API docs to:
+// DocsPublic, DocsAdmin or DocsDisabled. It must be called before Mount.
+func (rt *Routes) SetDocsExposure(exposure string) {
+	rt.docs = exposure
+}
+
+// SetGraphQLLimits bounds the queries SetupGraphQLRoutes serves. It must be
+// called before Mount.
+func (rt *Routes) SetGraphQLLimits(limits handlers.GraphQLLimits) {
+	rt.graphql = limits
+}
+
+// SetupGraphQLRoutes configures the GraphQL endpoint, answering queries
+// from the same services as the API v1 routes.
+func (rt *Routes) SetupGraphQLRoutes(r Router) {
+	h := handlers.NewGraphQLHandler(rt.userService, rt.statsService, rt.graphql, rt.logger)
+	r.Get("/", h.Query, Meta{Name: "graphql.query_get", Description: "Run a GraphQL query given as query parameters", Stability: routemeta.Beta})
+	r.Post("/", h.Query, Meta{Name: "graphql.query", Description: "Run a GraphQL query", Stability: routemeta.Beta})
+	r.Get("/schema", h.Schema, Meta{Name: "graphql.schema", Description: "The GraphQL schema in SDL", Stability: routemeta.Beta})
+}
+
+// SetupAdminRoutes configures operator endpoints under /admin. They require
+// a principal with AdminScope and are only served on the admin listener when
+// ADMIN_PORT is set.
+func (rt *Routes) SetupAdminRoutes(r Router) {
+	r.Get("/routes", rt.routeHandler.ListRoutes, Meta{Name: "admin.routes", Description: "Declared routes and their metadata"})
+	r.Get("/usage", rt.usageHandler.GetAllUsage, Meta{Name: "admin.usage", Description: "Usage of every API key"})
+	r.Get("/metering", rt.meterHandler.GetRollups, Meta{Name: "admin.metering", Description: "Metering rollups"})
+	r.Get("/config", rt.configHandler.GetConfig, Meta{Name: "admin.config", Description: "Effective configuration with secrets redacted"})
+	if rt.bootReport {
+		r.Get("/boot", rt.configHandler.GetBootReport, Meta{Name: "admin.boot", Description: "What this instance runs, as reported at startup"})
+	}
+	r.Get("/cors/rejections", rt.corsHandler.GetRejections, Meta{Name: "admin.cors_rejections", Description: "Recently rejected CORS origins"})
+	r.Get("/samples", rt.sampleHandler.ListSamples, Meta{Name: "admin.samples", Description: "Anonymized samples of requests and responses"})
+	r.Delete("/samples", rt.sampleHandler.ClearSamples, Meta{Name: "admin.samples_clear", Description: "Drop the request samples"})
+	r.Get("/secrets", rt.secretHandler.ListCachedSecrets, Meta{Name: "admin.secrets", Description: "Cached secret keys, without values"})
+	r.Delete("/secrets", rt.secretHandler.RefreshSecrets, Meta{Name: "admin.secrets_refresh", Description: "Drop cached secrets so rotated values are read"})
+	r.Route("/runtime", func(r Router) {
+		r.Get("/memstats", rt.statsHandler.GetMemStats, Meta{Name: "admin.runtime.memstats", Description: "Go memory statistics"})
+		r.Get("/goroutines", rt.statsHandler.GetGoroutines, Meta{Name: "admin.runtime.goroutines", Description: "Goroutine dump"})
+		r.Post("/gc", rt.statsHandler.RunGC, Meta{Name: "admin.runtime.gc", Description: "Run a garbage collection"})
+	})
+	r.Route("/snapshot", func(r Router) {
+		r.Get("/", rt.snapHandler.ExportSnapshot, Meta{Name: "admin.snapshot.export", Description: "Export the in-memory stores"})
+		r.Put("/", rt.snapHandler.ImportSnapshot, Meta{Name: "admin.snapshot.import", Description: "Replace the in-memory stores from a snapshot"})
+	})
+	r.Get("/dashboards", rt.assetHandler.ListDashboards, Meta{Name: "admin.dashboards.list", Description: "List packaged dashboards"})
+	r.Get("/dashboards/{name}", rt.assetHandler.GetDashboard, Meta{Name: "admin.dashboards.get", Description: "Get a packaged dashboard"})
+	r.Route("/dead-letters", func(r Router) {
+		r.Get("/", rt.deadHandler.ListDeadLetters, Meta{Name: "admin.dead_letters.list", Description: "List dead-lettered jobs"})
+		r.Delete("/", rt.deadHandler.PurgeDeadLetters, Meta{Name: "admin.dead_letters.purge", Description: "Purge dead-lettered jobs"})
+		r.Post("/replay", rt.deadHandler.ReplayDeadLetters, Meta{Name: "admin.dead_letters.replay", Description: "Replay dead-lettered jobs"})
+		r.Delete("/{id}", rt.deadHandler.DeleteDeadLetter, Meta{Name: "admin.dead_letters.delete", Description: "Delete a dead-lettered job"})
+	})
+	r.Route("/webhooks", func(r Router) {
+		r.Get("/", rt.hookHandler.ListWebhooks, Meta{Name: "admin.webhooks.list", Description: "List webhook subscriptions"})
+		r.Post("/", rt.hookHandler.CreateWebhook, Meta{Name: "admin.webhooks.create", Description: "Create a webhook subscription"})
+		r.Route("/{id}", func(r Router) {
+			r.Get("/", rt.hookHandler.GetWebhook, Meta{Name: "admin.webhooks.get", Description: "Get a webhook subscription"})
+			r.Delete("/", rt.hookHandler.DeleteWebhook, Meta{Name: "admin.webhooks.delete", Description: "Delete a webhook subscription"})
+			r.Post("/keys", rt.hookHandler.RotateWebhookKey, Meta{Name: "admin.webhooks.rotate_key", Description: "Rotate a subscription's signing key"})
+			r.Delete("/keys/{keyID}", rt.hookHandler.RetireWebhookKey, Meta{Name: "admin.webhooks.retire_key", Description: "Retire a signing key"})
+		})
+	})
+	// Issuing keys for any owner and scope is never served without the
+	// admin scope check, whatever the exposure matrix says
+	if r.RequiresScope(AdminScope) {
+		r.Route("/apikeys", func(r Router) {
+			r.Get("/", rt.keyHandler.ListAllAPIKeys, Meta{Name: "admin.apikeys.list", Description: "List every API key"})
+			r.Post("/", rt.keyHandler.IssueAPIKey, Meta{Name: "admin.apikeys.issue", Description: "Issue an API key for a principal"})
+			r.Delete("/{keyID}", rt.keyHandler.AdminRevokeAPIKey, Meta{Name: "admin.apikeys.revoke", Description: "Revoke any API key"})
+		})
+	}
+	r.Route("/quotas/{key}", func(r Router) {
+		r.Get("/", rt.quotaHandler.GetQuota, Meta{Name: "admin.quotas.get", Description: "Get an API key's quota"})
+		r.Put("/", rt.quotaHandler.SetQuota, Meta{Name: "admin.quotas.set", Description: "Set an API key's quota"})
+		r.Delete("/", rt.quotaHandler.ResetQuota, Meta{Name: "admin.quotas.reset", Description: "Reset an API key's quota"})
+	})
+}
+
+// SetupMetricsRoutes configures the Prometheus scrape endpoint
+func (rt *Routes) SetupMetricsRoutes(r Router) {
+	r.Method(http.MethodGet, "/metrics", metrics.Handler(), Meta{Name: "metrics", Description: "Prometheus metrics", RateClass: admission.Critical})
+}
+
 // SetupRootRoute configures the root endpoint
-func (rt *Routes) SetupRootRoute(r chi.Router) {
-	r.Get("/", handlers.Root)
+func (rt *Routes) SetupRootRoute(r Router) {
+	docs := "/swagger/index.html"
+	if rt.docs == DocsDisabled {
+		docs = ""
+	}
+	r.Get("/", handlers.NewRootHandler(docs).Root, Meta{Name: "root", Description: "API index"})
+}
+
+// SetupStaticRoutes serves the static assets
+func (rt *Routes) SetupStaticRoutes(r Router) {
+	r.Method(http.MethodGet, "/*", http.StripPrefix("/static", rt.assetHandler.Static()), Meta{Name: "static", Description: "Packaged static files"})
 }
 
 // SetupTestRoutes configures test/debug endpoints
-func (rt *Routes) SetupTestRoutes(r chi.Router) {
-	r.Get("/logs", handlers.TestLogs)
-	r.Get("/sleep", handlers.TestSleep)
+func (rt *Routes) SetupTestRoutes(r Router) {
+	r.Get("/logs", handlers.TestLogs, Meta{Name: "test.logs", Description: "Emit log lines at every level", Stability: routemeta.Experimental})
+	r.Get("/sleep", handlers.TestSleep, Meta{Name: "test.sleep", Description: "Sleep before answering", Stability: routemeta.Experimental})
 }
 
-// SetupSwaggerRoutes configures Swagger documentation routes
-func (rt *Routes) SetupSwaggerRoutes(r chi.Router, swaggerHandler http.HandlerFunc) {
-	r.Get("/swagger/*", swaggerHandler)
+// SetupSwaggerRoutes configures Swagger documentation routes, as
+// SetDocsExposure asks: for anyone, for principals with AdminScope, or not at
+// all.
+func (rt *Routes) SetupSwaggerRoutes(r Router, swaggerHandler, docHandler http.HandlerFunc) {
+	if rt.docs == DocsDisabled {
+		return
+	}
+	var scope string
+	if rt.docs == DocsAdmin {
+		scope = AdminScope
+	}
+	r.Method(http.MethodGet, "/swagger/doc.json", docHandler, Meta{Name: "docs.swagger_json", Description: "OpenAPI document", Scope: scope})
+	r.Method(http.MethodGet, "/swagger/*", swaggerHandler, Meta{Name: "docs.swagger_ui", Description: "Swagger UI", Scope: scope})
 
 	// Alias the Swagger UI under /api-docs as well
-	r.Get("/api-docs", func(w http.ResponseWriter, req *http.Request) {
-		http.Redirect(w, req, "/api-docs/index.html", http.StatusTemporaryRedirect)
-	})
-	r.Get("/api-docs/*", swaggerHandler)
+	r.Method(http.MethodGet, "/api-docs", http.RedirectHandler("/api-docs/index.html", http.StatusTemporaryRedirect), Meta{Name: "docs.api_docs", Description: "Redirect to the Swagger UI", Scope: scope})
+	r.Method(http.MethodGet, "/api-docs/doc.json", docHandler, Meta{Name: "docs.api_docs_json", Description: "OpenAPI document", Scope: scope})
+	r.Method(http.MethodGet, "/api-docs/*", swaggerHandler, Meta{Name: "docs.api_docs_ui", Description: "Swagger UI", Scope: scope})
 }
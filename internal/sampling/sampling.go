@@ -0,0 +1,188 @@
+// Package sampling keeps a few anonymized request and response bodies per
+// route, so a client sending malformed or unexpected payloads can be
+// diagnosed from the admin API without logging bodies. JSON bodies keep
+// their structure, numbers, booleans and the shape of their strings;
+// letters and digits are masked and fields named like secrets are
+// redacted. Other bodies are described by type and size only.
+package sampling
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mikko-kohtala/go-api/internal/scrub"
+)
+
+// Sample is one captured request and its response.
+type Sample struct {
+	Time      time.Time     `json:"time"`
+	Route     string        `json:"route"`
+	Method    string        `json:"method"`
+	URL       string        `json:"url"` // query secrets redacted
+	RequestID string        `json:"request_id,omitempty"`
+	Status    int           `json:"status"`
+	Duration  time.Duration `json:"duration"`
+	Request   Body          `json:"request"`
+	Response  Body          `json:"response"`
+}
+
+// Body is an anonymized body. JSON is set for JSON bodies that were captured
+// whole; Truncated ones are described by their size only.
+type Body struct {
+	ContentType string          `json:"content_type,omitempty"`
+	Size        int64           `json:"size"`
+	Truncated   bool            `json:"truncated,omitempty"`
+	JSON        json.RawMessage `json:"json,omitempty"`
+}
+
+// Options configure a Sampler.
+type Options struct {
+	Rate         float64 // share of requests sampled, 0 to 1
+	PerRoute     int     // samples kept per route; 0 means 20
+	MaxBodyBytes int     // longest body captured; 0 means 4096
+}
+
+// Sampler decides which requests are sampled and keeps the latest samples
+// of each route in a ring buffer.
+type Sampler struct {
+	opts Options
+
+	mu    sync.Mutex
+	rings map[string]*ring
+}
+
+// ring holds the latest samples of a route, next being the oldest once full.
+type ring struct {
+	samples []Sample
+	next    int
+}
+
+// Default is the sampler of the body sampling middleware and the admin
+// endpoint. main replaces it with one configured by BODY_SAMPLE_*; the
+// default samples nothing.
+var Default = New(Options{})
+
+// New returns a sampler without samples.
+func New(opts Options) *Sampler {
+	if opts.PerRoute <= 0 {
+		opts.PerRoute = 20
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 4096
+	}
+	return &Sampler{opts: opts, rings: map[string]*ring{}}
+}
+
+// Enabled reports whether s samples any requests.
+func (s *Sampler) Enabled() bool { return s.opts.Rate > 0 }
+
+// MaxBodyBytes returns the longest body s captures.
+func (s *Sampler) MaxBodyBytes() int { return s.opts.MaxBodyBytes }
+
+// Pick decides whether to sample a request.
+func (s *Sampler) Pick() bool {
+	return s.opts.Rate > 0 && rand.Float64() < s.opts.Rate
+}
+
+// Record keeps sample, replacing the oldest of its route once the route has
+// PerRoute samples.
+func (s *Sampler) Record(sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.rings[sample.Route]
+	if r == nil {
+		r = &ring{}
+		s.rings[sample.Route] = r
+	}
+	if len(r.samples) < s.opts.PerRoute {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+// Recent returns the kept samples of route, or of every route when route is
+// empty, newest first.
+func (s *Sampler) Recent(route string) []Sample {
+	s.mu.Lock()
+	var out []Sample
+	for name, r := range s.rings {
+		if route == "" || name == route {
+			out = append(out, r.samples...)
+		}
+	}
+	s.mu.Unlock()
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	return out
+}
+
+// Clear drops every sample.
+func (s *Sampler) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rings = map[string]*ring{}
+}
+
+// Anonymize describes a body of contentType whose first bytes are data and
+// whose full size is size.
+func Anonymize(contentType string, data []byte, size int64) Body {
+	b := Body{ContentType: contentType, Size: size, Truncated: int64(len(data)) < size}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if b.Truncated || len(data) == 0 || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+		return b
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return b
+	}
+	b.JSON, _ = json.Marshal(mask("", v))
+	return b
+}
+
+// mask anonymizes v, the value of the field named key.
+func mask(key string, v any) any {
+	if key != "" && scrub.IsSensitiveKey(key) {
+		return scrub.Redacted
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = mask(k, e)
+		}
+		return v
+	case []any:
+		for i, e := range v {
+			v[i] = mask(key, e)
+		}
+		return v
+	case string:
+		return shape(v)
+	default:
+		return v // numbers, booleans and null
+	}
+}
+
+// shape masks letters as x and digits as 9, keeping the rest, so formats
+// ("9999-99-99", "xxxxx@xxxxxxx.xxx") stay visible while content does not.
+func shape(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r):
+			return 'x'
+		case unicode.IsDigit(r):
+			return '9'
+		default:
+			return r
+		}
+	}, s)
+}
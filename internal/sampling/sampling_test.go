@@ -0,0 +1,45 @@
+package sampling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnonymize_MasksJSON(t *testing.T) {
+	body := []byte(`{"email":"alice@example.com","age":41,"active":true,"born":"1985-03-01","password":"hunter2","tags":["a1"],"api_token":{"v":1}}`)
+	got := Anonymize("application/json; charset=utf-8", body, int64(len(body)))
+	want := `{"active":true,"age":41,"api_token":"[REDACTED]","born":"9999-99-99","email":"xxxxx@xxxxxxx.xxx","password":"[REDACTED]","tags":["x9"]}`
+	if string(got.JSON) != want {
+		t.Fatalf("expected %s, got %s", want, got.JSON)
+	}
+}
+
+func TestAnonymize_DescribesOtherBodies(t *testing.T) {
+	if got := Anonymize("text/plain", []byte("secret"), 6); got.JSON != nil || got.Size != 6 {
+		t.Fatalf("expected only the size of a text body, got %+v", got)
+	}
+	if got := Anonymize("application/json", []byte(`{"a":`), 100); got.JSON != nil || !got.Truncated {
+		t.Fatalf("expected a truncated body to be described by size, got %+v", got)
+	}
+}
+
+func TestSampler_KeepsLatestPerRoute(t *testing.T) {
+	s := New(Options{Rate: 1, PerRoute: 2})
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		s.Record(Sample{Time: start.Add(time.Duration(i) * time.Second), Route: "/api/v1/users/", Status: 200 + i})
+	}
+	s.Record(Sample{Time: start, Route: "/api/v1/tasks"})
+
+	got := s.Recent("/api/v1/users/")
+	if len(got) != 2 || got[0].Status != 202 || got[1].Status != 201 {
+		t.Fatalf("expected the two latest samples, newest first, got %+v", got)
+	}
+	if len(s.Recent("")) != 3 {
+		t.Fatalf("expected samples of every route, got %d", len(s.Recent("")))
+	}
+	s.Clear()
+	if len(s.Recent("")) != 0 {
+		t.Fatal("expected no samples after Clear")
+	}
+}
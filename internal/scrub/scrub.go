@@ -0,0 +1,126 @@
+// Package scrub redacts credentials and other secrets from request dumps,
+// stack traces, and error reports before they reach the log pipeline.
+package scrub
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces secret values.
+const Redacted = "[REDACTED]"
+
+// sensitiveHeaders are always redacted regardless of name heuristics.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// sensitiveKeyParts mark header, query, and field names carrying secrets.
+var sensitiveKeyParts = []string{"token", "secret", "password", "passwd", "api-key", "api_key", "apikey", "signature", "sig", "session", "credential", "private"}
+
+var (
+	authSchemePattern = regexp.MustCompile(`(?i)\b(bearer|basic|digest|token)\s+[A-Za-z0-9._~+/=-]+`)
+	jwtPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// key=value, key: value and "key":"value" pairs whose key looks sensitive
+	keyValuePattern = regexp.MustCompile(`(?i)("?[A-Za-z0-9_-]*(?:token|secret|password|passwd|api[_-]?key|signature|credential)[A-Za-z0-9_-]*"?\s*[:=]\s*)("[^"]*"|[^\s&,;"]+)`)
+)
+
+// IsSensitiveKey reports whether a header, query parameter, or field name is
+// likely to carry a secret.
+func IsSensitiveKey(name string) bool {
+	if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, part := range sensitiveKeyParts {
+		if lower == part || (part != "sig" && strings.Contains(lower, part)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Header returns a copy of h with sensitive values redacted.
+func Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		if IsSensitiveKey(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		clean := make([]string, len(vs))
+		for i, v := range vs {
+			clean[i] = String(v)
+		}
+		out[k] = clean
+	}
+	return out
+}
+
+// URL returns u as a string with sensitive query parameters and userinfo redacted.
+func URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if c.User != nil {
+		c.User = url.User(Redacted)
+	}
+	if c.RawQuery != "" {
+		q := c.Query()
+		for k := range q {
+			if IsSensitiveKey(k) {
+				q[k] = []string{Redacted}
+			}
+		}
+		c.RawQuery = q.Encode()
+	}
+	return c.String()
+}
+
+// String redacts credentials embedded in free text such as panic messages,
+// error strings, and stack traces.
+func String(s string) string {
+	s = authSchemePattern.ReplaceAllString(s, "$1 "+Redacted)
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = keyValuePattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := keyValuePattern.FindStringSubmatch(m)
+		if strings.HasPrefix(parts[2], `"`) {
+			return parts[1] + `"` + Redacted + `"`
+		}
+		return parts[1] + Redacted
+	})
+	return s
+}
+
+// Value scrubs an arbitrary panic or error value, returning its string form.
+func Value(v any) string {
+	return String(fmt.Sprint(v))
+}
+
+// DumpRequest renders the request line and headers with secrets redacted.
+// Bodies are never included.
+func DumpRequest(r *http.Request) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s\r\n", r.Method, URL(r.URL), r.Proto)
+	fmt.Fprintf(&b, "Host: %s\r\n", r.Host)
+	h := Header(r.Header)
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	return b.String()
+}
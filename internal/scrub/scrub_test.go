@@ -0,0 +1,38 @@
+package scrub
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestString_RedactsCredentials(t *testing.T) {
+	in := `auth failed: Authorization: Bearer abc.def-123 password=hunter2 {"api_key":"k-999","name":"ok"} jwt eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig`
+	out := String(in)
+	for _, secret := range []string{"abc.def-123", "hunter2", "k-999", "eyJhbGciOiJIUzI1NiJ9"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("expected %q to be scrubbed from %q", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"name":"ok"`) {
+		t.Fatalf("expected non-sensitive fields to survive: %q", out)
+	}
+}
+
+func TestDumpRequest_RedactsHeadersAndQuery(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/files?id=7&token=s3cr3t&sig=abcd", nil)
+	req.Header.Set("Authorization", "Bearer top-secret")
+	req.Header.Set("Cookie", "session=xyz")
+	req.Header.Set("X-Api-Key", "key-123")
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	dump := DumpRequest(req)
+	for _, secret := range []string{"s3cr3t", "abcd", "top-secret", "session=xyz", "key-123"} {
+		if strings.Contains(dump, secret) {
+			t.Fatalf("expected %q to be scrubbed from dump:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, "id=7") || !strings.Contains(dump, "User-Agent: curl/8.0") {
+		t.Fatalf("expected harmless data to survive:\n%s", dump)
+	}
+}
@@ -0,0 +1,217 @@
+// Package secrets resolves the credentials handlers present to upstreams,
+// such as a partner's API key, at request time. A secret may be set for the
+// caller's tenant, for the route serving the request, or for every request;
+// the most specific one wins. Values are cached for a TTL and read again from
+// their source afterwards, so secrets rotated on disk or in the environment
+// are picked up without a restart. Handlers call Get instead of reading
+// environment variables.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+)
+
+// ErrNotFound is returned for a secret set in none of the request's scopes.
+var ErrNotFound = errors.New("secret not found")
+
+// Source looks secrets up by key. Keys are slash-separated, e.g.
+// "tenants/acme/billing_api_key", "routes/tasks.create/billing_api_key" or
+// "billing_api_key"; a source without the key returns ErrNotFound.
+type Source interface {
+	Lookup(ctx context.Context, key string) (string, error)
+}
+
+// Env reads secrets from environment variables named Prefix followed by the
+// key in upper case with every other character than letters and digits
+// replaced by "_": "tenants/acme/billing_api_key" is SECRET_TENANTS_ACME_BILLING_API_KEY.
+type Env struct {
+	Prefix string
+}
+
+func (e Env) Lookup(_ context.Context, key string) (string, error) {
+	name := e.Prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// Dir reads secrets from files under Path named by their key, as a mounted
+// Kubernetes secret or a Vault agent writes them. A trailing newline is
+// dropped.
+type Dir struct {
+	Path string
+}
+
+func (d Dir) Lookup(_ context.Context, key string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(d.Path, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("read secret %s: %w", key, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Map holds secrets by key, for tests and fixed configuration.
+type Map map[string]string
+
+func (m Map) Lookup(_ context.Context, key string) (string, error) {
+	v, ok := m[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// Resolver caches the secrets of a Source for ttl. Absent keys are cached
+// too, so a request does not look up every scope it has no secret in. When a
+// lookup fails, the previous value is used until Invalidate.
+type Resolver struct {
+	src Source
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// entry is a cached lookup; ok is false for an absent key.
+type entry struct {
+	value   string
+	ok      bool
+	fetched time.Time
+}
+
+// Default is the resolver of Get and the admin endpoint. main replaces it
+// with one configured by SECRETS_*; the default knows no secrets.
+var Default = NewResolver(Map{}, time.Minute)
+
+// NewResolver returns a resolver reading src at most once per ttl and key.
+func NewResolver(src Source, ttl time.Duration) *Resolver {
+	return &Resolver{src: src, ttl: ttl, now: time.Now, entries: map[string]entry{}}
+}
+
+// Get returns the secret name of the request ctx belongs to, from Default.
+func Get(ctx context.Context, name string) (string, error) {
+	return Default.Get(ctx, name)
+}
+
+// Get returns the secret name for the tenant of ctx's principal, else for
+// the route serving the request, else the one set for every request.
+// Scopes whose tenant or route name cannot be part of a key are skipped.
+func (r *Resolver) Get(ctx context.Context, name string) (string, error) {
+	if !validSegment(name) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	var keys []string
+	if p, ok := requestctx.Principal(ctx); ok && validSegment(p.Tenant) {
+		keys = append(keys, "tenants/"+p.Tenant+"/"+name)
+	}
+	if route := requestctx.Handler(ctx); validSegment(route) {
+		keys = append(keys, "routes/"+route+"/"+name)
+	}
+	keys = append(keys, name)
+	for _, key := range keys {
+		v, err := r.lookup(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return v, err
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// lookup returns key from the cache, reading it from the source once it is
+// older than the TTL.
+func (r *Resolver) lookup(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	e, cached := r.entries[key]
+	r.mu.Unlock()
+	now := r.now()
+	if !cached || now.Sub(e.fetched) >= r.ttl {
+		v, err := r.src.Lookup(ctx, key)
+		switch {
+		case err == nil:
+			e, cached = entry{value: v, ok: true, fetched: now}, true
+			metrics.ObserveSecretRefresh("ok")
+		case errors.Is(err, ErrNotFound):
+			e, cached = entry{fetched: now}, true
+			metrics.ObserveSecretRefresh("not_found")
+		default:
+			metrics.ObserveSecretRefresh("error")
+			if !cached {
+				return "", err
+			}
+			// Keep serving the previous value, and retry after another TTL
+			e.fetched = now
+		}
+		r.mu.Lock()
+		r.entries[key] = e
+		r.mu.Unlock()
+	}
+	if !e.ok {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+// Invalidate drops the cached values of name in every scope, e.g. after an
+// upstream rejected it, so the next Get reads the rotated one. An empty name
+// drops every cached value.
+func (r *Resolver) Invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.entries {
+		if name == "" || key == name || strings.HasSuffix(key, "/"+name) {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// Cached describes a cached key without its value.
+type Cached struct {
+	Key     string    `json:"key"`
+	Found   bool      `json:"found"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// Cached lists the cached keys by key.
+func (r *Resolver) Cached() []Cached {
+	r.mu.Lock()
+	out := make([]Cached, 0, len(r.entries))
+	for key, e := range r.entries {
+		out = append(out, Cached{Key: key, Found: e.ok, Fetched: e.fetched})
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// validSegment reports whether s can be one segment of a key: file names
+// must not climb out of a Dir.
+func validSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, "/\\\x00")
+}
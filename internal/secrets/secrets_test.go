@@ -0,0 +1,142 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+)
+
+// requestFor returns the context of a request of tenant served by route.
+func requestFor(tenant, route string) context.Context {
+	ctx := requestctx.New(context.Background())
+	if tenant != "" {
+		ctx = requestctx.SetPrincipal(ctx, requestctx.Identity{UserID: "u1", Tenant: tenant})
+	}
+	requestctx.SetHandler(ctx, route)
+	return ctx
+}
+
+func TestResolver_MostSpecificScopeWins(t *testing.T) {
+	r := NewResolver(Map{
+		"billing_api_key":                       "global",
+		"routes/reports.create/billing_api_key": "route",
+		"tenants/acme/billing_api_key":          "acme",
+	}, time.Minute)
+	for _, tc := range []struct {
+		tenant, route, want string
+	}{
+		{"acme", "reports.create", "acme"},
+		{"globex", "reports.create", "route"},
+		{"globex", "tasks.create", "global"},
+		{"", "", "global"},
+		{"../acme", "tasks.create", "global"}, // not a key segment
+	} {
+		got, err := r.Get(requestFor(tc.tenant, tc.route), "billing_api_key")
+		if err != nil || got != tc.want {
+			t.Fatalf("tenant %q route %q: expected %q, got %q (err %v)", tc.tenant, tc.route, tc.want, got, err)
+		}
+	}
+	if _, err := r.Get(context.Background(), "other"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := r.Get(context.Background(), "../billing_api_key"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an invalid name, got %v", err)
+	}
+}
+
+// countingSource counts lookups and fails while err is set.
+type countingSource struct {
+	Map
+	lookups int
+	err     error
+}
+
+func (c *countingSource) Lookup(ctx context.Context, key string) (string, error) {
+	c.lookups++
+	if c.err != nil {
+		return "", c.err
+	}
+	return c.Map.Lookup(ctx, key)
+}
+
+func TestResolver_CachesAndPicksUpRotation(t *testing.T) {
+	src := &countingSource{Map: Map{"tenants/acme/token": "v1"}}
+	r := NewResolver(src, time.Minute)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	ctx := requestFor("acme", "tasks.create")
+
+	for range 3 {
+		if v, _ := r.Get(ctx, "token"); v != "v1" {
+			t.Fatalf("expected v1, got %q", v)
+		}
+	}
+	if src.lookups != 1 {
+		t.Fatalf("expected one lookup, got %d", src.lookups)
+	}
+
+	src.Map["tenants/acme/token"] = "v2"
+	if v, _ := r.Get(ctx, "token"); v != "v1" {
+		t.Fatalf("expected the cached v1 within the TTL, got %q", v)
+	}
+	now = now.Add(time.Minute)
+	if v, _ := r.Get(ctx, "token"); v != "v2" {
+		t.Fatalf("expected the rotated v2 after the TTL, got %q", v)
+	}
+
+	src.Map["tenants/acme/token"] = "v3"
+	r.Invalidate("token")
+	if v, _ := r.Get(ctx, "token"); v != "v3" {
+		t.Fatalf("expected v3 after Invalidate, got %q", v)
+	}
+	if c := r.Cached(); len(c) != 1 || c[0].Key != "tenants/acme/token" || !c[0].Found {
+		t.Fatalf("unexpected cache %+v", c)
+	}
+}
+
+func TestResolver_KeepsValueWhenSourceFails(t *testing.T) {
+	src := &countingSource{Map: Map{"token": "v1"}}
+	r := NewResolver(src, time.Minute)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	if v, _ := r.Get(context.Background(), "token"); v != "v1" {
+		t.Fatalf("expected v1, got %q", v)
+	}
+	src.err = errors.New("vault unavailable")
+	now = now.Add(2 * time.Minute)
+	if v, err := r.Get(context.Background(), "token"); err != nil || v != "v1" {
+		t.Fatalf("expected the previous v1, got %q (err %v)", v, err)
+	}
+	if _, err := r.Get(context.Background(), "other"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the source error for an uncached key, got %v", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("SECRET_TENANTS_ACME_BILLING_API_KEY", "s3cret")
+	v, err := Env{Prefix: "SECRET_"}.Lookup(context.Background(), "tenants/acme/billing-api.key")
+	if err != nil || v != "s3cret" {
+		t.Fatalf("expected s3cret, got %q (err %v)", v, err)
+	}
+	if _, err := (Env{Prefix: "SECRET_"}).Lookup(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "tenants", "acme"), 0o700)
+	_ = os.WriteFile(filepath.Join(dir, "tenants", "acme", "token"), []byte("s3cret\n"), 0o600)
+	v, err := Dir{Path: dir}.Lookup(context.Background(), "tenants/acme/token")
+	if err != nil || v != "s3cret" {
+		t.Fatalf("expected s3cret, got %q (err %v)", v, err)
+	}
+	if _, err := (Dir{Path: dir}).Lookup(context.Background(), "token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidAPIKey is returned for a key that is unknown, malformed,
+	// revoked or expired; callers are not told which.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrInvalidScope  = errors.New("invalid scope")
+)
+
+// apiKeyPrefix starts every issued key, so leaked keys are easy to find
+// with secret scanners.
+const apiKeyPrefix = "gak_"
+
+// scopePattern is the form of a scope, e.g. "apikeys" or "users:write".
+var scopePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(:[a-z][a-z0-9_]*)?$`)
+
+// APIKey is an issued key as clients and logs see it; the secret is only
+// returned once, by Issue.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"` // the principal requests made with the key act as
+	Prefix     string     `json:"prefix"`   // the start of the key, to recognise it by
+	Scopes     []string   `json:"scopes"`   // what the key may do; routes declare the scope they need
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`   // never when nil
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // last successful authentication
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IssuedKey is a newly issued key together with its secret.
+type IssuedKey struct {
+	APIKey
+	Key string `json:"key"` // the full key; it cannot be retrieved again
+}
+
+// NewAPIKey holds the fields of a key to issue.
+type NewAPIKey struct {
+	Name      string
+	OwnerID   string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// APIKeyService issues, authenticates and revokes API keys for machine
+// clients. Only a SHA-256 hash of each key is stored.
+type APIKeyService interface {
+	Issue(ctx context.Context, k NewAPIKey) (*IssuedKey, error)
+	// List returns the keys of owner, or every key when owner is empty,
+	// oldest first.
+	List(ctx context.Context, owner string) ([]APIKey, error)
+	Get(ctx context.Context, id string) (*APIKey, error)
+	Revoke(ctx context.Context, id string) (*APIKey, error)
+	// Authenticate returns the key key identifies, or ErrInvalidAPIKey.
+	Authenticate(ctx context.Context, key string) (*APIKey, error)
+}
+
+// NewAPIKeyService creates an in-memory APIKeyService.
+func NewAPIKeyService() APIKeyService {
+	return &apiKeyService{keys: make(map[string]*storedKey), now: time.Now}
+}
+
+type storedKey struct {
+	APIKey
+	Hash string `json:"hash"` // hex SHA-256 of the key
+}
+
+type apiKeyService struct {
+	mu   sync.RWMutex
+	keys map[string]*storedKey
+	now  func() time.Time
+}
+
+func (s *apiKeyService) Issue(_ context.Context, k NewAPIKey) (*IssuedKey, error) {
+	if strings.TrimSpace(k.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	if k.OwnerID == "" {
+		return nil, ErrInvalidUserID
+	}
+	for _, scope := range k.Scopes {
+		if !scopePattern.MatchString(scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+	var id [6]byte
+	var secret [32]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret[:]); err != nil {
+		return nil, err
+	}
+	keyID := hex.EncodeToString(id[:])
+	raw := apiKeyPrefix + keyID + "_" + base64.RawURLEncoding.EncodeToString(secret[:])
+
+	scopes := slices.Clone(k.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	slices.Sort(scopes)
+	stored := &storedKey{
+		APIKey: APIKey{
+			ID:        "key_" + keyID,
+			Name:      k.Name,
+			OwnerID:   k.OwnerID,
+			Prefix:    apiKeyPrefix + keyID,
+			Scopes:    slices.Compact(scopes),
+			CreatedAt: s.now().UTC(),
+			ExpiresAt: k.ExpiresAt,
+		},
+		Hash: hashAPIKey(raw),
+	}
+	s.mu.Lock()
+	s.keys[stored.ID] = stored
+	s.mu.Unlock()
+	return &IssuedKey{APIKey: stored.APIKey, Key: raw}, nil
+}
+
+func (s *apiKeyService) List(_ context.Context, owner string) ([]APIKey, error) {
+	s.mu.RLock()
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		if owner == "" || k.OwnerID == owner {
+			keys = append(keys, k.APIKey)
+		}
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(keys, compareAPIKeys)
+	return keys, nil
+}
+
+func (s *apiKeyService) Get(_ context.Context, id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	cp := k.APIKey
+	return &cp, nil
+}
+
+func (s *apiKeyService) Revoke(_ context.Context, id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	if k.RevokedAt == nil {
+		now := s.now().UTC()
+		k.RevokedAt = &now
+	}
+	cp := k.APIKey
+	return &cp, nil
+}
+
+func (s *apiKeyService) Authenticate(_ context.Context, key string) (*APIKey, error) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	keyID, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys["key_"+keyID]
+	if !ok || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashAPIKey(key))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if k.RevokedAt != nil || (k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	used := now.UTC()
+	k.LastUsedAt = &used
+	cp := k.APIKey
+	return &cp, nil
+}
+
+// Snapshot returns every key with its hash, oldest first, for snapshot.Store.
+func (s *apiKeyService) Snapshot() (any, error) {
+	s.mu.RLock()
+	keys := make([]storedKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(keys, func(a, b storedKey) int { return compareAPIKeys(a.APIKey, b.APIKey) })
+	return keys, nil
+}
+
+// Restore decodes keys written by Snapshot and returns a function replacing
+// every key with them, for snapshot.Store.
+func (s *apiKeyService) Restore(data json.RawMessage) (func(), error) {
+	var list []storedKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*storedKey, len(list))
+	for i := range list {
+		if list[i].ID == "" || list[i].Hash == "" {
+			return nil, errors.New("api key without id or hash")
+		}
+		keys[list[i].ID] = &list[i]
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.keys = keys
+	}, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func compareAPIKeys(a, b APIKey) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_760_000_000, 0)
+	svc := NewAPIKeyService().(*apiKeyService)
+	svc.now = func() time.Time { return now }
+
+	if _, err := svc.Issue(ctx, NewAPIKey{Name: "ci", OwnerID: "usr_001", Scopes: []string{"Users!"}}); !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("expected ErrInvalidScope, got %v", err)
+	}
+	expires := now.Add(time.Hour)
+	issued, err := svc.Issue(ctx, NewAPIKey{Name: "ci", OwnerID: "usr_001", Scopes: []string{"users:write", "apikeys", "apikeys"}, ExpiresAt: &expires})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !strings.HasPrefix(issued.Key, issued.Prefix+"_") || len(issued.Scopes) != 2 {
+		t.Fatalf("unexpected issued key %+v", issued)
+	}
+
+	key, err := svc.Authenticate(ctx, issued.Key)
+	if err != nil || key.ID != issued.ID || key.LastUsedAt == nil {
+		t.Fatalf("Authenticate = %+v, %v", key, err)
+	}
+	for _, raw := range []string{"", "gak_", issued.Key + "x", strings.Replace(issued.Key, "gak_", "xyz_", 1)} {
+		if _, err := svc.Authenticate(ctx, raw); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate(%q): expected ErrInvalidAPIKey, got %v", raw, err)
+		}
+	}
+
+	// Snapshots keep only the hash, and restored keys still authenticate
+	state, _ := svc.Snapshot()
+	data, _ := json.Marshal(state)
+	if strings.Contains(string(data), issued.Key) {
+		t.Fatal("snapshot contains the raw key")
+	}
+	restored := NewAPIKeyService().(*apiKeyService)
+	restored.now = svc.now
+	swap, err := restored.Restore(data)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	swap()
+	if _, err := restored.Authenticate(ctx, issued.Key); err != nil {
+		t.Fatalf("Authenticate after restore: %v", err)
+	}
+
+	now = expires
+	if _, err := svc.Authenticate(ctx, issued.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected an expired key to be refused, got %v", err)
+	}
+	if _, err := restored.Revoke(ctx, issued.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := restored.Authenticate(ctx, issued.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected a revoked key to be refused, got %v", err)
+	}
+	if _, err := restored.Revoke(ctx, "key_missing"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+
+	"github.com/mikko-kohtala/go-api/internal/audit"
+)
+
+// NewAuditedUserService records the before and after state of every user
+// inner creates, updates or deletes with audit.RecordChange, for the audit
+// record of the request. Outside an audited request it only passes calls
+// through.
+func NewAuditedUserService(inner UserService) UserService {
+	return &auditedUserService{UserService: inner}
+}
+
+type auditedUserService struct {
+	UserService
+}
+
+func (s *auditedUserService) CreateUser(ctx context.Context, email, name string) (*User, error) {
+	user, err := s.UserService.CreateUser(ctx, email, name)
+	if err == nil {
+		audit.RecordChange(ctx, "user", user.ID, nil, user)
+	}
+	return user, err
+}
+
+func (s *auditedUserService) UpdateUser(ctx context.Context, id string, updates map[string]interface{}) (*User, error) {
+	before := s.before(ctx, id)
+	user, err := s.UserService.UpdateUser(ctx, id, updates)
+	if err == nil {
+		audit.RecordChange(ctx, "user", id, before, user)
+	}
+	return user, err
+}
+
+func (s *auditedUserService) DeleteUser(ctx context.Context, id string) error {
+	before := s.before(ctx, id)
+	err := s.UserService.DeleteUser(ctx, id)
+	if err == nil {
+		audit.RecordChange(ctx, "user", id, before, nil)
+	}
+	return err
+}
+
+// before returns a copy of the user before a change, or nil when the request
+// is not audited or the user cannot be read.
+func (s *auditedUserService) before(ctx context.Context, id string) *User {
+	if !audit.Collecting(ctx) {
+		return nil
+	}
+	user, err := s.UserService.GetUserByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ChangeClock tracks when a collection last changed, so listing it can be
+// answered with 304 Not Modified without reading it. Services touch it on
+// every write. It only sees writes made through this process, and starts at
+// its creation: what changed before is unknown.
+type ChangeClock struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewChangeClock creates a clock whose collection last changed now.
+func NewChangeClock() *ChangeClock {
+	return &ChangeClock{last: time.Now()}
+}
+
+// Touch records a change of the collection at t. The clock never goes back.
+func (c *ChangeClock) Touch(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.last) {
+		c.last = t
+	}
+}
+
+// LastModified returns when the collection last changed.
+func (c *ChangeClock) LastModified() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+)
+
+// Erasure lists what was erased about a user.
+type Erasure struct {
+	UserID string   `json:"user_id"`
+	Erased []string `json:"erased"`
+}
+
+// ErasureService erases what is stored about a user, on request of the user
+// ("right to be forgotten").
+type ErasureService interface {
+	// Erase removes the user's data step by step, reporting the steps done
+	// through t. The user record goes last, so a failed erasure can be
+	// started again.
+	Erase(ctx context.Context, userID string, t *operations.Tracker) (*Erasure, error)
+}
+
+type erasureService struct {
+	users UserService
+	prefs notify.PreferenceStore
+}
+
+func NewErasureService(users UserService, prefs notify.PreferenceStore) ErasureService {
+	return &erasureService{users: users, prefs: prefs}
+}
+
+func (s *erasureService) Erase(ctx context.Context, userID string, t *operations.Tracker) (*Erasure, error) {
+	steps := []struct {
+		name  string
+		erase func(ctx context.Context, userID string) error
+	}{
+		{"notification_preferences", s.prefs.Delete},
+		{"user", s.users.DeleteUser},
+	}
+	erasure := &Erasure{UserID: userID, Erased: []string{}}
+	for i, step := range steps {
+		if err := step.erase(ctx, userID); err != nil {
+			return nil, fmt.Errorf("erase %s: %w", step.name, err)
+		}
+		erasure.Erased = append(erasure.Erased, step.name)
+		t.Progress(i+1, len(steps))
+	}
+	return erasure, nil
+}
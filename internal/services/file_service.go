@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/storage"
+)
+
+var (
+	ErrFileNotFound     = errors.New("file not found")
+	ErrUploadExpired    = errors.New("upload expired")
+	ErrUploadTooLarge   = errors.New("upload exceeds maximum size")
+	ErrOffsetMismatch   = errors.New("upload offset mismatch")
+	ErrUploadComplete   = errors.New("upload already complete")
+	ErrUploadIncomplete = errors.New("upload incomplete")
+	ErrUploadLocked     = errors.New("upload is being written by another request")
+)
+
+// File describes an uploaded (or uploading) file. Offset tracks how many bytes
+// have been received; the file is usable once Offset reaches Size.
+type File struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Size        int64             `json:"size"`
+	Offset      int64             `json:"offset"`
+	Complete    bool              `json:"complete"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"` // set while the upload is incomplete
+}
+
+// NewUpload describes a file upload to be created.
+type NewUpload struct {
+	Size        int64
+	Name        string
+	ContentType string
+	Metadata    map[string]string
+}
+
+type FileService interface {
+	// CreateUpload registers a new resumable upload with zero bytes received.
+	CreateUpload(ctx context.Context, upload NewUpload) (*File, error)
+	// SaveFile stores a complete file in one step, e.g. a generated artifact.
+	SaveFile(ctx context.Context, upload NewUpload, r io.Reader) (*File, error)
+	// AppendChunk writes r at offset, which must equal the current upload offset.
+	// On a partial write the offset still advances by the bytes received.
+	AppendChunk(ctx context.Context, id string, offset int64, r io.Reader) (*File, error)
+	GetFile(ctx context.Context, id string) (*File, error)
+	// Open returns the contents of a completed file.
+	Open(ctx context.Context, id string) (io.ReadSeekCloser, *File, error)
+	DeleteFile(ctx context.Context, id string) error
+	// ExpireUploads removes incomplete uploads past their expiry and returns how many were removed.
+	ExpireUploads(ctx context.Context) (int, error)
+	// PurgeOlderThan deletes completed files created before cutoff and returns
+	// how many were removed, or with dryRun how many would be.
+	PurgeOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (int, error)
+	// MaxSize reports the largest accepted upload in bytes.
+	MaxSize() int64
+}
+
+type fileService struct {
+	mu      sync.Mutex
+	files   map[string]*File
+	writing map[string]bool // uploads with a chunk in flight
+	store   storage.Store
+	maxSize int64
+	expiry  time.Duration
+	now     func() time.Time
+}
+
+// NewFileService creates a file service backed by store. Uploads larger than
+// maxSize are rejected and incomplete uploads expire after expiry.
+func NewFileService(store storage.Store, maxSize int64, expiry time.Duration) FileService {
+	return &fileService{
+		files:   make(map[string]*File),
+		writing: make(map[string]bool),
+		store:   store,
+		maxSize: maxSize,
+		expiry:  expiry,
+		now:     time.Now,
+	}
+}
+
+func (s *fileService) MaxSize() int64 {
+	return s.maxSize
+}
+
+func (s *fileService) CreateUpload(ctx context.Context, upload NewUpload) (*File, error) {
+	if upload.Size < 0 {
+		return nil, errors.New("size must be >= 0")
+	}
+	if upload.Size > s.maxSize {
+		return nil, ErrUploadTooLarge
+	}
+	// Opportunistically clear abandoned uploads so they never accumulate
+	if _, err := s.ExpireUploads(ctx); err != nil {
+		return nil, err
+	}
+
+	// Unlike the time-ordered IDs of ids.Default, whose creation time narrows
+	// down a guess, file IDs are random: nothing sorts them, and they address
+	// an upload's bytes
+	id, err := newRandomID("file_")
+	if err != nil {
+		return nil, err
+	}
+	// Create the (possibly empty) object up front so zero-length files can be opened
+	if _, err := s.store.Put(ctx, id, eofReader{}); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	expires := now.Add(s.expiry)
+	f := &File{
+		ID:          id,
+		Name:        upload.Name,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		Metadata:    upload.Metadata,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   &expires,
+	}
+	if f.Size == 0 {
+		f.Complete = true
+		f.ExpiresAt = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[id] = f
+	return f.copy(), nil
+}
+
+func (s *fileService) SaveFile(ctx context.Context, upload NewUpload, r io.Reader) (*File, error) {
+	f, err := s.CreateUpload(ctx, upload)
+	if err != nil || f.Complete {
+		return f, err
+	}
+	id := f.ID
+	f, err = s.AppendChunk(ctx, id, 0, r)
+	if err == nil && !f.Complete {
+		err = ErrUploadIncomplete // r ended before Size bytes
+	}
+	if err != nil {
+		_ = s.DeleteFile(ctx, id)
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *fileService) AppendChunk(ctx context.Context, id string, offset int64, r io.Reader) (*File, error) {
+	s.mu.Lock()
+	f, err := s.lookup(ctx, id)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	switch {
+	case f.Complete:
+		s.mu.Unlock()
+		return f.copy(), ErrUploadComplete
+	case offset != f.Offset:
+		s.mu.Unlock()
+		return f.copy(), ErrOffsetMismatch
+	case s.writing[id]:
+		s.mu.Unlock()
+		return f.copy(), ErrUploadLocked
+	}
+	s.writing[id] = true
+	remaining := f.Size - f.Offset
+	s.mu.Unlock()
+
+	// Write outside the lock; the writing flag keeps other chunks out
+	n, werr := s.store.Append(ctx, id, io.LimitReader(r, remaining))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.writing, id)
+	if _, ok := s.files[id]; !ok {
+		// Deleted while the chunk was in flight
+		_ = s.store.Delete(ctx, id)
+		return nil, ErrFileNotFound
+	}
+	f.Offset += n
+	f.UpdatedAt = s.now()
+	if f.Offset == f.Size {
+		f.Complete = true
+		f.ExpiresAt = nil
+	}
+	return f.copy(), werr
+}
+
+func (s *fileService) GetFile(ctx context.Context, id string) (*File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return f.copy(), nil
+}
+
+func (s *fileService) Open(ctx context.Context, id string) (io.ReadSeekCloser, *File, error) {
+	f, err := s.GetFile(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.Complete {
+		return nil, f, ErrUploadIncomplete
+	}
+	rc, err := s.store.Open(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrFileNotFound
+	}
+	return rc, f, err
+}
+
+func (s *fileService) DeleteFile(ctx context.Context, id string) error {
+	s.mu.Lock()
+	_, ok := s.files[id]
+	delete(s.files, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrFileNotFound
+	}
+	return s.store.Delete(ctx, id)
+}
+
+func (s *fileService) ExpireUploads(ctx context.Context) (int, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	var expired []string
+	for id, f := range s.files {
+		if f.ExpiresAt != nil && !now.Before(*f.ExpiresAt) && !s.writing[id] {
+			expired = append(expired, id)
+			delete(s.files, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range expired {
+		if err := s.store.Delete(ctx, id); err != nil {
+			return len(expired), err
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *fileService) PurgeOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	s.mu.Lock()
+	var old []string
+	for id, f := range s.files {
+		if f.Complete && f.CreatedAt.Before(cutoff) {
+			old = append(old, id)
+			if !dryRun {
+				delete(s.files, id)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if dryRun {
+		return len(old), nil
+	}
+	for _, id := range old {
+		if err := s.store.Delete(ctx, id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return len(old), err
+		}
+	}
+	return len(old), nil
+}
+
+// lookup returns the live file record, removing it if the upload has expired.
+// Callers must hold s.mu.
+func (s *fileService) lookup(ctx context.Context, id string) (*File, error) {
+	f, ok := s.files[id]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	if f.ExpiresAt != nil && !s.now().Before(*f.ExpiresAt) && !s.writing[id] {
+		delete(s.files, id)
+		_ = s.store.Delete(ctx, id)
+		return nil, ErrUploadExpired
+	}
+	return f, nil
+}
+
+func (f *File) copy() *File {
+	c := *f
+	if f.Metadata != nil {
+		c.Metadata = make(map[string]string, len(f.Metadata))
+		for k, v := range f.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	if f.ExpiresAt != nil {
+		t := *f.ExpiresAt
+		c.ExpiresAt = &t
+	}
+	return &c
+}
+
+// newRandomID returns prefix followed by 96 random bits, for IDs that must not be guessable.
+func newRandomID(prefix string) (string, error) {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b[:]), nil
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/storage"
+)
+
+func TestFileService_ResumableUpload(t *testing.T) {
+	svc := NewFileService(storage.NewMemory(), 1024, time.Hour)
+	ctx := context.Background()
+
+	f, err := svc.CreateUpload(ctx, NewUpload{Size: 11, Name: "hello.txt"})
+	if err != nil {
+		t.Fatalf("CreateUpload returned error: %v", err)
+	}
+	if f.Offset != 0 || f.Complete || f.ExpiresAt == nil {
+		t.Fatalf("unexpected new upload state: %+v", f)
+	}
+	if _, _, err := svc.Open(ctx, f.ID); err != ErrUploadIncomplete {
+		t.Fatalf("expected ErrUploadIncomplete, got %v", err)
+	}
+
+	if f, err = svc.AppendChunk(ctx, f.ID, 0, strings.NewReader("hello ")); err != nil {
+		t.Fatalf("AppendChunk returned error: %v", err)
+	}
+	if f.Offset != 6 {
+		t.Fatalf("expected offset 6, got %d", f.Offset)
+	}
+	// A retried chunk at a stale offset must be refused
+	if _, err := svc.AppendChunk(ctx, f.ID, 0, strings.NewReader("hello ")); err != ErrOffsetMismatch {
+		t.Fatalf("expected ErrOffsetMismatch, got %v", err)
+	}
+	// Bytes beyond the declared length are ignored
+	if f, err = svc.AppendChunk(ctx, f.ID, 6, strings.NewReader("world and more")); err != nil {
+		t.Fatalf("AppendChunk returned error: %v", err)
+	}
+	if !f.Complete || f.Offset != 11 || f.ExpiresAt != nil {
+		t.Fatalf("expected completed upload, got %+v", f)
+	}
+
+	rc, _, err := svc.Open(ctx, f.ID)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if string(data) != "hello world" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+}
+
+func TestFileService_IDsAreRandom(t *testing.T) {
+	svc := NewFileService(storage.NewMemory(), 1024, time.Hour)
+	seen := map[string]bool{}
+	for range 3 {
+		f, err := svc.CreateUpload(context.Background(), NewUpload{Size: 1})
+		if err != nil {
+			t.Fatalf("CreateUpload returned error: %v", err)
+		}
+		if len(f.ID) != len("file_")+24 || !strings.HasPrefix(f.ID, "file_") || seen[f.ID] {
+			t.Fatalf("expected file_ and 96 random bits, got %q", f.ID)
+		}
+		if _, ok := ids.Time(f.ID); ok {
+			t.Fatalf("expected no creation time in file ID %q", f.ID)
+		}
+		seen[f.ID] = true
+	}
+}
+
+func TestFileService_PartialChunkAdvancesOffset(t *testing.T) {
+	svc := NewFileService(storage.NewMemory(), 1024, time.Hour)
+	ctx := context.Background()
+
+	f, _ := svc.CreateUpload(ctx, NewUpload{Size: 10})
+	r := io.MultiReader(strings.NewReader("abcd"), errReader{errors.New("connection reset")})
+	f, err := svc.AppendChunk(ctx, f.ID, 0, r)
+	if err == nil {
+		t.Fatalf("expected the read error to be returned")
+	}
+	if f.Offset != 4 {
+		t.Fatalf("expected offset 4 after partial chunk, got %d", f.Offset)
+	}
+}
+
+func TestFileService_ExpiresIncompleteUploads(t *testing.T) {
+	svc := NewFileService(storage.NewMemory(), 1024, time.Hour).(*fileService)
+	ctx := context.Background()
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	incomplete, _ := svc.CreateUpload(ctx, NewUpload{Size: 10})
+	done, _ := svc.CreateUpload(ctx, NewUpload{Size: 2})
+	if _, err := svc.AppendChunk(ctx, done.ID, 0, strings.NewReader("ok")); err != nil {
+		t.Fatalf("AppendChunk returned error: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := svc.GetFile(ctx, incomplete.ID); err != ErrUploadExpired {
+		t.Fatalf("expected ErrUploadExpired, got %v", err)
+	}
+	if _, err := svc.GetFile(ctx, done.ID); err != nil {
+		t.Fatalf("completed uploads must not expire: %v", err)
+	}
+
+	stale, _ := svc.CreateUpload(ctx, NewUpload{Size: 10})
+	now = now.Add(2 * time.Hour)
+	if n, err := svc.ExpireUploads(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 expired upload, got %d (%v)", n, err)
+	}
+	if _, err := svc.GetFile(ctx, stale.ID); err != ErrFileNotFound {
+		t.Fatalf("expected ErrFileNotFound after expiry, got %v", err)
+	}
+}
+
+func TestFileService_PurgeOlderThan(t *testing.T) {
+	svc := NewFileService(storage.NewMemory(), 1024, time.Hour).(*fileService)
+	ctx := context.Background()
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	old, _ := svc.SaveFile(ctx, NewUpload{Size: 2}, strings.NewReader("ok"))
+	now = now.Add(48 * time.Hour)
+	recent, _ := svc.SaveFile(ctx, NewUpload{Size: 2}, strings.NewReader("ok"))
+	cutoff := now.Add(-24 * time.Hour)
+
+	if n, err := svc.PurgeOlderThan(ctx, cutoff, true); err != nil || n != 1 {
+		t.Fatalf("dry run: expected 1, got %d (%v)", n, err)
+	}
+	if _, err := svc.GetFile(ctx, old.ID); err != nil {
+		t.Fatalf("dry run must not delete: %v", err)
+	}
+	if n, err := svc.PurgeOlderThan(ctx, cutoff, false); err != nil || n != 1 {
+		t.Fatalf("expected 1 purged file, got %d (%v)", n, err)
+	}
+	if _, err := svc.GetFile(ctx, old.ID); err != ErrFileNotFound {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+	if _, err := svc.GetFile(ctx, recent.ID); err != nil {
+		t.Fatalf("recent file must be kept: %v", err)
+	}
+}
+
+func TestFileService_RejectsOversizedUploads(t *testing.T) {
+	svc := NewFileService(storage.NewMemory(), 10, time.Hour)
+	if _, err := svc.CreateUpload(context.Background(), NewUpload{Size: 11}); err != ErrUploadTooLarge {
+		t.Fatalf("expected ErrUploadTooLarge, got %v", err)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"io"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/metering"
+)
+
+// NewMeteredFileService emits a metering.BytesStored event when a file is
+// complete. The file ID is the idempotency key, so a retried final chunk is
+// not billed twice.
+func NewMeteredFileService(inner FileService, bus *events.Bus) FileService {
+	return &meteredFileService{FileService: inner, bus: bus}
+}
+
+type meteredFileService struct {
+	FileService
+	bus *events.Bus
+}
+
+func (s *meteredFileService) SaveFile(ctx context.Context, upload NewUpload, r io.Reader) (*File, error) {
+	f, err := s.FileService.SaveFile(ctx, upload, r)
+	if err == nil {
+		s.stored(ctx, f)
+	}
+	return f, err
+}
+
+func (s *meteredFileService) AppendChunk(ctx context.Context, id string, offset int64, r io.Reader) (*File, error) {
+	f, err := s.FileService.AppendChunk(ctx, id, offset, r)
+	if err == nil && f.Complete {
+		s.stored(ctx, f)
+	}
+	return f, err
+}
+
+func (s *meteredFileService) stored(ctx context.Context, f *File) {
+	if f.Size == 0 {
+		return
+	}
+	metering.Emit(ctx, s.bus, metering.Event{
+		ID:       "file:" + f.ID,
+		Type:     metering.BytesStored,
+		Key:      metering.Consumer(ctx),
+		Quantity: f.Size,
+	})
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+)
+
+// The in-memory repositories keep each tenant's records apart, a reference
+// for multi-tenant stores: a request reads and writes only the partition of
+// its principal's tenant. Within a partition records are spread over
+// stripeCount maps, each with its own lock, so writes to different records
+// rarely wait for each other.
+
+// stripeCount is the number of stripes of a striped map.
+const stripeCount = 16
+
+// PartitionOf returns the tenant whose records a request sees: its
+// principal's tenant, or "" (the shared partition, where seeded records live)
+// for principals without one and anonymous requests. Repositories outside
+// this package use it to partition their stores the same way.
+func PartitionOf(ctx context.Context) string {
+	p, _ := requestctx.Principal(ctx)
+	return p.Tenant
+}
+
+// tenants holds a partition of type P per tenant, created on first write.
+type tenants[P any] struct {
+	newPartition func() *P
+
+	mu         sync.RWMutex
+	partitions map[string]*P
+}
+
+func newTenants[P any](newPartition func() *P) *tenants[P] {
+	return &tenants[P]{newPartition: newPartition, partitions: map[string]*P{}}
+}
+
+// get returns the partition of ctx's tenant; without one yet it returns nil,
+// or a new partition when create is set.
+func (t *tenants[P]) get(ctx context.Context, create bool) *P {
+	tenant := PartitionOf(ctx)
+	t.mu.RLock()
+	p := t.partitions[tenant]
+	t.mu.RUnlock()
+	if p != nil || !create {
+		return p
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p = t.partitions[tenant]; p == nil {
+		p = t.newPartition()
+		t.partitions[tenant] = p
+	}
+	return p
+}
+
+// all returns every partition by tenant.
+func (t *tenants[P]) all() map[string]*P {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	all := make(map[string]*P, len(t.partitions))
+	for tenant, p := range t.partitions {
+		all[tenant] = p
+	}
+	return all
+}
+
+// replace swaps every partition for those of partitions.
+func (t *tenants[P]) replace(partitions map[string]*P) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partitions = partitions
+}
+
+// striped is a map spread over stripes by key.
+type striped[V any] struct {
+	seed    maphash.Seed
+	stripes [stripeCount]stripe[V]
+}
+
+// stripe is one map of a striped map and the lock guarding it.
+type stripe[V any] struct {
+	sync.RWMutex
+	m map[string]V
+}
+
+func newStriped[V any]() *striped[V] {
+	s := &striped[V]{seed: maphash.MakeSeed()}
+	for i := range s.stripes {
+		s.stripes[i].m = map[string]V{}
+	}
+	return s
+}
+
+func (s *striped[V]) index(key string) int {
+	return int(maphash.String(s.seed, key) % stripeCount)
+}
+
+// of returns the stripe of key.
+func (s *striped[V]) of(key string) *stripe[V] {
+	return &s.stripes[s.index(key)]
+}
+
+// lock locks the stripes of keys in stripe order, so callers locking several
+// cannot deadlock, and returns the function unlocking them.
+func (s *striped[V]) lock(keys ...string) (unlock func()) {
+	var held [stripeCount]bool
+	for _, k := range keys {
+		held[s.index(k)] = true
+	}
+	for i := range s.stripes {
+		if held[i] {
+			s.stripes[i].Lock()
+		}
+	}
+	return func() {
+		for i := range s.stripes {
+			if held[i] {
+				s.stripes[i].Unlock()
+			}
+		}
+	}
+}
+
+// values returns every value, reading one stripe at a time; writes made
+// meanwhile to stripes already read are not included.
+func (s *striped[V]) values() []V {
+	var values []V
+	for i := range s.stripes {
+		st := &s.stripes[i]
+		st.RLock()
+		for _, v := range st.m {
+			values = append(values, v)
+		}
+		st.RUnlock()
+	}
+	return values
+}
+
+func (s *striped[V]) len() int {
+	n := 0
+	for i := range s.stripes {
+		st := &s.stripes[i]
+		st.RLock()
+		n += len(st.m)
+		st.RUnlock()
+	}
+	return n
+}
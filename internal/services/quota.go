@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/quota"
+)
+
+// NewQuotaUserService charges users created by a consumer (see quota.WithKey)
+// against its users quota, returning a *quota.ExceededError when it is full.
+func NewQuotaUserService(inner UserService, quotas *quota.Manager) UserService {
+	return &quotaUserService{UserService: inner, quotas: quotas}
+}
+
+type quotaUserService struct {
+	UserService
+	quotas *quota.Manager
+}
+
+func (s *quotaUserService) CreateUser(ctx context.Context, email, name string) (*User, error) {
+	key := quota.KeyFromContext(ctx)
+	if key == "" {
+		return s.UserService.CreateUser(ctx, email, name)
+	}
+	// Reserve before creating so concurrent signups cannot overshoot the quota
+	token, err := newRandomID("pending_")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.quotas.Allocate(key, quota.Users, token, 1); err != nil {
+		return nil, err
+	}
+	user, err := s.UserService.CreateUser(ctx, email, name)
+	if err != nil {
+		s.quotas.Release(quota.Users, token)
+		return nil, err
+	}
+	s.quotas.Rebind(quota.Users, token, user.ID)
+	return user, nil
+}
+
+func (s *quotaUserService) DeleteUser(ctx context.Context, id string) error {
+	if err := s.UserService.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	s.quotas.Release(quota.Users, id)
+	return nil
+}
+
+// NewQuotaFileService charges the declared size of files created by a
+// consumer against its storage quota. Allocations of files that no longer
+// exist (deleted, expired or lost in a restart) are released.
+func NewQuotaFileService(inner FileService, quotas *quota.Manager) FileService {
+	s := &quotaFileService{FileService: inner, quotas: quotas}
+	s.reconcile(context.Background())
+	return s
+}
+
+type quotaFileService struct {
+	FileService
+	quotas *quota.Manager
+}
+
+func (s *quotaFileService) CreateUpload(ctx context.Context, upload NewUpload) (*File, error) {
+	return s.charge(ctx, upload.Size, func() (*File, error) {
+		return s.FileService.CreateUpload(ctx, upload)
+	})
+}
+
+func (s *quotaFileService) SaveFile(ctx context.Context, upload NewUpload, r io.Reader) (*File, error) {
+	return s.charge(ctx, upload.Size, func() (*File, error) {
+		return s.FileService.SaveFile(ctx, upload, r)
+	})
+}
+
+func (s *quotaFileService) DeleteFile(ctx context.Context, id string) error {
+	err := s.FileService.DeleteFile(ctx, id)
+	if err == nil || errors.Is(err, ErrFileNotFound) || errors.Is(err, ErrUploadExpired) {
+		s.quotas.Release(quota.StorageBytes, id)
+	}
+	return err
+}
+
+func (s *quotaFileService) ExpireUploads(ctx context.Context) (int, error) {
+	n, err := s.FileService.ExpireUploads(ctx)
+	s.reconcile(ctx)
+	return n, err
+}
+
+func (s *quotaFileService) PurgeOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	n, err := s.FileService.PurgeOlderThan(ctx, cutoff, dryRun)
+	if !dryRun {
+		s.reconcile(ctx)
+	}
+	return n, err
+}
+
+// charge reserves size bytes for the consumer in ctx around create.
+func (s *quotaFileService) charge(ctx context.Context, size int64, create func() (*File, error)) (*File, error) {
+	key := quota.KeyFromContext(ctx)
+	if key == "" {
+		return create()
+	}
+	token, err := newRandomID("pending_")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.quotas.Allocate(key, quota.StorageBytes, token, size); err != nil {
+		// Expired uploads may still be holding space; free it and retry once
+		if !errors.Is(err, quota.ErrExceeded) || s.reconcile(ctx) == 0 {
+			return nil, err
+		}
+		if err := s.quotas.Allocate(key, quota.StorageBytes, token, size); err != nil {
+			return nil, err
+		}
+	}
+	f, err := create()
+	if err != nil {
+		s.quotas.Release(quota.StorageBytes, token)
+		return nil, err
+	}
+	s.quotas.Rebind(quota.StorageBytes, token, f.ID)
+	return f, nil
+}
+
+// reconcile releases allocations of files that no longer exist and returns how many were released.
+func (s *quotaFileService) reconcile(ctx context.Context) int {
+	released := 0
+	for _, id := range s.quotas.Allocated(quota.StorageBytes) {
+		if strings.HasPrefix(id, "pending_") {
+			continue // upload being created right now
+		}
+		_, err := s.FileService.GetFile(ctx, id)
+		if errors.Is(err, ErrFileNotFound) || errors.Is(err, ErrUploadExpired) {
+			s.quotas.Release(quota.StorageBytes, id)
+			released++
+		}
+	}
+	return released
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/reports"
+)
+
+var ErrReportNotFound = errors.New("report not found")
+
+// Report statuses
+const (
+	ReportPending   = "pending"
+	ReportRunning   = "running"
+	ReportCompleted = "completed"
+	ReportFailed    = "failed"
+)
+
+// Report tracks an asynchronously generated document. Once completed the
+// artifact is available as a stored file. Its generation is the operation
+// OperationID, whose result is the completed report.
+type Report struct {
+	ID          string     `json:"id"`
+	OperationID string     `json:"operation_id,omitempty"`
+	Type        string     `json:"type"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	FileID      string     `json:"file_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type ReportService interface {
+	// CreateReport records a pending report and queues its generation.
+	CreateReport(ctx context.Context, reportType, format string) (*Report, error)
+	GetReport(ctx context.Context, id string) (*Report, error)
+}
+
+type reportService struct {
+	mu      sync.RWMutex
+	reports map[string]*Report
+	users   UserService
+	stats   StatsService
+	files   FileService
+	ops     *operations.Store
+}
+
+func NewReportService(users UserService, stats StatsService, files FileService, ops *operations.Store) ReportService {
+	return &reportService{
+		reports: make(map[string]*Report),
+		users:   users,
+		stats:   stats,
+		files:   files,
+		ops:     ops,
+	}
+}
+
+func (s *reportService) CreateReport(ctx context.Context, reportType, format string) (*Report, error) {
+	if _, ok := reports.ContentTypes[format]; !ok {
+		return nil, fmt.Errorf("unsupported report format %q", format)
+	}
+	if reportType != "users" && reportType != "stats" {
+		return nil, fmt.Errorf("unsupported report type %q", reportType)
+	}
+	id, err := ids.Default.New(ids.Report)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{ID: id, Type: reportType, Format: format, Status: ReportPending, CreatedAt: time.Now()}
+
+	s.mu.Lock()
+	s.reports[id] = report
+	s.mu.Unlock()
+
+	op, err := s.ops.Start(ctx, "reports.generate", func(ctx context.Context, _ *operations.Tracker) (any, error) {
+		if err := s.generate(ctx, id); err != nil {
+			return nil, err
+		}
+		return s.GetReport(ctx, id)
+	})
+	if err != nil {
+		s.mu.Lock()
+		delete(s.reports, id)
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report.OperationID = op.ID
+	reportCopy := *report
+	return &reportCopy, nil
+}
+
+func (s *reportService) GetReport(ctx context.Context, id string) (*Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report, ok := s.reports[id]
+	if !ok {
+		return nil, ErrReportNotFound
+	}
+	reportCopy := *report
+	return &reportCopy, nil
+}
+
+func (s *reportService) generate(ctx context.Context, id string) error {
+	s.mu.Lock()
+	report := s.reports[id]
+	report.Status = ReportRunning
+	reportType, format := report.Type, report.Format
+	s.mu.Unlock()
+
+	fileID, err := s.render(ctx, reportType, format)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	report.CompletedAt = &now
+	if err != nil {
+		report.Status = ReportFailed
+		report.Error = "report generation failed"
+		return err
+	}
+	report.Status = ReportCompleted
+	report.FileID = fileID
+	return nil
+}
+
+func (s *reportService) render(ctx context.Context, reportType, format string) (string, error) {
+	var table reports.Table
+	switch reportType {
+	case "users":
+		users, err := s.users.GetAllUsers(ctx)
+		if err != nil {
+			return "", err
+		}
+		sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+		table = reports.Table{Title: "Users", Columns: []string{"id", "email", "name", "role", "created_at"}}
+		for _, u := range users {
+			table.Rows = append(table.Rows, []string{u.ID, u.Email, u.Name, u.Role, u.CreatedAt.UTC().Format(time.RFC3339)})
+		}
+	case "stats":
+		sys, err := s.stats.GetSystemStats(ctx)
+		if err != nil {
+			return "", err
+		}
+		api, err := s.stats.GetAPIStats(ctx)
+		if err != nil {
+			return "", err
+		}
+		table = reports.Table{Title: "Stats", Columns: []string{"metric", "value"}, Rows: [][]string{
+			{"uptime_seconds", strconv.FormatInt(int64(sys.Uptime.Seconds()), 10)},
+			{"memory_usage_mb", strconv.FormatUint(sys.MemoryUsage, 10)},
+			{"goroutines", strconv.Itoa(sys.NumGoroutine)},
+			{"cpus", strconv.Itoa(sys.NumCPU)},
+			{"go_version", sys.GoVersion},
+		}}
+		keys := make([]string, 0, len(api))
+		for k := range api {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			table.Rows = append(table.Rows, []string{k, fmt.Sprint(api[k])})
+		}
+	}
+	table.Title += " report generated " + time.Now().UTC().Format(time.RFC3339)
+
+	var buf bytes.Buffer
+	if err := reports.Render(&buf, table, format); err != nil {
+		return "", err
+	}
+	file, err := s.files.SaveFile(ctx, NewUpload{
+		Size:        int64(buf.Len()),
+		Name:        reportType + "-report." + format,
+		ContentType: reports.ContentTypes[format],
+	}, &buf)
+	if err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
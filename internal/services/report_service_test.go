@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/operations"
+	"github.com/mikko-kohtala/go-api/internal/storage"
+)
+
+func waitForReport(t *testing.T, svc ReportService, id string) *Report {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		report, err := svc.GetReport(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetReport returned error: %v", err)
+		}
+		if report.Status == ReportCompleted || report.Status == ReportFailed {
+			return report
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("report %s did not finish", id)
+	return nil
+}
+
+func TestReportService_GeneratesUsersCSV(t *testing.T) {
+	files := NewFileService(storage.NewMemory(), 1<<20, time.Hour)
+	pool := jobs.NewPool(1, 4)
+	defer pool.Shutdown(context.Background())
+	ops := operations.NewStore(pool)
+	svc := NewReportService(NewUserService(), NewStatsService(), files, ops)
+
+	report, err := svc.CreateReport(context.Background(), "users", "csv")
+	if err != nil {
+		t.Fatalf("CreateReport returned error: %v", err)
+	}
+	if report.Status != ReportPending {
+		t.Fatalf("expected pending report, got %s", report.Status)
+	}
+
+	report = waitForReport(t, svc, report.ID)
+	if report.Status != ReportCompleted || report.FileID == "" {
+		t.Fatalf("expected completed report with file, got %+v", report)
+	}
+	op, err := ops.Get(report.OperationID)
+	for deadline := time.Now().Add(time.Second); err == nil && !op.Done() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		op, err = ops.Get(report.OperationID)
+	}
+	if err != nil || op.Status != operations.Completed || op.Result.(*Report).FileID != report.FileID {
+		t.Fatalf("expected the report's operation to complete with it, got %+v %v", op, err)
+	}
+	rc, file, err := files.Open(context.Background(), report.FileID)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if file.ContentType != "text/csv" || !strings.HasPrefix(string(data), "id,email,name,role,created_at\nusr_001,john.doe@example.com") {
+		t.Fatalf("unexpected report artifact %s:\n%s", file.ContentType, data)
+	}
+}
+
+func TestReportService_RejectsUnknownTypes(t *testing.T) {
+	svc := NewReportService(NewUserService(), NewStatsService(), nil, operations.NewStore(jobs.NewPool(1, 1)))
+	if _, err := svc.CreateReport(context.Background(), "invoices", "csv"); err == nil {
+		t.Fatalf("expected error for unknown report type")
+	}
+	if _, err := svc.GetReport(context.Background(), "rpt_missing"); err != ErrReportNotFound {
+		t.Fatalf("expected ErrReportNotFound, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
 	"time"
 )
 
@@ -14,9 +17,47 @@ type SystemStats struct {
 	GoVersion    string        `json:"go_version"`
 }
 
+// MemStatsReport is a full snapshot of the runtime's memory statistics with
+// the recent GC pause history, for live memory investigations.
+type MemStatsReport struct {
+	MemStats       runtime.MemStats `json:"mem_stats"`
+	Goroutines     int              `json:"goroutines"`
+	MemoryLimit    int64            `json:"memory_limit_bytes"` // GOMEMLIMIT; math.MaxInt64 when unset
+	GCPauses       []GCPause        `json:"gc_pauses"`          // most recent first
+	PauseQuantiles []time.Duration  `json:"pause_quantiles_ns"` // min, 25th, 50th, 75th percentile and max
+}
+
+// GCPause is one stop-the-world pause of the garbage collector.
+type GCPause struct {
+	EndedAt  time.Time     `json:"ended_at"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// GCResult reports a forced garbage collection.
+type GCResult struct {
+	Duration        time.Duration `json:"duration_ns"`
+	FreedOSMemory   bool          `json:"freed_os_memory"`
+	HeapAllocBefore uint64        `json:"heap_alloc_before_bytes"`
+	HeapAllocAfter  uint64        `json:"heap_alloc_after_bytes"`
+	HeapReleased    uint64        `json:"heap_released_bytes"`
+	NumGC           uint32        `json:"num_gc"`
+}
+
+// maxGCPauses bounds the pause history in a MemStatsReport; the runtime keeps
+// the last 256.
+const maxGCPauses = 256
+
 type StatsService interface {
 	GetSystemStats(ctx context.Context) (*SystemStats, error)
 	GetAPIStats(ctx context.Context) (map[string]interface{}, error)
+	// GetMemStats returns the full runtime memory statistics.
+	GetMemStats(ctx context.Context) (*MemStatsReport, error)
+	// RunGC forces a garbage collection, returning as much memory to the OS
+	// as possible when freeOSMemory is set.
+	RunGC(ctx context.Context, freeOSMemory bool) (*GCResult, error)
+	// GoroutineDump returns the stacks of all goroutines in the format of an
+	// unrecovered panic.
+	GoroutineDump(ctx context.Context) ([]byte, error)
 }
 
 type statsService struct {
@@ -59,3 +100,53 @@ func (s *statsService) GetAPIStats(ctx context.Context) (map[string]interface{},
 		"active_connections": activeConnections,
 	}, nil
 }
+
+func (s *statsService) GetMemStats(ctx context.Context) (*MemStatsReport, error) {
+	report := &MemStatsReport{
+		Goroutines:  runtime.NumGoroutine(),
+		MemoryLimit: debug.SetMemoryLimit(-1), // a negative limit only reads it
+	}
+	runtime.ReadMemStats(&report.MemStats)
+
+	gc := debug.GCStats{PauseQuantiles: make([]time.Duration, 5)}
+	debug.ReadGCStats(&gc)
+	report.PauseQuantiles = gc.PauseQuantiles
+	if gc.NumGC == 0 {
+		report.PauseQuantiles = []time.Duration{}
+	}
+	n := min(len(gc.Pause), len(gc.PauseEnd), maxGCPauses)
+	report.GCPauses = make([]GCPause, n)
+	for i := 0; i < n; i++ {
+		report.GCPauses[i] = GCPause{EndedAt: gc.PauseEnd[i].UTC(), Duration: gc.Pause[i]}
+	}
+	return report, nil
+}
+
+func (s *statsService) RunGC(ctx context.Context, freeOSMemory bool) (*GCResult, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	if freeOSMemory {
+		debug.FreeOSMemory() // runs a GC first
+	} else {
+		runtime.GC()
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return &GCResult{
+		Duration:        elapsed,
+		FreedOSMemory:   freeOSMemory,
+		HeapAllocBefore: before.HeapAlloc,
+		HeapAllocAfter:  after.HeapAlloc,
+		HeapReleased:    after.HeapReleased,
+		NumGC:           after.NumGC,
+	}, nil
+}
+
+func (s *statsService) GoroutineDump(ctx context.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NewCachedTaskRepository serves Get from an in-process cache for up to ttl,
+// in front of a repository that is slow or remote. Writes through this
+// repository invalidate the cached task; writes made elsewhere (another
+// instance) are visible once the entry expires, or once that instance's
+// invalidation reaches Invalidate.
+func NewCachedTaskRepository(inner TaskRepository, ttl time.Duration) *CachedTaskRepository {
+	return &CachedTaskRepository{TaskRepository: inner, ttl: ttl, now: time.Now, entries: make(map[string]cachedTask)}
+}
+
+type cachedTask struct {
+	tenant  string // see PartitionOf
+	task    Task
+	expires time.Time
+}
+
+// CachedTaskRepository is a TaskRepository with a read cache.
+type CachedTaskRepository struct {
+	TaskRepository
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedTask
+}
+
+func (c *CachedTaskRepository) Get(ctx context.Context, id string) (*Task, error) {
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	c.mu.Unlock()
+	tenant := PartitionOf(ctx)
+	if ok && e.tenant == tenant && c.now().Before(e.expires) {
+		t := e.task
+		return &t, nil
+	}
+
+	task, err := c.TaskRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[id] = cachedTask{tenant: tenant, task: *task, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return task, nil
+}
+
+func (c *CachedTaskRepository) Save(ctx context.Context, task *Task) error {
+	err := c.TaskRepository.Save(ctx, task)
+	c.Invalidate(task.ID)
+	return err
+}
+
+func (c *CachedTaskRepository) Delete(ctx context.Context, id string) error {
+	err := c.TaskRepository.Delete(ctx, id)
+	c.Invalidate(id)
+	return err
+}
+
+// Invalidate drops the cached tasks with ids, or every cached task when no
+// ids are given.
+func (c *CachedTaskRepository) Invalidate(ids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(ids) == 0 {
+		clear(c.entries)
+		return
+	}
+	for _, id := range ids {
+		delete(c.entries, id)
+	}
+}
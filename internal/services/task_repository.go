@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// TaskRepository stores tasks. It has no business rules; TaskService
+// validates transitions and publishes events.
+type TaskRepository interface {
+	// List returns up to limit tasks from offset, oldest first, and the total.
+	List(ctx context.Context, limit, offset int) ([]Task, int, error)
+	Get(ctx context.Context, id string) (*Task, error)
+	Insert(ctx context.Context, task *Task) error
+	Save(ctx context.Context, task *Task) error
+	Delete(ctx context.Context, id string) error
+}
+
+// NewMemoryTaskRepository creates an in-memory TaskRepository keeping tasks
+// per tenant (see PartitionOf).
+func NewMemoryTaskRepository() TaskRepository {
+	return &memoryTaskRepository{tenants: newTenants(newStriped[*Task])}
+}
+
+type memoryTaskRepository struct {
+	tenants *tenants[striped[*Task]]
+}
+
+func (m *memoryTaskRepository) List(ctx context.Context, limit, offset int) ([]Task, int, error) {
+	var all []Task
+	if p := m.tenants.get(ctx, false); p != nil {
+		for _, t := range p.values() {
+			all = append(all, *t)
+		}
+	}
+
+	slices.SortFunc(all, compareTasks)
+	if offset >= len(all) {
+		return []Task{}, len(all), nil
+	}
+	return all[offset:min(offset+limit, len(all))], len(all), nil
+}
+
+// compareTasks orders tasks oldest first.
+func compareTasks(a, b Task) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+func (m *memoryTaskRepository) Get(ctx context.Context, id string) (*Task, error) {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return nil, ErrTaskNotFound
+	}
+	st := p.of(id)
+	st.RLock()
+	defer st.RUnlock()
+	t, ok := st.m[id]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (m *memoryTaskRepository) Insert(ctx context.Context, task *Task) error {
+	st := m.tenants.get(ctx, true).of(task.ID)
+	st.Lock()
+	defer st.Unlock()
+	cp := *task
+	st.m[task.ID] = &cp
+	return nil
+}
+
+func (m *memoryTaskRepository) Save(ctx context.Context, task *Task) error {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return ErrTaskNotFound
+	}
+	st := p.of(task.ID)
+	st.Lock()
+	defer st.Unlock()
+	if _, ok := st.m[task.ID]; !ok {
+		return ErrTaskNotFound
+	}
+	cp := *task
+	st.m[task.ID] = &cp
+	return nil
+}
+
+func (m *memoryTaskRepository) Delete(ctx context.Context, id string) error {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return ErrTaskNotFound
+	}
+	st := p.of(id)
+	st.Lock()
+	defer st.Unlock()
+	if _, ok := st.m[id]; !ok {
+		return ErrTaskNotFound
+	}
+	delete(st.m, id)
+	return nil
+}
+
+// partitionTask is a task in a snapshot, with its tenant.
+type partitionTask struct {
+	Tenant string `json:"tenant,omitempty"`
+	Task
+}
+
+// Snapshot returns every task with its tenant, oldest first, for
+// snapshot.Store.
+func (m *memoryTaskRepository) Snapshot() (any, error) {
+	tasks := []partitionTask{}
+	for tenant, p := range m.tenants.all() {
+		for _, t := range p.values() {
+			tasks = append(tasks, partitionTask{Tenant: tenant, Task: *t})
+		}
+	}
+	slices.SortFunc(tasks, func(a, b partitionTask) int { return compareTasks(a.Task, b.Task) })
+	return tasks, nil
+}
+
+// Restore decodes tasks written by Snapshot and returns a function replacing
+// every task with them, for snapshot.Store. Tasks without a tenant go to the
+// shared partition.
+func (m *memoryTaskRepository) Restore(data json.RawMessage) (func(), error) {
+	var list []partitionTask
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	partitions := map[string]*striped[*Task]{}
+	for i := range list {
+		t := &list[i].Task
+		if t.ID == "" {
+			return nil, errors.New("task without an ID")
+		}
+		p := partitions[list[i].Tenant]
+		if p == nil {
+			p = newStriped[*Task]()
+			partitions[list[i].Tenant] = p
+		}
+		p.of(t.ID).m[t.ID] = t
+	}
+	return func() { m.tenants.replace(partitions) }, nil
+}
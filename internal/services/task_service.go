@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+	"github.com/mikko-kohtala/go-api/internal/ids"
+)
+
+var (
+	ErrTaskNotFound    = errors.New("task not found")
+	ErrTaskAlreadyDone = errors.New("task already done")
+)
+
+// Task statuses.
+const (
+	TaskOpen = "open"
+	TaskDone = "done"
+)
+
+type Task struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ResourceID identifies the task for the resource package.
+func (t Task) ResourceID() string { return t.ID }
+
+// NewTask holds the fields of a task to create.
+type NewTask struct {
+	Title       string
+	Description string
+	DueAt       *time.Time
+}
+
+// TaskChanges holds the fields of a task to update; nil fields are unchanged.
+type TaskChanges struct {
+	Title       *string
+	Description *string
+	DueAt       *time.Time
+}
+
+// TaskTopic is the event bus topic TaskEvents are published on.
+const TaskTopic = "tasks"
+
+// Task event types.
+const (
+	TaskCreated   = "task.created"
+	TaskUpdated   = "task.updated"
+	TaskCompleted = "task.completed"
+	TaskDeleted   = "task.deleted"
+)
+
+// TaskEvent is published on TaskTopic after a task changes. Task is the
+// state after the change, or before it for TaskDeleted.
+type TaskEvent struct {
+	Type string
+	Task Task
+}
+
+type TaskService interface {
+	ListTasks(ctx context.Context, limit, offset int) ([]Task, int, error)
+	GetTask(ctx context.Context, id string) (*Task, error)
+	CreateTask(ctx context.Context, t NewTask) (*Task, error)
+	UpdateTask(ctx context.Context, id string, c TaskChanges) (*Task, error)
+	CompleteTask(ctx context.Context, id string) (*Task, error)
+	DeleteTask(ctx context.Context, id string) error
+	// LastModified returns when the task collection last changed, and false
+	// when that is unknown.
+	LastModified(ctx context.Context) (time.Time, bool)
+}
+
+// NewTaskService creates a TaskService over repo that publishes a TaskEvent
+// on bus for every change. bus may be nil.
+func NewTaskService(repo TaskRepository, bus *events.Bus) TaskService {
+	return &taskService{repo: repo, bus: bus, now: time.Now, changes: NewChangeClock()}
+}
+
+type taskService struct {
+	repo    TaskRepository
+	bus     *events.Bus
+	now     func() time.Time
+	changes *ChangeClock
+}
+
+func (s *taskService) ListTasks(ctx context.Context, limit, offset int) ([]Task, int, error) {
+	return s.repo.List(ctx, limit, offset)
+}
+
+func (s *taskService) GetTask(ctx context.Context, id string) (*Task, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *taskService) CreateTask(ctx context.Context, t NewTask) (*Task, error) {
+	id, err := ids.Default.New(ids.Task)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	task := &Task{
+		ID:          id,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      TaskOpen,
+		DueAt:       t.DueAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Insert(ctx, task); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, TaskCreated, task)
+	return task, nil
+}
+
+func (s *taskService) UpdateTask(ctx context.Context, id string, c TaskChanges) (*Task, error) {
+	task, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Title != nil {
+		task.Title = *c.Title
+	}
+	if c.Description != nil {
+		task.Description = *c.Description
+	}
+	if c.DueAt != nil {
+		task.DueAt = c.DueAt
+	}
+	task.UpdatedAt = s.now()
+	if err := s.repo.Save(ctx, task); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, TaskUpdated, task)
+	return task, nil
+}
+
+func (s *taskService) CompleteTask(ctx context.Context, id string) (*Task, error) {
+	task, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == TaskDone {
+		return nil, ErrTaskAlreadyDone
+	}
+	now := s.now()
+	task.Status = TaskDone
+	task.CompletedAt = &now
+	task.UpdatedAt = now
+	if err := s.repo.Save(ctx, task); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, TaskCompleted, task)
+	return task, nil
+}
+
+func (s *taskService) DeleteTask(ctx context.Context, id string) error {
+	task, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.publish(ctx, TaskDeleted, task)
+	return nil
+}
+
+func (s *taskService) LastModified(context.Context) (time.Time, bool) {
+	return s.changes.LastModified(), true
+}
+
+// publish touches the change clock and publishes the event of a change.
+func (s *taskService) publish(ctx context.Context, eventType string, task *Task) {
+	s.changes.Touch(time.Now())
+	if s.bus != nil {
+		s.bus.Publish(ctx, TaskTopic, TaskEvent{Type: eventType, Task: *task})
+	}
+}
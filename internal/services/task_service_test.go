@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/events"
+)
+
+func TestTaskService_LifecyclePublishesEvents(t *testing.T) {
+	bus := events.NewBus()
+	var got []string
+	bus.Subscribe(TaskTopic, func(_ context.Context, p any) { got = append(got, p.(TaskEvent).Type) })
+	svc := NewTaskService(NewMemoryTaskRepository(), bus)
+	ctx := context.Background()
+
+	task, err := svc.CreateTask(ctx, NewTask{Title: "Write docs"})
+	if err != nil {
+		t.Fatalf("CreateTask returned error: %v", err)
+	}
+	if task.Status != TaskOpen {
+		t.Fatalf("expected new task to be open, got %s", task.Status)
+	}
+	title := "Write better docs"
+	if task, err = svc.UpdateTask(ctx, task.ID, TaskChanges{Title: &title}); err != nil || task.Title != title {
+		t.Fatalf("UpdateTask: %v %+v", err, task)
+	}
+	if task, err = svc.CompleteTask(ctx, task.ID); err != nil || task.Status != TaskDone || task.CompletedAt == nil {
+		t.Fatalf("CompleteTask: %v %+v", err, task)
+	}
+	if _, err := svc.CompleteTask(ctx, task.ID); !errors.Is(err, ErrTaskAlreadyDone) {
+		t.Fatalf("expected ErrTaskAlreadyDone, got %v", err)
+	}
+	if err := svc.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("DeleteTask returned error: %v", err)
+	}
+	if _, err := svc.GetTask(ctx, task.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+
+	want := []string{TaskCreated, TaskUpdated, TaskCompleted, TaskDeleted}
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, got)
+		}
+	}
+}
+
+func TestMemoryTaskRepository_ListPaginatesOldestFirst(t *testing.T) {
+	repo := NewMemoryTaskRepository()
+	ctx := context.Background()
+	base := time.Now()
+	for i, id := range []string{"c", "a", "b"} {
+		_ = repo.Insert(ctx, &Task{ID: id, CreatedAt: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	page, total, err := repo.List(ctx, 2, 1)
+	if err != nil || total != 3 || len(page) != 2 || page[0].ID != "a" || page[1].ID != "b" {
+		t.Fatalf("unexpected page: %v total=%d err=%v", page, total, err)
+	}
+}
+
+func TestCachedTaskRepository_ServesReadsAndInvalidatesOnWrite(t *testing.T) {
+	inner := NewMemoryTaskRepository()
+	ctx := context.Background()
+	_ = inner.Insert(ctx, &Task{ID: "t1", Title: "cached"})
+	repo := NewCachedTaskRepository(inner, time.Minute)
+	now := time.Now()
+	repo.now = func() time.Time { return now }
+
+	if _, err := repo.Get(ctx, "t1"); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	// A write that bypasses the cache is not seen until the entry expires
+	_ = inner.Save(ctx, &Task{ID: "t1", Title: "changed elsewhere"})
+	if task, _ := repo.Get(ctx, "t1"); task.Title != "cached" {
+		t.Fatalf("expected cached title, got %q", task.Title)
+	}
+	now = now.Add(2 * time.Minute)
+	if task, _ := repo.Get(ctx, "t1"); task.Title != "changed elsewhere" {
+		t.Fatalf("expected expired entry to be reloaded, got %q", task.Title)
+	}
+
+	// Writes through the cache are visible immediately
+	_ = repo.Save(ctx, &Task{ID: "t1", Title: "saved"})
+	if task, _ := repo.Get(ctx, "t1"); task.Title != "saved" {
+		t.Fatalf("expected saved title, got %q", task.Title)
+	}
+	_ = repo.Delete(ctx, "t1")
+	if _, err := repo.Get(ctx, "t1"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound after delete, got %v", err)
+	}
+}
+
+func TestCachedTaskRepository_KeepsTenantsApart(t *testing.T) {
+	repo := NewCachedTaskRepository(NewMemoryTaskRepository(), time.Minute)
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+	_ = repo.Insert(acme, &Task{ID: "t1", Title: "acme's"})
+
+	if _, err := repo.Get(acme, "t1"); err != nil {
+		t.Fatalf("Get in acme: %v", err)
+	}
+	if _, err := repo.Get(globex, "t1"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected the cached task to stay invisible to globex, got %v", err)
+	}
+	if _, total, _ := repo.List(context.Background(), 10, 0); total != 0 {
+		t.Fatalf("expected no tasks in the shared partition, got %d", total)
+	}
+	if err := repo.Save(globex, &Task{ID: "t1"}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected globex not to overwrite acme's task, got %v", err)
+	}
+}
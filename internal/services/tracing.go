@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+)
+
+// NewTracedUserService wraps every operation of inner in a child span of the
+// request's, named like UserService.CreateUser, so the time spent in the
+// service and its repository shows up per operation. Errors are recorded on
+// the span. Without a span in the context, e.g. when tracing is disabled,
+// operations run untraced.
+func NewTracedUserService(inner UserService) UserService {
+	return &tracedUserService{inner: inner}
+}
+
+type tracedUserService struct {
+	inner UserService
+}
+
+func (s *tracedUserService) GetUserByID(ctx context.Context, id string) (*User, error) {
+	return traced(ctx, "UserService.GetUserByID", func(ctx context.Context) (*User, error) {
+		return s.inner.GetUserByID(ctx, id)
+	}, slog.String("user.id", id))
+}
+
+func (s *tracedUserService) GetAllUsers(ctx context.Context) ([]User, error) {
+	return traced(ctx, "UserService.GetAllUsers", s.inner.GetAllUsers)
+}
+
+func (s *tracedUserService) GetUsersByIDs(ctx context.Context, ids []string) ([]User, error) {
+	return traced(ctx, "UserService.GetUsersByIDs", func(ctx context.Context) ([]User, error) {
+		return s.inner.GetUsersByIDs(ctx, ids)
+	}, slog.Int("user.count", len(ids)))
+}
+
+func (s *tracedUserService) CreateUser(ctx context.Context, email, name string) (*User, error) {
+	return traced(ctx, "UserService.CreateUser", func(ctx context.Context) (*User, error) {
+		return s.inner.CreateUser(ctx, email, name)
+	})
+}
+
+func (s *tracedUserService) UpdateUser(ctx context.Context, id string, updates map[string]interface{}) (*User, error) {
+	return traced(ctx, "UserService.UpdateUser", func(ctx context.Context) (*User, error) {
+		return s.inner.UpdateUser(ctx, id, updates)
+	}, slog.String("user.id", id))
+}
+
+func (s *tracedUserService) DeleteUser(ctx context.Context, id string) error {
+	_, err := traced(ctx, "UserService.DeleteUser", func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, s.inner.DeleteUser(ctx, id)
+	}, slog.String("user.id", id))
+	return err
+}
+
+// LastModified is answered from memory on every list request; it is not
+// worth a span.
+func (s *tracedUserService) LastModified(ctx context.Context) (time.Time, bool) {
+	return s.inner.LastModified(ctx)
+}
+
+// NewTracedStatsService wraps every operation of inner in a child span, like
+// NewTracedUserService.
+func NewTracedStatsService(inner StatsService) StatsService {
+	return &tracedStatsService{inner: inner}
+}
+
+type tracedStatsService struct {
+	inner StatsService
+}
+
+func (s *tracedStatsService) GetSystemStats(ctx context.Context) (*SystemStats, error) {
+	return traced(ctx, "StatsService.GetSystemStats", s.inner.GetSystemStats)
+}
+
+func (s *tracedStatsService) GetAPIStats(ctx context.Context) (map[string]interface{}, error) {
+	return traced(ctx, "StatsService.GetAPIStats", s.inner.GetAPIStats)
+}
+
+func (s *tracedStatsService) GetMemStats(ctx context.Context) (*MemStatsReport, error) {
+	return traced(ctx, "StatsService.GetMemStats", s.inner.GetMemStats)
+}
+
+func (s *tracedStatsService) RunGC(ctx context.Context, freeOSMemory bool) (*GCResult, error) {
+	return traced(ctx, "StatsService.RunGC", func(ctx context.Context) (*GCResult, error) {
+		return s.inner.RunGC(ctx, freeOSMemory)
+	}, slog.Bool("gc.free_os_memory", freeOSMemory))
+}
+
+func (s *tracedStatsService) GoroutineDump(ctx context.Context) ([]byte, error) {
+	return traced(ctx, "StatsService.GoroutineDump", s.inner.GoroutineDump)
+}
+
+// traced runs op in a span named name, recording its error. Spans are only
+// started inside a traced request, so background callers do not start traces
+// of their own.
+func traced[T any](ctx context.Context, name string, op func(context.Context) (T, error), attrs ...slog.Attr) (T, error) {
+	if !tracing.SpanContextFromContext(ctx).IsValid() {
+		return op(ctx)
+	}
+	ctx, span := tracing.Start(ctx, name, attrs...)
+	defer span.End()
+	v, err := op(ctx)
+	tracing.RecordError(ctx, err)
+	return v, err
+}
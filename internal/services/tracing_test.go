@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/tracing"
+)
+
+func TestTracedUserService_SpansPerOperation(t *testing.T) {
+	type span struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Status       struct {
+			Code int `json:"code"`
+		} `json:"status"`
+	}
+	var spans []span
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		spans = append(spans, req.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}))
+	defer collector.Close()
+	exp := tracing.NewExporter(tracing.ExporterOptions{Endpoint: collector.URL})
+	svc := NewTracedUserService(NewUserService())
+
+	// Untraced callers start no trace
+	if _, err := svc.GetUserByID(context.Background(), "usr_001"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, request := tracing.New(exp, 1).Start(context.Background(), "GET /api/v1/users/{id}", tracing.KindServer)
+	if _, err := svc.GetUserByID(ctx, "usr_001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetUserByID(ctx, "usr_missing"); err != ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	request.End()
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spans) != 3 {
+		t.Fatalf("expected 2 operation spans and the request's, got %+v", spans)
+	}
+	root := spans[2]
+	for i, s := range spans[:2] {
+		if s.Name != "UserService.GetUserByID" || s.TraceID != root.TraceID || s.ParentSpanID != root.SpanID {
+			t.Fatalf("span %d not a child of the request: %+v", i, s)
+		}
+	}
+	if spans[0].Status.Code != 0 || spans[1].Status.Code != 2 {
+		t.Fatalf("expected only the failed lookup marked as an error: %+v", spans)
+	}
+}
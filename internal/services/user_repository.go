@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/mikko-kohtala/go-api/internal/ids"
+)
+
+// UserRepository stores users. It has no business rules; UserService
+// validates input, allocates IDs and sends notifications.
+type UserRepository interface {
+	// List returns every user, oldest first (by creation time, then ID).
+	List(ctx context.Context) ([]User, error)
+	Get(ctx context.Context, id string) (*User, error)
+	// GetMany returns the users of ids in one read, leaving out unknown ones.
+	GetMany(ctx context.Context, ids []string) ([]User, error)
+	Count(ctx context.Context) (int, error)
+	// Insert stores a new user, failing with ErrEmailAlreadyExists when
+	// another user has its email.
+	Insert(ctx context.Context, user *User) error
+	// Save replaces a stored user, failing with ErrUserNotFound or
+	// ErrEmailAlreadyExists.
+	Save(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id string) error
+}
+
+// NewMemoryUserRepository creates an in-memory UserRepository holding users
+// in the shared partition. It is the repository of tests and of deployments
+// without DATABASE_URL. Users are kept per tenant (see PartitionOf), and
+// emails are unique within a tenant.
+func NewMemoryUserRepository(users ...User) UserRepository {
+	m := &memoryUserRepository{tenants: newTenants(newUserPartition)}
+	p := m.tenants.get(context.Background(), true)
+	for i := range users {
+		p.users.of(users[i].ID).m[users[i].ID] = &users[i]
+		p.emails.of(users[i].Email).m[users[i].Email] = users[i].ID
+	}
+	return m
+}
+
+type memoryUserRepository struct {
+	tenants *tenants[userPartition]
+}
+
+// userPartition holds the users of a tenant. Writers lock the email stripes
+// they touch before the user's stripe.
+type userPartition struct {
+	users  *striped[*User]  // by ID
+	emails *striped[string] // user ID by email
+}
+
+func newUserPartition() *userPartition {
+	return &userPartition{users: newStriped[*User](), emails: newStriped[string]()}
+}
+
+func (m *memoryUserRepository) List(ctx context.Context) ([]User, error) {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return []User{}, nil
+	}
+	stored := p.users.values()
+	users := make([]User, len(stored))
+	for i, u := range stored {
+		users[i] = *u
+	}
+	// In creation order, like the IDs, rather than the map's random order
+	slices.SortFunc(users, func(a, b User) int { return ids.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+func (m *memoryUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	u, ok := m.tenants.get(ctx, false).get(id)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (m *memoryUserRepository) GetMany(ctx context.Context, userIDs []string) ([]User, error) {
+	p := m.tenants.get(ctx, false)
+	users := make([]User, 0, len(userIDs))
+	for _, id := range userIDs {
+		if u, ok := p.get(id); ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (m *memoryUserRepository) Count(ctx context.Context) (int, error) {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return 0, nil
+	}
+	return p.users.len(), nil
+}
+
+func (m *memoryUserRepository) Insert(ctx context.Context, user *User) error {
+	return m.tenants.get(ctx, true).put(user, false)
+}
+
+func (m *memoryUserRepository) Save(ctx context.Context, user *User) error {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return ErrUserNotFound
+	}
+	return p.put(user, true)
+}
+
+func (m *memoryUserRepository) Delete(ctx context.Context, id string) error {
+	p := m.tenants.get(ctx, false)
+	if p == nil {
+		return ErrUserNotFound
+	}
+	for {
+		prev, ok := p.get(id)
+		if !ok {
+			return ErrUserNotFound
+		}
+		unlock := p.emails.lock(prev.Email)
+		st := p.users.of(id)
+		st.Lock()
+		if cur, ok := st.m[id]; !ok || cur.Email != prev.Email {
+			// Changed since it was read; lock the stripes of its new email
+			st.Unlock()
+			unlock()
+			continue
+		}
+		delete(st.m, id)
+		delete(p.emails.of(prev.Email).m, prev.Email)
+		st.Unlock()
+		unlock()
+		return nil
+	}
+}
+
+// get returns a copy of the user with id; p may be nil.
+func (p *userPartition) get(id string) (User, bool) {
+	if p == nil {
+		return User{}, false
+	}
+	st := p.users.of(id)
+	st.RLock()
+	defer st.RUnlock()
+	u, ok := st.m[id]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// put stores user, replacing the stored user with its ID, which must exist
+// when replace is set.
+func (p *userPartition) put(user *User, replace bool) error {
+	for {
+		prev, exists := p.get(user.ID)
+		if replace && !exists {
+			return ErrUserNotFound
+		}
+		keys := []string{user.Email}
+		if exists {
+			keys = append(keys, prev.Email)
+		}
+		unlock := p.emails.lock(keys...)
+		st := p.users.of(user.ID)
+		st.Lock()
+		cur, ok := st.m[user.ID]
+		if ok != exists || ok && cur.Email != prev.Email {
+			// Changed since it was read; lock the stripes of its new email
+			st.Unlock()
+			unlock()
+			continue
+		}
+		return func() error {
+			defer unlock()
+			defer st.Unlock()
+			emails := p.emails.of(user.Email)
+			if id, taken := emails.m[user.Email]; taken && id != user.ID {
+				return ErrEmailAlreadyExists
+			}
+			if exists && prev.Email != user.Email {
+				delete(p.emails.of(prev.Email).m, prev.Email)
+			}
+			emails.m[user.Email] = user.ID
+			cp := *user
+			st.m[user.ID] = &cp
+			return nil
+		}()
+	}
+}
+
+// partitionUser is a user in a snapshot, with its tenant.
+type partitionUser struct {
+	Tenant string `json:"tenant,omitempty"`
+	User
+}
+
+// Snapshot returns every user with its tenant, oldest first, for
+// snapshot.Store.
+func (m *memoryUserRepository) Snapshot() (any, error) {
+	users := []partitionUser{}
+	for tenant, p := range m.tenants.all() {
+		for _, u := range p.users.values() {
+			users = append(users, partitionUser{Tenant: tenant, User: *u})
+		}
+	}
+	slices.SortFunc(users, func(a, b partitionUser) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return users, nil
+}
+
+// Restore decodes users written by Snapshot and returns a function replacing
+// every user with them, for snapshot.Store. Users without a tenant, such as
+// those of snapshots written before partitioning, go to the shared partition.
+func (m *memoryUserRepository) Restore(data json.RawMessage) (func(), error) {
+	var list []partitionUser
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	partitions := map[string]*userPartition{}
+	for i := range list {
+		u := &list[i].User
+		if u.ID == "" {
+			return nil, ErrInvalidUserID
+		}
+		p := partitions[list[i].Tenant]
+		if p == nil {
+			p = newUserPartition()
+			partitions[list[i].Tenant] = p
+		}
+		if _, taken := p.emails.of(u.Email).m[u.Email]; taken {
+			return nil, ErrEmailAlreadyExists
+		}
+		p.users.of(u.ID).m[u.ID] = u
+		p.emails.of(u.Email).m[u.Email] = u.ID
+	}
+	return func() { m.tenants.replace(partitions) }, nil
+}
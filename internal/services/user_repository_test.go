@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/internal/requestctx"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository(User{ID: "usr_001", Email: "a@example.com", Name: "A", CreatedAt: time.Now()})
+
+	if err := repo.Insert(ctx, &User{ID: "usr_002", Email: "a@example.com"}); !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Fatalf("Insert with taken email: expected ErrEmailAlreadyExists, got %v", err)
+	}
+	if err := repo.Insert(ctx, &User{ID: "usr_002", Email: "b@example.com", Name: "B"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if n, _ := repo.Count(ctx); n != 2 {
+		t.Fatalf("expected 2 users, got %d", n)
+	}
+
+	u, err := repo.Get(ctx, "usr_002")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	u.Name = "changed"
+	if stored, _ := repo.Get(ctx, "usr_002"); stored.Name != "B" {
+		t.Fatalf("Get must return a copy, stored name is %q", stored.Name)
+	}
+
+	if err := repo.Save(ctx, &User{ID: "usr_002", Email: "a@example.com"}); !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Fatalf("Save with taken email: expected ErrEmailAlreadyExists, got %v", err)
+	}
+	if err := repo.Save(ctx, &User{ID: "usr_404", Email: "c@example.com"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Save of unknown user: expected ErrUserNotFound, got %v", err)
+	}
+	if err := repo.Delete(ctx, "usr_002"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, "usr_002"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound after Delete, got %v", err)
+	}
+}
+
+func TestUserService_UpdateRejectedLeavesUserUnchanged(t *testing.T) {
+	svc := NewUserServiceWithRepository(NewMemoryUserRepository(SampleUsers()...), notify.Discard)
+	ctx := context.Background()
+
+	// The name is valid but the email is taken; neither may be applied
+	if _, err := svc.UpdateUser(ctx, "usr_002", map[string]interface{}{"name": "Renamed", "email": "john.doe@example.com"}); !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Fatalf("expected ErrEmailAlreadyExists, got %v", err)
+	}
+	user, err := svc.GetUserByID(ctx, "usr_002")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.Name != "Jane Smith" {
+		t.Fatalf("rejected update changed the name to %q", user.Name)
+	}
+}
+
+func tenantContext(tenant string) context.Context {
+	return requestctx.SetPrincipal(requestctx.New(context.Background()), requestctx.Identity{UserID: "usr_" + tenant, Tenant: tenant})
+}
+
+func TestMemoryUserRepository_PartitionsByTenant(t *testing.T) {
+	repo := NewMemoryUserRepository(User{ID: "usr_001", Email: "a@example.com"})
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	// Emails are unique per tenant
+	if err := repo.Insert(acme, &User{ID: "usr_002", Email: "a@example.com"}); err != nil {
+		t.Fatalf("Insert in acme: %v", err)
+	}
+	if err := repo.Insert(acme, &User{ID: "usr_003", Email: "a@example.com"}); !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Fatalf("expected ErrEmailAlreadyExists within acme, got %v", err)
+	}
+	if _, err := repo.Get(globex, "usr_002"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected another tenant's user to be invisible, got %v", err)
+	}
+	if err := repo.Delete(globex, "usr_002"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected another tenant's user not to be deleted, got %v", err)
+	}
+	if users, _ := repo.GetMany(acme, []string{"usr_001", "usr_002"}); len(users) != 1 || users[0].ID != "usr_002" {
+		t.Fatalf("expected only acme's user, got %+v", users)
+	}
+	if n, _ := repo.Count(context.Background()); n != 1 {
+		t.Fatalf("expected the shared partition to keep 1 user, got %d", n)
+	}
+
+	// A changed email frees the old one
+	if err := repo.Save(acme, &User{ID: "usr_002", Email: "b@example.com"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.Insert(acme, &User{ID: "usr_003", Email: "a@example.com"}); err != nil {
+		t.Fatalf("expected the previous email to be free, got %v", err)
+	}
+}
+
+func TestMemoryUserRepository_ConcurrentWrites(t *testing.T) {
+	repo := NewMemoryUserRepository()
+	ctx := tenantContext("acme")
+	parallel := func(fn func(i int)) {
+		var wg sync.WaitGroup
+		for i := range 64 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn(i)
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Pairs of writers race for each email; one of each pair wins
+	var conflicts atomic.Int32
+	parallel(func(i int) {
+		err := repo.Insert(ctx, &User{ID: fmt.Sprintf("usr_%03d", i), Email: fmt.Sprintf("%d@example.com", i/2)})
+		if errors.Is(err, ErrEmailAlreadyExists) {
+			conflicts.Add(1)
+		}
+	})
+	if conflicts.Load() != 32 {
+		t.Fatalf("expected 32 conflicts, got %d", conflicts.Load())
+	}
+	// The winners move to an email of their own and half of them leave
+	parallel(func(i int) {
+		id := fmt.Sprintf("usr_%03d", i)
+		if repo.Save(ctx, &User{ID: id, Email: fmt.Sprintf("moved-%d@example.com", i)}) == nil && i/2%2 == 0 {
+			_ = repo.Delete(ctx, id)
+		}
+	})
+
+	users, _ := repo.List(ctx)
+	if len(users) != 16 {
+		t.Fatalf("expected 16 users, got %d", len(users))
+	}
+	for _, u := range users {
+		if err := repo.Insert(ctx, &User{ID: "usr_new", Email: u.Email}); !errors.Is(err, ErrEmailAlreadyExists) {
+			t.Fatalf("expected the email index to hold %s, got %v", u.Email, err)
+		}
+	}
+	for i := range 32 {
+		if err := repo.Insert(ctx, &User{ID: fmt.Sprintf("usr_new%d", i), Email: fmt.Sprintf("%d@example.com", i)}); err != nil {
+			t.Fatalf("expected the moved-from email %d to be free, got %v", i, err)
+		}
+	}
+}
+
+func TestMemoryUserRepository_SnapshotKeepsTenants(t *testing.T) {
+	repo := NewMemoryUserRepository(User{ID: "usr_001", Email: "a@example.com"})
+	if err := repo.Insert(tenantContext("acme"), &User{ID: "usr_002", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	snap, _ := repo.(*memoryUserRepository).Snapshot()
+	data, _ := json.Marshal(snap)
+
+	restored := NewMemoryUserRepository().(*memoryUserRepository)
+	apply, err := restored.Restore(data)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	apply()
+	if _, err := restored.Get(tenantContext("acme"), "usr_002"); err != nil {
+		t.Fatalf("expected acme's user back in acme, got %v", err)
+	}
+	if _, err := restored.Get(context.Background(), "usr_002"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected acme's user outside the shared partition, got %v", err)
+	}
+
+	// Snapshots written before partitioning have no tenants
+	apply, err = restored.Restore(json.RawMessage(`[{"id":"usr_009","email":"z@example.com"}]`))
+	if err != nil {
+		t.Fatalf("Restore without tenants: %v", err)
+	}
+	apply()
+	if _, err := restored.Get(context.Background(), "usr_009"); err != nil {
+		t.Fatalf("expected the user in the shared partition, got %v", err)
+	}
+	if _, err := restored.Restore(json.RawMessage(`[{"id":"a","email":"x@example.com"},{"id":"b","email":"x@example.com"}]`)); !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Fatalf("expected duplicate emails to be rejected, got %v", err)
+	}
+}
@@ -3,9 +3,13 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
-	"sync"
+	"log/slog"
+	"slices"
 	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/notify"
+	"github.com/mikko-kohtala/go-api/pkg/logger"
 )
 
 // Custom error types for better error handling
@@ -27,64 +31,82 @@ type User struct {
 type UserService interface {
 	GetUserByID(ctx context.Context, id string) (*User, error)
 	GetAllUsers(ctx context.Context) ([]User, error)
+	// GetUsersByIDs returns the users of ids in one repository read, leaving
+	// out unknown ones, for callers batching lookups.
+	GetUsersByIDs(ctx context.Context, ids []string) ([]User, error)
 	CreateUser(ctx context.Context, email, name string) (*User, error)
 	UpdateUser(ctx context.Context, id string, updates map[string]interface{}) (*User, error)
 	DeleteUser(ctx context.Context, id string) error
+	// LastModified returns when the user collection last changed, and false
+	// when that is unknown.
+	LastModified(ctx context.Context) (time.Time, bool)
 }
 
 type userService struct {
-	mu    sync.RWMutex // Protects concurrent access to the users map
-	users map[string]*User
+	repo     UserRepository
+	notifier notify.Notifier
+	changes  *ChangeClock // nil when other processes write to repo
 }
 
 func NewUserService() UserService {
-	// Initialize with some test data
-	return &userService{
-		users: map[string]*User{
-			"usr_001": {
-				ID:        "usr_001",
-				Email:     "john.doe@example.com",
-				Name:      "John Doe",
-				Role:      "admin",
-				CreatedAt: time.Now().Add(-24 * time.Hour),
-			},
-			"usr_002": {
-				ID:        "usr_002",
-				Email:     "jane.smith@example.com",
-				Name:      "Jane Smith",
-				Role:      "user",
-				CreatedAt: time.Now().Add(-48 * time.Hour),
-			},
+	return NewUserServiceWithNotifier(notify.Discard)
+}
+
+// NewUserServiceWithNotifier creates a user service on an in-memory
+// repository holding SampleUsers that sends a welcome notification to newly
+// created users.
+func NewUserServiceWithNotifier(notifier notify.Notifier) UserService {
+	return NewUserServiceWithRepository(NewMemoryUserRepository(SampleUsers()...), notifier)
+}
+
+// SampleUsers returns the users an in-memory repository starts with.
+func SampleUsers() []User {
+	now := time.Now()
+	return []User{
+		{
+			ID:        "usr_001",
+			Email:     "john.doe@example.com",
+			Name:      "John Doe",
+			Role:      "admin",
+			CreatedAt: now.Add(-24 * time.Hour),
+		},
+		{
+			ID:        "usr_002",
+			Email:     "jane.smith@example.com",
+			Name:      "Jane Smith",
+			Role:      "user",
+			CreatedAt: now.Add(-48 * time.Hour),
 		},
 	}
 }
 
+// NewUserServiceWithRepository creates a user service storing users in repo.
+// It tracks when the users last changed unless repo is shared with other
+// processes.
+func NewUserServiceWithRepository(repo UserRepository, notifier notify.Notifier) UserService {
+	s := &userService{repo: repo, notifier: notifier}
+	if shared, ok := repo.(interface{ Shared() bool }); !ok || !shared.Shared() {
+		s.changes = NewChangeClock()
+	}
+	return s
+}
+
 func (s *userService) GetUserByID(ctx context.Context, id string) (*User, error) {
 	if id == "" {
 		return nil, ErrInvalidUserID
 	}
-
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	if user, exists := s.users[id]; exists {
-		// Return a copy to prevent external modifications
-		userCopy := *user
-		return &userCopy, nil
-	}
-	return nil, ErrUserNotFound
+	return s.repo.Get(ctx, id)
 }
 
 func (s *userService) GetAllUsers(ctx context.Context) ([]User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.repo.List(ctx)
+}
 
-	users := make([]User, 0, len(s.users))
-	for _, user := range s.users {
-		// Return copies to prevent external modifications
-		users = append(users, *user)
+func (s *userService) GetUsersByIDs(ctx context.Context, userIDs []string) ([]User, error) {
+	if slices.Contains(userIDs, "") {
+		return nil, ErrInvalidUserID
 	}
-	return users, nil
+	return s.repo.GetMany(ctx, userIDs)
 }
 
 func (s *userService) CreateUser(ctx context.Context, email, name string) (*User, error) {
@@ -96,19 +118,10 @@ func (s *userService) CreateUser(ctx context.Context, email, name string) (*User
 		return nil, errors.New("name is required")
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Check if email already exists
-	for _, user := range s.users {
-		if user.Email == email {
-			return nil, ErrEmailAlreadyExists
-		}
+	id, err := ids.Default.New(ids.User)
+	if err != nil {
+		return nil, err
 	}
-
-	// Generate a simple ID (in production, use UUID)
-	id := fmt.Sprintf("usr_%03d", len(s.users)+1)
-
 	user := &User{
 		ID:        id,
 		Email:     email,
@@ -116,12 +129,21 @@ func (s *userService) CreateUser(ctx context.Context, email, name string) (*User
 		Role:      "user",
 		CreatedAt: time.Now(),
 	}
-
-	s.users[id] = user
-
-	// Return a copy
-	userCopy := *user
-	return &userCopy, nil
+	if err := s.repo.Insert(ctx, user); err != nil {
+		return nil, err
+	}
+	s.touch()
+
+	// Delivery happens in the background; a failure to queue must not fail the signup
+	if err := s.notifier.Notify(ctx, notify.Notification{
+		UserID:   user.ID,
+		To:       notify.Recipient{Email: email},
+		Template: "welcome",
+		Data:     map[string]any{"Name": name, "Email": email},
+	}); err != nil {
+		logger.FromContext(ctx).Warn("welcome notification not queued", slog.String("user_id", user.ID), slog.String("error", err.Error()))
+	}
+	return user, nil
 }
 
 func (s *userService) UpdateUser(ctx context.Context, id string, updates map[string]interface{}) (*User, error) {
@@ -129,12 +151,9 @@ func (s *userService) UpdateUser(ctx context.Context, id string, updates map[str
 		return nil, ErrInvalidUserID
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	user, exists := s.users[id]
-	if !exists {
-		return nil, ErrUserNotFound
+	user, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
 	// Apply updates with validation
@@ -142,34 +161,40 @@ func (s *userService) UpdateUser(ctx context.Context, id string, updates map[str
 		user.Name = name
 	}
 	if email, ok := updates["email"].(string); ok && email != "" {
-		// Check if new email already exists (except for current user)
-		for uid, u := range s.users {
-			if uid != id && u.Email == email {
-				return nil, ErrEmailAlreadyExists
-			}
-		}
 		user.Email = email
 	}
 	if role, ok := updates["role"].(string); ok && role != "" {
 		user.Role = role
 	}
 
-	// Return a copy
-	userCopy := *user
-	return &userCopy, nil
+	// The repository rejects an email another user has
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	s.touch()
+	return user, nil
 }
 
 func (s *userService) DeleteUser(ctx context.Context, id string) error {
 	if id == "" {
 		return ErrInvalidUserID
 	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.touch()
+	return nil
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *userService) LastModified(context.Context) (time.Time, bool) {
+	if s.changes == nil {
+		return time.Time{}, false
+	}
+	return s.changes.LastModified(), true
+}
 
-	if _, exists := s.users[id]; !exists {
-		return ErrUserNotFound
+func (s *userService) touch() {
+	if s.changes != nil {
+		s.changes.Touch(time.Now())
 	}
-	delete(s.users, id)
-	return nil
 }
@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"strings"
 	"testing"
+
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/notify"
 )
 
 func TestUserService_CreateUser(t *testing.T) {
@@ -12,8 +16,8 @@ func TestUserService_CreateUser(t *testing.T) {
 	if err != nil {
 		t.Fatalf("CreateUser returned error: %v", err)
 	}
-	if user.ID == "" {
-		t.Fatalf("expected ID to be set")
+	if _, ok := ids.Time(user.ID); !strings.HasPrefix(user.ID, "usr_") || !ok {
+		t.Fatalf("expected a generated user ID, got %q", user.ID)
 	}
 	if user.Email != "new.user@example.com" {
 		t.Fatalf("expected email to match, got %s", user.Email)
@@ -22,6 +26,15 @@ func TestUserService_CreateUser(t *testing.T) {
 	if _, err := svc.CreateUser(context.Background(), "john.doe@example.com", "Dup"); err != ErrEmailAlreadyExists {
 		t.Fatalf("expected ErrEmailAlreadyExists, got %v", err)
 	}
+
+	// IDs do not depend on how many users there are, so deletes cause no collisions
+	_ = svc.DeleteUser(context.Background(), "usr_001")
+	if _, err := svc.CreateUser(context.Background(), "after.delete@example.com", "After Delete"); err != nil {
+		t.Fatalf("CreateUser after a delete returned error: %v", err)
+	}
+	if users, _ := svc.GetAllUsers(context.Background()); len(users) != 3 {
+		t.Fatalf("expected 3 users, got %d", len(users))
+	}
 }
 
 func TestUserService_UpdateUser(t *testing.T) {
@@ -68,3 +81,27 @@ func TestUserService_GetUserByIDValidation(t *testing.T) {
 		t.Fatalf("expected ErrInvalidUserID, got %v", err)
 	}
 }
+
+type sharedUserRepository struct{ UserRepository }
+
+func (sharedUserRepository) Shared() bool { return true }
+
+func TestUserService_LastModified(t *testing.T) {
+	ctx := context.Background()
+	svc := NewUserService()
+	before, ok := svc.LastModified(ctx)
+	if !ok {
+		t.Fatal("expected an in-memory user service to track changes")
+	}
+	if err := svc.DeleteUser(ctx, "usr_002"); err != nil {
+		t.Fatalf("DeleteUser returned error: %v", err)
+	}
+	if after, _ := svc.LastModified(ctx); after.Before(before) || after.Equal(before) {
+		t.Fatalf("expected the delete to advance LastModified, got %v then %v", before, after)
+	}
+
+	shared := NewUserServiceWithRepository(sharedUserRepository{NewMemoryUserRepository()}, notify.Discard)
+	if _, ok := shared.LastModified(ctx); ok {
+		t.Fatal("expected no change time for a shared repository")
+	}
+}
@@ -0,0 +1,84 @@
+package shutdown
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stage orders shutdown hooks. Once the listeners have drained, hooks run
+// stage by stage and, within a stage, in the order they were registered.
+type Stage int
+
+const (
+	// StageComponents stops background loops: retention, degradation checks,
+	// asset reloading, cache invalidation.
+	StageComponents Stage = iota
+	// StageWorkers finishes background jobs, which may still write.
+	StageWorkers
+	// StageStorage closes what requests and jobs wrote through: the
+	// database pool, Redis clients, the snapshot file.
+	StageStorage
+	// StageTelemetry flushes exporters, last so they carry the spans of
+	// everything before.
+	StageTelemetry
+)
+
+// Hook stops one subsystem.
+type Hook struct {
+	Name    string
+	Stage   Stage
+	Timeout time.Duration // of its own, 0 for only the shutdown deadline
+	Stop    func(context.Context) error
+}
+
+// Hooks holds the hooks subsystems register while the server starts. Its
+// methods may be called concurrently.
+type Hooks struct {
+	mu    sync.Mutex
+	hooks []Hook
+}
+
+// Default holds the hooks of the server; subsystems register on it when they
+// start, and main runs it after draining the listeners.
+var Default = &Hooks{}
+
+// Register adds hook, to be run by Run.
+func (h *Hooks) Register(hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, hook)
+}
+
+// Hooks returns the registered hooks in the order Run runs them.
+func (h *Hooks) Hooks() []Hook {
+	h.mu.Lock()
+	hooks := slices.Clone(h.hooks)
+	h.mu.Unlock()
+	slices.SortStableFunc(hooks, func(a, b Hook) int { return int(a.Stage - b.Stage) })
+	return hooks
+}
+
+// Run stops every hook in order through r, so each is recorded as a
+// component of the report, and logs the ones that failed or were left
+// behind. Hooks run one at a time; a stuck one costs its timeout, not the
+// rest of the shutdown.
+func (h *Hooks) Run(ctx context.Context, r *Recorder, logger *slog.Logger) {
+	for _, hook := range h.Hooks() {
+		if err := r.Run(ctx, hook.Name, hook.Timeout, hook.Stop); err != nil {
+			logger.Warn("component did not stop cleanly", slog.String("component", hook.Name), slog.String("error", err.Error()))
+		}
+	}
+}
+
+var draining atomic.Bool
+
+// SetDraining flags whether the server is draining: it still serves, but
+// GET /readyz answers 503 so load balancers stop sending it traffic.
+func SetDraining(v bool) { draining.Store(v) }
+
+// Draining reports whether the server is draining.
+func Draining() bool { return draining.Load() }
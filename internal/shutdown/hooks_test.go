@@ -0,0 +1,45 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooks_RunInStageOrder(t *testing.T) {
+	var h Hooks
+	var order []string
+	hook := func(name string, stage Stage, err error) Hook {
+		return Hook{Name: name, Stage: stage, Stop: func(context.Context) error {
+			order = append(order, name)
+			return err
+		}}
+	}
+	h.Register(hook("tracing", StageTelemetry, nil))
+	h.Register(hook("database", StageStorage, errors.New("pool busy")))
+	h.Register(hook("retention", StageComponents, nil))
+	h.Register(hook("jobs", StageWorkers, nil))
+	h.Register(hook("assets", StageComponents, nil))
+	stuck := make(chan struct{})
+	defer close(stuck)
+	h.Register(Hook{Name: "redis", Stage: StageStorage, Timeout: 10 * time.Millisecond, Stop: Func(func() { <-stuck })})
+
+	r := Start("terminated")
+	var logs bytes.Buffer
+	h.Run(context.Background(), r, slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	if got := strings.Join(order, ","); got != "retention,assets,jobs,database,tracing" {
+		t.Fatalf("hooks ran out of order: %s", got)
+	}
+	rep := r.Report()
+	if len(rep.Components) != 6 || rep.Components[3].Error != "pool busy" || !rep.Components[4].TimedOut || rep.Components[5].Name != "tracing" {
+		t.Fatalf("unexpected components: %+v", rep.Components)
+	}
+	if !strings.Contains(logs.String(), `"component":"database"`) || !strings.Contains(logs.String(), `"component":"redis"`) {
+		t.Fatalf("failed hooks not logged: %s", logs.String())
+	}
+}
@@ -0,0 +1,179 @@
+// Package shutdown records how a graceful shutdown went: how long draining
+// the listeners took, how many requests and background jobs were finished or
+// given up, and which components did not stop within their timeout. main
+// runs each stop step through a Recorder and logs the report as its last
+// record, so shutdown regressions show up in the logs instead of passing
+// silently. Subsystems register the hooks that stop them on Default, and
+// SetDraining turns readiness off before the listeners start draining.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Requests counts the requests in flight when the listeners started draining.
+type Requests struct {
+	InFlight  int64 `json:"in_flight"`
+	Completed int64 `json:"completed"`
+	Aborted   int64 `json:"aborted"` // still running when the connections were closed
+}
+
+// Jobs counts the background jobs queued or running when the pool was shut
+// down.
+type Jobs struct {
+	Pending int `json:"pending"`
+	Drained int `json:"drained"`
+	Dropped int `json:"dropped"` // not finished when the deadline passed
+}
+
+// Component is the outcome of stopping one part of the server.
+type Component struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration_ns"`
+	TimedOut bool          `json:"timed_out,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Report describes a shutdown.
+type Report struct {
+	Signal     string        `json:"signal"`
+	Duration   time.Duration `json:"duration_ns"`
+	Drain      time.Duration `json:"drain_ns"` // draining the listeners
+	Requests   Requests      `json:"requests"`
+	Jobs       Jobs          `json:"jobs"`
+	Components []Component   `json:"components"` // in the order they stopped
+}
+
+// Recorder collects the report while the server shuts down. Its methods may
+// be called concurrently, e.g. by listeners draining in parallel.
+type Recorder struct {
+	mu     sync.Mutex
+	start  time.Time
+	report Report
+}
+
+// Start begins recording a shutdown caused by signal.
+func Start(signal string) *Recorder {
+	return &Recorder{start: time.Now(), report: Report{Signal: signal, Components: []Component{}}}
+}
+
+// Run stops the component name with stop, giving it until ctx is done or
+// timeout (0 for no limit of its own) has passed. A component that does not
+// return in time is left behind and recorded as timed out, so one stuck
+// component cannot hold up the rest of the shutdown. Run returns the error of
+// stop, or the context's when it gave up.
+func (r *Recorder) Run(ctx context.Context, name string, timeout time.Duration, stop func(context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- stop(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c := Component{Name: name, Duration: time.Since(start), TimedOut: errors.Is(err, context.DeadlineExceeded)}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	r.mu.Lock()
+	r.report.Components = append(r.report.Components, c)
+	r.mu.Unlock()
+	return err
+}
+
+// Func adapts a Stop method that takes no context to Run.
+func Func(stop func()) func(context.Context) error {
+	return func(context.Context) error {
+		stop()
+		return nil
+	}
+}
+
+// SetRequests records that inFlight requests were being served when draining
+// started and aborted were still running when it ended, after drain.
+func (r *Recorder) SetRequests(inFlight, aborted int64, drain time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Drain = drain
+	r.report.Requests = Requests{InFlight: inFlight, Completed: max(0, inFlight-aborted), Aborted: aborted}
+}
+
+// SetJobs records that pending jobs were queued or running when the pool was
+// shut down and dropped of them were not finished after it.
+func (r *Recorder) SetJobs(pending, dropped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Jobs = Jobs{Pending: pending, Drained: max(0, pending-dropped), Dropped: dropped}
+}
+
+// Report returns a copy of the report, its duration measured until now.
+func (r *Recorder) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.report
+	rep.Duration = time.Since(r.start)
+	rep.Components = slices.Clone(rep.Components)
+	return rep
+}
+
+// Clean reports whether nothing was given up: no request aborted, no job
+// dropped and every component stopped in time without an error.
+func (rep Report) Clean() bool {
+	if rep.Requests.Aborted > 0 || rep.Jobs.Dropped > 0 {
+		return false
+	}
+	for _, c := range rep.Components {
+		if c.TimedOut || c.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Log writes the report as one "shutdown report" record, a warning unless
+// the shutdown was clean.
+func (r *Recorder) Log(logger *slog.Logger) {
+	rep := r.Report()
+	level := slog.LevelInfo
+	if !rep.Clean() {
+		level = slog.LevelWarn
+	}
+	durations := make([]any, 0, len(rep.Components))
+	timedOut, failed := []string{}, []string{}
+	for _, c := range rep.Components {
+		durations = append(durations, slog.Duration(c.Name, c.Duration))
+		switch {
+		case c.TimedOut:
+			timedOut = append(timedOut, c.Name)
+		case c.Error != "":
+			failed = append(failed, c.Name)
+		}
+	}
+	logger.Log(context.Background(), level, "shutdown report",
+		slog.String("signal", rep.Signal),
+		slog.Duration("duration", rep.Duration),
+		slog.Duration("drain_duration", rep.Drain),
+		slog.Group("requests",
+			slog.Int64("in_flight", rep.Requests.InFlight),
+			slog.Int64("completed", rep.Requests.Completed),
+			slog.Int64("aborted", rep.Requests.Aborted)),
+		slog.Group("jobs",
+			slog.Int("pending", rep.Jobs.Pending),
+			slog.Int("drained", rep.Jobs.Drained),
+			slog.Int("dropped", rep.Jobs.Dropped)),
+		slog.Group("components", durations...),
+		slog.Any("timed_out", timedOut),
+		slog.Any("failed", failed))
+}
@@ -0,0 +1,63 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder_RunRecordsComponents(t *testing.T) {
+	r := Start("terminated")
+	ctx := context.Background()
+
+	if err := r.Run(ctx, "assets", time.Second, Func(func() {})); err != nil {
+		t.Fatalf("expected a clean stop, got %v", err)
+	}
+	stuck := make(chan struct{})
+	defer close(stuck)
+	if err := r.Run(ctx, "retention", 10*time.Millisecond, Func(func() { <-stuck })); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a stuck component to be left behind, got %v", err)
+	}
+	if err := r.Run(ctx, "jobs", 0, func(context.Context) error { return errors.New("boom") }); err == nil {
+		t.Fatal("expected the component's error")
+	}
+
+	rep := r.Report()
+	if len(rep.Components) != 3 || rep.Components[0].TimedOut || !rep.Components[1].TimedOut || rep.Components[2].Error != "boom" {
+		t.Fatalf("unexpected components: %+v", rep.Components)
+	}
+	if rep.Signal != "terminated" || rep.Duration <= 0 || rep.Clean() {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestRecorder_Log(t *testing.T) {
+	r := Start("interrupt")
+	r.SetRequests(5, 0, 20*time.Millisecond)
+	r.SetJobs(3, 0)
+	_ = r.Run(context.Background(), "assets", 0, Func(func() {}))
+
+	var buf bytes.Buffer
+	r.Log(slog.New(slog.NewJSONHandler(&buf, nil)))
+	logged := buf.String()
+	for _, want := range []string{`"level":"INFO"`, `"msg":"shutdown report"`, `"completed":5`, `"drained":3`, `"timed_out":[]`} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("expected %s in %s", want, logged)
+		}
+	}
+
+	r.SetRequests(5, 2, time.Second)
+	r.SetJobs(3, 1)
+	buf.Reset()
+	r.Log(slog.New(slog.NewJSONHandler(&buf, nil)))
+	logged = buf.String()
+	for _, want := range []string{`"level":"WARN"`, `"completed":3`, `"aborted":2`, `"drained":2`, `"dropped":1`} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("expected %s in %s", want, logged)
+		}
+	}
+}
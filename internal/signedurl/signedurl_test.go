@@ -0,0 +1,70 @@
+package signedurl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := New([]byte("secret"), 30*time.Second, time.Hour)
+	s.now = func() time.Time { return now }
+
+	signed := s.Sign("/files/file_1", now.Add(time.Minute))
+	if err := s.Verify(httptest.NewRequest(http.MethodGet, signed, nil)); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	// Within the skew allowance after expiry
+	now = now.Add(80 * time.Second)
+	if err := s.Verify(httptest.NewRequest(http.MethodGet, signed, nil)); err != nil {
+		t.Fatalf("expected skew tolerance, got %v", err)
+	}
+	now = now.Add(20 * time.Second)
+	if err := s.Verify(httptest.NewRequest(http.MethodGet, signed, nil)); err != ErrExpired {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	s := New([]byte("secret"), 0, time.Hour)
+	signed := s.Sign("/files/file_1", time.Now().Add(time.Hour))
+
+	cases := map[string]string{
+		"other path":     strings.Replace(signed, "file_1", "file_2", 1),
+		"later expiry":   strings.Replace(signed, "expires=", "expires=9", 1),
+		"missing sig":    "/files/file_1",
+		"other key":      New([]byte("other"), 0, time.Hour).Sign("/files/file_1", time.Now().Add(time.Hour)),
+		"malformed sig":  "/files/file_1?expires=1&sig=%%%",
+		"non-numeric ex": "/files/file_1?expires=abc&sig=" + strings.SplitN(signed, "sig=", 2)[1],
+	}
+	for name, target := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL, _ = req.URL.Parse(target)
+		if err := s.Verify(req); err == nil {
+			t.Fatalf("%s: expected verification to fail", name)
+		}
+	}
+}
+
+func TestRequireMiddleware(t *testing.T) {
+	s := New([]byte("secret"), 0, time.Hour)
+	h := s.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, s.Sign("/files/a", time.Now().Add(time.Minute)), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, s.Sign("/files/a", time.Now().Add(-time.Minute)), nil))
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "url_expired") {
+		t.Fatalf("expected 403 url_expired, got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,220 @@
+// Package snapshot saves the state of the in-memory stores to a JSON document
+// and restores it, so development data survives restarts without a database.
+// main restores SNAPSHOT_FILE at startup and writes it on shutdown; the admin
+// API exports and imports the same document at runtime.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Version is the document format written by Export.
+const Version = 1
+
+// Store is in-memory state that can be snapshotted.
+type Store interface {
+	// Snapshot returns the state as a JSON-encodable value.
+	Snapshot() (any, error)
+	// Restore decodes data and returns a function swapping it in, so that
+	// an import replaces every store or none.
+	Restore(data json.RawMessage) (swap func(), err error)
+}
+
+// Document is a snapshot of every registered store.
+type Document struct {
+	Version int                        `json:"version"`
+	TakenAt time.Time                  `json:"taken_at"`
+	Stores  map[string]json.RawMessage `json:"stores"`
+}
+
+// Registry holds the stores that are snapshotted, by name.
+type Registry struct {
+	mu      sync.Mutex
+	stores  map[string]Store
+	secrets map[string]bool // stores registered with RegisterSecret
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{stores: make(map[string]Store), secrets: make(map[string]bool)}
+}
+
+// Default is the registry the router registers its in-memory stores with.
+var Default = NewRegistry()
+
+// Register adds s under name, replacing a store registered before.
+func (r *Registry) Register(name string, s Store) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stores[name] = s
+	delete(r.secrets, name)
+}
+
+// RegisterSecret adds s under name as a store whose state holds secrets,
+// such as API key hashes. It is saved to and restored from files like the
+// others, but ExportPublic leaves it out and ImportPublic refuses it.
+func (r *Registry) RegisterSecret(name string, s Store) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stores[name] = s
+	r.secrets[name] = true
+}
+
+// Names lists the registered stores in order.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.stores))
+	for name := range r.stores {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Export snapshots every registered store.
+func (r *Registry) Export() (Document, error) {
+	return r.export(true)
+}
+
+// ExportPublic snapshots the stores that hold no secrets, for the admin API.
+func (r *Registry) ExportPublic() (Document, error) {
+	return r.export(false)
+}
+
+func (r *Registry) export(secrets bool) (Document, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc := Document{Version: Version, TakenAt: time.Now().UTC(), Stores: make(map[string]json.RawMessage, len(r.stores))}
+	for name, s := range r.stores {
+		if r.secrets[name] && !secrets {
+			continue
+		}
+		state, err := s.Snapshot()
+		if err != nil {
+			return Document{}, fmt.Errorf("snapshot %s: %w", name, err)
+		}
+		data, err := json.Marshal(state)
+		if err != nil {
+			return Document{}, fmt.Errorf("snapshot %s: %w", name, err)
+		}
+		doc.Stores[name] = data
+	}
+	return doc, nil
+}
+
+// Import restores the stores in doc and returns their names. Registered
+// stores doc leaves out keep their state. Nothing is restored when doc has
+// another version, names an unknown store or any store rejects its data.
+func (r *Registry) Import(doc Document) ([]string, error) {
+	return r.importDoc(doc, true)
+}
+
+// ImportPublic is Import for the admin API: nothing is restored when doc
+// names a store registered with RegisterSecret.
+func (r *Registry) ImportPublic(doc Document) ([]string, error) {
+	return r.importDoc(doc, false)
+}
+
+func (r *Registry) importDoc(doc Document, secrets bool) ([]string, error) {
+	if doc.Version != Version {
+		return nil, fmt.Errorf("unsupported snapshot version %d", doc.Version)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(doc.Stores))
+	for name := range doc.Stores {
+		if _, ok := r.stores[name]; !ok {
+			return nil, fmt.Errorf("unknown store %q", name)
+		}
+		if r.secrets[name] && !secrets {
+			return nil, fmt.Errorf("store %q holds secrets and is only restored from files", name)
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	swaps := make([]func(), 0, len(names))
+	for _, name := range names {
+		swap, err := r.stores[name].Restore(doc.Stores[name])
+		if err != nil {
+			return nil, fmt.Errorf("restore %s: %w", name, err)
+		}
+		swaps = append(swaps, swap)
+	}
+	for _, swap := range swaps {
+		swap()
+	}
+	return names, nil
+}
+
+// SaveFile writes a snapshot to path, replacing it atomically.
+func (r *Registry) SaveFile(path string) error {
+	doc, err := r.Export()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile restores the snapshot at path and returns the restored stores. A
+// missing file restores nothing and is not an error.
+func (r *Registry) LoadFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return r.Import(doc)
+}
+
+// Then returns s, running fn after each restore of s is swapped in; for
+// example to drop caches of the replaced state.
+func Then(s Store, fn func()) Store {
+	return then{Store: s, fn: fn}
+}
+
+type then struct {
+	Store
+	fn func()
+}
+
+func (t then) Restore(data json.RawMessage) (func(), error) {
+	swap, err := t.Store.Restore(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		swap()
+		t.fn()
+	}, nil
+}
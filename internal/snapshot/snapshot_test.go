@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// listStore is a Store of strings that rejects "bad".
+type listStore struct{ items []string }
+
+func (s *listStore) Snapshot() (any, error) { return s.items, nil }
+
+func (s *listStore) Restore(data json.RawMessage) (func(), error) {
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if it == "bad" {
+			return nil, errors.New("bad item")
+		}
+	}
+	return func() { s.items = items }, nil
+}
+
+func TestRegistry_ExportImportIsAllOrNothing(t *testing.T) {
+	users, tasks := &listStore{items: []string{"u1"}}, &listStore{items: []string{"t1", "t2"}}
+	reg := NewRegistry()
+	reg.Register("users", users)
+	reg.Register("tasks", tasks)
+
+	doc, err := reg.Export()
+	if err != nil || doc.Version != Version || string(doc.Stores["tasks"]) != `["t1","t2"]` {
+		t.Fatalf("unexpected export %+v, %v", doc, err)
+	}
+
+	bad := Document{Version: Version, Stores: map[string]json.RawMessage{"users": json.RawMessage(`["u9"]`), "tasks": json.RawMessage(`["bad"]`)}}
+	if _, err := reg.Import(bad); err == nil || users.items[0] != "u1" {
+		t.Fatalf("expected a rejected import to leave every store alone, got %v %v", err, users.items)
+	}
+	if _, err := reg.Import(Document{Version: Version, Stores: map[string]json.RawMessage{"files": json.RawMessage(`[]`)}}); err == nil {
+		t.Fatalf("expected an unknown store to be rejected")
+	}
+	if _, err := reg.Import(Document{Version: 2}); err == nil {
+		t.Fatalf("expected an unknown version to be rejected")
+	}
+
+	swapped := 0
+	reg.Register("users", Then(users, func() { swapped++ }))
+	restored, err := reg.Import(Document{Version: Version, Stores: map[string]json.RawMessage{"users": json.RawMessage(`["u9"]`)}})
+	if err != nil || len(restored) != 1 || users.items[0] != "u9" || len(tasks.items) != 2 || swapped != 1 {
+		t.Fatalf("expected only users replaced and the hook run, got %v %v %v %v %d", restored, err, users.items, tasks.items, swapped)
+	}
+}
+
+func TestRegistry_PublicExportLeavesOutSecrets(t *testing.T) {
+	tasks, keys := &listStore{items: []string{"t1"}}, &listStore{items: []string{"hash1"}}
+	reg := NewRegistry()
+	reg.Register("tasks", tasks)
+	reg.RegisterSecret("apikeys", keys)
+
+	doc, err := reg.ExportPublic()
+	if _, ok := doc.Stores["apikeys"]; err != nil || ok || string(doc.Stores["tasks"]) != `["t1"]` {
+		t.Fatalf("expected only tasks in the public export, got %+v, %v", doc.Stores, err)
+	}
+	if doc, err := reg.Export(); err != nil || string(doc.Stores["apikeys"]) != `["hash1"]` {
+		t.Fatalf("expected the full export to keep the secrets, got %+v, %v", doc.Stores, err)
+	}
+
+	withKeys := Document{Version: Version, Stores: map[string]json.RawMessage{"tasks": json.RawMessage(`["t2"]`), "apikeys": json.RawMessage(`["hash2"]`)}}
+	if _, err := reg.ImportPublic(withKeys); err == nil || tasks.items[0] != "t1" || keys.items[0] != "hash1" {
+		t.Fatalf("expected a public import naming a secret store to be refused, got %v %v %v", err, tasks.items, keys.items)
+	}
+	if _, err := reg.Import(withKeys); err != nil || keys.items[0] != "hash2" {
+		t.Fatalf("expected a full import to restore the secrets, got %v %v", err, keys.items)
+	}
+}
+
+func TestRegistry_SaveAndLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := &listStore{items: []string{"a"}}
+	reg := NewRegistry()
+	reg.Register("items", store)
+
+	if restored, err := reg.LoadFile(path); err != nil || restored != nil {
+		t.Fatalf("expected a missing file to restore nothing, got %v %v", restored, err)
+	}
+	if err := reg.SaveFile(path); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	store.items = nil
+	if restored, err := reg.LoadFile(path); err != nil || len(restored) != 1 || len(store.items) != 1 || store.items[0] != "a" {
+		t.Fatalf("expected the saved state back, got %v %v %v", restored, err, store.items)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.LoadFile(path); err == nil {
+		t.Fatalf("expected a corrupt file to fail")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,167 @@
+// Package storage provides blob storage backends for uploaded files and
+// generated artifacts.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// keyPattern restricts keys to safe file names so they cannot escape the store.
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,200}$`)
+
+// Store persists opaque objects addressed by key.
+type Store interface {
+	// Append writes r to the end of the object, creating it if needed, and
+	// returns the number of bytes written (which may be partial on error).
+	Append(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Put replaces the object with the contents of r.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns a reader for the object.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+func validKey(key string) error {
+	if !keyPattern.MatchString(key) || key == "." || key == ".." {
+		return errors.New("invalid storage key")
+	}
+	return nil
+}
+
+// Disk stores objects as files in a directory.
+type Disk struct {
+	dir string
+}
+
+// NewDisk creates a disk store rooted at dir, creating the directory if needed.
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(d.dir, key), nil
+}
+
+func (d *Disk) Append(ctx context.Context, key string, r io.Reader) (int64, error) {
+	return d.write(key, os.O_CREATE|os.O_WRONLY|os.O_APPEND, r)
+}
+
+func (d *Disk) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	return d.write(key, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, r)
+}
+
+func (d *Disk) write(key string, flag int, r io.Reader) (int64, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(p, flag, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+func (d *Disk) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d *Disk) Delete(ctx context.Context, key string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Memory keeps objects in memory. It is intended for development and tests.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemory creates an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string][]byte)}
+}
+
+func (m *Memory) Append(ctx context.Context, key string, r io.Reader) (int64, error) {
+	if err := validKey(key); err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(r)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append(m.objects[key], data...)
+	return int64(len(data)), err
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	if err := validKey(key); err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return int64(len(data)), nil
+}
+
+func (m *Memory) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// Objects are only ever replaced or appended to, so sharing the slice is safe
+	return nopCloser{bytes.NewReader(data[:len(data):len(data)])}, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
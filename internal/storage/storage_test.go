@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := s.Append(ctx, "obj_1", strings.NewReader("hello ")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if _, err := s.Append(ctx, "obj_1", strings.NewReader("world")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	rc, err := s.Open(ctx, "obj_1")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello world" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+
+	if _, err := s.Put(ctx, "obj_1", strings.NewReader("replaced")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := s.Delete(ctx, "obj_1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Open(ctx, "obj_1"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if _, err := s.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Fatalf("expected invalid key to be rejected")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestDiskStore(t *testing.T) {
+	d, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatalf("new disk: %v", err)
+	}
+	testStore(t, d)
+}
@@ -0,0 +1,110 @@
+// Package streams tracks long-lived streaming responses (SSE, WebSocket) so that
+// shutdown can notify clients, refuse new streams, and wait for open ones to end.
+package streams
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// ErrDraining is returned by Open once the tracker has started draining.
+var ErrDraining = errors.New("server is draining; no new streams accepted")
+
+// Tracker keeps count of open streams and coordinates their termination.
+type Tracker struct {
+	mu       sync.Mutex
+	draining bool
+	active   map[*Stream]struct{}
+	idle     chan struct{} // closed when active drops to zero while draining
+}
+
+// Stream is a handle for one open streaming connection.
+type Stream struct {
+	t        *Tracker
+	shutdown chan struct{}
+	once     sync.Once
+}
+
+// Default is the process-wide tracker used by streaming handlers and main.
+var Default = NewTracker()
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{active: make(map[*Stream]struct{})}
+}
+
+// Open registers a new stream. Handlers must call Close when the stream ends and
+// should watch Shutdown to send a terminal event or close frame.
+func (t *Tracker) Open() (*Stream, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return nil, ErrDraining
+	}
+	s := &Stream{t: t, shutdown: make(chan struct{})}
+	t.active[s] = struct{}{}
+	metrics.SetStreamsActive(len(t.active))
+	return s, nil
+}
+
+// Active returns the number of open streams.
+func (t *Tracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.active)
+}
+
+// Draining reports whether the tracker has stopped accepting new streams.
+func (t *Tracker) Draining() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.draining
+}
+
+// Drain stops accepting new streams, signals every open stream to terminate and
+// waits until they have all closed or ctx is done. It returns the number of
+// streams still open when it gave up.
+func (t *Tracker) Drain(ctx context.Context) (int, error) {
+	t.mu.Lock()
+	if !t.draining {
+		t.draining = true
+		t.idle = make(chan struct{})
+		for s := range t.active {
+			close(s.shutdown)
+		}
+		if len(t.active) == 0 {
+			close(t.idle)
+		}
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return 0, nil
+	case <-ctx.Done():
+		return t.Active(), ctx.Err()
+	}
+}
+
+// Shutdown returns a channel that is closed when the server starts draining.
+func (s *Stream) Shutdown() <-chan struct{} {
+	return s.shutdown
+}
+
+// Close releases the stream. It is safe to call more than once.
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		t := s.t
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.active, s)
+		metrics.SetStreamsActive(len(t.active))
+		if t.draining && len(t.active) == 0 {
+			close(t.idle)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTracker_DrainNotifiesAndWaits(t *testing.T) {
+	tr := NewTracker()
+	s, err := tr.Open()
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	go func() {
+		<-s.Shutdown()
+		time.Sleep(20 * time.Millisecond) // simulate sending a terminal event
+		s.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	remaining, err := tr.Drain(ctx)
+	if err != nil || remaining != 0 {
+		t.Fatalf("expected clean drain, got remaining=%d err=%v", remaining, err)
+	}
+
+	if _, err := tr.Open(); !errors.Is(err, ErrDraining) {
+		t.Fatalf("expected ErrDraining after drain, got %v", err)
+	}
+}
+
+func TestTracker_DrainTimeoutReportsRemaining(t *testing.T) {
+	tr := NewTracker()
+	if _, err := tr.Open(); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	remaining, err := tr.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || remaining != 1 {
+		t.Fatalf("expected one stuck stream, got remaining=%d err=%v", remaining, err)
+	}
+}
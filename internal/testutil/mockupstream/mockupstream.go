@@ -0,0 +1,247 @@
+// Package mockupstream provides an in-process HTTP server with programmable
+// responses, latency, and failure injection for integration tests of code that
+// calls out to other services.
+package mockupstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Response describes what the mock upstream sends back for a request.
+type Response struct {
+	Status  int
+	Headers map[string]string
+	Body    []byte
+	// Delay is applied before the response is written (on top of the server latency).
+	Delay time.Duration
+	// Drop closes the connection without writing a response, simulating a network failure.
+	Drop bool
+}
+
+// JSON builds a Response with a JSON encoded body.
+func JSON(status int, v any) Response {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic("mockupstream: encode json: " + err.Error())
+	}
+	return Response{
+		Status:  status,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    body,
+	}
+}
+
+// Request is a snapshot of a request received by the mock upstream.
+type Request struct {
+	Method     string
+	Path       string
+	Query      string
+	Header     http.Header
+	Body       []byte
+	ReceivedAt time.Time
+}
+
+// Server is a programmable mock upstream backed by httptest.Server.
+type Server struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	defaults  map[string]Response
+	queued    map[string][]Response
+	latency   time.Duration
+	failNext  int
+	failResp  Response
+	requests  []Request
+	unmatched Response
+}
+
+// New starts a mock upstream that is closed automatically when the test ends.
+func New(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{
+		defaults:  make(map[string]Response),
+		queued:    make(map[string][]Response),
+		unmatched: JSON(http.StatusNotFound, map[string]string{"error": "not_found"}),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// URL returns the base URL of the mock upstream.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Client returns an HTTP client configured for the mock upstream.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// Close shuts the mock upstream down. It is safe to call more than once.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// On sets the response returned for every request matching method and path.
+func (s *Server) On(method, path string, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaults[routeKey(method, path)] = resp
+}
+
+// Enqueue registers one-shot responses consumed in order before falling back to
+// the response configured with On.
+func (s *Server) Enqueue(method, path string, resps ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := routeKey(method, path)
+	s.queued[key] = append(s.queued[key], resps...)
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailNext makes the next n requests, regardless of route, return resp.
+func (s *Server) FailNext(n int, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+	s.failResp = resp
+}
+
+// Reset clears configured responses, failure injection, and recorded requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaults = make(map[string]Response)
+	s.queued = make(map[string][]Response)
+	s.latency = 0
+	s.failNext = 0
+	s.requests = nil
+}
+
+// Requests returns all requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Request, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+// RequestsFor returns the requests received for method and path.
+func (s *Server) RequestsFor(method, path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Request
+	for _, req := range s.requests {
+		if req.Method == method && req.Path == path {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// AssertCalled fails the test unless method and path were requested exactly times.
+func (s *Server) AssertCalled(t testing.TB, method, path string, times int) {
+	t.Helper()
+	if got := len(s.RequestsFor(method, path)); got != times {
+		t.Fatalf("mockupstream: expected %s %s to be called %d times, got %d", method, path, times, got)
+	}
+}
+
+// AssertNotCalled fails the test if method and path were requested at all.
+func (s *Server) AssertNotCalled(t testing.TB, method, path string) {
+	t.Helper()
+	s.AssertCalled(t, method, path, 0)
+}
+
+// LastRequest returns the most recent request, failing the test if none was received.
+func (s *Server) LastRequest(t testing.TB) Request {
+	t.Helper()
+	reqs := s.Requests()
+	if len(reqs) == 0 {
+		t.Fatalf("mockupstream: no requests received")
+	}
+	return reqs[len(reqs)-1]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	resp, latency := s.record(r, body)
+
+	if wait := latency + resp.Delay; wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if resp.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, bytes.NewReader(resp.Body))
+}
+
+// record stores the request and picks the response to send under a single lock.
+func (s *Server) record(r *http.Request, body []byte) (Response, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Header:     r.Header.Clone(),
+		Body:       body,
+		ReceivedAt: time.Now(),
+	})
+
+	if s.failNext > 0 {
+		s.failNext--
+		return s.failResp, s.latency
+	}
+
+	key := routeKey(r.Method, r.URL.Path)
+	if q := s.queued[key]; len(q) > 0 {
+		s.queued[key] = q[1:]
+		return q[0], s.latency
+	}
+	if resp, ok := s.defaults[key]; ok {
+		return resp, s.latency
+	}
+	return s.unmatched, s.latency
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
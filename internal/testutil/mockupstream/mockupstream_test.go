@@ -0,0 +1,83 @@
+package mockupstream
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_ProgrammedResponses(t *testing.T) {
+	up := New(t)
+	up.On(http.MethodGet, "/status", JSON(http.StatusOK, map[string]string{"state": "up"}))
+	up.Enqueue(http.MethodGet, "/status", Response{Status: http.StatusServiceUnavailable})
+
+	resp, err := up.Client().Get(up.URL() + "/status")
+	if err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected queued 503, got %d", resp.StatusCode)
+	}
+
+	resp, err = up.Client().Get(up.URL() + "/status")
+	if err != nil {
+		t.Fatalf("second request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"up"`) {
+		t.Fatalf("expected default response, got %d %s", resp.StatusCode, body)
+	}
+
+	up.AssertCalled(t, http.MethodGet, "/status", 2)
+	up.AssertNotCalled(t, http.MethodPost, "/status")
+}
+
+func TestServer_RecordsRequests(t *testing.T) {
+	up := New(t)
+	up.On(http.MethodPost, "/hooks", Response{Status: http.StatusAccepted})
+
+	req, _ := http.NewRequest(http.MethodPost, up.URL()+"/hooks?source=test", strings.NewReader(`{"a":1}`))
+	req.Header.Set("X-Signature", "abc")
+	resp, err := up.Client().Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	got := up.LastRequest(t)
+	if got.Query != "source=test" || string(got.Body) != `{"a":1}` || got.Header.Get("X-Signature") != "abc" {
+		t.Fatalf("unexpected recorded request: %+v", got)
+	}
+}
+
+func TestServer_FailureInjection(t *testing.T) {
+	up := New(t)
+	up.On(http.MethodGet, "/", Response{Status: http.StatusOK})
+	up.FailNext(1, Response{Drop: true})
+
+	if _, err := up.Client().Get(up.URL() + "/"); err == nil {
+		t.Fatalf("expected dropped connection to error")
+	}
+	resp, err := up.Client().Get(up.URL() + "/")
+	if err != nil {
+		t.Fatalf("request after injected failure failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after failure injection, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	up := New(t)
+	up.SetLatency(50 * time.Millisecond)
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	if _, err := client.Get(up.URL() + "/slow"); err == nil {
+		t.Fatalf("expected client timeout due to injected latency")
+	}
+}
@@ -0,0 +1,285 @@
+// Package tokensource provides the credentials this service presents to other
+// services: OAuth2 client credentials, Kubernetes service account tokens and
+// cloud metadata server tokens, cached and refreshed before they expire.
+package tokensource
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Token is a credential for the Authorization header.
+type Token struct {
+	AccessToken string
+	TokenType   string    // header scheme; Bearer when empty
+	Expiry      time.Time // zero when unknown
+}
+
+// Header returns the Authorization header value.
+func (t Token) Header() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// Source returns a valid token.
+type Source interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// defaultClient fetches tokens. It must not be an outbound client that
+// attaches tokens itself.
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// ClientCredentials fetches tokens with the OAuth2 client credentials grant.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Audience     string // sent as audience when set
+	Client       *http.Client
+}
+
+func (c ClientCredentials) Token(ctx context.Context) (Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.Audience != "" {
+		form.Set("audience", c.Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	body, err := fetch(c.Client, req)
+	if err != nil {
+		return Token{}, err
+	}
+	return parseTokenResponse(body)
+}
+
+// File reads a token that is rotated on disk, such as a projected Kubernetes
+// service account token. The expiry is taken from the token's exp claim.
+type File struct {
+	Path string
+}
+
+// KubernetesTokenPath is where Kubernetes mounts the service account token.
+const KubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// fileRecheck is how often a token file without an exp claim is re-read.
+const fileRecheck = 5 * time.Minute
+
+func (f File) Token(ctx context.Context) (Token, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Token{}, err
+	}
+	tok := strings.TrimSpace(string(b))
+	if tok == "" {
+		return Token{}, fmt.Errorf("token file %s is empty", f.Path)
+	}
+	expiry := jwtExpiry(tok)
+	if expiry.IsZero() {
+		expiry = time.Now().Add(fileRecheck)
+	}
+	return Token{AccessToken: tok, Expiry: expiry}, nil
+}
+
+// GCPMetadata fetches tokens of the instance's service account from the GCP
+// metadata server: an ID token for Audience when set, otherwise an OAuth2
+// access token.
+type GCPMetadata struct {
+	Audience string
+	BaseURL  string // defaults to the metadata server
+	Client   *http.Client
+}
+
+func (g GCPMetadata) Token(ctx context.Context) (Token, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = "http://metadata.google.internal"
+	}
+	path := "/computeMetadata/v1/instance/service-accounts/default/token"
+	if g.Audience != "" {
+		path = "/computeMetadata/v1/instance/service-accounts/default/identity?format=full&audience=" + url.QueryEscape(g.Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := fetch(g.Client, req)
+	if err != nil {
+		return Token{}, err
+	}
+	if g.Audience != "" {
+		tok := strings.TrimSpace(string(body))
+		return Token{AccessToken: tok, Expiry: jwtExpiry(tok)}, nil
+	}
+	return parseTokenResponse(body)
+}
+
+// AWSMetadata fetches the instance's signed identity document (PKCS7) from the
+// EC2 instance metadata service using an IMDSv2 session. The receiving service
+// verifies it against the AWS public certificate.
+type AWSMetadata struct {
+	BaseURL string // defaults to the metadata service
+	Client  *http.Client
+}
+
+// awsDocumentLifetime is how long an identity document is reused. AWS does
+// not expire it, but receivers commonly reject old ones.
+const awsDocumentLifetime = time.Hour
+
+func (a AWSMetadata) Token(ctx context.Context) (Token, error) {
+	base := a.BaseURL
+	if base == "" {
+		base = "http://169.254.169.254"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, base+"/latest/api/token", nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	session, err := fetch(a.Client, req)
+	if err != nil {
+		return Token{}, fmt.Errorf("imds session: %w", err)
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, base+"/latest/dynamic/instance-identity/pkcs7", nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", strings.TrimSpace(string(session)))
+	doc, err := fetch(a.Client, req)
+	if err != nil {
+		return Token{}, err
+	}
+	pkcs7 := strings.ReplaceAll(strings.TrimSpace(string(doc)), "\n", "")
+	return Token{AccessToken: pkcs7, TokenType: "AWS-PKCS7", Expiry: time.Now().Add(awsDocumentLifetime)}, nil
+}
+
+// fetch performs req and returns the body of a 2xx response.
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("token endpoint %s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// parseTokenResponse parses an OAuth2 token response.
+func parseTokenResponse(body []byte) (Token, error) {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Token{}, fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return Token{}, errors.New("token response has no access_token")
+	}
+	tok := Token{AccessToken: resp.AccessToken, TokenType: resp.TokenType}
+	if resp.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// jwtExpiry returns the exp claim of a JWT, or zero. The signature is not
+// checked; the token is only being forwarded.
+func jwtExpiry(tok string) time.Time {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// Cached reuses the tokens of a Source until refreshBefore their expiry.
+// Tokens without an expiry are reused until Invalidate. When a refresh fails,
+// the current token is used for as long as it is still valid.
+type Cached struct {
+	src           Source
+	name          string
+	refreshBefore time.Duration
+	now           func() time.Time
+
+	mu  sync.Mutex
+	tok Token
+	ok  bool
+}
+
+// NewCached wraps src; name labels its refresh metrics.
+func NewCached(src Source, name string, refreshBefore time.Duration) *Cached {
+	return &Cached{src: src, name: name, refreshBefore: refreshBefore, now: time.Now}
+}
+
+func (c *Cached) Token(ctx context.Context) (Token, error) {
+	// Holding the lock while fetching makes concurrent callers share one refresh
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.ok && (c.tok.Expiry.IsZero() || now.Before(c.tok.Expiry.Add(-c.refreshBefore))) {
+		return c.tok, nil
+	}
+	tok, err := c.src.Token(ctx)
+	if err != nil {
+		metrics.ObserveTokenRefresh(c.name, err)
+		if c.ok && (c.tok.Expiry.IsZero() || now.Before(c.tok.Expiry)) {
+			return c.tok, nil
+		}
+		return Token{}, err
+	}
+	metrics.ObserveTokenRefresh(c.name, nil)
+	c.tok, c.ok = tok, true
+	return tok, nil
+}
+
+// Invalidate drops the cached token, e.g. after the receiver rejected it.
+func (c *Cached) Invalidate() {
+	c.mu.Lock()
+	c.ok = false
+	c.mu.Unlock()
+}
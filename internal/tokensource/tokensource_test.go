@@ -0,0 +1,101 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "a b" || id != "svc" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"tok","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer srv.Close()
+
+	tok, err := ClientCredentials{TokenURL: srv.URL, ClientID: "svc", ClientSecret: "s3cret", Scopes: []string{"a", "b"}}.Token(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok.Header() != "Bearer tok" || time.Until(tok.Expiry) < 59*time.Minute {
+		t.Fatalf("unexpected token: %+v", tok)
+	}
+}
+
+func TestFile_ReadsJWTExpiry(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp)))
+	path := filepath.Join(t.TempDir(), "token")
+	_ = os.WriteFile(path, []byte("hdr."+payload+".sig\n"), 0o600)
+
+	tok, err := File{Path: path}.Token(context.Background())
+	if err != nil || tok.AccessToken != "hdr."+payload+".sig" || tok.Expiry.Unix() != exp {
+		t.Fatalf("unexpected token %+v (%v)", tok, err)
+	}
+}
+
+type countingSource struct {
+	calls int
+	ttl   time.Duration
+	err   error
+}
+
+func (s *countingSource) Token(ctx context.Context) (Token, error) {
+	s.calls++
+	if s.err != nil {
+		return Token{}, s.err
+	}
+	return Token{AccessToken: fmt.Sprintf("tok-%d", s.calls), Expiry: time.Unix(1000, 0).Add(s.ttl)}, nil
+}
+
+func TestCached_RefreshesBeforeExpiry(t *testing.T) {
+	src := &countingSource{ttl: 10 * time.Minute}
+	c := NewCached(src, "test", time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	tok, _ := c.Token(context.Background())
+	again, _ := c.Token(context.Background())
+	if tok.AccessToken != "tok-1" || again.AccessToken != "tok-1" {
+		t.Fatalf("expected the token to be reused, got %s then %s", tok.AccessToken, again.AccessToken)
+	}
+
+	now = now.Add(9*time.Minute + time.Second) // inside the refresh window
+	if tok, _ = c.Token(context.Background()); tok.AccessToken != "tok-2" {
+		t.Fatalf("expected a refresh before expiry, got %s", tok.AccessToken)
+	}
+
+	c.Invalidate()
+	if tok, _ = c.Token(context.Background()); tok.AccessToken != "tok-3" {
+		t.Fatalf("expected a refresh after Invalidate, got %s", tok.AccessToken)
+	}
+}
+
+func TestCached_KeepsValidTokenWhenRefreshFails(t *testing.T) {
+	src := &countingSource{ttl: 10 * time.Minute}
+	c := NewCached(src, "test", time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	_, _ = c.Token(context.Background())
+
+	src.err = errors.New("token endpoint down")
+	now = now.Add(9*time.Minute + time.Second)
+	if tok, err := c.Token(context.Background()); err != nil || tok.AccessToken != "tok-1" {
+		t.Fatalf("expected the still valid token, got %+v (%v)", tok, err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := c.Token(context.Background()); err == nil {
+		t.Fatal("expected an error once the token has expired")
+	}
+}
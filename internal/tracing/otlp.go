@@ -0,0 +1,303 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+)
+
+// Exporter limits; a full queue drops spans rather than slowing requests.
+const (
+	queueSize      = 2048
+	batchSize      = 512
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+)
+
+// ExporterOptions configure an Exporter.
+type ExporterOptions struct {
+	Endpoint    string            // collector base URL; spans are posted to Endpoint/v1/traces
+	Headers     map[string]string // sent with every export, e.g. an API key
+	ServiceName string            // the service.name resource attribute
+	Logger      *slog.Logger
+	Client      *http.Client // defaults to a plain client; not the instrumented one, whose calls would be traced
+}
+
+// Exporter sends finished spans in batches to an OTLP/HTTP collector, JSON
+// encoded.
+type Exporter struct {
+	url     string
+	headers map[string]string
+	service string
+	logger  *slog.Logger
+	client  *http.Client
+
+	queue     chan *Span
+	flush     chan chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewExporter starts an exporter; stop it with Shutdown.
+func NewExporter(o ExporterOptions) *Exporter {
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: exportTimeout}
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	e := &Exporter{
+		url:     strings.TrimSuffix(o.Endpoint, "/") + "/v1/traces",
+		headers: o.Headers,
+		service: o.ServiceName,
+		logger:  o.Logger,
+		client:  o.Client,
+		queue:   make(chan *Span, queueSize),
+		flush:   make(chan chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *Exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		metrics.ObserveSpans("dropped", 1)
+	}
+}
+
+// Flush exports the spans queued so far.
+func (e *Exporter) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the queued spans and stops the exporter, giving up when
+// ctx is done.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("spans not exported: %w", ctx.Err())
+	}
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, batchSize)
+	send := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case s := <-e.queue:
+				if batch = append(batch, s); len(batch) == batchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) == batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			drain()
+			close(ack)
+		case <-e.stop:
+			drain()
+			return
+		}
+	}
+}
+
+func (e *Exporter) export(spans []*Span) {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		e.logger.Error("encode spans failed", slog.String("error", err.Error()))
+		metrics.ObserveSpans("failed", len(spans))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		e.logger.Error("export spans failed", slog.String("error", err.Error()))
+		metrics.ObserveSpans("failed", len(spans))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err == nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = errors.New("collector answered " + resp.Status)
+		}
+	}
+	if err != nil {
+		e.logger.Warn("export spans failed", slog.String("url", e.url), slog.Int("spans", len(spans)), slog.String("error", err.Error()))
+		metrics.ObserveSpans("failed", len(spans))
+		return
+	}
+	metrics.ObserveSpans("exported", len(spans))
+}
+
+// The OTLP/JSON request, see opentelemetry-proto's trace service. IDs are
+// hex and 64-bit integers decimal strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 2 is error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+const instrumentationScope = "github.com/mikko-kohtala/go-api"
+
+func (e *Exporter) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		os := otlpSpan{
+			TraceID:           s.sc.TraceID.String(),
+			SpanID:            s.sc.SpanID.String(),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(s.end),
+			Attributes:        keyValues(s.attrs),
+		}
+		if s.parent.IsValid() {
+			os.ParentSpanID = s.parent.String()
+		}
+		for _, ev := range s.events {
+			os.Events = append(os.Events, otlpEvent{TimeUnixNano: unixNano(ev.time), Name: ev.name, Attributes: keyValues(ev.attrs)})
+		}
+		if s.failed {
+			os.Status = otlpStatus{Code: 2, Message: s.statusMsg}
+		}
+		s.mu.Unlock()
+		out = append(out, os)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: keyValues([]slog.Attr{slog.String("service.name", e.service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: out}},
+	}}}
+}
+
+func unixNano(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+// keyValues converts attributes to OTLP, flattening groups into dotted keys.
+func keyValues(attrs []slog.Attr) []otlpKeyValue {
+	var out []otlpKeyValue
+	var add func(prefix string, a slog.Attr)
+	add = func(prefix string, a slog.Attr) {
+		v := a.Value.Resolve()
+		key := prefix + a.Key
+		switch v.Kind() {
+		case slog.KindGroup:
+			for _, g := range v.Group() {
+				add(key+".", g)
+			}
+		case slog.KindBool:
+			out = append(out, otlpKeyValue{key, map[string]any{"boolValue": v.Bool()}})
+		case slog.KindInt64:
+			out = append(out, otlpKeyValue{key, map[string]any{"intValue": strconv.FormatInt(v.Int64(), 10)}})
+		case slog.KindUint64:
+			out = append(out, otlpKeyValue{key, map[string]any{"intValue": strconv.FormatUint(v.Uint64(), 10)}})
+		case slog.KindFloat64:
+			out = append(out, otlpKeyValue{key, map[string]any{"doubleValue": v.Float64()}})
+		case slog.KindDuration:
+			out = append(out, otlpKeyValue{key, map[string]any{"intValue": strconv.FormatInt(v.Duration().Nanoseconds(), 10)}})
+		default:
+			out = append(out, otlpKeyValue{key, map[string]any{"stringValue": v.String()}})
+		}
+	}
+	for _, a := range attrs {
+		add("", a)
+	}
+	return out
+}
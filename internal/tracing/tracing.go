@@ -0,0 +1,325 @@
+// Package tracing records traces of the requests the API serves and the work
+// they do, and exports them to an OpenTelemetry collector over OTLP/HTTP with
+// JSON encoding, without the OpenTelemetry SDK. It covers what the API needs:
+// W3C trace context propagation, spans with attributes, errors recorded as
+// exception events, ratio sampling and a batching exporter.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is what a span passes on to its children, in this process or,
+// as a traceparent header, in others.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether sc has a trace and span ID.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Header is the W3C trace context header.
+const Header = "traceparent"
+
+// Traceparent formats sc as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value of version 00, or of a
+// later version as far as 00 defines it.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Kind is the role of a span, with the values of the OTLP enum.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Span is one timed operation of a trace. A span that is not sampled only
+// carries its context: its other methods do nothing. All methods are safe on
+// a nil *Span.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	kind   Kind
+	start  time.Time
+
+	mu        sync.Mutex
+	name      string
+	end       time.Time
+	attrs     []slog.Attr
+	events    []event
+	failed    bool
+	statusMsg string
+}
+
+type event struct {
+	name  string
+	time  time.Time
+	attrs []slog.Attr
+}
+
+func (s *Span) recording() bool {
+	return s != nil && s.tracer != nil && s.tracer.exporter != nil && s.sc.Sampled
+}
+
+// SpanContext returns the context of s.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName renames s, e.g. once the route a request matched is known.
+func (s *Span) SetName(name string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes adds attributes to s. Groups are flattened into dotted keys.
+func (s *Span) SetAttributes(attrs ...slog.Attr) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError adds err to s as an exception event and marks s as failed.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.events = append(s.events, event{name: "exception", time: time.Now(), attrs: []slog.Attr{slog.String("exception.message", err.Error())}})
+	s.failed, s.statusMsg = true, err.Error()
+	s.mu.Unlock()
+}
+
+// SetFailed marks s as failed without an error value, e.g. for a 5xx
+// response.
+func (s *Span) SetFailed(msg string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.failed, s.statusMsg = true, msg
+	s.mu.Unlock()
+}
+
+// End finishes s and hands it to the exporter. Only the first call counts.
+func (s *Span) End() {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.exporter.enqueue(s)
+}
+
+// Tracer starts spans, sampling new traces at a ratio and exporting sampled
+// spans.
+type Tracer struct {
+	exporter *Exporter
+	ratio    float64
+}
+
+// Default starts the spans of this process. It exports nothing until main
+// replaces it with one built by New.
+var Default = &Tracer{}
+
+// New returns a tracer recording the given share (0 to 1) of new traces and
+// handing their spans to exporter. Traces continued from a caller keep the
+// caller's sampling decision.
+func New(exporter *Exporter, ratio float64) *Tracer {
+	return &Tracer{exporter: exporter, ratio: ratio}
+}
+
+// Enabled reports whether t exports spans.
+func (t *Tracer) Enabled() bool { return t != nil && t.exporter != nil }
+
+// Shutdown exports the spans still queued, giving up when ctx is done.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if !t.Enabled() {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// Start starts a span named name as a child of the span in ctx, or of the
+// remote parent set with ContextWithRemoteParent, or as the root of a new
+// trace. End it when the operation is done. A tracer without an exporter
+// starts no span and returns ctx and nil.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind, attrs ...slog.Attr) (context.Context, *Span) {
+	if !t.Enabled() {
+		return ctx, nil
+	}
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	var psc SpanContext
+	if ok {
+		psc = parent.SpanContext()
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		psc = remote
+	}
+
+	s := &Span{tracer: t, kind: kind, start: time.Now(), name: name}
+	s.sc.SpanID = newSpanID()
+	if psc.IsValid() {
+		s.sc.TraceID, s.sc.Sampled, s.parent = psc.TraceID, psc.Sampled, psc.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.sample(s.sc.TraceID)
+	}
+	if s.recording() {
+		s.attrs = attrs
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// sample decides from the trace ID, so every service sampling at the same
+// ratio makes the same decision for a trace.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	if t.ratio <= 0 {
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:])>>11 < uint64(t.ratio*(1<<53))
+}
+
+// Start starts an internal span in the tracer of the span in ctx, or in
+// Default, for work done on behalf of a request, such as a service call.
+func Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, *Span) {
+	t := Default
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent.tracer != nil {
+		t = parent.tracer
+	}
+	return t.Start(ctx, name, KindInternal, attrs...)
+}
+
+// RecordError records err on the span in ctx.
+func RecordError(ctx context.Context, err error) {
+	SpanFromContext(ctx).RecordError(err)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the context of the span in ctx; it is not
+// valid when there is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return SpanFromContext(ctx).SpanContext()
+}
+
+// ContextWithRemoteParent returns ctx with sc as the parent of the next span
+// started, for a trace continued from another process.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Extract returns ctx with the remote parent carried in the traceparent
+// header of h, if any.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	if sc, ok := ParseTraceparent(h.Get(Header)); ok {
+		return ContextWithRemoteParent(ctx, sc)
+	}
+	return ctx
+}
+
+// Inject sets the traceparent header of h to the span in ctx, so the
+// receiver continues its trace.
+func Inject(ctx context.Context, h http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		h.Set(Header, sc.Traceparent())
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
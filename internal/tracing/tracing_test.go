@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(tp)
+	if !ok || !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected span context %+v", sc)
+	}
+	if sc.Traceparent() != tp {
+		t.Fatalf("round trip gave %q", sc.Traceparent())
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Fatal("a later version with more fields should parse")
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("%q: expected it rejected", bad)
+		}
+	}
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	exp := NewExporter(ExporterOptions{Endpoint: "http://127.0.0.1:0"})
+	defer exp.Shutdown(context.Background())
+
+	never := New(exp, 0)
+	_, span := never.Start(context.Background(), "op", KindServer)
+	if !span.SpanContext().IsValid() || span.SpanContext().Sampled || span.recording() {
+		t.Fatalf("ratio 0 should start an unsampled span, got %+v", span.SpanContext())
+	}
+
+	// A caller's decision wins over the ratio
+	parent := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	_, span = never.Start(ContextWithRemoteParent(context.Background(), parent), "op", KindServer)
+	if sc := span.SpanContext(); !sc.Sampled || sc.TraceID != parent.TraceID || span.parent != parent.SpanID {
+		t.Fatalf("remote parent not continued: %+v", sc)
+	}
+
+	half := New(exp, 0.5)
+	sampled := 0
+	for range 1000 {
+		if half.sample(newTraceID()) {
+			sampled++
+		}
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Fatalf("ratio 0.5 sampled %d of 1000", sampled)
+	}
+
+	if ctx, span := Default.Start(context.Background(), "op", KindServer); span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("a tracer without an exporter should start no span")
+	}
+}
+
+func TestExporter_PostsOTLPJSON(t *testing.T) {
+	got := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Api-Key") != "k" {
+			t.Errorf("unexpected export %s %s %v", r.Method, r.URL.Path, r.Header)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		got <- req
+	}))
+	defer collector.Close()
+
+	exp := NewExporter(ExporterOptions{Endpoint: collector.URL + "/", Headers: map[string]string{"api-key": "k"}, ServiceName: "test-api"})
+	tracer := New(exp, 1)
+	ctx, server := tracer.Start(context.Background(), "GET", KindServer, slog.String("http.request.method", "GET"))
+	ctx, child := Start(ctx, "UserService.Get", slog.Group("user", slog.Int("count", 2)))
+	RecordError(ctx, errors.New("not found"))
+	child.End()
+	server.SetName("GET /users/{id}")
+	server.End()
+	server.End()
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-got
+	rs := req.ResourceSpans[0]
+	if rs.Resource.Attributes[0].Key != "service.name" || rs.Resource.Attributes[0].Value["stringValue"] != "test-api" {
+		t.Fatalf("unexpected resource %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", spans)
+	}
+	c, s := spans[0], spans[1]
+	if s.Name != "GET /users/{id}" || s.Kind != KindServer || s.ParentSpanID != "" || s.Status.Code != 0 {
+		t.Fatalf("unexpected server span %+v", s)
+	}
+	if c.TraceID != s.TraceID || c.ParentSpanID != s.SpanID || c.Kind != KindInternal {
+		t.Fatalf("child not linked to its parent: %+v", c)
+	}
+	if c.Status.Code != 2 || c.Status.Message != "not found" || len(c.Events) != 1 || c.Events[0].Name != "exception" {
+		t.Fatalf("error not recorded: %+v", c)
+	}
+	if a := c.Attributes[0]; a.Key != "user.count" || a.Value["intValue"] != "2" {
+		t.Fatalf("unexpected attribute %+v", a)
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	h := http.Header{}
+	Inject(context.Background(), h)
+	if h.Get(Header) != "" {
+		t.Fatal("injected without a span")
+	}
+	exp := NewExporter(ExporterOptions{Endpoint: "http://127.0.0.1:0"})
+	defer exp.Shutdown(context.Background())
+	ctx, span := New(exp, 0).Start(context.Background(), "op", KindServer)
+	Inject(ctx, h)
+	if sc, ok := ParseTraceparent(h.Get(Header)); !ok || sc != span.SpanContext() {
+		t.Fatalf("unexpected traceparent %q", h.Get(Header))
+	}
+	ctx, next := New(exp, 1).Start(Extract(context.Background(), h), "op", KindServer)
+	if next.SpanContext().TraceID != span.SpanContext().TraceID || next.SpanContext().Sampled || SpanFromContext(ctx) != next {
+		t.Fatalf("trace not continued: %+v", next.SpanContext())
+	}
+}
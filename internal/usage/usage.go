@@ -0,0 +1,197 @@
+// Package usage keeps rolling per-consumer request statistics (request count,
+// error rate and data volume) as the basis for rate plans and billing.
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Anonymous is the key for requests that carry no API key.
+const Anonymous = "anonymous"
+
+// Overflow collects usage once MaxKeys distinct keys are tracked, so random
+// keys cannot grow the store without bound.
+const Overflow = "other"
+
+// buckets is the number of slots the window is divided into.
+const buckets = 60
+
+// KeyFromRequest identifies the consumer of r: a fingerprint of its X-API-Key
+// header, so raw keys are never stored or echoed, or Anonymous.
+func KeyFromRequest(r *http.Request) string {
+	k := r.Header.Get("X-API-Key")
+	if k == "" {
+		return Anonymous
+	}
+	sum := sha256.Sum256([]byte(k))
+	return "key_" + hex.EncodeToString(sum[:8])
+}
+
+// Usage summarises one key's traffic over the tracker window.
+type Usage struct {
+	Key       string  `json:"key,omitempty"`
+	Window    string  `json:"window"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"` // responses with status >= 400
+	ErrorRate float64 `json:"error_rate"`
+	BytesIn   int64   `json:"bytes_in"`
+	BytesOut  int64   `json:"bytes_out"`
+}
+
+type counts struct {
+	requests, errors, bytesIn, bytesOut int64
+}
+
+// series is a ring of per-slot counts; slot[i] is valid when stamp[i] matches
+// the absolute slot number it was written for.
+type series struct {
+	slot  [buckets]counts
+	stamp [buckets]int64
+}
+
+// Tracker aggregates usage per key over a rolling window.
+type Tracker struct {
+	window  time.Duration
+	res     time.Duration
+	maxKeys int
+	now     func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*series
+}
+
+// NewTracker creates a tracker covering window that tracks at most maxKeys
+// keys (no limit when maxKeys <= 0).
+func NewTracker(window time.Duration, maxKeys int) *Tracker {
+	res := window / buckets
+	if res < time.Second {
+		res = time.Second
+	}
+	return &Tracker{
+		window:  res * buckets,
+		res:     res,
+		maxKeys: maxKeys,
+		now:     time.Now,
+		keys:    make(map[string]*series),
+	}
+}
+
+// Window returns the period covered by Usage.
+func (t *Tracker) Window() time.Duration {
+	return t.window
+}
+
+// Record adds one request with the given response status and body sizes.
+func (t *Tracker) Record(key string, status int, bytesIn, bytesOut int64) {
+	abs := t.now().UnixNano() / int64(t.res)
+	i := abs % buckets
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.keys[key]
+	if !ok {
+		if t.maxKeys > 0 && len(t.keys) >= t.maxKeys {
+			t.pruneLocked(abs)
+		}
+		if t.maxKeys > 0 && len(t.keys) >= t.maxKeys {
+			key = Overflow
+		}
+		if s, ok = t.keys[key]; !ok {
+			s = &series{}
+			t.keys[key] = s
+		}
+	}
+	if s.stamp[i] != abs {
+		s.slot[i], s.stamp[i] = counts{}, abs
+	}
+	c := &s.slot[i]
+	c.requests++
+	if status >= 400 {
+		c.errors++
+	}
+	c.bytesIn += bytesIn
+	c.bytesOut += bytesOut
+}
+
+// Usage returns the usage of key over the window.
+func (t *Tracker) Usage(key string) Usage {
+	abs := t.now().UnixNano() / int64(t.res)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var c counts
+	if s, ok := t.keys[key]; ok {
+		c = s.sum(abs)
+	}
+	return t.usage(key, c)
+}
+
+// All returns the usage of every key active within the window, busiest first,
+// and the total across them.
+func (t *Tracker) All() ([]Usage, Usage) {
+	abs := t.now().UnixNano() / int64(t.res)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pruneLocked(abs)
+
+	out := make([]Usage, 0, len(t.keys))
+	var total counts
+	for key, s := range t.keys {
+		c := s.sum(abs)
+		total.add(c)
+		out = append(out, t.usage(key, c))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, t.usage("", total)
+}
+
+// pruneLocked drops keys with no requests inside the window. Callers hold t.mu.
+func (t *Tracker) pruneLocked(abs int64) {
+	for key, s := range t.keys {
+		if s.sum(abs).requests == 0 {
+			delete(t.keys, key)
+		}
+	}
+}
+
+func (t *Tracker) usage(key string, c counts) Usage {
+	u := Usage{
+		Key:      key,
+		Window:   t.window.String(),
+		Requests: c.requests,
+		Errors:   c.errors,
+		BytesIn:  c.bytesIn,
+		BytesOut: c.bytesOut,
+	}
+	if c.requests > 0 {
+		u.ErrorRate = float64(c.errors) / float64(c.requests)
+	}
+	return u
+}
+
+// sum totals the slots that fall inside the window ending at slot abs.
+func (s *series) sum(abs int64) counts {
+	var c counts
+	for i := range s.slot {
+		if abs-s.stamp[i] < buckets {
+			c.add(s.slot[i])
+		}
+	}
+	return c
+}
+
+func (c *counts) add(o counts) {
+	c.requests += o.requests
+	c.errors += o.errors
+	c.bytesIn += o.bytesIn
+	c.bytesOut += o.bytesOut
+}
@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker_RollingWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tr := NewTracker(time.Hour, 0)
+	tr.now = func() time.Time { return now }
+
+	tr.Record("key_a", 200, 10, 100)
+	tr.Record("key_a", 500, 0, 20)
+	now = now.Add(30 * time.Minute)
+	tr.Record("key_a", 404, 0, 5)
+	tr.Record("key_b", 200, 0, 1)
+
+	u := tr.Usage("key_a")
+	if u.Requests != 3 || u.Errors != 2 || u.BytesIn != 10 || u.BytesOut != 125 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if u.ErrorRate < 0.66 || u.ErrorRate > 0.67 || u.Window != "1h0m0s" {
+		t.Fatalf("unexpected rate/window: %+v", u)
+	}
+
+	// The first two requests fall out of the window
+	now = now.Add(31 * time.Minute)
+	if u := tr.Usage("key_a"); u.Requests != 1 {
+		t.Fatalf("expected 1 request in window, got %+v", u)
+	}
+
+	all, total := tr.All()
+	if len(all) != 2 || total.Requests != 2 || all[0].Key != "key_a" {
+		t.Fatalf("unexpected aggregate: %+v total %+v", all, total)
+	}
+	now = now.Add(2 * time.Hour)
+	if all, _ := tr.All(); len(all) != 0 {
+		t.Fatalf("expected idle keys to be pruned, got %+v", all)
+	}
+}
+
+func TestTracker_OverflowKey(t *testing.T) {
+	tr := NewTracker(time.Hour, 1)
+	tr.Record("key_a", 200, 0, 0)
+	tr.Record("key_b", 200, 0, 0)
+	if u := tr.Usage(Overflow); u.Requests != 1 {
+		t.Fatalf("expected the second key to be counted as %q, got %+v", Overflow, u)
+	}
+}
+
+func TestKeyFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if k := KeyFromRequest(req); k != Anonymous {
+		t.Fatalf("expected anonymous, got %q", k)
+	}
+	req.Header.Set("X-API-Key", "secret-key")
+	k := KeyFromRequest(req)
+	if !strings.HasPrefix(k, "key_") || strings.Contains(k, "secret") {
+		t.Fatalf("expected fingerprinted key, got %q", k)
+	}
+}
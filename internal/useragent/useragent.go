@@ -0,0 +1,141 @@
+// Package useragent classifies User-Agent headers into coarse client classes
+// (browser, bot, CLI/library) suitable for logs, metrics, and traffic policy.
+package useragent
+
+import (
+	"context"
+	"strings"
+)
+
+// Class is a low-cardinality client classification.
+type Class string
+
+const (
+	ClassBrowser Class = "browser"
+	ClassBot     Class = "bot"
+	ClassCLI     Class = "cli"
+	ClassUnknown Class = "unknown"
+)
+
+// Info is the parsed form of a User-Agent header.
+type Info struct {
+	Class   Class  `json:"class"`
+	Browser string `json:"browser,omitempty"`
+	OS      string `json:"os,omitempty"`
+}
+
+// Identified reports whether the client could be attributed to a known browser,
+// crawler, or tool. Unidentified clients are candidates for stricter policy.
+func (i Info) Identified() bool {
+	return i.Class != ClassUnknown
+}
+
+type token struct {
+	needle string
+	name   string
+}
+
+// Order matters: the first match wins.
+var (
+	knownBots = []token{
+		{"googlebot", "Googlebot"},
+		{"bingbot", "Bingbot"},
+		{"duckduckbot", "DuckDuckBot"},
+		{"yandexbot", "YandexBot"},
+		{"baiduspider", "Baiduspider"},
+		{"applebot", "Applebot"},
+		{"facebookexternalhit", "Facebook"},
+		{"twitterbot", "Twitterbot"},
+		{"slackbot", "Slackbot"},
+		{"headlesschrome", "HeadlessChrome"},
+	}
+	genericBotMarkers = []string{"bot", "crawler", "spider", "slurp", "scraper"}
+
+	cliTools = []token{
+		{"curl/", "curl"},
+		{"wget/", "Wget"},
+		{"httpie/", "HTTPie"},
+		{"go-http-client/", "Go"},
+		{"python-requests/", "python-requests"},
+		{"python-urllib/", "Python"},
+		{"okhttp/", "OkHttp"},
+		{"axios/", "axios"},
+		{"node-fetch", "node-fetch"},
+		{"postmanruntime/", "Postman"},
+		{"insomnia/", "Insomnia"},
+		{"k6/", "k6"},
+	}
+
+	browsers = []token{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"crios/", "Chrome"},
+		{"safari/", "Safari"},
+	}
+
+	systems = []token{
+		{"windows", "Windows"},
+		{"android", "Android"},
+		{"iphone", "iOS"},
+		{"ipad", "iOS"},
+		{"mac os x", "macOS"},
+		{"cros", "ChromeOS"},
+		{"linux", "Linux"},
+	}
+)
+
+// Parse classifies a raw User-Agent string.
+func Parse(ua string) Info {
+	lower := strings.ToLower(strings.TrimSpace(ua))
+	if lower == "" {
+		return Info{Class: ClassUnknown}
+	}
+	osName := match(lower, systems)
+
+	if name := match(lower, knownBots); name != "" {
+		return Info{Class: ClassBot, Browser: name, OS: osName}
+	}
+	for _, m := range genericBotMarkers {
+		if strings.Contains(lower, m) {
+			return Info{Class: ClassBot, OS: osName}
+		}
+	}
+	for _, t := range cliTools {
+		if strings.HasPrefix(lower, t.needle) {
+			return Info{Class: ClassCLI, Browser: t.name}
+		}
+	}
+	if strings.HasPrefix(lower, "mozilla/") {
+		if name := match(lower, browsers); name != "" {
+			return Info{Class: ClassBrowser, Browser: name, OS: osName}
+		}
+	}
+	return Info{Class: ClassUnknown, OS: osName}
+}
+
+func match(s string, tokens []token) string {
+	for _, t := range tokens {
+		if strings.Contains(s, t.needle) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+type ctxKey struct{}
+
+// IntoContext stores parsed client info in the context.
+func IntoContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext returns the client info stored by the classification middleware.
+// Requests that were never classified report ClassUnknown.
+func FromContext(ctx context.Context) Info {
+	if info, ok := ctx.Value(ctxKey{}).(Info); ok {
+		return info
+	}
+	return Info{Class: ClassUnknown}
+}
@@ -0,0 +1,30 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		ua      string
+		class   Class
+		browser string
+		os      string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", ClassBrowser, "Chrome", "Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15", ClassBrowser, "Safari", "macOS"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", ClassBrowser, "Firefox", "Linux"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0", ClassBrowser, "Edge", "Windows"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36", ClassBrowser, "Chrome", "Android"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", ClassBot, "Googlebot", ""},
+		{"SomeCrawler/1.0", ClassBot, "", ""},
+		{"curl/8.4.0", ClassCLI, "curl", ""},
+		{"Go-http-client/1.1", ClassCLI, "Go", ""},
+		{"TestClient/1.0", ClassUnknown, "", ""},
+		{"", ClassUnknown, "", ""},
+	}
+	for _, tc := range cases {
+		got := Parse(tc.ua)
+		if got.Class != tc.class || got.Browser != tc.browser || got.OS != tc.os {
+			t.Errorf("Parse(%q) = %+v, want class=%s browser=%s os=%s", tc.ua, got, tc.class, tc.browser, tc.os)
+		}
+	}
+}
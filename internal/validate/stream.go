@@ -0,0 +1,141 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// defaultStreamMaxErrors is used when StreamOptions.MaxErrors is zero.
+const defaultStreamMaxErrors = 10
+
+var (
+	// ErrNotArray is returned when a streamed body is not a JSON array.
+	ErrNotArray = errors.New("expected a JSON array")
+	// ErrTooManyItems is returned when a streamed array exceeds StreamOptions.MaxItems.
+	ErrTooManyItems = errors.New("too many items")
+)
+
+// StreamOptions controls DecodeStream.
+type StreamOptions struct {
+	// MaxErrors aborts processing once this many items have failed (default 10).
+	MaxErrors int
+	// MaxItems rejects bodies with more elements than this; 0 means unlimited.
+	MaxItems int
+	// Stop, when set, is called before each element; a non-nil error ends
+	// processing and is returned. Handlers use it to stop once the client has
+	// gone (see requestctx.Check).
+	Stop func() error
+}
+
+// ItemError describes why one array element was rejected. Violation
+// pointers are relative to the element.
+type ItemError struct {
+	Index      int               `json:"index"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Violations Errors            `json:"violations,omitempty"`
+	Message    string            `json:"message,omitempty"`
+}
+
+// StreamResult summarises a streamed decode.
+type StreamResult struct {
+	Processed int         `json:"processed"`
+	Accepted  int         `json:"accepted"`
+	Errors    []ItemError `json:"errors,omitempty"`
+	Aborted   bool        `json:"aborted"`
+}
+
+// DecodeStream reads a JSON array from r one element at a time, so memory use is
+// bounded by the largest element rather than the whole body. Each element is
+// decoded (rejecting unknown fields), validated, and passed to fn if valid.
+// Validation failures and errors returned by fn are collected per item;
+// processing stops after opts.MaxErrors of them. A returned error means the
+// stream itself was malformed or could not be read.
+func DecodeStream[T any](r io.Reader, opts StreamOptions, fn func(index int, item *T) error) (StreamResult, error) {
+	maxErrors := opts.MaxErrors
+	if maxErrors <= 0 {
+		maxErrors = defaultStreamMaxErrors
+	}
+
+	var res StreamResult
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	tok, err := dec.Token()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return res, ErrEmptyBody
+		}
+		return res, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return res, ErrNotArray
+	}
+
+	for index := 0; dec.More(); index++ {
+		if opts.Stop != nil {
+			if err := opts.Stop(); err != nil {
+				return res, err
+			}
+		}
+		if opts.MaxItems > 0 && index >= opts.MaxItems {
+			return res, fmt.Errorf("%w: limit is %d", ErrTooManyItems, opts.MaxItems)
+		}
+
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) || isUnknownFieldError(err) {
+				// The element was consumed; record it and keep going
+				res.Processed++
+				res.Errors = append(res.Errors, ItemError{Index: index, Message: err.Error()})
+				if len(res.Errors) >= maxErrors {
+					res.Aborted = true
+					return res, nil
+				}
+				continue
+			}
+			return res, err
+		}
+		res.Processed++
+
+		if errs := validateItem(&item); errs != nil {
+			res.Errors = append(res.Errors, ItemError{Index: index, Fields: errs.Fields(), Violations: errs})
+		} else if err := fn(index, &item); err != nil {
+			res.Errors = append(res.Errors, ItemError{Index: index, Message: err.Error()})
+		} else {
+			res.Accepted++
+		}
+		if len(res.Errors) >= maxErrors {
+			res.Aborted = true
+			return res, nil
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func validateItem(item any) Errors {
+	err := v.Struct(item)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return Errors{{Rule: "invalid", Message: err.Error()}}
+	}
+	return violations(item, verrs)
+}
+
+// isUnknownFieldError detects DisallowUnknownFields failures, which encoding/json
+// does not expose as a typed error.
+func isUnknownFieldError(err error) bool {
+	return strings.HasPrefix(err.Error(), "json: unknown field ")
+}
@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeStream_ValidatesEachItem(t *testing.T) {
+	body := `[{"email":"a@example.com"},{"email":"nope"},{"email":"b@example.com","extra":1},{"email":"c@example.com"}]`
+	var seen []string
+	res, err := DecodeStream(strings.NewReader(body), StreamOptions{}, func(i int, s *sample) error {
+		seen = append(seen, s.Email)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Processed != 4 || res.Accepted != 2 || len(res.Errors) != 2 || res.Aborted {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Errors[0].Index != 1 || res.Errors[0].Fields["email"] == "" || res.Errors[1].Index != 2 {
+		t.Fatalf("unexpected item errors: %+v", res.Errors)
+	}
+	if strings.Join(seen, ",") != "a@example.com,c@example.com" {
+		t.Fatalf("unexpected accepted items: %v", seen)
+	}
+}
+
+func TestDecodeStream_AbortsAfterMaxErrors(t *testing.T) {
+	body := `[{"email":"x"},{"email":"y"},{"email":"z"},{"email":"ok@example.com"}]`
+	res, err := DecodeStream(strings.NewReader(body), StreamOptions{MaxErrors: 2}, func(int, *sample) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Aborted || res.Processed != 2 || res.Accepted != 0 {
+		t.Fatalf("expected abort after two errors, got %+v", res)
+	}
+}
+
+func TestDecodeStream_CallbackErrorsAndShape(t *testing.T) {
+	res, err := DecodeStream(strings.NewReader(`[{"email":"dup@example.com"}]`), StreamOptions{}, func(int, *sample) error {
+		return errors.New("email already exists")
+	})
+	if err != nil || len(res.Errors) != 1 || res.Errors[0].Message != "email already exists" {
+		t.Fatalf("expected callback error to be recorded, got %+v %v", res, err)
+	}
+
+	if _, err := DecodeStream(strings.NewReader(`{"email":"a@example.com"}`), StreamOptions{}, func(int, *sample) error { return nil }); !errors.Is(err, ErrNotArray) {
+		t.Fatalf("expected ErrNotArray, got %v", err)
+	}
+	if _, err := DecodeStream(strings.NewReader(`[{"email":"a@example.com"},{"email":"b@example.com"}]`), StreamOptions{MaxItems: 1}, func(int, *sample) error { return nil }); err == nil {
+		t.Fatalf("expected MaxItems to be enforced")
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-playground/validator/v10"
+
+	"github.com/mikko-kohtala/go-api/internal/scrub"
 )
 
 var v = validator.New(validator.WithRequiredStructEnabled())
@@ -24,13 +28,89 @@ func init() {
 	})
 }
 
-// Errors represents field validation errors keyed by JSON field name.
-type Errors map[string]string
+// Violation is one constraint a request value failed.
+type Violation struct {
+	// Pointer locates the value in the request body as a JSON Pointer
+	// (RFC 6901), e.g. "/address/street/0"; "" is the whole body.
+	Pointer string `json:"pointer"`
+	// Field is the JSON name of the value, the last segment of Pointer.
+	Field string `json:"field"`
+	// Rule is the violated constraint, e.g. "required", "min" or "oneof".
+	Rule string `json:"rule"`
+	// Params are the constraint's parameters, e.g. {"min": 3} or
+	// {"oneof": ["a", "b"]}.
+	Params map[string]any `json:"params,omitempty"`
+	// Value is the rejected value, strings cut to 256 bytes; omitted for
+	// missing values, larger values and fields holding secrets.
+	Value   any    `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// maxEchoedBytes bounds the rejected values violations repeat back.
+const maxEchoedBytes = 256
+
+// secret reports whether the values of a field must not be repeated in
+// violations: fields tagged secret:"true", as in config.Config, and fields
+// whose JSON name looks like a credential (see scrub.IsSensitiveKey).
+func secret(fld reflect.StructField, name string) bool {
+	return fld.Tag.Get("secret") == "true" || scrub.IsSensitiveKey(name)
+}
+
+// Errors lists the violations found in a request, in field order. As an
+// error, e.g. returned from a handler, it is answered with the 400 of
+// response.ValidationFailed.
+type Errors []Violation
+
+func (e Errors) Error() string {
+	if len(e) == 0 {
+		return "validation failed"
+	}
+	return fmt.Sprintf("validation failed: %s: %s", e[0].Pointer, e[0].Message)
+}
+
+// Fields returns the messages keyed by field name, the flat form error
+// responses carried before violations had pointers. Nested fields of the
+// same name share one entry.
+func (e Errors) Fields() map[string]string {
+	if len(e) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(e))
+	for _, v := range e {
+		out[v.Field] = v.Message
+	}
+	return out
+}
+
+// Pointer returns the JSON Pointer of the path segments, escaping '~' and '/'.
+func Pointer(segments ...string) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(pointerEscaper.Replace(s))
+	}
+	return b.String()
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// ErrEmptyBody is returned when the request has no body to decode.
+var ErrEmptyBody = errors.New("empty body")
+
+// TooLarge reports whether err was caused by the body exceeding the limit set
+// with http.MaxBytesReader, returning that limit.
+func TooLarge(err error) (int64, bool) {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return mbe.Limit, true
+	}
+	return 0, false
+}
 
 // BindAndValidate decodes JSON into dst (disallowing unknown fields) and validates it.
 func BindAndValidate(r *http.Request, dst any) (Errors, error) {
 	if r.Body == nil {
-		return nil, errors.New("empty body")
+		return nil, ErrEmptyBody
 	}
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -39,17 +119,126 @@ func BindAndValidate(r *http.Request, dst any) (Errors, error) {
 	}
 	if err := v.Struct(dst); err != nil {
 		if verrs, ok := err.(validator.ValidationErrors); ok {
-			out := Errors{}
-			for _, fe := range verrs {
-				out[fe.Field()] = humanMessage(fe)
-			}
-			return out, nil
+			return violations(dst, verrs), nil
 		}
 		return nil, err
 	}
 	return nil, nil
 }
 
+func violations(dst any, verrs validator.ValidationErrors) Errors {
+	out := make(Errors, 0, len(verrs))
+	for _, fe := range verrs {
+		segments := namespaceSegments(fe.Namespace())
+		v := Violation{
+			Pointer: Pointer(segments...),
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Params:  params(fe),
+			Message: humanMessage(fe),
+		}
+		if fld, _ := structField(reflect.TypeOf(dst), fe.StructNamespace()); !secret(fld, fe.Field()) {
+			v.Value = rejected(fe)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// structField returns the field of t a validator struct namespace such as
+// "Request.Address.Street[0]" names, following pointers, slices and maps.
+func structField(t reflect.Type, ns string) (reflect.StructField, bool) {
+	var fld reflect.StructField
+	parts := strings.Split(ns, ".")
+	if len(parts) < 2 {
+		return fld, false
+	}
+	for _, part := range parts[1:] {
+		name, _, _ := strings.Cut(part, "[")
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return fld, false
+		}
+		var ok bool
+		if fld, ok = t.FieldByName(name); !ok {
+			return fld, false
+		}
+		t = fld.Type
+	}
+	return fld, true
+}
+
+// namespaceSegments splits a validator namespace such as
+// "Request.address.street[0]" into pointer segments ("address", "street",
+// "0"), dropping the top-level type name.
+func namespaceSegments(ns string) []string {
+	var segments []string
+	for i, part := range strings.Split(ns, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if i > 0 {
+			segments = append(segments, name)
+		}
+		for rest != "" {
+			var index string
+			index, rest, _ = strings.Cut(rest, "]")
+			segments = append(segments, index)
+			rest = strings.TrimPrefix(rest, "[")
+		}
+	}
+	return segments
+}
+
+// params returns the constraint's parameter, a number where the rule
+// compares against one and a list for oneof.
+func params(fe validator.FieldError) map[string]any {
+	p := fe.Param()
+	if p == "" {
+		return nil
+	}
+	switch fe.Tag() {
+	case "oneof":
+		return map[string]any{fe.Tag(): strings.Fields(p)}
+	case "min", "max", "len", "gt", "gte", "lt", "lte", "eq", "ne":
+		if n, err := strconv.ParseFloat(p, 64); err == nil {
+			return map[string]any{fe.Tag(): n}
+		}
+	}
+	return map[string]any{fe.Tag(): p}
+}
+
+// rejected returns the value that failed, or nil when it is missing. Strings
+// are cut to maxEchoedBytes; other values longer than that as JSON are left
+// out, so a violation never echoes a large request back.
+func rejected(fe validator.FieldError) any {
+	if fe.Tag() == "required" {
+		return nil
+	}
+	rv := reflect.ValueOf(fe.Value())
+	if !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		return nil
+	}
+	if rv.Kind() == reflect.String {
+		return truncate(rv.String(), maxEchoedBytes)
+	}
+	if b, err := json.Marshal(fe.Value()); err != nil || len(b) > maxEchoedBytes {
+		return nil
+	}
+	return fe.Value()
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 func humanMessage(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
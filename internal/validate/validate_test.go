@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 type sample struct {
@@ -25,7 +27,89 @@ func TestBindAndValidate_FieldNamesFromJSONTags(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected decode error: %v", err)
 	}
-	if errs == nil || errs["email"] == "" {
+	if errs == nil || errs.Fields()["email"] == "" {
 		t.Fatalf("expected field error keyed by 'email', got: %v", errs)
 	}
 }
+
+func TestBindAndValidate_TooLarge(t *testing.T) {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"email":"someone@example.com"}`))
+	r.Body = http.MaxBytesReader(rr, r.Body, 8)
+	_, err := BindAndValidate(r, &sample{})
+	if limit, ok := TooLarge(err); !ok || limit != 8 {
+		t.Fatalf("expected TooLarge with limit 8, got %v (err=%v)", limit, err)
+	}
+}
+
+type address struct {
+	Street []string `json:"street" validate:"min=1,dive,min=3"`
+	Kind   string   `json:"kind" validate:"oneof=home work"`
+}
+
+type nested struct {
+	Name    string            `json:"name" validate:"required"`
+	Address address           `json:"address"`
+	Tags    map[string]string `json:"tags" validate:"dive,max=2"`
+}
+
+func TestBindAndValidate_ViolationsHaveJSONPointers(t *testing.T) {
+	body := `{"address":{"street":["ok street","x"],"kind":"boat"},"tags":{"a/b":"long"}}`
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	errs, err := BindAndValidate(r, &nested{})
+	if err != nil {
+		t.Fatalf("unexpected decode error: %v", err)
+	}
+	byPointer := map[string]Violation{}
+	for _, v := range errs {
+		byPointer[v.Pointer] = v
+	}
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 violations, got %+v", errs)
+	}
+	if v := byPointer["/name"]; v.Rule != "required" || v.Value != nil || v.Field != "name" {
+		t.Fatalf("unexpected /name violation: %+v", v)
+	}
+	if v := byPointer["/address/street/1"]; v.Rule != "min" || v.Value != "x" || v.Params["min"] != 3.0 {
+		t.Fatalf("unexpected /address/street/1 violation: %+v", v)
+	}
+	if v := byPointer["/address/kind"]; v.Value != "boat" || len(v.Params["oneof"].([]string)) != 2 {
+		t.Fatalf("unexpected /address/kind violation: %+v", v)
+	}
+	if v := byPointer["/tags/a~1b"]; v.Rule != "max" {
+		t.Fatalf("expected an escaped map key pointer, got %+v", errs)
+	}
+}
+
+type credentials struct {
+	Password string `json:"password" validate:"min=12"`
+	PIN      string `json:"pin" validate:"len=4" secret:"true"`
+	Bio      string `json:"bio" validate:"max=3"`
+	Scores   []int  `json:"scores" validate:"max=2"`
+}
+
+func TestBindAndValidate_ViolationsDoNotEchoSecretsOrLargeValues(t *testing.T) {
+	bio := strings.Repeat("é", 200)
+	body := `{"password":"hunter2","pin":"12345","bio":"` + bio + `","scores":[` + strings.Repeat("1000,", 100) + `1]}`
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	errs, err := BindAndValidate(r, &credentials{})
+	if err != nil {
+		t.Fatalf("unexpected decode error: %v", err)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 violations, got %+v", errs)
+	}
+	for _, v := range errs {
+		switch v.Field {
+		case "password", "pin", "scores":
+			if v.Value != nil {
+				t.Errorf("expected no value for %s, got %v", v.Field, v.Value)
+			}
+		case "bio":
+			s, _ := v.Value.(string)
+			if len(s) != 256 || !utf8.ValidString(s) || !strings.HasPrefix(bio, s) {
+				t.Errorf("expected bio cut to 256 bytes, got %d bytes", len(s))
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/pkg/webhook"
+)
+
+var (
+	ErrNotFound     = errors.New("webhook subscription not found")
+	ErrKeyNotFound  = errors.New("webhook signing key not found")
+	ErrLastKey      = errors.New("a subscription needs at least one signing key")
+	ErrInvalidURL   = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownEvent = errors.New("unknown webhook event type")
+)
+
+// AllEvents subscribes to every event type.
+const AllEvents = "*"
+
+// Subscription is a consumer endpoint and the events it receives. Secrets are
+// never part of it; they are shown once, when a key is created.
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Keys      []KeyInfo `json:"keys"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyInfo describes a signing key without its secret.
+type KeyInfo struct {
+	ID        string     `json:"id"` // v1, v2, ... in creation order
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // set when a newer key replaced it
+}
+
+// NewKey is a freshly created signing key, the only time its secret is shown.
+type NewKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type signingKey struct {
+	KeyInfo
+	secret string
+}
+
+type subscription struct {
+	Subscription
+	keys    []signingKey
+	version int // of the newest key
+}
+
+// Registry holds the webhook subscriptions in memory.
+type Registry struct {
+	events []string      // known event types
+	grace  time.Duration // replaced keys keep signing this long
+	now    func() time.Time
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+}
+
+// NewRegistry returns an empty registry accepting subscriptions to events.
+// Keys replaced by RotateKey keep signing deliveries for grace.
+func NewRegistry(events []string, grace time.Duration) *Registry {
+	return &Registry{events: events, grace: grace, now: time.Now, subs: make(map[string]*subscription)}
+}
+
+// Events lists the event types subscriptions can choose from.
+func (r *Registry) Events() []string {
+	return slices.Clone(r.events)
+}
+
+// Create adds a subscription of rawURL to events ("*" for all) with a first
+// signing key.
+func (r *Registry) Create(rawURL string, events []string) (Subscription, NewKey, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, NewKey{}, ErrInvalidURL
+	}
+	for _, e := range events {
+		if e != AllEvents && !slices.Contains(r.events, e) {
+			return Subscription{}, NewKey{}, fmt.Errorf("%w: %q", ErrUnknownEvent, e)
+		}
+	}
+	id, err := randomID("whs_")
+	if err != nil {
+		return Subscription{}, NewKey{}, err
+	}
+
+	s := &subscription{Subscription: Subscription{ID: id, URL: u.String(), Events: slices.Clone(events), CreatedAt: r.now()}}
+	key, err := s.addKey(r.now())
+	if err != nil {
+		return Subscription{}, NewKey{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs[id] = s
+	return s.view(), key, nil
+}
+
+// List returns every subscription, oldest first.
+func (r *Registry) List() []Subscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Subscription, 0, len(r.subs))
+	for _, s := range r.subs {
+		s.prune(r.now())
+		out = append(out, s.view())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Get returns the subscription with id.
+func (r *Registry) Get(id string) (Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	s.prune(r.now())
+	return s.view(), nil
+}
+
+// Delete removes the subscription with id.
+func (r *Registry) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.subs, id)
+	return nil
+}
+
+// RotateKey adds a new signing key to the subscription. Keys without an
+// expiry stay valid for the registry's grace period, so deliveries are signed
+// with both until the consumer has switched to the new secret.
+func (r *Registry) RotateKey(id string) (Subscription, NewKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.subs[id]
+	if !ok {
+		return Subscription{}, NewKey{}, ErrNotFound
+	}
+	now := r.now()
+	s.prune(now)
+	expires := now.Add(r.grace)
+	for i := range s.keys {
+		if s.keys[i].ExpiresAt == nil {
+			s.keys[i].ExpiresAt = &expires
+		}
+	}
+	key, err := s.addKey(now)
+	if err != nil {
+		return Subscription{}, NewKey{}, err
+	}
+	return s.view(), key, nil
+}
+
+// RetireKey removes a signing key before it expires, e.g. once the consumer
+// confirms it switched to the new one. The last key cannot be removed.
+func (r *Registry) RetireKey(id, keyID string) (Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	i := slices.IndexFunc(s.keys, func(k signingKey) bool { return k.ID == keyID })
+	if i < 0 {
+		return Subscription{}, ErrKeyNotFound
+	}
+	if len(s.keys) == 1 {
+		return Subscription{}, ErrLastKey
+	}
+	s.keys = slices.Delete(s.keys, i, i+1)
+	return s.view(), nil
+}
+
+// matching returns the subscriptions to event.
+func (r *Registry) matching(event string) []target {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []target
+	for _, s := range r.subs {
+		if !slices.Contains(s.Events, event) && !slices.Contains(s.Events, AllEvents) {
+			continue
+		}
+		out = append(out, target{id: s.ID, url: s.URL})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].id < out[j].id })
+	return out
+}
+
+// keys returns the active signing keys of subscription id, newest first.
+func (r *Registry) keys(id string) ([]webhook.Key, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.subs[id]
+	if !ok {
+		return nil, false
+	}
+	s.prune(r.now())
+	return s.signingKeys(), true
+}
+
+// target is a subscription a delivery goes to.
+type target struct {
+	id  string
+	url string
+}
+
+func (s *subscription) addKey(now time.Time) (NewKey, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return NewKey{}, err
+	}
+	s.version++
+	k := signingKey{
+		KeyInfo: KeyInfo{ID: "v" + strconv.Itoa(s.version), CreatedAt: now},
+		secret:  "whsec_" + base64.RawURLEncoding.EncodeToString(b[:]),
+	}
+	s.keys = append(s.keys, k)
+	return NewKey{ID: k.ID, Secret: k.secret}, nil
+}
+
+// prune drops expired keys, always keeping the newest.
+func (s *subscription) prune(now time.Time) {
+	newest := s.keys[len(s.keys)-1].ID
+	s.keys = slices.DeleteFunc(s.keys, func(k signingKey) bool {
+		return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) && k.ID != newest
+	})
+}
+
+// signingKeys returns the keys deliveries are signed with, newest first.
+func (s *subscription) signingKeys() []webhook.Key {
+	out := make([]webhook.Key, 0, len(s.keys))
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		out = append(out, webhook.Key{ID: s.keys[i].ID, Secret: []byte(s.keys[i].secret)})
+	}
+	return out
+}
+
+func (s *subscription) view() Subscription {
+	v := s.Subscription
+	v.Events = slices.Clone(s.Events)
+	v.Keys = make([]KeyInfo, len(s.keys))
+	for i, k := range s.keys {
+		v.Keys[i] = k.KeyInfo
+	}
+	return v
+}
+
+func randomID(prefix string) (string, error) {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b[:]), nil
+}
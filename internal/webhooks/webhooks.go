@@ -0,0 +1,171 @@
+// Package webhooks delivers application events to the endpoints of webhook
+// subscriptions. Every delivery is signed with the subscription's active keys
+// (see pkg/webhook for the scheme and the consumer-side verifier), sent from
+// the background job pool and retried with backoff when the endpoint fails.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	apierrors "github.com/mikko-kohtala/go-api/internal/errors"
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/internal/metrics"
+	pkglogger "github.com/mikko-kohtala/go-api/pkg/logger"
+	"github.com/mikko-kohtala/go-api/pkg/webhook"
+)
+
+// Event is the JSON body of a delivery. ID is the same for every subscription
+// and every retry, so consumers can deduplicate.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Options configure a Service.
+type Options struct {
+	MaxAttempts int           // delivery attempts per subscription, default 5
+	RetryDelay  time.Duration // delay before the first retry, doubled for each further attempt; default 2s
+}
+
+// Service queues signed deliveries of events to the subscriptions in a Registry.
+type Service struct {
+	registry *Registry
+	pool     *jobs.Pool
+	client   *http.Client
+	opts     Options
+}
+
+// delivery is one event on its way to one subscription.
+type delivery struct {
+	sub     target
+	event   string
+	eventID string
+	body    []byte
+}
+
+// NewService creates a service delivering to registry's subscriptions with
+// client on pool.
+func NewService(registry *Registry, pool *jobs.Pool, client *http.Client, opts Options) *Service {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 2 * time.Second
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Service{registry: registry, pool: pool, client: client, opts: opts}
+}
+
+// Publish queues a delivery of an event of type eventType carrying data to
+// every subscription of that type. Deliveries the pool refuses are kept as
+// dead letters.
+func (s *Service) Publish(ctx context.Context, eventType string, data any) error {
+	targets := s.registry.matching(eventType)
+	if len(targets) == 0 {
+		return nil
+	}
+	id, err := randomID("evt_")
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(Event{ID: id, Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, t := range targets {
+		// New deliveries respect the pool's high-water mark so retries keep
+		// their room; refused ones wait in the dead letters for a replay.
+		job := s.job(delivery{sub: t, event: eventType, eventID: id, body: body}, 1)
+		if err := s.pool.Admit(ctx, job); err != nil {
+			s.pool.Bury(ctx, job, err)
+			errs = append(errs, fmt.Errorf("%s: %w", t.id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) submit(ctx context.Context, d delivery, attempt int) error {
+	return s.pool.Submit(ctx, s.job(d, attempt))
+}
+
+// job delivers d. Replayed from the dead letters it makes one more attempt,
+// retrying further only if the failure is transient and attempts remain.
+func (s *Service) job(d delivery, attempt int) jobs.Job {
+	return jobs.Job{
+		Name:   "webhook",
+		Detail: d.event + " " + d.eventID + " to " + d.sub.id,
+		Run: func(ctx context.Context) error {
+			return s.deliver(ctx, d, attempt)
+		},
+	}
+}
+
+func (s *Service) deliver(ctx context.Context, d delivery, attempt int) error {
+	// Sign at send time so retries use the keys active after a rotation
+	keys, ok := s.registry.keys(d.sub.id)
+	if !ok {
+		return nil // unsubscribed meanwhile
+	}
+	err := s.send(ctx, d, keys)
+	switch {
+	case err == nil:
+		metrics.ObserveWebhookDelivery(d.event, "delivered")
+		return nil
+	case attempt >= s.opts.MaxAttempts || apierrors.IsTerminal(err):
+		metrics.ObserveWebhookDelivery(d.event, "failed")
+		return err
+	}
+
+	metrics.ObserveWebhookDelivery(d.event, "retried")
+	delay := s.opts.RetryDelay << (attempt - 1)
+	l := pkglogger.FromContext(ctx)
+	l.Warn("webhook delivery failed, retrying",
+		slog.String("subscription", d.sub.id),
+		slog.String("event", d.event),
+		slog.Int("attempt", attempt),
+		slog.Duration("retry_in", delay),
+		slog.String("error", err.Error()))
+	time.AfterFunc(delay, func() {
+		if err := s.submit(ctx, d, attempt+1); err != nil {
+			metrics.ObserveWebhookDelivery(d.event, "failed")
+			l.Error("webhook delivery dropped", slog.String("subscription", d.sub.id), slog.String("error", err.Error()))
+			s.pool.Bury(ctx, s.job(d, attempt+1), err)
+		}
+	})
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, d delivery, keys []webhook.Key) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.sub.url, bytes.NewReader(d.body))
+	if err != nil {
+		return apierrors.Terminal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, d.eventID)
+	req.Header.Set(webhook.EventHeader, d.event)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(d.body, time.Now(), keys...))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return apierrors.Retryablef("webhook responded %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return apierrors.Terminalf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
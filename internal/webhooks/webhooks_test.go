@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/jobs"
+	"github.com/mikko-kohtala/go-api/pkg/webhook"
+)
+
+func TestRegistry_RotationKeepsOldKeyDuringGrace(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry([]string{"task.created"}, time.Hour)
+	r.now = func() time.Time { return now }
+
+	if _, _, err := r.Create("ftp://example.com", []string{"task.created"}); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("expected an invalid URL error, got %v", err)
+	}
+	if _, _, err := r.Create("https://example.com/hooks", []string{"task.exploded"}); !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("expected an unknown event error, got %v", err)
+	}
+	sub, first, err := r.Create("https://example.com/hooks", []string{"task.created"})
+	if err != nil || first.ID != "v1" || len(first.Secret) < 40 {
+		t.Fatalf("unexpected subscription %+v key %+v: %v", sub, first, err)
+	}
+
+	sub, second, err := r.RotateKey(sub.ID)
+	if err != nil || second.ID != "v2" || len(sub.Keys) != 2 || sub.Keys[0].ExpiresAt == nil || sub.Keys[1].ExpiresAt != nil {
+		t.Fatalf("unexpected rotation %+v: %v", sub, err)
+	}
+	keys, _ := r.keys(sub.ID)
+	if len(keys) != 2 || keys[0].ID != "v2" || keys[1].ID != "v1" {
+		t.Fatalf("expected both keys, newest first, got %v", keys)
+	}
+
+	now = now.Add(time.Hour)
+	if keys, _ := r.keys(sub.ID); len(keys) != 1 || keys[0].ID != "v2" {
+		t.Fatalf("expected the old key to expire after the grace period, got %v", keys)
+	}
+	if _, err := r.RetireKey(sub.ID, "v2"); !errors.Is(err, ErrLastKey) {
+		t.Fatalf("expected the last key to be kept, got %v", err)
+	}
+}
+
+func TestService_DeliversSignedEventsAndRetries(t *testing.T) {
+	registry := NewRegistry([]string{"task.created", "task.deleted"}, time.Hour)
+	received := make(chan *http.Request, 4)
+	bodies := make(chan []byte, 4)
+	var secret webhook.Key
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := webhook.VerifyRequest(r, []webhook.Key{secret}, webhook.DefaultTolerance)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	sub, key, err := registry.Create(srv.URL, []string{"task.created"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	secret = webhook.Key{ID: key.ID, Secret: []byte(key.Secret)}
+
+	pool := jobs.NewPool(1, 10)
+	defer pool.Shutdown(context.Background())
+	svc := NewService(registry, pool, srv.Client(), Options{RetryDelay: time.Millisecond})
+
+	if err := svc.Publish(context.Background(), "task.deleted", map[string]string{"id": "tsk_1"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if err := svc.Publish(context.Background(), "task.created", map[string]string{"id": "tsk_1"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	select {
+	case r := <-received:
+		var ev Event
+		_ = json.Unmarshal(<-bodies, &ev)
+		if r.Header.Get(webhook.EventHeader) != "task.created" || ev.Type != "task.created" || ev.ID == "" || r.Header.Get(webhook.IDHeader) != ev.ID {
+			t.Fatalf("unexpected delivery %v %+v", r.Header, ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("event was not delivered to %s", sub.ID)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected one retry after the 503 and no unsubscribed events, got %d attempts", attempts)
+	}
+}
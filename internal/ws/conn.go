@@ -0,0 +1,325 @@
+// Package ws is a minimal WebSocket (RFC 6455) server: the upgrade
+// handshake, message framing with ping/pong and close handling, and a hub
+// that groups connections into rooms for broadcasts. It has no
+// extensions (no compression) and no client side, which is all the API
+// needs, without a dependency.
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+// Frame opcodes; Text and Binary are the types of data messages.
+const (
+	continuationFrame             = 0x0
+	Text              MessageType = 0x1
+	Binary            MessageType = 0x2
+	closeFrame                    = 0x8
+	pingFrame                     = 0x9
+	pongFrame                     = 0xA
+)
+
+// Close codes of RFC 6455, section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001 // the server is shutting down
+	CloseProtocolError   = 1002
+	CloseInvalidData     = 1007 // a text message that is not UTF-8
+	ClosePolicyViolation = 1008
+	CloseTooLarge        = 1009
+	CloseTryAgainLater   = 1013 // the client did not keep up with its messages
+	closeNoStatus        = 1005 // received without a code, never sent
+)
+
+// maxControlPayload is the longest payload of a control frame.
+const maxControlPayload = 125
+
+// writeTimeout bounds each frame written, so a peer that stops reading
+// cannot block its writers forever.
+const writeTimeout = 10 * time.Second
+
+// acceptGUID is appended to the client's key to compute Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrBadHandshake is returned by Upgrade for requests that are not
+// WebSocket upgrades.
+var ErrBadHandshake = errors.New("ws: not a websocket handshake")
+
+// CloseError is returned by ReadMessage once the peer has closed the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("ws: closed with %d %s", e.Code, e.Reason)
+}
+
+// Conn is a server side WebSocket connection. One goroutine may read while
+// others write; writes are serialized.
+type Conn struct {
+	conn       net.Conn
+	br         *bufio.Reader
+	maxMessage int64
+
+	wmu       sync.Mutex
+	closeSent bool
+
+	onPong func() // called for each pong received, on the reading goroutine
+}
+
+// Upgrade answers a WebSocket handshake and takes over the connection of r.
+// Messages longer than maxMessage bytes close the connection with
+// CloseTooLarge. On ErrBadHandshake nothing has been written, so the caller
+// can answer the request; other errors leave the connection unusable.
+// Deadlines the server set on the connection are cleared.
+func Upgrade(w http.ResponseWriter, r *http.Request, maxMessage int64) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") || r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		return nil, ErrBadHandshake
+	}
+	if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != 16 {
+		return nil, ErrBadHandshake
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("ws: hijack: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, br: rw.Reader, maxMessage: maxMessage}, nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for a client's key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether a comma-separated header of h lists token.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetReadDeadline bounds the wait for the next frame, pongs included.
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// ReadMessage returns the next data message, answering pings and passing
+// pongs to the pong handler on the way. Once the peer closes the
+// connection it replies to the close and returns a *CloseError. Protocol
+// violations are answered with a close frame and returned as errors.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		msgType MessageType
+		msg     []byte
+		started bool
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case pingFrame:
+			if err := c.writeFrame(pongFrame, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			if c.onPong != nil {
+				c.onPong()
+			}
+			continue
+		case closeFrame:
+			ce := &CloseError{Code: closeNoStatus}
+			if len(payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Reason = string(payload[2:])
+			}
+			code := ce.Code
+			if code == closeNoStatus {
+				code = CloseNormal
+			}
+			_ = c.WriteClose(code, "")
+			return 0, nil, ce
+		case continuationFrame:
+			if !started {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case byte(Text), byte(Binary):
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			started, msgType = true, MessageType(opcode)
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+		if int64(len(msg)+len(payload)) > c.maxMessage {
+			return 0, nil, c.fail(CloseTooLarge, "message too large")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			if msgType == Text && !utf8.Valid(msg) {
+				return 0, nil, c.fail(CloseInvalidData, "text message is not UTF-8")
+			}
+			return msgType, msg, nil
+		}
+	}
+}
+
+// readFrame reads one frame and unmasks its payload.
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0F
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+	length := int64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+	if opcode >= closeFrame && (!fin || length > maxControlPayload) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length > c.maxMessage {
+		return false, 0, nil, c.fail(CloseTooLarge, "message too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail closes the connection with code for a protocol violation and returns
+// the violation as an error.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.WriteClose(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends data as one message of type t.
+func (c *Conn) WriteMessage(t MessageType, data []byte) error {
+	return c.writeFrame(byte(t), data)
+}
+
+// Ping sends a ping; the peer answers with a pong.
+func (c *Conn) Ping() error {
+	return c.writeFrame(pingFrame, nil)
+}
+
+// WriteClose starts the closing handshake with code and reason. Later
+// writes fail; the peer's close arrives through ReadMessage.
+func (c *Conn) WriteClose(code int, reason string) error {
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	return c.writeFrameLocked(closeFrame, payload)
+}
+
+// closing reports whether WriteClose was called.
+func (c *Conn) closing() bool {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.closeSent
+}
+
+// errCloseSent is returned by writes after WriteClose.
+var errCloseSent = errors.New("ws: close already sent")
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return errCloseSent
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked writes one final, unmasked frame; servers do not mask.
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+	head := make([]byte, 2, 10+len(payload))
+	head[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= 125:
+		head[1] = byte(n)
+	case n <= 0xFFFF:
+		head[1] = 126
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head[1] = 127
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(append(head, payload...))
+	return err
+}
+
+// Close closes the underlying connection without a closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
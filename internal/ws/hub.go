@@ -0,0 +1,370 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/ids"
+	"github.com/mikko-kohtala/go-api/internal/response"
+	"github.com/mikko-kohtala/go-api/internal/streams"
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Message is what clients and the server exchange, one per text message.
+// Clients send "join" and "leave" with a room, and "publish" with a room
+// and data for the room's other members. The server answers "joined",
+// "left" and "error", and delivers broadcasts with the type they were sent
+// with ("message" for those of clients) and the sender's connection ID.
+type Message struct {
+	Type  string          `json:"type"`
+	Room  string          `json:"room,omitempty"`
+	From  string          `json:"from,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// ErrOriginNotAllowed is returned by Accept for browsers on other origins.
+var ErrOriginNotAllowed = errors.New("ws: origin not allowed")
+
+// Options configure a Hub.
+type Options struct {
+	PingInterval    time.Duration    // how often connections are pinged; 0 means 30s
+	MaxMessageBytes int64            // longest message a client may send; 0 means 64 KiB
+	SendQueue       int              // messages waiting to be sent to a connection; 0 means 64
+	AllowedOrigins  []string         // Origin values accepted besides the API's own; "*" accepts any
+	Streams         *streams.Tracker // tracks connections for shutdown; nil means streams.Default
+}
+
+// Hub keeps the open connections and the rooms they joined.
+type Hub struct {
+	opts Options
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	rooms   map[string]map[*client]struct{}
+}
+
+// Default is the hub of /api/v1/ws. main replaces it with one configured by
+// the WS_* settings.
+var Default = NewHub(Options{})
+
+// NewHub returns a hub without connections.
+func NewHub(opts Options) *Hub {
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+	if opts.MaxMessageBytes <= 0 {
+		opts.MaxMessageBytes = 64 << 10
+	}
+	if opts.SendQueue <= 0 {
+		opts.SendQueue = 64
+	}
+	if opts.Streams == nil {
+		opts.Streams = streams.Default
+	}
+	return &Hub{opts: opts, clients: map[*client]struct{}{}, rooms: map[string]map[*client]struct{}{}}
+}
+
+// client is one connection of a hub.
+type client struct {
+	id     string
+	hub    *Hub
+	conn   *Conn
+	send   chan []byte
+	rooms  map[string]struct{} // guarded by hub.mu
+	logger *slog.Logger
+	closed sync.Once
+}
+
+// Accept upgrades r to a WebSocket connection and serves it in the
+// background until either side closes it or the server shuts down, so the
+// request's middleware finishes with the handshake. It returns
+// streams.ErrDraining while the server shuts down, ErrOriginNotAllowed and
+// ErrBadHandshake before writing anything, so the caller can answer r.
+// The connection logs with the request's logger and its own conn_id.
+func (h *Hub) Accept(w http.ResponseWriter, r *http.Request) error {
+	if !h.originAllowed(r) {
+		return ErrOriginNotAllowed
+	}
+	stream, err := h.opts.Streams.Open()
+	if err != nil {
+		return err
+	}
+	conn, err := Upgrade(w, r, h.opts.MaxMessageBytes)
+	if err != nil {
+		stream.Close()
+		return err
+	}
+	id, err := ids.Default.New(ids.Socket)
+	if err != nil {
+		stream.Close()
+		conn.Close()
+		return err
+	}
+	c := &client{
+		id:     id,
+		hub:    h,
+		conn:   conn,
+		send:   make(chan []byte, h.opts.SendQueue),
+		rooms:  map[string]struct{}{},
+		logger: logger.FromContext(r.Context()).With(slog.String("conn_id", id)),
+	}
+	// The request's context ends with the handler; the connection outlives it
+	ctx := logger.IntoContext(context.WithoutCancel(r.Context()), c.logger)
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	go c.serve(ctx, stream)
+	return nil
+}
+
+// originAllowed reports whether r comes from a client allowed to connect:
+// one without an Origin header (not a browser), the API's own origin, or
+// one of AllowedOrigins. Browsers send cookies with WebSocket handshakes
+// from any site, so other origins are refused.
+func (h *Hub) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range h.opts.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Broadcast sends a message of type typ with data to every connection in
+// room and returns how many it was queued for. Connections whose queue is
+// full are closed with CloseTryAgainLater rather than holding up the rest.
+func (h *Hub) Broadcast(room, typ string, data any) int {
+	return h.broadcast(room, typ, "", data)
+}
+
+// BroadcastAll sends a message of type typ with data to every connection.
+func (h *Hub) BroadcastAll(typ string, data any) int {
+	return h.broadcast("", typ, "", data)
+}
+
+func (h *Hub) broadcast(room, typ, from string, data any) int {
+	raw, err := response.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	msg, err := json.Marshal(Message{Type: typ, Room: room, From: from, Data: raw})
+	if err != nil {
+		return 0
+	}
+	h.mu.RLock()
+	members := h.clients
+	if room != "" {
+		members = h.rooms[room]
+	}
+	var sent, slow []*client
+	for c := range members {
+		select {
+		case c.send <- msg:
+			sent = append(sent, c)
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range slow {
+		c.close(CloseTryAgainLater, "too many messages queued")
+	}
+	return len(sent)
+}
+
+// Connections returns the number of open connections.
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Members returns the number of connections in room.
+func (h *Hub) Members(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+func (h *Hub) join(c *client, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.rooms[room] == nil {
+		h.rooms[room] = map[*client]struct{}{}
+	}
+	h.rooms[room][c] = struct{}{}
+	c.rooms[room] = struct{}{}
+}
+
+func (h *Hub) leave(c *client, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveLocked(c, room)
+}
+
+func (h *Hub) leaveLocked(c *client, room string) {
+	delete(h.rooms[room], c)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+	delete(c.rooms, room)
+}
+
+// serve reads c's messages until the connection ends, with a writer
+// goroutine sending queued messages and pings, then releases it.
+func (c *client) serve(ctx context.Context, stream *streams.Stream) {
+	h := c.hub
+	c.logger.Info("websocket connected", slog.String("remote", c.conn.RemoteAddr().String()))
+
+	// A client answers every ping, so one that sends nothing for two
+	// intervals is gone
+	idle := 2 * h.opts.PingInterval
+	_ = c.conn.SetReadDeadline(time.Now().Add(idle))
+	c.conn.onPong = func() { c.extend(idle) }
+
+	done := make(chan struct{})
+	go c.write(stream, done)
+	err := c.read(ctx, idle)
+	close(done)
+
+	h.mu.Lock()
+	delete(h.clients, c)
+	for room := range c.rooms {
+		h.leaveLocked(c, room)
+	}
+	h.mu.Unlock()
+	c.conn.Close()
+	stream.Close()
+
+	var ce *CloseError
+	switch {
+	case errors.As(err, &ce):
+		c.logger.Info("websocket closed", slog.Int("code", ce.Code), slog.String("reason", ce.Reason))
+	default:
+		c.logger.Info("websocket closed", slog.String("error", err.Error()))
+	}
+}
+
+// read handles c's messages until the connection fails or closes.
+func (c *client) read(ctx context.Context, idle time.Duration) error {
+	for {
+		typ, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		c.extend(idle)
+		if typ != Text {
+			c.reply(Message{Type: "error", Error: "messages must be JSON text"})
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reply(Message{Type: "error", Error: "invalid message"})
+			continue
+		}
+		c.handle(ctx, msg)
+	}
+}
+
+// extend moves the read deadline idle into the future, unless the server
+// is closing the connection and waits only briefly for the client's reply.
+func (c *client) extend(idle time.Duration) {
+	if !c.conn.closing() {
+		_ = c.conn.SetReadDeadline(time.Now().Add(idle))
+	}
+}
+
+// handle acts on one message of the client.
+func (c *client) handle(ctx context.Context, msg Message) {
+	if msg.Room == "" {
+		c.reply(Message{Type: "error", Error: "room is required"})
+		return
+	}
+	switch msg.Type {
+	case "join":
+		c.hub.join(c, msg.Room)
+		c.reply(Message{Type: "joined", Room: msg.Room})
+	case "leave":
+		c.hub.leave(c, msg.Room)
+		c.reply(Message{Type: "left", Room: msg.Room})
+	case "publish":
+		c.hub.mu.RLock()
+		_, member := c.rooms[msg.Room]
+		c.hub.mu.RUnlock()
+		if !member {
+			c.reply(Message{Type: "error", Room: msg.Room, Error: "join the room before publishing to it"})
+			return
+		}
+		n := c.hub.broadcast(msg.Room, "message", c.id, msg.Data)
+		logger.FromContext(ctx).Debug("websocket message published", slog.String("room", msg.Room), slog.Int("recipients", n))
+	default:
+		c.reply(Message{Type: "error", Error: "unknown message type " + msg.Type})
+	}
+}
+
+// reply queues msg for c alone.
+func (c *client) reply(msg Message) {
+	data, _ := json.Marshal(msg)
+	select {
+	case c.send <- data:
+	default:
+		c.close(CloseTryAgainLater, "too many messages queued")
+	}
+}
+
+// write sends c's queued messages and keepalive pings until done, closing
+// the connection with CloseGoingAway when the server shuts down.
+func (c *client) write(stream *streams.Stream, done <-chan struct{}) {
+	ticker := time.NewTicker(c.hub.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.conn.WriteMessage(Text, msg); err != nil {
+				c.close(0, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.Ping(); err != nil {
+				c.close(0, "")
+				return
+			}
+		case <-stream.Shutdown():
+			// The reader returns with the client's reply, or gives up after a second
+			_ = c.conn.WriteClose(CloseGoingAway, "server shutting down")
+			_ = c.conn.SetReadDeadline(time.Now().Add(time.Second))
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// close ends the connection, with a close frame of code unless it is 0;
+// the reader then fails and serve releases the client.
+func (c *client) close(code int, reason string) {
+	c.closed.Do(func() {
+		go func() {
+			if code != 0 {
+				_ = c.conn.WriteClose(code, reason)
+				c.logger.Warn("websocket closed by server", slog.Int("code", code), slog.String("reason", reason))
+			}
+			c.conn.Close()
+		}()
+	})
+}
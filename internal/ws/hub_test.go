@@ -0,0 +1,301 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/internal/streams"
+)
+
+// testClient speaks just enough of the client side of RFC 6455 for tests.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	pong bool // answer pings
+}
+
+func dial(t *testing.T, srv *httptest.Server, header http.Header) (*testClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	return &testClient{t: t, conn: conn, br: br, pong: true}, resp
+}
+
+func (c *testClient) writeFrame(opcode byte, payload []byte) {
+	c.t.Helper()
+	if err := c.frame(opcode, payload); err != nil {
+		c.t.Fatalf("write frame: %v", err)
+	}
+}
+
+// frame writes one masked frame; unlike writeFrame it is safe to call from
+// goroutines other than the test's.
+func (c *testClient) frame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func (c *testClient) send(msg Message) {
+	data, _ := json.Marshal(msg)
+	c.writeFrame(byte(Text), data)
+}
+
+// next returns the next data or close frame, answering pings. It does not
+// fail the test, so it may run in its own goroutine.
+func (c *testClient) next() (opcode byte, payload []byte, err error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.br, head[:]); err != nil {
+			return 0, nil, err
+		}
+		n := int(head[1] & 0x7F)
+		if n == 126 {
+			var ext [2]byte
+			_, _ = io.ReadFull(c.br, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload = make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		opcode = head[0] & 0x0F
+		if opcode == pingFrame {
+			if c.pong {
+				if err := c.frame(pongFrame, payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		}
+		return opcode, payload, nil
+	}
+}
+
+func (c *testClient) recv() Message {
+	c.t.Helper()
+	opcode, payload, err := c.next()
+	if err != nil || opcode != byte(Text) {
+		c.t.Fatalf("expected a text message, got opcode %d, err %v", opcode, err)
+	}
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		c.t.Fatalf("decode %s: %v", payload, err)
+	}
+	return msg
+}
+
+// expectClose reads until the server's close frame and returns its code.
+func (c *testClient) expectClose() int {
+	c.t.Helper()
+	for {
+		opcode, payload, err := c.next()
+		if err != nil {
+			c.t.Fatalf("expected a close frame, got %v", err)
+		}
+		if opcode == closeFrame {
+			return int(binary.BigEndian.Uint16(payload))
+		}
+	}
+}
+
+func newTestHub(t *testing.T, opts Options) (*Hub, *httptest.Server) {
+	t.Helper()
+	if opts.Streams == nil {
+		opts.Streams = streams.NewTracker()
+	}
+	hub := NewHub(opts)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := hub.Accept(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return hub, srv
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHub_Handshake(t *testing.T) {
+	_, srv := newTestHub(t, Options{})
+	_, resp := dial(t, srv, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	// The example key of RFC 6455, section 1.3
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected Sec-WebSocket-Accept %q", got)
+	}
+}
+
+func TestHub_RejectsOtherOrigins(t *testing.T) {
+	_, srv := newTestHub(t, Options{AllowedOrigins: []string{"https://app.example.com"}})
+	if _, resp := dial(t, srv, http.Header{"Origin": {"https://evil.example"}}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the handshake to be refused, got %d", resp.StatusCode)
+	}
+	if _, resp := dial(t, srv, http.Header{"Origin": {"https://app.example.com"}}); resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected an allowed origin to connect, got %d", resp.StatusCode)
+	}
+}
+
+func TestHub_RoomsAndBroadcasts(t *testing.T) {
+	hub, srv := newTestHub(t, Options{})
+	alice, _ := dial(t, srv, nil)
+	bob, _ := dial(t, srv, nil)
+
+	alice.send(Message{Type: "join", Room: "tasks"})
+	bob.send(Message{Type: "join", Room: "tasks"})
+	if msg := alice.recv(); msg.Type != "joined" || msg.Room != "tasks" {
+		t.Fatalf("expected joined, got %+v", msg)
+	}
+	bob.recv()
+
+	if n := hub.Broadcast("tasks", "task.created", map[string]string{"id": "tsk_1"}); n != 2 {
+		t.Fatalf("expected 2 recipients, got %d", n)
+	}
+	if msg := bob.recv(); msg.Type != "task.created" || string(msg.Data) != `{"id":"tsk_1"}` {
+		t.Fatalf("expected the broadcast, got %+v", msg)
+	}
+	alice.recv()
+
+	alice.send(Message{Type: "publish", Room: "tasks", Data: json.RawMessage(`"hi"`)})
+	if msg := bob.recv(); msg.Type != "message" || msg.From == "" || string(msg.Data) != `"hi"` {
+		t.Fatalf("expected alice's message, got %+v", msg)
+	}
+	alice.recv() // publishers get their own messages too
+
+	bob.send(Message{Type: "leave", Room: "tasks"})
+	bob.recv()
+	if n := hub.Members("tasks"); n != 1 {
+		t.Fatalf("expected 1 member left, got %d", n)
+	}
+
+	bob.send(Message{Type: "publish", Room: "tasks", Data: json.RawMessage(`1`)})
+	if msg := bob.recv(); msg.Type != "error" {
+		t.Fatalf("expected publishing outside the room to fail, got %+v", msg)
+	}
+}
+
+func TestHub_ClosesSilentConnections(t *testing.T) {
+	// Long enough that a busy machine answers pings within the idle
+	// deadline of two intervals
+	hub, srv := newTestHub(t, Options{PingInterval: 250 * time.Millisecond})
+	alive, _ := dial(t, srv, nil)
+	silent, _ := dial(t, srv, nil)
+	silent.pong = false
+
+	aliveErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := alive.next(); err != nil {
+				aliveErr <- err
+				return
+			}
+		}
+	}()
+	for {
+		if _, _, err := silent.next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Fatalf("expected the silent connection to be closed, got %v", err)
+		}
+	}
+	select {
+	case err := <-aliveErr:
+		t.Fatalf("expected the answering connection to stay open, got %v", err)
+	default:
+	}
+	if n := hub.Connections(); n != 1 {
+		t.Fatalf("expected the answering connection to stay, got %d connections", n)
+	}
+}
+
+func TestHub_ClosesOnShutdown(t *testing.T) {
+	tracker := streams.NewTracker()
+	hub, srv := newTestHub(t, Options{Streams: tracker})
+	c, _ := dial(t, srv, nil)
+	waitFor(t, func() bool { return tracker.Active() == 1 })
+
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_, err := tracker.Drain(ctx)
+		drained <- err
+	}()
+	if code := c.expectClose(); code != CloseGoingAway {
+		t.Fatalf("expected close code %d, got %d", CloseGoingAway, code)
+	}
+	c.writeFrame(closeFrame, binary.BigEndian.AppendUint16(nil, CloseGoingAway))
+	if err := <-drained; err != nil {
+		t.Fatalf("expected the connection to end the drain, got %v", err)
+	}
+	if hub.Connections() != 0 {
+		t.Fatal("expected no connections after shutdown")
+	}
+	if _, resp := dial(t, srv, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected new connections to be refused while draining, got %d", resp.StatusCode)
+	}
+}
+
+func TestConn_ClosesOnOversizedMessages(t *testing.T) {
+	_, srv := newTestHub(t, Options{MaxMessageBytes: 16})
+	c, _ := dial(t, srv, nil)
+	c.writeFrame(byte(Text), []byte(strings.Repeat("x", 17)))
+	if code := c.expectClose(); code != CloseTooLarge {
+		t.Fatalf("expected close code %d, got %d", CloseTooLarge, code)
+	}
+}
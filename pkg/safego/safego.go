@@ -0,0 +1,78 @@
+// Package safego starts goroutines that cannot crash the process. A panic is
+// recovered and logged with the logger carried by the context (so request
+// IDs are kept), then passed to the reporter installed with SetReporter,
+// which typically counts it and raises an alert.
+package safego
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"github.com/mikko-kohtala/go-api/pkg/logger"
+)
+
+// Panic describes a recovered panic.
+type Panic struct {
+	Name  string // name given to GoNamed, "goroutine" for Go
+	Value any
+	Stack []byte
+}
+
+// Reporter receives recovered panics.
+type Reporter func(ctx context.Context, p Panic)
+
+var (
+	mu       sync.RWMutex
+	reporter Reporter
+)
+
+// SetReporter installs the function recovered panics are reported to; nil
+// only logs them.
+func SetReporter(r Reporter) {
+	mu.Lock()
+	reporter = r
+	mu.Unlock()
+}
+
+// Go runs fn in a new goroutine, recovering panics and logging returned errors.
+// ctx is passed to fn as is; detach it from the request with
+// context.WithoutCancel when fn must outlive the request.
+func Go(ctx context.Context, fn func(ctx context.Context) error) {
+	GoNamed(ctx, "goroutine", fn)
+}
+
+// GoNamed is Go with a name used in logs and reports.
+func GoNamed(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	go Run(ctx, name, fn)
+}
+
+// Run calls fn on the current goroutine with the same recovery and logging as
+// GoNamed, returning fn's error or one describing the panic.
+func Run(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	l := logger.FromContext(ctx)
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		p := Panic{Name: name, Value: rec, Stack: debug.Stack()}
+		l.Error("goroutine panicked",
+			slog.String("goroutine", name),
+			slog.String("panic", fmt.Sprint(rec)),
+			slog.String("stack", string(p.Stack)))
+		mu.RLock()
+		report := reporter
+		mu.RUnlock()
+		if report != nil {
+			report(ctx, p)
+		}
+		err = fmt.Errorf("%s panicked: %v", name, rec)
+	}()
+	if err = fn(ctx); err != nil {
+		l.Error("goroutine failed", slog.String("goroutine", name), slog.String("error", err.Error()))
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package safego
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGoNamed_RecoversAndReports(t *testing.T) {
+	reports := make(chan Panic, 1)
+	SetReporter(func(_ context.Context, p Panic) { reports <- p })
+	defer SetReporter(nil)
+
+	GoNamed(context.Background(), "webhook", func(context.Context) error {
+		panic("boom")
+	})
+	select {
+	case p := <-reports:
+		if p.Name != "webhook" || p.Value != "boom" || len(p.Stack) == 0 {
+			t.Fatalf("unexpected report: %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic was not reported")
+	}
+}
+
+func TestRun_ReturnsErrors(t *testing.T) {
+	want := errors.New("delivery failed")
+	if err := Run(context.Background(), "x", func(context.Context) error { return want }); err != want {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+	if err := Run(context.Background(), "x", func(context.Context) error { panic("boom") }); err == nil {
+		t.Fatal("expected an error for a panic")
+	}
+}
@@ -0,0 +1,48 @@
+package timestamp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	c := Checker{Skew: 30 * time.Second, Now: func() time.Time { return now }}
+
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"expiry within skew", c.NotExpired(now.Add(-30 * time.Second)), nil},
+		{"expired beyond skew", c.NotExpired(now.Add(-31 * time.Second)), ErrExpired},
+		{"not before within skew", c.NotBefore(now.Add(30 * time.Second)), nil},
+		{"not yet valid", c.NotBefore(now.Add(31 * time.Second)), ErrNotYetValid},
+		{"fresh", c.Fresh(now.Add(-5*time.Minute-30*time.Second), 5*time.Minute), nil},
+		{"too old", c.Fresh(now.Add(-5*time.Minute-31*time.Second), 5*time.Minute), ErrTooOld},
+		{"ahead within skew", c.Fresh(now.Add(30*time.Second), 5*time.Minute), nil},
+		{"in future", c.Fresh(now.Add(31*time.Second), 5*time.Minute), ErrInFuture},
+	}
+	for _, tc := range cases {
+		if !errors.Is(tc.err, tc.want) || (tc.want == nil && tc.err != nil) {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.err, tc.want)
+		}
+	}
+}
+
+func TestParseUnixAndCode(t *testing.T) {
+	if got, err := ParseUnix("1760000000"); err != nil || !got.Equal(time.Unix(1_760_000_000, 0)) {
+		t.Fatalf("ParseUnix = %v, %v", got, err)
+	}
+	_, err := ParseUnix("yesterday")
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+	if code := Code(err); code != "timestamp_invalid" {
+		t.Fatalf("Code = %q", code)
+	}
+	if code := Code(errors.New("other")); code != "" {
+		t.Fatalf("Code of a foreign error = %q, want empty", code)
+	}
+}
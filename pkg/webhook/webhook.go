@@ -0,0 +1,133 @@
+// Package webhook signs webhook deliveries and verifies them on the receiving
+// side. It is published for webhook consumers.
+//
+// A delivery carries
+//
+//	Webhook-Signature: t=1760529600,v2=5257a869...,v1=9f0ce4e1...
+//
+// where t is the Unix time of sending and each keyID=signature pair is the
+// hex HMAC-SHA256 of "<t>.<body>" under one of the subscription's active
+// signing keys. During a key rotation deliveries are signed with both the old
+// and the new key, so a consumer holding either keeps verifying them.
+//
+// A consumer verifies with the keys it holds:
+//
+//	body, err := webhook.VerifyRequest(r, []webhook.Key{{ID: "v2", Secret: []byte(secret)}}, webhook.DefaultTolerance)
+//	if err != nil {
+//		http.Error(w, "invalid signature", http.StatusUnauthorized)
+//		return
+//	}
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikko-kohtala/go-api/pkg/timestamp"
+)
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "Webhook-Signature"
+	IDHeader        = "Webhook-ID"    // unique per delivery; retries repeat it
+	EventHeader     = "Webhook-Event" // the event type, e.g. "task.created"
+)
+
+// DefaultTolerance is how old a delivery's timestamp may be before it is
+// rejected as a possible replay.
+const DefaultTolerance = 5 * time.Minute
+
+// maxBodyBytes bounds the body VerifyRequest reads.
+const maxBodyBytes = 1 << 20
+
+var (
+	ErrMissingSignature = errors.New("webhook: missing or malformed signature header")
+	ErrTimestamp        = errors.New("webhook: timestamp outside the tolerance")
+	ErrNoMatch          = errors.New("webhook: no signature matches a known key")
+)
+
+// Key is a signing secret and its version identifier.
+type Key struct {
+	ID     string // e.g. "v2"; must not contain ',' or '='
+	Secret []byte
+}
+
+// Sign returns the signature header value for body sent at t, with one
+// signature per key.
+func Sign(body []byte, t time.Time, keys ...Key) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	var b strings.Builder
+	b.WriteString("t=" + ts)
+	for _, k := range keys {
+		b.WriteString("," + k.ID + "=" + signature(k.Secret, ts, body))
+	}
+	return b.String()
+}
+
+// Verify checks a signature header against body. It accepts the delivery when
+// the timestamp is within tolerance of now (0 skips the check) and any
+// signature made with one of keys matches.
+func Verify(header string, body []byte, keys []Key, tolerance time.Duration, now time.Time) error {
+	var ts string
+	sigs := map[string][]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		if name == "t" {
+			ts = value
+			continue
+		}
+		sigs[name] = append(sigs[name], value)
+	}
+	sent, err := timestamp.ParseUnix(ts)
+	if err != nil || len(sigs) == 0 {
+		return ErrMissingSignature
+	}
+	if tolerance > 0 {
+		c := timestamp.Checker{Skew: tolerance, Now: func() time.Time { return now }}
+		if c.Fresh(sent, 0) != nil {
+			return ErrTimestamp
+		}
+	}
+	for _, k := range keys {
+		want := signature(k.Secret, ts, body)
+		for _, got := range sigs[k.ID] {
+			if hmac.Equal([]byte(got), []byte(want)) {
+				return nil
+			}
+		}
+	}
+	return ErrNoMatch
+}
+
+// VerifyRequest reads r's body (up to 1 MiB), verifies its signature and
+// returns the body. r.Body is replaced so handlers can read it again.
+func VerifyRequest(r *http.Request, keys []Key, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := Verify(r.Header.Get(SignatureHeader), body, keys, tolerance, time.Now()); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func signature(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify_AcceptsAnyKnownKey(t *testing.T) {
+	body := []byte(`{"type":"task.created"}`)
+	now := time.Unix(1760529600, 0)
+	old := Key{ID: "v1", Secret: []byte("whsec_old")}
+	current := Key{ID: "v2", Secret: []byte("whsec_new")}
+	header := Sign(body, now, current, old)
+
+	if !strings.HasPrefix(header, "t=1760529600,v2=") {
+		t.Fatalf("unexpected header %q", header)
+	}
+	for _, keys := range [][]Key{{old}, {current}, {old, current}} {
+		if err := Verify(header, body, keys, DefaultTolerance, now.Add(time.Minute)); err != nil {
+			t.Fatalf("expected keys %v to verify: %v", keys, err)
+		}
+	}
+	if err := Verify(header, []byte(`{"type":"task.deleted"}`), []Key{current}, DefaultTolerance, now); err != ErrNoMatch {
+		t.Fatalf("expected a tampered body to fail, got %v", err)
+	}
+	if err := Verify(header, body, []Key{{ID: "v2", Secret: []byte("guess")}}, DefaultTolerance, now); err != ErrNoMatch {
+		t.Fatalf("expected a wrong secret to fail, got %v", err)
+	}
+	if err := Verify(header, body, []Key{current}, DefaultTolerance, now.Add(10*time.Minute)); err != ErrTimestamp {
+		t.Fatalf("expected an old delivery to fail, got %v", err)
+	}
+	if err := Verify("v2=abc", body, []Key{current}, 0, now); err != ErrMissingSignature {
+		t.Fatalf("expected a header without timestamp to fail, got %v", err)
+	}
+}
+
+func TestVerifyRequest_RestoresBody(t *testing.T) {
+	body := []byte(`{"id":"whd_1"}`)
+	key := Key{ID: "v1", Secret: []byte("whsec_test")}
+	r := httptest.NewRequest("POST", "/hooks", bytes.NewReader(body))
+	r.Header.Set(SignatureHeader, Sign(body, time.Now(), key))
+
+	got, err := VerifyRequest(r, []Key{key}, DefaultTolerance)
+	if err != nil || string(got) != string(body) {
+		t.Fatalf("unexpected result %q, %v", got, err)
+	}
+	if again, _ := io.ReadAll(r.Body); string(again) != string(body) {
+		t.Fatalf("expected the body to be readable again, got %q", again)
+	}
+}